@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -10,6 +11,7 @@ import (
 	"syscall"
 
 	"github.com/reusedev/uportal-api/pkg/logs"
+	"github.com/reusedev/uportal-api/pkg/mailer"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -79,18 +81,25 @@ func main() {
 	// 6. 创建Gin引擎
 	gin.SetMode(cfg.Server.Mode)
 	engine := gin.New()
+	// 只信任配置的反向代理转发的客户端IP，风控按真实IP统计
+	if err := engine.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logs.Business().Fatal("Invalid trusted proxies", zap.Error(err))
+	}
 
 	// 7. 注册中间件
 	// 注意：中间件的注册顺序很重要
 	engine.Use(middleware.Recovery(logs.Business())) // 恢复中间件应该最先注册
+	engine.Use(middleware.RequestID())               // 请求ID中间件，需在日志中间件之前
 	engine.Use(middleware.Logger(logs.Business()))   // 日志中间件
 	engine.Use(middleware.CORS())
 	engine.Any("/", func(c *gin.Context) {
 		c.AbortWithStatus(http.StatusOK)
 	}) // CORS中间件
 
-	// 8. 注册路由
-	registerRoutes(engine, model.DB, cfg)
+	// 8. 注册路由，后台任务随 ctx 取消退出
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	registerRoutes(ctx, engine, model.DB, cfg)
 
 	// 9. 启动服务器
 	server := &http.Server{
@@ -116,14 +125,22 @@ func main() {
 }
 
 // registerRoutes 注册路由
-func registerRoutes(engine *gin.Engine, db *gorm.DB, cfg *config.Config) {
+func registerRoutes(ctx context.Context, engine *gin.Engine, db *gorm.DB, cfg *config.Config) {
 	// 初始化服务
-	wechatSvc := service.NewWechatService(cfg)
-	authService := service.NewAuthService(db, wechatSvc)
-	tokenService := service.NewTokenService(db)
-	orderService := service.NewOrderService(db)
-	inviteService := service.NewInviteService(db)
-	taskService := service.NewTaskService(db, model.RedisClient, logs.Business(), cfg)
+	wechatSvc := service.NewWechatService(cfg, model.RedisClient)
+	notificationService := service.NewNotificationService(db, model.RedisClient, wechatSvc, cfg)
+	notificationService.Start(ctx)
+	mailSender, err := mailer.New(cfg)
+	if err != nil {
+		logs.Business().Error("Init mailer error", zap.Error(err))
+	}
+	riskService := service.NewRiskService(db, cfg, service.NewRoleService(db))
+	achievementService := service.NewAchievementService(db, cfg, notificationService)
+	inviteService := service.NewInviteService(db, model.RedisClient, cfg, wechatSvc, riskService, achievementService)
+	authService := service.NewAuthService(db, wechatSvc, mailSender, cfg, riskService, inviteService)
+	tokenService := service.NewTokenService(db, notificationService, achievementService, inviteService)
+	orderService := service.NewOrderService(db, notificationService, achievementService, inviteService)
+	taskService := service.NewTaskService(db, model.RedisClient, logs.Business(), cfg, notificationService, riskService, achievementService)
 	paymentService, err := service.NewPaymentService(db, model.RedisClient, orderService, cfg)
 	if err != nil {
 		logs.Business().Error("Init payment service error", zap.Error(err))
@@ -132,6 +149,11 @@ func registerRoutes(engine *gin.Engine, db *gorm.DB, cfg *config.Config) {
 	if err != nil {
 		logs.Business().Error("Init alipay service error", zap.Error(err))
 	}
+	privacyService := service.NewPrivacyService(db, model.RedisClient, cfg)
+	adCallbackService := service.NewAdCallbackService(db, model.RedisClient, cfg, taskService, notificationService)
+	privacyService.Start(ctx)
+	// 管理员模拟用户的每次访问写入管理端审计日志
+	middleware.SetImpersonationRecorder(service.NewAuditService(db))
 
 	// 初始化处理器
 	authHandler := handler.NewAuthHandler(authService)
@@ -140,6 +162,10 @@ func registerRoutes(engine *gin.Engine, db *gorm.DB, cfg *config.Config) {
 	orderHandler := handler.NewOrderHandler(orderService)
 	paymentHandler := handler.NewPaymentHandler(paymentService, alipayService)
 	taskHandler := handler.NewTaskHandler(taskService)
+	notificationHandler := handler.NewNotificationHandler(notificationService)
+	privacyHandler := handler.NewPrivacyHandler(privacyService)
+	adCallbackHandler := handler.NewAdCallbackHandler(adCallbackService)
+	achievementHandler := handler.NewAchievementHandler(achievementService)
 
 	// 注册路由
 	api := engine.Group("/api")
@@ -153,6 +179,8 @@ func registerRoutes(engine *gin.Engine, db *gorm.DB, cfg *config.Config) {
 		// 邀请
 		invite := api.Group("invite", middleware.Auth())
 		handler.RegisterInviteRoutes(invite, inviteHandler)
+		inviteCode := api.Group("invite-codes")
+		handler.RegisterInviteCodeRoutes(inviteCode, inviteHandler)
 
 		// 代币相关路由
 		token := api.Group("/points", middleware.Auth())
@@ -173,7 +201,23 @@ func registerRoutes(engine *gin.Engine, db *gorm.DB, cfg *config.Config) {
 		handler.RegisterPaymentRoutes(api, paymentHandler, middleware.Auth())
 
 		// 用户任务相关路由
-		tasks := api.Group("/reward-tasks")
+		tasks := api.Group("/reward-tasks", middleware.Auth())
 		handler.RegisterTaskRoutes(tasks, taskHandler)
+
+		// 成就与任务挑战
+		achievements := api.Group("/achievements", middleware.Auth())
+		handler.RegisterAchievementRoutes(achievements, achievementHandler)
+
+		// 通知相关路由
+		notification := api.Group("/notifications", middleware.Auth())
+		handler.RegisterNotificationRoutes(notification, notificationHandler)
+
+		// 个人数据导出与账号注销
+		privacy := api.Group("/privacy", middleware.Auth())
+		handler.RegisterPrivacyRoutes(privacy, privacyHandler)
+
+		// 激励视频广告服务端回调，由广告平台调用
+		adCallback := api.Group("/ad-callback")
+		handler.RegisterAdCallbackRoutes(adCallback, adCallbackHandler)
 	}
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -23,13 +24,18 @@ import (
 )
 
 var (
-	configPath string
-	doMigrate  bool
+	configPath     string
+	doMigrate      bool
+	bootstrapAdmin string
 )
 
+// bootstrapPasswordEnv 首个超级管理员的初始密码环境变量，未设置时自动生成
+const bootstrapPasswordEnv = "UPORTAL_BOOTSTRAP_PASSWORD"
+
 func init() {
 	flag.StringVar(&configPath, "config", "config/config.yaml", "config file path")
 	flag.BoolVar(&doMigrate, "migrate", false, "执行数据库迁移")
+	flag.StringVar(&bootstrapAdmin, "bootstrap-admin", "", "创建首个超级管理员（仅在没有任何管理员时可用）后退出，参数为用户名")
 	flag.Parse()
 }
 
@@ -70,6 +76,11 @@ func main() {
 		log.Println("数据库迁移完成。")
 	}
 
+	if bootstrapAdmin != "" {
+		runBootstrapAdmin(model.DB, cfg)
+		return
+	}
+
 	// 4. 初始化Redis
 	if err := model.InitRedis(); err != nil {
 		logs.Business().Fatal("Init redis error", zap.Error(err))
@@ -79,15 +90,22 @@ func main() {
 	// 6. 创建Gin引擎
 	gin.SetMode(cfg.Server.Mode)
 	engine := gin.New()
+	// 只信任配置的反向代理转发的客户端IP，登录锁定依赖真实IP
+	if err := engine.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logs.Business().Fatal("Invalid trusted proxies", zap.Error(err))
+	}
 
 	// 7. 注册中间件
 	// 注意：中间件的注册顺序很重要
 	engine.Use(middleware.Recovery(logs.Business())) // 恢复中间件应该最先注册
+	engine.Use(middleware.RequestID())               // 请求ID中间件，需在日志中间件之前
 	engine.Use(middleware.Logger(logs.Business()))   // 日志中间件
 	engine.Use(middleware.CORS())                    // CORS中间件
 
-	// 8. 注册路由
-	registerRoutes(engine, model.DB, cfg)
+	// 8. 注册路由，后台任务随 ctx 取消退出
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	registerRoutes(ctx, engine, model.DB, cfg)
 
 	// 9. 启动服务器
 	server := &http.Server{
@@ -112,19 +130,68 @@ func main() {
 	logs.Business().Info("Shutting down server...")
 }
 
+// runBootstrapAdmin 创建首个超级管理员，密码取自环境变量，未设置时生成随机密码并只输出这一次
+func runBootstrapAdmin(db *gorm.DB, cfg *config.Config) {
+	adminService := service.NewAdminService(db, nil, cfg, service.NewRoleService(db))
+	admin, password, err := adminService.BootstrapSuperAdmin(context.Background(), bootstrapAdmin, os.Getenv(bootstrapPasswordEnv))
+	if err != nil {
+		logs.Business().Error("创建超级管理员失败", zap.Error(err))
+		log.Printf("创建超级管理员失败：%v", err)
+		return
+	}
+	logs.Business().Info("已创建首个超级管理员", zap.String("username", admin.Username))
+	if admin.MustChangePassword {
+		log.Printf("已创建超级管理员 %s，初始密码：%s（仅显示这一次，首次登录后需修改密码并绑定双因素认证）", admin.Username, password)
+		return
+	}
+	log.Printf("已创建超级管理员 %s，密码取自环境变量 %s", admin.Username, bootstrapPasswordEnv)
+}
+
 // registerRoutes 注册路由
-func registerRoutes(engine *gin.Engine, db *gorm.DB, cfg *config.Config) {
+func registerRoutes(ctx context.Context, engine *gin.Engine, db *gorm.DB, cfg *config.Config) {
 	// 初始化服务
-	adminService := service.NewAdminService(db)
-	taskService := service.NewTaskService(db, model.RedisClient, logs.Business(), cfg)
+	roleService := service.NewRoleService(db)
+	adminService := service.NewAdminService(db, model.RedisClient, cfg, roleService)
+	wechatSvc := service.NewWechatService(cfg, model.RedisClient)
+	notificationService := service.NewNotificationService(db, model.RedisClient, wechatSvc, cfg)
+	notificationService.ResumeBroadcasts(ctx)
+	riskService := service.NewRiskService(db, cfg, roleService)
+	achievementService := service.NewAchievementService(db, cfg, notificationService)
+	taskService := service.NewTaskService(db, model.RedisClient, logs.Business(), cfg, notificationService, riskService, achievementService)
 	configService := service.NewSystemConfigService(db)
 	tokenRecordService := service.NewTokenRecordService(db)
 	loginService := service.NewUserLoginLogService(db)
+	auditService := service.NewAuditService(db)
+	analyticsService := service.NewAnalyticsService(db, model.RedisClient, cfg)
+	analyticsService.Start(ctx)
+	exportService := service.NewExportService(db, cfg)
+	exportService.Start(ctx)
+	bulkService := service.NewBulkService(db, cfg, roleService)
+	bulkService.Start(ctx)
+	userTagService := service.NewUserTagService(db, model.RedisClient, cfg)
+	userTagService.Start(ctx)
+	inviteService := service.NewInviteService(db, model.RedisClient, cfg, wechatSvc, riskService, achievementService)
 
 	// 初始化处理器
 	adminHandler := handler.NewAdminHandler(adminService, loginService, tokenRecordService)
 	taskHandler := handler.NewTaskHandler(taskService)
 	configHandler := handler.NewSystemConfigHandler(configService)
+	notificationHandler := handler.NewNotificationHandler(notificationService)
+	roleHandler := handler.NewRoleHandler(roleService)
+	auditHandler := handler.NewAuditHandler(auditService)
+	analyticsHandler := handler.NewAnalyticsHandler(analyticsService)
+	exportHandler := handler.NewExportHandler(exportService)
+	bulkHandler := handler.NewBulkHandler(bulkService)
+	userTagHandler := handler.NewUserTagHandler(userTagService)
+	riskHandler := handler.NewRiskHandler(riskService)
+	achievementHandler := handler.NewAchievementHandler(achievementService)
+	inviteHandler := handler.NewInviteHandler(inviteService)
+
+	// 按权限生成路由中间件
+	perm := middleware.RequirePermission(roleService)
+	anyPerm := middleware.RequireAnyPermission(roleService)
+	// 按操作类型生成审计中间件，挂在权限校验之后
+	audit := middleware.Audit(auditService)
 
 	// 注册路由
 	api := engine.Group("/admin")
@@ -133,30 +200,72 @@ func registerRoutes(engine *gin.Engine, db *gorm.DB, cfg *config.Config) {
 		{
 			// 认证相关
 			handler.RegisterAdminRoutes(api, adminHandler)
+			// 账号安全（修改密码、双因素认证）
+			security := api.Group("/", middleware.AdminSecurityAuth())
+			handler.RegisterAdminSecurityRoutes(security, adminHandler, audit)
 			// 管理员相关
 			admin := api.Group("/", middleware.AdminAuth())
-			handler.RegisterAdminManagementRoutes(admin, adminHandler)
+			handler.RegisterAdminManagementRoutes(admin, adminHandler, perm, audit)
+			// 数据导出
+			handler.RegisterExportRoutes(admin, exportHandler, perm, audit)
+			// 角色管理
+			roles := api.Group("/roles", middleware.AdminAuth())
+			handler.RegisterRoleRoutes(roles, roleHandler, perm, audit)
+			// 审计日志
+			auditLogs := api.Group("/audit-logs", middleware.AdminAuth())
+			handler.RegisterAuditLogRoutes(auditLogs, auditHandler, perm)
 		}
 		// 客户端用户
 		{
 			user := api.Group("/users", middleware.AdminAuth())
-			handler.RegisterUserManagerRoutes(user, adminHandler)
+			handler.RegisterUserManagerRoutes(user, adminHandler, perm, audit)
+			// 批量操作
+			bulk := api.Group("/users/bulk", middleware.AdminAuth())
+			handler.RegisterBulkRoutes(bulk, bulkHandler, perm, anyPerm, audit)
+			// 用户标签
+			tags := api.Group("/user-tags", middleware.AdminAuth())
+			handler.RegisterUserTagRoutes(tags, userTagHandler, perm, audit)
+		}
+		// 风控管理
+		{
+			risk := api.Group("/risk", middleware.AdminAuth())
+			handler.RegisterRiskRoutes(risk, riskHandler, perm, audit)
 		}
 
 		// 系统配置
 		{
 			configs := api.Group("/configs", middleware.AdminAuth())
-			handler.RegisterSystemConfigRoutes(configs, configHandler)
+			handler.RegisterSystemConfigRoutes(configs, configHandler, perm, audit)
 		}
 		// 代币管理
 		{
 			reward := api.Group("/reward-tasks", middleware.AdminAuth())
-			handler.RegisterRewardTaskRoutes(reward, taskHandler)
+			handler.RegisterRewardTaskRoutes(reward, taskHandler, perm, audit)
+		}
+		// 成就与任务挑战
+		{
+			achievements := api.Group("/achievements", middleware.AdminAuth())
+			handler.RegisterAdminAchievementRoutes(achievements, achievementHandler, perm, audit)
+		}
+		// 邀请活动
+		{
+			campaigns := api.Group("/invite-campaigns", middleware.AdminAuth())
+			handler.RegisterAdminInviteCampaignRoutes(campaigns, inviteHandler, perm, audit)
 		}
 		// 代币消耗规则
 		{
 			reward := api.Group("/token-consume-rules", middleware.AdminAuth())
-			handler.RegisterTokenConsumeRulesRoutes(reward, taskHandler)
+			handler.RegisterTokenConsumeRulesRoutes(reward, taskHandler, perm, audit)
+		}
+		// 通知管理
+		{
+			notification := api.Group("/notifications", middleware.AdminAuth())
+			handler.RegisterAdminNotificationRoutes(notification, notificationHandler, perm, audit)
+		}
+		// 运营数据
+		{
+			analytics := api.Group("/analytics", middleware.AdminAuth())
+			handler.RegisterAnalyticsRoutes(analytics, analyticsHandler, perm)
 		}
 	}
 }
@@ -7,20 +7,35 @@ import (
 	"github.com/reusedev/uportal-api/internal/service"
 	"github.com/reusedev/uportal-api/pkg/config"
 	"github.com/reusedev/uportal-api/pkg/logs"
+	"github.com/reusedev/uportal-api/pkg/mailer"
 	"gorm.io/gorm"
 )
 
 func InitServices(cfg *config.Config, db *gorm.DB, redis *redis.Client) (*service.AuthService, *service.AdminService, *service.TokenService, *service.TaskService, *service.PaymentService, error) {
 	// 初始化微信服务
-	wechatSvc := service.NewWechatService(cfg)
+	wechatSvc := service.NewWechatService(cfg, redis)
+
+	// 初始化邮件服务
+	mailSender, err := mailer.New(cfg)
+	if err != nil {
+		return nil, nil, nil, nil, nil, fmt.Errorf("init mailer error: %v", err)
+	}
+
+	// 初始化风控服务
+	riskSvc := service.NewRiskService(db, cfg, service.NewRoleService(db))
+
+	// 初始化成就和邀请服务，登录、消耗代币时推进进度和结算邀请活动
+	notificationSvc := service.NewNotificationService(db, redis, wechatSvc, cfg)
+	achievementSvc := service.NewAchievementService(db, cfg, notificationSvc)
+	inviteSvc := service.NewInviteService(db, redis, cfg, wechatSvc, riskSvc, achievementSvc)
 
 	// 初始化认证服务
-	authSvc := service.NewAuthService(db, wechatSvc)
+	authSvc := service.NewAuthService(db, wechatSvc, mailSender, cfg, riskSvc, inviteSvc)
 
 	// 初始化其他服务
-	adminSvc := service.NewAdminService(db)
-	tokenSvc := service.NewTokenService(db)
-	taskSvc := service.NewTaskService(db, redis, logs.Business(), cfg)
+	adminSvc := service.NewAdminService(db, redis, cfg, service.NewRoleService(db))
+	tokenSvc := service.NewTokenService(db, notificationSvc, achievementSvc, inviteSvc)
+	taskSvc := service.NewTaskService(db, redis, logs.Business(), cfg, notificationSvc, riskSvc, achievementSvc)
 	paymentSvc, err := service.NewPaymentService(db, redis, nil, cfg)
 	if err != nil {
 		return nil, nil, nil, nil, nil, fmt.Errorf("init payment service error: %v", err)
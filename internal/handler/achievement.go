@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/internal/service"
+	"github.com/reusedev/uportal-api/pkg/consts"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/response"
+)
+
+// AchievementHandler 成就处理器
+type AchievementHandler struct {
+	achievementSvc *service.AchievementService
+}
+
+// NewAchievementHandler 创建成就处理器
+func NewAchievementHandler(achievementSvc *service.AchievementService) *AchievementHandler {
+	return &AchievementHandler{achievementSvc: achievementSvc}
+}
+
+// UpdateAchievementRequest 更新成就请求
+type UpdateAchievementRequest struct {
+	ID int64 `json:"id" binding:"required"`
+	service.AchievementRequest
+}
+
+// UserAchievements 当前用户的成就和任务挑战进度
+func (h *AchievementHandler) UserAchievements(c *gin.Context) {
+	kind := c.Query("kind")
+	if kind != "" && kind != model.AchievementKindAchievement && kind != model.AchievementKindQuest {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的成就类别", nil))
+		return
+	}
+
+	list, err := h.achievementSvc.ListUserAchievements(c.Request.Context(), c.GetString(consts.UserId), kind)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ListResponse(c, list, int64(len(list)))
+}
+
+// UserBadges 当前用户已获得的徽章
+func (h *AchievementHandler) UserBadges(c *gin.Context) {
+	badges, err := h.achievementSvc.ListUserBadges(c.Request.Context(), c.GetString(consts.UserId))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ListResponse(c, badges, int64(len(badges)))
+}
+
+// ListAchievements 成就定义列表
+func (h *AchievementHandler) ListAchievements(c *gin.Context) {
+	var req struct {
+		Kind string `json:"kind" binding:"omitempty,oneof=achievement quest"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	list, err := h.achievementSvc.ListAchievements(c.Request.Context(), req.Kind)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ListResponse(c, list, int64(len(list)))
+}
+
+// CreateAchievement 创建成就
+func (h *AchievementHandler) CreateAchievement(c *gin.Context) {
+	var req service.AchievementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	a, err := h.achievementSvc.CreateAchievement(c.Request.Context(), &req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, a)
+}
+
+// UpdateAchievement 更新成就
+func (h *AchievementHandler) UpdateAchievement(c *gin.Context) {
+	var req UpdateAchievementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	a, err := h.achievementSvc.UpdateAchievement(c.Request.Context(), req.ID, &req.AchievementRequest)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, a)
+}
+
+// DeleteAchievement 删除成就
+func (h *AchievementHandler) DeleteAchievement(c *gin.Context) {
+	var req struct {
+		ID int64 `json:"id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	if err := h.achievementSvc.DeleteAchievement(c.Request.Context(), req.ID); err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, nil)
+}
+
+// RegisterAchievementRoutes 注册用户端成就路由
+func RegisterAchievementRoutes(r *gin.RouterGroup, h *AchievementHandler) {
+	r.GET("/list", h.UserAchievements) // 成就和任务挑战进度，kind 可选 achievement/quest
+	r.GET("/badges", h.UserBadges)     // 已获得的徽章
+}
+
+// RegisterAdminAchievementRoutes 注册成就管理路由
+func RegisterAdminAchievementRoutes(r *gin.RouterGroup, h *AchievementHandler, perm, audit func(string) gin.HandlerFunc) {
+	r.POST("/list", perm(model.PermTasksRead), h.ListAchievements)                                  // 成就列表
+	r.POST("/create", perm(model.PermTasksWrite), audit("achievement.create"), h.CreateAchievement) // 创建成就
+	r.POST("/edit", perm(model.PermTasksWrite), audit("achievement.update"), h.UpdateAchievement)   // 更新成就
+	r.POST("/delete", perm(model.PermTasksWrite), audit("achievement.delete"), h.DeleteAchievement) // 删除成就
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/internal/service"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/response"
+)
+
+// AdCallbackHandler 激励视频广告服务端回调处理器
+type AdCallbackHandler struct {
+	adCallbackService *service.AdCallbackService
+}
+
+// NewAdCallbackHandler 创建激励视频广告回调处理器
+func NewAdCallbackHandler(adCallbackService *service.AdCallbackService) *AdCallbackHandler {
+	return &AdCallbackHandler{adCallbackService: adCallbackService}
+}
+
+// AdMob AdMob 回调，返回非 200 时平台会重试
+func (h *AdCallbackHandler) AdMob(c *gin.Context) {
+	if !h.adCallbackService.Enabled(model.AdNetworkAdMob) {
+		response.Error(c, errors.New(errors.ErrCodeNotFound, "未启用该广告平台回调", nil))
+		return
+	}
+	cb, err := h.adCallbackService.HandleAdMob(c.Request.Context(), c.Request.URL.RawQuery)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, cb)
+}
+
+// Pangle 穿山甲回调，按平台约定返回 {"isValid": bool}
+func (h *AdCallbackHandler) Pangle(c *gin.Context) {
+	h.handleIsValid(c, model.AdNetworkPangle, h.adCallbackService.HandlePangle)
+}
+
+// YLH 优量汇回调，按平台约定返回 {"isValid": bool}
+func (h *AdCallbackHandler) YLH(c *gin.Context) {
+	h.handleIsValid(c, model.AdNetworkYLH, h.adCallbackService.HandleYLH)
+}
+
+// handleIsValid 处理以 isValid 表示是否发放的回调；签名错误和未发放返回 false，内部错误返回 500 由平台重试
+func (h *AdCallbackHandler) handleIsValid(c *gin.Context, network string, handle func(context.Context, string) (*model.AdRewardCallback, error)) {
+	if !h.adCallbackService.Enabled(network) {
+		response.Error(c, errors.New(errors.ErrCodeNotFound, "未启用该广告平台回调", nil))
+		return
+	}
+	cb, err := handle(c.Request.Context(), c.Request.URL.RawQuery)
+	if err != nil {
+		var bizErr *errors.Error
+		if stderrors.As(err, &bizErr) && (bizErr.Code == errors.ErrCodeForbidden || bizErr.Code == errors.ErrCodeInvalidParams) {
+			c.JSON(http.StatusOK, gin.H{"isValid": false})
+			return
+		}
+		response.Error(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"isValid": cb.Status == model.AdCallbackGranted})
+}
+
+// RegisterAdCallbackRoutes 注册激励视频广告回调路由，由广告平台服务端调用，不需要登录
+func RegisterAdCallbackRoutes(r *gin.RouterGroup, h *AdCallbackHandler) {
+	r.GET("/admob", h.AdMob)   // AdMob 服务端验证回调
+	r.GET("/pangle", h.Pangle) // 穿山甲奖励回调
+	r.GET("/ylh", h.YLH)       // 优量汇奖励回调
+}
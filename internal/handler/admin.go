@@ -3,12 +3,13 @@ package handler
 import (
 	"time"
 
-	"github.com/reusedev/uportal-api/pkg/constants"
 	"github.com/reusedev/uportal-api/pkg/consts"
 
 	"github.com/gin-gonic/gin"
+	"github.com/reusedev/uportal-api/internal/model"
 	"github.com/reusedev/uportal-api/internal/service"
 	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/jwt"
 	"github.com/reusedev/uportal-api/pkg/response"
 )
 
@@ -35,6 +36,7 @@ type ListUsersRequest struct {
 	NickName string   `json:"nickname"`
 	Phone    string   `json:"phone"`
 	Status   *int     `json:"status"`
+	TagIDs   []int64  `json:"tag_ids"`
 	Sort     []string `json:"sort" binding:"omitempty,dive,oneof=token_balance created_at updated_at last_login_at"` // 排序字段
 }
 
@@ -69,12 +71,15 @@ func (h *AdminHandler) ListUsers(c *gin.Context) {
 	}
 
 	users, total, err := h.adminService.ListUsers(c.Request.Context(), &service.ListUsersParams{
-		Page:     req.Page,
-		Limit:    req.Limit,
-		NickName: req.NickName,
-		Phone:    req.Phone,
-		Status:   req.Status,
-		Sort:     sortParams,
+		Page:  req.Page,
+		Limit: req.Limit,
+		UserFilter: service.UserFilter{
+			NickName: req.NickName,
+			Phone:    req.Phone,
+			Status:   req.Status,
+			TagIDs:   req.TagIDs,
+		},
+		Sort: sortParams,
 	})
 	if err != nil {
 		response.Error(c, err)
@@ -126,29 +131,22 @@ func (h *AdminHandler) ListUserLoginLogs(c *gin.Context) {
 		return
 	}
 
+	// 解析时间
+	startTime, endTime, err := parseTimeRange(req.StartTime, req.EndTime)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
 	// 构建服务层请求
 	serviceReq := &service.ListUserLoginLogsRequest{
+		LoginLogFilter: service.LoginLogFilter{
+			UserID:    req.UserId,
+			StartTime: startTime,
+			EndTime:   endTime,
+		},
 		PageNum:  req.Page,
 		PageSize: req.Limit,
-		UserID:   req.UserId,
-	}
-	// 解析时间
-	if req.StartTime != "" {
-		startTime, err := time.Parse(constants.TimeFormatDateTime, req.StartTime)
-		if err != nil {
-			response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的开始时间", err))
-			return
-		}
-		serviceReq.StartTime = &startTime
-	}
-
-	if req.EndTime != "" {
-		endTime, err := time.Parse(constants.TimeFormatDateTime, req.EndTime)
-		if err != nil {
-			response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的结束时间", err))
-			return
-		}
-		serviceReq.EndTime = &endTime
 	}
 
 	// 调用服务
@@ -161,6 +159,28 @@ func (h *AdminHandler) ListUserLoginLogs(c *gin.Context) {
 	response.ListResponse(c, resp.List, resp.Total)
 }
 
+// ImpersonateUserRequest 模拟用户请求
+type ImpersonateUserRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+	Reason string `json:"reason" binding:"required,max=255"` // 模拟原因，如工单号
+}
+
+// ImpersonateUser 签发模拟用户的只读令牌
+func (h *AdminHandler) ImpersonateUser(c *gin.Context) {
+	var req ImpersonateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	result, err := h.adminService.ImpersonateUser(c.Request.Context(), c.GetInt64(consts.UserId), c.GetString(consts.UserName), req.UserID, req.Reason)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, result)
+}
+
 // TokenAdjustUser 调整用户代币
 func (h *AdminHandler) TokenAdjustUser(c *gin.Context) {
 	var req TokenUsersRequest
@@ -181,7 +201,7 @@ func (h *AdminHandler) TokenAdjustUser(c *gin.Context) {
 		return
 	}
 	changeAmount := *req.ChangeAmount - beforeToken
-	err, i := h.adminService.CreateTokenRecord(req.UserId, req.Remark, changeAmount, *req.ChangeAmount)
+	err, i := h.adminService.CreateTokenRecord(c.GetInt64(consts.UserId), req.UserId, req.Remark, changeAmount, *req.ChangeAmount)
 	if err != nil {
 		response.Error(c, err)
 		return
@@ -248,12 +268,14 @@ func (h *AdminHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	if err := h.adminService.ResetPassword(c.Request.Context(), id, req.Password, req.OldPassword); err != nil {
+	result, err := h.adminService.ResetPassword(c.Request.Context(), id, req.Password, req.OldPassword)
+	if err != nil {
 		response.Error(c, err)
 		return
 	}
 
-	response.Success(c, nil)
+	// 强制改密后换发新令牌，受限令牌随之失去作用
+	writeAdminLoginResult(c, result)
 }
 
 // ListAdminUsersRequest 获取管理员列表请求
@@ -298,7 +320,7 @@ type AdminLoginRequest struct {
 type CreateAdminRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=32"`
 	Password string `json:"password" binding:"required,min=6,max=32"`
-	Role     string `json:"role" binding:"required,oneof=admin super_admin"`
+	Role     string `json:"role" binding:"required,max=20"`
 	Status   *int8  `json:"status" binding:"omitempty,oneof=0 1"`
 }
 
@@ -306,7 +328,7 @@ type CreateAdminRequest struct {
 type UpdateAdminRequest struct {
 	Id       string `json:"id" binding:"required"`
 	UserName string `json:"username" binding:"required,min=3,max=32"`
-	Role     string `json:"role" binding:"omitempty,oneof=admin super_admin"`
+	Role     string `json:"role" binding:"omitempty,max=20"`
 	Status   *int8  `json:"status" binding:"omitempty,oneof=0 1"`
 }
 
@@ -331,13 +353,43 @@ func (h *AdminHandler) Login(c *gin.Context) {
 		UserAgent: c.GetHeader("User-Agent"),
 	}
 
-	_, token, err := h.adminService.Login(c.Request.Context(), loginReq)
+	result, err := h.adminService.Login(c.Request.Context(), loginReq)
 	if err != nil {
 		response.Error(c, err)
 		return
 	}
-	c.Header(consts.SetToken, token)
-	response.Success(c, nil)
+	writeAdminLoginResult(c, result)
+}
+
+// AdminLoginResponse 管理员登录响应
+type AdminLoginResponse struct {
+	RequireTwoFactor      bool   `json:"require_2fa"`               // 需要输入动态码，凭 challenge_token 调用 /auth/2fa/verify
+	ChallengeToken        string `json:"challenge_token,omitempty"` // 登录挑战凭证
+	MustChangePassword    bool   `json:"must_change_password"`      // 令牌仅可用于修改密码
+	RequireTwoFactorSetup bool   `json:"require_2fa_setup"`         // 令牌仅可用于绑定双因素认证
+}
+
+// writeAdminLoginResult 令牌通过响应头下发，响应体说明下一步操作
+func writeAdminLoginResult(c *gin.Context, result *service.AdminLoginResult) {
+	if result.Token != "" {
+		c.Header(consts.SetToken, result.Token)
+	}
+	response.Success(c, AdminLoginResponse{
+		RequireTwoFactor:      result.ChallengeToken != "",
+		ChallengeToken:        result.ChallengeToken,
+		MustChangePassword:    result.Scope == jwt.ScopePasswordChange,
+		RequireTwoFactorSetup: result.Scope == jwt.ScopeTwoFactorSetup,
+	})
+}
+
+// GetPermissions 获取当前管理员的权限列表
+func (h *AdminHandler) GetPermissions(c *gin.Context) {
+	perms, err := h.adminService.GetPermissions(c.Request.Context(), c.GetInt64(consts.UserId))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, perms)
 }
 
 // CreateAdmin 创建管理员
@@ -348,7 +400,7 @@ func (h *AdminHandler) CreateAdmin(c *gin.Context) {
 		return
 	}
 
-	admin, err := h.adminService.CreateAdmin(c.Request.Context(), &service.CreateAdminRequest{
+	admin, err := h.adminService.CreateAdmin(c.Request.Context(), c.GetInt64(consts.UserId), &service.CreateAdminRequest{
 		Username: req.Username,
 		Password: req.Password,
 		Role:     req.Role,
@@ -370,7 +422,7 @@ func (h *AdminHandler) UpdateAdmin(c *gin.Context) {
 		return
 	}
 
-	err := h.adminService.UpdateAdmin(c.Request.Context(), req.Id, &service.UpdateAdminRequest{
+	err := h.adminService.UpdateAdmin(c.Request.Context(), c.GetInt64(consts.UserId), req.Id, &service.UpdateAdminRequest{
 		UserName: req.UserName,
 		Role:     req.Role,
 		Status:   req.Status,
@@ -391,7 +443,7 @@ func (h *AdminHandler) DeleteAdmin(c *gin.Context) {
 		return
 	}
 
-	err := h.adminService.DeleteAdmin(c.Request.Context(), req.Id)
+	err := h.adminService.DeleteAdmin(c.Request.Context(), c.GetInt64(consts.UserId), req.Id)
 	if err != nil {
 		response.Error(c, err)
 		return
@@ -419,31 +471,23 @@ func (h *AdminHandler) ListTokenRecords(c *gin.Context) {
 		return
 	}
 
-	// 构建服务层请求
-	serviceReq := &service.ListTokenRecordsRequest{
-		UserID:     req.UserID,
-		ChangeType: req.ChangeType,
-		PageNum:    req.Page,
-		PageSize:   req.Limit,
-	}
-
 	// 解析时间
-	if req.StartTime != "" {
-		startTime, err := time.Parse(constants.TimeFormatDateTime, req.StartTime)
-		if err != nil {
-			response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的开始时间", err))
-			return
-		}
-		serviceReq.StartTime = &startTime
+	startTime, endTime, err := parseTimeRange(req.StartTime, req.EndTime)
+	if err != nil {
+		response.Error(c, err)
+		return
 	}
 
-	if req.EndTime != "" {
-		endTime, err := time.Parse(constants.TimeFormatDateTime, req.EndTime)
-		if err != nil {
-			response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的结束时间", err))
-			return
-		}
-		serviceReq.EndTime = &endTime
+	// 构建服务层请求
+	serviceReq := &service.ListTokenRecordsRequest{
+		TokenRecordFilter: service.TokenRecordFilter{
+			UserID:     req.UserID,
+			ChangeType: req.ChangeType,
+			StartTime:  startTime,
+			EndTime:    endTime,
+		},
+		PageNum:  req.Page,
+		PageSize: req.Limit,
 	}
 
 	// 调用服务
@@ -460,27 +504,46 @@ func (h *AdminHandler) ListTokenRecords(c *gin.Context) {
 func RegisterAdminRoutes(r *gin.RouterGroup, h *AdminHandler) {
 	// 公开路由
 	r.POST("/auth/login", h.Login)
-	r.POST("/managers/create", h.CreateAdmin)
-
+	r.POST("/auth/2fa/verify", h.VerifyLoginTwoFactor)
+	r.POST("/auth/invitations/info", h.GetInvitation)      // 查看邀请
+	r.POST("/auth/invitations/accept", h.AcceptInvitation) // 接受邀请并设置密码
 }
 
-// RegisterUserManagerRoutes 注册用户列表路由（普通用户可访问）
-func RegisterUserManagerRoutes(r *gin.RouterGroup, h *AdminHandler) {
+// RegisterUserManagerRoutes 注册用户管理路由
+func RegisterUserManagerRoutes(r *gin.RouterGroup, h *AdminHandler, perm, audit func(string) gin.HandlerFunc) {
 	{
-		r.POST("/list", h.ListUsers)                 // 获取用户列表
-		r.GET("/:id", h.GetUser)                     // 获取用户详情
-		r.POST("/operate", h.UpdateUser)             // 更新用户状态
-		r.POST("/tokens/adjust", h.TokenAdjustUser)  // 调整用户代币
-		r.POST("/login-logs", h.ListUserLoginLogs)   // 获取用户登录日志
-		r.POST("/token-records", h.ListTokenRecords) // 获取用户代币记录
+		r.POST("/list", perm(model.PermUsersRead), h.ListUsers)                                                // 获取用户列表
+		r.GET("/:id", perm(model.PermUsersRead), h.GetUser)                                                    // 获取用户详情
+		r.POST("/operate", perm(model.PermUsersWrite), audit("user.update"), h.UpdateUser)                     // 更新用户状态
+		r.POST("/tokens/adjust", perm(model.PermTokensAdjust), audit("user.tokens.adjust"), h.TokenAdjustUser) // 调整用户代币
+		r.POST("/impersonate", perm(model.PermUsersImpersonate), audit("user.impersonate"), h.ImpersonateUser) // 签发模拟用户的只读令牌
+		r.POST("/login-logs", perm(model.PermUsersRead), h.ListUserLoginLogs)                                  // 获取用户登录日志
+		r.POST("/token-records", perm(model.PermTokensRead), h.ListTokenRecords)                               // 获取用户代币记录
 	}
 }
 
+// RegisterAdminSecurityRoutes 注册账号安全路由，受限令牌（强制改密、待绑定2FA）也可访问
+func RegisterAdminSecurityRoutes(r *gin.RouterGroup, h *AdminHandler, audit func(string) gin.HandlerFunc) {
+	r.POST("/auth/change-password", audit("admin.password.change"), h.ResetPassword)                 // 修改密码
+	r.GET("/auth/2fa", h.GetTwoFactorStatus)                                                         // 2FA 状态
+	r.POST("/auth/2fa/setup", h.SetupTwoFactor)                                                      // 获取绑定二维码
+	r.POST("/auth/2fa/enable", audit("admin.2fa.enable"), h.EnableTwoFactor)                         // 校验动态码并启用
+	r.POST("/auth/2fa/disable", audit("admin.2fa.disable"), h.DisableTwoFactor)                      // 关闭 2FA
+	r.POST("/auth/2fa/recovery-codes", audit("admin.2fa.recovery_codes"), h.RegenerateRecoveryCodes) // 重新生成恢复码
+}
+
 // RegisterAdminManagementRoutes 注册管理员管理路由
-func RegisterAdminManagementRoutes(r *gin.RouterGroup, h *AdminHandler) {
+func RegisterAdminManagementRoutes(r *gin.RouterGroup, h *AdminHandler, perm, audit func(string) gin.HandlerFunc) {
+	r.GET("/auth/permissions", h.GetPermissions) // 当前管理员的权限
+
 	// 管理员管理路由
-	r.POST("/auth/change-password", h.ResetPassword) // 获取管理员列表
-	r.POST("/managers/list", h.ListAdminUsers)       // 获取管理员列表
-	r.POST("/managers/edit", h.UpdateAdmin)          // 更新管理员信息
-	r.POST("/managers/delete", h.DeleteAdmin)        // 删除管理员
+	r.POST("/managers/list", perm(model.PermAdminsRead), h.ListAdminUsers)                        // 获取管理员列表
+	r.POST("/managers/create", perm(model.PermAdminsWrite), audit("admin.create"), h.CreateAdmin) // 创建管理员
+	r.POST("/managers/edit", perm(model.PermAdminsWrite), audit("admin.update"), h.UpdateAdmin)   // 更新管理员信息
+	r.POST("/managers/delete", perm(model.PermAdminsWrite), audit("admin.delete"), h.DeleteAdmin) // 删除管理员
+
+	// 管理员邀请
+	r.POST("/managers/invitations/list", perm(model.PermAdminsRead), h.ListInvitations)                                       // 邀请列表
+	r.POST("/managers/invitations/create", perm(model.PermAdminsWrite), audit("admin.invitation.create"), h.CreateInvitation) // 创建邀请
+	r.POST("/managers/invitations/revoke", perm(model.PermAdminsWrite), audit("admin.invitation.revoke"), h.RevokeInvitation) // 撤销邀请
 }
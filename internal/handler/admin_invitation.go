@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/reusedev/uportal-api/internal/service"
+	"github.com/reusedev/uportal-api/pkg/consts"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/response"
+)
+
+// CreateInvitationRequest 创建管理员邀请请求
+type CreateInvitationRequest struct {
+	Username string  `json:"username" binding:"required,min=3,max=32"`
+	Role     string  `json:"role" binding:"required,max=20"`
+	Note     *string `json:"note" binding:"omitempty,max=255"`
+}
+
+// ListInvitationsRequest 管理员邀请列表请求
+type ListInvitationsRequest struct {
+	Page  int `json:"page" binding:"required,min=1"`
+	Limit int `json:"limit" binding:"required,min=1,max=100"`
+}
+
+// RevokeInvitationRequest 撤销邀请请求
+type RevokeInvitationRequest struct {
+	ID int64 `json:"id" binding:"required"`
+}
+
+// InvitationTokenRequest 查看邀请请求
+type InvitationTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// AcceptInvitationRequest 接受邀请请求
+type AcceptInvitationRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// CreateInvitation 创建管理员邀请
+func (h *AdminHandler) CreateInvitation(c *gin.Context) {
+	var req CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	result, err := h.adminService.CreateInvitation(c.Request.Context(), c.GetInt64(consts.UserId), &service.CreateInvitationRequest{
+		Username: req.Username,
+		Role:     req.Role,
+		Note:     req.Note,
+	})
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, result)
+}
+
+// ListInvitations 管理员邀请列表
+func (h *AdminHandler) ListInvitations(c *gin.Context) {
+	var req ListInvitationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	list, total, err := h.adminService.ListInvitations(c.Request.Context(), req.Page, req.Limit)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ListResponse(c, list, total)
+}
+
+// RevokeInvitation 撤销管理员邀请
+func (h *AdminHandler) RevokeInvitation(c *gin.Context) {
+	var req RevokeInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	if err := h.adminService.RevokeInvitation(c.Request.Context(), c.GetInt64(consts.UserId), req.ID); err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, nil)
+}
+
+// GetInvitation 被邀请人查看邀请信息
+func (h *AdminHandler) GetInvitation(c *gin.Context) {
+	var req InvitationTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	info, err := h.adminService.GetInvitation(c.Request.Context(), req.Token)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, info)
+}
+
+// AcceptInvitation 被邀请人设置密码并开通账号
+func (h *AdminHandler) AcceptInvitation(c *gin.Context) {
+	var req AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	result, err := h.adminService.AcceptInvitation(c.Request.Context(), req.Token, req.Password)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	writeAdminLoginResult(c, result)
+}
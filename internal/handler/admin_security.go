@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/reusedev/uportal-api/internal/service"
+	"github.com/reusedev/uportal-api/pkg/consts"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/response"
+)
+
+// VerifyLoginTwoFactorRequest 登录第二步请求
+type VerifyLoginTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required_without=RecoveryCode"`
+	RecoveryCode   string `json:"recovery_code" binding:"required_without=Code"`
+}
+
+// TwoFactorCodeRequest 动态码请求
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// DisableTwoFactorRequest 关闭 2FA 请求
+type DisableTwoFactorRequest struct {
+	Password     string `json:"password" binding:"required"`
+	Code         string `json:"code" binding:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code" binding:"required_without=Code"`
+}
+
+// EnableTwoFactorResponse 启用 2FA 响应
+type EnableTwoFactorResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"` // 恢复码，仅展示这一次
+}
+
+// VerifyLoginTwoFactor 登录第二步：校验动态码或恢复码
+func (h *AdminHandler) VerifyLoginTwoFactor(c *gin.Context) {
+	var req VerifyLoginTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	result, err := h.adminService.VerifyLoginTwoFactor(c.Request.Context(), &service.AdminTwoFactorLoginRequest{
+		ChallengeToken: req.ChallengeToken,
+		Code:           req.Code,
+		RecoveryCode:   req.RecoveryCode,
+	})
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	writeAdminLoginResult(c, result)
+}
+
+// GetTwoFactorStatus 获取 2FA 状态
+func (h *AdminHandler) GetTwoFactorStatus(c *gin.Context) {
+	status, err := h.adminService.GetTwoFactorStatus(c.Request.Context(), c.GetInt64(consts.UserId))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, status)
+}
+
+// SetupTwoFactor 生成绑定用的密钥和二维码链接
+func (h *AdminHandler) SetupTwoFactor(c *gin.Context) {
+	setup, err := h.adminService.SetupTwoFactor(c.Request.Context(), c.GetInt64(consts.UserId))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, setup)
+}
+
+// EnableTwoFactor 校验动态码并启用 2FA
+func (h *AdminHandler) EnableTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	codes, result, err := h.adminService.EnableTwoFactor(c.Request.Context(), c.GetInt64(consts.UserId), req.Code)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	// 绑定前持有的可能是受限令牌，这里换发新令牌
+	c.Header(consts.SetToken, result.Token)
+	response.Success(c, EnableTwoFactorResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor 关闭 2FA
+func (h *AdminHandler) DisableTwoFactor(c *gin.Context) {
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	err := h.adminService.DisableTwoFactor(c.Request.Context(), c.GetInt64(consts.UserId),
+		req.Password, req.Code, req.RecoveryCode)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, nil)
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码
+func (h *AdminHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	codes, err := h.adminService.RegenerateRecoveryCodes(c.Request.Context(), c.GetInt64(consts.UserId), req.Code)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, EnableTwoFactorResponse{RecoveryCodes: codes})
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/internal/service"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/response"
+)
+
+// AnalyticsHandler 运营数据处理器
+type AnalyticsHandler struct {
+	analyticsService *service.AnalyticsService
+}
+
+// NewAnalyticsHandler 创建运营数据处理器
+func NewAnalyticsHandler(analyticsService *service.AnalyticsService) *AnalyticsHandler {
+	return &AnalyticsHandler{analyticsService: analyticsService}
+}
+
+// Overview 按日期区间和粒度查询运营数据
+func (h *AnalyticsHandler) Overview(c *gin.Context) {
+	var req service.AnalyticsQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	result, err := h.analyticsService.Query(c.Request.Context(), &req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, result)
+}
+
+// RegisterAnalyticsRoutes 注册运营数据路由
+func RegisterAnalyticsRoutes(r *gin.RouterGroup, h *AnalyticsHandler, perm func(string) gin.HandlerFunc) {
+	r.POST("/overview", perm(model.PermAnalyticsRead), h.Overview) // 运营数据汇总
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/internal/service"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/logs"
+	"github.com/reusedev/uportal-api/pkg/response"
+	"go.uber.org/zap"
+)
+
+// AuditHandler 审计日志处理器
+type AuditHandler struct {
+	auditService *service.AuditService
+}
+
+// NewAuditHandler 创建审计日志处理器
+func NewAuditHandler(auditService *service.AuditService) *AuditHandler {
+	return &AuditHandler{auditService: auditService}
+}
+
+// ListAuditLogs 审计日志列表
+func (h *AuditHandler) ListAuditLogs(c *gin.Context) {
+	var req service.ListAuditLogsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	list, total, err := h.auditService.ListAuditLogs(c.Request.Context(), &req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ListResponse(c, list, total)
+}
+
+// GetAuditLog 审计日志详情
+func (h *AuditHandler) GetAuditLog(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的日志ID", err))
+		return
+	}
+
+	log, err := h.auditService.GetAuditLog(c.Request.Context(), id)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, log)
+}
+
+// ExportAuditLogs 导出审计日志为 CSV 文件
+func (h *AuditHandler) ExportAuditLogs(c *gin.Context) {
+	var req service.ExportAuditLogsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+	if err := h.auditService.ValidateExport(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	filename := fmt.Sprintf("audit-logs-%s.csv", time.Now().Format("20060102150405"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	// 响应头已发出，导出中途出错只能记录日志，客户端会收到不完整的文件
+	if err := h.auditService.ExportAuditLogs(c.Request.Context(), &req, c.Writer); err != nil {
+		logs.Business().Error("导出审计日志失败", zap.Error(err))
+	}
+}
+
+// RegisterAuditLogRoutes 注册审计日志路由
+func RegisterAuditLogRoutes(r *gin.RouterGroup, h *AuditHandler, perm func(string) gin.HandlerFunc) {
+	r.POST("/list", perm(model.PermAuditRead), h.ListAuditLogs)     // 审计日志列表
+	r.GET("/:id", perm(model.PermAuditRead), h.GetAuditLog)         // 审计日志详情
+	r.POST("/export", perm(model.PermAuditRead), h.ExportAuditLogs) // 导出 CSV
+}
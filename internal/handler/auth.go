@@ -87,6 +87,7 @@ func (h *AuthHandler) ThirdPartyLogin(c *gin.Context) {
 	req.Platform = c.GetHeader("X-Platform")
 	req.IP = c.ClientIP()
 	req.UserAgent = c.GetHeader("User-Agent")
+	req.DeviceID = c.GetHeader("X-Device-ID")
 
 	user, token, err := h.authService.ThirdPartyLogin(c.Request.Context(), &req)
 	if err != nil {
@@ -128,11 +129,21 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 	if req.AvatarURL != nil {
 		updates["avatar_url"] = req.AvatarURL
 	}
+	if req.Language != nil && *req.Language != "" {
+		updates["language"] = *req.Language
+	}
+	if req.Timezone != nil {
+		if err := h.authService.UpdateTimezone(c.Request.Context(), userID, *req.Timezone); err != nil {
+			response.Error(c, err)
+			return
+		}
+	}
 
-	err := h.authService.UpdateUser(c.Request.Context(), userID, updates)
-	if err != nil {
-		response.Error(c, err)
-		return
+	if len(updates) > 0 {
+		if err := h.authService.UpdateUser(c.Request.Context(), userID, updates); err != nil {
+			response.Error(c, err)
+			return
+		}
 	}
 
 	response.Success(c, nil)
@@ -142,8 +153,8 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	userID := c.GetString("user_id")
 	var req struct {
-		OldPassword string `json:"old_password" binding:"required,min=6,max=32"`
-		NewPassword string `json:"new_password" binding:"required,min=6,max=32"`
+		OldPassword string `json:"old_password" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -160,6 +171,117 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	response.Success(c, nil)
 }
 
+// VerifyEmail 验证邮箱
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req service.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	if err := h.authService.VerifyEmail(c.Request.Context(), &req); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// ResendVerification 重新发送验证邮件
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	if err := h.authService.ResendVerification(c.Request.Context(), req.Email); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// ForgotPassword 忘记密码，发送重置邮件
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	if err := h.authService.ForgotPassword(c.Request.Context(), req.Email); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// ResetPassword 重置密码
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req service.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	if err := h.authService.ResetPassword(c.Request.Context(), &req); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// BindWechatPhone 绑定微信手机号
+func (h *AuthHandler) BindWechatPhone(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	phone, err := h.authService.BindWechatPhone(c.Request.Context(), c.GetString(consts.UserId), req.Code)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"phone": phone})
+}
+
+// MergePhoneAccount 验证微信手机号和目标账号密码后，将当前账号合并到持有该手机号的账号
+func (h *AuthHandler) MergePhoneAccount(c *gin.Context) {
+	var req struct {
+		Code     string `json:"code" binding:"required"`     // getPhoneNumber 返回的 code
+		Password string `json:"password" binding:"required"` // 持有该手机号的账号的密码
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	user, token, err := h.authService.MergePhoneAccount(c.Request.Context(), c.GetString(consts.UserId), req.Code, req.Password)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"profile":      user,
+		"access_token": token,
+		"expire_in":    24 * 60 * 60 * 1000,
+	})
+}
+
 // WxMiniProgramLogin 微信小程序登录
 func (h *AuthHandler) WxMiniProgramLogin(c *gin.Context) {
 	var req service.WxMiniProgramLoginRequest
@@ -172,6 +294,7 @@ func (h *AuthHandler) WxMiniProgramLogin(c *gin.Context) {
 	req.Platform = c.GetHeader("X-Platform")
 	req.IP = c.ClientIP()
 	req.UserAgent = c.GetHeader("User-Agent")
+	req.DeviceID = c.GetHeader("X-Device-ID")
 
 	user, token, err := h.authService.WxMiniProgramLogin(c.Request.Context(), &req)
 	if err != nil {
@@ -192,11 +315,21 @@ func RegisterUser(r *gin.RouterGroup, h *AuthHandler) {
 	//r.POST("/login", h.Register)
 	r.POST("/login", h.WxMiniProgramLogin)          // 微信登陆
 	r.POST("/third-party-login", h.ThirdPartyLogin) // 第三方登陆
+
+	// 邮箱账号
+	r.POST("/register", h.Register)               // 邮箱注册
+	r.POST("/email-login", h.Login)               // 邮箱/手机号密码登录
+	r.POST("/email/verify", h.VerifyEmail)        // 验证邮箱
+	r.POST("/email/resend", h.ResendVerification) // 重发验证邮件
+	r.POST("/password/forgot", h.ForgotPassword)  // 忘记密码
+	r.POST("/password/reset", h.ResetPassword)    // 重置密码
 }
 
 // RegisterUserRoutes 注册用户相关路由
 func RegisterUserRoutes(r *gin.RouterGroup, h *AuthHandler) {
 	//r.GET("/profile", h.GetProfile)
 	r.POST("/update", h.UpdateProfile)
-	//r.PUT("/password", h.ChangePassword)
+	r.PUT("/password", h.ChangePassword)
+	r.POST("/bind-phone", h.BindWechatPhone)            // 绑定微信手机号
+	r.POST("/merge-phone-account", h.MergePhoneAccount) // 验证手机号和密码后合并到手机号账号
 }
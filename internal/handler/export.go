@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/internal/service"
+	"github.com/reusedev/uportal-api/pkg/constants"
+	"github.com/reusedev/uportal-api/pkg/consts"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/export"
+	"github.com/reusedev/uportal-api/pkg/logs"
+	"github.com/reusedev/uportal-api/pkg/response"
+	"go.uber.org/zap"
+)
+
+// ExportHandler 数据导出处理器
+type ExportHandler struct {
+	exportService *service.ExportService
+}
+
+// NewExportHandler 创建数据导出处理器
+func NewExportHandler(exportService *service.ExportService) *ExportHandler {
+	return &ExportHandler{exportService: exportService}
+}
+
+// ExportOptions 导出选项，各导出请求共用
+type ExportOptions struct {
+	Format string `json:"format" binding:"omitempty,oneof=csv xlsx"` // 文件格式，默认 csv
+	Async  bool   `json:"async"`                                     // 是否创建异步导出任务
+}
+
+// ExportUsersRequest 导出用户请求，筛选条件与用户列表一致
+type ExportUsersRequest struct {
+	ExportOptions
+	NickName string  `json:"nickname"`
+	Phone    string  `json:"phone"`
+	Status   *int    `json:"status"`
+	TagIDs   []int64 `json:"tag_ids"`
+}
+
+// ExportLoginLogsRequest 导出登录日志请求，筛选条件与登录日志列表一致
+type ExportLoginLogsRequest struct {
+	ExportOptions
+	UserId    string `json:"user_id"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+}
+
+// ExportTokenRecordsRequest 导出代币记录请求，筛选条件与代币记录列表一致
+type ExportTokenRecordsRequest struct {
+	ExportOptions
+	UserID     string `json:"user_id"`
+	ChangeType string `json:"change_type"`
+	StartTime  string `json:"start_time"`
+	EndTime    string `json:"end_time"`
+}
+
+// ExportOrdersRequest 导出订单请求
+type ExportOrdersRequest struct {
+	ExportOptions
+	UserID    string `json:"user_id"`
+	Status    string `json:"status"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+}
+
+// ExportUsers 导出用户
+func (h *ExportHandler) ExportUsers(c *gin.Context) {
+	var req ExportUsersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+	h.export(c, &service.UserFilter{
+		NickName: req.NickName,
+		Phone:    req.Phone,
+		Status:   req.Status,
+		TagIDs:   req.TagIDs,
+	}, req.ExportOptions)
+}
+
+// ExportLoginLogs 导出登录日志
+func (h *ExportHandler) ExportLoginLogs(c *gin.Context) {
+	var req ExportLoginLogsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+	startTime, endTime, err := parseTimeRange(req.StartTime, req.EndTime)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	h.export(c, &service.LoginLogFilter{
+		UserID:    req.UserId,
+		StartTime: startTime,
+		EndTime:   endTime,
+	}, req.ExportOptions)
+}
+
+// ExportTokenRecords 导出代币记录
+func (h *ExportHandler) ExportTokenRecords(c *gin.Context) {
+	var req ExportTokenRecordsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+	startTime, endTime, err := parseTimeRange(req.StartTime, req.EndTime)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	h.export(c, &service.TokenRecordFilter{
+		UserID:     req.UserID,
+		ChangeType: req.ChangeType,
+		StartTime:  startTime,
+		EndTime:    endTime,
+	}, req.ExportOptions)
+}
+
+// ExportOrders 导出订单
+func (h *ExportHandler) ExportOrders(c *gin.Context) {
+	var req ExportOrdersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+	startTime, endTime, err := parseTimeRange(req.StartTime, req.EndTime)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	h.export(c, &service.OrderFilter{
+		UserID:    req.UserID,
+		Status:    req.Status,
+		StartTime: startTime,
+		EndTime:   endTime,
+	}, req.ExportOptions)
+}
+
+// export 异步导出时创建任务并返回任务信息，否则直接以附件形式流式写出文件
+func (h *ExportHandler) export(c *gin.Context, filter service.Exportable, opts ExportOptions) {
+	format := opts.Format
+	if format == "" {
+		format = export.FormatCSV
+	}
+
+	if opts.Async {
+		job, err := h.exportService.CreateExportJob(c.Request.Context(), c.GetInt64(consts.UserId), filter, format)
+		if err != nil {
+			response.Error(c, err)
+			return
+		}
+		response.Success(c, job)
+		return
+	}
+
+	if err := h.exportService.ValidateSyncExport(c.Request.Context(), filter); err != nil {
+		response.Error(c, err)
+		return
+	}
+	c.Header("Content-Type", export.ContentType(format))
+	c.Header("Content-Disposition", "attachment; filename="+service.ExportFileName(filter, format))
+	// 响应头已发出，导出中途出错只能记录日志，客户端会收到不完整的文件
+	if err := h.exportService.Export(c.Request.Context(), filter, format, c.Writer); err != nil {
+		logs.Business().Error("导出数据失败", zap.Error(err))
+	}
+}
+
+// ListExportJobs 当前管理员的导出任务列表
+func (h *ExportHandler) ListExportJobs(c *gin.Context) {
+	var req struct {
+		Page  int `json:"page" binding:"required,min=1"`
+		Limit int `json:"limit" binding:"required,min=1,max=100"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	list, total, err := h.exportService.ListExportJobs(c.Request.Context(), c.GetInt64(consts.UserId), req.Page, req.Limit)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ListResponse(c, list, total)
+}
+
+// DownloadExport 下载异步导出文件
+func (h *ExportHandler) DownloadExport(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的任务ID", err))
+		return
+	}
+
+	job, err := h.exportService.GetExportFile(c.Request.Context(), c.GetInt64(consts.UserId), id)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	c.Header("Content-Type", export.ContentType(job.Format))
+	c.FileAttachment(job.FilePath, job.FileName)
+}
+
+// RegisterExportRoutes 注册数据导出路由，导出需同时具备数据查看权限和导出权限
+func RegisterExportRoutes(r *gin.RouterGroup, h *ExportHandler, perm, audit func(string) gin.HandlerFunc) {
+	exportPerm := perm(model.PermDataExport)
+	r.POST("/users/export", perm(model.PermUsersRead), exportPerm, audit("export.users"), h.ExportUsers)                               // 导出用户
+	r.POST("/users/login-logs/export", perm(model.PermUsersRead), exportPerm, audit("export.login_logs"), h.ExportLoginLogs)           // 导出登录日志
+	r.POST("/users/token-records/export", perm(model.PermTokensRead), exportPerm, audit("export.token_records"), h.ExportTokenRecords) // 导出代币记录
+	r.POST("/orders/export", perm(model.PermOrdersRead), exportPerm, audit("export.orders"), h.ExportOrders)                           // 导出订单
+
+	// 异步导出任务只能由发起人本人查看和下载
+	r.POST("/exports/list", exportPerm, h.ListExportJobs)                                  // 导出任务列表
+	r.GET("/exports/:id/download", exportPerm, audit("export.download"), h.DownloadExport) // 下载导出文件
+}
+
+// parseTimeRange 解析列表与导出共用的时间范围参数，格式为 2006-01-02 15:04:05，按服务器本地时区解析
+func parseTimeRange(start, end string) (*time.Time, *time.Time, error) {
+	var startTime, endTime *time.Time
+	if start != "" {
+		t, err := time.ParseInLocation(constants.TimeFormatDateTime, start, time.Local)
+		if err != nil {
+			return nil, nil, errors.New(errors.ErrCodeInvalidParams, "无效的开始时间", err)
+		}
+		startTime = &t
+	}
+	if end != "" {
+		t, err := time.ParseInLocation(constants.TimeFormatDateTime, end, time.Local)
+		if err != nil {
+			return nil, nil, errors.New(errors.ErrCodeInvalidParams, "无效的结束时间", err)
+		}
+		endTime = &t
+	}
+	return startTime, endTime, nil
+}
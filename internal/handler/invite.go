@@ -2,6 +2,8 @@ package handler
 
 import (
 	basicErr "errors"
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 	"github.com/reusedev/uportal-api/internal/model"
 	"github.com/reusedev/uportal-api/internal/service"
@@ -23,14 +25,10 @@ func NewInviteHandler(inviteSvc *service.InviteService) *InviteHandler {
 	}
 }
 
-// ReportInviteRequest 邀请上报请求
+// ReportInviteRequest 邀请上报请求，invite_code 和 invite_by 二选一，优先使用邀请码
 type ReportInviteRequest struct {
-	InviteBy string `json:"invite_by" binding:"required"` // 邀请人ID
-}
-
-// ReportPointsRewardRequest 代币奖励上报请求
-type ReportPointsRewardRequest struct {
-	Type string `json:"type" binding:"required"` // 奖励类型
+	InviteCode string `json:"invite_code"` // 邀请码，不区分大小写
+	InviteBy   string `json:"invite_by"`   // 邀请人ID
 }
 
 func (h *InviteHandler) ReportInvite(c *gin.Context) {
@@ -48,6 +46,14 @@ func (h *InviteHandler) ReportInvite(c *gin.Context) {
 		return
 	}
 	inviteBy := req.InviteBy
+	if req.InviteCode != "" {
+		inviterID, err := h.inviteSvc.ValidateInviteCode(c.Request.Context(), req.InviteCode)
+		if err != nil {
+			response.Error(c, err)
+			return
+		}
+		inviteBy = inviterID
+	}
 
 	// 检查邀请人ID是否有效
 	if inviteBy == "" {
@@ -74,7 +80,7 @@ func (h *InviteHandler) ReportInvite(c *gin.Context) {
 
 	// 检查邀请人状态
 	var inviter model.User
-	if err := h.inviteSvc.GetDB().Where("id = ?", req.InviteBy).First(&inviter).Error; err != nil {
+	if err := h.inviteSvc.GetDB().Where("id = ?", inviteBy).First(&inviter).Error; err != nil {
 		if basicErr.Is(err, gorm.ErrRecordNotFound) {
 			response.Error(c, errors.New(errors.ErrCodeUserNotFound, "邀请人不存在", nil))
 			return
@@ -87,9 +93,16 @@ func (h *InviteHandler) ReportInvite(c *gin.Context) {
 		return
 	}
 
-	// 从系统配置获取邀请奖励代币数
-	// TODO: 从系统配置中获取实际的奖励代币数
-	tokenReward := 1000 // 临时使用固定值，后续从配置获取
+	// 有生效的邀请活动时按活动奖励，奖励在被邀请人达成触发条件后结算
+	campaign, err := h.inviteSvc.ActiveInviteCampaign(c.Request.Context())
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	tokenReward := service.DefaultInviteReward
+	if campaign != nil {
+		tokenReward = service.InviteRewardTotal(campaign)
+	}
 
 	// 开启事务处理邀请记录和奖励
 	tx := h.inviteSvc.GetDB().Begin()
@@ -104,35 +117,208 @@ func (h *InviteHandler) ReportInvite(c *gin.Context) {
 	}()
 
 	// 更新当前用户的邀请人ID
-	if err := tx.Model(&currentUser).Update("inviter_id", req.InviteBy).Error; err != nil {
+	if err := tx.Model(&currentUser).Update("inviter_id", inviteBy).Error; err != nil {
 		tx.Rollback()
 		response.Error(c, errors.New(errors.ErrCodeInternal, "更新邀请关系失败", err))
 		return
 	}
-	// 创建邀请记录
-	if err := h.inviteSvc.CreateInviteRecordWithTx(c.Request.Context(), tx, inviteBy, userID, tokenReward); err != nil {
+	// 风控评估，拒绝时不建立邀请关系
+	risk, err := h.inviteSvc.AssessInviteWithTx(c.Request.Context(), tx, inviteBy, userID, tokenReward, riskClient(c))
+	if err != nil {
 		tx.Rollback()
 		response.Error(c, err)
 		return
 	}
-
-	// 立即处理邀请奖励
-	if err := h.inviteSvc.ProcessInviteRewardWithTx(c.Request.Context(), tx, userID); err != nil {
+	if risk.Blocked() {
 		tx.Rollback()
-		response.Error(c, err)
+		response.Error(c, errors.New(errors.ErrCodeForbidden, "邀请存在异常，无法领取奖励", nil))
 		return
 	}
+	if campaign != nil {
+		// 创建活动邀请记录，暂扣时进入风控审核中
+		if err := h.inviteSvc.CreateCampaignInviteWithTx(c.Request.Context(), tx, &inviter, userID, campaign, risk); err != nil {
+			tx.Rollback()
+			response.Error(c, err)
+			return
+		}
+	} else {
+		// 暂扣时邀请记录保持待发放，审核通过后补发原奖励
+		if !risk.Held() {
+			tokenReward = risk.Amount
+		}
+
+		// 创建邀请记录
+		if err := h.inviteSvc.CreateInviteRecordWithTx(c.Request.Context(), tx, inviteBy, userID, tokenReward); err != nil {
+			tx.Rollback()
+			response.Error(c, err)
+			return
+		}
+
+		// 立即处理邀请奖励
+		if !risk.Held() {
+			if err := h.inviteSvc.ProcessInviteRewardWithTx(c.Request.Context(), tx, userID); err != nil {
+				tx.Rollback()
+				response.Error(c, err)
+				return
+			}
+		}
+	}
 
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		response.Error(c, errors.New(errors.ErrCodeInternal, "提交事务失败", err))
 		return
 	}
+	// 被风控暂扣的邀请不计入成就，也不结算
+	if !risk.Held() {
+		h.inviteSvc.TrackInvite(c.Request.Context(), inviteBy, userID)
+		h.inviteSvc.SettleInvite(c.Request.Context(), userID)
+	}
 
 	response.Success(c, nil)
 }
 
+// GetInviteShare 当前用户的邀请码和分享链接
+func (h *InviteHandler) GetInviteShare(c *gin.Context) {
+	share, err := h.inviteSvc.GetInviteShare(c.Request.Context(), c.GetString(consts.UserId))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, share)
+}
+
+// GetInviteQRCode 当前用户的邀请小程序码图片
+func (h *InviteHandler) GetInviteQRCode(c *gin.Context) {
+	data, contentType, err := h.inviteSvc.GetInviteQRCode(c.Request.Context(), c.GetString(consts.UserId))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// LookupInviteCode 查询邀请码对应的邀请人，用于邀请落地页展示
+func (h *InviteHandler) LookupInviteCode(c *gin.Context) {
+	info, err := h.inviteSvc.LookupInviteCode(c.Request.Context(), c.Param("code"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, info)
+}
+
+// InviteDashboardRequest 邀请看板请求
+type InviteDashboardRequest struct {
+	Page  int `form:"page" binding:"omitempty,min=1"`
+	Limit int `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// GetInviteDashboard 当前用户的邀请汇总、当前活动和邀请的人
+func (h *InviteHandler) GetInviteDashboard(c *gin.Context) {
+	var req InviteDashboardRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.Limit == 0 {
+		req.Limit = 20
+	}
+
+	dashboard, err := h.inviteSvc.GetInviteDashboard(c.Request.Context(), c.GetString(consts.UserId), req.Page, req.Limit)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, dashboard)
+}
+
+// UpdateInviteCampaignRequest 更新邀请活动请求
+type UpdateInviteCampaignRequest struct {
+	ID int64 `json:"id" binding:"required"`
+	service.InviteCampaignRequest
+}
+
+// ListInviteCampaigns 邀请活动列表
+func (h *InviteHandler) ListInviteCampaigns(c *gin.Context) {
+	list, err := h.inviteSvc.ListInviteCampaigns(c.Request.Context())
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, list)
+}
+
+// CreateInviteCampaign 创建邀请活动
+func (h *InviteHandler) CreateInviteCampaign(c *gin.Context) {
+	var req service.InviteCampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	campaign, err := h.inviteSvc.CreateInviteCampaign(c.Request.Context(), &req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, campaign)
+}
+
+// UpdateInviteCampaign 更新邀请活动
+func (h *InviteHandler) UpdateInviteCampaign(c *gin.Context) {
+	var req UpdateInviteCampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	campaign, err := h.inviteSvc.UpdateInviteCampaign(c.Request.Context(), req.ID, &req.InviteCampaignRequest)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, campaign)
+}
+
+// DeleteInviteCampaign 删除邀请活动
+func (h *InviteHandler) DeleteInviteCampaign(c *gin.Context) {
+	var req struct {
+		ID int64 `json:"id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	if err := h.inviteSvc.DeleteInviteCampaign(c.Request.Context(), req.ID); err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, nil)
+}
+
 // RegisterInviteRoutes 注册邀请相关路由
 func RegisterInviteRoutes(r *gin.RouterGroup, h *InviteHandler) {
 	r.POST("/report", h.ReportInvite)
+	r.GET("/share", h.GetInviteShare)         // 邀请码和分享链接
+	r.GET("/wxacode", h.GetInviteQRCode)      // 邀请小程序码图片
+	r.GET("/dashboard", h.GetInviteDashboard) // 邀请汇总、当前活动和邀请的人
+}
+
+// RegisterAdminInviteCampaignRoutes 注册邀请活动管理路由
+func RegisterAdminInviteCampaignRoutes(r *gin.RouterGroup, h *InviteHandler, perm, audit func(string) gin.HandlerFunc) {
+	r.POST("/list", perm(model.PermTasksRead), h.ListInviteCampaigns)                                      // 邀请活动列表
+	r.POST("/create", perm(model.PermTasksWrite), audit("invite_campaign.create"), h.CreateInviteCampaign) // 创建邀请活动
+	r.POST("/edit", perm(model.PermTasksWrite), audit("invite_campaign.update"), h.UpdateInviteCampaign)   // 更新邀请活动
+	r.POST("/delete", perm(model.PermTasksWrite), audit("invite_campaign.delete"), h.DeleteInviteCampaign) // 删除邀请活动
+}
+
+// RegisterInviteCodeRoutes 注册无需登录的邀请码查询路由
+func RegisterInviteCodeRoutes(r *gin.RouterGroup, h *InviteHandler) {
+	r.GET("/:code", h.LookupInviteCode) // 邀请码对应的邀请人昵称和头像
 }
@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/internal/service"
+	"github.com/reusedev/uportal-api/pkg/consts"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/response"
+)
+
+// NotificationHandler 通知处理器
+type NotificationHandler struct {
+	notificationSvc *service.NotificationService
+}
+
+// NewNotificationHandler 创建通知处理器
+func NewNotificationHandler(notificationSvc *service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		notificationSvc: notificationSvc,
+	}
+}
+
+// ListSubscribeTemplates 获取可订阅的消息模板
+func (h *NotificationHandler) ListSubscribeTemplates(c *gin.Context) {
+	response.Success(c, h.notificationSvc.ListSubscribeTemplates())
+}
+
+// RecordSubscribeResults 上报订阅消息授权结果
+func (h *NotificationHandler) RecordSubscribeResults(c *gin.Context) {
+	var req struct {
+		Results map[string]string `json:"results" binding:"required"` // wx.requestSubscribeMessage 返回的模板ID到 accept/reject 的映射
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	if err := h.notificationSvc.RecordSubscribeResults(c.Request.Context(), c.GetString(consts.UserId), req.Results); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// ListSubscribeAuths 获取用户订阅授权
+func (h *NotificationHandler) ListSubscribeAuths(c *gin.Context) {
+	auths, err := h.notificationSvc.ListSubscribeAuths(c.Request.Context(), c.GetString(consts.UserId))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, auths)
+}
+
+// ListNotifications 获取通知列表
+func (h *NotificationHandler) ListNotifications(c *gin.Context) {
+	var req service.ListNotificationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	resp, err := h.notificationSvc.ListNotifications(c.Request.Context(), c.GetString(consts.UserId), &req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, resp)
+}
+
+// GetUnreadCount 获取未读通知数
+func (h *NotificationHandler) GetUnreadCount(c *gin.Context) {
+	count, err := h.notificationSvc.GetUnreadCount(c.Request.Context(), c.GetString(consts.UserId))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"unread": count})
+}
+
+// MarkRead 标记通知已读
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的通知ID", err))
+		return
+	}
+
+	if err := h.notificationSvc.MarkRead(c.Request.Context(), c.GetString(consts.UserId), id); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// MarkAllRead 标记全部通知已读
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	affected, err := h.notificationSvc.MarkAllRead(c.Request.Context(), c.GetString(consts.UserId))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"updated": affected})
+}
+
+// DeleteNotification 删除通知
+func (h *NotificationHandler) DeleteNotification(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的通知ID", err))
+		return
+	}
+
+	if err := h.notificationSvc.DeleteNotification(c.Request.Context(), c.GetString(consts.UserId), id); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// Broadcast 管理员群发通知
+func (h *NotificationHandler) Broadcast(c *gin.Context) {
+	var req service.BroadcastRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	broadcast, err := h.notificationSvc.Broadcast(c.Request.Context(), c.GetInt64(consts.UserId), &req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, broadcast)
+}
+
+// ListBroadcasts 获取群发任务列表
+func (h *NotificationHandler) ListBroadcasts(c *gin.Context) {
+	var req struct {
+		Page  int `json:"page" binding:"required,min=1"`
+		Limit int `json:"limit" binding:"required,min=1,max=100"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	list, total, err := h.notificationSvc.ListBroadcasts(c.Request.Context(), req.Page, req.Limit)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.ListResponse(c, list, total)
+}
+
+// GetBroadcast 获取群发任务详情
+func (h *NotificationHandler) GetBroadcast(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的群发任务ID", err))
+		return
+	}
+
+	broadcast, err := h.notificationSvc.GetBroadcast(c.Request.Context(), id)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, broadcast)
+}
+
+// RegisterNotificationRoutes 注册通知相关路由
+func RegisterNotificationRoutes(r *gin.RouterGroup, h *NotificationHandler) {
+	r.POST("/list", h.ListNotifications)               // 通知列表（游标分页）
+	r.GET("/unread-count", h.GetUnreadCount)           // 未读数
+	r.POST("/read-all", h.MarkAllRead)                 // 全部已读
+	r.POST("/:id/read", h.MarkRead)                    // 标记已读
+	r.DELETE("/:id", h.DeleteNotification)             // 删除通知
+	r.GET("/templates", h.ListSubscribeTemplates)      // 可订阅的消息模板
+	r.GET("/subscriptions", h.ListSubscribeAuths)      // 订阅授权情况
+	r.POST("/subscriptions", h.RecordSubscribeResults) // 上报订阅授权结果
+}
+
+// RegisterAdminNotificationRoutes 注册管理端通知路由
+func RegisterAdminNotificationRoutes(r *gin.RouterGroup, h *NotificationHandler, perm, audit func(string) gin.HandlerFunc) {
+	r.POST("/broadcast", perm(model.PermNotificationsBroadcast), audit("notification.broadcast"), h.Broadcast) // 群发通知
+	r.POST("/broadcasts/list", perm(model.PermNotificationsRead), h.ListBroadcasts)                            // 群发任务列表
+	r.GET("/broadcasts/:id", perm(model.PermNotificationsRead), h.GetBroadcast)                                // 群发任务详情
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/reusedev/uportal-api/internal/service"
+	"github.com/reusedev/uportal-api/pkg/consts"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/logs"
+	"github.com/reusedev/uportal-api/pkg/response"
+	"go.uber.org/zap"
+)
+
+// PrivacyHandler 个人信息保护处理器
+type PrivacyHandler struct {
+	privacyService *service.PrivacyService
+}
+
+// NewPrivacyHandler 创建个人信息保护处理器
+func NewPrivacyHandler(privacyService *service.PrivacyService) *PrivacyHandler {
+	return &PrivacyHandler{privacyService: privacyService}
+}
+
+// ExportData 导出个人数据，format=zip 时每类数据一个 JSON 文件，默认 json
+func (h *PrivacyHandler) ExportData(c *gin.Context) {
+	var req struct {
+		Format string `form:"format" binding:"omitempty,oneof=json zip"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+	format := req.Format
+	if format == "" {
+		format = service.PrivacyFormatJSON
+	}
+
+	userID := c.GetString(consts.UserId)
+	if err := h.privacyService.ValidateExport(c.Request.Context(), userID); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	contentType := "application/json; charset=utf-8"
+	if format == service.PrivacyFormatZIP {
+		contentType = "application/zip"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", "attachment; filename="+service.PrivacyExportFileName(userID, format))
+	// 响应头已发出，导出中途出错只能记录日志，客户端会收到不完整的文件
+	if err := h.privacyService.Export(c.Request.Context(), userID, format, c.Writer); err != nil {
+		logs.Business().Error("导出个人数据失败", zap.String("user_id", userID), zap.Error(err))
+	}
+}
+
+// RequestDeletion 申请注销账号
+func (h *PrivacyHandler) RequestDeletion(c *gin.Context) {
+	var req struct {
+		Reason string `json:"reason" binding:"max=255"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	deletion, err := h.privacyService.RequestDeletion(c.Request.Context(), c.GetString(consts.UserId), req.Reason)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, deletion)
+}
+
+// GetDeletion 查询注销申请状态，没有申请时返回空
+func (h *PrivacyHandler) GetDeletion(c *gin.Context) {
+	deletion, err := h.privacyService.GetDeletion(c.Request.Context(), c.GetString(consts.UserId))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, deletion)
+}
+
+// CancelDeletion 撤销冷静期内的注销申请
+func (h *PrivacyHandler) CancelDeletion(c *gin.Context) {
+	if err := h.privacyService.CancelDeletion(c.Request.Context(), c.GetString(consts.UserId)); err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, nil)
+}
+
+// RegisterPrivacyRoutes 注册个人信息保护路由
+func RegisterPrivacyRoutes(r *gin.RouterGroup, h *PrivacyHandler) {
+	r.GET("/export", h.ExportData)               // 导出个人数据
+	r.GET("/deletion", h.GetDeletion)            // 注销申请状态
+	r.POST("/deletion", h.RequestDeletion)       // 申请注销账号
+	r.POST("/deletion/cancel", h.CancelDeletion) // 撤销注销申请
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/internal/service"
+	"github.com/reusedev/uportal-api/pkg/consts"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/response"
+)
+
+// riskClient 从请求中获取风控所需的客户端信息
+func riskClient(c *gin.Context) service.RiskClient {
+	return service.RiskClient{IP: c.ClientIP(), DeviceID: c.GetHeader("X-Device-ID")}
+}
+
+// RiskHandler 风控处理器
+type RiskHandler struct {
+	riskService *service.RiskService
+}
+
+// NewRiskHandler 创建风控处理器
+func NewRiskHandler(riskService *service.RiskService) *RiskHandler {
+	return &RiskHandler{riskService: riskService}
+}
+
+// ListRiskEventsRequest 风控事件列表请求
+type ListRiskEventsRequest struct {
+	EventType string `json:"event_type" binding:"omitempty,oneof=signup reward invite"`
+	UserID    string `json:"user_id"` // 发起人或关联用户
+	IP        string `json:"ip"`
+	DeviceID  string `json:"device_id"`
+	Decision  string `json:"decision" binding:"omitempty,oneof=allow reduce review block"`
+	Page      int    `json:"page" binding:"required,min=1"`
+	Limit     int    `json:"limit" binding:"required,min=1,max=100"`
+}
+
+// ListRiskReviewsRequest 风控审核列表请求
+type ListRiskReviewsRequest struct {
+	Status    string `json:"status" binding:"omitempty,oneof=pending approved rejected"`
+	EventType string `json:"event_type" binding:"omitempty,oneof=signup reward invite"`
+	UserID    string `json:"user_id"` // 发起人或受益人
+	Page      int    `json:"page" binding:"required,min=1"`
+	Limit     int    `json:"limit" binding:"required,min=1,max=100"`
+}
+
+// RiskReviewDecisionRequest 审核风控暂扣的奖励请求
+type RiskReviewDecisionRequest struct {
+	ID           int64  `json:"id" binding:"required"`
+	Note         string `json:"note" binding:"max=255"`
+	DisableUsers bool   `json:"disable_users"` // 拒绝时同时禁用发起人，邀请事件还包括邀请人
+}
+
+// ListEvents 风控事件列表
+func (h *RiskHandler) ListEvents(c *gin.Context) {
+	var req ListRiskEventsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	filter := &model.RiskEventFilter{
+		EventType: req.EventType,
+		UserID:    req.UserID,
+		IP:        req.IP,
+		DeviceID:  req.DeviceID,
+		Decision:  req.Decision,
+	}
+	list, total, err := h.riskService.ListEvents(c.Request.Context(), filter, req.Page, req.Limit)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ListResponse(c, list, total)
+}
+
+// ListReviews 风控审核队列
+func (h *RiskHandler) ListReviews(c *gin.Context) {
+	var req ListRiskReviewsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	list, total, err := h.riskService.ListReviews(c.Request.Context(), req.Status, req.EventType, req.UserID, req.Page, req.Limit)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ListResponse(c, list, total)
+}
+
+// GetReview 风控审核详情
+func (h *RiskHandler) GetReview(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的审核ID", err))
+		return
+	}
+
+	review, err := h.riskService.GetReview(c.Request.Context(), id)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, review)
+}
+
+// ApproveReview 审核通过，补发暂扣的奖励
+func (h *RiskHandler) ApproveReview(c *gin.Context) {
+	var req RiskReviewDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	review, err := h.riskService.ApproveReview(c.Request.Context(), c.GetInt64(consts.UserId), req.ID, req.Note)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, review)
+}
+
+// RejectReview 审核拒绝，暂扣的奖励不再发放
+func (h *RiskHandler) RejectReview(c *gin.Context) {
+	var req RiskReviewDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	review, err := h.riskService.RejectReview(c.Request.Context(), c.GetInt64(consts.UserId), req.ID, req.Note, req.DisableUsers)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, review)
+}
+
+// RegisterRiskRoutes 注册风控管理路由，拒绝时禁用用户另行校验修改用户状态权限
+func RegisterRiskRoutes(r *gin.RouterGroup, h *RiskHandler, perm, audit func(string) gin.HandlerFunc) {
+	r.POST("/events/list", perm(model.PermRiskRead), h.ListEvents)                                        // 风控事件列表
+	r.POST("/reviews/list", perm(model.PermRiskRead), h.ListReviews)                                      // 风控审核队列
+	r.GET("/reviews/:id", perm(model.PermRiskRead), h.GetReview)                                          // 风控审核详情
+	r.POST("/reviews/approve", perm(model.PermRiskReview), audit("risk.review.approve"), h.ApproveReview) // 审核通过，补发奖励
+	r.POST("/reviews/reject", perm(model.PermRiskReview), audit("risk.review.reject"), h.RejectReview)    // 审核拒绝
+}
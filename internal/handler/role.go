@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/internal/service"
+	"github.com/reusedev/uportal-api/pkg/consts"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/response"
+)
+
+// RoleHandler 角色管理处理器
+type RoleHandler struct {
+	roleService *service.RoleService
+}
+
+// NewRoleHandler 创建角色管理处理器
+func NewRoleHandler(roleService *service.RoleService) *RoleHandler {
+	return &RoleHandler{roleService: roleService}
+}
+
+// CreateRoleRequest 创建角色请求
+type CreateRoleRequest struct {
+	Code        string   `json:"code" binding:"required,min=2,max=20"`
+	Name        string   `json:"name" binding:"required,max=50"`
+	Description *string  `json:"description" binding:"omitempty,max=255"`
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+// UpdateRoleRequest 更新角色请求
+type UpdateRoleRequest struct {
+	RoleID      int64    `json:"role_id" binding:"required"`
+	Name        string   `json:"name" binding:"omitempty,max=50"`
+	Description *string  `json:"description" binding:"omitempty,max=255"`
+	Permissions []string `json:"permissions"` // 不传表示不修改权限
+}
+
+// DeleteRoleRequest 删除角色请求
+type DeleteRoleRequest struct {
+	RoleID int64 `json:"role_id" binding:"required"`
+}
+
+// ListPermissions 获取可分配的权限列表
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	response.Success(c, h.roleService.ListPermissions())
+}
+
+// ListRoles 获取角色列表
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	roles, err := h.roleService.ListRoles(c.Request.Context())
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ListResponse(c, roles, int64(len(roles)))
+}
+
+// GetRole 获取角色详情
+func (h *RoleHandler) GetRole(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的角色ID", err))
+		return
+	}
+
+	role, err := h.roleService.GetRole(c.Request.Context(), id)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, role)
+}
+
+// CreateRole 创建角色
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	role, err := h.roleService.CreateRole(c.Request.Context(), c.GetInt64(consts.UserId), &service.CreateRoleRequest{
+		Code:        req.Code,
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	})
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, role)
+}
+
+// UpdateRole 更新角色
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	err := h.roleService.UpdateRole(c.Request.Context(), c.GetInt64(consts.UserId), req.RoleID, &service.UpdateRoleRequest{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	})
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, nil)
+}
+
+// DeleteRole 删除角色
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	var req DeleteRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	if err := h.roleService.DeleteRole(c.Request.Context(), c.GetInt64(consts.UserId), req.RoleID); err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, nil)
+}
+
+// RegisterRoleRoutes 注册角色管理路由
+func RegisterRoleRoutes(r *gin.RouterGroup, h *RoleHandler, perm, audit func(string) gin.HandlerFunc) {
+	r.GET("/permissions", perm(model.PermRolesRead), h.ListPermissions)               // 可分配的权限列表
+	r.POST("/list", perm(model.PermRolesRead), h.ListRoles)                           // 角色列表
+	r.GET("/:id", perm(model.PermRolesRead), h.GetRole)                               // 角色详情
+	r.POST("/create", perm(model.PermRolesWrite), audit("role.create"), h.CreateRole) // 创建角色
+	r.POST("/edit", perm(model.PermRolesWrite), audit("role.update"), h.UpdateRole)   // 更新角色
+	r.POST("/delete", perm(model.PermRolesWrite), audit("role.delete"), h.DeleteRole) // 删除角色
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/reusedev/uportal-api/internal/model"
 	"github.com/reusedev/uportal-api/internal/service"
 	"github.com/reusedev/uportal-api/pkg/response"
 )
@@ -75,11 +76,11 @@ func (h *SystemConfigHandler) DeleteConfig(c *gin.Context) {
 }
 
 // RegisterSystemConfigRoutes 注册系统配置路由
-func RegisterSystemConfigRoutes(r *gin.RouterGroup, h *SystemConfigHandler) {
+func RegisterSystemConfigRoutes(r *gin.RouterGroup, h *SystemConfigHandler, perm, audit func(string) gin.HandlerFunc) {
 	{
-		r.GET("", h.GetConfigs)           // 获取系统配置列表
-		r.POST("/create", h.CreateConfig) // 创建系统配置
-		r.POST("/edit", h.UpdateConfig)   // 更新系统配置
-		r.POST("/delete", h.DeleteConfig) // 删除系统配置
+		r.GET("", perm(model.PermConfigsRead), h.GetConfigs)                                    // 获取系统配置列表
+		r.POST("/create", perm(model.PermConfigsWrite), audit("config.create"), h.CreateConfig) // 创建系统配置
+		r.POST("/edit", perm(model.PermConfigsWrite), audit("config.update"), h.UpdateConfig)   // 更新系统配置
+		r.POST("/delete", perm(model.PermConfigsWrite), audit("config.delete"), h.DeleteConfig) // 删除系统配置
 	}
 }
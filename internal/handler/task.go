@@ -4,6 +4,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/reusedev/uportal-api/pkg/consts"
 
+	"github.com/reusedev/uportal-api/internal/model"
 	"github.com/reusedev/uportal-api/internal/service"
 	"github.com/reusedev/uportal-api/pkg/errors"
 	"github.com/reusedev/uportal-api/pkg/response"
@@ -45,6 +46,57 @@ func RegisterTaskRoutes(r *gin.RouterGroup, h *TaskHandler) {
 	r.POST("/report", h.CompleteTask)
 	r.GET("/records", h.GetUserTaskRecords)
 	r.GET("/statistics", h.GetUserTaskStatistics)
+	r.POST("/checkin", h.Checkin)                 // 连续签到任务今日签到
+	r.POST("/checkin/makeup", h.MakeupCheckin)    // 补签
+	r.GET("/checkin/calendar", h.CheckinCalendar) // 月度签到日历
+}
+
+// Checkin 今日签到
+func (h *TaskHandler) Checkin(c *gin.Context) {
+	var req service.CheckinRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	result, err := h.taskService.Checkin(c.Request.Context(), c.GetString(consts.UserId), req.TaskID)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, result)
+}
+
+// MakeupCheckin 补签
+func (h *TaskHandler) MakeupCheckin(c *gin.Context) {
+	var req service.MakeupCheckinRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	result, err := h.taskService.MakeupCheckin(c.Request.Context(), c.GetString(consts.UserId), req.TaskID, req.Date)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, result)
+}
+
+// CheckinCalendar 月度签到日历，参数 task_id 和 month（格式 2006-01，默认本月）
+func (h *TaskHandler) CheckinCalendar(c *gin.Context) {
+	taskID, err := utils.GetIntQuery(c, "task_id", 0)
+	if err != nil || taskID <= 0 {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的任务ID", err))
+		return
+	}
+
+	cal, err := h.taskService.GetCheckinCalendar(c.Request.Context(), c.GetString(consts.UserId), taskID, c.Query("month"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, cal)
 }
 
 // UpdateTask 更新任务
@@ -135,6 +187,8 @@ func (h *TaskHandler) CompleteTask(c *gin.Context) {
 		return
 	}
 
+	req.Client = riskClient(c)
+
 	result, err := h.taskService.CompleteTask(c.Request.Context(), userID, &req)
 	if err != nil {
 		response.Error(c, err)
@@ -162,15 +216,15 @@ func (h *TaskHandler) GetUserTaskRecords(c *gin.Context) {
 	})
 }
 
-// GetTaskStatistics 获取任务统计信息
+// GetTaskStatistics 管理端任务统计：完成趋势、完成人数、发放代币和完成次数排行
 func (h *TaskHandler) GetTaskStatistics(c *gin.Context) {
-	taskID, err := utils.GetIntParam(c, "task_id")
-	if err != nil {
-		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的任务ID", err))
+	var req service.TaskAnalyticsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
 		return
 	}
 
-	stats, err := h.taskService.GetTaskStatistics(c.Request.Context(), taskID)
+	stats, err := h.taskService.GetTaskAnalytics(c.Request.Context(), &req)
 	if err != nil {
 		response.Error(c, err)
 		return
@@ -179,6 +233,58 @@ func (h *TaskHandler) GetTaskStatistics(c *gin.Context) {
 	response.Success(c, stats)
 }
 
+// ListTaskCompletionsRequest 任务完成记录列表请求
+type ListTaskCompletionsRequest struct {
+	UserID         string `json:"user_id"`
+	TaskID         int    `json:"task_id"`
+	IncludeRevoked bool   `json:"include_revoked"` // 是否包含已撤销的记录
+	Page           int    `json:"page" binding:"required,min=1"`
+	Limit          int    `json:"limit" binding:"required,min=1,max=100"`
+}
+
+// ListTaskCompletions 任务完成记录，包含上报时提交的数据
+func (h *TaskHandler) ListTaskCompletions(c *gin.Context) {
+	var req ListTaskCompletionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+	if req.UserID == "" && req.TaskID == 0 {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "user_id 和 task_id 至少指定一个", nil))
+		return
+	}
+
+	filter := &model.TaskCompletionFilter{UserID: req.UserID, TaskID: req.TaskID, IncludeRevoked: req.IncludeRevoked}
+	list, total, err := h.taskService.ListTaskCompletions(c.Request.Context(), filter, req.Page, req.Limit)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ListResponse(c, list, total)
+}
+
+// RevokeTaskCompletionRequest 撤销任务完成请求
+type RevokeTaskCompletionRequest struct {
+	ID     int64  `json:"id" binding:"required"`
+	Reason string `json:"reason" binding:"required,max=200"`
+}
+
+// RevokeTaskCompletion 撤销作弊的任务完成并扣回奖励
+func (h *TaskHandler) RevokeTaskCompletion(c *gin.Context) {
+	var req RevokeTaskCompletionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	result, err := h.taskService.RevokeTaskCompletion(c.Request.Context(), c.GetInt64(consts.UserId), req.ID, req.Reason)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, result)
+}
+
 // GetUserTaskStatistics 获取用户任务统计信息
 func (h *TaskHandler) GetUserTaskStatistics(c *gin.Context) {
 	userID := c.GetInt64(consts.UserId)
@@ -320,19 +426,23 @@ func (h *TaskHandler) UpdateConsumptionRule(c *gin.Context) {
 }
 
 // RegisterRewardTaskRoutes 注册代币任务配置路由
-func RegisterRewardTaskRoutes(r *gin.RouterGroup, h *TaskHandler) {
-	r.POST("/list", h.ListTasks)
-	r.POST("/create", h.CreateTask)
-	r.POST("/edit", h.UpdateTask)
-
-	r.POST("/consumption-rules/list", h.ListConsumptionRules)    // 获取代币消耗规则列表
-	r.POST("/consumption-rules/create", h.CreateConsumptionRule) // 创建代币消耗规则
-	r.POST("/consumption-rules/update", h.UpdateConsumptionRule) // 更新代币消耗规则
+func RegisterRewardTaskRoutes(r *gin.RouterGroup, h *TaskHandler, perm, audit func(string) gin.HandlerFunc) {
+	r.POST("/list", perm(model.PermTasksRead), h.ListTasks)
+	r.POST("/create", perm(model.PermTasksWrite), audit("task.create"), h.CreateTask)
+	r.POST("/edit", perm(model.PermTasksWrite), audit("task.update"), h.UpdateTask)
+
+	r.POST("/statistics", perm(model.PermTasksRead), h.GetTaskStatistics)                                                                            // 任务完成趋势、人数、发放代币和排行
+	r.POST("/completions/list", perm(model.PermTasksRead), h.ListTaskCompletions)                                                                    // 任务完成记录，含上报数据
+	r.POST("/completions/revoke", perm(model.PermTasksWrite), perm(model.PermTokensAdjust), audit("task.completion.revoke"), h.RevokeTaskCompletion) // 撤销任务完成并扣回奖励
+
+	r.POST("/consumption-rules/list", perm(model.PermRulesRead), h.ListConsumptionRules)                                   // 获取代币消耗规则列表
+	r.POST("/consumption-rules/create", perm(model.PermRulesWrite), audit("consume_rule.create"), h.CreateConsumptionRule) // 创建代币消耗规则
+	r.POST("/consumption-rules/update", perm(model.PermRulesWrite), audit("consume_rule.update"), h.UpdateConsumptionRule) // 更新代币消耗规则
 }
 
 // RegisterTokenConsumeRulesRoutes 代币消耗规则
-func RegisterTokenConsumeRulesRoutes(r *gin.RouterGroup, h *TaskHandler) {
-	r.POST("/list", h.ListConsumptionRules)    // 获取代币消耗规则列表
-	r.POST("/create", h.CreateConsumptionRule) // 创建代币消耗规则
-	r.POST("/update", h.UpdateConsumptionRule) // 更新代币消耗规则
+func RegisterTokenConsumeRulesRoutes(r *gin.RouterGroup, h *TaskHandler, perm, audit func(string) gin.HandlerFunc) {
+	r.POST("/list", perm(model.PermRulesRead), h.ListConsumptionRules)                                   // 获取代币消耗规则列表
+	r.POST("/create", perm(model.PermRulesWrite), audit("consume_rule.create"), h.CreateConsumptionRule) // 创建代币消耗规则
+	r.POST("/update", perm(model.PermRulesWrite), audit("consume_rule.update"), h.UpdateConsumptionRule) // 更新代币消耗规则
 }
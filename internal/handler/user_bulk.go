@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"encoding/csv"
+	stderrors "errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/internal/service"
+	"github.com/reusedev/uportal-api/pkg/consts"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/response"
+)
+
+// bulkUploadMaxSize 上传的用户ID文件大小上限
+const bulkUploadMaxSize = 10 << 20
+
+// BulkHandler 用户批量操作处理器
+type BulkHandler struct {
+	bulkService *service.BulkService
+}
+
+// NewBulkHandler 创建用户批量操作处理器
+func NewBulkHandler(bulkService *service.BulkService) *BulkHandler {
+	return &BulkHandler{bulkService: bulkService}
+}
+
+// CreateBulkJobRequest 创建批量任务请求，user_ids 与 filter 二选一
+type CreateBulkJobRequest struct {
+	Operation string              `json:"operation" binding:"required,oneof=tokens_grant disable enable"`
+	Amount    int                 `json:"amount"` // 代币增减数量，负数为扣减，仅 tokens_grant
+	Remark    string              `json:"remark" binding:"max=255"`
+	DryRun    bool                `json:"dry_run"` // 试运行，只计算结果不修改数据
+	UserIDs   []string            `json:"user_ids"`
+	Filter    *service.UserFilter `json:"filter"` // 筛选条件与用户列表一致
+}
+
+// UploadBulkJobRequest 上传用户ID文件创建批量任务请求，文件每行一个用户ID，CSV 文件取第一列
+type UploadBulkJobRequest struct {
+	Operation string `form:"operation" binding:"required,oneof=tokens_grant disable enable"`
+	Amount    int    `form:"amount"`
+	Remark    string `form:"remark" binding:"max=255"`
+	DryRun    bool   `form:"dry_run"`
+}
+
+// ListBulkItemsRequest 批量任务结果请求
+type ListBulkItemsRequest struct {
+	JobID  int64  `json:"job_id" binding:"required"`
+	Result string `json:"result" binding:"omitempty,oneof=pending success failed skipped"` // 按结果筛选
+	Page   int    `json:"page" binding:"required,min=1"`
+	Limit  int    `json:"limit" binding:"required,min=1,max=100"`
+}
+
+// CreateJob 按用户ID列表或筛选条件创建批量任务
+func (h *BulkHandler) CreateJob(c *gin.Context) {
+	var req CreateBulkJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	job, err := h.bulkService.CreateJob(c.Request.Context(), c.GetInt64(consts.UserId), &service.CreateBulkJobRequest{
+		Operation: req.Operation,
+		Amount:    req.Amount,
+		Remark:    req.Remark,
+		DryRun:    req.DryRun,
+		UserIDs:   req.UserIDs,
+		Filter:    req.Filter,
+	})
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, job)
+}
+
+// UploadJob 上传用户ID文件创建批量任务
+func (h *BulkHandler) UploadJob(c *gin.Context) {
+	var req UploadBulkJobRequest
+	if err := c.ShouldBind(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "请上传用户ID文件", err))
+		return
+	}
+	if header.Size > bulkUploadMaxSize {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "文件不能超过10MB", nil))
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "读取文件失败", err))
+		return
+	}
+	defer file.Close()
+
+	userIDs, err := readBulkUserIDs(io.LimitReader(file, bulkUploadMaxSize))
+	if err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "文件格式错误", err))
+		return
+	}
+
+	job, err := h.bulkService.CreateJob(c.Request.Context(), c.GetInt64(consts.UserId), &service.CreateBulkJobRequest{
+		Operation: req.Operation,
+		Amount:    req.Amount,
+		Remark:    req.Remark,
+		DryRun:    req.DryRun,
+		UserIDs:   userIDs,
+	})
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, job)
+}
+
+// ListJobs 批量任务列表
+func (h *BulkHandler) ListJobs(c *gin.Context) {
+	var req struct {
+		Page  int `json:"page" binding:"required,min=1"`
+		Limit int `json:"limit" binding:"required,min=1,max=100"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	list, total, err := h.bulkService.ListJobs(c.Request.Context(), req.Page, req.Limit)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ListResponse(c, list, total)
+}
+
+// GetJob 批量任务详情及进度
+func (h *BulkHandler) GetJob(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的任务ID", err))
+		return
+	}
+
+	job, err := h.bulkService.GetJob(c.Request.Context(), id)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, job)
+}
+
+// ListItems 批量任务的逐个用户结果
+func (h *BulkHandler) ListItems(c *gin.Context) {
+	var req ListBulkItemsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	list, total, err := h.bulkService.ListItems(c.Request.Context(), req.JobID, req.Result, req.Page, req.Limit)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ListResponse(c, list, total)
+}
+
+// CancelJob 取消批量任务
+func (h *BulkHandler) CancelJob(c *gin.Context) {
+	var req struct {
+		JobID int64 `json:"job_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	job, err := h.bulkService.CancelJob(c.Request.Context(), c.GetInt64(consts.UserId), req.JobID)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, job)
+}
+
+// RegisterBulkRoutes 注册用户批量操作路由。创建和取消任务需查看用户权限及代币调整或修改用户状态权限之一，
+// 业务层再按操作类型校验具体权限
+func RegisterBulkRoutes(r *gin.RouterGroup, h *BulkHandler, perm func(string) gin.HandlerFunc, anyPerm func(...string) gin.HandlerFunc, audit func(string) gin.HandlerFunc) {
+	write := anyPerm(model.PermTokensAdjust, model.PermUsersWrite)
+	r.POST("/create", perm(model.PermUsersRead), write, audit("user.bulk.create"), h.CreateJob) // 创建批量任务
+	r.POST("/upload", perm(model.PermUsersRead), write, audit("user.bulk.create"), h.UploadJob) // 上传用户ID文件创建批量任务
+	r.POST("/list", perm(model.PermUsersRead), h.ListJobs)                                      // 批量任务列表
+	r.GET("/:id", perm(model.PermUsersRead), h.GetJob)                                          // 批量任务详情
+	r.POST("/items", perm(model.PermUsersRead), h.ListItems)                                    // 逐个用户的处理结果
+	r.POST("/cancel", perm(model.PermUsersRead), write, audit("user.bulk.cancel"), h.CancelJob) // 取消批量任务
+}
+
+// readBulkUserIDs 读取上传的用户ID，每行取第一列，忽略空行和表头（可直接上传用户导出文件）
+func readBulkUserIDs(r io.Reader) ([]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	var ids []string
+	for line := 0; ; line++ {
+		record, err := reader.Read()
+		if stderrors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) == 0 {
+			continue
+		}
+		id := strings.TrimSpace(record[0])
+		if line == 0 {
+			id = strings.TrimPrefix(id, "\ufeff")
+			if id == "用户ID" || strings.EqualFold(id, "user_id") || strings.EqualFold(id, "id") {
+				continue
+			}
+		}
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
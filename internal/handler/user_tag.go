@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/internal/service"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/response"
+)
+
+// UserTagHandler 用户标签处理器
+type UserTagHandler struct {
+	tagService *service.UserTagService
+}
+
+// NewUserTagHandler 创建用户标签处理器
+func NewUserTagHandler(tagService *service.UserTagService) *UserTagHandler {
+	return &UserTagHandler{tagService: tagService}
+}
+
+// UpdateUserTagRequest 更新用户标签请求
+type UpdateUserTagRequest struct {
+	ID int64 `json:"id" binding:"required"`
+	service.UserTagRequest
+}
+
+// UserTagMembersRequest 手动标签增删用户请求
+type UserTagMembersRequest struct {
+	TagID   int64    `json:"tag_id" binding:"required"`
+	UserIDs []string `json:"user_ids" binding:"required,min=1,max=1000"`
+}
+
+// ListTags 标签列表
+func (h *UserTagHandler) ListTags(c *gin.Context) {
+	var req struct {
+		Type string `json:"type" binding:"omitempty,oneof=manual rule"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	tags, err := h.tagService.ListTags(c.Request.Context(), req.Type)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ListResponse(c, tags, int64(len(tags)))
+}
+
+// GetTag 标签详情
+func (h *UserTagHandler) GetTag(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的标签ID", err))
+		return
+	}
+
+	tag, err := h.tagService.GetTag(c.Request.Context(), id)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, tag)
+}
+
+// CreateTag 创建标签
+func (h *UserTagHandler) CreateTag(c *gin.Context) {
+	var req service.UserTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	tag, err := h.tagService.CreateTag(c.Request.Context(), &req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, tag)
+}
+
+// UpdateTag 更新标签
+func (h *UserTagHandler) UpdateTag(c *gin.Context) {
+	var req UpdateUserTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	tag, err := h.tagService.UpdateTag(c.Request.Context(), req.ID, &req.UserTagRequest)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, tag)
+}
+
+// DeleteTag 删除标签
+func (h *UserTagHandler) DeleteTag(c *gin.Context) {
+	var req struct {
+		ID int64 `json:"id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	if err := h.tagService.DeleteTag(c.Request.Context(), req.ID); err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, nil)
+}
+
+// RecomputeTag 立即重算规则标签
+func (h *UserTagHandler) RecomputeTag(c *gin.Context) {
+	var req struct {
+		ID int64 `json:"id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	tag, err := h.tagService.RecomputeTag(c.Request.Context(), req.ID)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, tag)
+}
+
+// ListMembers 标签下的用户
+func (h *UserTagHandler) ListMembers(c *gin.Context) {
+	var req struct {
+		TagID int64 `json:"tag_id" binding:"required"`
+		Page  int   `json:"page" binding:"required,min=1"`
+		Limit int   `json:"limit" binding:"required,min=1,max=100"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	list, total, err := h.tagService.ListMembers(c.Request.Context(), req.TagID, req.Page, req.Limit)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ListResponse(c, list, total)
+}
+
+// AddMembers 为用户打上手动标签
+func (h *UserTagHandler) AddMembers(c *gin.Context) {
+	var req UserTagMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	added, err := h.tagService.AddMembers(c.Request.Context(), req.TagID, req.UserIDs)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, gin.H{"added": added})
+}
+
+// RemoveMembers 移除用户的手动标签
+func (h *UserTagHandler) RemoveMembers(c *gin.Context) {
+	var req UserTagMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.New(errors.ErrCodeInvalidParams, "无效的请求参数", err))
+		return
+	}
+
+	if err := h.tagService.RemoveMembers(c.Request.Context(), req.TagID, req.UserIDs); err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.Success(c, nil)
+}
+
+// UserTags 用户拥有的标签
+func (h *UserTagHandler) UserTags(c *gin.Context) {
+	tags, err := h.tagService.UserTags(c.Request.Context(), c.Param("user_id"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ListResponse(c, tags, int64(len(tags)))
+}
+
+// RegisterUserTagRoutes 注册用户标签路由
+func RegisterUserTagRoutes(r *gin.RouterGroup, h *UserTagHandler, perm, audit func(string) gin.HandlerFunc) {
+	r.POST("/list", perm(model.PermTagsRead), h.ListTags)                                              // 标签列表
+	r.GET("/:id", perm(model.PermTagsRead), h.GetTag)                                                  // 标签详情
+	r.GET("/users/:user_id", perm(model.PermTagsRead), h.UserTags)                                     // 用户拥有的标签
+	r.POST("/members", perm(model.PermTagsRead), h.ListMembers)                                        // 标签下的用户
+	r.POST("/create", perm(model.PermTagsWrite), audit("tag.create"), h.CreateTag)                     // 创建标签
+	r.POST("/edit", perm(model.PermTagsWrite), audit("tag.update"), h.UpdateTag)                       // 更新标签
+	r.POST("/delete", perm(model.PermTagsWrite), audit("tag.delete"), h.DeleteTag)                     // 删除标签
+	r.POST("/recompute", perm(model.PermTagsWrite), audit("tag.recompute"), h.RecomputeTag)            // 立即重算规则标签
+	r.POST("/members/add", perm(model.PermTagsWrite), audit("tag.members.add"), h.AddMembers)          // 手动标签添加用户
+	r.POST("/members/remove", perm(model.PermTagsWrite), audit("tag.members.remove"), h.RemoveMembers) // 手动标签移除用户
+}
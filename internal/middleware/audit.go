@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reusedev/uportal-api/pkg/audit"
+	"github.com/reusedev/uportal-api/pkg/consts"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/response"
+)
+
+// maxAuditBodySize 审计记录的请求体上限，超出部分不记录
+const maxAuditBodySize = 64 << 10
+
+// Audit 返回按操作类型生成审计中间件的函数，需挂在 AdminAuth 和权限校验之后；
+// 业务代码通过 audit.Record 补充操作对象和变更前后的数据，请求结束后统一写入
+func Audit(recorder audit.Recorder) func(action string) gin.HandlerFunc {
+	return func(action string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			var params []byte
+			if c.Request.Body != nil {
+				body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxAuditBodySize+1))
+				if err == nil {
+					// 读过的部分和未读部分拼回去，保证后续绑定不受影响
+					c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+					if len(body) <= maxAuditBodySize {
+						params = audit.MaskParams(body)
+					}
+				}
+			}
+
+			entry := &audit.Entry{}
+			c.Request = c.Request.WithContext(audit.NewContext(c.Request.Context(), entry))
+
+			c.Next()
+
+			targetType, targetID, before, after := entry.Snapshot()
+			log := &audit.Log{
+				AdminID:    c.GetInt64(consts.UserId),
+				AdminName:  c.GetString(consts.UserName),
+				Action:     action,
+				TargetType: targetType,
+				TargetID:   targetID,
+				Before:     before,
+				After:      after,
+				Params:     params,
+				Success:    true,
+				IP:         c.ClientIP(),
+				UserAgent:  c.Request.UserAgent(),
+				RequestID:  c.GetString(consts.RequestId),
+				Method:     c.Request.Method,
+				Path:       c.FullPath(),
+			}
+			if v, ok := c.Get(response.ErrorKey); ok {
+				log.Success = false
+				if e, ok := v.(*errors.Error); ok {
+					log.Error = e.Message
+				} else if err, ok := v.(error); ok {
+					log.Error = err.Error()
+				}
+			} else if c.Writer.Status() >= 400 {
+				log.Success = false
+			}
+			// 请求结束后上下文可能已被取消，审计写入不应受影响
+			recorder.Write(context.WithoutCancel(c.Request.Context()), log)
+		}
+	}
+}
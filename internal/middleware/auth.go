@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"github.com/reusedev/uportal-api/pkg/consts"
 	"net/http"
 	"strings"
@@ -76,14 +77,30 @@ func Auth() gin.HandlerFunc {
 			return
 		}
 
+		// 管理员模拟用户的令牌只读，且每次使用都记录审计日志
+		if claims.Scope == jwt.ScopeImpersonation {
+			impersonate(c, claims)
+			return
+		}
+
 		// 将用户ID存入上下文
 		c.Set(consts.UserId, claims.Sub)
 		c.Next()
 	}
 }
 
-// AdminAuth 管理员认证中间件
+// AdminAuth 管理员认证中间件，仅接受完整权限令牌
 func AdminAuth() gin.HandlerFunc {
+	return adminAuth(false)
+}
+
+// AdminSecurityAuth 管理员账号安全接口认证中间件，
+// 额外接受强制改密、待绑定2FA的受限令牌
+func AdminSecurityAuth() gin.HandlerFunc {
+	return adminAuth(true)
+}
+
+func adminAuth(allowRestricted bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := c.GetHeader("Authorization")
 		if token == "" {
@@ -111,8 +128,70 @@ func AdminAuth() gin.HandlerFunc {
 			return
 		}
 
+		// 受限令牌只能访问账号安全接口
+		if claims.Scope != "" && !allowRestricted {
+			message := "请先完成双因素认证绑定"
+			if claims.Scope == jwt.ScopePasswordChange {
+				message = "请先修改默认密码"
+			}
+			c.JSON(http.StatusForbidden, gin.H{"code": 1003, "message": message})
+			c.Abort()
+			return
+		}
+
 		// 将用户ID存入上下文
 		c.Set(consts.UserId, claims.UserID)
+		c.Set(consts.UserName, claims.Username)
 		c.Next()
 	}
 }
+
+// PermissionChecker 校验管理员是否拥有指定权限
+type PermissionChecker interface {
+	CheckPermission(ctx context.Context, adminID int64, permission string) error
+}
+
+// RequirePermission 返回按权限生成中间件的函数，需挂在 AdminAuth 之后
+func RequirePermission(checker PermissionChecker) func(permission string) gin.HandlerFunc {
+	return func(permission string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			err := checker.CheckPermission(c.Request.Context(), c.GetInt64(consts.UserId), permission)
+			if err != nil {
+				abortPermission(c, err)
+				return
+			}
+			c.Next()
+		}
+	}
+}
+
+// RequireAnyPermission 返回按权限生成中间件的函数，拥有其中任一权限即可访问，需挂在 AdminAuth 之后。
+// 用于具体权限取决于请求内容的接口，由业务层再按请求校验
+func RequireAnyPermission(checker PermissionChecker) func(permissions ...string) gin.HandlerFunc {
+	return func(permissions ...string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			var err error
+			for _, permission := range permissions {
+				err = checker.CheckPermission(c.Request.Context(), c.GetInt64(consts.UserId), permission)
+				if err == nil {
+					c.Next()
+					return
+				}
+				if e, ok := err.(*errors.Error); !ok || e.Code != errors.ErrCodeForbidden {
+					break
+				}
+			}
+			abortPermission(c, err)
+		}
+	}
+}
+
+// abortPermission 权限校验未通过时中止请求
+func abortPermission(c *gin.Context, err error) {
+	if e, ok := err.(*errors.Error); ok {
+		c.JSON(e.HTTPStatus(), gin.H{"code": e.Code, "message": e.Message})
+	} else {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": errors.ErrCodeInternal, "message": "权限校验失败"})
+	}
+	c.Abort()
+}
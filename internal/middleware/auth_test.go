@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reusedev/uportal-api/pkg/errors"
+)
+
+// staticChecker 按固定的权限集合校验，err 不为空时所有校验都返回该错误
+type staticChecker struct {
+	perms map[string]bool
+	err   error
+}
+
+func (c *staticChecker) CheckPermission(ctx context.Context, adminID int64, permission string) error {
+	if c.err != nil {
+		return c.err
+	}
+	if !c.perms[permission] {
+		return errors.New(errors.ErrCodeForbidden, "没有访问权限", nil)
+	}
+	return nil
+}
+
+func TestRequireAnyPermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cases := []struct {
+		name    string
+		checker *staticChecker
+		status  int
+	}{
+		{"first permission", &staticChecker{perms: map[string]bool{"tokens:adjust": true}}, http.StatusOK},
+		{"second permission", &staticChecker{perms: map[string]bool{"users:write": true}}, http.StatusOK},
+		{"read only", &staticChecker{perms: map[string]bool{"users:read": true}}, http.StatusForbidden},
+		{"check failed", &staticChecker{err: stderrors.New("db down")}, http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		r := gin.New()
+		anyPerm := RequireAnyPermission(c.checker)
+		r.POST("/create", anyPerm("tokens:adjust", "users:write"), func(c *gin.Context) { c.Status(http.StatusOK) })
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/create", nil))
+		if w.Code != c.status {
+			t.Errorf("%s: status %d, want %d", c.name, w.Code, c.status)
+		}
+	}
+}
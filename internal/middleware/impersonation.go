@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reusedev/uportal-api/pkg/audit"
+	"github.com/reusedev/uportal-api/pkg/consts"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/jwt"
+	"github.com/reusedev/uportal-api/pkg/response"
+)
+
+// impersonationAction 模拟令牌访问用户接口的审计操作类型
+const impersonationAction = "user.impersonate.access"
+
+// impersonationReadPaths 以 POST 实现的只读用户接口，模拟令牌可以访问
+var impersonationReadPaths = map[string]bool{
+	"/api/points/records":     true, // 代币明细
+	"/api/notifications/list": true, // 通知列表
+}
+
+// impersonationDeniedPaths 虽为 GET 但有副作用或需用户本人操作的接口，模拟令牌不能访问
+var impersonationDeniedPaths = map[string]bool{
+	"/api/privacy/export": true, // 个人数据导出
+	"/api/invite/share":   true, // 用户还没有邀请码时会生成
+	"/api/invite/wxacode": true, // 同上，并调用微信接口生成小程序码
+}
+
+// impersonationRecorder 模拟令牌的审计日志存储，未设置时拒绝所有模拟令牌
+var impersonationRecorder audit.Recorder
+
+// SetImpersonationRecorder 设置模拟令牌的审计日志存储，需在注册用户路由前调用
+func SetImpersonationRecorder(recorder audit.Recorder) {
+	impersonationRecorder = recorder
+}
+
+// impersonate 处理管理员模拟用户的请求：只允许只读接口，无论是否放行都写入审计日志
+func impersonate(c *gin.Context, claims *jwt.Claims) {
+	if impersonationRecorder == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 1002, "message": "不支持模拟登录令牌"})
+		c.Abort()
+		return
+	}
+
+	c.Set(consts.UserId, claims.Sub)
+	c.Set(consts.ImpersonatorId, claims.ImpersonatorID)
+
+	log := &audit.Log{
+		AdminID:    claims.ImpersonatorID,
+		AdminName:  claims.Username,
+		Action:     impersonationAction,
+		TargetType: "user",
+		TargetID:   claims.Sub,
+		After:      map[string]interface{}{"session_id": claims.ID, "uri": c.Request.URL.RequestURI()},
+		Success:    true,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		RequestID:  c.GetString(consts.RequestId),
+		Method:     c.Request.Method,
+		Path:       c.FullPath(),
+	}
+	// 请求结束后上下文可能已被取消，审计写入不应受影响
+	defer func() {
+		impersonationRecorder.Write(context.WithoutCancel(c.Request.Context()), log)
+	}()
+
+	if !impersonationAllowed(c) {
+		log.Success = false
+		log.Error = "模拟登录令牌为只读，不能访问该接口"
+		c.JSON(http.StatusForbidden, gin.H{"code": 1003, "message": log.Error})
+		c.Abort()
+		return
+	}
+
+	c.Next()
+
+	if v, ok := c.Get(response.ErrorKey); ok {
+		log.Success = false
+		if e, ok := v.(*errors.Error); ok {
+			log.Error = e.Message
+		} else if err, ok := v.(error); ok {
+			log.Error = err.Error()
+		}
+	} else if c.Writer.Status() >= 400 {
+		log.Success = false
+	}
+}
+
+// impersonationAllowed 模拟令牌只能访问 GET/HEAD 请求和登记过的只读 POST 接口
+func impersonationAllowed(c *gin.Context) bool {
+	path := c.FullPath()
+	if impersonationDeniedPaths[path] {
+		return false
+	}
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	case http.MethodPost:
+		return impersonationReadPaths[path]
+	default:
+		return false
+	}
+}
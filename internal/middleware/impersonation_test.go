@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reusedev/uportal-api/pkg/audit"
+	"github.com/reusedev/uportal-api/pkg/jwt"
+)
+
+// memoryRecorder 把审计日志保存在内存中
+type memoryRecorder struct {
+	mu   sync.Mutex
+	logs []*audit.Log
+}
+
+func (r *memoryRecorder) Write(ctx context.Context, log *audit.Log) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logs = append(r.logs, log)
+}
+
+func newImpersonationRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	claims := &jwt.Claims{Sub: "u1", ImpersonatorID: 7, Username: "root", Scope: jwt.ScopeImpersonation}
+	r.Use(func(c *gin.Context) { impersonate(c, claims) })
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/api/user/profile", ok)
+	r.HEAD("/api/user/profile", ok)
+	r.PUT("/api/user/profile", ok)
+	r.DELETE("/api/user/profile", ok)
+	r.POST("/api/points/records", ok)
+	r.POST("/api/notifications/list", ok)
+	r.POST("/api/orders/create", ok)
+	r.GET("/api/privacy/export", ok)
+	r.GET("/api/invite/share", ok)
+	r.GET("/api/invite/wxacode", ok)
+	r.GET("/api/invite/dashboard", ok)
+	r.GET("/api/orders/:id", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+	return r
+}
+
+func TestImpersonationAllowList(t *testing.T) {
+	recorder := &memoryRecorder{}
+	SetImpersonationRecorder(recorder)
+	defer SetImpersonationRecorder(nil)
+	r := newImpersonationRouter()
+
+	cases := []struct {
+		method  string
+		path    string
+		status  int
+		success bool
+	}{
+		{http.MethodGet, "/api/user/profile", http.StatusOK, true},
+		{http.MethodHead, "/api/user/profile", http.StatusOK, true},
+		{http.MethodPut, "/api/user/profile", http.StatusForbidden, false},
+		{http.MethodDelete, "/api/user/profile", http.StatusForbidden, false},
+		{http.MethodPost, "/api/points/records", http.StatusOK, true},
+		{http.MethodPost, "/api/notifications/list", http.StatusOK, true},
+		{http.MethodPost, "/api/orders/create", http.StatusForbidden, false},
+		{http.MethodGet, "/api/privacy/export", http.StatusForbidden, false},
+		{http.MethodGet, "/api/invite/share", http.StatusForbidden, false},
+		{http.MethodGet, "/api/invite/wxacode", http.StatusForbidden, false},
+		{http.MethodGet, "/api/invite/dashboard", http.StatusOK, true},
+		{http.MethodGet, "/api/orders/o1", http.StatusNotFound, false},
+	}
+	for _, c := range cases {
+		recorder.logs = nil
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(c.method, c.path, nil))
+		if w.Code != c.status {
+			t.Errorf("%s %s: status %d, want %d", c.method, c.path, w.Code, c.status)
+		}
+		// 无论是否放行都要写一条审计日志
+		if len(recorder.logs) != 1 {
+			t.Errorf("%s %s: %d audit logs, want 1", c.method, c.path, len(recorder.logs))
+			continue
+		}
+		log := recorder.logs[0]
+		if log.Success != c.success {
+			t.Errorf("%s %s: audit success %v, want %v", c.method, c.path, log.Success, c.success)
+		}
+		if log.AdminID != 7 || log.TargetID != "u1" || log.Action != impersonationAction {
+			t.Errorf("%s %s: unexpected audit log %+v", c.method, c.path, log)
+		}
+	}
+}
+
+func TestImpersonationWithoutRecorder(t *testing.T) {
+	SetImpersonationRecorder(nil)
+	r := newImpersonationRouter()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/user/profile", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
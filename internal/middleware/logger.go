@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/reusedev/uportal-api/pkg/consts"
 	"go.uber.org/zap"
 )
 
@@ -22,6 +23,7 @@ func Logger(logger *zap.Logger) gin.HandlerFunc {
 		cost := time.Since(start)
 		logger.Info("request",
 			zap.Int("status", c.Writer.Status()),
+			zap.String("request_id", c.GetString(consts.RequestId)),
 			zap.String("method", c.Request.Method),
 			zap.String("path", path),
 			zap.String("query", query),
@@ -0,0 +1,88 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 账号令牌用途
+const (
+	AccountTokenEmailVerify   = "email_verify"   // 邮箱验证
+	AccountTokenPasswordReset = "password_reset" // 密码重置
+)
+
+// AccountToken 账号一次性令牌表结构体（邮箱验证、密码重置）
+type AccountToken struct {
+	TokenID   int64      `gorm:"column:token_id;primaryKey;autoIncrement" json:"token_id"`                                                 // 令牌ID，主键，自增
+	UserID    string     `gorm:"column:user_id;type:varchar(13);not null;index:idx_account_tokens_user_purpose,priority:1" json:"user_id"` // 用户ID
+	Purpose   string     `gorm:"column:purpose;type:varchar(20);not null;index:idx_account_tokens_user_purpose,priority:2" json:"purpose"` // 令牌用途
+	TokenHash string     `gorm:"column:token_hash;type:char(64);not null;uniqueIndex:uk_account_tokens_hash" json:"-"`                     // 链接令牌的SHA-256哈希
+	CodeHash  string     `gorm:"column:code_hash;type:char(64);not null" json:"-"`                                                         // 数字验证码的SHA-256哈希
+	Email     string     `gorm:"column:email;type:varchar(100);not null" json:"email"`                                                     // 发送时的邮箱地址
+	Attempts  int        `gorm:"column:attempts;not null;default:0" json:"attempts"`                                                       // 验证码错误尝试次数
+	ExpiresAt time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`                                                             // 过期时间
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at"`                                                                            // 使用时间，非空表示已失效
+	CreatedAt time.Time  `gorm:"column:created_at;not null;autoCreateTime" json:"created_at"`                                              // 创建时间
+}
+
+func (AccountToken) TableName() string {
+	return "account_tokens"
+}
+
+// CreateAccountToken 创建账号令牌
+func CreateAccountToken(db *gorm.DB, token *AccountToken) error {
+	return db.Create(token).Error
+}
+
+// GetAccountTokenByHash 根据令牌哈希获取账号令牌
+func GetAccountTokenByHash(db *gorm.DB, purpose, tokenHash string) (*AccountToken, error) {
+	var token AccountToken
+	err := db.Where("purpose = ? AND token_hash = ?", purpose, tokenHash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// GetLatestAccountToken 获取用户某用途下最近一次签发的令牌
+func GetLatestAccountToken(db *gorm.DB, userID, purpose string) (*AccountToken, error) {
+	var token AccountToken
+	err := db.Where("user_id = ? AND purpose = ?", userID, purpose).
+		Order("created_at DESC").
+		First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ConsumeAccountToken 将令牌标记为已使用，返回是否由本次调用成功标记
+// 错误尝试次数超过 maxAttempts 的令牌不会被标记
+func ConsumeAccountToken(db *gorm.DB, tokenID int64, maxAttempts int) (bool, error) {
+	result := db.Model(&AccountToken{}).
+		Where("token_id = ? AND used_at IS NULL AND attempts <= ?", tokenID, maxAttempts).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// TryAccountTokenAttempt 原子地占用一次验证码尝试次数，次数已达上限时返回 false
+func TryAccountTokenAttempt(db *gorm.DB, tokenID int64, maxAttempts int) (bool, error) {
+	result := db.Model(&AccountToken{}).
+		Where("token_id = ? AND used_at IS NULL AND attempts < ?", tokenID, maxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// InvalidateAccountTokens 使用户某用途下所有未使用的令牌失效
+func InvalidateAccountTokens(db *gorm.DB, userID, purpose string) error {
+	return db.Model(&AccountToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+}
@@ -0,0 +1,199 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 成就类别
+const (
+	AchievementKindAchievement = "achievement" // 成就，通常不限周期，完成后永久保留
+	AchievementKindQuest       = "quest"       // 任务挑战，通常按周期重置
+)
+
+// 推进成就进度的业务事件
+const (
+	AchievementEventTaskComplete = "task_complete" // 完成奖励任务，Key 为任务ID
+	AchievementEventTokenConsume = "token_consume" // 消耗代币，Key 为功能编码，数量为消耗的代币数
+	AchievementEventInvite       = "invite"        // 邀请好友注册，Key 为被邀请人ID
+	AchievementEventOrderPaid    = "order_paid"    // 订单支付成功，Key 为订单ID，数量为订单金额（分）
+)
+
+// 事件的累计方式
+const (
+	AchievementAggregateCount    = "count"    // 累计事件次数
+	AchievementAggregateSum      = "sum"      // 累计事件数量
+	AchievementAggregateDistinct = "distinct" // 累计不同 Key 的个数
+)
+
+// 成就统计周期，按用户时区划分
+const (
+	AchievementPeriodNone    = "none"    // 不重置
+	AchievementPeriodDaily   = "daily"   // 每天重置
+	AchievementPeriodWeekly  = "weekly"  // 每周一重置
+	AchievementPeriodMonthly = "monthly" // 每月 1 日重置
+)
+
+// Achievement 成就与任务挑战定义表结构体
+type Achievement struct {
+	ID           int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`                                               // 主键，自增
+	Code         string    `gorm:"column:code;type:varchar(50);not null;uniqueIndex:uk_achievements_code" json:"code"`         // 成就编码
+	Name         string    `gorm:"column:name;type:varchar(100);not null" json:"name"`                                         // 名称
+	Description  *string   `gorm:"column:description;type:varchar(255)" json:"description"`                                    // 说明
+	Kind         string    `gorm:"column:kind;type:varchar(20);not null" json:"kind"`                                          // 类别：achievement/quest
+	EventType    string    `gorm:"column:event_type;type:varchar(20);not null;index:idx_achievements_event" json:"event_type"` // 推进进度的事件
+	Aggregate    string    `gorm:"column:aggregate;type:varchar(10);not null" json:"aggregate"`                                // 累计方式：count/sum/distinct
+	FilterKey    *string   `gorm:"column:filter_key;type:varchar(64)" json:"filter_key"`                                       // 只统计 Key 等于该值的事件，为空不限
+	Target       int64     `gorm:"column:target;not null" json:"target"`                                                       // 目标值
+	Period       string    `gorm:"column:period;type:varchar(10);not null;default:none" json:"period"`                         // 统计周期：none/daily/weekly/monthly
+	RewardTokens int       `gorm:"column:reward_tokens;not null;default:0" json:"reward_tokens"`                               // 完成奖励的代币数
+	BadgeName    *string   `gorm:"column:badge_name;type:varchar(50)" json:"badge_name"`                                       // 完成后获得的徽章名称，为空不发徽章
+	BadgeIcon    *string   `gorm:"column:badge_icon;type:varchar(255)" json:"badge_icon"`                                      // 徽章图标URL
+	Sort         int       `gorm:"column:sort;not null;default:0" json:"sort"`                                                 // 排序，越小越靠前
+	Status       int8      `gorm:"column:status;not null;default:1" json:"status"`                                             // 状态：1=启用，0=停用
+	CreatedAt    time.Time `gorm:"column:created_at;not null;autoCreateTime" json:"created_at"`                                // 创建时间
+	UpdatedAt    time.Time `gorm:"column:updated_at;not null;autoUpdateTime" json:"updated_at"`                                // 更新时间
+}
+
+func (Achievement) TableName() string {
+	return "achievements"
+}
+
+// UserAchievement 用户成就进度表结构体，周期性成就每个周期一条
+type UserAchievement struct {
+	ID            int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`                                                                     // 主键，自增
+	UserID        string     `gorm:"column:user_id;type:varchar(13);not null;uniqueIndex:uk_user_achievements_period,priority:1" json:"user_id"`       // 用户ID
+	AchievementID int64      `gorm:"column:achievement_id;not null;uniqueIndex:uk_user_achievements_period,priority:2" json:"achievement_id"`          // 成就ID
+	PeriodKey     string     `gorm:"column:period_key;type:varchar(10);not null;uniqueIndex:uk_user_achievements_period,priority:3" json:"period_key"` // 周期标识：all/2006-01-02/2006-W01/2006-01
+	Progress      int64      `gorm:"column:progress;not null;default:0" json:"progress"`                                                               // 当前进度
+	CompletedAt   *time.Time `gorm:"column:completed_at" json:"completed_at"`                                                                          // 完成时间
+	RewardTokens  int        `gorm:"column:reward_tokens;not null;default:0" json:"reward_tokens"`                                                     // 完成时发放的代币数
+	CreatedAt     time.Time  `gorm:"column:created_at;not null;autoCreateTime" json:"created_at"`                                                      // 创建时间
+	UpdatedAt     time.Time  `gorm:"column:updated_at;not null;autoUpdateTime" json:"updated_at"`                                                      // 更新时间
+}
+
+func (UserAchievement) TableName() string {
+	return "user_achievements"
+}
+
+// UserAchievementMark 按不同 Key 累计的成就已计入的 Key
+type UserAchievementMark struct {
+	UserAchievementID int64     `gorm:"column:user_achievement_id;primaryKey" json:"user_achievement_id"` // 用户成就进度ID
+	Mark              string    `gorm:"column:mark;type:varchar(64);primaryKey" json:"mark"`              // 已计入的事件 Key
+	CreatedAt         time.Time `gorm:"column:created_at;not null;autoCreateTime" json:"created_at"`      // 计入时间
+}
+
+func (UserAchievementMark) TableName() string {
+	return "user_achievement_marks"
+}
+
+// CreateAchievement 创建成就
+func CreateAchievement(db *gorm.DB, a *Achievement) error {
+	return db.Create(a).Error
+}
+
+// GetAchievement 获取成就
+func GetAchievement(db *gorm.DB, id int64) (*Achievement, error) {
+	var a Achievement
+	if err := db.Where("id = ?", id).First(&a).Error; err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// UpdateAchievement 更新成就
+func UpdateAchievement(db *gorm.DB, id int64, updates map[string]interface{}) error {
+	return db.Model(&Achievement{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// DeleteAchievement 删除成就及用户进度
+func DeleteAchievement(db *gorm.DB, id int64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_achievement_id IN (?)",
+			tx.Model(&UserAchievement{}).Select("id").Where("achievement_id = ?", id)).
+			Delete(&UserAchievementMark{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("achievement_id = ?", id).Delete(&UserAchievement{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Achievement{}, id).Error
+	})
+}
+
+// ListAchievements 获取成就定义，kind 为空不过滤，status 为 nil 不过滤
+func ListAchievements(db *gorm.DB, kind string, status *int8) ([]*Achievement, error) {
+	var list []*Achievement
+	query := db.Model(&Achievement{})
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+	err := query.Order("sort ASC, id ASC").Find(&list).Error
+	return list, err
+}
+
+// ListActiveAchievementsByEvent 获取由某类事件推进的启用成就
+func ListActiveAchievementsByEvent(db *gorm.DB, eventType string) ([]*Achievement, error) {
+	var list []*Achievement
+	err := db.Where("status = 1 AND event_type = ?", eventType).Find(&list).Error
+	return list, err
+}
+
+// LockUserAchievement 获取并锁定用户某周期的成就进度，不存在时先创建
+func LockUserAchievement(tx *gorm.DB, userID string, achievementID int64, periodKey string) (*UserAchievement, error) {
+	row := &UserAchievement{UserID: userID, AchievementID: achievementID, PeriodKey: periodKey}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(row).Error; err != nil {
+		return nil, err
+	}
+	var ua UserAchievement
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND achievement_id = ? AND period_key = ?", userID, achievementID, periodKey).
+		First(&ua).Error
+	if err != nil {
+		return nil, err
+	}
+	return &ua, nil
+}
+
+// AddUserAchievementMark 记录已计入的 Key，返回是否为新 Key
+func AddUserAchievementMark(tx *gorm.DB, userAchievementID int64, mark string) (bool, error) {
+	res := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&UserAchievementMark{UserAchievementID: userAchievementID, Mark: mark})
+	return res.RowsAffected > 0, res.Error
+}
+
+// ListUserAchievements 获取用户的成就进度，periodKeys 为成就ID到当前周期标识的映射
+func ListUserAchievements(db *gorm.DB, userID string, periodKeys map[int64]string) ([]*UserAchievement, error) {
+	if len(periodKeys) == 0 {
+		return nil, nil
+	}
+	ids := make([]int64, 0, len(periodKeys))
+	for id := range periodKeys {
+		ids = append(ids, id)
+	}
+	var rows []*UserAchievement
+	if err := db.Where("user_id = ? AND achievement_id IN ?", userID, ids).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	current := rows[:0]
+	for _, r := range rows {
+		if periodKeys[r.AchievementID] == r.PeriodKey {
+			current = append(current, r)
+		}
+	}
+	return current, nil
+}
+
+// ListUserBadges 获取用户已获得徽章的成就进度，按完成时间倒序
+func ListUserBadges(db *gorm.DB, userID string) ([]*UserAchievement, error) {
+	var rows []*UserAchievement
+	err := db.Where("user_id = ? AND completed_at IS NOT NULL AND achievement_id IN (?)", userID,
+		db.Model(&Achievement{}).Select("id").Where("badge_name IS NOT NULL AND badge_name <> ''")).
+		Order("completed_at DESC").Find(&rows).Error
+	return rows, err
+}
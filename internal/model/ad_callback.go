@@ -0,0 +1,52 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 激励视频广告平台
+const (
+	AdNetworkAdMob  = "admob"  // Google AdMob
+	AdNetworkPangle = "pangle" // 穿山甲
+	AdNetworkYLH    = "ylh"    // 腾讯优量汇
+)
+
+// 广告回调处理结果
+const (
+	AdCallbackGranted  = "granted"  // 已发放奖励
+	AdCallbackRejected = "rejected" // 验签通过但未发放，如任务次数已达上限
+)
+
+// AdRewardCallback 激励视频广告服务端回调记录，按平台和交易号去重
+type AdRewardCallback struct {
+	ID            int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`                                                                              // 主键，自增
+	Network       string    `gorm:"column:network;type:varchar(10);not null;uniqueIndex:uk_ad_callbacks_transaction,priority:1" json:"network"`                // 广告平台
+	TransactionID string    `gorm:"column:transaction_id;type:varchar(128);not null;uniqueIndex:uk_ad_callbacks_transaction,priority:2" json:"transaction_id"` // 平台交易号
+	UserID        string    `gorm:"column:user_id;type:varchar(13);not null;index:idx_ad_callbacks_user" json:"user_id"`                                       // 用户ID
+	TaskID        *int      `gorm:"column:task_id" json:"task_id"`                                                                                             // 匹配的任务ID
+	Status        string    `gorm:"column:status;type:varchar(10);not null" json:"status"`                                                                     // 处理结果：granted/rejected
+	Reason        *string   `gorm:"column:reason;type:varchar(255)" json:"reason"`                                                                             // 未发放原因
+	Reward        int       `gorm:"column:reward;not null;default:0" json:"reward"`                                                                            // 发放的代币数
+	RawQuery      string    `gorm:"column:raw_query;type:text;not null" json:"raw_query"`                                                                      // 回调原始参数
+	CreatedAt     time.Time `gorm:"column:created_at;not null;autoCreateTime" json:"created_at"`                                                               // 回调时间
+}
+
+func (AdRewardCallback) TableName() string {
+	return "ad_reward_callbacks"
+}
+
+// GetAdRewardCallback 按平台和交易号获取回调记录
+func GetAdRewardCallback(db *gorm.DB, network, transactionID string) (*AdRewardCallback, error) {
+	var cb AdRewardCallback
+	if err := db.Where("network = ? AND transaction_id = ?", network, transactionID).First(&cb).Error; err != nil {
+		return nil, err
+	}
+	return &cb, nil
+}
+
+// CreateAdRewardCallback 创建回调记录
+func CreateAdRewardCallback(db *gorm.DB, cb *AdRewardCallback) error {
+	return db.Create(cb).Error
+}
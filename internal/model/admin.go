@@ -1,6 +1,23 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AdminRecoveryCode 管理员双因素认证恢复码
+type AdminRecoveryCode struct {
+	ID        int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`                            // 主键，自增
+	AdminID   int        `gorm:"column:admin_id;not null;index:idx_recovery_codes_admin" json:"admin_id"` // 管理员ID
+	CodeHash  string     `gorm:"column:code_hash;type:char(64);not null" json:"-"`                        // 恢复码的SHA-256哈希
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at"`                                           // 使用时间，非空表示已失效
+	CreatedAt time.Time  `gorm:"column:created_at;not null;autoCreateTime" json:"created_at"`             // 生成时间
+}
+
+func (AdminRecoveryCode) TableName() string {
+	return "admin_recovery_codes"
+}
 
 // GetAdinUserByID 根据ID获取管理员用户
 func GetAdinUserByID(db *gorm.DB, id int64) (*AdminUser, error) {
@@ -16,3 +33,169 @@ func GetAdinUserByID(db *gorm.DB, id int64) (*AdminUser, error) {
 func UpdateAdminUser(db *gorm.DB, id int64, updates map[string]interface{}) error {
 	return db.Model(&AdminUser{}).Where("admin_id = ?", id).Updates(updates).Error
 }
+
+// ReplaceAdminRecoveryCodes 删除管理员原有恢复码并写入新的一组
+func ReplaceAdminRecoveryCodes(db *gorm.DB, adminID int, hashes []string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := DeleteAdminRecoveryCodes(tx, adminID); err != nil {
+			return err
+		}
+		codes := make([]AdminRecoveryCode, 0, len(hashes))
+		for _, h := range hashes {
+			codes = append(codes, AdminRecoveryCode{AdminID: adminID, CodeHash: h})
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// DeleteAdminRecoveryCodes 删除管理员全部恢复码
+func DeleteAdminRecoveryCodes(db *gorm.DB, adminID int) error {
+	return db.Where("admin_id = ?", adminID).Delete(&AdminRecoveryCode{}).Error
+}
+
+// UseAdminRecoveryCode 核销一个未使用的恢复码，返回是否核销成功
+func UseAdminRecoveryCode(db *gorm.DB, adminID int, hash string) (bool, error) {
+	result := db.Model(&AdminRecoveryCode{}).
+		Where("admin_id = ? AND code_hash = ? AND used_at IS NULL", adminID, hash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CountUnusedAdminRecoveryCodes 统计管理员剩余可用恢复码数量
+func CountUnusedAdminRecoveryCodes(db *gorm.DB, adminID int) (int64, error) {
+	var count int64
+	err := db.Model(&AdminRecoveryCode{}).
+		Where("admin_id = ? AND used_at IS NULL", adminID).
+		Count(&count).Error
+	return count, err
+}
+
+// 管理员邀请状态，由使用、撤销时间和有效期推算，不单独存储
+const (
+	InvitationStatusPending  = "pending"  // 待接受
+	InvitationStatusAccepted = "accepted" // 已接受
+	InvitationStatusRevoked  = "revoked"  // 已撤销
+	InvitationStatusExpired  = "expired"  // 已过期
+)
+
+// AdminInvitation 管理员邀请，一次性使用，被邀请人凭链接设置自己的密码完成开户
+type AdminInvitation struct {
+	ID        int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`                                                   // 主键，自增
+	TokenHash string     `gorm:"column:token_hash;type:char(64);not null;uniqueIndex:uk_admin_invitations_token" json:"-"`       // 邀请令牌的SHA-256哈希
+	Username  string     `gorm:"column:username;type:varchar(50);not null;index:idx_admin_invitations_username" json:"username"` // 预分配的用户名
+	Role      string     `gorm:"column:role;type:varchar(20);not null" json:"role"`                                              // 接受后获得的角色
+	Note      *string    `gorm:"column:note;type:varchar(255)" json:"note"`                                                      // 备注
+	CreatedBy int        `gorm:"column:created_by;not null" json:"created_by"`                                                   // 邀请人（管理员ID）
+	ExpiresAt time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`                                                   // 过期时间
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at"`                                                                  // 接受时间
+	AdminID   *int       `gorm:"column:admin_id" json:"admin_id"`                                                                // 接受后创建的管理员ID
+	RevokedAt *time.Time `gorm:"column:revoked_at" json:"revoked_at"`                                                            // 撤销时间
+	Status    string     `gorm:"-" json:"status"`                                                                                // 当前状态
+	CreatedAt time.Time  `gorm:"column:created_at;not null;autoCreateTime" json:"created_at"`                                    // 创建时间
+}
+
+func (AdminInvitation) TableName() string {
+	return "admin_invitations"
+}
+
+// AfterFind 查询后推算邀请状态
+func (i *AdminInvitation) AfterFind(tx *gorm.DB) error {
+	i.Status = i.CurrentStatus(time.Now())
+	return nil
+}
+
+// CurrentStatus 返回邀请在指定时间的状态
+func (i *AdminInvitation) CurrentStatus(now time.Time) string {
+	switch {
+	case i.UsedAt != nil:
+		return InvitationStatusAccepted
+	case i.RevokedAt != nil:
+		return InvitationStatusRevoked
+	case !now.Before(i.ExpiresAt):
+		return InvitationStatusExpired
+	default:
+		return InvitationStatusPending
+	}
+}
+
+// CreateAdminInvitation 创建管理员邀请
+func CreateAdminInvitation(db *gorm.DB, invitation *AdminInvitation) error {
+	if err := db.Create(invitation).Error; err != nil {
+		return err
+	}
+	invitation.Status = invitation.CurrentStatus(time.Now())
+	return nil
+}
+
+// GetAdminInvitationByID 根据ID获取邀请
+func GetAdminInvitationByID(db *gorm.DB, id int64) (*AdminInvitation, error) {
+	var invitation AdminInvitation
+	if err := db.Where("id = ?", id).First(&invitation).Error; err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// GetAdminInvitationByTokenHash 根据令牌哈希获取邀请
+func GetAdminInvitationByTokenHash(db *gorm.DB, hash string) (*AdminInvitation, error) {
+	var invitation AdminInvitation
+	if err := db.Where("token_hash = ?", hash).First(&invitation).Error; err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// ListAdminInvitations 分页获取邀请列表，按创建时间倒序
+func ListAdminInvitations(db *gorm.DB, page, limit int) ([]*AdminInvitation, int64, error) {
+	var (
+		invitations []*AdminInvitation
+		total       int64
+	)
+	query := db.Model(&AdminInvitation{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id DESC").Offset((page - 1) * limit).Limit(limit).Find(&invitations).Error
+	return invitations, total, err
+}
+
+// CountPendingAdminInvitations 统计某用户名尚未失效的邀请数量
+func CountPendingAdminInvitations(db *gorm.DB, username string) (int64, error) {
+	var count int64
+	err := db.Model(&AdminInvitation{}).
+		Where("username = ? AND used_at IS NULL AND revoked_at IS NULL AND expires_at > ?", username, time.Now()).
+		Count(&count).Error
+	return count, err
+}
+
+// AcceptAdminInvitation 核销一个仍然有效的邀请并记录创建的管理员，返回是否核销成功
+func AcceptAdminInvitation(db *gorm.DB, id int64, adminID int) (bool, error) {
+	result := db.Model(&AdminInvitation{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL AND expires_at > ?", id, time.Now()).
+		Updates(map[string]interface{}{"used_at": time.Now(), "admin_id": adminID})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// RevokeAdminInvitation 撤销一个待接受的邀请，返回是否撤销成功
+func RevokeAdminInvitation(db *gorm.DB, id int64) (bool, error) {
+	result := db.Model(&AdminInvitation{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CountAdminUsers 统计管理员数量
+func CountAdminUsers(db *gorm.DB) (int64, error) {
+	var count int64
+	err := db.Model(&AdminUser{}).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 日汇总指标
+const (
+	MetricNewUsers        = "new_users"        // 新增用户，维度为注册渠道（UserAuth.Provider），空维度为总数
+	MetricActiveUsers     = "dau"              // 日活跃用户（按登录日志去重），仅空维度
+	MetricRevenue         = "revenue"          // 充值收入，维度为支付方式，count 为支付成功订单数
+	MetricTokensGranted   = "tokens_granted"   // 发放代币，维度为变动类型（ChangeType）
+	MetricTokensConsumed  = "tokens_consumed"  // 消耗代币，维度为功能代码（feature_code）
+	MetricTaskCompletions = "task_completions" // 任务完成，维度为任务ID，amount 为发放奖励
+	MetricInvites         = "invites"          // 邀请注册，仅空维度
+	MetricInvitesRewarded = "invites_rewarded" // 已发放奖励的邀请，仅空维度，按邀请注册日期统计
+)
+
+// AnalyticsDailyStat 运营数据日汇总表结构体，由定时任务从业务表重算生成
+type AnalyticsDailyStat struct {
+	ID        int64     `gorm:"column:id;primaryKey;autoIncrement" json:"-"`                                                                      // 主键，自增
+	StatDate  time.Time `gorm:"column:stat_date;type:date;not null;uniqueIndex:uk_analytics_daily,priority:1" json:"stat_date"`                   // 统计日期
+	Metric    string    `gorm:"column:metric;type:varchar(32);not null;uniqueIndex:uk_analytics_daily,priority:2" json:"metric"`                  // 指标
+	Dimension string    `gorm:"column:dimension;type:varchar(64);not null;default:'';uniqueIndex:uk_analytics_daily,priority:3" json:"dimension"` // 维度值，空字符串表示汇总
+	Count     int64     `gorm:"column:count;not null;default:0" json:"count"`                                                                     // 次数/人数
+	Amount    float64   `gorm:"column:amount;type:decimal(16,2);not null;default:0" json:"amount"`                                                // 金额/代币数
+	UpdatedAt time.Time `gorm:"column:updated_at;not null;autoUpdateTime" json:"updated_at"`                                                      // 最近一次重算时间
+}
+
+func (AnalyticsDailyStat) TableName() string {
+	return "analytics_daily_stats"
+}
+
+// ReplaceAnalyticsDailyStats 用新结果整体替换某一天的汇总数据，维度消失时旧行也会被删除
+func ReplaceAnalyticsDailyStats(db *gorm.DB, day time.Time, stats []*AnalyticsDailyStat) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("stat_date = ?", day.Format("2006-01-02")).Delete(&AnalyticsDailyStat{}).Error; err != nil {
+			return err
+		}
+		if len(stats) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(stats, 200).Error
+	})
+}
+
+// ListAnalyticsDailyStats 获取日期区间 [start, end] 内指定指标的汇总数据
+func ListAnalyticsDailyStats(db *gorm.DB, start, end time.Time, metrics ...string) ([]*AnalyticsDailyStat, error) {
+	var stats []*AnalyticsDailyStat
+	query := db.Where("stat_date BETWEEN ? AND ?", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	if len(metrics) > 0 {
+		query = query.Where("metric IN ?", metrics)
+	}
+	err := query.Order("stat_date ASC, metric ASC, dimension ASC").Find(&stats).Error
+	return stats, err
+}
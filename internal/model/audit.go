@@ -0,0 +1,118 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 审计日志执行结果
+const (
+	AuditResultFailed  int8 = 0 // 失败
+	AuditResultSuccess int8 = 1 // 成功
+)
+
+// AdminAuditLog 管理端操作审计日志表结构体
+type AdminAuditLog struct {
+	ID         int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`                                                                              // 主键，自增
+	AdminID    int64     `gorm:"column:admin_id;not null;index:idx_audit_admin_time,priority:1" json:"admin_id"`                                            // 操作人ID
+	AdminName  string    `gorm:"column:admin_name;type:varchar(50);not null;default:''" json:"admin_name"`                                                  // 操作人用户名
+	Action     string    `gorm:"column:action;type:varchar(64);not null;index:idx_audit_action" json:"action"`                                              // 操作类型，如 user.update
+	TargetType string    `gorm:"column:target_type;type:varchar(32);not null;default:'';index:idx_audit_target,priority:1" json:"target_type"`              // 操作对象类型
+	TargetID   string    `gorm:"column:target_id;type:varchar(64);not null;default:'';index:idx_audit_target,priority:2" json:"target_id"`                  // 操作对象ID
+	Before     *string   `gorm:"column:before_data;type:json" json:"before"`                                                                                // 变更前数据
+	After      *string   `gorm:"column:after_data;type:json" json:"after"`                                                                                  // 变更后数据
+	Changes    *string   `gorm:"column:changes;type:json" json:"changes"`                                                                                   // 字段级差异 {"字段": {"before": x, "after": y}}
+	Params     *string   `gorm:"column:params;type:json" json:"params"`                                                                                     // 请求参数（敏感字段已脱敏）
+	Result     int8      `gorm:"column:result;not null;default:1" json:"result"`                                                                            // 执行结果：0=失败，1=成功
+	Error      *string   `gorm:"column:error;type:varchar(255)" json:"error"`                                                                               // 失败原因
+	IP         string    `gorm:"column:ip;type:varchar(64);not null;default:''" json:"ip"`                                                                  // 客户端IP
+	UserAgent  string    `gorm:"column:user_agent;type:varchar(255);not null;default:''" json:"user_agent"`                                                 // 客户端UA
+	RequestID  string    `gorm:"column:request_id;type:varchar(64);not null;default:'';index:idx_audit_request" json:"request_id"`                          // 请求ID
+	Method     string    `gorm:"column:method;type:varchar(10);not null" json:"method"`                                                                     // 请求方法
+	Path       string    `gorm:"column:path;type:varchar(255);not null" json:"path"`                                                                        // 请求路径
+	CreatedAt  time.Time `gorm:"column:created_at;not null;autoCreateTime;index:idx_audit_admin_time,priority:2;index:idx_audit_created" json:"created_at"` // 操作时间
+}
+
+func (AdminAuditLog) TableName() string {
+	return "admin_audit_logs"
+}
+
+// AuditLogFilter 审计日志查询条件，零值字段不参与过滤
+type AuditLogFilter struct {
+	AdminID    int64
+	Action     string
+	TargetType string
+	TargetID   string
+	RequestID  string
+	Result     *int8
+	StartTime  *time.Time
+	EndTime    *time.Time
+}
+
+func (f *AuditLogFilter) apply(db *gorm.DB) *gorm.DB {
+	if f.AdminID > 0 {
+		db = db.Where("admin_id = ?", f.AdminID)
+	}
+	if f.Action != "" {
+		db = db.Where("action = ?", f.Action)
+	}
+	if f.TargetType != "" {
+		db = db.Where("target_type = ?", f.TargetType)
+	}
+	if f.TargetID != "" {
+		db = db.Where("target_id = ?", f.TargetID)
+	}
+	if f.RequestID != "" {
+		db = db.Where("request_id = ?", f.RequestID)
+	}
+	if f.Result != nil {
+		db = db.Where("result = ?", *f.Result)
+	}
+	if f.StartTime != nil {
+		db = db.Where("created_at >= ?", *f.StartTime)
+	}
+	if f.EndTime != nil {
+		db = db.Where("created_at < ?", *f.EndTime)
+	}
+	return db
+}
+
+// CreateAdminAuditLog 写入审计日志
+func CreateAdminAuditLog(db *gorm.DB, log *AdminAuditLog) error {
+	return db.Create(log).Error
+}
+
+// GetAdminAuditLog 获取审计日志详情
+func GetAdminAuditLog(db *gorm.DB, id int64) (*AdminAuditLog, error) {
+	var log AdminAuditLog
+	if err := db.Where("id = ?", id).First(&log).Error; err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+// ListAdminAuditLogs 分页查询审计日志，按时间倒序
+func ListAdminAuditLogs(db *gorm.DB, filter *AuditLogFilter, page, limit int) ([]*AdminAuditLog, int64, error) {
+	var (
+		logs  []*AdminAuditLog
+		total int64
+	)
+	query := filter.apply(db.Model(&AdminAuditLog{}))
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id DESC").Offset((page - 1) * limit).Limit(limit).Find(&logs).Error
+	return logs, total, err
+}
+
+// ListAdminAuditLogsBefore 按ID游标倒序批量读取审计日志，prev 为上一批最后一条ID，0 表示从最新开始；用于导出
+func ListAdminAuditLogsBefore(db *gorm.DB, filter *AuditLogFilter, prev int64, limit int) ([]*AdminAuditLog, error) {
+	query := filter.apply(db.Model(&AdminAuditLog{}))
+	if prev > 0 {
+		query = query.Where("id < ?", prev)
+	}
+	var logs []*AdminAuditLog
+	err := query.Order("id DESC").Limit(limit).Find(&logs).Error
+	return logs, err
+}
@@ -0,0 +1,114 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 导出任务状态
+const (
+	ExportStatusPending = "pending" // 排队中
+	ExportStatusRunning = "running" // 导出中
+	ExportStatusSuccess = "success" // 已完成，可下载
+	ExportStatusFailed  = "failed"  // 失败
+	ExportStatusExpired = "expired" // 文件已过期清理
+)
+
+// AdminExportJob 管理端异步导出任务表结构体
+type AdminExportJob struct {
+	ID         int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`                                                  // 主键，自增
+	AdminID    int64      `gorm:"column:admin_id;not null;index:idx_export_admin" json:"admin_id"`                               // 发起导出的管理员ID，只有本人可下载
+	Type       string     `gorm:"column:type;type:varchar(32);not null" json:"type"`                                             // 导出数据类型，如 users、orders
+	Format     string     `gorm:"column:format;type:varchar(10);not null" json:"format"`                                         // 文件格式：csv/xlsx
+	Filter     string     `gorm:"column:filter;type:json;not null" json:"filter"`                                                // 筛选条件
+	Status     string     `gorm:"column:status;type:varchar(20);not null;default:pending;index:idx_export_status" json:"status"` // 任务状态
+	RowCount   int64      `gorm:"column:row_count;not null;default:0" json:"row_count"`                                          // 已导出行数
+	FileName   string     `gorm:"column:file_name;type:varchar(128);not null;default:''" json:"file_name"`                       // 下载文件名
+	FilePath   string     `gorm:"column:file_path;type:varchar(255);not null;default:''" json:"-"`                               // 服务器上的文件路径
+	FileSize   int64      `gorm:"column:file_size;not null;default:0" json:"file_size"`                                          // 文件大小（字节）
+	Error      *string    `gorm:"column:error;type:varchar(255)" json:"error"`                                                   // 失败原因
+	CreatedAt  time.Time  `gorm:"column:created_at;not null;autoCreateTime" json:"created_at"`                                   // 创建时间
+	StartedAt  *time.Time `gorm:"column:started_at" json:"started_at"`                                                           // 开始导出时间
+	FinishedAt *time.Time `gorm:"column:finished_at" json:"finished_at"`                                                         // 完成时间
+	ExpiresAt  *time.Time `gorm:"column:expires_at;index:idx_export_expires" json:"expires_at"`                                  // 文件过期时间，过期后删除文件
+}
+
+func (AdminExportJob) TableName() string {
+	return "admin_export_jobs"
+}
+
+// CreateExportJob 创建导出任务
+func CreateExportJob(db *gorm.DB, job *AdminExportJob) error {
+	return db.Create(job).Error
+}
+
+// GetExportJob 获取导出任务
+func GetExportJob(db *gorm.DB, id int64) (*AdminExportJob, error) {
+	var job AdminExportJob
+	if err := db.Where("id = ?", id).First(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListExportJobs 分页获取某个管理员的导出任务，按创建时间倒序
+func ListExportJobs(db *gorm.DB, adminID int64, page, limit int) ([]*AdminExportJob, int64, error) {
+	var (
+		jobs  []*AdminExportJob
+		total int64
+	)
+	query := db.Model(&AdminExportJob{}).Where("admin_id = ?", adminID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id DESC").Offset((page - 1) * limit).Limit(limit).Find(&jobs).Error
+	return jobs, total, err
+}
+
+// ClaimExportJob 领取一个排队中的导出任务，条件更新保证多实例下同一任务只被领取一次；没有任务时返回 nil
+func ClaimExportJob(db *gorm.DB) (*AdminExportJob, error) {
+	var job AdminExportJob
+	err := db.Where("status = ?", ExportStatusPending).Order("id ASC").First(&job).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	now := time.Now()
+	result := db.Model(&AdminExportJob{}).
+		Where("id = ? AND status = ?", job.ID, ExportStatusPending).
+		Updates(map[string]interface{}{"status": ExportStatusRunning, "started_at": now})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	job.Status = ExportStatusRunning
+	job.StartedAt = &now
+	return &job, nil
+}
+
+// UpdateExportJob 更新导出任务
+func UpdateExportJob(db *gorm.DB, id int64, updates map[string]interface{}) error {
+	return db.Model(&AdminExportJob{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// FailStaleExportJobs 将开始时间早于 before 仍在导出中的任务标记为失败（实例异常退出后遗留）
+func FailStaleExportJobs(db *gorm.DB, before time.Time, reason string) (int64, error) {
+	result := db.Model(&AdminExportJob{}).
+		Where("status = ? AND started_at < ?", ExportStatusRunning, before).
+		Updates(map[string]interface{}{"status": ExportStatusFailed, "error": reason, "finished_at": time.Now()})
+	return result.RowsAffected, result.Error
+}
+
+// ListExpiredExportJobs 获取文件已过期但尚未清理的任务
+func ListExpiredExportJobs(db *gorm.DB, now time.Time, limit int) ([]*AdminExportJob, error) {
+	var jobs []*AdminExportJob
+	err := db.Where("status = ? AND expires_at < ?", ExportStatusSuccess, now).
+		Order("id ASC").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
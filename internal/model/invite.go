@@ -0,0 +1,257 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InviteCode 用户邀请码表结构体，每个用户一个邀请码，统一存为大写
+type InviteCode struct {
+	UserID    string    `gorm:"column:user_id;type:varchar(13);primaryKey" json:"user_id"`                          // 邀请人ID
+	Code      string    `gorm:"column:code;type:varchar(16);not null;uniqueIndex:uk_invite_codes_code" json:"code"` // 邀请码
+	CreatedAt time.Time `gorm:"column:created_at;not null;autoCreateTime" json:"created_at"`                        // 创建时间
+}
+
+func (InviteCode) TableName() string {
+	return "invite_codes"
+}
+
+// NormalizeInviteCode 去掉首尾空白并转为大写，邀请码不区分大小写
+func NormalizeInviteCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// GetInviteCodeByUser 获取用户的邀请码，没有时返回 nil
+func GetInviteCodeByUser(db *gorm.DB, userID string) (*InviteCode, error) {
+	var ic InviteCode
+	err := db.Where("user_id = ?", userID).First(&ic).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ic, nil
+}
+
+// GetInviteCodeByCode 按邀请码查找，不区分大小写，没有时返回 nil
+func GetInviteCodeByCode(db *gorm.DB, code string) (*InviteCode, error) {
+	var ic InviteCode
+	err := db.Where("code = ?", NormalizeInviteCode(code)).First(&ic).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ic, nil
+}
+
+// CreateInviteCode 尝试为用户保存邀请码，用户已有邀请码或邀请码已被占用时不写入，
+// 由调用方重新读取用户的邀请码判断是否成功
+func CreateInviteCode(db *gorm.DB, userID, code string) error {
+	return db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&InviteCode{UserID: userID, Code: NormalizeInviteCode(code)}).Error
+}
+
+// 邀请记录状态
+const (
+	InviteStatusPending  int8 = 0 // 待发放，邀请活动的记录等待被邀请人达成触发条件
+	InviteStatusPaid     int8 = 1 // 已发放
+	InviteStatusRejected int8 = 2 // 发放失败或风控审核拒绝
+	InviteStatusHeld     int8 = 3 // 风控审核中，审核通过后转为待发放
+)
+
+// 邀请活动的奖励触发条件
+const (
+	InviteTriggerSignup        = "signup"         // 建立邀请关系时
+	InviteTriggerLoginDay      = "login_day"      // 被邀请人在注册后第 N 天或之后登录，注册当天为第 1 天
+	InviteTriggerFirstPurchase = "first_purchase" // 被邀请人首次支付订单
+	InviteTriggerSpend         = "spend"          // 被邀请人累计消耗代币达到 N
+)
+
+// InviteCampaign 邀请活动表结构体，建立邀请关系时按排序选取生效中的第一个活动
+type InviteCampaign struct {
+	ID            int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`                                           // 主键，自增
+	Code          string     `gorm:"column:code;type:varchar(50);not null;uniqueIndex:uk_invite_campaigns_code" json:"code"` // 活动编码
+	Name          string     `gorm:"column:name;type:varchar(100);not null" json:"name"`                                     // 名称
+	Description   *string    `gorm:"column:description;type:varchar(255)" json:"description"`                                // 说明
+	InviterReward int        `gorm:"column:inviter_reward;not null;default:0" json:"inviter_reward"`                         // 邀请人奖励的代币数
+	InviteeReward int        `gorm:"column:invitee_reward;not null;default:0" json:"invitee_reward"`                         // 被邀请人奖励的代币数
+	Level2Reward  int        `gorm:"column:level2_reward;not null;default:0" json:"level2_reward"`                           // 二级奖励，发给邀请人的邀请人，0 表示不发
+	Trigger       string     `gorm:"column:trigger_type;type:varchar(20);not null" json:"trigger"`                           // 触发条件
+	TriggerValue  int        `gorm:"column:trigger_value;not null;default:0" json:"trigger_value"`                           // login_day 为天数，spend 为代币数
+	InviterCap    int        `gorm:"column:inviter_cap;not null;default:0" json:"inviter_cap"`                               // 每个邀请人在本活动中最多获得奖励的人数，0 表示不限
+	StartAt       *time.Time `gorm:"column:start_at" json:"start_at"`                                                        // 开始时间，为空不限
+	EndAt         *time.Time `gorm:"column:end_at" json:"end_at"`                                                            // 结束时间，为空不限
+	Sort          int        `gorm:"column:sort;not null;default:0" json:"sort"`                                             // 排序，越小越优先
+	Status        int8       `gorm:"column:status;not null;default:1" json:"status"`                                         // 状态：1=启用，0=停用
+	CreatedAt     time.Time  `gorm:"column:created_at;not null;autoCreateTime" json:"created_at"`                            // 创建时间
+	UpdatedAt     time.Time  `gorm:"column:updated_at;not null;autoUpdateTime" json:"updated_at"`                            // 更新时间
+}
+
+func (InviteCampaign) TableName() string {
+	return "invite_campaigns"
+}
+
+// CreateInviteCampaign 创建邀请活动
+func CreateInviteCampaign(db *gorm.DB, c *InviteCampaign) error {
+	return db.Create(c).Error
+}
+
+// GetInviteCampaign 获取邀请活动
+func GetInviteCampaign(db *gorm.DB, id int64) (*InviteCampaign, error) {
+	var c InviteCampaign
+	if err := db.First(&c, id).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// UpdateInviteCampaign 更新邀请活动
+func UpdateInviteCampaign(db *gorm.DB, id int64, updates map[string]interface{}) error {
+	return db.Model(&InviteCampaign{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// DeleteInviteCampaign 删除邀请活动
+func DeleteInviteCampaign(db *gorm.DB, id int64) error {
+	return db.Delete(&InviteCampaign{}, id).Error
+}
+
+// ListInviteCampaigns 邀请活动列表
+func ListInviteCampaigns(db *gorm.DB) ([]*InviteCampaign, error) {
+	var list []*InviteCampaign
+	err := db.Order("sort ASC, id DESC").Find(&list).Error
+	return list, err
+}
+
+// GetActiveInviteCampaign 获取 now 时生效的邀请活动，没有时返回 nil
+func GetActiveInviteCampaign(db *gorm.DB, now time.Time) (*InviteCampaign, error) {
+	var c InviteCampaign
+	err := db.Where("status = 1").
+		Where("start_at IS NULL OR start_at <= ?", now).
+		Where("end_at IS NULL OR end_at > ?", now).
+		Order("sort ASC, id DESC").Limit(1).Find(&c).Error
+	if err != nil || c.ID == 0 {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// CountInviteRecordsByCampaign 统计关联活动的邀请记录数
+func CountInviteRecordsByCampaign(db *gorm.DB, campaignID int64) (int64, error) {
+	var count int64
+	err := db.Model(&InviteRecord{}).Where("campaign_id = ?", campaignID).Count(&count).Error
+	return count, err
+}
+
+// CountRewardedInvites 统计邀请人在活动中已获得奖励的人数，达到上限后结算的记录邀请人奖励为 0
+func CountRewardedInvites(db *gorm.DB, campaignID int64, inviterID string) (int64, error) {
+	var count int64
+	err := db.Model(&InviteRecord{}).
+		Where("campaign_id = ? AND inviter_id = ? AND status = ? AND token_reward > 0", campaignID, inviterID, InviteStatusPaid).
+		Count(&count).Error
+	return count, err
+}
+
+// GetPendingCampaignInvite 获取被邀请人等待达成条件的活动邀请记录，没有时返回 nil
+func GetPendingCampaignInvite(db *gorm.DB, inviteeID string) (*InviteRecord, error) {
+	var r InviteRecord
+	err := db.Where("invitee_id = ? AND status = ? AND campaign_id IS NOT NULL", inviteeID, InviteStatusPending).
+		Limit(1).Find(&r).Error
+	if err != nil || r.RecordID == 0 {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// HasLoginSince 用户在 since 之后是否登录过
+func HasLoginSince(db *gorm.DB, userID string, since time.Time) (bool, error) {
+	var count int64
+	err := db.Model(&UserLoginLog{}).
+		Where("user_id = ? AND login_time >= ?", userID, since).
+		Limit(1).Count(&count).Error
+	return count > 0, err
+}
+
+// SumConsumedTokens 用户累计消耗的代币数
+func SumConsumedTokens(db *gorm.DB, userID string) (int64, error) {
+	var total int64
+	err := db.Model(&TokenRecord{}).
+		Select("COALESCE(SUM(-change_amount), 0)").
+		Where("user_id = ? AND change_type = ? AND change_amount < 0", userID, "CONSUME").
+		Scan(&total).Error
+	return total, err
+}
+
+// InviteSummary 用户的邀请汇总
+type InviteSummary struct {
+	Invited       int64 `gorm:"column:invited" json:"invited"`               // 邀请人数
+	Settled       int64 `gorm:"column:settled" json:"settled"`               // 已达成条件并结算的人数
+	Pending       int64 `gorm:"column:pending" json:"pending"`               // 等待达成条件或审核中的人数
+	EarnedTokens  int64 `gorm:"column:earned_tokens" json:"earned_tokens"`   // 直接邀请获得的代币数
+	Level2Tokens  int64 `gorm:"column:level2_tokens" json:"level2_tokens"`   // 二级奖励获得的代币数
+	Level2Invited int64 `gorm:"column:level2_invited" json:"level2_invited"` // 二级邀请人数
+}
+
+// GetInviteSummary 统计用户作为邀请人和二级邀请人的邀请人数与所得奖励
+func GetInviteSummary(db *gorm.DB, userID string) (*InviteSummary, error) {
+	var s InviteSummary
+	err := db.Model(&InviteRecord{}).
+		Select(`COUNT(*) AS invited,
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0) AS settled,
+			COALESCE(SUM(CASE WHEN status IN ? THEN 1 ELSE 0 END), 0) AS pending,
+			COALESCE(SUM(CASE WHEN status = ? THEN token_reward ELSE 0 END), 0) AS earned_tokens`,
+			InviteStatusPaid, []int8{InviteStatusPending, InviteStatusHeld}, InviteStatusPaid).
+		Where("inviter_id = ?", userID).
+		Scan(&s).Error
+	if err != nil {
+		return nil, err
+	}
+	var level2 struct {
+		Invited int64
+		Tokens  int64
+	}
+	err = db.Model(&InviteRecord{}).
+		Select("COUNT(*) AS invited, COALESCE(SUM(CASE WHEN status = ? THEN level2_reward ELSE 0 END), 0) AS tokens", InviteStatusPaid).
+		Where("level2_inviter_id = ?", userID).
+		Scan(&level2).Error
+	s.Level2Invited, s.Level2Tokens = level2.Invited, level2.Tokens
+	return &s, err
+}
+
+// Invitee 邀请人看到的被邀请人
+type Invitee struct {
+	UserID      string     `gorm:"column:invitee_id" json:"user_id"`          // 被邀请人ID
+	Nickname    *string    `gorm:"column:nickname" json:"nickname"`           // 昵称
+	AvatarURL   *string    `gorm:"column:avatar_url" json:"avatar_url"`       // 头像
+	Status      int8       `gorm:"column:status" json:"status"`               // 邀请记录状态
+	TokenReward int        `gorm:"column:token_reward" json:"token_reward"`   // 邀请人获得或将获得的代币数
+	CampaignID  *int64     `gorm:"column:campaign_id" json:"campaign_id"`     // 邀请活动ID
+	Trigger     *string    `gorm:"column:trigger_type" json:"trigger"`        // 活动触发条件
+	TriggerVal  *int       `gorm:"column:trigger_value" json:"trigger_value"` // 活动触发条件的值
+	InvitedAt   time.Time  `gorm:"column:created_at" json:"invited_at"`       // 建立邀请关系的时间
+	SettledAt   *time.Time `gorm:"column:settled_at" json:"settled_at"`       // 结算时间
+}
+
+// ListInvitees 分页查询用户邀请的人，按邀请时间倒序
+func ListInvitees(db *gorm.DB, inviterID string, page, limit int) ([]*Invitee, int64, error) {
+	query := db.Table("invite_records AS r").Where("r.inviter_id = ?", inviterID)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var list []*Invitee
+	err := query.
+		Select("r.invitee_id, u.nickname, u.avatar_url, r.status, r.token_reward, r.campaign_id, c.trigger_type, c.trigger_value, r.created_at, r.settled_at").
+		Joins("LEFT JOIN users AS u ON u.id = r.invitee_id").
+		Joins("LEFT JOIN invite_campaigns AS c ON c.id = r.campaign_id").
+		Order("r.created_at DESC, r.record_id DESC").
+		Offset((page - 1) * limit).Limit(limit).
+		Scan(&list).Error
+	return list, total, err
+}
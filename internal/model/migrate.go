@@ -23,20 +23,45 @@ func Migrate(db *gorm.DB) error {
 
 	// 创建所有表
 	err = newDB.AutoMigrate(
-		&User{},                // 基础用户表
-		&AdminUser{},           // 管理员表
-		&SystemConfig{},        // 系统配置表
-		&RechargePlan{},        // 充值方案表
-		&TokenConsumeRule{},    // 代币消耗规则表
-		&RewardTask{},          // 奖励任务表
-		&Order{},               // 订单表
-		&UserAuth{},            // 用户认证表
-		&UserLoginLog{},        // 用户登录日志表
-		&RechargeOrder{},       // 充值订单表
-		&Refund{},              // 退款记录表
-		&TokenRecord{},         // 代币记录表
-		&PaymentNotifyRecord{}, // 支付通知记录表
-		&InviteRecord{},        // 邀请记录表
+		&User{},                  // 基础用户表
+		&AdminUser{},             // 管理员表
+		&AdminRecoveryCode{},     // 管理员2FA恢复码表
+		&AdminInvitation{},       // 管理员邀请表
+		&AdminRole{},             // 管理员角色表
+		&AdminRolePermission{},   // 角色权限表
+		&SystemConfig{},          // 系统配置表
+		&RechargePlan{},          // 充值方案表
+		&TokenConsumeRule{},      // 代币消耗规则表
+		&RewardTask{},            // 奖励任务表
+		&Order{},                 // 订单表
+		&UserAuth{},              // 用户认证表
+		&UserLoginLog{},          // 用户登录日志表
+		&RechargeOrder{},         // 充值订单表
+		&Refund{},                // 退款记录表
+		&TokenRecord{},           // 代币记录表
+		&PaymentNotifyRecord{},   // 支付通知记录表
+		&InviteRecord{},          // 邀请记录表
+		&AccountToken{},          // 账号一次性令牌表
+		&Notification{},          // 通知表
+		&UserSubscribeAuth{},     // 订阅消息授权表
+		&NotificationBroadcast{}, // 通知群发任务表
+		&AdminAuditLog{},         // 管理端操作审计日志表
+		&AnalyticsDailyStat{},    // 运营数据日汇总表
+		&AdminExportJob{},        // 管理端异步导出任务表
+		&UserBulkJob{},           // 用户批量操作任务表
+		&UserBulkJobItem{},       // 用户批量操作结果表
+		&UserTag{},               // 用户标签表
+		&UserTagMember{},         // 用户标签关联表
+		&RewardTaskTag{},         // 任务参与条件标签表
+		&UserDeletionRequest{},   // 账号注销申请表
+		&RiskEvent{},             // 风控事件表
+		&RiskReview{},            // 风控审核表
+		&AdRewardCallback{},      // 激励视频广告回调记录表
+		&Achievement{},           // 成就定义表
+		&UserAchievement{},       // 用户成就进度表
+		&UserAchievementMark{},   // 用户成就去重记录表
+		&InviteCode{},            // 用户邀请码表
+		&InviteCampaign{},        // 邀请活动表
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create tables: %v", err)
@@ -58,6 +83,8 @@ func Migrate(db *gorm.DB) error {
 		{"refunds", "user_id", "users", "id"},
 		{"refunds", "order_id", "recharge_orders", "order_id"},
 		{"refunds", "admin_id", "admin_users", "admin_id"},
+		{"admin_recovery_codes", "admin_id", "admin_users", "admin_id"},
+		{"admin_role_permissions", "role_id", "admin_roles", "role_id"},
 		{"token_records", "user_id", "users", "id"},
 		{"token_records", "task_id", "reward_tasks", "task_id"},
 		{"token_records", "feature_id", "token_consume_rules", "feature_id"},
@@ -66,6 +93,9 @@ func Migrate(db *gorm.DB) error {
 		{"payment_notify_records", "order_id", "recharge_orders", "order_id"},
 		{"invite_records", "inviter_id", "users", "id"},
 		{"invite_records", "invitee_id", "users", "id"},
+		{"account_tokens", "user_id", "users", "id"},
+		{"user_subscribe_auths", "user_id", "users", "id"},
+		{"invite_codes", "user_id", "users", "id"},
 	}
 
 	for _, c := range constraints {
@@ -79,6 +109,25 @@ func Migrate(db *gorm.DB) error {
 		}
 	}
 
+	// 第三步：补建未纳入自动迁移的表的索引
+	indexes := []struct {
+		table   string
+		name    string
+		columns string
+	}{
+		{"task_completion_records", "idx_task_completion_user_task_time", "user_id, task_id, completed_at"},
+		{"task_completion_records", "idx_task_completion_task_time", "task_id, completed_at"},
+	}
+	for _, idx := range indexes {
+		sql := fmt.Sprintf("CREATE INDEX %s ON %s (%s)", idx.name, idx.table, idx.columns)
+		if err := db.Exec(sql).Error; err != nil {
+			// 如果索引已存在，忽略错误
+			if !strings.Contains(err.Error(), "Duplicate key name") {
+				return fmt.Errorf("failed to create index %s on %s: %v", idx.name, idx.table, err)
+			}
+		}
+	}
+
 	// 初始化基础数据
 	if err := initBaseData(db); err != nil {
 		return fmt.Errorf("failed to initialize base data: %v", err)
@@ -90,24 +139,70 @@ func Migrate(db *gorm.DB) error {
 
 // initBaseData 初始化基础数据
 func initBaseData(db *gorm.DB) error {
-	// 检查是否已经存在管理员账号
-	var count int64
-	if err := db.Model(&AdminUser{}).Count(&count).Error; err != nil {
+	// 不再预置默认管理员账号，首个超级管理员通过 manager -bootstrap-admin 创建
+
+	// 检查并初始化角色，管理员的 role 字段引用角色编码
+	var roleCount int64
+	if err := db.Model(&AdminRole{}).Count(&roleCount).Error; err != nil {
 		return err
 	}
 
-	// 如果不存在管理员账号，创建默认管理员
-	if count == 0 {
-		admin := &AdminUser{
-			Username:     "admin",
-			PasswordHash: "$2a$10$uXh03r/wuLRWVvTQNzUthugLWcVDE5mburOLWvql0FK5lUKK2owqa", // admin123
-			Role:         "super_admin",
-			Status:       1,
+	if roleCount == 0 {
+		superDesc := "拥有全部权限"
+		adminDesc := "日常运营管理，不能管理管理员、角色和系统配置"
+		operatorDesc := "任务与通知运营，只读查看用户和订单"
+		auditorDesc := "只读访问全部业务数据"
+
+		roles := []*AdminRole{
+			{
+				Code:        RoleSuperAdmin,
+				Name:        "超级管理员",
+				Description: &superDesc,
+				IsSystem:    true,
+				Permissions: []string{PermissionAll},
+			},
+			{
+				Code:        RoleAdmin,
+				Name:        "管理员",
+				Description: &adminDesc,
+				IsSystem:    true,
+				Permissions: []string{
+					PermUsersRead, PermUsersWrite, PermUsersImpersonate, PermTagsRead, PermTagsWrite,
+					PermTokensRead, PermTokensAdjust,
+					PermOrdersRead, PermOrdersRefund, PermTasksRead, PermTasksWrite,
+					PermRulesRead, PermRulesWrite, PermConfigsRead,
+					PermNotificationsRead, PermNotificationsBroadcast, PermAnalyticsRead,
+					PermDataExport, PermRiskRead, PermRiskReview,
+				},
+			},
+			{
+				Code:        RoleOperator,
+				Name:        "运营",
+				Description: &operatorDesc,
+				Permissions: []string{
+					PermUsersRead, PermTagsRead, PermTagsWrite, PermTokensRead, PermOrdersRead,
+					PermTasksRead, PermTasksWrite, PermRulesRead,
+					PermNotificationsRead, PermNotificationsBroadcast, PermAnalyticsRead,
+				},
+			},
+			{
+				Code:        RoleAuditor,
+				Name:        "审计",
+				Description: &auditorDesc,
+				Permissions: []string{
+					PermUsersRead, PermTagsRead, PermTokensRead, PermOrdersRead, PermTasksRead,
+					PermRulesRead, PermConfigsRead, PermNotificationsRead,
+					PermAdminsRead, PermRolesRead, PermAuditRead, PermAnalyticsRead,
+					PermRiskRead,
+				},
+			},
 		}
-		if err := db.Create(admin).Error; err != nil {
-			return err
+		for _, role := range roles {
+			if err := CreateRole(db, role); err != nil {
+				return err
+			}
 		}
-		log.Println("Created default admin user")
+		log.Println("Initialized admin roles")
 	}
 
 	// 检查并初始化系统配置
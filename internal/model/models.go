@@ -10,22 +10,26 @@ import (
 
 // User 用户表结构体
 type User struct {
-	UserID       string         `gorm:"column:id;type:varchar(13);primaryKey" json:"id"`                         // 用户ID，主键，自增
-	Phone        *string        `gorm:"column:phone;type:varchar(20);uniqueIndex:uk_users_phone" json:"phone"`   // 手机号
-	Email        *string        `gorm:"column:email;type:varchar(100);uniqueIndex:uk_users_email" json:"email"`  // 邮箱
-	PasswordHash *string        `gorm:"column:password_hash;type:varchar(255)" json:"-"`                         // 密码哈希
-	Nickname     *string        `gorm:"column:nickname;type:varchar(50)" json:"nickname"`                        // 用户昵称
-	AvatarURL    *string        `gorm:"column:avatar_url;type:varchar(255)" json:"avatar"`                       // 头像URL
-	Language     string         `gorm:"column:language;type:varchar(10);not null;default:zh-CN" json:"language"` // 界面语言偏好
-	Status       int8           `gorm:"column:status;not null;default:1;index:idx_users_status" json:"status"`   // 账号状态：1=正常，0=禁用
-	TokenBalance int            `gorm:"column:token_balance;not null;default:0" json:"token_balance"`            // 代币余额
-	InviterID    *string        `gorm:"column:inviter_id;index:idx_users_inviter" json:"inviter_id"`             // 邀请人ID
-	CreatedAt    time.Time      `gorm:"column:created_at;not null;autoCreateTime" json:"-"`                      // 注册时间
-	UpdatedAt    time.Time      `gorm:"column:updated_at;not null;autoUpdateTime" json:"updated_at"`             // 记录更新时间
-	LastLoginAt  *time.Time     `gorm:"column:last_login_at" json:"last_login_at"`                               // 最后登录时间
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
-	UserAuths    []UserAuth     `gorm:"foreignKey:UserID" json:"-"`                                      // 第三方认证信息（不直接序列化）
-	Inviter      *User          `gorm:"foreignKey:InviterID;references:UserID" json:"inviter,omitempty"` // 邀请人信息
+	UserID          string         `gorm:"column:id;type:varchar(13);primaryKey" json:"id"`                         // 用户ID，主键，自增
+	Phone           *string        `gorm:"column:phone;type:varchar(20);uniqueIndex:uk_users_phone" json:"phone"`   // 手机号
+	Email           *string        `gorm:"column:email;type:varchar(100);uniqueIndex:uk_users_email" json:"email"`  // 邮箱
+	PasswordHash    *string        `gorm:"column:password_hash;type:varchar(255)" json:"-"`                         // 密码哈希
+	Nickname        *string        `gorm:"column:nickname;type:varchar(50)" json:"nickname"`                        // 用户昵称
+	AvatarURL       *string        `gorm:"column:avatar_url;type:varchar(255)" json:"avatar"`                       // 头像URL
+	Language        string         `gorm:"column:language;type:varchar(10);not null;default:zh-CN" json:"language"` // 界面语言偏好
+	Timezone        *string        `gorm:"column:timezone;type:varchar(64)" json:"timezone"`                        // 用户时区（IANA 时区名），为空使用业务时区
+	TimezoneSetAt   *time.Time     `gorm:"column:timezone_set_at" json:"-"`                                         // 最近一次修改时区的时间，用于限制修改频率
+	Status          int8           `gorm:"column:status;not null;default:1;index:idx_users_status" json:"status"`   // 账号状态：1=正常，0=禁用
+	TokenBalance    int            `gorm:"column:token_balance;not null;default:0" json:"token_balance"`            // 代币余额
+	InviterID       *string        `gorm:"column:inviter_id;index:idx_users_inviter" json:"inviter_id"`             // 邀请人ID
+	CreatedAt       time.Time      `gorm:"column:created_at;not null;autoCreateTime" json:"-"`                      // 注册时间
+	UpdatedAt       time.Time      `gorm:"column:updated_at;not null;autoUpdateTime" json:"updated_at"`             // 记录更新时间
+	LastLoginAt     *time.Time     `gorm:"column:last_login_at" json:"last_login_at"`                               // 最后登录时间
+	EmailVerifiedAt *time.Time     `gorm:"column:email_verified_at" json:"email_verified_at"`                       // 邮箱验证时间
+	PhoneVerifiedAt *time.Time     `gorm:"column:phone_verified_at" json:"phone_verified_at"`                       // 手机号验证时间，为空表示注册时填写、未经验证
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+	UserAuths       []UserAuth     `gorm:"foreignKey:UserID" json:"-"`                                      // 第三方认证信息（不直接序列化）
+	Inviter         *User          `gorm:"foreignKey:InviterID;references:UserID" json:"inviter,omitempty"` // 邀请人信息
 }
 
 // MarshalJSON 自定义 JSON 序列化方法
@@ -49,13 +53,17 @@ func (u User) MarshalJSON() ([]byte, error) {
 
 // AdminUser 管理员用户表结构体
 type AdminUser struct {
-	AdminID      int        `gorm:"column:admin_id;primaryKey;autoIncrement" json:"admin_id"`                                // 管理员ID，主键，自增
-	Username     string     `gorm:"column:username;type:varchar(50);not null;uniqueIndex:uk_admin_username" json:"username"` // 登录用户名
-	PasswordHash string     `gorm:"column:password_hash;type:varchar(255);not null" json:"-"`                                // 密码哈希
-	Role         string     `gorm:"column:role;type:varchar(20);not null;default:admin" json:"role"`                         // 角色
-	Status       int8       `gorm:"column:status;not null;default:1" json:"status"`                                          // 账号状态：1=正常，0=停用
-	CreatedAt    time.Time  `gorm:"column:created_at;not null;autoCreateTime" json:"-"`                                      // 创建时间
-	LastLoginAt  *time.Time `gorm:"column:last_login_at" json:"-"`                                                           // 最后登录时间
+	AdminID            int        `gorm:"column:admin_id;primaryKey;autoIncrement" json:"admin_id"`                                // 管理员ID，主键，自增
+	Username           string     `gorm:"column:username;type:varchar(50);not null;uniqueIndex:uk_admin_username" json:"username"` // 登录用户名
+	PasswordHash       string     `gorm:"column:password_hash;type:varchar(255);not null" json:"-"`                                // 密码哈希
+	Role               string     `gorm:"column:role;type:varchar(20);not null;default:admin" json:"role"`                         // 角色
+	Status             int8       `gorm:"column:status;not null;default:1" json:"status"`                                          // 账号状态：1=正常，0=停用
+	CreatedAt          time.Time  `gorm:"column:created_at;not null;autoCreateTime" json:"-"`                                      // 创建时间
+	LastLoginAt        *time.Time `gorm:"column:last_login_at" json:"-"`                                                           // 最后登录时间
+	TOTPSecret         *string    `gorm:"column:totp_secret;type:varchar(255)" json:"-"`                                           // 加密后的TOTP密钥
+	TwoFactorEnabled   bool       `gorm:"column:two_factor_enabled;not null;default:false" json:"two_factor_enabled"`              // 是否已启用双因素认证
+	MustChangePassword bool       `gorm:"column:must_change_password;not null;default:false" json:"must_change_password"`          // 下次登录是否必须修改密码
+	PasswordChangedAt  *time.Time `gorm:"column:password_changed_at" json:"-"`                                                     // 最近一次修改密码时间
 }
 
 // MarshalJSON 自定义 JSON 序列化方法
@@ -91,7 +99,7 @@ type UserAuth struct {
 type UserLoginLog struct {
 	LogID         int64     `gorm:"column:log_id;primaryKey;autoIncrement" json:"log_id"`                                                                          // 日志ID，主键，自增
 	UserID        string    `gorm:"column:user_id;type:varchar(13);not null;index:idx_login_log_user;constraint:OnDelete:CASCADE,OnUpdate:CASCADE" json:"user_id"` // 用户ID
-	LoginTime     time.Time `gorm:"column:login_time;not null;autoCreateTime" json:"login_time"`                                                                   // 登录时间
+	LoginTime     time.Time `gorm:"column:login_time;not null;autoCreateTime;index:idx_login_log_time" json:"login_time"`                                          // 登录时间
 	LoginMethod   string    `gorm:"column:login_method;type:varchar(20);not null" json:"login_method"`                                                             // 登录方式
 	LoginPlatform *string   `gorm:"column:login_platform;type:varchar(20)" json:"login_platform"`                                                                  // 登录平台
 	IPAddress     *string   `gorm:"column:ip_address;type:varchar(45)" json:"ip_address"`                                                                          // 登录IP地址
@@ -168,7 +176,7 @@ type TokenRecord struct {
 	OrderID      *int64            `gorm:"column:order_id;index:idx_token_records_order;constraint:OnDelete:SET NULL,OnUpdate:CASCADE" json:"order_id"`                       // 订单ID来源
 	AdminID      *int64            `gorm:"column:admin_id;index:idx_token_records_admin;constraint:OnDelete:SET NULL,OnUpdate:CASCADE" json:"admin_id"`                       // 管理员ID来源
 	Remark       *string           `gorm:"column:remark;type:varchar(255)" json:"remark"`                                                                                     // 备注说明
-	ChangeTime   time.Time         `gorm:"column:change_time;not null;autoCreateTime" json:"created_at"`                                                                      // 变动时间
+	ChangeTime   time.Time         `gorm:"column:change_time;not null;autoCreateTime;index:idx_token_records_time" json:"created_at"`                                         // 变动时间
 	User         User              `gorm:"foreignKey:UserID;references:UserID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE" json:"-"`                                         // 关联用户信息
 	Task         *RewardTask       `gorm:"foreignKey:TaskID;references:TaskID;constraint:OnDelete:SET NULL,OnUpdate:CASCADE" json:"-"`                                        // 关联任务信息
 	Feature      *TokenConsumeRule `gorm:"foreignKey:FeatureID;references:FeatureID;constraint:OnDelete:SET NULL,OnUpdate:CASCADE" json:"-"`                                  // 关联功能信息
@@ -178,17 +186,37 @@ type TokenRecord struct {
 
 // RewardTask 代币任务配置表结构体
 type RewardTask struct {
-	TaskID          int        `gorm:"column:task_id;primaryKey;autoIncrement" json:"task_id"`                            // 任务ID，主键，自增
-	TaskKey         string     `gorm:"column:task_key;type:varchar(50);not null;uniqueIndex:uk_task_key" json:"task_key"` // 任务唯一标识
-	TaskName        string     `gorm:"column:task_name;type:varchar(100);not null" json:"task_name"`                      // 任务名称
-	TaskDesc        *string    `gorm:"column:task_desc;type:varchar(255)" json:"task_desc"`                               // 任务描述
-	TokenReward     int        `gorm:"column:token_reward;not null" json:"token_reward"`                                  // 完成一次任务获得的代币数
-	DailyLimit      int        `gorm:"column:daily_limit;not null;default:0" json:"daily_limit"`                          // 每日奖励上限
-	IntervalSeconds int        `gorm:"column:interval_seconds;not null;default:0" json:"interval_seconds"`                // 两次完成任务的最小间隔秒数
-	ValidFrom       *time.Time `gorm:"column:valid_from;type:date" json:"-"`                                              // 任务生效时间
-	ValidTo         *time.Time `gorm:"column:valid_to;type:date" json:"-"`                                                // 任务截止时间
-	Repeatable      int8       `gorm:"column:repeatable;not null;default:1" json:"repeatable"`                            // 是否可重复完成：1=是，0=否
-	Status          int8       `gorm:"column:status;not null;default:1" json:"status"`                                    // 任务状态：1=启用，0=停用
+	TaskID          int              `gorm:"column:task_id;primaryKey;autoIncrement" json:"task_id"`                            // 任务ID，主键，自增
+	TaskKey         string           `gorm:"column:task_key;type:varchar(50);not null;uniqueIndex:uk_task_key" json:"task_key"` // 任务唯一标识
+	TaskName        string           `gorm:"column:task_name;type:varchar(100);not null" json:"task_name"`                      // 任务名称
+	TaskDesc        *string          `gorm:"column:task_desc;type:varchar(255)" json:"task_desc"`                               // 任务描述
+	TokenReward     int              `gorm:"column:token_reward;not null" json:"token_reward"`                                  // 完成一次任务获得的代币数
+	DailyLimit      int              `gorm:"column:daily_limit;not null;default:0" json:"daily_limit"`                          // 每日奖励上限
+	IntervalSeconds int              `gorm:"column:interval_seconds;not null;default:0" json:"interval_seconds"`                // 两次完成任务的最小间隔秒数
+	ValidFrom       *time.Time       `gorm:"column:valid_from;type:date" json:"-"`                                              // 任务生效时间
+	ValidTo         *time.Time       `gorm:"column:valid_to;type:date" json:"-"`                                                // 任务截止时间
+	Repeatable      int8             `gorm:"column:repeatable;not null;default:1" json:"repeatable"`                            // 是否可重复完成：1=是，0=否
+	Status          int8             `gorm:"column:status;not null;default:1" json:"status"`                                    // 任务状态：1=启用，0=停用
+	TagIDs          []int64          `gorm:"-" json:"tag_ids"`                                                                  // 参与条件：带其中任一标签的用户才能参与，为空表示不限
+	VerifyJSON      *string          `gorm:"column:verify_config;type:json" json:"-"`                                           // 任务完成校验配置，格式由 TaskKey 对应的校验器决定
+	VerifyConfig    json.RawMessage  `gorm:"-" json:"verify_config,omitempty"`                                                  // 解析后的任务完成校验配置
+	EligibilityJSON *string          `gorm:"column:eligibility;type:json" json:"-"`                                             // 参与规则
+	Eligibility     *TaskEligibility `gorm:"-" json:"eligibility"`                                                              // 解析后的参与规则，为空表示不限
+}
+
+// AfterFind 查询后填充任务完成校验配置和参与规则
+func (t *RewardTask) AfterFind(tx *gorm.DB) error {
+	if t.VerifyJSON != nil && *t.VerifyJSON != "" {
+		t.VerifyConfig = json.RawMessage(*t.VerifyJSON)
+	}
+	if t.EligibilityJSON != nil && *t.EligibilityJSON != "" {
+		var rule TaskEligibility
+		if err := json.Unmarshal([]byte(*t.EligibilityJSON), &rule); err != nil {
+			return err
+		}
+		t.Eligibility = &rule
+	}
+	return nil
 }
 
 func (t RewardTask) MarshalJSON() ([]byte, error) {
@@ -237,38 +265,58 @@ type PaymentNotifyRecord struct {
 
 // TaskCompletionRecord 任务完成记录
 type TaskCompletionRecord struct {
-	ID          int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
-	UserID      string    `gorm:"column:user_id;type:varchar(13);not null" json:"user_id"`
-	TaskID      int       `gorm:"column:task_id;not null" json:"task_id"`
-	TokenReward int       `gorm:"column:token_reward;not null" json:"token_reward"`
-	CompletedAt time.Time `gorm:"column:completed_at;not null;default:CURRENT_TIMESTAMP" json:"completed_at"`
-	CreatedAt   time.Time `gorm:"column:created_at;not null;default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt   time.Time `gorm:"column:updated_at;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP" json:"updated_at"`
+	ID            int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID        string     `gorm:"column:user_id;type:varchar(13);not null;uniqueIndex:uk_task_completion_checkin,priority:1;index:idx_task_completion_user_task_time,priority:1" json:"user_id"`
+	TaskID        int        `gorm:"column:task_id;not null;uniqueIndex:uk_task_completion_checkin,priority:2;index:idx_task_completion_user_task_time,priority:2;index:idx_task_completion_task_time,priority:1" json:"task_id"`
+	TokenReward   int        `gorm:"column:token_reward;not null" json:"token_reward"`
+	CheckinDate   *time.Time `gorm:"column:checkin_date;type:date;uniqueIndex:uk_task_completion_checkin,priority:3" json:"checkin_date,omitempty"` // 签到日期，仅连续签到任务使用
+	StreakDay     int        `gorm:"column:streak_day;not null;default:0" json:"streak_day,omitempty"`                                              // 截至签到日期的连续签到天数
+	Makeup        int8       `gorm:"column:makeup;not null;default:0" json:"makeup,omitempty"`                                                      // 是否补签：1=是，0=否
+	ExtraData     *string    `gorm:"column:extra_data;type:json" json:"-"`                                                                          // 上报完成时提交的数据，作为校验凭证留存
+	RevokedAt     *time.Time `gorm:"column:revoked_at" json:"revoked_at,omitempty"`                                                                 // 撤销时间，撤销后不计入任务统计
+	RevokedBy     *int64     `gorm:"column:revoked_by" json:"revoked_by,omitempty"`                                                                 // 撤销的管理员ID
+	RevokeReason  *string    `gorm:"column:revoke_reason;type:varchar(255)" json:"revoke_reason,omitempty"`                                         // 撤销原因
+	RevokedTokens int        `gorm:"column:revoked_tokens;not null;default:0" json:"revoked_tokens,omitempty"`                                      // 撤销时实际扣回的代币数
+	CompletedAt   time.Time  `gorm:"column:completed_at;not null;default:CURRENT_TIMESTAMP;index:idx_task_completion_user_task_time,priority:3;index:idx_task_completion_task_time,priority:2" json:"completed_at"`
+	CreatedAt     time.Time  `gorm:"column:created_at;not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"column:updated_at;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP" json:"updated_at"`
 }
 
 // Notification 通知
 type Notification struct {
-	ID        int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
-	UserID    string    `gorm:"column:user_id;type:varchar(13);not null" json:"user_id"`
-	Type      string    `gorm:"column:type;not null;size:32" json:"type"`
-	Title     string    `gorm:"column:title;not null;size:128" json:"title"`
-	Content   string    `gorm:"column:content;not null;type:text" json:"content"`
-	Status    int8      `gorm:"column:status;not null;default:0" json:"status"`
-	CreatedAt time.Time `gorm:"column:created_at;not null;default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt time.Time `gorm:"column:updated_at;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP" json:"updated_at"`
+	ID           int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID       string     `gorm:"column:user_id;type:varchar(13);not null" json:"user_id"`
+	Type         string     `gorm:"column:type;not null;size:32" json:"type"`
+	Title        string     `gorm:"column:title;not null;size:128" json:"title"`
+	Content      string     `gorm:"column:content;not null;type:text" json:"content"`
+	Status       int8       `gorm:"column:status;not null;default:0" json:"status"`
+	BroadcastID  *int64     `gorm:"column:broadcast_id;index:idx_notifications_broadcast" json:"broadcast_id,omitempty"` // 群发任务ID，非群发通知为空
+	TemplateID   *string    `gorm:"column:template_id;size:64" json:"template_id"`                                       // 微信订阅消息模板ID
+	Payload      *string    `gorm:"column:payload;type:json" json:"-"`                                                   // 订阅消息模板数据
+	SendStatus   int8       `gorm:"column:send_status;not null;default:0" json:"send_status"`                            // 推送状态：0=无需推送，1=待推送，2=成功，3=失败
+	SendAttempts int        `gorm:"column:send_attempts;not null;default:0" json:"send_attempts"`                        // 推送尝试次数
+	SendError    *string    `gorm:"column:send_error;size:255" json:"send_error"`                                        // 最近一次推送失败原因
+	SentAt       *time.Time `gorm:"column:sent_at" json:"sent_at"`                                                       // 推送成功时间
+	CreatedAt    time.Time  `gorm:"column:created_at;not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP" json:"updated_at"`
 }
 
 // InviteRecord 邀请记录表结构体
 type InviteRecord struct {
-	RecordID    int64     `gorm:"column:record_id;primaryKey;autoIncrement" json:"record_id"`                             // 记录ID，主键，自增
-	InviterID   string    `gorm:"column:inviter_id;size:13;not null;index:idx_invite_inviter(13)" json:"inviter_id"`      // 邀请人ID
-	InviteeID   string    `gorm:"column:invitee_id;size:13;not null;uniqueIndex:uk_invite_invitee(13)" json:"invitee_id"` // 被邀请人ID
-	TokenReward int       `gorm:"column:token_reward;not null" json:"token_reward"`                                       // 邀请奖励代币数
-	Status      int8      `gorm:"column:status;not null;default:0" json:"status"`                                         // 状态：0=待发放，1=已发放，2=发放失败
-	CreatedAt   time.Time `gorm:"column:created_at;not null;autoCreateTime" json:"created_at"`                            // 创建时间
-	UpdatedAt   time.Time `gorm:"column:updated_at;not null;autoUpdateTime" json:"updated_at"`                            // 更新时间
-	Inviter     User      `gorm:"foreignKey:InviterID;references:UserID" json:"inviter,omitempty"`                        // 邀请人信息
-	Invitee     User      `gorm:"foreignKey:InviteeID;references:UserID" json:"invitee,omitempty"`                        // 被邀请人信息
+	RecordID        int64      `gorm:"column:record_id;primaryKey;autoIncrement" json:"record_id"`                             // 记录ID，主键，自增
+	InviterID       string     `gorm:"column:inviter_id;size:13;not null;index:idx_invite_inviter(13)" json:"inviter_id"`      // 邀请人ID
+	InviteeID       string     `gorm:"column:invitee_id;size:13;not null;uniqueIndex:uk_invite_invitee(13)" json:"invitee_id"` // 被邀请人ID
+	TokenReward     int        `gorm:"column:token_reward;not null" json:"token_reward"`                                       // 邀请奖励代币数
+	Status          int8       `gorm:"column:status;not null;default:0" json:"status"`                                         // 状态：0=待发放，1=已发放，2=发放失败，3=风控审核中
+	CampaignID      *int64     `gorm:"column:campaign_id;index:idx_invite_campaign" json:"campaign_id"`                        // 邀请活动ID，为空表示建立关系时没有生效的活动
+	InviteeReward   int        `gorm:"column:invitee_reward;not null;default:0" json:"invitee_reward"`                         // 被邀请人奖励的代币数
+	Level2InviterID *string    `gorm:"column:level2_inviter_id;size:13;index:idx_invite_level2(13)" json:"level2_inviter_id"`  // 二级邀请人，即邀请人的邀请人
+	Level2Reward    int        `gorm:"column:level2_reward;not null;default:0" json:"level2_reward"`                           // 二级邀请人奖励的代币数
+	SettledAt       *time.Time `gorm:"column:settled_at" json:"settled_at"`                                                    // 结算时间
+	CreatedAt       time.Time  `gorm:"column:created_at;not null;autoCreateTime" json:"created_at"`                            // 创建时间
+	UpdatedAt       time.Time  `gorm:"column:updated_at;not null;autoUpdateTime" json:"updated_at"`                            // 更新时间
+	Inviter         User       `gorm:"foreignKey:InviterID;references:UserID" json:"inviter,omitempty"`                        // 邀请人信息
+	Invitee         User       `gorm:"foreignKey:InviteeID;references:UserID" json:"invitee,omitempty"`                        // 被邀请人信息
 }
 
 // TableName 指定表名
@@ -0,0 +1,239 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 通知类型
+const (
+	NotificationTypeTaskCompletion  = "TASK_COMPLETION"  // 任务奖励到账
+	NotificationTypePaymentSuccess  = "PAYMENT_SUCCESS"  // 支付成功
+	NotificationTypeRefundCompleted = "REFUND_COMPLETED" // 退款完成
+	NotificationTypeLowBalance      = "LOW_BALANCE"      // 余额不足提醒
+	NotificationTypeAchievement     = "ACHIEVEMENT"      // 成就达成
+	NotificationTypeSystem          = "SYSTEM"           // 系统公告（管理员群发）
+)
+
+// 群发任务状态
+const (
+	BroadcastStatusPending  int8 = 0 // 待执行
+	BroadcastStatusRunning  int8 = 1 // 执行中
+	BroadcastStatusFinished int8 = 2 // 已完成
+	BroadcastStatusFailed   int8 = 3 // 执行失败
+)
+
+// 订阅消息推送状态
+const (
+	NotificationSendNone    int8 = 0 // 无需推送
+	NotificationSendPending int8 = 1 // 待推送
+	NotificationSendSuccess int8 = 2 // 推送成功
+	NotificationSendFailed  int8 = 3 // 推送失败
+)
+
+// UserSubscribeAuth 用户订阅消息授权表结构体
+// 一次性订阅每次授权只能下发一条消息，RemainingCount 记录剩余可下发次数
+type UserSubscribeAuth struct {
+	ID             int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`                                                              // 主键，自增
+	UserID         string    `gorm:"column:user_id;type:varchar(13);not null;uniqueIndex:uk_subscribe_user_template,priority:1" json:"user_id"` // 用户ID
+	TemplateID     string    `gorm:"column:template_id;size:64;not null;uniqueIndex:uk_subscribe_user_template,priority:2" json:"template_id"`  // 订阅消息模板ID
+	RemainingCount int       `gorm:"column:remaining_count;not null;default:0" json:"remaining_count"`                                          // 剩余可下发次数
+	LastAcceptedAt time.Time `gorm:"column:last_accepted_at;not null" json:"last_accepted_at"`                                                  // 最近一次授权时间
+	CreatedAt      time.Time `gorm:"column:created_at;not null;autoCreateTime" json:"created_at"`                                               // 创建时间
+	UpdatedAt      time.Time `gorm:"column:updated_at;not null;autoUpdateTime" json:"updated_at"`                                               // 更新时间
+}
+
+func (UserSubscribeAuth) TableName() string {
+	return "user_subscribe_auths"
+}
+
+// NotificationBroadcast 通知群发任务表结构体
+type NotificationBroadcast struct {
+	ID         int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`                               // 主键，自增
+	AdminID    int64      `gorm:"column:admin_id;not null" json:"admin_id"`                                   // 发起群发的管理员ID
+	Title      string     `gorm:"column:title;size:128;not null" json:"title"`                                // 通知标题
+	Content    string     `gorm:"column:content;type:text;not null" json:"content"`                           // 通知内容
+	Filter     string     `gorm:"column:filter;type:json;not null" json:"filter"`                             // 用户筛选条件
+	Status     int8       `gorm:"column:status;not null;default:0;index:idx_broadcasts_status" json:"status"` // 状态：0=待执行，1=执行中，2=已完成，3=失败
+	LastUserID string     `gorm:"column:last_user_id;type:varchar(13);not null" json:"-"`                     // 已处理到的用户ID，用于中断后续跑
+	SentCount  int64      `gorm:"column:sent_count;not null;default:0" json:"sent_count"`                     // 已投递用户数
+	Error      *string    `gorm:"column:error;size:255" json:"error"`                                         // 失败原因
+	CreatedAt  time.Time  `gorm:"column:created_at;not null;autoCreateTime" json:"created_at"`                // 创建时间
+	FinishedAt *time.Time `gorm:"column:finished_at" json:"finished_at"`                                      // 完成时间
+}
+
+func (NotificationBroadcast) TableName() string {
+	return "notification_broadcasts"
+}
+
+// CreateNotification 创建通知
+func CreateNotification(db *gorm.DB, notification *Notification) error {
+	return db.Create(notification).Error
+}
+
+// GetNotificationByID 根据ID获取通知
+func GetNotificationByID(db *gorm.DB, id int64) (*Notification, error) {
+	var notification Notification
+	err := db.Where("id = ?", id).First(&notification).Error
+	if err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+// UpdateNotification 更新通知
+func UpdateNotification(db *gorm.DB, id int64, updates map[string]interface{}) error {
+	return db.Model(&Notification{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// AddSubscribeAuth 记录用户对模板的一次授权
+func AddSubscribeAuth(db *gorm.DB, userID, templateID string) error {
+	auth := &UserSubscribeAuth{
+		UserID:         userID,
+		TemplateID:     templateID,
+		RemainingCount: 1,
+		LastAcceptedAt: time.Now(),
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "template_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"remaining_count":  gorm.Expr("remaining_count + 1"),
+			"last_accepted_at": auth.LastAcceptedAt,
+		}),
+	}).Create(auth).Error
+}
+
+// GetSubscribeAuth 获取用户对模板的授权
+func GetSubscribeAuth(db *gorm.DB, userID, templateID string) (*UserSubscribeAuth, error) {
+	var auth UserSubscribeAuth
+	err := db.Where("user_id = ? AND template_id = ?", userID, templateID).First(&auth).Error
+	if err != nil {
+		return nil, err
+	}
+	return &auth, nil
+}
+
+// ListSubscribeAuths 获取用户全部模板授权
+func ListSubscribeAuths(db *gorm.DB, userID string) ([]*UserSubscribeAuth, error) {
+	var auths []*UserSubscribeAuth
+	err := db.Where("user_id = ?", userID).Find(&auths).Error
+	return auths, err
+}
+
+// ConsumeSubscribeAuth 扣减一次授权次数，返回是否扣减成功
+func ConsumeSubscribeAuth(db *gorm.DB, userID, templateID string) (bool, error) {
+	result := db.Model(&UserSubscribeAuth{}).
+		Where("user_id = ? AND template_id = ? AND remaining_count > 0", userID, templateID).
+		Update("remaining_count", gorm.Expr("remaining_count - 1"))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// GetUserOpenID 获取用户绑定的微信 openid
+func GetUserOpenID(db *gorm.DB, userID string) (string, error) {
+	var auth UserAuth
+	err := db.Where("user_id = ? AND provider = ?", userID, "wechat").
+		Order("created_at DESC").
+		First(&auth).Error
+	if err != nil {
+		return "", err
+	}
+	return auth.ProviderUserID, nil
+}
+
+// ListUserNotifications 按游标获取用户通知，prev 为上一页最后一条通知ID，0 表示第一页
+func ListUserNotifications(db *gorm.DB, userID string, prev int64, limit int, unreadOnly bool) ([]*Notification, error) {
+	query := db.Where("user_id = ?", userID)
+	if prev > 0 {
+		query = query.Where("id < ?", prev)
+	}
+	if unreadOnly {
+		query = query.Where("status = ?", 0)
+	}
+	var notifications []*Notification
+	err := query.Order("id DESC").Limit(limit).Find(&notifications).Error
+	return notifications, err
+}
+
+// CountUnreadNotifications 获取用户未读通知数
+func CountUnreadNotifications(db *gorm.DB, userID string) (int64, error) {
+	var count int64
+	err := db.Model(&Notification{}).Where("user_id = ? AND status = ?", userID, 0).Count(&count).Error
+	return count, err
+}
+
+// MarkNotificationRead 将用户的一条通知标记为已读
+func MarkNotificationRead(db *gorm.DB, userID string, id int64) (int64, error) {
+	result := db.Model(&Notification{}).
+		Where("id = ? AND user_id = ?", id, userID).
+		Update("status", 1)
+	return result.RowsAffected, result.Error
+}
+
+// MarkAllNotificationsRead 将用户全部未读通知标记为已读
+func MarkAllNotificationsRead(db *gorm.DB, userID string) (int64, error) {
+	result := db.Model(&Notification{}).
+		Where("user_id = ? AND status = ?", userID, 0).
+		Update("status", 1)
+	return result.RowsAffected, result.Error
+}
+
+// DeleteUserNotification 删除用户的一条通知
+func DeleteUserNotification(db *gorm.DB, userID string, id int64) (int64, error) {
+	result := db.Where("id = ? AND user_id = ?", id, userID).Delete(&Notification{})
+	return result.RowsAffected, result.Error
+}
+
+// CreateNotificationBroadcast 创建群发任务
+func CreateNotificationBroadcast(db *gorm.DB, broadcast *NotificationBroadcast) error {
+	return db.Create(broadcast).Error
+}
+
+// GetNotificationBroadcast 获取群发任务
+func GetNotificationBroadcast(db *gorm.DB, id int64) (*NotificationBroadcast, error) {
+	var broadcast NotificationBroadcast
+	err := db.Where("id = ?", id).First(&broadcast).Error
+	if err != nil {
+		return nil, err
+	}
+	return &broadcast, nil
+}
+
+// ListNotificationBroadcasts 分页获取群发任务
+func ListNotificationBroadcasts(db *gorm.DB, page, limit int) ([]*NotificationBroadcast, int64, error) {
+	var broadcasts []*NotificationBroadcast
+	var total int64
+	query := db.Model(&NotificationBroadcast{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id DESC").Offset((page - 1) * limit).Limit(limit).Find(&broadcasts).Error
+	return broadcasts, total, err
+}
+
+// UpdateNotificationBroadcast 更新群发任务
+func UpdateNotificationBroadcast(db *gorm.DB, id int64, updates map[string]interface{}) error {
+	return db.Model(&NotificationBroadcast{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// ListUnfinishedBroadcasts 获取未完成的群发任务，用于服务重启后续跑
+func ListUnfinishedBroadcasts(db *gorm.DB) ([]*NotificationBroadcast, error) {
+	var broadcasts []*NotificationBroadcast
+	err := db.Where("status IN ?", []int8{BroadcastStatusPending, BroadcastStatusRunning}).
+		Order("id ASC").Find(&broadcasts).Error
+	return broadcasts, err
+}
+
+// GetUserNotification 获取用户的一条通知
+func GetUserNotification(db *gorm.DB, userID string, id int64) (*Notification, error) {
+	var notification Notification
+	err := db.Where("id = ? AND user_id = ?", id, userID).First(&notification).Error
+	if err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
@@ -0,0 +1,113 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 账号注销申请状态
+const (
+	DeletionStatusPending   = "pending"   // 冷静期内，等待执行
+	DeletionStatusCancelled = "cancelled" // 用户已撤销
+	DeletionStatusCompleted = "completed" // 已完成个人信息匿名化
+)
+
+// UserDeletionRequest 账号注销申请表结构体
+type UserDeletionRequest struct {
+	ID          int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`                                                              // 主键，自增
+	UserID      string     `gorm:"column:user_id;type:varchar(13);not null;index:idx_user_deletion_user" json:"user_id"`                      // 用户ID
+	Status      string     `gorm:"column:status;type:varchar(10);not null;index:idx_user_deletion_status_scheduled,priority:1" json:"status"` // 申请状态：pending/cancelled/completed
+	Reason      *string    `gorm:"column:reason;type:varchar(255)" json:"reason"`                                                             // 注销原因
+	ScheduledAt time.Time  `gorm:"column:scheduled_at;not null;index:idx_user_deletion_status_scheduled,priority:2" json:"scheduled_at"`      // 冷静期结束、计划执行匿名化的时间
+	CancelledAt *time.Time `gorm:"column:cancelled_at" json:"cancelled_at"`                                                                   // 撤销时间
+	CompletedAt *time.Time `gorm:"column:completed_at" json:"completed_at"`                                                                   // 完成匿名化时间
+	CreatedAt   time.Time  `gorm:"column:created_at;not null;autoCreateTime" json:"created_at"`                                               // 申请时间
+	UpdatedAt   time.Time  `gorm:"column:updated_at;not null;autoUpdateTime" json:"updated_at"`                                               // 更新时间
+}
+
+func (UserDeletionRequest) TableName() string {
+	return "user_deletion_requests"
+}
+
+// CreateUserDeletionRequest 创建账号注销申请
+func CreateUserDeletionRequest(db *gorm.DB, req *UserDeletionRequest) error {
+	return db.Create(req).Error
+}
+
+// GetPendingUserDeletionRequest 获取用户冷静期内的注销申请
+func GetPendingUserDeletionRequest(db *gorm.DB, userID string) (*UserDeletionRequest, error) {
+	var req UserDeletionRequest
+	err := db.Where("user_id = ? AND status = ?", userID, DeletionStatusPending).Order("id DESC").First(&req).Error
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// GetLatestUserDeletionRequest 获取用户最近一次注销申请
+func GetLatestUserDeletionRequest(db *gorm.DB, userID string) (*UserDeletionRequest, error) {
+	var req UserDeletionRequest
+	if err := db.Where("user_id = ?", userID).Order("id DESC").First(&req).Error; err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// CancelUserDeletionRequest 撤销冷静期内的注销申请，返回是否撤销成功
+func CancelUserDeletionRequest(db *gorm.DB, id int64) (bool, error) {
+	now := time.Now()
+	result := db.Model(&UserDeletionRequest{}).
+		Where("id = ? AND status = ?", id, DeletionStatusPending).
+		Updates(map[string]interface{}{"status": DeletionStatusCancelled, "cancelled_at": now})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListDueUserDeletionRequests 获取冷静期已结束、待执行的注销申请
+func ListDueUserDeletionRequests(db *gorm.DB, now time.Time, limit int) ([]*UserDeletionRequest, error) {
+	var reqs []*UserDeletionRequest
+	err := db.Where("status = ? AND scheduled_at <= ?", DeletionStatusPending, now).
+		Order("scheduled_at ASC").Limit(limit).Find(&reqs).Error
+	return reqs, err
+}
+
+// CompleteUserDeletionRequest 标记注销申请已完成，仅冷静期内的申请可以完成，返回是否更新成功
+func CompleteUserDeletionRequest(db *gorm.DB, id int64) (bool, error) {
+	now := time.Now()
+	result := db.Model(&UserDeletionRequest{}).
+		Where("id = ? AND status = ?", id, DeletionStatusPending).
+		Updates(map[string]interface{}{"status": DeletionStatusCompleted, "completed_at": now})
+	return result.RowsAffected > 0, result.Error
+}
+
+// AnonymizeUser 清除用户个人信息：资料与第三方绑定、登录与风控事件中的IP和设备、一次性令牌和标签；代币流水、订单等财务记录保留
+func AnonymizeUser(db *gorm.DB, userID string) error {
+	if err := db.Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"phone":             nil,
+		"email":             nil,
+		"nickname":          nil,
+		"avatar_url":        nil,
+		"password_hash":     nil,
+		"email_verified_at": nil,
+		"phone_verified_at": nil,
+		"status":            0,
+	}).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&UserLoginLog{}).Where("user_id = ?", userID).
+		Updates(map[string]interface{}{"ip_address": nil, "device_info": nil}).Error; err != nil {
+		return err
+	}
+	// 风控事件保留用于审计，仅清空IP与设备指纹
+	if err := db.Model(&RiskEvent{}).Where("user_id = ?", userID).
+		Updates(map[string]interface{}{"ip": "", "device_id": ""}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("user_id = ?", userID).Delete(&UserAuth{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("user_id = ?", userID).Delete(&AccountToken{}).Error; err != nil {
+		return err
+	}
+	return db.Where("user_id = ?", userID).Delete(&UserTagMember{}).Error
+}
@@ -0,0 +1,242 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 权限标识，格式为 资源:操作
+const (
+	PermissionAll = "*" // 全部权限
+
+	PermUsersRead              = "users:read"              // 查看用户、登录日志
+	PermUsersWrite             = "users:write"             // 修改用户状态
+	PermUsersImpersonate       = "users:impersonate"       // 以用户身份只读查看用户端数据
+	PermTagsRead               = "tags:read"               // 查看用户标签
+	PermTagsWrite              = "tags:write"              // 创建、编辑用户标签，维护手动标签的用户
+	PermTokensRead             = "tokens:read"             // 查看代币记录
+	PermTokensAdjust           = "tokens:adjust"           // 调整用户代币
+	PermOrdersRead             = "orders:read"             // 查看订单
+	PermOrdersRefund           = "orders:refund"           // 订单退款
+	PermTasksRead              = "tasks:read"              // 查看奖励任务
+	PermTasksWrite             = "tasks:write"             // 创建、编辑奖励任务
+	PermRulesRead              = "rules:read"              // 查看代币消耗规则
+	PermRulesWrite             = "rules:write"             // 创建、编辑代币消耗规则
+	PermConfigsRead            = "configs:read"            // 查看系统配置
+	PermConfigsWrite           = "configs:write"           // 修改系统配置
+	PermNotificationsRead      = "notifications:read"      // 查看群发任务
+	PermNotificationsBroadcast = "notifications:broadcast" // 群发通知
+	PermAdminsRead             = "admins:read"             // 查看管理员
+	PermAdminsWrite            = "admins:write"            // 创建、编辑、删除管理员
+	PermRolesRead              = "roles:read"              // 查看角色
+	PermRolesWrite             = "roles:write"             // 创建、编辑、删除角色
+	PermAuditRead              = "audit:read"              // 查看、导出审计日志
+	PermAnalyticsRead          = "analytics:read"          // 查看运营数据
+	PermDataExport             = "data:export"             // 导出用户、订单、代币记录、登录日志（还需对应数据的查看权限）
+	PermRiskRead               = "risk:read"               // 查看风控事件和审核队列
+	PermRiskReview             = "risk:review"             // 审核被风控暂扣的奖励
+)
+
+// 内置角色编码
+const (
+	RoleSuperAdmin = "super_admin"
+	RoleAdmin      = "admin"
+	RoleOperator   = "operator"
+	RoleAuditor    = "auditor"
+)
+
+// PermissionInfo 权限说明
+type PermissionInfo struct {
+	Code  string `json:"code"`  // 权限标识
+	Name  string `json:"name"`  // 权限名称
+	Group string `json:"group"` // 所属模块
+}
+
+// Permissions 系统支持的全部权限，角色只能从中选择
+var Permissions = []PermissionInfo{
+	{PermUsersRead, "查看用户", "用户管理"},
+	{PermUsersWrite, "修改用户状态", "用户管理"},
+	{PermUsersImpersonate, "模拟用户查看", "用户管理"},
+	{PermTagsRead, "查看用户标签", "用户管理"},
+	{PermTagsWrite, "管理用户标签", "用户管理"},
+	{PermTokensRead, "查看代币记录", "代币管理"},
+	{PermTokensAdjust, "调整用户代币", "代币管理"},
+	{PermOrdersRead, "查看订单", "订单管理"},
+	{PermOrdersRefund, "订单退款", "订单管理"},
+	{PermTasksRead, "查看奖励任务", "任务管理"},
+	{PermTasksWrite, "编辑奖励任务", "任务管理"},
+	{PermRulesRead, "查看消耗规则", "任务管理"},
+	{PermRulesWrite, "编辑消耗规则", "任务管理"},
+	{PermConfigsRead, "查看系统配置", "系统配置"},
+	{PermConfigsWrite, "修改系统配置", "系统配置"},
+	{PermNotificationsRead, "查看群发任务", "通知管理"},
+	{PermNotificationsBroadcast, "群发通知", "通知管理"},
+	{PermAdminsRead, "查看管理员", "权限管理"},
+	{PermAdminsWrite, "管理管理员账号", "权限管理"},
+	{PermRolesRead, "查看角色", "权限管理"},
+	{PermRolesWrite, "管理角色", "权限管理"},
+	{PermAuditRead, "查看审计日志", "权限管理"},
+	{PermAnalyticsRead, "查看运营数据", "数据统计"},
+	{PermDataExport, "导出数据", "数据统计"},
+	{PermRiskRead, "查看风控事件", "风控管理"},
+	{PermRiskReview, "审核风控奖励", "风控管理"},
+}
+
+// IsValidPermission 检查权限标识是否存在
+func IsValidPermission(code string) bool {
+	if code == PermissionAll {
+		return true
+	}
+	for _, p := range Permissions {
+		if p.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminRole 管理员角色
+type AdminRole struct {
+	RoleID      int64     `gorm:"column:role_id;primaryKey;autoIncrement" json:"role_id"`                           // 角色ID，主键，自增
+	Code        string    `gorm:"column:code;type:varchar(20);not null;uniqueIndex:uk_admin_role_code" json:"code"` // 角色编码，对应 admin_users.role
+	Name        string    `gorm:"column:name;type:varchar(50);not null" json:"name"`                                // 角色名称
+	Description *string   `gorm:"column:description;type:varchar(255)" json:"description"`                          // 角色说明
+	IsSystem    bool      `gorm:"column:is_system;not null;default:false" json:"is_system"`                         // 是否内置角色，内置角色不可删除
+	Permissions []string  `gorm:"-" json:"permissions"`                                                             // 权限列表
+	CreatedAt   time.Time `gorm:"column:created_at;not null;autoCreateTime" json:"created_at"`                      // 创建时间
+	UpdatedAt   time.Time `gorm:"column:updated_at;not null;autoUpdateTime" json:"updated_at"`                      // 更新时间
+}
+
+func (AdminRole) TableName() string {
+	return "admin_roles"
+}
+
+// AdminRolePermission 角色权限关联
+type AdminRolePermission struct {
+	RoleID     int64  `gorm:"column:role_id;primaryKey" json:"role_id"`                        // 角色ID
+	Permission string `gorm:"column:permission;type:varchar(50);primaryKey" json:"permission"` // 权限标识
+}
+
+func (AdminRolePermission) TableName() string {
+	return "admin_role_permissions"
+}
+
+// GetRoleByCode 根据编码获取角色（含权限）
+func GetRoleByCode(db *gorm.DB, code string) (*AdminRole, error) {
+	var role AdminRole
+	if err := db.Where("code = ?", code).First(&role).Error; err != nil {
+		return nil, err
+	}
+	if err := loadRolePermissions(db, &role); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// GetRoleByID 根据ID获取角色（含权限）
+func GetRoleByID(db *gorm.DB, id int64) (*AdminRole, error) {
+	var role AdminRole
+	if err := db.First(&role, id).Error; err != nil {
+		return nil, err
+	}
+	if err := loadRolePermissions(db, &role); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// ListRoles 获取全部角色（含权限）
+func ListRoles(db *gorm.DB) ([]*AdminRole, error) {
+	var roles []*AdminRole
+	if err := db.Order("role_id ASC").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	if len(roles) == 0 {
+		return roles, nil
+	}
+
+	ids := make([]int64, 0, len(roles))
+	for _, r := range roles {
+		ids = append(ids, r.RoleID)
+	}
+	var perms []AdminRolePermission
+	if err := db.Where("role_id IN ?", ids).Order("permission ASC").Find(&perms).Error; err != nil {
+		return nil, err
+	}
+	byRole := make(map[int64][]string, len(roles))
+	for _, p := range perms {
+		byRole[p.RoleID] = append(byRole[p.RoleID], p.Permission)
+	}
+	for _, r := range roles {
+		r.Permissions = byRole[r.RoleID]
+		if r.Permissions == nil {
+			r.Permissions = []string{}
+		}
+	}
+	return roles, nil
+}
+
+// CreateRole 创建角色及其权限
+func CreateRole(db *gorm.DB, role *AdminRole) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(role).Error; err != nil {
+			return err
+		}
+		return replaceRolePermissions(tx, role.RoleID, role.Permissions)
+	})
+}
+
+// UpdateRole 更新角色信息，permissions 非 nil 时整体替换权限
+func UpdateRole(db *gorm.DB, id int64, updates map[string]interface{}, permissions []string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&AdminRole{}).Where("role_id = ?", id).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if permissions == nil {
+			return nil
+		}
+		return replaceRolePermissions(tx, id, permissions)
+	})
+}
+
+// DeleteRole 删除角色及其权限
+func DeleteRole(db *gorm.DB, id int64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", id).Delete(&AdminRolePermission{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&AdminRole{}, id).Error
+	})
+}
+
+// CountAdminsByRole 统计使用某角色的管理员数量
+func CountAdminsByRole(db *gorm.DB, code string) (int64, error) {
+	var count int64
+	err := db.Model(&AdminUser{}).Where("role = ?", code).Count(&count).Error
+	return count, err
+}
+
+func loadRolePermissions(db *gorm.DB, role *AdminRole) error {
+	role.Permissions = []string{}
+	return db.Model(&AdminRolePermission{}).
+		Where("role_id = ?", role.RoleID).
+		Order("permission ASC").
+		Pluck("permission", &role.Permissions).Error
+}
+
+func replaceRolePermissions(tx *gorm.DB, roleID int64, permissions []string) error {
+	if err := tx.Where("role_id = ?", roleID).Delete(&AdminRolePermission{}).Error; err != nil {
+		return err
+	}
+	if len(permissions) == 0 {
+		return nil
+	}
+	rows := make([]AdminRolePermission, 0, len(permissions))
+	for _, p := range permissions {
+		rows = append(rows, AdminRolePermission{RoleID: roleID, Permission: p})
+	}
+	return tx.Create(&rows).Error
+}
@@ -0,0 +1,228 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 风控事件类型
+const (
+	RiskEventSignup = "signup" // 注册赠送
+	RiskEventReward = "reward" // 奖励上报
+	RiskEventInvite = "invite" // 邀请奖励
+)
+
+// 风控处置结果
+const (
+	RiskDecisionAllow  = "allow"  // 放行
+	RiskDecisionReduce = "reduce" // 按比例降低奖励
+	RiskDecisionReview = "review" // 暂扣奖励，等待人工审核
+	RiskDecisionBlock  = "block"  // 拒绝
+)
+
+// 风控审核状态
+const (
+	RiskReviewPending  = "pending"  // 待审核
+	RiskReviewApproved = "approved" // 审核通过，已补发暂扣的奖励
+	RiskReviewRejected = "rejected" // 审核拒绝，奖励不再发放
+)
+
+// RiskEvent 风控事件表结构体，每次评估记录一条，同时作为频率统计的数据来源
+type RiskEvent struct {
+	ID            int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`                                                                                              // 主键，自增
+	EventType     string    `gorm:"column:event_type;type:varchar(10);not null;index:idx_risk_events_ip,priority:1;index:idx_risk_events_device,priority:1" json:"event_type"` // 事件类型：signup/reward/invite
+	Action        string    `gorm:"column:action;type:varchar(32);not null;default:''" json:"action"`                                                                          // 具体行为，如奖励类型
+	UserID        string    `gorm:"column:user_id;type:varchar(13);not null;index:idx_risk_events_user,priority:1" json:"user_id"`                                             // 发起行为的用户ID
+	RelatedUserID *string   `gorm:"column:related_user_id;type:varchar(13);index:idx_risk_events_related" json:"related_user_id"`                                              // 关联用户ID，邀请事件为邀请人
+	IP            string    `gorm:"column:ip;type:varchar(45);not null;default:'';index:idx_risk_events_ip,priority:2" json:"ip"`                                              // 客户端IP
+	DeviceID      string    `gorm:"column:device_id;type:varchar(64);not null;default:'';index:idx_risk_events_device,priority:2" json:"device_id"`                            // 设备指纹
+	Score         int       `gorm:"column:score;not null;default:0" json:"score"`                                                                                              // 风险分 0-100
+	Decision      string    `gorm:"column:decision;type:varchar(10);not null;index:idx_risk_events_decision" json:"decision"`                                                  // 处置结果
+	ReasonsJSON   *string   `gorm:"column:reasons;type:json" json:"-"`                                                                                                         // 命中的规则
+	Reasons       []string  `gorm:"-" json:"reasons"`                                                                                                                          // 解析后的命中规则
+	Amount        int       `gorm:"column:amount;not null;default:0" json:"amount"`                                                                                            // 原始奖励代币数
+	GrantedAmount int       `gorm:"column:granted_amount;not null;default:0" json:"granted_amount"`                                                                            // 实际发放代币数
+	CreatedAt     time.Time `gorm:"column:created_at;not null;autoCreateTime;index:idx_risk_events_user,priority:2" json:"created_at"`                                         // 发生时间
+}
+
+func (RiskEvent) TableName() string {
+	return "risk_events"
+}
+
+// AfterFind 查询后解析命中规则
+func (e *RiskEvent) AfterFind(tx *gorm.DB) error {
+	if e.ReasonsJSON == nil || *e.ReasonsJSON == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(*e.ReasonsJSON), &e.Reasons)
+}
+
+// RiskReview 风控审核表结构体，记录被暂扣的奖励，审核通过后补发给受益人
+type RiskReview struct {
+	ID            int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`                                        // 主键，自增
+	EventID       int64      `gorm:"column:event_id;not null;uniqueIndex:uk_risk_reviews_event" json:"event_id"`          // 风控事件ID
+	EventType     string     `gorm:"column:event_type;type:varchar(10);not null" json:"event_type"`                       // 事件类型
+	UserID        string     `gorm:"column:user_id;type:varchar(13);not null;index:idx_risk_reviews_user" json:"user_id"` // 发起行为的用户ID
+	BeneficiaryID string     `gorm:"column:beneficiary_id;type:varchar(13);not null" json:"beneficiary_id"`               // 奖励受益人，邀请事件为邀请人
+	Amount        int        `gorm:"column:amount;not null" json:"amount"`                                                // 暂扣的代币数
+	ChangeType    string     `gorm:"column:change_type;type:varchar(20);not null" json:"change_type"`                     // 补发时代币记录的变动类型
+	Remark        string     `gorm:"column:remark;type:varchar(255);not null;default:''" json:"remark"`                   // 补发时代币记录的备注
+	Status        string     `gorm:"column:status;type:varchar(10);not null;index:idx_risk_reviews_status" json:"status"` // 审核状态：pending/approved/rejected
+	ReviewerID    *int64     `gorm:"column:reviewer_id" json:"reviewer_id"`                                               // 审核管理员ID
+	ReviewNote    *string    `gorm:"column:review_note;type:varchar(255)" json:"review_note"`                             // 审核备注
+	RecordID      *int64     `gorm:"column:record_id" json:"record_id"`                                                   // 补发的代币记录ID
+	ReviewedAt    *time.Time `gorm:"column:reviewed_at" json:"reviewed_at"`                                               // 审核时间
+	CreatedAt     time.Time  `gorm:"column:created_at;not null;autoCreateTime" json:"created_at"`                         // 创建时间
+	Event         *RiskEvent `gorm:"foreignKey:EventID;references:ID" json:"event,omitempty"`                             // 风控事件
+}
+
+func (RiskReview) TableName() string {
+	return "risk_reviews"
+}
+
+// RiskEventFilter 风控事件查询条件，零值字段不参与过滤
+type RiskEventFilter struct {
+	EventType string
+	UserID    string
+	IP        string
+	DeviceID  string
+	Decision  string
+}
+
+// CreateRiskEvent 创建风控事件
+func CreateRiskEvent(db *gorm.DB, event *RiskEvent) error {
+	if len(event.Reasons) > 0 {
+		data, err := json.Marshal(event.Reasons)
+		if err != nil {
+			return err
+		}
+		reasons := string(data)
+		event.ReasonsJSON = &reasons
+	}
+	return db.Create(event).Error
+}
+
+// ListRiskEvents 分页查询风控事件
+func ListRiskEvents(db *gorm.DB, filter *RiskEventFilter, page, limit int) ([]*RiskEvent, int64, error) {
+	var (
+		events []*RiskEvent
+		total  int64
+	)
+	query := db.Model(&RiskEvent{})
+	if filter.EventType != "" {
+		query = query.Where("event_type = ?", filter.EventType)
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id = ? OR related_user_id = ?", filter.UserID, filter.UserID)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if filter.DeviceID != "" {
+		query = query.Where("device_id = ?", filter.DeviceID)
+	}
+	if filter.Decision != "" {
+		query = query.Where("decision = ?", filter.Decision)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id DESC").Offset((page - 1) * limit).Limit(limit).Find(&events).Error
+	return events, total, err
+}
+
+// CountRiskEvents 统计时间窗口内的风控事件数，column 为 user_id/related_user_id/ip/device_id
+func CountRiskEvents(db *gorm.DB, eventType, column, value string, since time.Time) (int64, error) {
+	var count int64
+	err := db.Model(&RiskEvent{}).
+		Where("event_type = ? AND "+column+" = ? AND created_at >= ?", eventType, value, since).
+		Count(&count).Error
+	return count, err
+}
+
+// CountRiskEventUsers 统计时间窗口内同一 IP 或设备上发起事件的不同用户数，column 为 ip/device_id
+func CountRiskEventUsers(db *gorm.DB, eventType, column, value string, since time.Time) (int64, error) {
+	var count int64
+	err := db.Model(&RiskEvent{}).
+		Where("event_type = ? AND "+column+" = ? AND created_at >= ?", eventType, value, since).
+		Distinct("user_id").Count(&count).Error
+	return count, err
+}
+
+// UserUsedDevice 用户是否在该设备上发起过事件
+func UserUsedDevice(db *gorm.DB, userID, deviceID string) (bool, error) {
+	var count int64
+	err := db.Model(&RiskEvent{}).Where("user_id = ? AND device_id = ?", userID, deviceID).Limit(1).Count(&count).Error
+	return count > 0, err
+}
+
+// UserUsedIP 用户在时间窗口内是否使用过该 IP 登录或发起事件
+func UserUsedIP(db *gorm.DB, userID, ip string, since time.Time) (bool, error) {
+	var count int64
+	if err := db.Model(&UserLoginLog{}).
+		Where("user_id = ? AND ip_address = ? AND login_time >= ?", userID, ip, since).
+		Limit(1).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	err := db.Model(&RiskEvent{}).
+		Where("user_id = ? AND ip = ? AND created_at >= ?", userID, ip, since).
+		Limit(1).Count(&count).Error
+	return count > 0, err
+}
+
+// CountRejectedRiskReviews 统计用户作为发起人或受益人被审核拒绝的次数
+func CountRejectedRiskReviews(db *gorm.DB, userID string) (int64, error) {
+	var count int64
+	err := db.Model(&RiskReview{}).
+		Where("status = ? AND (user_id = ? OR beneficiary_id = ?)", RiskReviewRejected, userID, userID).
+		Count(&count).Error
+	return count, err
+}
+
+// CreateRiskReview 创建风控审核
+func CreateRiskReview(db *gorm.DB, review *RiskReview) error {
+	return db.Create(review).Error
+}
+
+// GetRiskReview 获取风控审核及其事件
+func GetRiskReview(db *gorm.DB, id int64) (*RiskReview, error) {
+	var review RiskReview
+	if err := db.Preload("Event").Where("id = ?", id).First(&review).Error; err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// ListRiskReviews 分页查询风控审核，status、eventType 为空时不过滤
+func ListRiskReviews(db *gorm.DB, status, eventType, userID string, page, limit int) ([]*RiskReview, int64, error) {
+	var (
+		reviews []*RiskReview
+		total   int64
+	)
+	query := db.Model(&RiskReview{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if eventType != "" {
+		query = query.Where("event_type = ?", eventType)
+	}
+	if userID != "" {
+		query = query.Where("user_id = ? OR beneficiary_id = ?", userID, userID)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Preload("Event").Order("id DESC").Offset((page - 1) * limit).Limit(limit).Find(&reviews).Error
+	return reviews, total, err
+}
+
+// FinishRiskReview 完成待审核的风控审核，返回是否更新成功（已被其他管理员处理时返回 false）
+func FinishRiskReview(db *gorm.DB, id int64, updates map[string]interface{}) (bool, error) {
+	result := db.Model(&RiskReview{}).Where("id = ? AND status = ?", id, RiskReviewPending).Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,211 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TaskCompletionStat 用户某个任务的完成情况汇总
+type TaskCompletionStat struct {
+	TaskID          int        `gorm:"column:task_id" json:"task_id"`                     // 任务ID
+	TotalCount      int64      `gorm:"column:total_count" json:"total_count"`             // 总完成次数
+	TodayCount      int64      `gorm:"column:today_count" json:"today_count"`             // 当天完成次数
+	LastCompletedAt *time.Time `gorm:"column:last_completed_at" json:"last_completed_at"` // 最近一次完成时间
+}
+
+// GetTaskCompletionStats 一次查询汇总用户各任务的总次数、[dayStart, dayEnd) 内的次数和最近完成时间，
+// 走 (user_id, task_id, completed_at) 覆盖索引；taskIDs 为空时汇总全部任务。没有完成记录的任务不在结果中
+func GetTaskCompletionStats(db *gorm.DB, userID string, taskIDs []int, dayStart, dayEnd time.Time) (map[int]*TaskCompletionStat, error) {
+	var rows []*TaskCompletionStat
+	query := db.Model(&TaskCompletionRecord{}).
+		Select("task_id, COUNT(*) AS total_count, "+
+			"COALESCE(SUM(CASE WHEN completed_at >= ? AND completed_at < ? THEN 1 ELSE 0 END), 0) AS today_count, "+
+			"MAX(completed_at) AS last_completed_at", dayStart, dayEnd).
+		Where("user_id = ?", userID)
+	if len(taskIDs) > 0 {
+		query = query.Where("task_id IN ?", taskIDs)
+	}
+	if err := query.Group("task_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	stats := make(map[int]*TaskCompletionStat, len(rows))
+	for _, r := range rows {
+		stats[r.TaskID] = r
+	}
+	return stats, nil
+}
+
+// TaskEligibility 任务参与规则，用户需同时满足全部已设置的条件，未设置的条件不限
+type TaskEligibility struct {
+	NewUserDays int           `json:"new_user_days,omitempty"` // 仅注册不满 N 天的新用户，0 表示不限
+	Platforms   []string      `json:"platforms,omitempty"`     // 最近一次登录的平台，取自 user_login_log.login_platform
+	Providers   []string      `json:"providers,omitempty"`     // 最近一次登录的方式，取自 user_login_log.login_method
+	Languages   []string      `json:"languages,omitempty"`     // 用户界面语言，如 zh-CN、en
+	MinBalance  *int          `json:"min_balance,omitempty"`   // 代币余额下限（含）
+	MaxBalance  *int          `json:"max_balance,omitempty"`   // 代币余额上限（含）
+	Purchased   *bool         `json:"purchased,omitempty"`     // true=有支付成功的订单，false=从未购买
+	ABBucket    *TaskABBucket `json:"ab_bucket,omitempty"`     // A/B 分桶
+}
+
+// TaskABBucket A/B 分桶条件，用户按 hash(salt:user_id) % 100 分到 0-99 号桶，桶号在 [From, To] 内的用户可参与
+type TaskABBucket struct {
+	Salt string `json:"salt,omitempty"` // 分桶盐，相同盐的任务分桶结果一致，为空时使用任务标识
+	From int    `json:"from"`           // 起始桶号（含）
+	To   int    `json:"to"`             // 结束桶号（含）
+}
+
+// Empty 是否未设置任何条件
+func (e *TaskEligibility) Empty() bool {
+	return e == nil || (e.NewUserDays == 0 && len(e.Platforms) == 0 && len(e.Providers) == 0 &&
+		len(e.Languages) == 0 && e.MinBalance == nil && e.MaxBalance == nil && e.Purchased == nil && e.ABBucket == nil)
+}
+
+// GetLatestLoginLog 获取用户最近一次登录记录，没有时返回 nil
+func GetLatestLoginLog(db *gorm.DB, userID string) (*UserLoginLog, error) {
+	var log UserLoginLog
+	err := db.Where("user_id = ?", userID).Order("login_time DESC").Limit(1).Find(&log).Error
+	if err != nil || log.LogID == 0 {
+		return nil, err
+	}
+	return &log, nil
+}
+
+// HasPaidOrder 用户是否有支付成功的订单
+func HasPaidOrder(db *gorm.DB, userID string) (bool, error) {
+	var count int64
+	err := db.Model(&Order{}).
+		Where("user_id = ? AND status IN ?", userID, []OrderStatus{OrderStatusPaid, OrderStatusCompleted}).
+		Limit(1).Count(&count).Error
+	return count > 0, err
+}
+
+// TaskCompletionTotals 任务在一段时间内的完成汇总，撤销的完成不计入次数和人数
+type TaskCompletionTotals struct {
+	Completions   int64 `gorm:"column:completions" json:"completions"`       // 完成次数
+	UniqueUsers   int64 `gorm:"column:unique_users" json:"unique_users"`     // 完成人数
+	TokensIssued  int64 `gorm:"column:tokens_issued" json:"tokens_issued"`   // 发放的代币数，已扣除撤销时扣回的部分
+	Revoked       int64 `gorm:"column:revoked" json:"revoked"`               // 撤销的完成次数
+	RevokedTokens int64 `gorm:"column:revoked_tokens" json:"revoked_tokens"` // 撤销时扣回的代币数
+}
+
+// taskCompletionTotalsSelect 汇总完成次数、人数和代币的查询字段
+const taskCompletionTotalsSelect = "COALESCE(SUM(CASE WHEN revoked_at IS NULL THEN 1 ELSE 0 END), 0) AS completions, " +
+	"COUNT(DISTINCT CASE WHEN revoked_at IS NULL THEN user_id END) AS unique_users, " +
+	"COALESCE(SUM(token_reward - revoked_tokens), 0) AS tokens_issued, " +
+	"COALESCE(SUM(CASE WHEN revoked_at IS NULL THEN 0 ELSE 1 END), 0) AS revoked, " +
+	"COALESCE(SUM(revoked_tokens), 0) AS revoked_tokens"
+
+// GetTaskCompletionTotals 汇总任务在 [start, end) 内的完成情况
+func GetTaskCompletionTotals(db *gorm.DB, taskID int, start, end time.Time) (*TaskCompletionTotals, error) {
+	var totals TaskCompletionTotals
+	err := db.Model(&TaskCompletionRecord{}).
+		Select(taskCompletionTotalsSelect).
+		Where("task_id = ? AND completed_at >= ? AND completed_at < ?", taskID, start, end).
+		Scan(&totals).Error
+	return &totals, err
+}
+
+// TaskCompletionBucket 时间序列中一个区间的完成汇总
+type TaskCompletionBucket struct {
+	Bucket int `gorm:"column:bucket"` // 区间序号，从 0 开始
+	TaskCompletionTotals
+}
+
+// ListTaskCompletionBuckets 按 bounds 划分的相邻区间 [bounds[i], bounds[i+1]) 一次查询汇总任务的完成情况，
+// 区间边界由调用方按时区计算；没有完成记录的区间不在结果中
+func ListTaskCompletionBuckets(db *gorm.DB, taskID int, bounds []time.Time) ([]*TaskCompletionBucket, error) {
+	if len(bounds) < 2 {
+		return nil, nil
+	}
+	var (
+		bucket strings.Builder
+		args   []interface{}
+	)
+	bucket.WriteString("CASE")
+	for i := 1; i < len(bounds)-1; i++ {
+		bucket.WriteString(" WHEN completed_at < ? THEN ?")
+		args = append(args, bounds[i], i-1)
+	}
+	bucket.WriteString(" ELSE ? END AS bucket, ")
+	args = append(args, len(bounds)-2)
+
+	var rows []*TaskCompletionBucket
+	err := db.Model(&TaskCompletionRecord{}).
+		Select(bucket.String()+taskCompletionTotalsSelect, args...).
+		Where("task_id = ? AND completed_at >= ? AND completed_at < ?", taskID, bounds[0], bounds[len(bounds)-1]).
+		Group("bucket").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// TaskCompleter 任务完成次数排行
+type TaskCompleter struct {
+	UserID          string    `gorm:"column:user_id" json:"user_id"`
+	Nickname        *string   `gorm:"column:nickname" json:"nickname"`
+	Completions     int64     `gorm:"column:completions" json:"completions"`             // 完成次数，不含撤销的
+	Tokens          int64     `gorm:"column:tokens" json:"tokens"`                       // 获得的代币数
+	LastCompletedAt time.Time `gorm:"column:last_completed_at" json:"last_completed_at"` // 最近一次完成时间
+}
+
+// ListTopTaskCompleters 按完成次数从高到低列出任务在 [start, end) 内的完成用户
+func ListTopTaskCompleters(db *gorm.DB, taskID int, start, end time.Time, limit int) ([]*TaskCompleter, error) {
+	var list []*TaskCompleter
+	err := db.Table("task_completion_records AS r").
+		Select("r.user_id, u.nickname, COUNT(*) AS completions, SUM(r.token_reward) AS tokens, MAX(r.completed_at) AS last_completed_at").
+		Joins("LEFT JOIN users AS u ON u.id = r.user_id").
+		Where("r.task_id = ? AND r.completed_at >= ? AND r.completed_at < ? AND r.revoked_at IS NULL", taskID, start, end).
+		Group("r.user_id, u.nickname").
+		Order("completions DESC, last_completed_at DESC").
+		Limit(limit).
+		Scan(&list).Error
+	return list, err
+}
+
+// TaskCompletionFilter 任务完成记录查询条件
+type TaskCompletionFilter struct {
+	UserID         string
+	TaskID         int
+	IncludeRevoked bool
+}
+
+// TaskCompletionDetail 任务完成记录及任务名称
+type TaskCompletionDetail struct {
+	TaskCompletionRecord
+	TaskName string          `gorm:"column:task_name" json:"task_name"`
+	Evidence json.RawMessage `gorm:"-" json:"extra_data,omitempty"` // 上报完成时提交的数据
+}
+
+// ListTaskCompletions 按完成时间倒序分页查询任务完成记录
+func ListTaskCompletions(db *gorm.DB, filter *TaskCompletionFilter, page, limit int) ([]*TaskCompletionDetail, int64, error) {
+	query := db.Table("task_completion_records AS r").
+		Joins("LEFT JOIN reward_tasks AS t ON t.task_id = r.task_id")
+	if filter.UserID != "" {
+		query = query.Where("r.user_id = ?", filter.UserID)
+	}
+	if filter.TaskID > 0 {
+		query = query.Where("r.task_id = ?", filter.TaskID)
+	}
+	if !filter.IncludeRevoked {
+		query = query.Where("r.revoked_at IS NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var list []*TaskCompletionDetail
+	err := query.Select("r.*, t.task_name").
+		Order("r.completed_at DESC, r.id DESC").
+		Offset((page - 1) * limit).Limit(limit).
+		Scan(&list).Error
+	for _, d := range list {
+		if d.ExtraData != nil && *d.ExtraData != "" {
+			d.Evidence = json.RawMessage(*d.ExtraData)
+		}
+	}
+	return list, total, err
+}
@@ -0,0 +1,236 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 批量操作类型
+const (
+	BulkOpGrantTokens = "tokens_grant" // 增减代币，按差额调整
+	BulkOpDisable     = "disable"      // 禁用账号
+	BulkOpEnable      = "enable"       // 启用账号
+)
+
+// 批量任务状态
+const (
+	BulkJobStatusPending   = "pending"   // 排队中
+	BulkJobStatusRunning   = "running"   // 执行中
+	BulkJobStatusSuccess   = "success"   // 已完成（单个用户失败不影响任务完成）
+	BulkJobStatusFailed    = "failed"    // 任务异常终止
+	BulkJobStatusCancelled = "cancelled" // 已取消，已处理的用户不回滚
+)
+
+// 批量任务单个用户的处理结果
+const (
+	BulkItemPending = "pending" // 待处理
+	BulkItemSuccess = "success" // 成功（试运行时表示预计成功）
+	BulkItemFailed  = "failed"  // 失败
+	BulkItemSkipped = "skipped" // 无需处理，如状态未变化
+)
+
+// UserBulkJob 用户批量操作任务表结构体
+type UserBulkJob struct {
+	ID         int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`                                                     // 主键，自增
+	AdminID    int64      `gorm:"column:admin_id;not null;index:idx_bulk_jobs_admin" json:"admin_id"`                               // 发起人
+	Operation  string     `gorm:"column:operation;type:varchar(20);not null" json:"operation"`                                      // 操作类型
+	Amount     int        `gorm:"column:amount;not null;default:0" json:"amount"`                                                   // 代币增减数量，仅 tokens_grant
+	Remark     *string    `gorm:"column:remark;type:varchar(255)" json:"remark"`                                                    // 备注，写入代币记录
+	Source     string     `gorm:"column:source;type:varchar(10);not null" json:"source"`                                            // 用户来源：filter=按筛选条件，ids=ID列表
+	Filter     *string    `gorm:"column:filter;type:json" json:"filter"`                                                            // 筛选条件，仅 filter 来源
+	DryRun     bool       `gorm:"column:dry_run;not null;default:false" json:"dry_run"`                                             // 试运行，只计算结果不落库
+	Status     string     `gorm:"column:status;type:varchar(20);not null;default:pending;index:idx_bulk_jobs_status" json:"status"` // 任务状态
+	Total      int        `gorm:"column:total;not null;default:0" json:"total"`                                                     // 目标用户数
+	Processed  int        `gorm:"column:processed;not null;default:0" json:"processed"`                                             // 已处理数
+	Succeeded  int        `gorm:"column:succeeded;not null;default:0" json:"succeeded"`                                             // 成功数
+	Failed     int        `gorm:"column:failed;not null;default:0" json:"failed"`                                                   // 失败数
+	Skipped    int        `gorm:"column:skipped;not null;default:0" json:"skipped"`                                                 // 跳过数
+	Error      *string    `gorm:"column:error;type:varchar(255)" json:"error"`                                                      // 任务异常原因
+	CreatedAt  time.Time  `gorm:"column:created_at;not null;autoCreateTime" json:"created_at"`                                      // 创建时间
+	UpdatedAt  time.Time  `gorm:"column:updated_at;not null;autoUpdateTime" json:"updated_at"`                                      // 最近一次进度更新时间
+	StartedAt  *time.Time `gorm:"column:started_at" json:"started_at"`                                                              // 开始执行时间
+	FinishedAt *time.Time `gorm:"column:finished_at" json:"finished_at"`                                                            // 结束时间
+}
+
+func (UserBulkJob) TableName() string {
+	return "user_bulk_jobs"
+}
+
+// UserBulkJobItem 批量任务中单个用户的处理结果表结构体
+type UserBulkJobItem struct {
+	ID            int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`                                                                        // 主键，自增
+	JobID         int64      `gorm:"column:job_id;not null;uniqueIndex:uk_bulk_item_user,priority:1;index:idx_bulk_item_result,priority:1" json:"job_id"` // 任务ID
+	UserID        string     `gorm:"column:user_id;type:varchar(13);not null;uniqueIndex:uk_bulk_item_user,priority:2" json:"user_id"`                    // 用户ID
+	Result        string     `gorm:"column:result;type:varchar(10);not null;default:pending;index:idx_bulk_item_result,priority:2" json:"result"`         // 处理结果
+	Message       *string    `gorm:"column:message;type:varchar(255)" json:"message"`                                                                     // 失败或跳过原因
+	BalanceBefore *int       `gorm:"column:balance_before" json:"balance_before"`                                                                         // 调整前余额，仅 tokens_grant
+	BalanceAfter  *int       `gorm:"column:balance_after" json:"balance_after"`                                                                           // 调整后余额，仅 tokens_grant
+	RecordID      *int64     `gorm:"column:record_id" json:"record_id"`                                                                                   // 代币记录ID
+	ProcessedAt   *time.Time `gorm:"column:processed_at" json:"processed_at"`                                                                             // 处理时间
+}
+
+func (UserBulkJobItem) TableName() string {
+	return "user_bulk_job_items"
+}
+
+// CreateUserBulkJob 创建批量任务并写入目标用户，items 中的用户ID需已去重
+func CreateUserBulkJob(db *gorm.DB, job *UserBulkJob, userIDs []string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		job.Total = len(userIDs)
+		if err := tx.Create(job).Error; err != nil {
+			return err
+		}
+		items := make([]*UserBulkJobItem, 0, len(userIDs))
+		for _, id := range userIDs {
+			items = append(items, &UserBulkJobItem{JobID: job.ID, UserID: id, Result: BulkItemPending})
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(items, 1000).Error
+	})
+}
+
+// GetUserBulkJob 获取批量任务
+func GetUserBulkJob(db *gorm.DB, id int64) (*UserBulkJob, error) {
+	var job UserBulkJob
+	if err := db.Where("id = ?", id).First(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListUserBulkJobs 分页获取批量任务，按创建时间倒序
+func ListUserBulkJobs(db *gorm.DB, page, limit int) ([]*UserBulkJob, int64, error) {
+	var (
+		jobs  []*UserBulkJob
+		total int64
+	)
+	query := db.Model(&UserBulkJob{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id DESC").Offset((page - 1) * limit).Limit(limit).Find(&jobs).Error
+	return jobs, total, err
+}
+
+// ListUserBulkJobItems 分页获取批量任务的逐个用户结果，result 为空时不过滤
+func ListUserBulkJobItems(db *gorm.DB, jobID int64, result string, page, limit int) ([]*UserBulkJobItem, int64, error) {
+	var (
+		items []*UserBulkJobItem
+		total int64
+	)
+	query := db.Model(&UserBulkJobItem{}).Where("job_id = ?", jobID)
+	if result != "" {
+		query = query.Where("result = ?", result)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id ASC").Offset((page - 1) * limit).Limit(limit).Find(&items).Error
+	return items, total, err
+}
+
+// ListPendingBulkJobItems 按ID顺序获取一批待处理的用户
+func ListPendingBulkJobItems(db *gorm.DB, jobID int64, limit int) ([]*UserBulkJobItem, error) {
+	var items []*UserBulkJobItem
+	err := db.Where("job_id = ? AND result = ?", jobID, BulkItemPending).
+		Order("id ASC").Limit(limit).Find(&items).Error
+	return items, err
+}
+
+// FinishBulkJobItem 记录单个用户的处理结果，只更新仍待处理的记录，返回是否更新成功
+func FinishBulkJobItem(db *gorm.DB, item *UserBulkJobItem) (bool, error) {
+	now := time.Now()
+	item.ProcessedAt = &now
+	result := db.Model(&UserBulkJobItem{}).
+		Where("id = ? AND result = ?", item.ID, BulkItemPending).
+		Updates(map[string]interface{}{
+			"result":         item.Result,
+			"message":        item.Message,
+			"balance_before": item.BalanceBefore,
+			"balance_after":  item.BalanceAfter,
+			"record_id":      item.RecordID,
+			"processed_at":   now,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// RefreshUserBulkJobProgress 按逐个用户的结果重新统计任务进度
+func RefreshUserBulkJobProgress(db *gorm.DB, jobID int64) error {
+	var rows []struct {
+		Result string
+		Count  int
+	}
+	if err := db.Model(&UserBulkJobItem{}).
+		Select("result, COUNT(*) AS count").
+		Where("job_id = ?", jobID).
+		Group("result").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+	updates := map[string]interface{}{"processed": 0, "succeeded": 0, "failed": 0, "skipped": 0}
+	processed := 0
+	for _, r := range rows {
+		switch r.Result {
+		case BulkItemSuccess:
+			updates["succeeded"] = r.Count
+		case BulkItemFailed:
+			updates["failed"] = r.Count
+		case BulkItemSkipped:
+			updates["skipped"] = r.Count
+		default:
+			continue
+		}
+		processed += r.Count
+	}
+	updates["processed"] = processed
+	return db.Model(&UserBulkJob{}).Where("id = ?", jobID).Updates(updates).Error
+}
+
+// ClaimUserBulkJob 领取一个排队中的批量任务，条件更新保证多实例下同一任务只被领取一次；没有任务时返回 nil
+func ClaimUserBulkJob(db *gorm.DB) (*UserBulkJob, error) {
+	var job UserBulkJob
+	err := db.Where("status = ?", BulkJobStatusPending).Order("id ASC").First(&job).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	now := time.Now()
+	updates := map[string]interface{}{"status": BulkJobStatusRunning}
+	if job.StartedAt == nil {
+		updates["started_at"] = now
+	}
+	result := db.Model(&UserBulkJob{}).
+		Where("id = ? AND status = ?", job.ID, BulkJobStatusPending).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	job.Status = BulkJobStatusRunning
+	if job.StartedAt == nil {
+		job.StartedAt = &now
+	}
+	return &job, nil
+}
+
+// UpdateUserBulkJobStatus 条件更新任务状态，只有当前状态为 from 之一时才更新，返回是否更新成功
+func UpdateUserBulkJobStatus(db *gorm.DB, id int64, from []string, updates map[string]interface{}) (bool, error) {
+	result := db.Model(&UserBulkJob{}).Where("id = ? AND status IN ?", id, from).Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
+// RequeueStaleUserBulkJobs 将长时间没有进度的执行中任务重新排队（实例异常退出后遗留），已处理的用户不会重复处理
+func RequeueStaleUserBulkJobs(db *gorm.DB, before time.Time) (int64, error) {
+	result := db.Model(&UserBulkJob{}).
+		Where("status = ? AND updated_at < ?", BulkJobStatusRunning, before).
+		Update("status", BulkJobStatusPending)
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,240 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 用户标签类型
+const (
+	TagTypeManual = "manual" // 手动标签，由管理员添加或移除用户
+	TagTypeRule   = "rule"   // 规则标签，按规则定期重算
+)
+
+// 规则标签的统计指标
+const (
+	TagMetricTokensSpent    = "tokens_spent"     // 消耗代币数
+	TagMetricTokensEarned   = "tokens_earned"    // 获得代币数
+	TagMetricRechargeAmount = "recharge_amount"  // 充值金额（元）
+	TagMetricInvites        = "invites"          // 邀请注册人数
+	TagMetricTaskCompletion = "task_completions" // 完成任务次数
+	TagMetricProvider       = "provider"         // 注册渠道，取自 user_auth.provider
+)
+
+// UserTagCondition 规则标签的单个条件；provider 只比较 Provider，其余指标按 Op 与 Value 比较统计值
+type UserTagCondition struct {
+	Metric   string  `json:"metric"`             // 统计指标
+	Op       string  `json:"op,omitempty"`       // 比较方式：> >= < <= =
+	Value    float64 `json:"value,omitempty"`    // 比较值
+	Days     int     `json:"days,omitempty"`     // 只统计最近 N 天，0 表示全部
+	Provider string  `json:"provider,omitempty"` // 注册渠道，仅 provider 指标
+}
+
+// UserTagRule 标签规则，用户需同时满足全部条件
+type UserTagRule struct {
+	Conditions []UserTagCondition `json:"conditions"`
+}
+
+// UserTag 用户标签表结构体
+type UserTag struct {
+	ID          int64        `gorm:"column:id;primaryKey;autoIncrement" json:"id"`                                    // 主键，自增
+	Code        string       `gorm:"column:code;type:varchar(50);not null;uniqueIndex:uk_user_tags_code" json:"code"` // 标签编码
+	Name        string       `gorm:"column:name;type:varchar(50);not null" json:"name"`                               // 标签名称
+	Description *string      `gorm:"column:description;type:varchar(255)" json:"description"`                         // 标签说明
+	Type        string       `gorm:"column:type;type:varchar(10);not null" json:"type"`                               // 标签类型：manual/rule
+	RuleJSON    *string      `gorm:"column:rule;type:json" json:"-"`                                                  // 标签规则，仅规则标签
+	Rule        *UserTagRule `gorm:"-" json:"rule"`                                                                   // 解析后的标签规则
+	MemberCount int64        `gorm:"column:member_count;not null;default:0" json:"member_count"`                      // 用户数
+	ComputedAt  *time.Time   `gorm:"column:computed_at" json:"computed_at"`                                           // 规则最近一次重算时间
+	CreatedAt   time.Time    `gorm:"column:created_at;not null;autoCreateTime" json:"created_at"`                     // 创建时间
+	UpdatedAt   time.Time    `gorm:"column:updated_at;not null;autoUpdateTime" json:"updated_at"`                     // 更新时间
+}
+
+func (UserTag) TableName() string {
+	return "user_tags"
+}
+
+// AfterFind 查询后解析标签规则
+func (t *UserTag) AfterFind(tx *gorm.DB) error {
+	if t.RuleJSON == nil || *t.RuleJSON == "" {
+		return nil
+	}
+	var rule UserTagRule
+	if err := json.Unmarshal([]byte(*t.RuleJSON), &rule); err != nil {
+		return err
+	}
+	t.Rule = &rule
+	return nil
+}
+
+// UserTagMember 用户标签关联表结构体
+type UserTagMember struct {
+	TagID     int64     `gorm:"column:tag_id;primaryKey" json:"tag_id"`                                                    // 标签ID
+	UserID    string    `gorm:"column:user_id;type:varchar(13);primaryKey;index:idx_user_tag_members_user" json:"user_id"` // 用户ID
+	CreatedAt time.Time `gorm:"column:created_at;not null;autoCreateTime" json:"created_at"`                               // 打上标签的时间
+}
+
+func (UserTagMember) TableName() string {
+	return "user_tag_members"
+}
+
+// RewardTaskTag 任务参与条件关联表，任务关联标签后只有带其中任一标签的用户可以参与
+type RewardTaskTag struct {
+	TaskID int   `gorm:"column:task_id;primaryKey" json:"task_id"` // 任务ID
+	TagID  int64 `gorm:"column:tag_id;primaryKey" json:"tag_id"`   // 标签ID
+}
+
+func (RewardTaskTag) TableName() string {
+	return "reward_task_tags"
+}
+
+// CreateUserTag 创建用户标签
+func CreateUserTag(db *gorm.DB, tag *UserTag) error {
+	return db.Create(tag).Error
+}
+
+// GetUserTag 获取用户标签
+func GetUserTag(db *gorm.DB, id int64) (*UserTag, error) {
+	var tag UserTag
+	if err := db.Where("id = ?", id).First(&tag).Error; err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// ListUserTags 获取用户标签，typ 为空时不过滤
+func ListUserTags(db *gorm.DB, typ string) ([]*UserTag, error) {
+	var tags []*UserTag
+	query := db.Model(&UserTag{})
+	if typ != "" {
+		query = query.Where("type = ?", typ)
+	}
+	err := query.Order("id ASC").Find(&tags).Error
+	return tags, err
+}
+
+// CountUserTags 统计存在的标签数，用于校验标签ID
+func CountUserTags(db *gorm.DB, ids []int64) (int64, error) {
+	var count int64
+	err := db.Model(&UserTag{}).Where("id IN ?", ids).Count(&count).Error
+	return count, err
+}
+
+// UpdateUserTag 更新用户标签
+func UpdateUserTag(db *gorm.DB, id int64, updates map[string]interface{}) error {
+	return db.Model(&UserTag{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// DeleteUserTag 删除用户标签及其用户和任务关联
+func DeleteUserTag(db *gorm.DB, id int64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tag_id = ?", id).Delete(&UserTagMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tag_id = ?", id).Delete(&RewardTaskTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&UserTag{}, id).Error
+	})
+}
+
+// AddUserTagMembers 为用户打上标签，已有的忽略
+func AddUserTagMembers(db *gorm.DB, tagID int64, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	members := make([]*UserTagMember, 0, len(userIDs))
+	for _, id := range userIDs {
+		members = append(members, &UserTagMember{TagID: tagID, UserID: id})
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(members, 1000).Error
+}
+
+// RemoveUserTagMembers 移除用户的标签
+func RemoveUserTagMembers(db *gorm.DB, tagID int64, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	return db.Where("tag_id = ? AND user_id IN ?", tagID, userIDs).Delete(&UserTagMember{}).Error
+}
+
+// ListUserTagMembers 分页获取标签下的用户
+func ListUserTagMembers(db *gorm.DB, tagID int64, page, limit int) ([]*UserTagMember, int64, error) {
+	var (
+		members []*UserTagMember
+		total   int64
+	)
+	query := db.Model(&UserTagMember{}).Where("tag_id = ?", tagID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&members).Error
+	return members, total, err
+}
+
+// ListUserTagMemberIDs 获取标签下的全部用户ID
+func ListUserTagMemberIDs(db *gorm.DB, tagID int64) ([]string, error) {
+	var ids []string
+	err := db.Model(&UserTagMember{}).Where("tag_id = ?", tagID).Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// RefreshUserTagMemberCount 重新统计标签的用户数
+func RefreshUserTagMemberCount(db *gorm.DB, tagID int64) error {
+	var count int64
+	if err := db.Model(&UserTagMember{}).Where("tag_id = ?", tagID).Count(&count).Error; err != nil {
+		return err
+	}
+	return db.Model(&UserTag{}).Where("id = ?", tagID).Update("member_count", count).Error
+}
+
+// ListUserTagIDsByUser 获取用户拥有的标签ID
+func ListUserTagIDsByUser(db *gorm.DB, userID string) ([]int64, error) {
+	var ids []int64
+	err := db.Model(&UserTagMember{}).Where("user_id = ?", userID).Pluck("tag_id", &ids).Error
+	return ids, err
+}
+
+// ListUserTagsByUser 获取用户拥有的标签
+func ListUserTagsByUser(db *gorm.DB, userID string) ([]*UserTag, error) {
+	var tags []*UserTag
+	err := db.Where("id IN (?)", db.Model(&UserTagMember{}).Select("tag_id").Where("user_id = ?", userID)).
+		Order("id ASC").Find(&tags).Error
+	return tags, err
+}
+
+// ListRewardTaskTagIDs 获取任务关联的标签ID，按任务ID分组
+func ListRewardTaskTagIDs(db *gorm.DB, taskIDs []int) (map[int][]int64, error) {
+	result := make(map[int][]int64, len(taskIDs))
+	if len(taskIDs) == 0 {
+		return result, nil
+	}
+	var rows []RewardTaskTag
+	if err := db.Where("task_id IN ?", taskIDs).Order("tag_id ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		result[r.TaskID] = append(result[r.TaskID], r.TagID)
+	}
+	return result, nil
+}
+
+// SetRewardTaskTags 替换任务关联的标签，tagIDs 为空表示所有用户都可参与
+func SetRewardTaskTags(db *gorm.DB, taskID int, tagIDs []int64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("task_id = ?", taskID).Delete(&RewardTaskTag{}).Error; err != nil {
+			return err
+		}
+		if len(tagIDs) == 0 {
+			return nil
+		}
+		rows := make([]*RewardTaskTag, 0, len(tagIDs))
+		for _, id := range tagIDs {
+			rows = append(rows, &RewardTaskTag{TaskID: taskID, TagID: id})
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(rows).Error
+	})
+}
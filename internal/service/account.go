@@ -0,0 +1,331 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/mailer"
+	"gorm.io/gorm"
+)
+
+// 验证码允许的最大错误次数，超过后令牌作废
+const maxAccountTokenAttempts = 5
+
+var errTooFrequent = errors.New(errors.ErrCodeInvalidParams, "发送过于频繁，请稍后再试", nil)
+
+// VerifyEmailRequest 邮箱验证请求，支持链接令牌或邮箱+验证码两种方式
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+	Email string `json:"email" binding:"omitempty,email"`
+	Code  string `json:"code" binding:"omitempty,len=6,numeric"`
+}
+
+// ResetPasswordRequest 重置密码请求，支持链接令牌或邮箱+验证码两种方式
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	Email       string `json:"email" binding:"omitempty,email"`
+	Code        string `json:"code" binding:"omitempty,len=6,numeric"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ResendVerification 重新发送邮箱验证邮件
+func (s *AuthService) ResendVerification(ctx context.Context, email string) error {
+	user, err := model.GetUserByEmail(s.db, normalizeEmail(email))
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			// 不暴露邮箱是否注册
+			return nil
+		}
+		return errors.New(errors.ErrCodeInternal, "查询用户失败", err)
+	}
+	if user.EmailVerifiedAt != nil {
+		return errors.New(errors.ErrCodeInvalidParams, "邮箱已验证", nil)
+	}
+	return s.sendVerification(ctx, user)
+}
+
+// VerifyEmail 验证邮箱
+func (s *AuthService) VerifyEmail(ctx context.Context, req *VerifyEmailRequest) error {
+	token, err := s.lookupAccountToken(model.AccountTokenEmailVerify, req.Token, req.Email, req.Code)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		ok, err := model.ConsumeAccountToken(tx, token.TokenID, maxAccountTokenAttempts)
+		if err != nil {
+			return errors.New(errors.ErrCodeInternal, "更新令牌失败", err)
+		}
+		if !ok {
+			return errors.ErrInvalidToken
+		}
+
+		// 邮箱在令牌签发后被修改过，则令牌失效
+		user, err := model.GetUserByID(tx, token.UserID)
+		if err != nil {
+			return errors.New(errors.ErrCodeInternal, "查询用户失败", err)
+		}
+		if user.Email == nil || *user.Email != token.Email {
+			return errors.ErrInvalidToken
+		}
+		if user.EmailVerifiedAt != nil {
+			return nil
+		}
+		if err := model.UpdateUser(tx, user.UserID, map[string]interface{}{
+			"email_verified_at": time.Now(),
+		}); err != nil {
+			return errors.New(errors.ErrCodeInternal, "更新用户信息失败", err)
+		}
+		return nil
+	})
+}
+
+// ForgotPassword 发送密码重置邮件，无论邮箱是否存在都返回成功
+func (s *AuthService) ForgotPassword(ctx context.Context, email string) error {
+	user, err := model.GetUserByEmail(s.db, normalizeEmail(email))
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return errors.New(errors.ErrCodeInternal, "查询用户失败", err)
+	}
+	if user.Status != 1 {
+		return nil
+	}
+
+	link, code, err := s.issueAccountToken(user, model.AccountTokenPasswordReset, s.config.Account.ResetTokenTTL)
+	if err != nil {
+		if err == errTooFrequent {
+			// 冷却期内静默忽略，避免通过响应判断邮箱是否注册
+			return nil
+		}
+		return err
+	}
+
+	body := fmt.Sprintf("您正在重置账号密码。\n\n验证码：%s\n\n也可以点击以下链接完成重置：\n%s\n\n链接和验证码%s内有效，如非本人操作请忽略本邮件。",
+		code, buildTokenURL(s.config.Account.ResetURL, link), formatTTL(s.config.Account.ResetTokenTTL))
+	return s.sendMail(ctx, user, "重置密码", body)
+}
+
+// ResetPassword 使用令牌重置密码
+func (s *AuthService) ResetPassword(ctx context.Context, req *ResetPasswordRequest) error {
+	token, err := s.lookupAccountToken(model.AccountTokenPasswordReset, req.Token, req.Email, req.Code)
+	if err != nil {
+		return err
+	}
+
+	passwordHash, err := s.policy.Hash(req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		ok, err := model.ConsumeAccountToken(tx, token.TokenID, maxAccountTokenAttempts)
+		if err != nil {
+			return errors.New(errors.ErrCodeInternal, "更新令牌失败", err)
+		}
+		if !ok {
+			return errors.ErrInvalidToken
+		}
+
+		updates := map[string]interface{}{
+			"password_hash": passwordHash,
+		}
+		// 能收到重置邮件即证明邮箱归属
+		user, err := model.GetUserByID(tx, token.UserID)
+		if err != nil {
+			return errors.New(errors.ErrCodeInternal, "查询用户失败", err)
+		}
+		if user.Email == nil || *user.Email != token.Email {
+			return errors.ErrInvalidToken
+		}
+		if user.EmailVerifiedAt == nil {
+			updates["email_verified_at"] = time.Now()
+		}
+		if err := model.UpdateUser(tx, user.UserID, updates); err != nil {
+			return errors.New(errors.ErrCodeInternal, "更新密码失败", err)
+		}
+
+		// 同一用户其余未使用的重置令牌一并作废
+		if err := model.InvalidateAccountTokens(tx, user.UserID, model.AccountTokenPasswordReset); err != nil {
+			return errors.New(errors.ErrCodeInternal, "作废重置令牌失败", err)
+		}
+		return nil
+	})
+}
+
+// sendVerification 签发并发送邮箱验证邮件
+func (s *AuthService) sendVerification(ctx context.Context, user *model.User) error {
+	link, code, err := s.issueAccountToken(user, model.AccountTokenEmailVerify, s.config.Account.VerifyTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("感谢注册，请完成邮箱验证。\n\n验证码：%s\n\n也可以点击以下链接完成验证：\n%s\n\n链接和验证码%s内有效，如非本人操作请忽略本邮件。",
+		code, buildTokenURL(s.config.Account.VerifyURL, link), formatTTL(s.config.Account.VerifyTokenTTL))
+	return s.sendMail(ctx, user, "验证您的邮箱", body)
+}
+
+// issueAccountToken 签发一次性令牌，返回链接令牌和数字验证码明文
+func (s *AuthService) issueAccountToken(user *model.User, purpose string, ttl time.Duration) (string, string, error) {
+	if user.Email == nil || *user.Email == "" {
+		return "", "", errors.New(errors.ErrCodeInvalidParams, "账号未绑定邮箱", nil)
+	}
+
+	// 发送频率限制
+	latest, err := model.GetLatestAccountToken(s.db, user.UserID, purpose)
+	if err != nil && !stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return "", "", errors.New(errors.ErrCodeInternal, "查询令牌失败", err)
+	}
+	if latest != nil && time.Since(latest.CreatedAt) < s.config.Account.ResendInterval {
+		return "", "", errTooFrequent
+	}
+
+	link, err := randomToken()
+	if err != nil {
+		return "", "", errors.New(errors.ErrCodeInternal, "生成令牌失败", err)
+	}
+	code, err := randomCode()
+	if err != nil {
+		return "", "", errors.New(errors.ErrCodeInternal, "生成验证码失败", err)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 新令牌签发后旧令牌作废
+		if err := model.InvalidateAccountTokens(tx, user.UserID, purpose); err != nil {
+			return err
+		}
+		return model.CreateAccountToken(tx, &model.AccountToken{
+			UserID:    user.UserID,
+			Purpose:   purpose,
+			TokenHash: hashSecret(link),
+			CodeHash:  hashSecret(code),
+			Email:     *user.Email,
+			ExpiresAt: time.Now().Add(ttl),
+		})
+	})
+	if err != nil {
+		return "", "", errors.New(errors.ErrCodeInternal, "保存令牌失败", err)
+	}
+	return link, code, nil
+}
+
+// lookupAccountToken 根据链接令牌或邮箱+验证码查找有效令牌
+func (s *AuthService) lookupAccountToken(purpose, link, email, code string) (*model.AccountToken, error) {
+	var token *model.AccountToken
+	var err error
+
+	switch {
+	case link != "":
+		token, err = model.GetAccountTokenByHash(s.db, purpose, hashSecret(link))
+	case email != "" && code != "":
+		var user *model.User
+		user, err = model.GetUserByEmail(s.db, normalizeEmail(email))
+		if err == nil {
+			token, err = model.GetLatestAccountToken(s.db, user.UserID, purpose)
+		}
+	default:
+		return nil, errors.New(errors.ErrCodeInvalidParams, "请提供链接令牌或邮箱验证码", nil)
+	}
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.ErrInvalidToken
+		}
+		return nil, errors.New(errors.ErrCodeInternal, "查询令牌失败", err)
+	}
+
+	if token.UsedAt != nil || time.Now().After(token.ExpiresAt) || token.Attempts >= maxAccountTokenAttempts {
+		return nil, errors.ErrInvalidToken
+	}
+
+	if link != "" {
+		return token, nil
+	}
+
+	// 验证码方式先原子占用一次尝试次数再比对，避免并发猜测绕过次数上限
+	ok, err := model.TryAccountTokenAttempt(s.db, token.TokenID, maxAccountTokenAttempts)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "更新验证码尝试次数失败", err)
+	}
+	if !ok {
+		return nil, errors.ErrInvalidToken
+	}
+	if subtle.ConstantTimeCompare([]byte(token.CodeHash), []byte(hashSecret(code))) != 1 {
+		return nil, errors.New(errors.ErrCodeInvalidVerifyCode, "验证码错误", nil)
+	}
+	return token, nil
+}
+
+// sendMail 发送邮件
+func (s *AuthService) sendMail(ctx context.Context, user *model.User, subject, body string) error {
+	if s.mailer == nil {
+		return errors.New(errors.ErrCodeServiceUnavailable, "邮件服务未配置", nil)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	if err := s.mailer.Send(ctx, &mailer.Message{To: *user.Email, Subject: subject, Body: body}); err != nil {
+		return errors.New(errors.ErrCodeServiceUnavailable, "邮件发送失败", err)
+	}
+	return nil
+}
+
+// normalizeEmail 统一邮箱格式
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// hashSecret 计算令牌的SHA-256哈希，数据库只保存哈希值
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomToken 生成URL安全的随机令牌
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// randomCode 生成6位数字验证码
+func randomCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// buildTokenURL 拼接带令牌的链接，未配置地址时只返回令牌
+func buildTokenURL(base, token string) string {
+	if base == "" {
+		return token
+	}
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + "token=" + url.QueryEscape(token)
+}
+
+// formatTTL 将有效期格式化为中文描述
+func formatTTL(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("%d小时", int(d/time.Hour))
+	}
+	return fmt.Sprintf("%d分钟", int(d/time.Minute))
+}
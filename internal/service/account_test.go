@@ -0,0 +1,132 @@
+package service
+
+import (
+	stderrors "errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := &PasswordPolicy{MinLength: 8, MaxLength: 64, RequireLetter: true, RequireDigit: true}
+	strict := &PasswordPolicy{MinLength: 8, RequireUpper: true, RequireSymbol: true}
+
+	cases := []struct {
+		name     string
+		policy   *PasswordPolicy
+		password string
+		ok       bool
+	}{
+		{"valid", policy, "abcdef12", true},
+		{"too short", policy, "abc12", false},
+		{"too long", policy, strings.Repeat("a1", 33), false},
+		{"no digit", policy, "abcdefgh", false},
+		{"no letter", policy, "12345678", false},
+		{"unicode letters count by rune", policy, "密码密码密码1a", true},
+		{"over bcrypt limit", &PasswordPolicy{}, strings.Repeat("a", 73), false},
+		{"strict valid", strict, "Abcdefg!", true},
+		{"strict no upper", strict, "abcdefg!", false},
+		{"strict no symbol", strict, "Abcdefgh", false},
+	}
+	for _, c := range cases {
+		err := c.policy.Validate(c.password)
+		if c.ok && err != nil {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+		if !c.ok {
+			var bizErr *errors.Error
+			if !stderrors.As(err, &bizErr) || bizErr.Code != errors.ErrCodeWeakPassword {
+				t.Errorf("%s: got %v, want weak password error", c.name, err)
+			}
+		}
+	}
+}
+
+func TestPasswordPolicyHash(t *testing.T) {
+	policy := &PasswordPolicy{MinLength: 6, BcryptCost: bcrypt.MinCost}
+	hash, err := policy.Hash("secret1")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte("secret1")) != nil {
+		t.Errorf("hash does not match password")
+	}
+	if cost, _ := bcrypt.Cost([]byte(hash)); cost != bcrypt.MinCost {
+		t.Errorf("bcrypt cost %d, want %d", cost, bcrypt.MinCost)
+	}
+	if _, err := policy.Hash("short"); err == nil {
+		t.Errorf("hash accepted a password that violates the policy")
+	}
+}
+
+func TestAccountTokenSecrets(t *testing.T) {
+	if hashSecret("abc") != hashSecret("abc") {
+		t.Errorf("hashSecret is not deterministic")
+	}
+	if hashSecret("abc") == hashSecret("abd") {
+		t.Errorf("hashSecret collides on different input")
+	}
+	if len(hashSecret("abc")) != 64 {
+		t.Errorf("hashSecret length %d, want 64", len(hashSecret("abc")))
+	}
+
+	codePattern := regexp.MustCompile(`^\d{6}$`)
+	for i := 0; i < 100; i++ {
+		code, err := randomCode()
+		if err != nil {
+			t.Fatalf("randomCode: %v", err)
+		}
+		if !codePattern.MatchString(code) {
+			t.Fatalf("randomCode %q is not 6 digits", code)
+		}
+	}
+
+	a, err := randomToken()
+	if err != nil {
+		t.Fatalf("randomToken: %v", err)
+	}
+	b, _ := randomToken()
+	if a == b {
+		t.Errorf("randomToken returned the same token twice")
+	}
+	if strings.ContainsAny(a, "+/=") {
+		t.Errorf("randomToken %q is not URL safe", a)
+	}
+}
+
+func TestAccountTokenHelpers(t *testing.T) {
+	urls := []struct {
+		base, token, want string
+	}{
+		{"", "tok", "tok"},
+		{"https://example.com/verify", "tok", "https://example.com/verify?token=tok"},
+		{"https://example.com/verify?lang=zh", "a+b", "https://example.com/verify?lang=zh&token=a%2Bb"},
+	}
+	for _, c := range urls {
+		if got := buildTokenURL(c.base, c.token); got != c.want {
+			t.Errorf("buildTokenURL(%q, %q) = %q, want %q", c.base, c.token, got, c.want)
+		}
+	}
+
+	ttls := []struct {
+		d    time.Duration
+		want string
+	}{
+		{30 * time.Minute, "30分钟"},
+		{2 * time.Hour, "2小时"},
+		{90 * time.Minute, "90分钟"},
+	}
+	for _, c := range ttls {
+		if got := formatTTL(c.d); got != c.want {
+			t.Errorf("formatTTL(%v) = %q, want %q", c.d, got, c.want)
+		}
+	}
+
+	if got := normalizeEmail("  Foo@Example.COM "); got != "foo@example.com" {
+		t.Errorf("normalizeEmail = %q", got)
+	}
+}
@@ -0,0 +1,423 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/audit"
+	"github.com/reusedev/uportal-api/pkg/config"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/logs"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// achievementPeriodAll 不重置的成就使用的周期标识
+const achievementPeriodAll = "all"
+
+// AchievementEvent 推进成就进度的业务事件，由各业务在事务提交后上报
+type AchievementEvent struct {
+	UserID string // 用户ID
+	Type   string // 事件类型，见 model.AchievementEvent*
+	Key    string // 事件标识，如任务ID、功能编码、被邀请人ID、订单ID
+	Amount int64  // 事件数量，按 sum 累计时使用
+}
+
+// AchievementRequest 创建或更新成就请求
+type AchievementRequest struct {
+	Code         string `json:"code" binding:"required,max=50"`                                                    // 成就编码，创建后不可修改
+	Name         string `json:"name" binding:"required,max=100"`                                                   // 名称
+	Description  string `json:"description" binding:"max=255"`                                                     // 说明
+	Kind         string `json:"kind" binding:"required,oneof=achievement quest"`                                   // 类别
+	EventType    string `json:"event_type" binding:"required,oneof=task_complete token_consume invite order_paid"` // 推进进度的事件
+	Aggregate    string `json:"aggregate" binding:"required,oneof=count sum distinct"`                             // 累计方式
+	FilterKey    string `json:"filter_key" binding:"max=64"`                                                       // 只统计该 Key 的事件，为空不限
+	Target       int64  `json:"target" binding:"required,min=1"`                                                   // 目标值
+	Period       string `json:"period" binding:"omitempty,oneof=none daily weekly monthly"`                        // 统计周期，默认不重置
+	RewardTokens int    `json:"reward_tokens" binding:"min=0"`                                                     // 完成奖励的代币数
+	BadgeName    string `json:"badge_name" binding:"max=50"`                                                       // 徽章名称，为空不发徽章
+	BadgeIcon    string `json:"badge_icon" binding:"max=255"`                                                      // 徽章图标URL
+	Sort         int    `json:"sort"`                                                                              // 排序
+	Status       *int8  `json:"status" binding:"required,oneof=0 1"`                                               // 状态：1=启用，0=停用
+}
+
+// UserAchievementInfo 用户成就进度
+type UserAchievementInfo struct {
+	*model.Achievement
+	PeriodKey   string     `json:"period_key"`   // 当前周期标识
+	Progress    int64      `json:"progress"`     // 当前进度
+	Completed   bool       `json:"completed"`    // 当前周期是否已完成
+	CompletedAt *time.Time `json:"completed_at"` // 完成时间
+}
+
+// UserBadge 用户已获得的徽章
+type UserBadge struct {
+	AchievementID int64     `json:"achievement_id"` // 成就ID
+	Code          string    `json:"code"`           // 成就编码
+	Name          string    `json:"name"`           // 徽章名称
+	Icon          *string   `json:"icon"`           // 徽章图标URL
+	PeriodKey     string    `json:"period_key"`     // 获得徽章的周期
+	AwardedAt     time.Time `json:"awarded_at"`     // 获得时间
+}
+
+// AchievementService 成就与任务挑战服务，根据任务完成、代币消耗、邀请和订单事件累计进度并在达成时发放奖励
+type AchievementService struct {
+	db       *gorm.DB
+	config   *config.Config
+	notifier *NotificationService
+}
+
+// NewAchievementService 创建成就服务
+func NewAchievementService(db *gorm.DB, cfg *config.Config, notifier *NotificationService) *AchievementService {
+	return &AchievementService{db: db, config: cfg, notifier: notifier}
+}
+
+// Track 上报业务事件，推进匹配成就的进度。成就不影响主流程，失败只记录日志
+func (s *AchievementService) Track(ctx context.Context, ev *AchievementEvent) {
+	if s == nil || ev == nil || ev.UserID == "" {
+		return
+	}
+	list, err := model.ListActiveAchievementsByEvent(s.db.WithContext(ctx), ev.Type)
+	if err != nil {
+		logs.Business().Warn("查询成就失败", zap.String("event", ev.Type), zap.Error(err))
+		return
+	}
+	if len(list) == 0 {
+		return
+	}
+
+	now := time.Now()
+	loc := s.userLocation(ctx, ev.UserID)
+	for _, a := range list {
+		if a.FilterKey != nil && *a.FilterKey != "" && *a.FilterKey != ev.Key {
+			continue
+		}
+		completed, err := s.advance(ctx, a, ev, achievementPeriodKey(a.Period, now, loc), now)
+		if err != nil {
+			logs.Business().Warn("更新成就进度失败", zap.String("user_id", ev.UserID),
+				zap.String("achievement", a.Code), zap.Error(err))
+			continue
+		}
+		if completed {
+			s.notifyCompleted(ctx, ev.UserID, a)
+		}
+	}
+}
+
+// advance 在事务中累计一次事件，返回本次是否达成
+func (s *AchievementService) advance(ctx context.Context, a *model.Achievement, ev *AchievementEvent, periodKey string, now time.Time) (bool, error) {
+	completed := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ua, err := model.LockUserAchievement(tx, ev.UserID, a.ID, periodKey)
+		if err != nil {
+			return err
+		}
+		if ua.CompletedAt != nil {
+			return nil
+		}
+
+		var delta int64
+		switch a.Aggregate {
+		case model.AchievementAggregateCount:
+			delta = 1
+		case model.AchievementAggregateSum:
+			delta = ev.Amount
+		case model.AchievementAggregateDistinct:
+			if ev.Key == "" {
+				return nil
+			}
+			added, err := model.AddUserAchievementMark(tx, ua.ID, ev.Key)
+			if err != nil {
+				return err
+			}
+			if added {
+				delta = 1
+			}
+		}
+		if delta <= 0 {
+			return nil
+		}
+
+		progress, reached := achievementProgress(ua.Progress, delta, a.Target)
+		updates := map[string]interface{}{"progress": progress}
+		if reached {
+			completed = true
+			updates["completed_at"] = now
+			updates["reward_tokens"] = a.RewardTokens
+			if a.RewardTokens > 0 {
+				if err := s.grantReward(tx, ev.UserID, a, now); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Model(&model.UserAchievement{}).Where("id = ?", ua.ID).Updates(updates).Error
+	})
+	return completed, err
+}
+
+// achievementProgress 累计 delta 后的进度（不超过目标值）及是否达成
+func achievementProgress(progress, delta, target int64) (int64, bool) {
+	return min(progress+delta, target), progress+delta >= target
+}
+
+// grantReward 发放成就奖励代币并记录代币流水
+func (s *AchievementService) grantReward(tx *gorm.DB, userID string, a *model.Achievement, now time.Time) error {
+	var user model.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", userID).First(&user).Error; err != nil {
+		return err
+	}
+	if err := model.UpdateUserTokenBalance(tx, userID, a.RewardTokens); err != nil {
+		return err
+	}
+	remark := "成就奖励：" + a.Name
+	return model.CreateTokenRecord(tx, &model.TokenRecord{
+		UserID:       userID,
+		ChangeAmount: a.RewardTokens,
+		BalanceAfter: user.TokenBalance + a.RewardTokens,
+		ChangeType:   "ACHIEVEMENT_REWARD",
+		Remark:       &remark,
+		ChangeTime:   now,
+	})
+}
+
+// notifyCompleted 成就达成通知
+func (s *AchievementService) notifyCompleted(ctx context.Context, userID string, a *model.Achievement) {
+	content := fmt.Sprintf("恭喜您达成「%s」", a.Name)
+	if a.RewardTokens > 0 {
+		content += fmt.Sprintf("，获得 %d 代币奖励", a.RewardTokens)
+	}
+	if a.BadgeName != nil && *a.BadgeName != "" {
+		content += fmt.Sprintf("，解锁徽章「%s」", *a.BadgeName)
+	}
+	s.notifier.Notify(ctx, &NotifyRequest{
+		UserID:  userID,
+		Type:    model.NotificationTypeAchievement,
+		Title:   "成就达成通知",
+		Content: content + "！",
+		Fields: map[string]string{
+			"achievement": a.Name,
+			"reward":      strconv.Itoa(a.RewardTokens),
+			"time":        time.Now().Format("2006-01-02 15:04:05"),
+		},
+	})
+}
+
+// achievementPeriodKey 返回 now 在 loc 时区下所属的统计周期标识
+func achievementPeriodKey(period string, now time.Time, loc *time.Location) string {
+	t := now.In(loc)
+	switch period {
+	case model.AchievementPeriodDaily:
+		return t.Format(time.DateOnly)
+	case model.AchievementPeriodWeekly:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	case model.AchievementPeriodMonthly:
+		return t.Format("2006-01")
+	default:
+		return achievementPeriodAll
+	}
+}
+
+// userLocation 用户时区，未设置时使用业务时区
+func (s *AchievementService) userLocation(ctx context.Context, userID string) *time.Location {
+	fallback := time.Local
+	if s.config != nil {
+		fallback = ResolveLocation(s.config.Locale.Timezone, time.Local)
+	}
+	var user model.User
+	if err := s.db.WithContext(ctx).Select("timezone").Where("id = ?", userID).First(&user).Error; err != nil || user.Timezone == nil {
+		return fallback
+	}
+	return ResolveLocation(*user.Timezone, fallback)
+}
+
+// ListUserAchievements 获取用户当前周期的成就进度，kind 为空时返回全部类别
+func (s *AchievementService) ListUserAchievements(ctx context.Context, userID, kind string) ([]*UserAchievementInfo, error) {
+	enabled := int8(1)
+	list, err := model.ListAchievements(s.db.WithContext(ctx), kind, &enabled)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "获取成就列表失败", err)
+	}
+
+	now := time.Now()
+	loc := s.userLocation(ctx, userID)
+	periodKeys := make(map[int64]string, len(list))
+	for _, a := range list {
+		periodKeys[a.ID] = achievementPeriodKey(a.Period, now, loc)
+	}
+	rows, err := model.ListUserAchievements(s.db.WithContext(ctx), userID, periodKeys)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "获取成就进度失败", err)
+	}
+	progress := make(map[int64]*model.UserAchievement, len(rows))
+	for _, r := range rows {
+		progress[r.AchievementID] = r
+	}
+
+	result := make([]*UserAchievementInfo, 0, len(list))
+	for _, a := range list {
+		info := &UserAchievementInfo{Achievement: a, PeriodKey: periodKeys[a.ID]}
+		if r := progress[a.ID]; r != nil {
+			info.Progress = r.Progress
+			info.Completed = r.CompletedAt != nil
+			info.CompletedAt = r.CompletedAt
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// ListUserBadges 获取用户已获得的徽章
+func (s *AchievementService) ListUserBadges(ctx context.Context, userID string) ([]*UserBadge, error) {
+	rows, err := model.ListUserBadges(s.db.WithContext(ctx), userID)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "获取徽章失败", err)
+	}
+	list, err := model.ListAchievements(s.db.WithContext(ctx), "", nil)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "获取成就列表失败", err)
+	}
+	byID := make(map[int64]*model.Achievement, len(list))
+	for _, a := range list {
+		byID[a.ID] = a
+	}
+
+	badges := make([]*UserBadge, 0, len(rows))
+	for _, r := range rows {
+		a := byID[r.AchievementID]
+		if a == nil || a.BadgeName == nil {
+			continue
+		}
+		badges = append(badges, &UserBadge{
+			AchievementID: a.ID,
+			Code:          a.Code,
+			Name:          *a.BadgeName,
+			Icon:          a.BadgeIcon,
+			PeriodKey:     r.PeriodKey,
+			AwardedAt:     *r.CompletedAt,
+		})
+	}
+	return badges, nil
+}
+
+// ListAchievements 获取成就定义列表，kind 为空时返回全部
+func (s *AchievementService) ListAchievements(ctx context.Context, kind string) ([]*model.Achievement, error) {
+	list, err := model.ListAchievements(s.db.WithContext(ctx), kind, nil)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "获取成就列表失败", err)
+	}
+	return list, nil
+}
+
+// GetAchievement 获取成就定义
+func (s *AchievementService) GetAchievement(ctx context.Context, id int64) (*model.Achievement, error) {
+	a, err := model.GetAchievement(s.db.WithContext(ctx), id)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New(errors.ErrCodeNotFound, "成就不存在", nil)
+		}
+		return nil, errors.New(errors.ErrCodeDatabaseError, "查询成就失败", err)
+	}
+	return a, nil
+}
+
+// CreateAchievement 创建成就定义
+func (s *AchievementService) CreateAchievement(ctx context.Context, req *AchievementRequest) (*model.Achievement, error) {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&model.Achievement{}).Where("code = ?", req.Code).Count(&count).Error; err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "检查成就编码失败", err)
+	}
+	if count > 0 {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "成就编码已存在", nil)
+	}
+
+	a := &model.Achievement{Code: req.Code}
+	applyAchievementRequest(a, req)
+	if err := model.CreateAchievement(s.db.WithContext(ctx), a); err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "创建成就失败", err)
+	}
+	audit.Record(ctx, "achievement", strconv.FormatInt(a.ID, 10), nil, a)
+	return a, nil
+}
+
+// UpdateAchievement 更新成就定义。修改目标值只影响尚未达成的进度，已达成的不会撤销
+func (s *AchievementService) UpdateAchievement(ctx context.Context, id int64, req *AchievementRequest) (*model.Achievement, error) {
+	before, err := s.GetAchievement(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Code != before.Code {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "成就编码不可修改", nil)
+	}
+	if req.EventType != before.EventType || req.Aggregate != before.Aggregate {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "事件类型和累计方式不可修改，请新建成就", nil)
+	}
+
+	a := *before
+	applyAchievementRequest(&a, req)
+	updates := map[string]interface{}{
+		"name":          a.Name,
+		"description":   a.Description,
+		"kind":          a.Kind,
+		"filter_key":    a.FilterKey,
+		"target":        a.Target,
+		"period":        a.Period,
+		"reward_tokens": a.RewardTokens,
+		"badge_name":    a.BadgeName,
+		"badge_icon":    a.BadgeIcon,
+		"sort":          a.Sort,
+		"status":        a.Status,
+	}
+	if err := model.UpdateAchievement(s.db.WithContext(ctx), id, updates); err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "更新成就失败", err)
+	}
+	after, _ := model.GetAchievement(s.db.WithContext(ctx), id)
+	audit.Record(ctx, "achievement", strconv.FormatInt(id, 10), before, after)
+	return after, nil
+}
+
+// DeleteAchievement 删除成就定义及用户进度，已发放的奖励不回收
+func (s *AchievementService) DeleteAchievement(ctx context.Context, id int64) error {
+	before, err := s.GetAchievement(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := model.DeleteAchievement(s.db.WithContext(ctx), id); err != nil {
+		return errors.New(errors.ErrCodeDatabaseError, "删除成就失败", err)
+	}
+	audit.Record(ctx, "achievement", strconv.FormatInt(id, 10), before, nil)
+	return nil
+}
+
+// applyAchievementRequest 将请求字段写入成就定义
+func applyAchievementRequest(a *model.Achievement, req *AchievementRequest) {
+	a.Name = req.Name
+	a.Kind = req.Kind
+	a.EventType = req.EventType
+	a.Aggregate = req.Aggregate
+	a.Target = req.Target
+	a.Period = req.Period
+	if a.Period == "" {
+		a.Period = model.AchievementPeriodNone
+	}
+	a.RewardTokens = req.RewardTokens
+	a.Sort = req.Sort
+	a.Status = *req.Status
+	a.Description = optionalString(req.Description)
+	a.FilterKey = optionalString(req.FilterKey)
+	a.BadgeName = optionalString(req.BadgeName)
+	a.BadgeIcon = optionalString(req.BadgeIcon)
+}
+
+// optionalString 空字符串返回 nil
+func optionalString(v string) *string {
+	if v == "" {
+		return nil
+	}
+	return &v
+}
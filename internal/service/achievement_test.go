@@ -0,0 +1,83 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+)
+
+func TestAchievementPeriodKey(t *testing.T) {
+	shanghai := mustLoadLocation(t, "Asia/Shanghai")
+	newYork := mustLoadLocation(t, "America/New_York")
+	// 2026-01-01 是周四，ISO 周属于 2026-W01；2027-01-01 是周五，属于 2026-W53
+	cases := []struct {
+		name   string
+		period string
+		now    time.Time
+		loc    *time.Location
+		want   string
+	}{
+		{"none", model.AchievementPeriodNone, time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC), shanghai, achievementPeriodAll},
+		{"unknown period", "yearly", time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC), shanghai, achievementPeriodAll},
+		{"daily in user timezone", model.AchievementPeriodDaily, time.Date(2026, 5, 1, 17, 0, 0, 0, time.UTC), shanghai, "2026-05-02"},
+		{"daily behind utc", model.AchievementPeriodDaily, time.Date(2026, 5, 1, 2, 0, 0, 0, time.UTC), newYork, "2026-04-30"},
+		{"weekly", model.AchievementPeriodWeekly, time.Date(2026, 5, 4, 1, 0, 0, 0, shanghai), shanghai, "2026-W19"},
+		{"weekly sunday belongs to previous week", model.AchievementPeriodWeekly, time.Date(2026, 5, 3, 23, 0, 0, 0, shanghai), shanghai, "2026-W18"},
+		{"weekly iso year start", model.AchievementPeriodWeekly, time.Date(2026, 1, 1, 12, 0, 0, 0, shanghai), shanghai, "2026-W01"},
+		{"weekly iso year end", model.AchievementPeriodWeekly, time.Date(2027, 1, 1, 12, 0, 0, 0, shanghai), shanghai, "2026-W53"},
+		{"monthly", model.AchievementPeriodMonthly, time.Date(2026, 5, 31, 16, 30, 0, 0, time.UTC), shanghai, "2026-06"},
+	}
+	for _, c := range cases {
+		if got := achievementPeriodKey(c.period, c.now, c.loc); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestAchievementProgress(t *testing.T) {
+	cases := []struct {
+		name                    string
+		progress, delta, target int64
+		want                    int64
+		completed               bool
+	}{
+		{"first event", 0, 1, 3, 1, false},
+		{"reaches target", 2, 1, 3, 3, true},
+		{"sum overshoots target", 80, 50, 100, 100, true},
+		{"single event completes", 0, 500, 100, 100, true},
+	}
+	for _, c := range cases {
+		got, completed := achievementProgress(c.progress, c.delta, c.target)
+		if got != c.want || completed != c.completed {
+			t.Errorf("%s: got %d/%v, want %d/%v", c.name, got, completed, c.want, c.completed)
+		}
+	}
+}
+
+func TestApplyAchievementRequest(t *testing.T) {
+	status := int8(1)
+	a := &model.Achievement{Code: "first_order"}
+	applyAchievementRequest(a, &AchievementRequest{
+		Code:      "first_order",
+		Name:      "首单",
+		Kind:      model.AchievementKindAchievement,
+		EventType: model.AchievementEventOrderPaid,
+		Aggregate: model.AchievementAggregateCount,
+		Target:    1,
+		BadgeName: "新手买家",
+		Status:    &status,
+	})
+	if a.Period != model.AchievementPeriodNone {
+		t.Errorf("period = %q, want %q", a.Period, model.AchievementPeriodNone)
+	}
+	if a.Description != nil || a.FilterKey != nil || a.BadgeIcon != nil {
+		t.Errorf("empty optional fields should be nil: %+v", a)
+	}
+	if a.BadgeName == nil || *a.BadgeName != "新手买家" {
+		t.Errorf("badge name = %v", a.BadgeName)
+	}
+	if a.Status != 1 || a.Target != 1 || a.Name != "首单" {
+		t.Errorf("unexpected achievement %+v", a)
+	}
+}
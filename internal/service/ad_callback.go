@@ -0,0 +1,427 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/config"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/logs"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	adCallbackLockPrefix     = "ad_callback_lock:"
+	adCallbackLockTTL        = 30 * time.Second
+	adMobKeysRefreshInterval = time.Minute // 遇到未知 key_id 时重新拉取公钥的最小间隔
+)
+
+// adRewardCallback 已验签的广告回调
+type adRewardCallback struct {
+	Network       string
+	TransactionID string
+	UserID        string
+	TaskKey       string // 回调自定义参数中的任务标识，为空时使用平台配置的默认任务
+	RawQuery      string
+}
+
+// ParseAdMobVerifierKeys 解析 AdMob 验签公钥列表，格式为 {"keys":[{"keyId":..,"base64":..}]}
+func ParseAdMobVerifierKeys(data []byte) (map[string]*ecdsa.PublicKey, error) {
+	var payload struct {
+		Keys []struct {
+			KeyID  json.Number `json:"keyId"`
+			Base64 string      `json:"base64"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("decode verifier keys: %w", err)
+	}
+
+	keys := make(map[string]*ecdsa.PublicKey, len(payload.Keys))
+	for _, k := range payload.Keys {
+		der, err := base64.StdEncoding.DecodeString(k.Base64)
+		if err != nil {
+			return nil, fmt.Errorf("decode key %s: %w", k.KeyID, err)
+		}
+		pub, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("parse key %s: %w", k.KeyID, err)
+		}
+		ecKey, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("key %s is not an ECDSA key", k.KeyID)
+		}
+		keys[k.KeyID.String()] = ecKey
+	}
+	return keys, nil
+}
+
+// VerifyAdMobSignature 校验 AdMob SSV 回调签名。签名内容为原始查询串中 &signature= 之前的部分，
+// signature 为 URL 安全的 base64 编码的 DER 格式 ECDSA-SHA256 签名，key_id 指定所用公钥
+func VerifyAdMobSignature(rawQuery string, keys map[string]*ecdsa.PublicKey) error {
+	idx := strings.Index(rawQuery, "&signature=")
+	if idx < 0 {
+		return fmt.Errorf("signature missing")
+	}
+	message := rawQuery[:idx]
+
+	params, err := url.ParseQuery(rawQuery[idx+1:])
+	if err != nil {
+		return fmt.Errorf("parse signature params: %w", err)
+	}
+	keyID := params.Get("key_id")
+	pub, ok := keys[keyID]
+	if !ok {
+		return fmt.Errorf("unknown key_id %q", keyID)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(params.Get("signature"), "="))
+	if err != nil {
+		return fmt.Errorf("decode signature: %w", err)
+	}
+
+	digest := sha256.Sum256([]byte(message))
+	if !ecdsa.VerifyASN1(pub, digest[:], sig) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// PangleSign 计算穿山甲奖励回调签名：sha256(secret:trans_id) 的十六进制
+func PangleSign(secret, transID string) string {
+	sum := sha256.Sum256([]byte(secret + ":" + transID))
+	return hex.EncodeToString(sum[:])
+}
+
+// YLHSign 计算优量汇奖励回调签名：sha256(transid:secret) 的十六进制
+func YLHSign(secret, transID string) string {
+	sum := sha256.Sum256([]byte(transID + ":" + secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// signEqual 不区分大小写地比较十六进制签名
+func signEqual(expected, actual string) bool {
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(actual))) == 1
+}
+
+// AdCallbackService 激励视频广告服务端回调服务
+type AdCallbackService struct {
+	db         *gorm.DB
+	redis      *redis.Client
+	config     *config.Config
+	tasks      *TaskService
+	notifier   *NotificationService
+	httpClient *http.Client
+
+	keysMu           sync.Mutex
+	adMobKeys        map[string]*ecdsa.PublicKey
+	adMobKeysAt      time.Time
+	adMobKeysTriedAt time.Time
+}
+
+// NewAdCallbackService 创建激励视频广告回调服务
+func NewAdCallbackService(db *gorm.DB, redis *redis.Client, cfg *config.Config, tasks *TaskService, notifier *NotificationService) *AdCallbackService {
+	return &AdCallbackService{
+		db:         db,
+		redis:      redis,
+		config:     cfg,
+		tasks:      tasks,
+		notifier:   notifier,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Enabled 平台的回调是否启用
+func (s *AdCallbackService) Enabled(network string) bool {
+	switch network {
+	case model.AdNetworkAdMob:
+		return s.config.AdCallback.AdMob.Enabled
+	case model.AdNetworkPangle:
+		return s.config.AdCallback.Pangle.Enabled
+	case model.AdNetworkYLH:
+		return s.config.AdCallback.YLH.Enabled
+	default:
+		return false
+	}
+}
+
+// HandleAdMob 处理 AdMob 回调，rawQuery 必须是未经重新编码的原始查询串。
+// AdMob 后台验证回调地址时发送的请求没有交易号，验签通过后返回 nil
+func (s *AdCallbackService) HandleAdMob(ctx context.Context, rawQuery string) (*model.AdRewardCallback, error) {
+	keys, err := s.adMobVerifierKeys(ctx, rawQuery)
+	if err != nil {
+		return nil, err
+	}
+	if err := VerifyAdMobSignature(rawQuery, keys); err != nil {
+		return nil, errors.New(errors.ErrCodeForbidden, "回调签名校验失败", err)
+	}
+
+	params, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "无效的回调参数", err)
+	}
+	if params.Get("transaction_id") == "" {
+		return nil, nil
+	}
+	return s.handle(ctx, &adRewardCallback{
+		Network:       model.AdNetworkAdMob,
+		TransactionID: params.Get("transaction_id"),
+		UserID:        params.Get("user_id"),
+		TaskKey:       params.Get("custom_data"),
+		RawQuery:      rawQuery,
+	})
+}
+
+// HandlePangle 处理穿山甲回调
+func (s *AdCallbackService) HandlePangle(ctx context.Context, rawQuery string) (*model.AdRewardCallback, error) {
+	params, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "无效的回调参数", err)
+	}
+	transID := params.Get("trans_id")
+	if transID == "" || !signEqual(PangleSign(s.config.AdCallback.Pangle.Secret, transID), params.Get("sign")) {
+		return nil, errors.New(errors.ErrCodeForbidden, "回调签名校验失败", nil)
+	}
+	return s.handle(ctx, &adRewardCallback{
+		Network:       model.AdNetworkPangle,
+		TransactionID: transID,
+		UserID:        params.Get("user_id"),
+		TaskKey:       params.Get("extra"),
+		RawQuery:      rawQuery,
+	})
+}
+
+// HandleYLH 处理优量汇回调
+func (s *AdCallbackService) HandleYLH(ctx context.Context, rawQuery string) (*model.AdRewardCallback, error) {
+	params, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "无效的回调参数", err)
+	}
+	transID := params.Get("transid")
+	if transID == "" || !signEqual(YLHSign(s.config.AdCallback.YLH.Secret, transID), params.Get("sig")) {
+		return nil, errors.New(errors.ErrCodeForbidden, "回调签名校验失败", nil)
+	}
+	return s.handle(ctx, &adRewardCallback{
+		Network:       model.AdNetworkYLH,
+		TransactionID: transID,
+		UserID:        params.Get("userid"),
+		TaskKey:       params.Get("extrainfo"),
+		RawQuery:      rawQuery,
+	})
+}
+
+// defaultTaskKey 平台配置的默认任务标识
+func (s *AdCallbackService) defaultTaskKey(network string) string {
+	switch network {
+	case model.AdNetworkAdMob:
+		return s.config.AdCallback.AdMob.TaskKey
+	case model.AdNetworkPangle:
+		return s.config.AdCallback.Pangle.TaskKey
+	case model.AdNetworkYLH:
+		return s.config.AdCallback.YLH.TaskKey
+	default:
+		return ""
+	}
+}
+
+// handle 按交易号去重后匹配用户和任务并发放奖励。验签通过但不满足发放条件时记为 rejected，
+// 平台重试同一交易号时直接返回已有记录
+func (s *AdCallbackService) handle(ctx context.Context, in *adRewardCallback) (*model.AdRewardCallback, error) {
+	if len(in.TransactionID) > 128 {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "无效的交易号", nil)
+	}
+
+	lockKey := fmt.Sprintf("%s%s:%s", adCallbackLockPrefix, in.Network, in.TransactionID)
+	acquired, err := s.redis.SetNX(ctx, lockKey, "1", adCallbackLockTTL).Result()
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "获取回调锁失败", err)
+	}
+	if !acquired {
+		return nil, errors.New(errors.ErrCodeTooManyRequests, "回调正在处理中，请稍后重试", nil)
+	}
+	defer s.redis.Del(ctx, lockKey)
+
+	existing, err := model.GetAdRewardCallback(s.db.WithContext(ctx), in.Network, in.TransactionID)
+	if err == nil {
+		return existing, nil
+	}
+	if !stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New(errors.ErrCodeInternal, "获取回调记录失败", err)
+	}
+
+	cb := &model.AdRewardCallback{
+		Network:       in.Network,
+		TransactionID: in.TransactionID,
+		UserID:        truncateRunes(in.UserID, 13),
+		RawQuery:      in.RawQuery,
+	}
+
+	task, reason := s.matchTask(ctx, in)
+	if task != nil {
+		cb.TaskID = &task.TaskID
+	}
+
+	tx := s.db.WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "开启事务失败", tx.Error)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if reason == "" {
+		reason, err = s.grant(ctx, tx, in.UserID, task)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if reason == "" {
+		cb.Status = model.AdCallbackGranted
+		cb.Reward = task.TokenReward
+	} else {
+		cb.Status = model.AdCallbackRejected
+		cb.Reason = model.StringPtr(truncateRunes(reason, 255))
+	}
+	if err := model.CreateAdRewardCallback(tx, cb); err != nil {
+		tx.Rollback()
+		return nil, errors.New(errors.ErrCodeInternal, "保存回调记录失败", err)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "提交事务失败", err)
+	}
+
+	logs.Business().Info("激励视频广告回调处理完成",
+		zap.String("network", cb.Network),
+		zap.String("transaction_id", cb.TransactionID),
+		zap.String("user_id", cb.UserID),
+		zap.String("status", cb.Status),
+	)
+
+	if cb.Status == model.AdCallbackGranted {
+		s.notifier.NotifyTaskReward(context.Background(), in.UserID, task)
+		s.tasks.trackTaskCompletion(in.UserID, task)
+	}
+	return cb, nil
+}
+
+// matchTask 匹配回调对应的任务，只接受 rewarded_ad 类的任务，不匹配时返回原因
+func (s *AdCallbackService) matchTask(ctx context.Context, in *adRewardCallback) (*model.RewardTask, string) {
+	taskKey := in.TaskKey
+	if taskKey == "" {
+		taskKey = s.defaultTaskKey(in.Network)
+	}
+	if taskKey != TaskKeyRewardedAd && !strings.HasPrefix(taskKey, TaskKeyRewardedAd+":") {
+		return nil, fmt.Sprintf("任务标识 %q 不是激励视频任务", truncateRunes(taskKey, 50))
+	}
+	task, err := s.tasks.GetTaskByKey(ctx, taskKey)
+	if err != nil {
+		return nil, "任务不存在"
+	}
+	return task, ""
+}
+
+// grant 检查用户后发放任务奖励，不满足发放条件时返回原因，其他错误返回 error 由平台重试
+func (s *AdCallbackService) grant(ctx context.Context, tx *gorm.DB, userID string, task *model.RewardTask) (string, error) {
+	var user model.User
+	if err := tx.Where("id = ?", userID).First(&user).Error; err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return "用户不存在", nil
+		}
+		return "", errors.New(errors.ErrCodeInternal, "获取用户信息失败", err)
+	}
+	if user.Status != 1 {
+		return "用户账号已被禁用", nil
+	}
+
+	// 在保存点内发放，不满足任务条件时只回滚发放部分，回调记录仍然写入
+	// 保存点创建失败时无法只回滚发放部分，整个回调失败由平台重试
+	if err := tx.SavePoint("ad_reward").Error; err != nil {
+		return "", errors.New(errors.ErrCodeInternal, "创建保存点失败", err)
+	}
+	if err := s.tasks.GrantVerifiedCompletion(ctx, tx, userID, task); err != nil {
+		var bizErr *errors.Error
+		if stderrors.As(err, &bizErr) && bizErr.Code != errors.ErrCodeInternal {
+			if err := tx.RollbackTo("ad_reward").Error; err != nil {
+				return "", errors.New(errors.ErrCodeInternal, "回滚保存点失败", err)
+			}
+			return bizErr.Message, nil
+		}
+		return "", err
+	}
+	return "", nil
+}
+
+// adMobVerifierKeys 获取 AdMob 验签公钥，缓存过期或回调使用了未知 key_id 时重新拉取
+func (s *AdCallbackService) adMobVerifierKeys(ctx context.Context, rawQuery string) (map[string]*ecdsa.PublicKey, error) {
+	keyID := ""
+	if idx := strings.Index(rawQuery, "&signature="); idx >= 0 {
+		if params, err := url.ParseQuery(rawQuery[idx+1:]); err == nil {
+			keyID = params.Get("key_id")
+		}
+	}
+
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+
+	now := time.Now()
+	_, known := s.adMobKeys[keyID]
+	expired := now.Sub(s.adMobKeysAt) > s.config.AdCallback.AdMob.KeysTTL
+	if s.adMobKeys != nil && !expired && (known || now.Sub(s.adMobKeysTriedAt) < adMobKeysRefreshInterval) {
+		return s.adMobKeys, nil
+	}
+
+	s.adMobKeysTriedAt = now
+	keys, err := s.fetchAdMobKeys(ctx)
+	if err != nil {
+		if s.adMobKeys != nil {
+			// 拉取失败时继续使用旧公钥
+			logs.Business().Warn("拉取AdMob验签公钥失败", zap.Error(err))
+			return s.adMobKeys, nil
+		}
+		return nil, errors.New(errors.ErrCodeServiceUnavailable, "获取验签公钥失败", err)
+	}
+	s.adMobKeys = keys
+	s.adMobKeysAt = now
+	return keys, nil
+}
+
+// fetchAdMobKeys 从 AdMob 拉取验签公钥
+func (s *AdCallbackService) fetchAdMobKeys(ctx context.Context) (map[string]*ecdsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.config.AdCallback.AdMob.KeysURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	return ParseAdMobVerifierKeys(data)
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// signAdMobQuery 用测试私钥按 AdMob 的格式给查询串追加签名
+func signAdMobQuery(t *testing.T, key *ecdsa.PrivateKey, keyID, query string) string {
+	t.Helper()
+	digest := sha256.Sum256([]byte(query))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return query + "&signature=" + base64.RawURLEncoding.EncodeToString(sig) + "&key_id=" + keyID
+}
+
+func newAdMobTestKeys(t *testing.T) (*ecdsa.PrivateKey, map[string]*ecdsa.PublicKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]interface{}{{"keyId": 3335741209, "base64": base64.StdEncoding.EncodeToString(der)}},
+	})
+	keys, err := ParseAdMobVerifierKeys(data)
+	if err != nil {
+		t.Fatalf("parse keys: %v", err)
+	}
+	return key, keys
+}
+
+func TestVerifyAdMobSignature(t *testing.T) {
+	key, keys := newAdMobTestKeys(t)
+	query := "ad_network=5450213213286189855&ad_unit=1234567890&custom_data=rewarded_ad&reward_amount=1&reward_item=coins&timestamp=1507770365237823&transaction_id=18fa792de1bca816048293fc71035638&user_id=u123"
+	signed := signAdMobQuery(t, key, "3335741209", query)
+
+	if err := VerifyAdMobSignature(signed, keys); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+
+	tampered := strings.Replace(signed, "user_id=u123", "user_id=u124", 1)
+	if err := VerifyAdMobSignature(tampered, keys); err == nil {
+		t.Fatal("tampered query accepted")
+	}
+
+	unknownKey := strings.Replace(signed, "key_id=3335741209", "key_id=1", 1)
+	if err := VerifyAdMobSignature(unknownKey, keys); err == nil {
+		t.Fatal("unknown key_id accepted")
+	}
+
+	otherKey, _ := newAdMobTestKeys(t)
+	if err := VerifyAdMobSignature(signAdMobQuery(t, otherKey, "3335741209", query), keys); err == nil {
+		t.Fatal("signature from another key accepted")
+	}
+
+	if err := VerifyAdMobSignature(query, keys); err == nil {
+		t.Fatal("unsigned query accepted")
+	}
+}
+
+func TestPangleAndYLHSign(t *testing.T) {
+	const secret = "test-secret"
+
+	sum := sha256.Sum256([]byte(secret + ":tx1"))
+	if got := PangleSign(secret, "tx1"); got != hex.EncodeToString(sum[:]) {
+		t.Fatalf("pangle sign mismatch: %s", got)
+	}
+	sum = sha256.Sum256([]byte("tx1:" + secret))
+	if got := YLHSign(secret, "tx1"); got != hex.EncodeToString(sum[:]) {
+		t.Fatalf("ylh sign mismatch: %s", got)
+	}
+
+	if !signEqual(PangleSign(secret, "tx1"), strings.ToUpper(PangleSign(secret, "tx1"))) {
+		t.Fatal("upper-case sign rejected")
+	}
+	if signEqual(PangleSign(secret, "tx1"), PangleSign(secret, "tx2")) {
+		t.Fatal("sign for another transaction accepted")
+	}
+	if signEqual(PangleSign(secret, "tx1"), PangleSign("other-secret", "tx1")) {
+		t.Fatal("sign with another secret accepted")
+	}
+}
@@ -3,27 +3,36 @@ package service
 import (
 	"context"
 	stderrors "errors"
-	"strings"
+	"strconv"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/audit"
+	"github.com/reusedev/uportal-api/pkg/config"
 	"github.com/reusedev/uportal-api/pkg/errors"
 	"github.com/reusedev/uportal-api/pkg/jwt"
-	"github.com/reusedev/uportal-api/pkg/logs"
-	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 // AdminService 管理员服务
 type AdminService struct {
-	db *gorm.DB
+	db     *gorm.DB
+	redis  *redis.Client
+	cfg    *config.Config
+	policy *PasswordPolicy
+	roles  *RoleService
 }
 
 // NewAdminService 创建管理员服务
-func NewAdminService(db *gorm.DB) *AdminService {
+func NewAdminService(db *gorm.DB, redis *redis.Client, cfg *config.Config, roles *RoleService) *AdminService {
 	return &AdminService{
-		db: db,
+		db:     db,
+		redis:  redis,
+		cfg:    cfg,
+		policy: NewPasswordPolicy(cfg),
+		roles:  roles,
 	}
 }
 
@@ -33,30 +42,42 @@ type SortParam struct {
 	Order string // 排序方向：asc 或 desc
 }
 
+// UserFilter 用户筛选条件，列表与导出共用
+type UserFilter struct {
+	NickName string  `json:"nickname"`
+	Phone    string  `json:"phone"`
+	Status   *int    `json:"status"`
+	TagIDs   []int64 `json:"tag_ids"` // 用户需同时拥有这些标签
+}
+
+func (f *UserFilter) apply(db *gorm.DB) *gorm.DB {
+	if f.NickName != "" {
+		db = db.Where("nickname LIKE ?", "%"+f.NickName+"%")
+	}
+	if f.Phone != "" {
+		db = db.Where("phone LIKE ?", "%"+f.Phone+"%")
+	}
+	if f.Status != nil {
+		db = db.Where("status = ?", *f.Status)
+	}
+	for _, tagID := range f.TagIDs {
+		db = db.Where("id IN (SELECT user_id FROM user_tag_members WHERE tag_id = ?)", tagID)
+	}
+	return db
+}
+
 // ListUsersParams 获取用户列表参数
 type ListUsersParams struct {
-	Page     int
-	Limit    int
-	NickName string
-	Phone    string
-	Status   *int
-	Sort     []SortParam // 排序参数
+	Page  int
+	Limit int
+	UserFilter
+	Sort []SortParam // 排序参数
 }
 
 // ListUsers 获取用户列表
 func (s *AdminService) ListUsers(ctx context.Context, params *ListUsersParams) ([]*model.User, int64, error) {
-	query := s.db.Model(&model.User{})
-
 	// 添加查询条件
-	if params.NickName != "" {
-		query = query.Where("nickname LIKE ?", "%"+params.NickName+"%")
-	}
-	if params.Phone != "" {
-		query = query.Where("phone LIKE ?", "%"+params.Phone+"%")
-	}
-	if params.Status != nil {
-		query = query.Where("status = ?", *params.Status)
-	}
+	query := params.UserFilter.apply(s.db.Model(&model.User{}))
 
 	// 添加排序
 	for _, sort := range params.Sort {
@@ -127,13 +148,19 @@ func (s *AdminService) UpdateUser(ctx context.Context, id string, updates map[st
 		return 0, errors.New(errors.ErrCodeInternal, "Failed to update user", err)
 	}
 
+	// 重新读取变更后的数据用于审计，读取失败时审计只保留变更前的数据
+	after, _ := model.GetUserByID(s.db, id)
+	audit.Record(ctx, "user", id, user, after)
+
 	return user.TokenBalance, nil
 }
 
-func (s *AdminService) CreateTokenRecord(id, remark string, amount, tokenBalance int) (error, int64) {
+// CreateTokenRecord 记录管理员调整代币的流水，adminID 为操作人
+func (s *AdminService) CreateTokenRecord(adminID int64, id, remark string, amount, tokenBalance int) (error, int64) {
 	// 创建Token记录
 	record := &model.TokenRecord{
 		UserID:       id,
+		AdminID:      &adminID,
 		ChangeAmount: amount,
 		BalanceAfter: tokenBalance,
 		ChangeType:   "ADJUST",
@@ -144,6 +171,42 @@ func (s *AdminService) CreateTokenRecord(id, remark string, amount, tokenBalance
 	return err, record.RecordID
 }
 
+// ImpersonationResult 模拟用户令牌
+type ImpersonationResult struct {
+	Token     string    `json:"token"`      // 只读用户令牌，用于调用用户端接口
+	SessionID string    `json:"session_id"` // 会话ID，审计日志中用于关联每次访问
+	UserID    string    `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ImpersonateUser 为管理员签发模拟指定用户的短期只读令牌，令牌只能访问用户端只读接口
+func (s *AdminService) ImpersonateUser(ctx context.Context, adminID int64, adminName, userID, reason string) (*ImpersonationResult, error) {
+	if _, err := model.GetUserByID(s.db.WithContext(ctx), userID); err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New(errors.ErrCodeNotFound, "用户不存在", err)
+		}
+		return nil, errors.New(errors.ErrCodeDatabaseError, "查询用户失败", err)
+	}
+
+	ttl := s.cfg.AdminSecurity.ImpersonationTTL
+	token, sessionID, err := jwt.GenerateImpersonationToken(userID, adminID, adminName, ttl)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "生成模拟令牌失败", err)
+	}
+	result := &ImpersonationResult{
+		Token:     token,
+		SessionID: sessionID,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	audit.Record(ctx, "user", userID, nil, map[string]interface{}{
+		"session_id": sessionID,
+		"reason":     reason,
+		"expires_at": result.ExpiresAt,
+	})
+	return result, nil
+}
+
 // DeleteUser 删除用户
 func (s *AdminService) DeleteUser(ctx context.Context, id string) error {
 	// 检查用户是否存在
@@ -158,34 +221,45 @@ func (s *AdminService) DeleteUser(ctx context.Context, id string) error {
 	return nil
 }
 
-// ResetPassword 重置用户密码
-func (s *AdminService) ResetPassword(ctx context.Context, id int64, password, oldPassword string) error {
+// ResetPassword 修改管理员密码，成功后清除强制改密标记并签发新令牌
+func (s *AdminService) ResetPassword(ctx context.Context, id int64, password, oldPassword string) (*AdminLoginResult, error) {
+	audit.Record(ctx, "admin", strconv.FormatInt(id, 10), nil, nil)
+
 	// 检查用户是否存在
 	adminUser, err := model.GetAdinUserByID(s.db, id)
 	if err != nil {
-		return errors.New(errors.ErrCodeNotFound, "User not found", err)
+		return nil, errors.New(errors.ErrCodeNotFound, "User not found", err)
 	}
 
 	// 验证密码
 	err = bcrypt.CompareHashAndPassword([]byte(adminUser.PasswordHash), []byte(oldPassword))
 	if err != nil {
-		return errors.New(errors.ErrCodeUnauthorized, "用户名或密码错误", err)
+		return nil, errors.New(errors.ErrCodeUnauthorized, "用户名或密码错误", err)
+	}
+	if password == oldPassword || password == defaultAdminPassword {
+		return nil, errors.New(errors.ErrCodeWeakPassword, "新密码不能与原密码或默认密码相同", nil)
 	}
 
 	// 加密密码
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := s.policy.Hash(password)
 	if err != nil {
-		return errors.New(errors.ErrCodeInternal, "Failed to encrypt password", err)
+		return nil, err
 	}
 
 	// 更新密码
+	now := time.Now()
 	if err = model.UpdateAdminUser(s.db, id, map[string]interface{}{
-		"password_hash": string(hashedPassword),
+		"password_hash":        hashedPassword,
+		"must_change_password": false,
+		"password_changed_at":  now,
 	}); err != nil {
-		return errors.New(errors.ErrCodeInternal, "Failed to reset password", err)
+		return nil, errors.New(errors.ErrCodeInternal, "Failed to reset password", err)
 	}
 
-	return nil
+	adminUser.PasswordHash = hashedPassword
+	adminUser.MustChangePassword = false
+	adminUser.PasswordChangedAt = &now
+	return s.issueToken(adminUser, false)
 }
 
 // ListAdminUsersParams 获取管理员列表参数
@@ -243,61 +317,64 @@ type AdminLoginRequest struct {
 type CreateAdminRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=32"`
 	Password string `json:"password" binding:"required,min=6,max=32"`
-	Role     string `json:"role" binding:"required,oneof=admin super_admin"`
+	Role     string `json:"role" binding:"required,max=20"`
 	Status   *int8  `json:"status" binding:"omitempty,oneof=0 1"`
 }
 
 // UpdateAdminRequest 更新管理员请求
 type UpdateAdminRequest struct {
 	UserName string `json:"username" binding:"required,min=3,max=32"`
-	Role     string `json:"role" binding:"omitempty,oneof=admin super_admin"`
+	Role     string `json:"role" binding:"omitempty,max=20"`
 	Status   *int8  `json:"status" binding:"omitempty,oneof=0 1"`
 }
 
 // Login 管理员登录
-func (s *AdminService) Login(ctx context.Context, req *AdminLoginRequest) (*model.AdminUser, string, error) {
+// 已启用2FA时只返回 ChallengeToken，需调用 VerifyLoginTwoFactor 完成登录；
+// 使用默认密码或被要求改密、角色强制2FA但未绑定时返回受限令牌
+func (s *AdminService) Login(ctx context.Context, req *AdminLoginRequest) (*AdminLoginResult, error) {
+	if err := s.checkLoginLocked(ctx, req.Username, req.IP); err != nil {
+		return nil, err
+	}
+
 	var admin model.AdminUser
 	err := s.db.Where("username = ?", req.Username).First(&admin).Error
 	if err != nil {
 		if stderrors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, "", errors.New(errors.ErrCodeUnauthorized, "用户名或密码错误", nil)
+			if lockErr := s.recordLoginFailure(ctx, req.Username, req.IP); lockErr != nil {
+				return nil, lockErr
+			}
+			return nil, errors.New(errors.ErrCodeUnauthorized, "用户名或密码错误", nil)
 		}
-		return nil, "", errors.New(errors.ErrCodeInternal, "查询管理员失败", err)
+		return nil, errors.New(errors.ErrCodeInternal, "查询管理员失败", err)
 	}
 
 	// 验证密码
 	err = bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte(req.Password))
 	if err != nil {
-		return nil, "", errors.New(errors.ErrCodeUnauthorized, "用户名或密码错误", nil)
+		if lockErr := s.recordLoginFailure(ctx, req.Username, req.IP); lockErr != nil {
+			return nil, lockErr
+		}
+		return nil, errors.New(errors.ErrCodeUnauthorized, "用户名或密码错误", nil)
 	}
 
 	// 检查状态
 	if admin.Status != 1 {
-		return nil, "", errors.New(errors.ErrCodeForbidden, "账号已被禁用", nil)
+		return nil, errors.New(errors.ErrCodeForbidden, "账号已被禁用", nil)
 	}
 
-	// 生成JWT token
-	token, err := jwt.GenerateToken(int64(admin.AdminID), strings.Contains(admin.Role, "super"),
-		admin.Username, req.Password, admin.Role)
-	if err != nil {
-		return nil, "", errors.New(errors.ErrCodeInternal, "生成token失败", err)
+	defaultPassword := req.Password == defaultAdminPassword
+	if admin.TwoFactorEnabled {
+		return s.startLoginChallenge(ctx, &admin, req.IP, defaultPassword)
 	}
+	return s.completeLogin(ctx, &admin, req.IP, defaultPassword)
+}
 
-	// 更新最后登录时间
-	now := time.Now()
-	if err := s.db.Model(&admin).Update("last_login_at", now).Error; err != nil {
-		// 仅记录错误，不影响登录流程
-		logs.Business().Warn("更新管理员最后登录时间失败",
-			zap.Int("admin_id", admin.AdminID),
-			zap.Error(err),
-		)
+// CreateAdmin 创建管理员，操作人只能分配不超出自身权限的角色
+func (s *AdminService) CreateAdmin(ctx context.Context, operatorID int64, req *CreateAdminRequest) (*model.AdminUser, error) {
+	if err := s.roles.CanAssignRole(ctx, operatorID, req.Role); err != nil {
+		return nil, err
 	}
 
-	return &admin, token, nil
-}
-
-// CreateAdmin 创建管理员
-func (s *AdminService) CreateAdmin(ctx context.Context, req *CreateAdminRequest) (*model.AdminUser, error) {
 	// 检查用户名是否已存在
 	var count int64
 	if err := s.db.Model(&model.AdminUser{}).Where("username = ?", req.Username).Count(&count).Error; err != nil {
@@ -308,28 +385,34 @@ func (s *AdminService) CreateAdmin(ctx context.Context, req *CreateAdminRequest)
 	}
 
 	// 生成密码哈希
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	passwordHash, err := s.policy.Hash(req.Password)
 	if err != nil {
-		return nil, errors.New(errors.ErrCodeInternal, "生成密码哈希失败", err)
+		return nil, err
+	}
+
+	status := int8(1)
+	if req.Status != nil {
+		status = *req.Status
 	}
 
 	// 创建管理员
 	admin := &model.AdminUser{
 		Username:     req.Username,
-		PasswordHash: string(passwordHash),
+		PasswordHash: passwordHash,
 		Role:         req.Role,
-		Status:       *req.Status,
+		Status:       status,
 	}
 
 	if err := s.db.Create(admin).Error; err != nil {
 		return nil, errors.New(errors.ErrCodeInternal, "创建管理员失败", err)
 	}
+	audit.Record(ctx, "admin", strconv.Itoa(admin.AdminID), nil, admin)
 
 	return admin, nil
 }
 
 // UpdateAdmin 更新管理员信息
-func (s *AdminService) UpdateAdmin(ctx context.Context, id string, req *UpdateAdminRequest) error {
+func (s *AdminService) UpdateAdmin(ctx context.Context, operatorID int64, id string, req *UpdateAdminRequest) error {
 	// 检查管理员是否存在
 	var admin model.AdminUser
 	if err := s.db.First(&admin, id).Error; err != nil {
@@ -339,10 +422,27 @@ func (s *AdminService) UpdateAdmin(ctx context.Context, id string, req *UpdateAd
 		return errors.New(errors.ErrCodeInternal, "查询管理员失败", err)
 	}
 
-	// 不允许修改超级管理员的角色
-	//if admin.Role == "super_admin" && req.Role != "" && req.Role != "super_admin" {
-	//	return errors.New(errors.ErrCodeForbidden, "不能修改超级管理员的角色", nil)
-	//}
+	// 只能管理权限不高于自己的管理员
+	if err := s.roles.CanAssignRole(ctx, operatorID, admin.Role); err != nil {
+		return err
+	}
+
+	self := int64(admin.AdminID) == operatorID
+	roleChanged := req.Role != "" && req.Role != admin.Role
+	disabling := req.Status != nil && *req.Status != 1 && admin.Status == 1
+	if self && (roleChanged || disabling) {
+		return errors.New(errors.ErrCodeForbidden, "不能修改自己的角色或停用自己", nil)
+	}
+	if roleChanged {
+		if err := s.roles.CanAssignRole(ctx, operatorID, req.Role); err != nil {
+			return err
+		}
+	}
+	if roleChanged || disabling {
+		if err := s.ensureOtherSuperAdmin(&admin); err != nil {
+			return err
+		}
+	}
 
 	updates := make(map[string]interface{})
 	if req.UserName != "" {
@@ -355,15 +455,17 @@ func (s *AdminService) UpdateAdmin(ctx context.Context, id string, req *UpdateAd
 		updates["status"] = *req.Status
 	}
 
+	before := admin
 	if err := s.db.Model(&admin).Updates(updates).Error; err != nil {
 		return errors.New(errors.ErrCodeInternal, "更新管理员失败", err)
 	}
+	audit.Record(ctx, "admin", strconv.Itoa(admin.AdminID), &before, &admin)
 
 	return nil
 }
 
 // DeleteAdmin 删除管理员
-func (s *AdminService) DeleteAdmin(ctx context.Context, id string) error {
+func (s *AdminService) DeleteAdmin(ctx context.Context, operatorID int64, id string) error {
 	// 检查管理员是否存在
 	var admin model.AdminUser
 	if err := s.db.First(&admin, id).Error; err != nil {
@@ -373,14 +475,44 @@ func (s *AdminService) DeleteAdmin(ctx context.Context, id string) error {
 		return errors.New(errors.ErrCodeInternal, "查询管理员失败", err)
 	}
 
-	// 不允许删除超级管理员
-	if admin.Role == "super_admin" {
-		return errors.New(errors.ErrCodeForbidden, "不能删除超级管理员", nil)
+	if int64(admin.AdminID) == operatorID {
+		return errors.New(errors.ErrCodeForbidden, "不能删除自己", nil)
+	}
+	// 只能删除权限不高于自己的管理员，且至少保留一个可用的超级管理员
+	if err := s.roles.CanAssignRole(ctx, operatorID, admin.Role); err != nil {
+		return err
+	}
+	if err := s.ensureOtherSuperAdmin(&admin); err != nil {
+		return err
 	}
 
 	if err := s.db.Delete(&admin).Error; err != nil {
 		return errors.New(errors.ErrCodeInternal, "删除管理员失败", err)
 	}
+	audit.Record(ctx, "admin", strconv.Itoa(admin.AdminID), &admin, nil)
+
+	return nil
+}
 
+// GetPermissions 获取管理员当前拥有的权限，供前端控制菜单展示
+func (s *AdminService) GetPermissions(ctx context.Context, adminID int64) ([]string, error) {
+	return s.roles.adminPermissions(adminID)
+}
+
+// ensureOtherSuperAdmin 目标是超级管理员时，确认除它之外还有可用的超级管理员
+func (s *AdminService) ensureOtherSuperAdmin(admin *model.AdminUser) error {
+	if admin.Role != model.RoleSuperAdmin || admin.Status != 1 {
+		return nil
+	}
+	var count int64
+	err := s.db.Model(&model.AdminUser{}).
+		Where("role = ? AND status = 1 AND admin_id <> ?", model.RoleSuperAdmin, admin.AdminID).
+		Count(&count).Error
+	if err != nil {
+		return errors.New(errors.ErrCodeInternal, "查询超级管理员失败", err)
+	}
+	if count == 0 {
+		return errors.New(errors.ErrCodeForbidden, "至少需要保留一个可用的超级管理员", nil)
+	}
 	return nil
 }
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/audit"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// bootstrapPasswordLength 自动生成的初始密码长度
+const bootstrapPasswordLength = 20
+
+// CreateInvitationRequest 创建管理员邀请请求
+type CreateInvitationRequest struct {
+	Username string
+	Role     string
+	Note     *string
+}
+
+// InvitationResult 创建邀请的结果，令牌明文只在此时返回一次
+type InvitationResult struct {
+	Invitation *model.AdminInvitation `json:"invitation"`
+	Token      string                 `json:"token"`      // 邀请令牌
+	InviteURL  string                 `json:"invite_url"` // 邀请链接，未配置 adminSecurity.inviteUrl 时与令牌相同
+}
+
+// InvitationInfo 被邀请人查看的邀请信息
+type InvitationInfo struct {
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CreateInvitation 创建一次性管理员邀请，操作人只能邀请不超出自身权限的角色
+func (s *AdminService) CreateInvitation(ctx context.Context, operatorID int64, req *CreateInvitationRequest) (*InvitationResult, error) {
+	if err := s.roles.CanAssignRole(ctx, operatorID, req.Role); err != nil {
+		return nil, err
+	}
+
+	username := strings.TrimSpace(req.Username)
+	if err := s.ensureUsernameAvailable(s.db, username); err != nil {
+		return nil, err
+	}
+	pending, err := model.CountPendingAdminInvitations(s.db, username)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "查询邀请失败", err)
+	}
+	if pending > 0 {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "该用户名已有待接受的邀请", nil)
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "生成邀请令牌失败", err)
+	}
+	invitation := &model.AdminInvitation{
+		TokenHash: hashSecret(token),
+		Username:  username,
+		Role:      req.Role,
+		Note:      req.Note,
+		CreatedBy: int(operatorID),
+		ExpiresAt: time.Now().Add(s.cfg.AdminSecurity.InviteTTL),
+	}
+	if err := model.CreateAdminInvitation(s.db, invitation); err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "创建邀请失败", err)
+	}
+	audit.Record(ctx, "admin_invitation", strconv.FormatInt(invitation.ID, 10), nil, invitation)
+
+	return &InvitationResult{
+		Invitation: invitation,
+		Token:      token,
+		InviteURL:  buildTokenURL(s.cfg.AdminSecurity.InviteURL, token),
+	}, nil
+}
+
+// ListInvitations 分页获取管理员邀请
+func (s *AdminService) ListInvitations(ctx context.Context, page, limit int) ([]*model.AdminInvitation, int64, error) {
+	list, total, err := model.ListAdminInvitations(s.db, page, limit)
+	if err != nil {
+		return nil, 0, errors.New(errors.ErrCodeInternal, "获取邀请列表失败", err)
+	}
+	return list, total, nil
+}
+
+// RevokeInvitation 撤销尚未接受的邀请
+func (s *AdminService) RevokeInvitation(ctx context.Context, operatorID, id int64) error {
+	invitation, err := model.GetAdminInvitationByID(s.db, id)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New(errors.ErrCodeNotFound, "邀请不存在", nil)
+		}
+		return errors.New(errors.ErrCodeInternal, "查询邀请失败", err)
+	}
+	if err := s.roles.CanAssignRole(ctx, operatorID, invitation.Role); err != nil {
+		return err
+	}
+
+	ok, err := model.RevokeAdminInvitation(s.db, id)
+	if err != nil {
+		return errors.New(errors.ErrCodeInternal, "撤销邀请失败", err)
+	}
+	if !ok {
+		return errors.New(errors.ErrCodeInvalidParams, "邀请已被接受或已撤销", nil)
+	}
+	after, _ := model.GetAdminInvitationByID(s.db, id)
+	audit.Record(ctx, "admin_invitation", strconv.FormatInt(id, 10), invitation, after)
+	return nil
+}
+
+// GetInvitation 被邀请人打开链接时查看邀请信息，无效、过期或已使用的邀请统一返回同一错误
+func (s *AdminService) GetInvitation(ctx context.Context, token string) (*InvitationInfo, error) {
+	invitation, err := s.pendingInvitation(token)
+	if err != nil {
+		return nil, err
+	}
+	return &InvitationInfo{
+		Username:  invitation.Username,
+		Role:      invitation.Role,
+		ExpiresAt: invitation.ExpiresAt,
+	}, nil
+}
+
+// AcceptInvitation 被邀请人设置密码，创建管理员账号并直接登录
+func (s *AdminService) AcceptInvitation(ctx context.Context, token, password string) (*AdminLoginResult, error) {
+	invitation, err := s.pendingInvitation(token)
+	if err != nil {
+		return nil, err
+	}
+	if password == defaultAdminPassword {
+		return nil, errors.New(errors.ErrCodeWeakPassword, "不能使用默认密码", nil)
+	}
+	passwordHash, err := s.policy.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	admin := &model.AdminUser{
+		Username:          invitation.Username,
+		PasswordHash:      passwordHash,
+		Role:              invitation.Role,
+		Status:            1,
+		PasswordChangedAt: &now,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.ensureUsernameAvailable(tx, admin.Username); err != nil {
+			return err
+		}
+		if err := tx.Create(admin).Error; err != nil {
+			return errors.New(errors.ErrCodeInternal, "创建管理员失败", err)
+		}
+		// 条件更新保证并发提交同一邀请时只有一个成功
+		ok, err := model.AcceptAdminInvitation(tx, invitation.ID, admin.AdminID)
+		if err != nil {
+			return errors.New(errors.ErrCodeInternal, "核销邀请失败", err)
+		}
+		if !ok {
+			return errors.New(errors.ErrCodeInvalidParams, "邀请链接无效或已过期", nil)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.issueToken(admin, false)
+}
+
+// BootstrapSuperAdmin 创建首个超级管理员，仅在系统中还没有任何管理员时可用；
+// password 为空时生成随机密码并要求首次登录后修改，返回实际使用的密码
+func (s *AdminService) BootstrapSuperAdmin(ctx context.Context, username, password string) (*model.AdminUser, string, error) {
+	username = strings.TrimSpace(username)
+	if len(username) < 3 || len(username) > 32 {
+		return nil, "", errors.New(errors.ErrCodeInvalidParams, "用户名长度必须在3到32位之间", nil)
+	}
+
+	generated := password == ""
+	var (
+		passwordHash string
+		err          error
+	)
+	if generated {
+		if password, err = randomToken(); err != nil {
+			return nil, "", errors.New(errors.ErrCodeInternal, "生成初始密码失败", err)
+		}
+		password = password[:bootstrapPasswordLength]
+		// 随机密码强度足够，不受字符类别策略限制
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), s.policy.BcryptCost)
+		if err != nil {
+			return nil, "", errors.New(errors.ErrCodeInternal, "生成密码哈希失败", err)
+		}
+		passwordHash = string(hash)
+	} else {
+		if password == defaultAdminPassword {
+			return nil, "", errors.New(errors.ErrCodeWeakPassword, "不能使用默认密码", nil)
+		}
+		if passwordHash, err = s.policy.Hash(password); err != nil {
+			return nil, "", err
+		}
+	}
+
+	now := time.Now()
+	admin := &model.AdminUser{
+		Username:           username,
+		PasswordHash:       passwordHash,
+		Role:               model.RoleSuperAdmin,
+		Status:             1,
+		MustChangePassword: generated,
+		PasswordChangedAt:  &now,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := ensureBootstrapAllowed(tx); err != nil {
+			return err
+		}
+		if err := tx.Create(admin).Error; err != nil {
+			return errors.New(errors.ErrCodeInternal, "创建管理员失败", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return admin, password, nil
+}
+
+// ensureBootstrapAllowed 检查能否创建首个超级管理员：超级管理员角色已迁移且系统中还没有任何管理员
+func ensureBootstrapAllowed(tx *gorm.DB) error {
+	if _, err := model.GetRoleByCode(tx, model.RoleSuperAdmin); err != nil {
+		return errors.New(errors.ErrCodeInternal, "超级管理员角色不存在，请先执行数据库迁移", err)
+	}
+	count, err := model.CountAdminUsers(tx)
+	if err != nil {
+		return errors.New(errors.ErrCodeInternal, "查询管理员失败", err)
+	}
+	if count > 0 {
+		return errors.New(errors.ErrCodeForbidden, "系统中已存在管理员，请登录后通过邀请添加", nil)
+	}
+	return nil
+}
+
+// pendingInvitation 按令牌查找仍可接受的邀请
+func (s *AdminService) pendingInvitation(token string) (*model.AdminInvitation, error) {
+	invalid := errors.New(errors.ErrCodeInvalidParams, "邀请链接无效或已过期", nil)
+	if token == "" {
+		return nil, invalid
+	}
+	invitation, err := model.GetAdminInvitationByTokenHash(s.db, hashSecret(token))
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, invalid
+		}
+		return nil, errors.New(errors.ErrCodeInternal, "查询邀请失败", err)
+	}
+	if invitation.Status != model.InvitationStatusPending {
+		return nil, invalid
+	}
+	return invitation, nil
+}
+
+// ensureUsernameAvailable 检查管理员用户名是否已被占用
+func (s *AdminService) ensureUsernameAvailable(db *gorm.DB, username string) error {
+	var count int64
+	if err := db.Model(&model.AdminUser{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return errors.New(errors.ErrCodeInternal, "检查用户名失败", err)
+	}
+	if count > 0 {
+		return errors.New(errors.ErrCodeInvalidParams, "用户名已存在", nil)
+	}
+	return nil
+}
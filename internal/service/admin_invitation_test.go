@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/config"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"gorm.io/gorm"
+)
+
+func TestAdminInvitationStatus(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		v := now.Add(d)
+		return &v
+	}
+
+	cases := []struct {
+		name       string
+		invitation model.AdminInvitation
+		want       string
+	}{
+		{"pending", model.AdminInvitation{ExpiresAt: now.Add(time.Hour)}, model.InvitationStatusPending},
+		{"expired", model.AdminInvitation{ExpiresAt: now.Add(-time.Minute)}, model.InvitationStatusExpired},
+		{"expires exactly now", model.AdminInvitation{ExpiresAt: now}, model.InvitationStatusExpired},
+		{"revoked", model.AdminInvitation{ExpiresAt: now.Add(time.Hour), RevokedAt: at(-time.Minute)}, model.InvitationStatusRevoked},
+		{"accepted before expiry stays accepted", model.AdminInvitation{ExpiresAt: now.Add(-time.Hour), UsedAt: at(-2 * time.Hour)}, model.InvitationStatusAccepted},
+		{"revoked after expiry", model.AdminInvitation{ExpiresAt: now.Add(-time.Hour), RevokedAt: at(-time.Minute)}, model.InvitationStatusRevoked},
+	}
+	for _, c := range cases {
+		if got := c.invitation.CurrentStatus(now); got != c.want {
+			t.Errorf("%s: status %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+// newInvitationDB 在试运行 GORM 上返回固定的邀请记录和管理员数量
+func newInvitationDB(t *testing.T, invitation *model.AdminInvitation, admins int64) *gorm.DB {
+	t.Helper()
+	db := newDryRunDB(t)
+	rows := func(tx *gorm.DB) {
+		switch dest := tx.Statement.Dest.(type) {
+		case *model.AdminInvitation:
+			if invitation != nil {
+				*dest = *invitation
+				tx.RowsAffected = 1
+			}
+		case *model.AdminRole:
+			*dest = model.AdminRole{Code: model.RoleSuperAdmin}
+			tx.RowsAffected = 1
+		case *int64:
+			if tx.Statement.Table == "admin_users" {
+				*dest = admins
+				tx.RowsAffected = admins
+			}
+		}
+	}
+	if err := db.Callback().Query().After("gorm:query").Before("gorm:after_query").Register("test:rows", rows); err != nil {
+		t.Fatalf("register query callback: %v", err)
+	}
+	return db
+}
+
+func TestAcceptInvitationOnlyOnce(t *testing.T) {
+	now := time.Now()
+	usedAt := now.Add(-time.Minute)
+	cases := []struct {
+		name       string
+		invitation *model.AdminInvitation
+		ok         bool
+	}{
+		{"pending", &model.AdminInvitation{Username: "ops", ExpiresAt: now.Add(time.Hour)}, true},
+		{"already accepted", &model.AdminInvitation{Username: "ops", ExpiresAt: now.Add(time.Hour), UsedAt: &usedAt}, false},
+		{"expired", &model.AdminInvitation{Username: "ops", ExpiresAt: now.Add(-time.Minute)}, false},
+		{"unknown token", nil, false},
+	}
+	for _, c := range cases {
+		s := &AdminService{db: newInvitationDB(t, c.invitation, 0), cfg: &config.Config{}}
+		_, err := s.GetInvitation(context.Background(), "token")
+		if (err == nil) != c.ok {
+			t.Errorf("%s: get invitation returned %v, want ok=%v", c.name, err, c.ok)
+		}
+		if c.ok {
+			continue
+		}
+		// 已使用或过期的邀请在设置密码前就被拒绝，不会创建账号
+		_, err = s.AcceptInvitation(context.Background(), "token", "Str0ng-Passw0rd")
+		var bizErr *errors.Error
+		if !stderrors.As(err, &bizErr) || bizErr.Code != errors.ErrCodeInvalidParams {
+			t.Errorf("%s: accept returned %v, want invalid invitation", c.name, err)
+		}
+	}
+}
+
+func TestEnsureBootstrapAllowed(t *testing.T) {
+	if err := ensureBootstrapAllowed(newInvitationDB(t, nil, 0)); err != nil {
+		t.Errorf("no admins: %v", err)
+	}
+	err := ensureBootstrapAllowed(newInvitationDB(t, nil, 1))
+	var bizErr *errors.Error
+	if !stderrors.As(err, &bizErr) || bizErr.Code != errors.ErrCodeForbidden {
+		t.Errorf("existing admin: got %v, want forbidden", err)
+	}
+}
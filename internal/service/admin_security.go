@@ -0,0 +1,561 @@
+package service
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/audit"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/jwt"
+	"github.com/reusedev/uportal-api/pkg/logs"
+	"github.com/reusedev/uportal-api/pkg/totp"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultAdminPassword 早期版本迁移时预置管理员的默认密码，旧库中仍使用该密码登录时必须先修改
+	defaultAdminPassword = "admin123"
+
+	adminLoginFailKeyPrefix = "admin:login:fail:"      // 登录失败计数，按用户名+IP及用户名
+	adminLoginLockKeyPrefix = "admin:login:lock:"      // 登录锁定标记，按用户名+IP及用户名
+	adminChallengeKeyPrefix = "admin:login:challenge:" // 密码已通过、待输入动态码的登录
+	adminTOTPPendingPrefix  = "admin:2fa:pending:"     // 绑定中尚未确认的TOTP密钥
+	adminTOTPUsedKeyPrefix  = "admin:2fa:used:"        // 已使用的时间步，防止动态码重放
+	adminTOTPPendingTTL     = 10 * time.Minute
+	recoveryCodeAlphabet    = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	recoveryCodeHalfLength  = 5
+)
+
+// AdminLoginResult 管理员登录结果
+type AdminLoginResult struct {
+	Admin          *model.AdminUser
+	Token          string // 访问令牌，Scope 非空时为受限令牌
+	Scope          string // 令牌作用域，见 jwt.Scope* 常量
+	ChallengeToken string // 已启用2FA时返回，凭此调用动态码校验接口完成登录
+}
+
+// AdminTwoFactorLoginRequest 管理员登录第二步请求
+type AdminTwoFactorLoginRequest struct {
+	ChallengeToken string
+	Code           string // 验证器动态码
+	RecoveryCode   string // 恢复码，与动态码二选一
+}
+
+// TwoFactorSetup 2FA 绑定信息
+type TwoFactorSetup struct {
+	Secret          string `json:"secret"`           // Base32 密钥，供无法扫码时手动输入
+	ProvisioningURI string `json:"provisioning_uri"` // otpauth:// 链接，前端渲染为二维码
+}
+
+// TwoFactorStatus 2FA 状态
+type TwoFactorStatus struct {
+	Enabled                bool  `json:"enabled"`                  // 是否已启用
+	Required               bool  `json:"required"`                 // 当前角色是否强制启用
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"` // 剩余可用恢复码数量
+}
+
+// adminLoginChallenge 登录挑战，保存在 Redis 中
+type adminLoginChallenge struct {
+	AdminID         int    `json:"admin_id"`
+	IP              string `json:"ip"` // 输入密码时的IP，动态码错误计入该IP的失败次数
+	DefaultPassword bool   `json:"default_password"`
+}
+
+// VerifyLoginTwoFactor 校验登录第二步的动态码或恢复码
+func (s *AdminService) VerifyLoginTwoFactor(ctx context.Context, req *AdminTwoFactorLoginRequest) (*AdminLoginResult, error) {
+	key := adminChallengeKeyPrefix + hashSecret(req.ChallengeToken)
+	raw, err := s.redis.Get(ctx, key).Result()
+	if err != nil {
+		if stderrors.Is(err, redis.Nil) {
+			return nil, errors.New(errors.ErrCodeUnauthorized, "登录已过期，请重新登录", nil)
+		}
+		return nil, errors.New(errors.ErrCodeRedisError, "读取登录状态失败", err)
+	}
+	var challenge adminLoginChallenge
+	if err := json.Unmarshal([]byte(raw), &challenge); err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "解析登录状态失败", err)
+	}
+
+	admin, err := model.GetAdinUserByID(s.db, int64(challenge.AdminID))
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New(errors.ErrCodeUnauthorized, "登录已过期，请重新登录", nil)
+		}
+		return nil, errors.New(errors.ErrCodeInternal, "查询管理员失败", err)
+	}
+	if err := s.checkLoginLocked(ctx, admin.Username, challenge.IP); err != nil {
+		return nil, err
+	}
+
+	ok, err := s.verifySecondFactor(ctx, admin, req.Code, req.RecoveryCode)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if lockErr := s.recordLoginFailure(ctx, admin.Username, challenge.IP); lockErr != nil {
+			s.redis.Del(ctx, key)
+			return nil, lockErr
+		}
+		return nil, errors.ErrInvalidOTP
+	}
+
+	s.redis.Del(ctx, key)
+	return s.completeLogin(ctx, admin, challenge.IP, challenge.DefaultPassword)
+}
+
+// GetTwoFactorStatus 获取管理员 2FA 状态
+func (s *AdminService) GetTwoFactorStatus(ctx context.Context, adminID int64) (*TwoFactorStatus, error) {
+	admin, err := s.getAdmin(adminID)
+	if err != nil {
+		return nil, err
+	}
+	status := &TwoFactorStatus{
+		Enabled:  admin.TwoFactorEnabled,
+		Required: s.roleRequiresTwoFactor(admin.Role),
+	}
+	if admin.TwoFactorEnabled {
+		status.RecoveryCodesRemaining, err = model.CountUnusedAdminRecoveryCodes(s.db, admin.AdminID)
+		if err != nil {
+			return nil, errors.New(errors.ErrCodeInternal, "查询恢复码失败", err)
+		}
+	}
+	return status, nil
+}
+
+// SetupTwoFactor 生成待确认的 TOTP 密钥，需调用 EnableTwoFactor 校验动态码后才生效
+func (s *AdminService) SetupTwoFactor(ctx context.Context, adminID int64) (*TwoFactorSetup, error) {
+	admin, err := s.getAdmin(adminID)
+	if err != nil {
+		return nil, err
+	}
+	if admin.TwoFactorEnabled {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "已启用双因素认证，如需更换请先关闭", nil)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "生成密钥失败", err)
+	}
+	key := fmt.Sprintf("%s%d", adminTOTPPendingPrefix, admin.AdminID)
+	if err := s.redis.Set(ctx, key, secret, adminTOTPPendingTTL).Err(); err != nil {
+		return nil, errors.New(errors.ErrCodeRedisError, "保存密钥失败", err)
+	}
+
+	return &TwoFactorSetup{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(s.cfg.AdminSecurity.TOTPIssuer, admin.Username, secret),
+	}, nil
+}
+
+// EnableTwoFactor 校验动态码并启用 2FA，返回一次性展示的恢复码和新的访问令牌
+func (s *AdminService) EnableTwoFactor(ctx context.Context, adminID int64, code string) ([]string, *AdminLoginResult, error) {
+	admin, err := s.getAdmin(adminID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if admin.TwoFactorEnabled {
+		return nil, nil, errors.New(errors.ErrCodeInvalidParams, "已启用双因素认证", nil)
+	}
+
+	pendingKey := fmt.Sprintf("%s%d", adminTOTPPendingPrefix, admin.AdminID)
+	secret, err := s.redis.Get(ctx, pendingKey).Result()
+	if err != nil {
+		if stderrors.Is(err, redis.Nil) {
+			return nil, nil, errors.New(errors.ErrCodeInvalidParams, "绑定已过期，请重新获取二维码", nil)
+		}
+		return nil, nil, errors.New(errors.ErrCodeRedisError, "读取密钥失败", err)
+	}
+	if !s.validateTOTP(ctx, admin.AdminID, secret, code) {
+		return nil, nil, errors.ErrInvalidOTP
+	}
+
+	encrypted, err := s.encryptSecret(secret)
+	if err != nil {
+		return nil, nil, errors.New(errors.ErrCodeInternal, "加密密钥失败", err)
+	}
+	codes, hashes, err := generateRecoveryCodes(s.cfg.AdminSecurity.RecoveryCodeCount)
+	if err != nil {
+		return nil, nil, errors.New(errors.ErrCodeInternal, "生成恢复码失败", err)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := model.UpdateAdminUser(tx, int64(admin.AdminID), map[string]interface{}{
+			"totp_secret":        encrypted,
+			"two_factor_enabled": true,
+		}); err != nil {
+			return err
+		}
+		return model.ReplaceAdminRecoveryCodes(tx, admin.AdminID, hashes)
+	})
+	if err != nil {
+		return nil, nil, errors.New(errors.ErrCodeInternal, "启用双因素认证失败", err)
+	}
+	s.redis.Del(ctx, pendingKey)
+	audit.Record(ctx, "admin", strconv.Itoa(admin.AdminID),
+		map[string]interface{}{"two_factor_enabled": false},
+		map[string]interface{}{"two_factor_enabled": true})
+
+	admin.TOTPSecret = &encrypted
+	admin.TwoFactorEnabled = true
+	result, err := s.issueToken(admin, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logs.Business().Info("管理员启用双因素认证", zap.Int("admin_id", admin.AdminID))
+	return codes, result, nil
+}
+
+// DisableTwoFactor 关闭 2FA，需要同时校验密码和动态码（或恢复码）
+func (s *AdminService) DisableTwoFactor(ctx context.Context, adminID int64, password, code, recoveryCode string) error {
+	admin, err := s.getAdmin(adminID)
+	if err != nil {
+		return err
+	}
+	if !admin.TwoFactorEnabled {
+		return errors.New(errors.ErrCodeInvalidParams, "未启用双因素认证", nil)
+	}
+	if s.roleRequiresTwoFactor(admin.Role) {
+		return errors.New(errors.ErrCodeForbidden, "当前角色必须启用双因素认证", nil)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte(password)); err != nil {
+		return errors.New(errors.ErrCodeUnauthorized, "密码错误", nil)
+	}
+	ok, err := s.verifySecondFactor(ctx, admin, code, recoveryCode)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.ErrInvalidOTP
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := model.UpdateAdminUser(tx, int64(admin.AdminID), map[string]interface{}{
+			"totp_secret":        nil,
+			"two_factor_enabled": false,
+		}); err != nil {
+			return err
+		}
+		return model.DeleteAdminRecoveryCodes(tx, admin.AdminID)
+	})
+	if err != nil {
+		return errors.New(errors.ErrCodeInternal, "关闭双因素认证失败", err)
+	}
+	audit.Record(ctx, "admin", strconv.Itoa(admin.AdminID),
+		map[string]interface{}{"two_factor_enabled": true},
+		map[string]interface{}{"two_factor_enabled": false})
+
+	logs.Business().Info("管理员关闭双因素认证", zap.Int("admin_id", admin.AdminID))
+	return nil
+}
+
+// RegenerateRecoveryCodes 校验动态码后重新生成恢复码，原有恢复码全部作废
+func (s *AdminService) RegenerateRecoveryCodes(ctx context.Context, adminID int64, code string) ([]string, error) {
+	admin, err := s.getAdmin(adminID)
+	if err != nil {
+		return nil, err
+	}
+	if !admin.TwoFactorEnabled {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "未启用双因素认证", nil)
+	}
+	ok, err := s.verifySecondFactor(ctx, admin, code, "")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.ErrInvalidOTP
+	}
+
+	codes, hashes, err := generateRecoveryCodes(s.cfg.AdminSecurity.RecoveryCodeCount)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "生成恢复码失败", err)
+	}
+	if err := model.ReplaceAdminRecoveryCodes(s.db, admin.AdminID, hashes); err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "保存恢复码失败", err)
+	}
+	audit.Record(ctx, "admin", strconv.Itoa(admin.AdminID), nil,
+		map[string]interface{}{"recovery_codes_regenerated": len(codes)})
+	return codes, nil
+}
+
+// startLoginChallenge 密码校验通过后创建登录挑战，等待输入动态码
+func (s *AdminService) startLoginChallenge(ctx context.Context, admin *model.AdminUser, ip string, defaultPassword bool) (*AdminLoginResult, error) {
+	token, err := randomToken()
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "生成登录凭证失败", err)
+	}
+	data, _ := json.Marshal(adminLoginChallenge{AdminID: admin.AdminID, IP: ip, DefaultPassword: defaultPassword})
+	key := adminChallengeKeyPrefix + hashSecret(token)
+	if err := s.redis.Set(ctx, key, data, s.cfg.AdminSecurity.ChallengeTTL).Err(); err != nil {
+		return nil, errors.New(errors.ErrCodeRedisError, "保存登录状态失败", err)
+	}
+	return &AdminLoginResult{Admin: admin, ChallengeToken: token}, nil
+}
+
+// completeLogin 全部认证因素通过，清除该IP上的失败计数并签发令牌。
+// 按用户名的计数不清除，只随统计窗口过期，避免登录成功为分布式破解重置次数
+func (s *AdminService) completeLogin(ctx context.Context, admin *model.AdminUser, ip string, defaultPassword bool) (*AdminLoginResult, error) {
+	s.redis.Del(ctx, adminLoginFailKeyPrefix+s.loginLimits(admin.Username, ip)[0].key)
+
+	result, err := s.issueToken(admin, defaultPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	// 更新最后登录时间
+	if err := s.db.Model(admin).Update("last_login_at", time.Now()).Error; err != nil {
+		// 仅记录错误，不影响登录流程
+		logs.Business().Warn("更新管理员最后登录时间失败",
+			zap.Int("admin_id", admin.AdminID),
+			zap.Error(err),
+		)
+	}
+	return result, nil
+}
+
+// issueToken 根据账号安全状态签发完整或受限令牌：
+// 需改密时只能修改密码，角色强制2FA但未绑定时只能进行绑定
+func (s *AdminService) issueToken(admin *model.AdminUser, defaultPassword bool) (*AdminLoginResult, error) {
+	scope := ""
+	ttl := s.cfg.JWT.ExpireTime
+	switch {
+	case admin.MustChangePassword || defaultPassword:
+		scope = jwt.ScopePasswordChange
+	case s.roleRequiresTwoFactor(admin.Role) && !admin.TwoFactorEnabled:
+		scope = jwt.ScopeTwoFactorSetup
+	}
+	if scope != "" {
+		ttl = s.cfg.AdminSecurity.RestrictedTTL
+	}
+
+	// 具体能访问哪些接口由权限中间件按角色判断
+	token, err := jwt.GenerateAdminToken(int64(admin.AdminID), true, admin.Username, admin.Role, scope, ttl)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "生成token失败", err)
+	}
+	return &AdminLoginResult{Admin: admin, Token: token, Scope: scope}, nil
+}
+
+// loginLimit 登录失败计数与锁定的一个维度
+type loginLimit struct {
+	key         string // 计数键和锁定键的后缀
+	maxAttempts int    // 锁定前允许的连续失败次数，0 表示不限制
+}
+
+// loginLimits 登录失败按两个维度计数：用户名+IP 阈值较低，
+// 避免他人从其他IP故意输错密码把管理员锁在门外；
+// 用户名阈值较高，挡住不断更换IP的分布式暴力破解
+func (s *AdminService) loginLimits(username, ip string) []loginLimit {
+	name := strings.ToLower(username)
+	sec := s.cfg.AdminSecurity
+	return []loginLimit{
+		{key: "ip:" + name + ":" + ip, maxAttempts: sec.MaxLoginAttempts},
+		{key: "user:" + name, maxAttempts: sec.MaxAccountLoginAttempts},
+	}
+}
+
+// checkLoginLocked 检查账号在该IP上或在所有IP上是否因连续登录失败被锁定
+func (s *AdminService) checkLoginLocked(ctx context.Context, username, ip string) error {
+	for _, limit := range s.loginLimits(username, ip) {
+		ttl, err := s.redis.TTL(ctx, adminLoginLockKeyPrefix+limit.key).Result()
+		if err != nil {
+			// Redis 异常时不阻断登录，避免所有管理员被拒之门外
+			logs.Business().Warn("查询管理员锁定状态失败", zap.String("username", username), zap.Error(err))
+			return nil
+		}
+		if ttl > 0 {
+			minutes := int((ttl + time.Minute - 1) / time.Minute)
+			return errors.New(errors.ErrCodeAccountLocked, fmt.Sprintf("登录失败次数过多，请%d分钟后再试", minutes), nil)
+		}
+	}
+	return nil
+}
+
+// recordLoginFailure 记录一次登录失败，任一维度达到阈值时锁定登录并返回锁定错误
+func (s *AdminService) recordLoginFailure(ctx context.Context, username, ip string) error {
+	sec := s.cfg.AdminSecurity
+	var lockErr error
+	for _, limit := range s.loginLimits(username, ip) {
+		if limit.maxAttempts <= 0 {
+			continue
+		}
+		failKey := adminLoginFailKeyPrefix + limit.key
+		count, err := s.redis.Incr(ctx, failKey).Result()
+		if err != nil {
+			logs.Business().Warn("记录管理员登录失败次数失败", zap.String("username", username), zap.Error(err))
+			return nil
+		}
+		if count == 1 {
+			s.redis.Expire(ctx, failKey, sec.AttemptWindow)
+		}
+		if count < int64(limit.maxAttempts) {
+			continue
+		}
+
+		s.redis.Set(ctx, adminLoginLockKeyPrefix+limit.key, 1, sec.LockoutDuration)
+		s.redis.Del(ctx, failKey)
+		logs.Business().Warn("管理员账号因连续登录失败被锁定",
+			zap.String("username", username),
+			zap.String("ip", ip),
+			zap.String("scope", limit.key),
+			zap.Duration("duration", sec.LockoutDuration),
+		)
+		lockErr = errors.New(errors.ErrCodeAccountLocked,
+			fmt.Sprintf("登录失败次数过多，账号已锁定%s", formatTTL(sec.LockoutDuration)), nil)
+	}
+	return lockErr
+}
+
+// verifySecondFactor 校验动态码或恢复码，恢复码校验通过即作废
+func (s *AdminService) verifySecondFactor(ctx context.Context, admin *model.AdminUser, code, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		ok, err := model.UseAdminRecoveryCode(s.db, admin.AdminID, hashSecret(normalizeRecoveryCode(recoveryCode)))
+		if err != nil {
+			return false, errors.New(errors.ErrCodeInternal, "校验恢复码失败", err)
+		}
+		if ok {
+			logs.Business().Info("管理员使用恢复码登录", zap.Int("admin_id", admin.AdminID))
+		}
+		return ok, nil
+	}
+	if code == "" || admin.TOTPSecret == nil {
+		return false, nil
+	}
+	secret, err := s.decryptSecret(*admin.TOTPSecret)
+	if err != nil {
+		return false, errors.New(errors.ErrCodeInternal, "解密密钥失败", err)
+	}
+	return s.validateTOTP(ctx, admin.AdminID, secret, code), nil
+}
+
+// validateTOTP 校验动态码，同一时间步的动态码只能使用一次
+func (s *AdminService) validateTOTP(ctx context.Context, adminID int, secret, code string) bool {
+	skew := s.cfg.AdminSecurity.TOTPSkew
+	step, ok := totp.Validate(secret, code, time.Now(), skew)
+	if !ok {
+		return false
+	}
+	key := fmt.Sprintf("%s%d:%d", adminTOTPUsedKeyPrefix, adminID, step)
+	ttl := time.Duration(2*skew+1) * totp.Period * time.Second
+	fresh, err := s.redis.SetNX(ctx, key, 1, ttl).Result()
+	if err != nil {
+		logs.Business().Warn("记录动态码使用状态失败", zap.Int("admin_id", adminID), zap.Error(err))
+		return true
+	}
+	return fresh
+}
+
+// roleRequiresTwoFactor 角色是否强制启用 2FA
+func (s *AdminService) roleRequiresTwoFactor(role string) bool {
+	for _, r := range s.cfg.AdminSecurity.Require2FARoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// getAdmin 查询管理员
+func (s *AdminService) getAdmin(adminID int64) (*model.AdminUser, error) {
+	admin, err := model.GetAdinUserByID(s.db, adminID)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New(errors.ErrCodeNotFound, "管理员不存在", nil)
+		}
+		return nil, errors.New(errors.ErrCodeInternal, "查询管理员失败", err)
+	}
+	return admin, nil
+}
+
+// secretCipher 由配置口令派生 AES-256-GCM 加密器
+func (s *AdminService) secretCipher() (cipher.AEAD, error) {
+	passphrase := s.cfg.AdminSecurity.SecretKey
+	if passphrase == "" {
+		passphrase = s.cfg.JWT.Secret
+	}
+	key := sha256.Sum256([]byte(passphrase))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptSecret 加密 TOTP 密钥后再入库
+func (s *AdminService) encryptSecret(secret string) (string, error) {
+	gcm, err := s.secretCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(secret), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptSecret 解密数据库中的 TOTP 密钥
+func (s *AdminService) decryptSecret(encrypted string) (string, error) {
+	gcm, err := s.secretCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// generateRecoveryCodes 生成恢复码，返回明文（仅展示一次）和对应哈希
+func generateRecoveryCodes(n int) ([]string, []string, error) {
+	codes := make([]string, 0, n)
+	hashes := make([]string, 0, n)
+	buf := make([]byte, recoveryCodeHalfLength*2)
+	for i := 0; i < n; i++ {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		chars := make([]byte, len(buf))
+		for j, b := range buf {
+			chars[j] = recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)]
+		}
+		code := string(chars[:recoveryCodeHalfLength]) + "-" + string(chars[recoveryCodeHalfLength:])
+		codes = append(codes, code)
+		hashes = append(hashes, hashSecret(normalizeRecoveryCode(code)))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode 去掉分隔符并转大写，用户输入时可不区分大小写、不带横线
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
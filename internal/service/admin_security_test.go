@@ -0,0 +1,342 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/reusedev/uportal-api/pkg/config"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/logs"
+	"github.com/reusedev/uportal-api/pkg/totp"
+	"go.uber.org/zap"
+)
+
+// fakeRedis 内存中的 Redis，只实现登录锁定和 nonce 用到的命令
+type fakeRedis struct {
+	mu      sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time
+}
+
+// newFakeRedisClient 返回连接到内存 Redis 的客户端
+func newFakeRedisClient(t *testing.T) (*redis.Client, *fakeRedis) {
+	t.Helper()
+	if logs.BusinessLogger == nil {
+		logs.BusinessLogger = zap.NewNop()
+	}
+	f := &fakeRedis{values: map[string]string{}, expires: map[string]time.Time{}}
+	client := redis.NewClient(&redis.Options{
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			server, conn := net.Pipe()
+			go f.serve(server)
+			return conn, nil
+		},
+	})
+	t.Cleanup(func() { client.Close() })
+	return client, f
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readRESP(r)
+		if err != nil {
+			return
+		}
+		if _, err := conn.Write([]byte(f.exec(args))); err != nil {
+			return
+		}
+	}
+}
+
+func readRESP(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+// get 读取未过期的值
+func (f *fakeRedis) get(key string) (string, bool) {
+	if exp, ok := f.expires[key]; ok && !time.Now().Before(exp) {
+		delete(f.values, key)
+		delete(f.expires, key)
+	}
+	v, ok := f.values[key]
+	return v, ok
+}
+
+func (f *fakeRedis) exec(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := ""
+	if len(args) > 1 {
+		key = args[1]
+	}
+	switch strings.ToLower(args[0]) {
+	case "ping":
+		return "+PONG\r\n"
+	case "get":
+		if v, ok := f.get(key); ok {
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+		}
+		return "$-1\r\n"
+	case "set":
+		nx := false
+		var ttl time.Duration
+		for i := 3; i < len(args); i++ {
+			switch strings.ToLower(args[i]) {
+			case "nx":
+				nx = true
+			case "ex":
+				n, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(n) * time.Second
+				i++
+			case "px":
+				n, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(n) * time.Millisecond
+				i++
+			}
+		}
+		if _, ok := f.get(key); ok && nx {
+			return "$-1\r\n"
+		}
+		f.values[key] = args[2]
+		delete(f.expires, key)
+		if ttl > 0 {
+			f.expires[key] = time.Now().Add(ttl)
+		}
+		return "+OK\r\n"
+	case "incr":
+		v, _ := f.get(key)
+		n, _ := strconv.Atoi(v)
+		f.values[key] = strconv.Itoa(n + 1)
+		return fmt.Sprintf(":%d\r\n", n+1)
+	case "expire":
+		if _, ok := f.get(key); !ok {
+			return ":0\r\n"
+		}
+		n, _ := strconv.Atoi(args[2])
+		f.expires[key] = time.Now().Add(time.Duration(n) * time.Second)
+		return ":1\r\n"
+	case "ttl":
+		if _, ok := f.get(key); !ok {
+			return ":-2\r\n"
+		}
+		exp, ok := f.expires[key]
+		if !ok {
+			return ":-1\r\n"
+		}
+		return fmt.Sprintf(":%d\r\n", int(time.Until(exp).Seconds()+0.5))
+	case "del":
+		deleted := 0
+		for _, k := range args[1:] {
+			if _, ok := f.get(k); ok {
+				deleted++
+			}
+			delete(f.values, k)
+			delete(f.expires, k)
+		}
+		return fmt.Sprintf(":%d\r\n", deleted)
+	}
+	return fmt.Sprintf("-ERR unknown command %q\r\n", args[0])
+}
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 附录 B 的 SHA1 测试向量，密钥为 ASCII "12345678901234567890"，取后 6 位
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, c := range cases {
+		got, err := totp.Code(secret, totp.Step(time.Unix(c.unix, 0)))
+		if err != nil {
+			t.Fatalf("code at %d: %v", c.unix, err)
+		}
+		if got != c.want {
+			t.Errorf("code at %d = %s, want %s", c.unix, got, c.want)
+		}
+	}
+	if _, err := totp.Code("not base32!", 1); err == nil {
+		t.Errorf("invalid secret accepted")
+	}
+}
+
+func TestTOTPValidateSkew(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1234567890, 0)
+	step := totp.Step(now)
+	codeAt := func(offset int64) string {
+		code, _ := totp.Code(secret, step+offset)
+		return code
+	}
+
+	cases := []struct {
+		name   string
+		code   string
+		skew   int
+		ok     bool
+		offset int64
+	}{
+		{"current step", codeAt(0), 1, true, 0},
+		{"previous step within skew", codeAt(-1), 1, true, -1},
+		{"next step within skew", codeAt(1), 1, true, 1},
+		{"outside skew", codeAt(2), 1, false, 0},
+		{"no skew rejects previous", codeAt(-1), 0, false, 0},
+		{"surrounding spaces", " " + codeAt(0) + " ", 0, true, 0},
+		{"wrong length", "12345", 1, false, 0},
+	}
+	for _, c := range cases {
+		got, ok := totp.Validate(secret, c.code, now, c.skew)
+		if ok != c.ok {
+			t.Errorf("%s: ok = %v, want %v", c.name, ok, c.ok)
+			continue
+		}
+		if ok && got != step+c.offset {
+			t.Errorf("%s: step %d, want %d", c.name, got, step+c.offset)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := generateRecoveryCodes(8)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if len(codes) != 8 || len(hashes) != 8 {
+		t.Fatalf("got %d codes and %d hashes, want 8", len(codes), len(hashes))
+	}
+	seen := map[string]bool{}
+	for i, code := range codes {
+		if len(code) != recoveryCodeHalfLength*2+1 || code[recoveryCodeHalfLength] != '-' {
+			t.Errorf("code %q has unexpected format", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate code %q", code)
+		}
+		seen[code] = true
+		// 用户输入小写、不带横线时仍能匹配
+		typed := strings.ToLower(strings.ReplaceAll(code, "-", " "))
+		if hashSecret(normalizeRecoveryCode(typed)) != hashes[i] {
+			t.Errorf("normalized input %q does not match hash of %q", typed, code)
+		}
+	}
+}
+
+func TestTwoFactorSecretEncryption(t *testing.T) {
+	s := &AdminService{cfg: &config.Config{}}
+	s.cfg.AdminSecurity.SecretKey = "test-passphrase"
+
+	encrypted, err := s.encryptSecret("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if strings.Contains(encrypted, "JBSWY3DPEHPK3PXP") {
+		t.Errorf("secret stored in plain text")
+	}
+	plain, err := s.decryptSecret(encrypted)
+	if err != nil || plain != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("decrypt = %q, %v", plain, err)
+	}
+
+	other := &AdminService{cfg: &config.Config{}}
+	other.cfg.AdminSecurity.SecretKey = "another-passphrase"
+	if _, err := other.decryptSecret(encrypted); err == nil {
+		t.Errorf("decrypt with a different key succeeded")
+	}
+}
+
+func TestAdminLoginLockout(t *testing.T) {
+	client, _ := newFakeRedisClient(t)
+	s := &AdminService{redis: client, cfg: &config.Config{}}
+	s.cfg.AdminSecurity.MaxLoginAttempts = 3
+	s.cfg.AdminSecurity.MaxAccountLoginAttempts = 5
+	s.cfg.AdminSecurity.AttemptWindow = 15 * time.Minute
+	s.cfg.AdminSecurity.LockoutDuration = 30 * time.Minute
+	ctx := context.Background()
+
+	isLocked := func(err error) bool {
+		var bizErr *errors.Error
+		return stderrors.As(err, &bizErr) && bizErr.Code == errors.ErrCodeAccountLocked
+	}
+
+	for i := 1; i <= 2; i++ {
+		if err := s.recordLoginFailure(ctx, "Root", "10.0.0.1"); err != nil {
+			t.Fatalf("failure %d locked early: %v", i, err)
+		}
+	}
+	if err := s.checkLoginLocked(ctx, "root", "10.0.0.1"); err != nil {
+		t.Fatalf("locked before reaching the limit: %v", err)
+	}
+	if err := s.recordLoginFailure(ctx, "root", "10.0.0.1"); !isLocked(err) {
+		t.Fatalf("third failure returned %v, want account locked", err)
+	}
+
+	cases := []struct {
+		name     string
+		username string
+		ip       string
+		locked   bool
+	}{
+		{"same username and ip", "root", "10.0.0.1", true},
+		{"username is case insensitive", "ROOT", "10.0.0.1", true},
+		{"same username from another ip", "root", "10.0.0.2", false},
+		{"another username from the same ip", "ops", "10.0.0.1", false},
+	}
+	for _, c := range cases {
+		err := s.checkLoginLocked(ctx, c.username, c.ip)
+		if isLocked(err) != c.locked {
+			t.Errorf("%s: got %v, want locked=%v", c.name, err, c.locked)
+		}
+	}
+
+	// 更换IP继续尝试，累计达到按用户名的阈值后所有IP都被锁定
+	if err := s.recordLoginFailure(ctx, "root", "10.0.0.3"); err != nil {
+		t.Fatalf("fourth failure locked early: %v", err)
+	}
+	if err := s.recordLoginFailure(ctx, "root", "10.0.0.4"); !isLocked(err) {
+		t.Fatalf("fifth failure returned %v, want account locked", err)
+	}
+	for _, ip := range []string{"10.0.0.2", "10.0.0.5"} {
+		if err := s.checkLoginLocked(ctx, "root", ip); !isLocked(err) {
+			t.Errorf("account lock from %s: got %v, want locked", ip, err)
+		}
+	}
+	if err := s.checkLoginLocked(ctx, "ops", "10.0.0.5"); err != nil {
+		t.Errorf("another username locked: %v", err)
+	}
+}
@@ -0,0 +1,370 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/config"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/logs"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// analyticsRollupLockKey 多实例部署时同一时刻只允许一个实例重算日汇总
+	analyticsRollupLockKey = "analytics:rollup:lock"
+	// analyticsRollupLockTTL 重算锁的最长持有时间，防止实例异常退出后锁不释放
+	analyticsRollupLockTTL = 10 * time.Minute
+	// analyticsMaxRangeDays 单次查询的最大天数
+	analyticsMaxRangeDays = 366
+	// analyticsUnknownDimension 缺少维度信息时使用的维度值
+	analyticsUnknownDimension = "unknown"
+)
+
+// 统计粒度
+const (
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+// AnalyticsService 运营数据统计服务，查询只读取日汇总表，不直接扫描业务表
+type AnalyticsService struct {
+	db     *gorm.DB
+	redis  *redis.Client
+	config *config.Config
+}
+
+// NewAnalyticsService 创建运营数据统计服务
+func NewAnalyticsService(db *gorm.DB, redis *redis.Client, cfg *config.Config) *AnalyticsService {
+	return &AnalyticsService{db: db, redis: redis, config: cfg}
+}
+
+// AnalyticsQueryRequest 运营数据查询请求
+type AnalyticsQueryRequest struct {
+	StartDate   string `json:"start_date" binding:"required,datetime=2006-01-02"`    // 开始日期（含）
+	EndDate     string `json:"end_date" binding:"required,datetime=2006-01-02"`      // 结束日期（含）
+	Granularity string `json:"granularity" binding:"omitempty,oneof=day week month"` // 统计粒度，默认 day
+}
+
+// AnalyticsValue 次数与金额
+type AnalyticsValue struct {
+	Count  int64   `json:"count"`  // 次数/人数
+	Amount float64 `json:"amount"` // 金额/代币数
+}
+
+// AnalyticsPoint 一个统计周期的数据
+type AnalyticsPoint struct {
+	Period                  string                     `json:"period"`                     // 周期起始日期：按天为当天，按周为周一，按月为1号
+	NewUsers                int64                      `json:"new_users"`                  // 新增用户
+	NewUsersByProvider      map[string]int64           `json:"new_users_by_provider"`      // 按注册渠道的新增用户，一个用户绑定多个渠道时分别计入
+	ActiveUsers             float64                    `json:"dau"`                        // 日活跃用户，按周/月统计时为周期内日均
+	PaidOrders              int64                      `json:"paid_orders"`                // 支付成功订单数
+	Revenue                 float64                    `json:"revenue"`                    // 充值收入（元）
+	RevenueByMethod         map[string]*AnalyticsValue `json:"revenue_by_method"`          // 按支付方式的订单数与收入
+	TokensGranted           int64                      `json:"tokens_granted"`             // 发放代币总数
+	TokensGrantedByType     map[string]int64           `json:"tokens_granted_by_type"`     // 按变动类型的发放代币
+	TokensConsumed          int64                      `json:"tokens_consumed"`            // 消耗代币总数
+	TokensConsumedByFeature map[string]int64           `json:"tokens_consumed_by_feature"` // 按功能代码的消耗代币
+	TaskCompletions         int64                      `json:"task_completions"`           // 任务完成次数
+	TaskCompletionsByTask   map[string]*AnalyticsValue `json:"task_completions_by_task"`   // 按任务ID的完成次数与奖励代币
+	Invites                 int64                      `json:"invites"`                    // 通过邀请注册的用户
+	InvitesRewarded         int64                      `json:"invites_rewarded"`           // 已发放奖励的邀请
+	InviteRate              float64                    `json:"invite_rate"`                // 邀请注册占新增用户的比例
+}
+
+// AnalyticsResult 运营数据查询结果
+type AnalyticsResult struct {
+	Granularity string            `json:"granularity"`
+	Points      []*AnalyticsPoint `json:"points"` // 按周期升序，没有数据的周期也会返回
+	Total       *AnalyticsPoint   `json:"total"`  // 整个日期区间的合计，period 为开始日期
+}
+
+// Start 启动日汇总定时任务：启动时重算最近 backfillDays 天，之后每隔 interval 重算今天和昨天
+func (s *AnalyticsService) Start(ctx context.Context) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.runRollup(ctx, s.config.Analytics.BackfillDays)
+
+		ticker := time.NewTicker(s.config.Analytics.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// 重算昨天，保证跨零点前最后一段时间的数据被补齐
+				s.runRollup(ctx, 2)
+			}
+		}
+	}()
+	return &wg
+}
+
+// runRollup 加锁后依次重算最近 days 天（含今天）
+func (s *AnalyticsService) runRollup(ctx context.Context, days int) {
+	if s.redis != nil {
+		locked, err := s.redis.SetNX(ctx, analyticsRollupLockKey, 1, analyticsRollupLockTTL).Result()
+		if err != nil || !locked {
+			return
+		}
+		defer s.redis.Del(context.Background(), analyticsRollupLockKey)
+	}
+
+	today := truncateDay(time.Now())
+	for i := days - 1; i >= 0; i-- {
+		if ctx.Err() != nil {
+			return
+		}
+		day := today.AddDate(0, 0, -i)
+		if err := s.RollupDay(ctx, day); err != nil {
+			logs.Business().Error("重算运营日汇总失败", zap.String("date", day.Format("2006-01-02")), zap.Error(err))
+		}
+	}
+}
+
+// analyticsRow 分组统计的一行结果
+type analyticsRow struct {
+	Dimension string
+	Count     int64
+	Amount    float64
+}
+
+// RollupDay 从业务表重算某一天的全部指标并整体替换该天的汇总数据
+func (s *AnalyticsService) RollupDay(ctx context.Context, day time.Time) error {
+	from := truncateDay(day)
+	to := from.AddDate(0, 0, 1)
+	db := s.db.WithContext(ctx)
+
+	var stats []*model.AnalyticsDailyStat
+	add := func(metric string, rows []analyticsRow) {
+		for _, r := range rows {
+			if r.Count == 0 && r.Amount == 0 {
+				continue
+			}
+			stats = append(stats, &model.AnalyticsDailyStat{
+				StatDate:  from,
+				Metric:    metric,
+				Dimension: r.Dimension,
+				Count:     r.Count,
+				Amount:    r.Amount,
+			})
+		}
+	}
+
+	queries := []struct {
+		metric string
+		query  *gorm.DB
+	}{
+		// 注销的用户同样计入当天新增
+		{model.MetricNewUsers, db.Unscoped().Model(&model.User{}).
+			Select("'' AS dimension, COUNT(*) AS count").
+			Where("created_at >= ? AND created_at < ?", from, to)},
+		{model.MetricNewUsers, db.Table("users AS u").
+			Select("COALESCE(a.provider, ?) AS dimension, COUNT(DISTINCT u.id) AS count", analyticsUnknownDimension).
+			Joins("LEFT JOIN user_auth AS a ON a.user_id = u.id").
+			Where("u.created_at >= ? AND u.created_at < ?", from, to).
+			Group("dimension")},
+		{model.MetricActiveUsers, db.Model(&model.UserLoginLog{}).
+			Select("'' AS dimension, COUNT(DISTINCT user_id) AS count").
+			Where("login_time >= ? AND login_time < ?", from, to)},
+		{model.MetricRevenue, db.Model(&model.RechargeOrder{}).
+			Select("payment_method AS dimension, COUNT(*) AS count, COALESCE(SUM(amount_paid), 0) AS amount").
+			Where("status = ? AND paid_at >= ? AND paid_at < ?", 1, from, to).
+			Group("payment_method")},
+		{model.MetricTokensGranted, db.Model(&model.TokenRecord{}).
+			Select("change_type AS dimension, COUNT(*) AS count, SUM(change_amount) AS amount").
+			Where("change_amount > 0 AND change_time >= ? AND change_time < ?", from, to).
+			Group("change_type")},
+		// 只统计功能消耗，管理员扣减不计入
+		{model.MetricTokensConsumed, db.Table("token_records AS t").
+			Select("COALESCE(r.feature_code, ?) AS dimension, COUNT(*) AS count, -SUM(t.change_amount) AS amount", analyticsUnknownDimension).
+			Joins("LEFT JOIN token_consume_rules AS r ON r.feature_id = t.feature_id").
+			Where("t.change_amount < 0 AND t.change_type IN ? AND t.change_time >= ? AND t.change_time < ?",
+				[]string{"CONSUME", "consume"}, from, to).
+			Group("dimension")},
+		{model.MetricTaskCompletions, db.Model(&model.TaskCompletionRecord{}).
+			Select("CAST(task_id AS CHAR) AS dimension, COUNT(*) AS count, SUM(token_reward) AS amount").
+			Where("completed_at >= ? AND completed_at < ?", from, to).
+			Group("task_id")},
+		{model.MetricInvites, db.Model(&model.InviteRecord{}).
+			Select("'' AS dimension, COUNT(*) AS count, SUM(token_reward) AS amount").
+			Where("created_at >= ? AND created_at < ?", from, to)},
+		{model.MetricInvitesRewarded, db.Model(&model.InviteRecord{}).
+			Select("'' AS dimension, COUNT(*) AS count, SUM(token_reward) AS amount").
+			Where("status = 1 AND created_at >= ? AND created_at < ?", from, to)},
+	}
+	for _, q := range queries {
+		var rows []analyticsRow
+		if err := q.query.Scan(&rows).Error; err != nil {
+			return errors.New(errors.ErrCodeDatabaseError, "统计"+q.metric+"失败", err)
+		}
+		add(q.metric, rows)
+	}
+
+	if err := model.ReplaceAnalyticsDailyStats(db, from, stats); err != nil {
+		return errors.New(errors.ErrCodeDatabaseError, "保存运营日汇总失败", err)
+	}
+	return nil
+}
+
+// Query 按日期区间和粒度汇总运营数据
+func (s *AnalyticsService) Query(ctx context.Context, req *AnalyticsQueryRequest) (*AnalyticsResult, error) {
+	start, err := time.ParseInLocation("2006-01-02", req.StartDate, time.Local)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "开始日期格式错误", err)
+	}
+	end, err := time.ParseInLocation("2006-01-02", req.EndDate, time.Local)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "结束日期格式错误", err)
+	}
+	if end.Before(start) {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "结束日期不能早于开始日期", nil)
+	}
+	if end.Sub(start) >= analyticsMaxRangeDays*24*time.Hour {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "单次最多查询一年的数据", nil)
+	}
+	granularity := req.Granularity
+	if granularity == "" {
+		granularity = GranularityDay
+	}
+
+	stats, err := model.ListAnalyticsDailyStats(s.db.WithContext(ctx), start, end)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "查询运营数据失败", err)
+	}
+	byDate := make(map[string][]*model.AnalyticsDailyStat)
+	for _, stat := range stats {
+		key := stat.StatDate.Format("2006-01-02")
+		byDate[key] = append(byDate[key], stat)
+	}
+
+	result := &AnalyticsResult{Granularity: granularity, Total: newAnalyticsPoint(req.StartDate)}
+	var (
+		current   *AnalyticsPoint
+		dauSum    int64
+		days      int
+		totalDays int
+		totalDAU  int64
+	)
+	flush := func() {
+		if current == nil {
+			return
+		}
+		current.ActiveUsers = average(dauSum, days)
+		current.InviteRate = ratio(current.Invites, current.NewUsers)
+		result.Points = append(result.Points, current)
+	}
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		period := periodStart(day, granularity).Format("2006-01-02")
+		if current == nil || current.Period != period {
+			flush()
+			current = newAnalyticsPoint(period)
+			dauSum, days = 0, 0
+		}
+		days++
+		totalDays++
+		for _, stat := range byDate[day.Format("2006-01-02")] {
+			if stat.Metric == model.MetricActiveUsers {
+				dauSum += stat.Count
+				totalDAU += stat.Count
+				continue
+			}
+			current.add(stat)
+			result.Total.add(stat)
+		}
+	}
+	flush()
+	result.Total.ActiveUsers = average(totalDAU, totalDays)
+	result.Total.InviteRate = ratio(result.Total.Invites, result.Total.NewUsers)
+	return result, nil
+}
+
+func newAnalyticsPoint(period string) *AnalyticsPoint {
+	return &AnalyticsPoint{
+		Period:                  period,
+		NewUsersByProvider:      make(map[string]int64),
+		RevenueByMethod:         make(map[string]*AnalyticsValue),
+		TokensGrantedByType:     make(map[string]int64),
+		TokensConsumedByFeature: make(map[string]int64),
+		TaskCompletionsByTask:   make(map[string]*AnalyticsValue),
+	}
+}
+
+// add 将一条日汇总累加到周期数据中，日活需要按天数取均值，由调用方单独处理
+func (p *AnalyticsPoint) add(stat *model.AnalyticsDailyStat) {
+	switch stat.Metric {
+	case model.MetricNewUsers:
+		if stat.Dimension == "" {
+			p.NewUsers += stat.Count
+		} else {
+			p.NewUsersByProvider[stat.Dimension] += stat.Count
+		}
+	case model.MetricRevenue:
+		p.PaidOrders += stat.Count
+		p.Revenue += stat.Amount
+		addValue(p.RevenueByMethod, stat)
+	case model.MetricTokensGranted:
+		p.TokensGranted += int64(stat.Amount)
+		p.TokensGrantedByType[stat.Dimension] += int64(stat.Amount)
+	case model.MetricTokensConsumed:
+		p.TokensConsumed += int64(stat.Amount)
+		p.TokensConsumedByFeature[stat.Dimension] += int64(stat.Amount)
+	case model.MetricTaskCompletions:
+		p.TaskCompletions += stat.Count
+		addValue(p.TaskCompletionsByTask, stat)
+	case model.MetricInvites:
+		p.Invites += stat.Count
+	case model.MetricInvitesRewarded:
+		p.InvitesRewarded += stat.Count
+	}
+}
+
+func addValue(m map[string]*AnalyticsValue, stat *model.AnalyticsDailyStat) {
+	v, ok := m[stat.Dimension]
+	if !ok {
+		v = &AnalyticsValue{}
+		m[stat.Dimension] = v
+	}
+	v.Count += stat.Count
+	v.Amount += stat.Amount
+}
+
+// periodStart 返回日期所在统计周期的起始日期，周从周一开始
+func periodStart(day time.Time, granularity string) time.Time {
+	switch granularity {
+	case GranularityWeek:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case GranularityMonth:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+	default:
+		return day
+	}
+}
+
+// truncateDay 返回本地时区当天零点
+func truncateDay(t time.Time) time.Time {
+	t = t.In(time.Local)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+func average(sum int64, n int) float64 {
+	if n == 0 {
+		return 0
+	}
+	return float64(sum) / float64(n)
+}
+
+func ratio(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole)
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+)
+
+func TestPeriodStart(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	}
+
+	cases := []struct {
+		name        string
+		day         time.Time
+		granularity string
+		want        time.Time
+	}{
+		{"day", day(2026, 10, 18), GranularityDay, day(2026, 10, 18)},
+		{"week from sunday", day(2026, 10, 18), GranularityWeek, day(2026, 10, 12)},
+		{"week from monday", day(2026, 10, 12), GranularityWeek, day(2026, 10, 12)},
+		{"week across month", day(2026, 11, 1), GranularityWeek, day(2026, 10, 26)},
+		{"week across year", day(2027, 1, 2), GranularityWeek, day(2026, 12, 28)},
+		{"month", day(2026, 10, 18), GranularityMonth, day(2026, 10, 1)},
+		{"unknown granularity falls back to day", day(2026, 10, 18), "hour", day(2026, 10, 18)},
+	}
+	for _, c := range cases {
+		if got := periodStart(c.day, c.granularity); !got.Equal(c.want) {
+			t.Errorf("%s: got %s, want %s", c.name, got.Format(time.DateOnly), c.want.Format(time.DateOnly))
+		}
+	}
+}
+
+func TestAnalyticsPointAdd(t *testing.T) {
+	p := newAnalyticsPoint("2026-10-18")
+	stats := []*model.AnalyticsDailyStat{
+		{Metric: model.MetricNewUsers, Count: 10},
+		{Metric: model.MetricNewUsers, Dimension: "wechat", Count: 7},
+		{Metric: model.MetricNewUsers, Dimension: "apple", Count: 3},
+		{Metric: model.MetricRevenue, Dimension: "alipay", Count: 2, Amount: 30},
+		{Metric: model.MetricRevenue, Dimension: "alipay", Count: 1, Amount: 12.5},
+		{Metric: model.MetricTokensGranted, Dimension: "TASK_REWARD", Amount: 500},
+		{Metric: model.MetricTokensConsumed, Dimension: "chat", Amount: 120},
+		{Metric: model.MetricTaskCompletions, Dimension: "3", Count: 4, Amount: 40},
+		{Metric: model.MetricInvites, Count: 5},
+		{Metric: model.MetricInvitesRewarded, Count: 2},
+	}
+	for _, stat := range stats {
+		p.add(stat)
+	}
+
+	if p.NewUsers != 10 || p.NewUsersByProvider["wechat"] != 7 || p.NewUsersByProvider["apple"] != 3 {
+		t.Errorf("new users = %d %v", p.NewUsers, p.NewUsersByProvider)
+	}
+	if p.PaidOrders != 3 || p.Revenue != 42.5 {
+		t.Errorf("revenue = %d orders, %v", p.PaidOrders, p.Revenue)
+	}
+	if v := p.RevenueByMethod["alipay"]; v == nil || v.Count != 3 || v.Amount != 42.5 {
+		t.Errorf("revenue by method = %+v", v)
+	}
+	if p.TokensGranted != 500 || p.TokensGrantedByType["TASK_REWARD"] != 500 {
+		t.Errorf("tokens granted = %d %v", p.TokensGranted, p.TokensGrantedByType)
+	}
+	if p.TokensConsumed != 120 || p.TokensConsumedByFeature["chat"] != 120 {
+		t.Errorf("tokens consumed = %d %v", p.TokensConsumed, p.TokensConsumedByFeature)
+	}
+	if p.TaskCompletions != 4 || p.TaskCompletionsByTask["3"].Amount != 40 {
+		t.Errorf("task completions = %d %+v", p.TaskCompletions, p.TaskCompletionsByTask["3"])
+	}
+	if p.Invites != 5 || p.InvitesRewarded != 2 {
+		t.Errorf("invites = %d, rewarded %d", p.Invites, p.InvitesRewarded)
+	}
+}
+
+func TestAverageAndRatio(t *testing.T) {
+	if average(0, 0) != 0 || average(10, 4) != 2.5 {
+		t.Errorf("average = %v, %v", average(0, 0), average(10, 4))
+	}
+	if ratio(1, 0) != 0 || ratio(1, 4) != 0.25 {
+		t.Errorf("ratio = %v, %v", ratio(1, 0), ratio(1, 4))
+	}
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/audit"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/logs"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// auditExportBatchSize 导出时每批读取的日志条数
+	auditExportBatchSize = 500
+	// auditExportMaxRows 单次导出的最大条数，超出请缩小时间范围
+	auditExportMaxRows = 100000
+	// auditErrorMaxLen 失败原因的最大长度，与表字段一致
+	auditErrorMaxLen = 255
+)
+
+// AuditService 管理端操作审计服务
+type AuditService struct {
+	db *gorm.DB
+}
+
+// NewAuditService 创建审计服务
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{db: db}
+}
+
+// ListAuditLogsRequest 审计日志查询请求
+type ListAuditLogsRequest struct {
+	AdminID    int64      `json:"admin_id"`
+	Action     string     `json:"action"`
+	TargetType string     `json:"target_type"`
+	TargetID   string     `json:"target_id"`
+	RequestID  string     `json:"request_id"`
+	Result     *int8      `json:"result" binding:"omitempty,oneof=0 1"`
+	StartTime  *time.Time `json:"start_time"`
+	EndTime    *time.Time `json:"end_time"`
+	Page       int        `json:"page" binding:"required,min=1"`
+	Limit      int        `json:"limit" binding:"required,min=1,max=100"`
+}
+
+// ExportAuditLogsRequest 审计日志导出请求
+type ExportAuditLogsRequest struct {
+	AdminID    int64      `json:"admin_id"`
+	Action     string     `json:"action"`
+	TargetType string     `json:"target_type"`
+	TargetID   string     `json:"target_id"`
+	RequestID  string     `json:"request_id"`
+	Result     *int8      `json:"result" binding:"omitempty,oneof=0 1"`
+	StartTime  *time.Time `json:"start_time" binding:"required"`
+	EndTime    *time.Time `json:"end_time" binding:"required"`
+}
+
+// Write 写入一条审计日志，实现 audit.Recorder；
+// 审计失败只记录错误日志，不影响已经完成的业务操作
+func (s *AuditService) Write(ctx context.Context, l *audit.Log) {
+	record := &model.AdminAuditLog{
+		AdminID:    l.AdminID,
+		AdminName:  l.AdminName,
+		Action:     l.Action,
+		TargetType: l.TargetType,
+		TargetID:   l.TargetID,
+		Result:     model.AuditResultSuccess,
+		IP:         l.IP,
+		UserAgent:  truncateRunes(l.UserAgent, 255),
+		RequestID:  l.RequestID,
+		Method:     l.Method,
+		Path:       l.Path,
+	}
+	if !l.Success {
+		record.Result = model.AuditResultFailed
+		if l.Error != "" {
+			msg := truncateRunes(l.Error, auditErrorMaxLen)
+			record.Error = &msg
+		}
+	}
+	if len(l.Params) > 0 {
+		params := string(l.Params)
+		record.Params = &params
+	}
+
+	before, beforeMap := snapshotJSON(l.Before)
+	after, afterMap := snapshotJSON(l.After)
+	record.Before, record.After = before, after
+	if changes := diffSnapshots(beforeMap, afterMap); changes != nil {
+		record.Changes = changes
+	}
+
+	if err := model.CreateAdminAuditLog(s.db.WithContext(ctx), record); err != nil {
+		logs.Business().Error("写入审计日志失败",
+			zap.String("action", l.Action),
+			zap.Int64("admin_id", l.AdminID),
+			zap.String("request_id", l.RequestID),
+			zap.Error(err),
+		)
+	}
+}
+
+// ListAuditLogs 分页查询审计日志
+func (s *AuditService) ListAuditLogs(ctx context.Context, req *ListAuditLogsRequest) ([]*model.AdminAuditLog, int64, error) {
+	filter := &model.AuditLogFilter{
+		AdminID:    req.AdminID,
+		Action:     req.Action,
+		TargetType: req.TargetType,
+		TargetID:   req.TargetID,
+		RequestID:  req.RequestID,
+		Result:     req.Result,
+		StartTime:  req.StartTime,
+		EndTime:    req.EndTime,
+	}
+	list, total, err := model.ListAdminAuditLogs(s.db.WithContext(ctx), filter, req.Page, req.Limit)
+	if err != nil {
+		return nil, 0, errors.New(errors.ErrCodeDatabaseError, "查询审计日志失败", err)
+	}
+	return list, total, nil
+}
+
+// GetAuditLog 获取审计日志详情
+func (s *AuditService) GetAuditLog(ctx context.Context, id int64) (*model.AdminAuditLog, error) {
+	log, err := model.GetAdminAuditLog(s.db.WithContext(ctx), id)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New(errors.ErrCodeNotFound, "审计日志不存在", nil)
+		}
+		return nil, errors.New(errors.ErrCodeDatabaseError, "查询审计日志失败", err)
+	}
+	return log, nil
+}
+
+// ValidateExport 导出前校验参数，校验失败时还未写出任何内容，可正常返回错误响应
+func (s *AuditService) ValidateExport(req *ExportAuditLogsRequest) error {
+	if !req.EndTime.After(*req.StartTime) {
+		return errors.New(errors.ErrCodeInvalidParams, "结束时间必须晚于开始时间", nil)
+	}
+	if req.EndTime.Sub(*req.StartTime) > 366*24*time.Hour {
+		return errors.New(errors.ErrCodeInvalidParams, "单次最多导出一年的审计日志", nil)
+	}
+	return nil
+}
+
+// ExportAuditLogs 按条件分批导出审计日志为 CSV（UTF-8 BOM，便于 Excel 直接打开）
+func (s *AuditService) ExportAuditLogs(ctx context.Context, req *ExportAuditLogsRequest, w io.Writer) error {
+	filter := &model.AuditLogFilter{
+		AdminID:    req.AdminID,
+		Action:     req.Action,
+		TargetType: req.TargetType,
+		TargetID:   req.TargetID,
+		RequestID:  req.RequestID,
+		Result:     req.Result,
+		StartTime:  req.StartTime,
+		EndTime:    req.EndTime,
+	}
+
+	if _, err := w.Write([]byte("\xEF\xBB\xBF")); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	header := []string{"ID", "操作时间", "操作人ID", "操作人", "操作", "对象类型", "对象ID",
+		"结果", "失败原因", "变更内容", "请求参数", "IP", "请求ID", "请求方法", "请求路径"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	var prev int64
+	written := 0
+	for written < auditExportMaxRows {
+		batch, err := model.ListAdminAuditLogsBefore(s.db.WithContext(ctx), filter, prev, auditExportBatchSize)
+		if err != nil {
+			return errors.New(errors.ErrCodeDatabaseError, "查询审计日志失败", err)
+		}
+		for _, l := range batch {
+			result := "成功"
+			if l.Result == model.AuditResultFailed {
+				result = "失败"
+			}
+			row := []string{
+				strconv.FormatInt(l.ID, 10),
+				l.CreatedAt.Format("2006-01-02 15:04:05"),
+				strconv.FormatInt(l.AdminID, 10),
+				l.AdminName,
+				l.Action,
+				l.TargetType,
+				l.TargetID,
+				result,
+				derefString(l.Error),
+				derefString(l.Changes),
+				derefString(l.Params),
+				l.IP,
+				l.RequestID,
+				l.Method,
+				l.Path,
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		written += len(batch)
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+		if len(batch) < auditExportBatchSize {
+			break
+		}
+		prev = batch[len(batch)-1].ID
+	}
+	return nil
+}
+
+// snapshotJSON 将快照序列化为 JSON 字符串，同时返回字段表用于计算差异；
+// 密码哈希、密钥等字段在模型上已标记 json:"-"，不会进入快照
+func snapshotJSON(v interface{}) (*string, map[string]interface{}) {
+	if v == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil || string(raw) == "null" {
+		return nil, nil
+	}
+	str := string(raw)
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		// 非对象类型的快照（如数组）只保存原文，不做字段级差异
+		return &str, nil
+	}
+	return &str, fields
+}
+
+// diffSnapshots 计算字段级差异，只有变更前后都是对象时才计算
+func diffSnapshots(before, after map[string]interface{}) *string {
+	if before == nil || after == nil {
+		return nil
+	}
+	type change struct {
+		Before interface{} `json:"before"`
+		After  interface{} `json:"after"`
+	}
+
+	keys := make(map[string]struct{}, len(before)+len(after))
+	for k := range before {
+		keys[k] = struct{}{}
+	}
+	for k := range after {
+		keys[k] = struct{}{}
+	}
+	changes := make(map[string]change)
+	for k := range keys {
+		// 更新时间每次都会变化，不作为业务差异
+		if k == "updated_at" {
+			continue
+		}
+		if !reflect.DeepEqual(before[k], after[k]) {
+			changes[k] = change{Before: before[k], After: after[k]}
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	raw, err := json.Marshal(changes)
+	if err != nil {
+		return nil
+	}
+	str := string(raw)
+	return &str
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/reusedev/uportal-api/pkg/audit"
+)
+
+func TestMaskParams(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"masks passwords and codes",
+			`{"username":"root","password":"p","old_password":"o","code":"123456"}`,
+			`{"code":"******","old_password":"******","password":"******","username":"root"}`},
+		{"masks nested and case insensitive keys",
+			`{"items":[{"Client_Secret":"s","name":"a"}],"challenge_token":"t"}`,
+			`{"challenge_token":"******","items":[{"Client_Secret":"******","name":"a"}]}`},
+		{"keeps ordinary fields", `{"status":1,"remark":"ok"}`, `{"remark":"ok","status":1}`},
+	}
+	for _, c := range cases {
+		if got := string(audit.MaskParams([]byte(c.body))); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, got, c.want)
+		}
+	}
+	if audit.MaskParams(nil) != nil || audit.MaskParams([]byte("not json")) != nil {
+		t.Errorf("empty or non-JSON body should not be recorded")
+	}
+}
+
+func TestAuditEntryOutsideRequest(t *testing.T) {
+	// 不在审计范围内的调用不应 panic
+	audit.Record(context.Background(), "user", "1", nil, map[string]int{"status": 0})
+
+	e := &audit.Entry{}
+	ctx := audit.NewContext(context.Background(), e)
+	audit.Record(ctx, "user", "1", map[string]int{"status": 1}, map[string]int{"status": 0})
+	audit.Record(ctx, "user", "1", map[string]int{"status": 9}, map[string]int{"status": 2})
+	targetType, targetID, before, after := e.Snapshot()
+	if targetType != "user" || targetID != "1" {
+		t.Errorf("target = %s/%s", targetType, targetID)
+	}
+	if !reflect.DeepEqual(before, map[string]int{"status": 1}) {
+		t.Errorf("before = %v, want the first value", before)
+	}
+	if !reflect.DeepEqual(after, map[string]int{"status": 2}) {
+		t.Errorf("after = %v, want the last value", after)
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	type snapshot struct {
+		Status    int    `json:"status"`
+		Nickname  string `json:"nickname"`
+		UpdatedAt string `json:"updated_at"`
+	}
+
+	cases := []struct {
+		name   string
+		before interface{}
+		after  interface{}
+		want   map[string]interface{}
+	}{
+		{"changed field",
+			snapshot{Status: 1, Nickname: "a", UpdatedAt: "t1"},
+			snapshot{Status: 0, Nickname: "a", UpdatedAt: "t2"},
+			map[string]interface{}{"status": map[string]interface{}{"before": 1.0, "after": 0.0}}},
+		{"only updated_at changed", snapshot{UpdatedAt: "t1"}, snapshot{UpdatedAt: "t2"}, nil},
+		{"added and removed keys",
+			map[string]interface{}{"a": 1},
+			map[string]interface{}{"b": 2},
+			map[string]interface{}{
+				"a": map[string]interface{}{"before": 1.0, "after": nil},
+				"b": map[string]interface{}{"before": nil, "after": 2.0},
+			}},
+		{"created", nil, snapshot{Status: 1}, nil},
+		{"array snapshot", []int{1}, []int{2}, nil},
+	}
+	for _, c := range cases {
+		_, before := snapshotJSON(c.before)
+		_, after := snapshotJSON(c.after)
+		diff := diffSnapshots(before, after)
+		if c.want == nil {
+			if diff != nil {
+				t.Errorf("%s: diff = %s, want none", c.name, *diff)
+			}
+			continue
+		}
+		if diff == nil {
+			t.Errorf("%s: no diff", c.name)
+			continue
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal([]byte(*diff), &got); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: diff = %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	if raw, fields := snapshotJSON([]int{1, 2}); raw == nil || *raw != "[1,2]" || fields != nil {
+		t.Errorf("array snapshot should keep raw JSON without fields")
+	}
+}
@@ -3,14 +3,19 @@ package service
 import (
 	"context"
 	stderrors "errors"
+	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/reusedev/uportal-api/internal/model"
 	"github.com/reusedev/uportal-api/pkg/config"
+	"github.com/reusedev/uportal-api/pkg/consts"
 	"github.com/reusedev/uportal-api/pkg/errors"
 	"github.com/reusedev/uportal-api/pkg/logs"
+	"github.com/reusedev/uportal-api/pkg/mailer"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sort"
 	"time"
 )
 
@@ -18,13 +23,29 @@ import (
 type AuthService struct {
 	db        *gorm.DB
 	wechatSvc *WechatService
+	mailer    mailer.Mailer
+	config    *config.Config
+	policy    *PasswordPolicy
+	risk      *RiskService
+	invites   *InviteService
 }
 
-// NewAuthService 创建认证服务实例
-func NewAuthService(db *gorm.DB, wechatSvc *WechatService) *AuthService {
+// signupBonus 第三方登录注册新用户赠送的代币数
+const signupBonus = 1000
+
+// errInvalidCredentials 密码登录失败时的统一错误
+var errInvalidCredentials = errors.New(errors.ErrCodeUnauthorized, "账号或密码错误", nil)
+
+// NewAuthService 创建认证服务实例，invites 为 nil 时登录不结算邀请活动
+func NewAuthService(db *gorm.DB, wechatSvc *WechatService, m mailer.Mailer, cfg *config.Config, risk *RiskService, invites *InviteService) *AuthService {
 	return &AuthService{
 		db:        db,
 		wechatSvc: wechatSvc,
+		mailer:    m,
+		config:    cfg,
+		policy:    NewPasswordPolicy(cfg),
+		risk:      risk,
+		invites:   invites,
 	}
 }
 
@@ -32,7 +53,7 @@ func NewAuthService(db *gorm.DB, wechatSvc *WechatService) *AuthService {
 type RegisterRequest struct {
 	Phone    string `json:"phone" binding:"omitempty,len=11"`
 	Email    string `json:"email" binding:"omitempty,email"`
-	Password string `json:"password" binding:"required,min=6,max=32"`
+	Password string `json:"password" binding:"required"`
 	Nickname string `json:"nickname" binding:"required,min=2,max=50"`
 }
 
@@ -40,7 +61,7 @@ type RegisterRequest struct {
 type LoginRequest struct {
 	Phone     string `json:"phone" binding:"omitempty,len=11"`
 	Email     string `json:"email" binding:"omitempty,email"`
-	Password  string `json:"password" binding:"required,min=6,max=32"`
+	Password  string `json:"password" binding:"required"`
 	Platform  string `json:"-"` // 登录平台，从请求头获取
 	IP        string `json:"-"` // 登录IP，从请求头获取
 	UserAgent string `json:"-"` // 设备信息，从请求头获取
@@ -52,14 +73,18 @@ type ThirdPartyLoginRequest struct {
 	ProviderUserID string  `json:"provider_user_id" binding:"required"`
 	Nickname       *string `json:"nickname" binding:"required,min=2,max=50"`
 	AvatarURL      *string `json:"avatar_url" binding:"omitempty,url"`
+	Phone          string  `json:"-"` // 第三方平台提供的已验证手机号，用于绑定和合并账号
 	Platform       string  `json:"-"` // 登录平台，从请求头获取
 	IP             string  `json:"-"` // 登录IP，从请求头获取
 	UserAgent      string  `json:"-"` // 设备信息，从请求头获取
+	DeviceID       string  `json:"-"` // 设备指纹，从请求头获取，用于风控
 }
 
 type UpdateProfileReq struct {
 	Nickname  *string `json:"nickname"`
 	AvatarURL *string `json:"avatar"`
+	Language  *string `json:"language" binding:"omitempty,max=10"` // 界面语言，如 zh-CN、en-US
+	Timezone  *string `json:"timezone"`                            // IANA 时区名，如 America/New_York，空字符串表示使用业务时区
 }
 
 // WxMiniProgramLoginRequest 微信小程序登录请求
@@ -67,15 +92,22 @@ type WxMiniProgramLoginRequest struct {
 	Code          string  `json:"code" binding:"required"`
 	Nickname      *string `json:"nickname"`
 	AvatarURL     *string `json:"avatar_url"`
-	EncryptedData string  `json:"encrypted_data"`
+	EncryptedData string  `json:"encrypted_data"` // 旧版 getUserInfo/getPhoneNumber 加密数据
 	IV            string  `json:"iv"`
+	PhoneCode     string  `json:"phone_code"` // 新版 getPhoneNumber 返回的 code
 	Platform      string  `json:"-"`
 	IP            string  `json:"-"`
 	UserAgent     string  `json:"-"`
+	DeviceID      string  `json:"-"`
 }
 
 // Register 用户注册
 func (s *AuthService) Register(ctx context.Context, req *RegisterRequest) (*model.User, error) {
+	if req.Phone == "" && req.Email == "" {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "手机号或邮箱至少提供一个", nil)
+	}
+	req.Email = normalizeEmail(req.Email)
+
 	// 检查手机号或邮箱是否已存在
 	if req.Phone != "" {
 		exists, err := s.checkPhoneExists(ctx, req.Phone)
@@ -97,25 +129,54 @@ func (s *AuthService) Register(ctx context.Context, req *RegisterRequest) (*mode
 		}
 	}
 
-	// 生成密码哈希
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	// 校验密码策略并生成密码哈希
+	passwordHash, err := s.policy.Hash(req.Password)
 	if err != nil {
-		return nil, errors.New(errors.ErrCodeInternal, "生成密码哈希失败", err)
+		return nil, err
 	}
-	passwordHashStr := string(passwordHash)
 
 	// 创建用户
 	user := &model.User{
-		Phone:        &req.Phone,
-		Email:        &req.Email,
-		PasswordHash: &passwordHashStr,
+		UserID:       model.GenerateUserID(),
+		PasswordHash: &passwordHash,
 		Nickname:     &req.Nickname,
 		Status:       1,
 	}
+	if req.Phone != "" {
+		user.Phone = model.StringPtr(req.Phone)
+	}
+	if req.Email != "" {
+		user.Email = model.StringPtr(req.Email)
+	}
 
-	err = model.CreateUser(s.db, user)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := model.CreateUser(tx, user); err != nil {
+			return errors.New(errors.ErrCodeInternal, "创建用户失败", err)
+		}
+		if req.Email != "" {
+			auth := &model.UserAuth{
+				UserID:         user.UserID,
+				Provider:       consts.AuthTypeEmail,
+				ProviderUserID: req.Email,
+			}
+			if err := model.CreateUserAuth(tx, auth); err != nil {
+				return errors.New(errors.ErrCodeInternal, "创建邮箱认证失败", err)
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, errors.New(errors.ErrCodeInternal, "创建用户失败", err)
+		return nil, err
+	}
+
+	// 发送验证邮件，失败时用户可通过重发接口再次获取
+	if req.Email != "" {
+		if err := s.sendVerification(ctx, user); err != nil {
+			logs.Business().Warn("发送邮箱验证邮件失败",
+				zap.String("user_id", user.UserID),
+				zap.Error(err),
+			)
+		}
 	}
 
 	return user, nil
@@ -130,22 +191,26 @@ func (s *AuthService) Login(ctx context.Context, req *LoginRequest) (*model.User
 	if req.Phone != "" {
 		user, err = model.GetUserByPhone(s.db, req.Phone)
 	} else if req.Email != "" {
-		user, err = model.GetUserByEmail(s.db, req.Email)
+		user, err = model.GetUserByEmail(s.db, normalizeEmail(req.Email))
 	} else {
 		return nil, "", errors.New(errors.ErrCodeInvalidParams, "手机号或邮箱至少提供一个", nil)
 	}
 
+	// 账号不存在、未设置密码与密码错误统一返回同一错误，避免枚举已注册账号
 	if err != nil {
 		if stderrors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, "", errors.New(errors.ErrCodeNotFound, "用户不存在", nil)
+			return nil, "", errInvalidCredentials
 		}
 		return nil, "", errors.New(errors.ErrCodeInternal, "查询用户失败", err)
 	}
 
-	// 验证密码
+	// 验证密码，第三方登录创建的账号没有密码
+	if user.PasswordHash == nil || *user.PasswordHash == "" {
+		return nil, "", errInvalidCredentials
+	}
 	err = bcrypt.CompareHashAndPassword([]byte(*user.PasswordHash), []byte(req.Password))
 	if err != nil {
-		return nil, "", errors.New(errors.ErrCodeUnauthorized, "密码错误", nil)
+		return nil, "", errInvalidCredentials
 	}
 
 	// 检查用户状态
@@ -153,6 +218,11 @@ func (s *AuthService) Login(ctx context.Context, req *LoginRequest) (*model.User
 		return nil, "", errors.New(errors.ErrCodeForbidden, "账号已被禁用", nil)
 	}
 
+	// 邮箱登录时检查邮箱是否已验证
+	if req.Email != "" && s.config.Account.RequireEmailVerified && user.EmailVerifiedAt == nil {
+		return nil, "", errors.ErrEmailNotVerified
+	}
+
 	// 生成JWT token
 	token, err := s.generateToken(user)
 	if err != nil {
@@ -170,9 +240,13 @@ func (s *AuthService) Login(ctx context.Context, req *LoginRequest) (*model.User
 	}
 
 	// 记录登录日志
+	loginMethod := "password"
+	if req.Email != "" {
+		loginMethod = consts.AuthTypeEmail
+	}
 	logEntry := &model.UserLoginLog{
 		UserID:        user.UserID,
-		LoginMethod:   "password",
+		LoginMethod:   loginMethod,
 		LoginPlatform: &req.Platform,
 		IPAddress:     &req.IP,
 		DeviceInfo:    &req.UserAgent,
@@ -184,6 +258,8 @@ func (s *AuthService) Login(ctx context.Context, req *LoginRequest) (*model.User
 			zap.Error(err),
 		)
 	}
+	// 登录可能达成邀请活动的 login_day 条件
+	s.invites.SettleInvite(ctx, user.UserID)
 
 	return user, token, nil
 }
@@ -218,6 +294,25 @@ func (s *AuthService) ThirdPartyLogin(ctx context.Context, req *ThirdPartyLoginR
 			if req.AvatarURL != nil {
 				updates["avatar_url"] = *req.AvatarURL
 			}
+			if req.Phone != "" && (existingUser.Phone == nil || *existingUser.Phone == "") {
+				owner, err := model.GetUserByPhone(tx, req.Phone)
+				switch {
+				case err == nil:
+					// 手机号已属于其他账号时不覆盖，需用户通过客服处理账号合并
+					logs.Business().Warn("手机号已绑定其他账号，跳过绑定",
+						zap.String("user_id", existingUser.UserID),
+						zap.String("owner_id", owner.UserID),
+					)
+				case stderrors.Is(err, gorm.ErrRecordNotFound):
+					now := time.Now()
+					updates["phone"] = req.Phone
+					updates["phone_verified_at"] = now
+					existingUser.Phone = model.StringPtr(req.Phone)
+					existingUser.PhoneVerifiedAt = &now
+				default:
+					return errors.New(errors.ErrCodeInternal, "查询手机号失败", err)
+				}
+			}
 
 			if err := model.UpdateUser(tx, existingUser.UserID, updates); err != nil {
 				return errors.New(errors.ErrCodeInternal, "更新用户信息失败", err)
@@ -232,12 +327,69 @@ func (s *AuthService) ThirdPartyLogin(ctx context.Context, req *ThirdPartyLoginR
 				zap.Error(err))
 			return errors.New(errors.ErrCodeInternal, "查询用户失败", err)
 		}
+		// 手机号已有账号时，只有该账号的手机号经过验证才将第三方账号关联过去；
+		// 注册时填写的手机号未经验证，可能被他人抢注，此时创建独立账号且不绑定手机号，由用户验证后主动合并
+		phoneTaken := false
+		if req.Phone != "" {
+			owner, err := model.GetUserByPhone(tx, req.Phone)
+			if err == nil && owner.PhoneVerifiedAt == nil {
+				phoneTaken = true
+				logs.Business().Warn("手机号属于未验证的账号，不自动关联",
+					zap.String("owner_id", owner.UserID),
+					zap.String("provider", req.Provider),
+				)
+			} else if err == nil {
+				if owner.Status != 1 {
+					return errors.New(errors.ErrCodeForbidden, "账号已被禁用，有问题请联系客服！", nil)
+				}
+				auth := &model.UserAuth{
+					UserID:         owner.UserID,
+					Provider:       req.Provider,
+					ProviderUserID: req.ProviderUserID,
+				}
+				if err := model.CreateUserAuth(tx, auth); err != nil {
+					return errors.New(errors.ErrCodeInternal, "创建第三方认证失败", err)
+				}
+				if err := model.UpdateUser(tx, owner.UserID, map[string]interface{}{
+					"last_login_at": time.Now(),
+				}); err != nil {
+					return errors.New(errors.ErrCodeInternal, "更新用户信息失败", err)
+				}
+				token, err = s.generateToken(owner)
+				if err != nil {
+					return errors.New(errors.ErrCodeInternal, "生成token失败", err)
+				}
+				user = owner
+				return nil
+			}
+			if err != nil && !stderrors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New(errors.ErrCodeInternal, "查询手机号失败", err)
+			}
+		}
+
 		now := time.Now()
-		// 不存在关联，创建新用户
+		// 不存在关联，创建新用户，注册赠送先经过风控评估
+		userID := model.GenerateUserID()
+		remark := "注册赠送"
+		risk, err := s.risk.Assess(ctx, tx, &RiskInput{
+			EventType:  model.RiskEventSignup,
+			Action:     req.Provider,
+			UserID:     userID,
+			Amount:     signupBonus,
+			ChangeType: "CONSUME",
+			Remark:     remark,
+			Client:     RiskClient{IP: req.IP, DeviceID: req.DeviceID},
+		})
+		if err != nil {
+			return err
+		}
+		if risk.Blocked() {
+			return errors.New(errors.ErrCodeForbidden, "当前设备或网络注册过于频繁，请稍后再试", nil)
+		}
 		user = &model.User{
-			TokenBalance: 1000,
+			TokenBalance: risk.Amount,
 			Status:       1,
-			UserID:       model.GenerateUserID(),
+			UserID:       userID,
 			LastLoginAt:  &now,
 		}
 		logs.Business().Warn("创建登录日志失败",
@@ -251,6 +403,10 @@ func (s *AuthService) ThirdPartyLogin(ctx context.Context, req *ThirdPartyLoginR
 		if req.AvatarURL != nil {
 			user.AvatarURL = req.AvatarURL
 		}
+		if req.Phone != "" && !phoneTaken {
+			user.Phone = model.StringPtr(req.Phone)
+			user.PhoneVerifiedAt = &now
+		}
 
 		err = tx.Create(user).Error
 		if err != nil {
@@ -266,17 +422,19 @@ func (s *AuthService) ThirdPartyLogin(ctx context.Context, req *ThirdPartyLoginR
 		if err := model.CreateUserAuth(tx, auth); err != nil {
 			return errors.New(errors.ErrCodeInternal, "创建第三方认证失败", err)
 		}
-		remark := "注册赠送"
-		record := &model.TokenRecord{
-			UserID:       user.UserID,
-			ChangeAmount: 1000,
-			BalanceAfter: 1000,
-			ChangeType:   "CONSUME",
-			Remark:       &remark,
-			ChangeTime:   time.Now(),
-		}
+		// 赠送被风控暂扣时不写代币记录，审核通过后补发
+		if risk.Amount > 0 {
+			record := &model.TokenRecord{
+				UserID:       user.UserID,
+				ChangeAmount: risk.Amount,
+				BalanceAfter: risk.Amount,
+				ChangeType:   "CONSUME",
+				Remark:       &remark,
+				ChangeTime:   time.Now(),
+			}
 
-		model.CreateTokenRecord(tx, record)
+			model.CreateTokenRecord(tx, record)
+		}
 
 		// 生成token
 		token, err = s.generateToken(user)
@@ -306,6 +464,8 @@ func (s *AuthService) ThirdPartyLogin(ctx context.Context, req *ThirdPartyLoginR
 			zap.Error(err),
 		)
 	}
+	// 登录可能达成邀请活动的 login_day 条件
+	s.invites.SettleInvite(ctx, user.UserID)
 
 	return user, token, nil
 }
@@ -359,6 +519,44 @@ func (s *AuthService) UpdateUser(ctx context.Context, id string, updates map[str
 	return nil
 }
 
+// UpdateTimezone 修改用户时区，空字符串表示使用业务时区。每日次数和签到日期按用户时区划分，
+// 两次修改至少间隔 locale.timezoneChangeWindow，避免来回切换时区在同一天重复领取奖励
+func (s *AuthService) UpdateTimezone(ctx context.Context, userID, timezone string) error {
+	if err := ValidateTimezone(timezone); err != nil {
+		return err
+	}
+	user, err := model.GetUserByID(s.db.WithContext(ctx), userID)
+	if err != nil {
+		return errors.New(errors.ErrCodeInternal, "获取用户信息失败", err)
+	}
+	current := ""
+	if user.Timezone != nil {
+		current = *user.Timezone
+	}
+	if current == timezone {
+		return nil
+	}
+
+	var value interface{}
+	if timezone != "" {
+		value = timezone
+	}
+	now := time.Now()
+	window := s.config.Locale.TimezoneChangeWindow
+	// 条件更新保证并发请求也只有一个能在间隔内生效
+	result := s.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ? AND (timezone_set_at IS NULL OR timezone_set_at <= ?)", userID, now.Add(-window)).
+		Updates(map[string]interface{}{"timezone": value, "timezone_set_at": now})
+	if result.Error != nil {
+		return errors.New(errors.ErrCodeInternal, "修改时区失败", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New(errors.ErrCodeInvalidParams,
+			fmt.Sprintf("时区每 %d 天只能修改一次", int(window.Hours()/24)), nil)
+	}
+	return nil
+}
+
 // ChangePassword 修改密码
 func (s *AuthService) ChangePassword(ctx context.Context, userID string, oldPassword, newPassword string) error {
 	// 获取用户信息
@@ -371,17 +569,22 @@ func (s *AuthService) ChangePassword(ctx context.Context, userID string, oldPass
 	}
 
 	// 验证旧密码
+	if user.PasswordHash == nil || *user.PasswordHash == "" {
+		return errors.New(errors.ErrCodeForbidden, "该账号未设置密码，请使用找回密码设置", nil)
+	}
 	err = bcrypt.CompareHashAndPassword([]byte(*user.PasswordHash), []byte(oldPassword))
 	if err != nil {
 		return errors.New(errors.ErrCodeUnauthorized, "旧密码错误", nil)
 	}
+	if oldPassword == newPassword {
+		return errors.New(errors.ErrCodeInvalidParams, "新密码不能与旧密码相同", nil)
+	}
 
-	// 生成新密码哈希
-	newPasswordHash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	// 校验密码策略并生成新密码哈希
+	passwordHashStr, err := s.policy.Hash(newPassword)
 	if err != nil {
-		return errors.New(errors.ErrCodeInternal, "生成密码哈希失败", err)
+		return err
 	}
-	passwordHashStr := string(newPasswordHash)
 
 	// 更新密码
 	err = model.UpdateUser(s.db, userID, map[string]interface{}{
@@ -391,6 +594,14 @@ func (s *AuthService) ChangePassword(ctx context.Context, userID string, oldPass
 		return errors.New(errors.ErrCodeInternal, "更新密码失败", err)
 	}
 
+	// 修改密码后之前签发的重置链接全部失效
+	if err := model.InvalidateAccountTokens(s.db, userID, model.AccountTokenPasswordReset); err != nil {
+		logs.Business().Warn("作废密码重置令牌失败",
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+	}
+
 	return nil
 }
 
@@ -409,15 +620,54 @@ func (s *AuthService) WxMiniProgramLogin(ctx context.Context, req *WxMiniProgram
 	if err != nil {
 		return nil, "", err
 	}
+
+	// 解密旧版加密数据，可能是用户信息也可能是手机号
+	var userInfo map[string]interface{}
+	if req.EncryptedData != "" && req.IV != "" {
+		userInfo, err = s.wechatSvc.DecryptUserInfo(wxResult.SessionKey, req.EncryptedData, req.IV)
+		if err != nil {
+			logs.Business().Warn("解密用户信息失败",
+				zap.String("openid", wxResult.OpenID),
+				zap.Error(err),
+			)
+		}
+	}
+
+	// 获取手机号：优先使用新版 code 换取，其次使用旧版加密数据
+	var phone string
+	if req.PhoneCode != "" {
+		phoneInfo, err := s.wechatSvc.GetPhoneNumber(ctx, req.PhoneCode)
+		if err != nil {
+			logs.Business().Warn("获取微信手机号失败",
+				zap.String("openid", wxResult.OpenID),
+				zap.Error(err),
+			)
+		} else {
+			phone = phoneInfo.Phone()
+		}
+	} else if _, ok := userInfo["purePhoneNumber"]; ok {
+		phoneInfo, err := s.wechatSvc.DecryptPhoneNumber(wxResult.SessionKey, req.EncryptedData, req.IV)
+		if err != nil {
+			logs.Business().Warn("解密微信手机号失败",
+				zap.String("openid", wxResult.OpenID),
+				zap.Error(err),
+			)
+		} else {
+			phone = phoneInfo.Phone()
+		}
+	}
+
 	// 使用 openid 作为 provider_user_id 进行第三方登录
 	thirdPartyReq := &ThirdPartyLoginRequest{
 		Provider:       "wechat",
 		ProviderUserID: wxResult.OpenID,
 		Nickname:       req.Nickname,
 		AvatarURL:      req.AvatarURL,
+		Phone:          phone,
 		Platform:       req.Platform,
 		IP:             req.IP,
 		UserAgent:      req.UserAgent,
+		DeviceID:       req.DeviceID,
 	}
 
 	// 调用第三方登录方法
@@ -426,33 +676,141 @@ func (s *AuthService) WxMiniProgramLogin(ctx context.Context, req *WxMiniProgram
 		return nil, "", err
 	}
 
-	// 如果有加密数据，解密并更新用户信息
-	if req.EncryptedData != "" && req.IV != "" {
-		userInfo, err := s.wechatSvc.DecryptUserInfo(wxResult.SessionKey, req.EncryptedData, req.IV)
-		if err != nil {
-			logs.Business().Warn("解密用户信息失败",
+	// 如果解密出用户信息，更新昵称和头像
+	updates := make(map[string]interface{})
+	if nickname, ok := userInfo["nickName"].(string); ok && nickname != "" {
+		updates["nickname"] = nickname
+	}
+	if avatarURL, ok := userInfo["avatarUrl"].(string); ok && avatarURL != "" {
+		updates["avatar_url"] = avatarURL
+	}
+	if len(updates) > 0 {
+		if err := model.UpdateUser(s.db, user.UserID, updates); err != nil {
+			logs.Business().Warn("更新用户信息失败",
 				zap.String("user_id", user.UserID),
 				zap.Error(err),
 			)
-		} else {
-			// 更新用户信息
-			updates := make(map[string]interface{})
-			if nickname, ok := userInfo["nickName"].(string); ok && nickname != "" {
-				updates["nickname"] = nickname
+		}
+	}
+
+	return user, token, nil
+}
+
+// BindWechatPhone 使用 getPhoneNumber 返回的 code 为当前用户绑定手机号
+func (s *AuthService) BindWechatPhone(ctx context.Context, userID, code string) (string, error) {
+	phoneInfo, err := s.wechatSvc.GetPhoneNumber(ctx, code)
+	if err != nil {
+		return "", err
+	}
+	phone := phoneInfo.Phone()
+
+	owner, err := model.GetUserByPhone(s.db, phone)
+	if err == nil && owner.UserID != userID {
+		return "", errors.ErrPhoneExists
+	}
+	if err != nil && !stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return "", errors.New(errors.ErrCodeInternal, "查询手机号失败", err)
+	}
+
+	// 本人注册时填写的手机号经微信验证后同样标记为已验证
+	if err := model.UpdateUser(s.db, userID, map[string]interface{}{
+		"phone":             phone,
+		"phone_verified_at": time.Now(),
+	}); err != nil {
+		return "", errors.New(errors.ErrCodeInternal, "绑定手机号失败", err)
+	}
+	return phone, nil
+}
+
+// MergePhoneAccount 将当前账号合并到持有同一手机号的密码账号：需用微信 getPhoneNumber 的 code 证明手机号归属，
+// 并提供该账号的密码。当前账号的第三方绑定和代币余额转入目标账号，当前账号被禁用，返回目标账号及新令牌
+func (s *AuthService) MergePhoneAccount(ctx context.Context, userID, code, password string) (*model.User, string, error) {
+	phoneInfo, err := s.wechatSvc.GetPhoneNumber(ctx, code)
+	if err != nil {
+		return nil, "", err
+	}
+	phone := phoneInfo.Phone()
+
+	var target *model.User
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		owner, err := model.GetUserByPhone(tx, phone)
+		if err != nil {
+			if stderrors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New(errors.ErrCodeNotFound, "该手机号没有可合并的账号", nil)
 			}
-			if avatarURL, ok := userInfo["avatarUrl"].(string); ok && avatarURL != "" {
-				updates["avatar_url"] = avatarURL
+			return errors.New(errors.ErrCodeInternal, "查询手机号失败", err)
+		}
+		if owner.UserID == userID {
+			return errors.New(errors.ErrCodeInvalidParams, "不能合并到当前账号", nil)
+		}
+		if owner.PasswordHash == nil || bcrypt.CompareHashAndPassword([]byte(*owner.PasswordHash), []byte(password)) != nil {
+			return errors.New(errors.ErrCodeUnauthorized, "手机号或密码错误", nil)
+		}
+		if owner.Status != 1 {
+			return errors.New(errors.ErrCodeForbidden, "账号已被禁用", nil)
+		}
+
+		// 按用户ID顺序加锁
+		ids := []string{userID, owner.UserID}
+		sort.Strings(ids)
+		var users []*model.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", ids).Order("id").Find(&users).Error; err != nil {
+			return errors.New(errors.ErrCodeInternal, "获取用户信息失败", err)
+		}
+		var source *model.User
+		for _, u := range users {
+			switch u.UserID {
+			case userID:
+				source = u
+			case owner.UserID:
+				target = u
 			}
-			if len(updates) > 0 {
-				if err := model.UpdateUser(s.db, user.UserID, updates); err != nil {
-					logs.Business().Warn("更新用户信息失败",
-						zap.String("user_id", user.UserID),
-						zap.Error(err),
-					)
+		}
+		if source == nil || target == nil {
+			return errors.New(errors.ErrCodeUserNotFound, "用户不存在", nil)
+		}
+
+		if err := tx.Model(&model.UserAuth{}).Where("user_id = ?", source.UserID).
+			Update("user_id", target.UserID).Error; err != nil {
+			return errors.New(errors.ErrCodeInternal, "转移第三方绑定失败", err)
+		}
+		if amount := source.TokenBalance; amount > 0 {
+			now := time.Now()
+			remark := "账号合并"
+			if err := model.UpdateUserTokenBalance(tx, source.UserID, -amount); err != nil {
+				return errors.New(errors.ErrCodeInternal, "转出代币失败", err)
+			}
+			if err := model.UpdateUserTokenBalance(tx, target.UserID, amount); err != nil {
+				return errors.New(errors.ErrCodeInternal, "转入代币失败", err)
+			}
+			for _, r := range []*model.TokenRecord{
+				{UserID: source.UserID, ChangeAmount: -amount, BalanceAfter: 0, ChangeType: "ACCOUNT_MERGE", Remark: &remark, ChangeTime: now},
+				{UserID: target.UserID, ChangeAmount: amount, BalanceAfter: target.TokenBalance + amount, ChangeType: "ACCOUNT_MERGE", Remark: &remark, ChangeTime: now},
+			} {
+				if err := model.CreateTokenRecord(tx, r); err != nil {
+					return errors.New(errors.ErrCodeInternal, "创建代币记录失败", err)
 				}
 			}
+			target.TokenBalance += amount
+		}
+		now := time.Now()
+		if err := model.UpdateUser(tx, source.UserID, map[string]interface{}{"status": 0, "updated_at": now}); err != nil {
+			return errors.New(errors.ErrCodeInternal, "禁用原账号失败", err)
+		}
+		if err := model.UpdateUser(tx, target.UserID, map[string]interface{}{"phone_verified_at": now, "last_login_at": now}); err != nil {
+			return errors.New(errors.ErrCodeInternal, "更新用户信息失败", err)
 		}
+		target.PhoneVerifiedAt = &now
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
 	}
 
-	return user, token, nil
+	logs.Business().Info("账号已合并", zap.String("from", userID), zap.String("to", target.UserID))
+	token, err := s.generateToken(target)
+	if err != nil {
+		return nil, "", errors.New(errors.ErrCodeInternal, "生成token失败", err)
+	}
+	return target, token, nil
 }
@@ -0,0 +1,457 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 连续签到默认每轮奖励和补签期限
+var (
+	defaultCheckinRewards    = []int{10, 20, 30, 40, 50, 60, 100}
+	defaultCheckinMakeupDays = 7
+)
+
+// checkinStreakVerifier 连续签到任务只能通过签到接口完成，配置在创建和更新任务时校验
+var checkinStreakVerifier = typedTaskVerifier[checkinStreakConfig]{
+	validate: func(cfg *checkinStreakConfig) error {
+		if len(cfg.Rewards) == 0 {
+			cfg.Rewards = defaultCheckinRewards
+		}
+		if len(cfg.Rewards) > 31 {
+			return errors.New(errors.ErrCodeInvalidParams, "每轮签到天数不能超过 31 天", nil)
+		}
+		for _, r := range cfg.Rewards {
+			if r < 0 {
+				return errors.New(errors.ErrCodeInvalidParams, "签到奖励不能为负数", nil)
+			}
+		}
+		if cfg.MakeupCost < 0 {
+			return errors.New(errors.ErrCodeInvalidParams, "补签消耗不能为负数", nil)
+		}
+		if cfg.MakeupDays < 0 || cfg.MakeupDays > 31 {
+			return errors.New(errors.ErrCodeInvalidParams, "可补签天数需在 0-31 之间", nil)
+		}
+		if cfg.MakeupDays == 0 {
+			cfg.MakeupDays = defaultCheckinMakeupDays
+		}
+		return ValidateTimezone(cfg.Timezone)
+	},
+	verify: func(ctx context.Context, in *TaskVerifyInput, cfg *checkinStreakConfig) error {
+		return errors.New(errors.ErrCodeTaskVerifyFailed, "请通过签到接口签到", nil)
+	},
+}
+
+// location 划分签到自然日的时区，未配置时使用 fallback（用户时区）
+func (c *checkinStreakConfig) location(fallback *time.Location) *time.Location {
+	return ResolveLocation(c.Timezone, fallback)
+}
+
+// rewardFor 连续签到第 streak 天在本轮中的天数和奖励
+func (c *checkinStreakConfig) rewardFor(streak int) (cycleDay, reward int) {
+	cycleDay = (streak-1)%len(c.Rewards) + 1
+	return cycleDay, c.Rewards[cycleDay-1]
+}
+
+// CheckinRequest 签到请求
+type CheckinRequest struct {
+	TaskID int `json:"task_id" binding:"required"`
+}
+
+// MakeupCheckinRequest 补签请求
+type MakeupCheckinRequest struct {
+	TaskID int    `json:"task_id" binding:"required"`
+	Date   string `json:"date" binding:"required"` // 补签日期，格式 2006-01-02
+}
+
+// CheckinResult 签到结果
+type CheckinResult struct {
+	Date     string `json:"date"`      // 签到日期
+	Streak   int    `json:"streak"`    // 当前连续签到天数
+	CycleDay int    `json:"cycle_day"` // 签到日期在本轮中是第几天
+	Reward   int    `json:"reward"`    // 获得的代币，补签为 0
+	Cost     int    `json:"cost"`      // 补签消耗的代币
+	Balance  int64  `json:"balance"`   // 签到后的代币余额
+}
+
+// CheckinDay 签到日历中的一天
+type CheckinDay struct {
+	Date      string `json:"date"`
+	CheckedIn bool   `json:"checked_in"`
+	Makeup    bool   `json:"makeup"`     // 是否为补签
+	Reward    int    `json:"reward"`     // 当天获得的代币
+	StreakDay int    `json:"streak_day"` // 截至当天的连续签到天数
+	CanMakeup bool   `json:"can_makeup"` // 当天是否可以补签
+}
+
+// CheckinCalendar 月度签到日历
+type CheckinCalendar struct {
+	TaskID       int           `json:"task_id"`
+	Month        string        `json:"month"`
+	Today        string        `json:"today"`
+	Timezone     string        `json:"timezone"`
+	Streak       int           `json:"streak"`        // 当前连续签到天数，今天和昨天都未签到时为 0
+	CheckedToday bool          `json:"checked_today"` // 今天是否已签到
+	NextReward   int           `json:"next_reward"`   // 下一次签到可获得的代币
+	Rewards      []int         `json:"rewards"`       // 每轮各天的奖励
+	MakeupCost   int           `json:"makeup_cost"`   // 补签一天消耗的代币，0 表示不支持补签
+	Days         []*CheckinDay `json:"days"`
+}
+
+// checkinDate 把某时区下的自然日转换为入库的日期值，数据库连接按服务器时区解析 DATE 列
+func checkinDate(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// isCheckinStreakTask 任务是否为连续签到任务
+func isCheckinStreakTask(task *model.RewardTask) bool {
+	return task.TaskKey == TaskKeyCheckinStreak || strings.HasPrefix(task.TaskKey, TaskKeyCheckinStreak+":")
+}
+
+// loadCheckinTask 获取连续签到任务及其配置
+func (s *TaskService) loadCheckinTask(ctx context.Context, tx *gorm.DB, taskID int) (*model.RewardTask, *checkinStreakConfig, error) {
+	task, err := s.getTaskWithDB(ctx, tx, taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !isCheckinStreakTask(task) {
+		return nil, nil, errors.New(errors.ErrCodeTaskNotAvailable, "该任务不是签到任务", nil)
+	}
+	cfg, err := checkinStreakVerifier.parse(task.VerifyConfig)
+	if err != nil {
+		return nil, nil, errors.New(errors.ErrCodeInternal, "签到任务配置无效", err)
+	}
+	return task, cfg, nil
+}
+
+// getTaskWithDB 在指定连接（如事务）中获取任务
+func (s *TaskService) getTaskWithDB(ctx context.Context, db *gorm.DB, taskID int) (*model.RewardTask, error) {
+	var task model.RewardTask
+	if err := db.WithContext(ctx).First(&task, taskID).Error; err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New(errors.ErrCodeNotFound, "任务不存在", nil)
+		}
+		return nil, errors.New(errors.ErrCodeInternal, "获取任务失败", err)
+	}
+	if err := s.loadTaskTags(&task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// getCheckinRecord 获取某天的签到记录，不存在时返回 nil
+func getCheckinRecord(tx *gorm.DB, userID string, taskID int, date time.Time) (*model.TaskCompletionRecord, error) {
+	var record model.TaskCompletionRecord
+	err := tx.Where("user_id = ? AND task_id = ? AND checkin_date = ?", userID, taskID, date.Format(time.DateOnly)).
+		First(&record).Error
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, errors.New(errors.ErrCodeInternal, "获取签到记录失败", err)
+	}
+	return &record, nil
+}
+
+// streakBefore 截至 date 前一天的连续签到天数
+func streakBefore(tx *gorm.DB, userID string, taskID int, date time.Time) (int, error) {
+	prev, err := getCheckinRecord(tx, userID, taskID, date.AddDate(0, 0, -1))
+	if err != nil || prev == nil {
+		return 0, err
+	}
+	return prev.StreakDay, nil
+}
+
+// Checkin 今日签到，按连续签到天数发放本轮对应的奖励
+func (s *TaskService) Checkin(ctx context.Context, userID string, taskID int) (*CheckinResult, error) {
+	return s.checkin(ctx, userID, taskID, "")
+}
+
+// MakeupCheckin 补签最近几天中漏签的一天，扣除补签代币，不发放当天奖励，只接续连续签到天数
+func (s *TaskService) MakeupCheckin(ctx context.Context, userID string, taskID int, date string) (*CheckinResult, error) {
+	return s.checkin(ctx, userID, taskID, date)
+}
+
+// checkin 签到或补签，makeupDate 为空表示今日签到
+func (s *TaskService) checkin(ctx context.Context, userID string, taskID int, makeupDate string) (*CheckinResult, error) {
+	// 与上报完成任务共用分布式锁
+	lockKey := fmt.Sprintf("task_completion_lock:%s:%d", userID, taskID)
+	acquired, err := s.redis.SetNX(ctx, lockKey, "1", 10*time.Second).Result()
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "获取任务锁失败", err)
+	}
+	if !acquired {
+		return nil, errors.New(errors.ErrCodeTooManyRequests, "签到正在处理中，请稍后重试", nil)
+	}
+	defer s.redis.Del(ctx, lockKey)
+
+	tx := s.db.WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "开启事务失败", tx.Error)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	result, task, err := s.checkinWithTx(ctx, tx, userID, taskID, makeupDate)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "提交事务失败", err)
+	}
+
+	if result.Reward > 0 {
+		rewarded := *task
+		rewarded.TokenReward = result.Reward
+		s.notifier.NotifyTaskReward(context.Background(), userID, &rewarded)
+	}
+	s.trackTaskCompletion(userID, task)
+	s.logger.Info("签到成功",
+		zap.String("user_id", userID),
+		zap.Int("task_id", taskID),
+		zap.String("date", result.Date),
+		zap.Int("streak", result.Streak),
+		zap.Int("reward", result.Reward),
+		zap.Int("cost", result.Cost),
+	)
+	return result, nil
+}
+
+// checkinWithTx 在事务中签到或补签
+func (s *TaskService) checkinWithTx(ctx context.Context, tx *gorm.DB, userID string, taskID int, makeupDate string) (*CheckinResult, *model.RewardTask, error) {
+	task, cfg, err := s.loadCheckinTask(ctx, tx, taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	loc := cfg.location(s.userLocation(tx, userID))
+	if err := s.checkTaskOpen(userID, task, now, loc); err != nil {
+		return nil, nil, err
+	}
+	user, err := lockTaskUser(tx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	today := checkinDate(now, loc)
+	date := today
+	makeup := makeupDate != ""
+	if makeup {
+		if cfg.MakeupCost == 0 {
+			return nil, nil, errors.New(errors.ErrCodeTaskNotAvailable, "该签到任务不支持补签", nil)
+		}
+		d, err := time.ParseInLocation(time.DateOnly, makeupDate, time.Local)
+		if err != nil {
+			return nil, nil, errors.New(errors.ErrCodeInvalidParams, "无效的补签日期", err)
+		}
+		if !d.Before(today) || d.Before(today.AddDate(0, 0, -cfg.MakeupDays)) {
+			return nil, nil, errors.New(errors.ErrCodeInvalidParams, fmt.Sprintf("只能补签最近 %d 天", cfg.MakeupDays), nil)
+		}
+		if task.ValidFrom != nil && d.Format(time.DateOnly) < task.ValidFrom.Format(time.DateOnly) {
+			return nil, nil, errors.New(errors.ErrCodeInvalidParams, "补签日期早于任务开始日期", nil)
+		}
+		date = d
+	}
+
+	existing, err := getCheckinRecord(tx, userID, taskID, date)
+	if err != nil {
+		return nil, nil, err
+	}
+	if existing != nil {
+		if makeup {
+			return nil, nil, errors.New(errors.ErrCodeTaskLimitExceeded, "该日期已签到", nil)
+		}
+		return nil, nil, errors.New(errors.ErrCodeTaskLimitExceeded, "今日已签到", nil)
+	}
+
+	prevStreak, err := streakBefore(tx, userID, taskID, date)
+	if err != nil {
+		return nil, nil, err
+	}
+	streak := prevStreak + 1
+	cycleDay, reward := cfg.rewardFor(streak)
+
+	result := &CheckinResult{Date: date.Format(time.DateOnly), CycleDay: cycleDay}
+	if makeup {
+		reward = 0
+		result.Cost = cfg.MakeupCost
+		if err := s.chargeMakeup(tx, user, task, cfg.MakeupCost, result.Date); err != nil {
+			return nil, nil, err
+		}
+	} else if reward > 0 {
+		if err := s.grantTaskTokens(ctx, tx, userID, task, reward); err != nil {
+			return nil, nil, err
+		}
+	}
+	result.Reward = reward
+
+	record := &model.TaskCompletionRecord{
+		UserID:      userID,
+		TaskID:      taskID,
+		TokenReward: reward,
+		CheckinDate: &date,
+		StreakDay:   streak,
+		CompletedAt: time.Now(),
+	}
+	if makeup {
+		record.Makeup = 1
+	}
+	if err := tx.Create(record).Error; err != nil {
+		return nil, nil, errors.New(errors.ErrCodeInternal, "创建签到记录失败", err)
+	}
+
+	// 补签接上了之后的签到时，顺延之后各天的连续天数
+	if makeup {
+		if err := s.relinkStreak(tx, userID, taskID, date, streak, today); err != nil {
+			return nil, nil, err
+		}
+	}
+	if result.Streak, _, err = currentStreak(tx, userID, taskID, today); err != nil {
+		return nil, nil, err
+	}
+
+	if result.Balance, err = s.getUserToken(ctx, tx, userID); err != nil {
+		return nil, nil, err
+	}
+	return result, task, nil
+}
+
+// chargeMakeup 扣除补签消耗的代币并记录代币明细
+func (s *TaskService) chargeMakeup(tx *gorm.DB, user *model.User, task *model.RewardTask, cost int, date string) error {
+	if user.TokenBalance < cost {
+		return errors.New(errors.ErrCodeInsufficientBalance, "代币余额不足，无法补签", nil)
+	}
+	if err := model.UpdateUserTokenBalance(tx, user.UserID, -cost); err != nil {
+		return errors.New(errors.ErrCodeInternal, "扣除补签代币失败", err)
+	}
+	remark := fmt.Sprintf("%s补签 %s", task.TaskName, date)
+	if err := model.CreateTokenRecord(tx, &model.TokenRecord{
+		UserID:       user.UserID,
+		ChangeAmount: -cost,
+		BalanceAfter: user.TokenBalance - cost,
+		ChangeType:   "CHECKIN_MAKEUP",
+		TaskID:       &task.TaskID,
+		Remark:       &remark,
+		ChangeTime:   time.Now(),
+	}); err != nil {
+		return errors.New(errors.ErrCodeInternal, "创建代币记录失败", err)
+	}
+	return nil
+}
+
+// relinkStreak 补签 date 后依次更新之后连续各天的连续天数
+func (s *TaskService) relinkStreak(tx *gorm.DB, userID string, taskID int, date time.Time, streak int, today time.Time) error {
+	var records []*model.TaskCompletionRecord
+	if err := tx.Where("user_id = ? AND task_id = ? AND checkin_date > ? AND checkin_date <= ?",
+		userID, taskID, date.Format(time.DateOnly), today.Format(time.DateOnly)).
+		Order("checkin_date ASC").
+		Find(&records).Error; err != nil {
+		return errors.New(errors.ErrCodeInternal, "获取签到记录失败", err)
+	}
+
+	for _, r := range linkedCheckins(records, date, streak) {
+		if err := tx.Model(r).Update("streak_day", r.StreakDay).Error; err != nil {
+			return errors.New(errors.ErrCodeInternal, "更新连续签到天数失败", err)
+		}
+	}
+	return nil
+}
+
+// linkedCheckins 从 date（连续第 streak 天）起逐日接上的签到记录，按顺延后的连续天数更新 StreakDay；
+// records 需按日期升序，遇到断签即停止
+func linkedCheckins(records []*model.TaskCompletionRecord, date time.Time, streak int) []*model.TaskCompletionRecord {
+	last := date
+	for i, r := range records {
+		if r.CheckinDate.Format(time.DateOnly) != last.AddDate(0, 0, 1).Format(time.DateOnly) {
+			return records[:i]
+		}
+		streak++
+		r.StreakDay = streak
+		last = *r.CheckinDate
+	}
+	return records
+}
+
+// currentStreak 当前连续签到天数及今天是否已签到，今天和昨天都未签到时连续天数为 0
+func currentStreak(db *gorm.DB, userID string, taskID int, today time.Time) (int, bool, error) {
+	r, err := getCheckinRecord(db, userID, taskID, today)
+	if err != nil {
+		return 0, false, err
+	}
+	if r != nil {
+		return r.StreakDay, true, nil
+	}
+	streak, err := streakBefore(db, userID, taskID, today)
+	return streak, false, err
+}
+
+// GetCheckinCalendar 获取某月的签到日历，month 格式 2006-01，为空表示本月
+func (s *TaskService) GetCheckinCalendar(ctx context.Context, userID string, taskID int, month string) (*CheckinCalendar, error) {
+	task, cfg, err := s.loadCheckinTask(ctx, s.db, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := cfg.location(s.userLocation(s.db.WithContext(ctx), userID))
+	today := checkinDate(time.Now(), loc)
+	first := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.Local)
+	if month != "" {
+		if first, err = time.ParseInLocation("2006-01", month, time.Local); err != nil {
+			return nil, errors.New(errors.ErrCodeInvalidParams, "无效的月份", err)
+		}
+	}
+	next := first.AddDate(0, 1, 0)
+
+	var records []*model.TaskCompletionRecord
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ? AND task_id = ? AND checkin_date >= ? AND checkin_date < ?",
+			userID, taskID, first.Format(time.DateOnly), next.Format(time.DateOnly)).
+		Find(&records).Error; err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "获取签到记录失败", err)
+	}
+	byDate := make(map[string]*model.TaskCompletionRecord, len(records))
+	for _, r := range records {
+		byDate[r.CheckinDate.Format(time.DateOnly)] = r
+	}
+
+	cal := &CheckinCalendar{
+		TaskID:     task.TaskID,
+		Month:      first.Format("2006-01"),
+		Today:      today.Format(time.DateOnly),
+		Timezone:   loc.String(),
+		Rewards:    cfg.Rewards,
+		MakeupCost: cfg.MakeupCost,
+	}
+	if cal.Streak, cal.CheckedToday, err = currentStreak(s.db.WithContext(ctx), userID, taskID, today); err != nil {
+		return nil, err
+	}
+	// 今天已签到时为明天的奖励
+	_, cal.NextReward = cfg.rewardFor(cal.Streak + 1)
+
+	earliestMakeup := today.AddDate(0, 0, -cfg.MakeupDays)
+	for d := first; d.Before(next); d = d.AddDate(0, 0, 1) {
+		day := &CheckinDay{Date: d.Format(time.DateOnly)}
+		if r, ok := byDate[day.Date]; ok {
+			day.CheckedIn = true
+			day.Makeup = r.Makeup == 1
+			day.Reward = r.TokenReward
+			day.StreakDay = r.StreakDay
+		} else {
+			day.CanMakeup = cfg.MakeupCost > 0 && d.Before(today) && !d.Before(earliestMakeup)
+		}
+		cal.Days = append(cal.Days, day)
+	}
+	return cal, nil
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+)
+
+func TestCheckinRewardFor(t *testing.T) {
+	cfg := &checkinStreakConfig{Rewards: []int{10, 20, 30}}
+	cases := []struct {
+		streak   int
+		cycleDay int
+		reward   int
+	}{
+		{1, 1, 10},
+		{2, 2, 20},
+		{3, 3, 30},
+		{4, 1, 10},
+		{6, 3, 30},
+		{7, 1, 10},
+	}
+	for _, c := range cases {
+		cycleDay, reward := cfg.rewardFor(c.streak)
+		if cycleDay != c.cycleDay || reward != c.reward {
+			t.Errorf("rewardFor(%d) = %d/%d, want %d/%d", c.streak, cycleDay, reward, c.cycleDay, c.reward)
+		}
+	}
+}
+
+func TestCheckinStreakConfig(t *testing.T) {
+	cases := []struct {
+		name string
+		raw  string
+		ok   bool
+	}{
+		{"defaults", "", true},
+		{"custom rewards", `{"rewards":[5,10],"makeup_cost":20,"makeup_days":3}`, true},
+		{"too many days", `{"rewards":[` + strings.Repeat("1,", 31) + `1]}`, false},
+		{"negative reward", `{"rewards":[10,-1]}`, false},
+		{"negative makeup cost", `{"makeup_cost":-1}`, false},
+		{"makeup days too long", `{"makeup_days":32}`, false},
+		{"invalid timezone", `{"timezone":"Mars/Base"}`, false},
+		{"valid timezone", `{"timezone":"Asia/Shanghai"}`, true},
+	}
+	for _, c := range cases {
+		err := checkinStreakVerifier.ValidateConfig(json.RawMessage(c.raw))
+		if (err == nil) != c.ok {
+			t.Errorf("%s: got %v, want ok=%v", c.name, err, c.ok)
+		}
+	}
+
+	cfg, err := checkinStreakVerifier.parse(nil)
+	if err != nil {
+		t.Fatalf("parse defaults: %v", err)
+	}
+	if len(cfg.Rewards) != len(defaultCheckinRewards) || cfg.MakeupDays != defaultCheckinMakeupDays {
+		t.Errorf("defaults = %+v", cfg)
+	}
+}
+
+func TestLinkedCheckins(t *testing.T) {
+	day := func(d int) *time.Time {
+		date := time.Date(2026, 3, d, 0, 0, 0, 0, time.Local)
+		return &date
+	}
+	records := func(days ...int) []*model.TaskCompletionRecord {
+		list := make([]*model.TaskCompletionRecord, len(days))
+		for i, d := range days {
+			list[i] = &model.TaskCompletionRecord{CheckinDate: day(d), StreakDay: 1}
+		}
+		return list
+	}
+
+	cases := []struct {
+		name    string
+		records []*model.TaskCompletionRecord
+		streak  int
+		want    []int
+	}{
+		{"no later check-ins", records(), 3, nil},
+		{"fills the gap before later days", records(6, 7, 8), 3, []int{4, 5, 6}},
+		{"stops at the next gap", records(6, 7, 9, 10), 1, []int{2, 3}},
+		{"not adjacent", records(7, 8), 2, nil},
+		{"continues past the cycle length", records(6, 7, 8, 9), 5, []int{6, 7, 8, 9}},
+	}
+	for _, c := range cases {
+		got := linkedCheckins(c.records, *day(5), c.streak)
+		if len(got) != len(c.want) {
+			t.Errorf("%s: linked %d records, want %d", c.name, len(got), len(c.want))
+			continue
+		}
+		for i, r := range got {
+			if r.StreakDay != c.want[i] {
+				t.Errorf("%s: record %d streak %d, want %d", c.name, i, r.StreakDay, c.want[i])
+			}
+		}
+		// 未接上的记录保持原连续天数
+		for _, r := range c.records[len(got):] {
+			if r.StreakDay != 1 {
+				t.Errorf("%s: unlinked record on %s changed to %d", c.name, r.CheckinDate.Format(time.DateOnly), r.StreakDay)
+			}
+		}
+	}
+}
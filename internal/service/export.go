@@ -0,0 +1,481 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/audit"
+	"github.com/reusedev/uportal-api/pkg/config"
+	"github.com/reusedev/uportal-api/pkg/constants"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/export"
+	"github.com/reusedev/uportal-api/pkg/logs"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 导出数据类型
+const (
+	ExportTypeUsers        = "users"
+	ExportTypeOrders       = "orders"
+	ExportTypeTokenRecords = "token_records"
+	ExportTypeLoginLogs    = "login_logs"
+)
+
+const (
+	// exportBatchSize 导出时每批读取的行数
+	exportBatchSize = 500
+	// exportPollInterval 异步导出协程检查新任务的间隔
+	exportPollInterval = 3 * time.Second
+	// exportCleanInterval 清理过期文件和超时任务的间隔
+	exportCleanInterval = 10 * time.Minute
+)
+
+// errExportTooManyRows 导出行数超过上限
+var errExportTooManyRows = stderrors.New("export row limit exceeded")
+
+// Exportable 可导出的筛选条件，列表接口的筛选条件实现该接口后即可按同样的条件导出
+type Exportable interface {
+	exportType() string
+	exportHeader() []string
+	exportQuery(db *gorm.DB) *gorm.DB
+	exportEach(query *gorm.DB, fn func(row []string) error) error
+}
+
+// newExportFilter 按导出类型创建空的筛选条件，用于从任务中反序列化
+func newExportFilter(typ string) (Exportable, error) {
+	switch typ {
+	case ExportTypeUsers:
+		return &UserFilter{}, nil
+	case ExportTypeOrders:
+		return &OrderFilter{}, nil
+	case ExportTypeTokenRecords:
+		return &TokenRecordFilter{}, nil
+	case ExportTypeLoginLogs:
+		return &LoginLogFilter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown export type: %s", typ)
+	}
+}
+
+// ExportService 管理端数据导出服务，同步导出直接写入响应，数据量大时通过异步任务生成文件
+type ExportService struct {
+	db     *gorm.DB
+	config *config.Config
+}
+
+// NewExportService 创建导出服务
+func NewExportService(db *gorm.DB, cfg *config.Config) *ExportService {
+	return &ExportService{db: db, config: cfg}
+}
+
+// ExportFileName 生成下载文件名
+func ExportFileName(filter Exportable, format string) string {
+	return fmt.Sprintf("%s-%s.%s", filter.exportType(), time.Now().Format("20060102150405"), format)
+}
+
+// ValidateSyncExport 同步导出前检查数据量，超出上限时需改用异步导出；校验失败时还未写出任何内容
+func (s *ExportService) ValidateSyncExport(ctx context.Context, filter Exportable) error {
+	var count int64
+	if err := filter.exportQuery(s.db.WithContext(ctx)).Count(&count).Error; err != nil {
+		return errors.New(errors.ErrCodeDatabaseError, "统计导出数据失败", err)
+	}
+	if count > s.config.Export.SyncMaxRows {
+		return errors.New(errors.ErrCodeInvalidParams,
+			fmt.Sprintf("导出数据共 %d 条，超过 %d 条请使用异步导出", count, s.config.Export.SyncMaxRows), nil)
+	}
+	return nil
+}
+
+// Export 按条件流式导出数据，逐批读取并写出，不在内存中保留全部数据
+func (s *ExportService) Export(ctx context.Context, filter Exportable, format string, w io.Writer) error {
+	_, err := s.write(ctx, filter, format, w, s.config.Export.MaxRows)
+	return err
+}
+
+// CreateExportJob 创建异步导出任务，由后台协程生成文件，完成后本人可下载
+func (s *ExportService) CreateExportJob(ctx context.Context, adminID int64, filter Exportable, format string) (*model.AdminExportJob, error) {
+	raw, err := json.Marshal(filter)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "无效的筛选条件", err)
+	}
+	job := &model.AdminExportJob{
+		AdminID:  adminID,
+		Type:     filter.exportType(),
+		Format:   format,
+		Filter:   string(raw),
+		Status:   model.ExportStatusPending,
+		FileName: ExportFileName(filter, format),
+	}
+	if err := model.CreateExportJob(s.db.WithContext(ctx), job); err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "创建导出任务失败", err)
+	}
+	audit.Record(ctx, "export_job", strconv.FormatInt(job.ID, 10), nil, job)
+	return job, nil
+}
+
+// ListExportJobs 分页获取当前管理员的导出任务
+func (s *ExportService) ListExportJobs(ctx context.Context, adminID int64, page, limit int) ([]*model.AdminExportJob, int64, error) {
+	list, total, err := model.ListExportJobs(s.db.WithContext(ctx), adminID, page, limit)
+	if err != nil {
+		return nil, 0, errors.New(errors.ErrCodeDatabaseError, "获取导出任务失败", err)
+	}
+	return list, total, nil
+}
+
+// GetExportFile 获取可下载的导出任务，只有发起人本人可下载
+func (s *ExportService) GetExportFile(ctx context.Context, adminID, id int64) (*model.AdminExportJob, error) {
+	job, err := model.GetExportJob(s.db.WithContext(ctx), id)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New(errors.ErrCodeNotFound, "导出任务不存在", nil)
+		}
+		return nil, errors.New(errors.ErrCodeDatabaseError, "查询导出任务失败", err)
+	}
+	if job.AdminID != adminID {
+		return nil, errors.New(errors.ErrCodeNotFound, "导出任务不存在", nil)
+	}
+	switch job.Status {
+	case model.ExportStatusSuccess:
+	case model.ExportStatusExpired:
+		return nil, errors.New(errors.ErrCodeInvalidParams, "导出文件已过期，请重新导出", nil)
+	case model.ExportStatusFailed:
+		return nil, errors.New(errors.ErrCodeInvalidParams, "导出任务失败，请重新导出", nil)
+	default:
+		return nil, errors.New(errors.ErrCodeInvalidParams, "导出任务尚未完成", nil)
+	}
+	if _, err := os.Stat(job.FilePath); err != nil {
+		return nil, errors.New(errors.ErrCodeNotFound, "导出文件不存在，请重新导出", err)
+	}
+	audit.Record(ctx, "export_job", strconv.FormatInt(job.ID, 10), nil, nil)
+	return job, nil
+}
+
+// Start 启动异步导出协程和过期文件清理协程
+func (s *ExportService) Start(ctx context.Context) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	if err := os.MkdirAll(s.config.Export.Dir, 0o750); err != nil {
+		logs.Business().Error("创建导出目录失败", zap.String("dir", s.config.Export.Dir), zap.Error(err))
+		return &wg
+	}
+	for i := 0; i < s.config.Export.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.runWorker(ctx)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.runCleaner(ctx)
+	}()
+	return &wg
+}
+
+// runWorker 轮询并执行排队中的导出任务
+func (s *ExportService) runWorker(ctx context.Context) {
+	ticker := time.NewTicker(exportPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for ctx.Err() == nil {
+			job, err := model.ClaimExportJob(s.db)
+			if err != nil {
+				logs.Business().Warn("领取导出任务失败", zap.Error(err))
+				break
+			}
+			if job == nil {
+				break
+			}
+			s.runJob(ctx, job)
+		}
+	}
+}
+
+// runJob 执行一个导出任务，生成文件后记录路径和过期时间
+func (s *ExportService) runJob(ctx context.Context, job *model.AdminExportJob) {
+	fail := func(reason string, err error) {
+		logs.Business().Error("导出任务失败", zap.Int64("job_id", job.ID), zap.String("reason", reason), zap.Error(err))
+		if err := model.UpdateExportJob(s.db, job.ID, map[string]interface{}{
+			"status":      model.ExportStatusFailed,
+			"error":       truncateRunes(reason, 255),
+			"finished_at": time.Now(),
+		}); err != nil {
+			logs.Business().Error("更新导出任务状态失败", zap.Int64("job_id", job.ID), zap.Error(err))
+		}
+	}
+
+	filter, err := newExportFilter(job.Type)
+	if err != nil {
+		fail("不支持的导出类型", err)
+		return
+	}
+	if err := json.Unmarshal([]byte(job.Filter), filter); err != nil {
+		fail("筛选条件格式错误", err)
+		return
+	}
+
+	path := filepath.Join(s.config.Export.Dir, fmt.Sprintf("%d-%s", job.ID, job.FileName))
+	file, err := os.Create(path)
+	if err != nil {
+		fail("创建导出文件失败", err)
+		return
+	}
+	rows, err := s.write(ctx, filter, job.Format, file, s.config.Export.MaxRows)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		if stderrors.Is(err, errExportTooManyRows) || stderrors.Is(err, export.ErrTooManyRows) {
+			fail(fmt.Sprintf("导出数据超过 %d 条，请缩小筛选范围", s.config.Export.MaxRows), err)
+			return
+		}
+		fail("导出数据失败", err)
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		fail("读取导出文件失败", err)
+		return
+	}
+	now := time.Now()
+	if err := model.UpdateExportJob(s.db, job.ID, map[string]interface{}{
+		"status":      model.ExportStatusSuccess,
+		"row_count":   rows,
+		"file_path":   path,
+		"file_size":   info.Size(),
+		"finished_at": now,
+		"expires_at":  now.Add(s.config.Export.FileTTL),
+	}); err != nil {
+		logs.Business().Error("更新导出任务状态失败", zap.Int64("job_id", job.ID), zap.Error(err))
+	}
+}
+
+// runCleaner 定期删除过期文件，并将超时未完成的任务标记为失败
+func (s *ExportService) runCleaner(ctx context.Context) {
+	ticker := time.NewTicker(exportCleanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if n, err := model.FailStaleExportJobs(s.db, time.Now().Add(-s.config.Export.JobTimeout), "导出超时"); err != nil {
+			logs.Business().Warn("清理超时导出任务失败", zap.Error(err))
+		} else if n > 0 {
+			logs.Business().Warn("导出任务超时", zap.Int64("count", n))
+		}
+
+		jobs, err := model.ListExpiredExportJobs(s.db, time.Now(), 100)
+		if err != nil {
+			logs.Business().Warn("查询过期导出任务失败", zap.Error(err))
+			continue
+		}
+		for _, job := range jobs {
+			if err := os.Remove(job.FilePath); err != nil && !os.IsNotExist(err) {
+				logs.Business().Warn("删除导出文件失败", zap.Int64("job_id", job.ID), zap.Error(err))
+				continue
+			}
+			if err := model.UpdateExportJob(s.db, job.ID, map[string]interface{}{
+				"status":    model.ExportStatusExpired,
+				"file_path": "",
+			}); err != nil {
+				logs.Business().Warn("更新导出任务状态失败", zap.Int64("job_id", job.ID), zap.Error(err))
+			}
+		}
+	}
+}
+
+// write 写出表头和数据行，超过 maxRows 时返回 errExportTooManyRows
+func (s *ExportService) write(ctx context.Context, filter Exportable, format string, w io.Writer, maxRows int64) (int64, error) {
+	writer, err := export.NewWriter(format, w)
+	if err != nil {
+		return 0, errors.New(errors.ErrCodeInvalidParams, "不支持的导出格式", err)
+	}
+	if err := writer.Write(filter.exportHeader()); err != nil {
+		return 0, err
+	}
+	var rows int64
+	err = filter.exportEach(filter.exportQuery(s.db.WithContext(ctx)), func(row []string) error {
+		if rows >= maxRows {
+			return errExportTooManyRows
+		}
+		rows++
+		return writer.Write(row)
+	})
+	if err != nil {
+		return rows, err
+	}
+	return rows, writer.Close()
+}
+
+func (f *UserFilter) exportType() string { return ExportTypeUsers }
+
+func (f *UserFilter) exportHeader() []string {
+	return []string{"用户ID", "昵称", "手机号", "邮箱", "代币余额", "状态", "邀请人ID", "注册时间", "最后登录时间"}
+}
+
+func (f *UserFilter) exportQuery(db *gorm.DB) *gorm.DB {
+	return f.apply(db.Model(&model.User{}))
+}
+
+func (f *UserFilter) exportEach(query *gorm.DB, fn func(row []string) error) error {
+	var batch []*model.User
+	return query.FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+		for _, u := range batch {
+			status := "正常"
+			if u.Status == 0 {
+				status = "禁用"
+			}
+			if err := fn([]string{
+				u.UserID,
+				derefString(u.Nickname),
+				derefString(u.Phone),
+				derefString(u.Email),
+				strconv.Itoa(u.TokenBalance),
+				status,
+				derefString(u.InviterID),
+				formatExportTime(&u.CreatedAt),
+				formatExportTime(u.LastLoginAt),
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+func (f *OrderFilter) exportType() string { return ExportTypeOrders }
+
+func (f *OrderFilter) exportHeader() []string {
+	return []string{"订单ID", "订单号", "用户ID", "商品ID", "商品名称", "金额", "状态", "创建时间", "支付时间"}
+}
+
+func (f *OrderFilter) exportQuery(db *gorm.DB) *gorm.DB {
+	return f.apply(db.Model(&model.Order{}))
+}
+
+func (f *OrderFilter) exportEach(query *gorm.DB, fn func(row []string) error) error {
+	var batch []*model.Order
+	return query.FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+		for _, o := range batch {
+			if err := fn([]string{
+				strconv.FormatInt(o.OrderID, 10),
+				o.OrderNo,
+				o.UserID,
+				o.ProductID,
+				o.ProductName,
+				strconv.FormatFloat(o.Amount, 'f', 2, 64),
+				string(o.Status),
+				formatExportTime(&o.CreatedAt),
+				formatExportTime(o.PaidAt),
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+func (f *TokenRecordFilter) exportType() string { return ExportTypeTokenRecords }
+
+func (f *TokenRecordFilter) exportHeader() []string {
+	return []string{"记录ID", "用户ID", "变动数", "变动后余额", "变动类型", "任务ID", "功能ID", "订单ID", "管理员ID", "备注", "变动时间"}
+}
+
+func (f *TokenRecordFilter) exportQuery(db *gorm.DB) *gorm.DB {
+	return f.apply(db.Model(&model.TokenRecord{}))
+}
+
+func (f *TokenRecordFilter) exportEach(query *gorm.DB, fn func(row []string) error) error {
+	var batch []*model.TokenRecord
+	return query.FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+		for _, r := range batch {
+			if err := fn([]string{
+				strconv.FormatInt(r.RecordID, 10),
+				r.UserID,
+				strconv.Itoa(r.ChangeAmount),
+				strconv.Itoa(r.BalanceAfter),
+				r.ChangeType,
+				formatExportInt(r.TaskID),
+				formatExportInt(r.FeatureID),
+				formatExportInt64(r.OrderID),
+				formatExportInt64(r.AdminID),
+				derefString(r.Remark),
+				formatExportTime(&r.ChangeTime),
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+func (f *LoginLogFilter) exportType() string { return ExportTypeLoginLogs }
+
+func (f *LoginLogFilter) exportHeader() []string {
+	return []string{"日志ID", "用户ID", "登录时间", "登录方式", "登录平台", "IP地址", "设备信息"}
+}
+
+func (f *LoginLogFilter) exportQuery(db *gorm.DB) *gorm.DB {
+	return f.apply(db.Model(&model.UserLoginLog{}))
+}
+
+func (f *LoginLogFilter) exportEach(query *gorm.DB, fn func(row []string) error) error {
+	var batch []*model.UserLoginLog
+	return query.FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+		for _, l := range batch {
+			if err := fn([]string{
+				strconv.FormatInt(l.LogID, 10),
+				l.UserID,
+				formatExportTime(&l.LoginTime),
+				l.LoginMethod,
+				derefString(l.LoginPlatform),
+				derefString(l.IPAddress),
+				derefString(l.DeviceInfo),
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+func formatExportTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.Format(constants.TimeFormatDateTime)
+}
+
+func formatExportInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+func formatExportInt64(v *int64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(*v, 10)
+}
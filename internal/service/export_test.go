@@ -0,0 +1,118 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reusedev/uportal-api/pkg/export"
+)
+
+func TestCSVExportEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := export.NewWriter(export.FormatCSV, &buf)
+	if err != nil {
+		t.Fatalf("new writer: %v", err)
+	}
+	rows := [][]string{
+		{"nickname", "amount"},
+		{"=HYPERLINK(\"http://x\")", "-12.5"},
+		{"+cmd", "@SUM(A1)"},
+		{"-", "normal"},
+	}
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.HasPrefix(out, "\xEF\xBB\xBF") {
+		t.Errorf("missing UTF-8 BOM")
+	}
+	want := "nickname,amount\n" +
+		"\"'=HYPERLINK(\"\"http://x\"\")\",-12.5\n" +
+		"'+cmd,'@SUM(A1)\n" +
+		"'-,normal\n"
+	if got := strings.TrimPrefix(out, "\xEF\xBB\xBF"); got != want {
+		t.Errorf("csv = %q, want %q", got, want)
+	}
+}
+
+func TestXLSXExport(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := export.NewWriter(export.FormatXLSX, &buf)
+	if err != nil {
+		t.Fatalf("new writer: %v", err)
+	}
+	if err := w.Write([]string{"昵称", "备注"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := w.Write([]string{"a<b>&c", "bad\x00\x0bchars"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	var sheet string
+	names := map[string]bool{}
+	for _, f := range zr.File {
+		names[f.Name] = true
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			rc, _ := f.Open()
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			sheet = string(data)
+		}
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		if !names[name] {
+			t.Errorf("missing part %s", name)
+		}
+	}
+	if strings.Count(sheet, "<row>") != 2 {
+		t.Errorf("sheet has %d rows, want 2", strings.Count(sheet, "<row>"))
+	}
+	if !strings.Contains(sheet, "a&lt;b&gt;&amp;c") {
+		t.Errorf("cell text is not escaped: %s", sheet)
+	}
+	if !strings.Contains(sheet, ">badchars<") {
+		t.Errorf("control characters are not stripped: %s", sheet)
+	}
+}
+
+func TestExportHelpers(t *testing.T) {
+	if _, err := export.NewWriter("pdf", io.Discard); err == nil {
+		t.Errorf("unsupported format accepted")
+	}
+	for _, typ := range []string{ExportTypeUsers, ExportTypeOrders, ExportTypeTokenRecords, ExportTypeLoginLogs} {
+		filter, err := newExportFilter(typ)
+		if err != nil || filter.exportType() != typ {
+			t.Errorf("newExportFilter(%q) = %v, %v", typ, filter, err)
+		}
+	}
+	if _, err := newExportFilter("admins"); err == nil {
+		t.Errorf("unknown export type accepted")
+	}
+
+	n, n64 := 3, int64(-4)
+	zero := time.Time{}
+	at := time.Date(2026, 10, 18, 8, 30, 0, 0, time.Local)
+	if formatExportInt(nil) != "" || formatExportInt(&n) != "3" || formatExportInt64(&n64) != "-4" {
+		t.Errorf("int formatting")
+	}
+	if formatExportTime(nil) != "" || formatExportTime(&zero) != "" || formatExportTime(&at) != "2026-10-18 08:30:00" {
+		t.Errorf("time formatting = %q", formatExportTime(&at))
+	}
+}
@@ -2,88 +2,217 @@ package service
 
 import (
 	"context"
-	"crypto/md5"
-	"encoding/hex"
+	"crypto/rand"
 	stderrors "errors"
 	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/config"
 	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/logs"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
+// 邀请码由去掉易混淆字符（0/O、1/I/L）的大写字母和数字组成，不区分大小写
+const (
+	inviteCodeAlphabet    = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+	inviteCodeLength      = 8
+	inviteCodeMaxAttempts = 5 // 邀请码冲突时的最大重试次数
+)
+
 // InviteService 邀请服务
 type InviteService struct {
-	db *gorm.DB
+	db           *gorm.DB
+	redis        *redis.Client
+	cfg          *config.Config
+	wechat       *WechatService
+	risk         *RiskService
+	achievements *AchievementService
 }
 
-// NewInviteService 创建邀请服务
-func NewInviteService(db *gorm.DB) *InviteService {
+// NewInviteService 创建邀请服务，achievements 为 nil 时不统计成就进度
+func NewInviteService(db *gorm.DB, redis *redis.Client, cfg *config.Config, wechat *WechatService, risk *RiskService, achievements *AchievementService) *InviteService {
 	return &InviteService{
-		db: db,
+		db:           db,
+		redis:        redis,
+		cfg:          cfg,
+		wechat:       wechat,
+		risk:         risk,
+		achievements: achievements,
 	}
 }
 
-// GenerateInviteCode 生成邀请码
-func (s *InviteService) GenerateInviteCode(userID int64) string {
-	// 使用用户ID和时间戳生成邀请码
-	data := fmt.Sprintf("%d-%d", userID, time.Now().UnixNano())
-	hash := md5.Sum([]byte(data))
-	return hex.EncodeToString(hash[:8]) // 取前8位作为邀请码
+// generateInviteCode 随机生成邀请码
+func generateInviteCode() (string, error) {
+	buf := make([]byte, inviteCodeLength)
+	size := big.NewInt(int64(len(inviteCodeAlphabet)))
+	for i := range buf {
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", err
+		}
+		buf[i] = inviteCodeAlphabet[n.Int64()]
+	}
+	return string(buf), nil
 }
 
-// GetInviteLink 获取邀请链接
-func (s *InviteService) GetInviteLink(ctx context.Context, userID int64) (string, error) {
-	// 检查用户是否存在
-	var user model.User
-	if err := s.db.First(&user, userID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return "", errors.New(errors.ErrCodeUserNotFound, "用户不存在", nil)
+// GetInviteCode 获取用户的邀请码，没有时生成并保存，之后保持不变
+func (s *InviteService) GetInviteCode(ctx context.Context, userID string) (*model.InviteCode, error) {
+	db := s.db.WithContext(ctx)
+	ic, err := model.GetInviteCodeByUser(db, userID)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "获取邀请码失败", err)
+	}
+	if ic != nil {
+		return ic, nil
+	}
+
+	if err := db.Where("id = ?", userID).First(&model.User{}).Error; err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New(errors.ErrCodeUserNotFound, "用户不存在", nil)
 		}
-		return "", errors.New(errors.ErrCodeInternal, "获取用户信息失败", err)
+		return nil, errors.New(errors.ErrCodeInternal, "获取用户信息失败", err)
 	}
 
-	// 生成邀请码
-	inviteCode := s.GenerateInviteCode(userID)
+	// 并发生成或邀请码冲突时不写入，重新读取后以数据库中的为准
+	for i := 0; i < inviteCodeMaxAttempts; i++ {
+		code, err := generateInviteCode()
+		if err != nil {
+			return nil, errors.New(errors.ErrCodeInternal, "生成邀请码失败", err)
+		}
+		if err := model.CreateInviteCode(db, userID, code); err != nil {
+			return nil, errors.New(errors.ErrCodeDatabaseError, "保存邀请码失败", err)
+		}
+		if ic, err = model.GetInviteCodeByUser(db, userID); err != nil {
+			return nil, errors.New(errors.ErrCodeDatabaseError, "获取邀请码失败", err)
+		}
+		if ic != nil {
+			return ic, nil
+		}
+	}
+	return nil, errors.New(errors.ErrCodeInternal, "生成邀请码失败，请重试", nil)
+}
 
-	// TODO: 从配置中获取域名
-	domain := "https://your-domain.com"
-	return fmt.Sprintf("%s/register?invite_code=%s", domain, inviteCode), nil
+// InviteShare 邀请分享信息
+type InviteShare struct {
+	Code string `json:"code"`           // 邀请码
+	Link string `json:"link,omitempty"` // 邀请落地页链接，未配置落地页地址时为空
 }
 
-// ValidateInviteCode 验证邀请码
-func (s *InviteService) ValidateInviteCode(ctx context.Context, inviteCode string) (int64, error) {
-	// 从邀请码中提取用户ID（这里需要根据实际生成邀请码的逻辑来实现）
-	// 示例实现：假设邀请码的前8位是用户ID的哈希
-	userID, err := s.extractUserIDFromInviteCode(inviteCode)
+// GetInviteShare 获取用户的邀请码和分享链接
+func (s *InviteService) GetInviteShare(ctx context.Context, userID string) (*InviteShare, error) {
+	ic, err := s.GetInviteCode(ctx, userID)
 	if err != nil {
-		return 0, errors.New(errors.ErrCodeInvalidParams, "无效的邀请码", err)
+		return nil, err
 	}
+	return &InviteShare{Code: ic.Code, Link: s.inviteLink(ic.Code)}, nil
+}
 
-	// 检查邀请人是否存在且状态正常
-	var user model.User
-	if err := s.db.First(&user, userID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return 0, errors.New(errors.ErrCodeUserNotFound, "邀请人不存在", nil)
+// inviteLink 拼接邀请落地页链接，邀请码以 invite_code 参数附加
+func (s *InviteService) inviteLink(code string) string {
+	base := strings.TrimRight(s.cfg.Invite.LinkBaseURL, "/")
+	if base == "" {
+		return ""
+	}
+	return base + s.cfg.Invite.LinkPath + "?invite_code=" + url.QueryEscape(code)
+}
+
+// GetInviteQRCode 获取打开邀请页面的小程序码图片，邀请码作为 scene 传入，图片按配置缓存
+func (s *InviteService) GetInviteQRCode(ctx context.Context, userID string) ([]byte, string, error) {
+	ic, err := s.GetInviteCode(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	inv := s.cfg.Invite
+	cacheKey := fmt.Sprintf("invite_wxacode:%s:%s:%d:%s", ic.Code, inv.MiniProgramEnv, inv.QRCodeWidth, inv.MiniProgramPage)
+	if s.redis != nil {
+		if data, err := s.redis.Get(ctx, cacheKey).Bytes(); err == nil && len(data) > 0 {
+			return data, http.DetectContentType(data), nil
 		}
-		return 0, errors.New(errors.ErrCodeInternal, "验证邀请码失败", err)
 	}
 
-	if user.Status != 1 {
-		return 0, errors.New(errors.ErrCodeUserDisabled, "邀请人账号已被禁用", nil)
+	data, contentType, err := s.wechat.GetUnlimitedQRCode(ctx, &WxaCodeRequest{
+		Scene:      ic.Code,
+		Page:       inv.MiniProgramPage,
+		CheckPath:  inv.MiniProgramEnv == "release",
+		EnvVersion: inv.MiniProgramEnv,
+		Width:      inv.QRCodeWidth,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if s.redis != nil {
+		if err := s.redis.Set(ctx, cacheKey, data, inv.QRCodeCacheTTL).Err(); err != nil {
+			logs.Business().Warn("缓存邀请小程序码失败", zap.Error(err))
+		}
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
 	}
+	return data, contentType, nil
+}
+
+// InviterInfo 邀请码对应的邀请人公开信息，用于落地页展示
+type InviterInfo struct {
+	Code      string  `json:"code"`
+	Nickname  *string `json:"nickname"`
+	AvatarURL *string `json:"avatar"`
+}
 
-	return userID, nil
+// LookupInviteCode 查询邀请码对应的邀请人公开信息
+func (s *InviteService) LookupInviteCode(ctx context.Context, code string) (*InviterInfo, error) {
+	inviter, ic, err := s.resolveInviteCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	return &InviterInfo{Code: ic.Code, Nickname: inviter.Nickname, AvatarURL: inviter.AvatarURL}, nil
 }
 
-// extractUserIDFromInviteCode 从邀请码中提取用户ID
-func (s *InviteService) extractUserIDFromInviteCode(inviteCode string) (int64, error) {
-	// TODO: 实现从邀请码中提取用户ID的逻辑
-	// 这里需要根据实际生成邀请码的逻辑来实现
-	// 示例实现：假设邀请码的前8位是用户ID的哈希
-	return 0, fmt.Errorf("not implemented")
+// ValidateInviteCode 验证邀请码，返回邀请人ID
+func (s *InviteService) ValidateInviteCode(ctx context.Context, code string) (string, error) {
+	inviter, _, err := s.resolveInviteCode(ctx, code)
+	if err != nil {
+		return "", err
+	}
+	return inviter.UserID, nil
+}
+
+// resolveInviteCode 按邀请码查找邀请人，邀请人不存在或已禁用时返回错误
+func (s *InviteService) resolveInviteCode(ctx context.Context, code string) (*model.User, *model.InviteCode, error) {
+	code = model.NormalizeInviteCode(code)
+	if code == "" || len(code) > 16 {
+		return nil, nil, errors.New(errors.ErrCodeInvalidParams, "无效的邀请码", nil)
+	}
+	db := s.db.WithContext(ctx)
+	ic, err := model.GetInviteCodeByCode(db, code)
+	if err != nil {
+		return nil, nil, errors.New(errors.ErrCodeDatabaseError, "验证邀请码失败", err)
+	}
+	if ic == nil {
+		return nil, nil, errors.New(errors.ErrCodeNotFound, "邀请码不存在", nil)
+	}
+
+	// 检查邀请人是否存在且状态正常
+	var user model.User
+	if err := db.Where("id = ?", ic.UserID).First(&user).Error; err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New(errors.ErrCodeUserNotFound, "邀请人不存在", nil)
+		}
+		return nil, nil, errors.New(errors.ErrCodeInternal, "验证邀请码失败", err)
+	}
+	if user.Status != 1 {
+		return nil, nil, errors.New(errors.ErrCodeUserDisabled, "邀请人账号已被禁用", nil)
+	}
+	return &user, ic, nil
 }
 
 // GetDB 获取数据库连接
@@ -147,6 +276,30 @@ func (s *InviteService) CreateInviteRecordWithTx(ctx context.Context, tx *gorm.D
 	return nil
 }
 
+// TrackInvite 邀请关系建立后推进邀请人的成就进度，需在事务提交后调用
+func (s *InviteService) TrackInvite(ctx context.Context, inviterID, inviteeID string) {
+	s.achievements.Track(ctx, &AchievementEvent{
+		UserID: inviterID,
+		Type:   model.AchievementEventInvite,
+		Key:    inviteeID,
+		Amount: 1,
+	})
+}
+
+// AssessInviteWithTx 在事务中对邀请奖励做风控评估，奖励发给邀请人
+func (s *InviteService) AssessInviteWithTx(ctx context.Context, tx *gorm.DB, inviterID, inviteeID string, tokenReward int, client RiskClient) (*RiskResult, error) {
+	return s.risk.Assess(ctx, tx, &RiskInput{
+		EventType:     model.RiskEventInvite,
+		UserID:        inviteeID,
+		RelatedUserID: inviterID,
+		BeneficiaryID: inviterID,
+		Amount:        tokenReward,
+		ChangeType:    "INVITE_REWARD",
+		Remark:        "邀请奖励",
+		Client:        client,
+	})
+}
+
 // ProcessInviteRewardWithTx - 在事务中处理邀请奖励
 func (s *InviteService) ProcessInviteRewardWithTx(ctx context.Context, tx *gorm.DB, inviteeID string) error {
 	// 查找待处理的邀请记录
@@ -192,21 +345,3 @@ func (s *InviteService) ProcessInviteRewardWithTx(ctx context.Context, tx *gorm.
 
 	return nil
 }
-
-// 定义奖励类型常量
-const (
-	RewardTypeDailyLogin = "DAILY_LOGIN" // 每日登录奖励
-	RewardTypeProfile    = "PROFILE"     // 完善资料奖励
-	RewardTypeShare      = "SHARE"       // 分享奖励
-	RewardTypeFeedback   = "FEEDBACK"    // 反馈奖励
-	RewardAd             = "rewardad"    // 激励视频奖励
-)
-
-// 定义奖励类型对应的代币数
-var rewardAmounts = map[string]int{
-	RewardTypeDailyLogin: 10,  // 每日登录奖励10代币
-	RewardTypeProfile:    50,  // 完善资料奖励50代币
-	RewardTypeShare:      20,  // 分享奖励20代币
-	RewardTypeFeedback:   30,  // 反馈奖励30代币
-	RewardAd:             300, // 激励视频奖励100代币
-}
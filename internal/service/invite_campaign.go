@@ -0,0 +1,460 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/audit"
+	"github.com/reusedev/uportal-api/pkg/config"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/logs"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultInviteReward 没有生效的邀请活动时，建立邀请关系即发给邀请人的代币数
+const DefaultInviteReward = 1000
+
+// InviteCampaignRequest 创建或更新邀请活动请求
+type InviteCampaignRequest struct {
+	Code          string     `json:"code" binding:"required,max=50"`                                         // 活动编码，创建后不可修改
+	Name          string     `json:"name" binding:"required,max=100"`                                        // 名称
+	Description   string     `json:"description" binding:"max=255"`                                          // 说明
+	InviterReward int        `json:"inviter_reward" binding:"min=0"`                                         // 邀请人奖励的代币数
+	InviteeReward int        `json:"invitee_reward" binding:"min=0"`                                         // 被邀请人奖励的代币数
+	Level2Reward  int        `json:"level2_reward" binding:"min=0"`                                          // 二级奖励，发给邀请人的邀请人
+	Trigger       string     `json:"trigger" binding:"required,oneof=signup login_day first_purchase spend"` // 触发条件
+	TriggerValue  int        `json:"trigger_value" binding:"min=0"`                                          // login_day 为天数，spend 为代币数
+	InviterCap    int        `json:"inviter_cap" binding:"min=0"`                                            // 每个邀请人最多获得奖励的人数，0 表示不限
+	StartAt       *time.Time `json:"start_at"`                                                               // 开始时间，为空不限
+	EndAt         *time.Time `json:"end_at"`                                                                 // 结束时间，为空不限
+	Sort          int        `json:"sort"`                                                                   // 排序，越小越优先
+	Status        *int8      `json:"status" binding:"required,oneof=0 1"`                                    // 状态：1=启用，0=停用
+}
+
+// validateInviteCampaign 校验邀请活动的奖励、触发条件和有效期
+func validateInviteCampaign(req *InviteCampaignRequest) error {
+	if req.InviterReward+req.InviteeReward+req.Level2Reward <= 0 {
+		return errors.New(errors.ErrCodeInvalidParams, "至少需要设置一项奖励", nil)
+	}
+	switch req.Trigger {
+	case model.InviteTriggerLoginDay, model.InviteTriggerSpend:
+		if req.TriggerValue < 1 {
+			return errors.New(errors.ErrCodeInvalidParams, "login_day 和 spend 条件的 trigger_value 须大于 0", nil)
+		}
+	default:
+		if req.TriggerValue != 0 {
+			return errors.New(errors.ErrCodeInvalidParams, "signup 和 first_purchase 条件不需要 trigger_value", nil)
+		}
+	}
+	if req.StartAt != nil && req.EndAt != nil && !req.EndAt.After(*req.StartAt) {
+		return errors.New(errors.ErrCodeInvalidParams, "结束时间须晚于开始时间", nil)
+	}
+	return nil
+}
+
+// ListInviteCampaigns 获取邀请活动列表
+func (s *InviteService) ListInviteCampaigns(ctx context.Context) ([]*model.InviteCampaign, error) {
+	list, err := model.ListInviteCampaigns(s.db.WithContext(ctx))
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "获取邀请活动列表失败", err)
+	}
+	return list, nil
+}
+
+// GetInviteCampaign 获取邀请活动
+func (s *InviteService) GetInviteCampaign(ctx context.Context, id int64) (*model.InviteCampaign, error) {
+	c, err := model.GetInviteCampaign(s.db.WithContext(ctx), id)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New(errors.ErrCodeNotFound, "邀请活动不存在", nil)
+		}
+		return nil, errors.New(errors.ErrCodeDatabaseError, "查询邀请活动失败", err)
+	}
+	return c, nil
+}
+
+// CreateInviteCampaign 创建邀请活动
+func (s *InviteService) CreateInviteCampaign(ctx context.Context, req *InviteCampaignRequest) (*model.InviteCampaign, error) {
+	if err := validateInviteCampaign(req); err != nil {
+		return nil, err
+	}
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&model.InviteCampaign{}).Where("code = ?", req.Code).Count(&count).Error; err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "检查活动编码失败", err)
+	}
+	if count > 0 {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "活动编码已存在", nil)
+	}
+
+	c := &model.InviteCampaign{Code: req.Code}
+	applyInviteCampaignRequest(c, req)
+	if err := model.CreateInviteCampaign(s.db.WithContext(ctx), c); err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "创建邀请活动失败", err)
+	}
+	audit.Record(ctx, "invite_campaign", strconv.FormatInt(c.ID, 10), nil, c)
+	return c, nil
+}
+
+// UpdateInviteCampaign 更新邀请活动。奖励在建立邀请关系时记入邀请记录，修改奖励只影响之后的邀请；
+// 触发条件在结算时读取，修改后对尚未结算的邀请同样生效
+func (s *InviteService) UpdateInviteCampaign(ctx context.Context, id int64, req *InviteCampaignRequest) (*model.InviteCampaign, error) {
+	before, err := s.GetInviteCampaign(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Code != before.Code {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "活动编码不可修改", nil)
+	}
+	if err := validateInviteCampaign(req); err != nil {
+		return nil, err
+	}
+
+	c := *before
+	applyInviteCampaignRequest(&c, req)
+	updates := map[string]interface{}{
+		"name":           c.Name,
+		"description":    c.Description,
+		"inviter_reward": c.InviterReward,
+		"invitee_reward": c.InviteeReward,
+		"level2_reward":  c.Level2Reward,
+		"trigger_type":   c.Trigger,
+		"trigger_value":  c.TriggerValue,
+		"inviter_cap":    c.InviterCap,
+		"start_at":       c.StartAt,
+		"end_at":         c.EndAt,
+		"sort":           c.Sort,
+		"status":         c.Status,
+	}
+	if err := model.UpdateInviteCampaign(s.db.WithContext(ctx), id, updates); err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "更新邀请活动失败", err)
+	}
+	after, _ := model.GetInviteCampaign(s.db.WithContext(ctx), id)
+	audit.Record(ctx, "invite_campaign", strconv.FormatInt(id, 10), before, after)
+	return after, nil
+}
+
+// DeleteInviteCampaign 删除邀请活动，已有邀请记录的活动只能停用
+func (s *InviteService) DeleteInviteCampaign(ctx context.Context, id int64) error {
+	before, err := s.GetInviteCampaign(ctx, id)
+	if err != nil {
+		return err
+	}
+	count, err := model.CountInviteRecordsByCampaign(s.db.WithContext(ctx), id)
+	if err != nil {
+		return errors.New(errors.ErrCodeDatabaseError, "检查邀请记录失败", err)
+	}
+	if count > 0 {
+		return errors.New(errors.ErrCodeInvalidParams, "该活动已有邀请记录，请停用而不是删除", nil)
+	}
+	if err := model.DeleteInviteCampaign(s.db.WithContext(ctx), id); err != nil {
+		return errors.New(errors.ErrCodeDatabaseError, "删除邀请活动失败", err)
+	}
+	audit.Record(ctx, "invite_campaign", strconv.FormatInt(id, 10), before, nil)
+	return nil
+}
+
+// applyInviteCampaignRequest 将请求字段写入邀请活动
+func applyInviteCampaignRequest(c *model.InviteCampaign, req *InviteCampaignRequest) {
+	c.Name = req.Name
+	c.Description = optionalString(req.Description)
+	c.InviterReward = req.InviterReward
+	c.InviteeReward = req.InviteeReward
+	c.Level2Reward = req.Level2Reward
+	c.Trigger = req.Trigger
+	c.TriggerValue = req.TriggerValue
+	c.InviterCap = req.InviterCap
+	c.StartAt = req.StartAt
+	c.EndAt = req.EndAt
+	c.Sort = req.Sort
+	c.Status = *req.Status
+}
+
+// ActiveInviteCampaign 当前生效的邀请活动，没有时返回 nil
+func (s *InviteService) ActiveInviteCampaign(ctx context.Context) (*model.InviteCampaign, error) {
+	c, err := model.GetActiveInviteCampaign(s.db.WithContext(ctx), time.Now())
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "查询邀请活动失败", err)
+	}
+	return c, nil
+}
+
+// InviteRewardTotal 邀请活动单次邀请可能发放的代币总数，用于风控评估
+func InviteRewardTotal(c *model.InviteCampaign) int {
+	return c.InviterReward + c.InviteeReward + c.Level2Reward
+}
+
+// scaleInviteReward 风控降额时按实际放行比例缩减各项奖励
+func scaleInviteReward(reward, granted, total int) int {
+	if total <= 0 || granted >= total {
+		return reward
+	}
+	return reward * granted / total
+}
+
+// CreateCampaignInviteWithTx 在事务中按邀请活动创建邀请记录，奖励按风控结果降额，
+// 被暂扣时记录进入风控审核中状态，审核通过前不结算
+func (s *InviteService) CreateCampaignInviteWithTx(ctx context.Context, tx *gorm.DB, inviter *model.User, inviteeID string, campaign *model.InviteCampaign, risk *RiskResult) error {
+	var count int64
+	if err := tx.Model(&model.InviteRecord{}).Where("invitee_id = ?", inviteeID).Count(&count).Error; err != nil {
+		return errors.New(errors.ErrCodeInternal, "检查邀请记录失败", err)
+	}
+	if count > 0 {
+		return errors.New(errors.ErrCodeInvalidParams, "该用户已被邀请", nil)
+	}
+
+	total := InviteRewardTotal(campaign)
+	granted := total
+	status := model.InviteStatusPending
+	if risk.Held() {
+		status = model.InviteStatusHeld
+	} else {
+		granted = risk.Amount
+	}
+	record := &model.InviteRecord{
+		InviterID:     inviter.UserID,
+		InviteeID:     inviteeID,
+		TokenReward:   scaleInviteReward(campaign.InviterReward, granted, total),
+		InviteeReward: scaleInviteReward(campaign.InviteeReward, granted, total),
+		Status:        status,
+		CampaignID:    &campaign.ID,
+	}
+	// 二级奖励发给邀请人的邀请人，不能是被邀请人本人
+	if campaign.Level2Reward > 0 && inviter.InviterID != nil && *inviter.InviterID != "" && *inviter.InviterID != inviteeID {
+		record.Level2InviterID = inviter.InviterID
+		record.Level2Reward = scaleInviteReward(campaign.Level2Reward, granted, total)
+	}
+	if err := tx.Create(record).Error; err != nil {
+		return errors.New(errors.ErrCodeInternal, "创建邀请记录失败", err)
+	}
+	return nil
+}
+
+// SettleInvite 被邀请人发生登录、消耗代币、支付订单等行为后检查所属邀请活动的触发条件，
+// 达成时向邀请双方和二级邀请人发放奖励。需在业务事务提交后调用，失败只记录日志，下次行为时重试
+func (s *InviteService) SettleInvite(ctx context.Context, inviteeID string) {
+	if s == nil || inviteeID == "" {
+		return
+	}
+	db := s.db.WithContext(ctx)
+	record, err := model.GetPendingCampaignInvite(db, inviteeID)
+	if err != nil {
+		logs.Business().Warn("查询待结算邀请记录失败", zap.String("invitee_id", inviteeID), zap.Error(err))
+		return
+	}
+	if record == nil {
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		_, err := settleCampaignInvite(tx, record.RecordID, inviteLocation(s.cfg), time.Now())
+		return err
+	})
+	if err != nil {
+		logs.Business().Warn("结算邀请奖励失败", zap.Int64("record_id", record.RecordID),
+			zap.String("invitee_id", inviteeID), zap.Error(err))
+	}
+}
+
+// inviteLocation 判断 login_day 条件使用的业务时区
+func inviteLocation(cfg *config.Config) *time.Location {
+	if cfg == nil {
+		return time.Local
+	}
+	return ResolveLocation(cfg.Locale.Timezone, time.Local)
+}
+
+// settleCampaignInvite 在事务中结算一条待发放的活动邀请记录，返回是否已结算。
+// 活动停用或尚未达成触发条件时保持待发放；邀请人达到活动上限时只发被邀请人和二级邀请人的奖励，
+// 账号已禁用的用户不发放
+func settleCampaignInvite(tx *gorm.DB, recordID int64, loc *time.Location, now time.Time) (bool, error) {
+	var record model.InviteRecord
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&record, recordID).Error; err != nil {
+		return false, errors.New(errors.ErrCodeDatabaseError, "查询邀请记录失败", err)
+	}
+	if record.Status != model.InviteStatusPending || record.CampaignID == nil {
+		return false, nil
+	}
+	campaign, err := model.GetInviteCampaign(tx, *record.CampaignID)
+	if err != nil {
+		return false, errors.New(errors.ErrCodeDatabaseError, "查询邀请活动失败", err)
+	}
+	// 有效期只限制建立邀请关系的时间，停用活动会暂停结算
+	if campaign.Status != 1 {
+		return false, nil
+	}
+	reached, err := inviteTriggerReached(tx, campaign, record.InviteeID, loc)
+	if err != nil {
+		return false, errors.New(errors.ErrCodeDatabaseError, "检查邀请触发条件失败", err)
+	}
+	if !reached {
+		return false, nil
+	}
+
+	// 按用户ID顺序加锁，避免并发结算互相等待
+	userIDs := []string{record.InviterID, record.InviteeID}
+	if record.Level2InviterID != nil {
+		userIDs = append(userIDs, *record.Level2InviterID)
+	}
+	userIDs = dedupeUserIDs(userIDs)
+	sort.Strings(userIDs)
+	var users []*model.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", userIDs).
+		Order("id").Find(&users).Error; err != nil {
+		return false, errors.New(errors.ErrCodeDatabaseError, "获取用户信息失败", err)
+	}
+	// 只给正常状态的用户发放，已禁用的用户不计入余额表，其奖励记为 0
+	balances := make(map[string]int, len(users))
+	for _, u := range users {
+		if u.Status == 1 {
+			balances[u.UserID] = u.TokenBalance
+		}
+	}
+
+	inviterReward := record.TokenReward
+	if campaign.InviterCap > 0 && inviterReward > 0 {
+		rewarded, err := model.CountRewardedInvites(tx, campaign.ID, record.InviterID)
+		if err != nil {
+			return false, errors.New(errors.ErrCodeDatabaseError, "统计邀请奖励次数失败", err)
+		}
+		if rewarded >= int64(campaign.InviterCap) {
+			inviterReward = 0
+		}
+	}
+
+	// credit 发放一笔奖励，返回实际发放的代币数
+	credit := func(userID string, amount int, remark string) (int, error) {
+		balance, ok := balances[userID]
+		if amount <= 0 || !ok {
+			return 0, nil
+		}
+		if err := model.UpdateUserTokenBalance(tx, userID, amount); err != nil {
+			return 0, errors.New(errors.ErrCodeInternal, "发放邀请奖励失败", err)
+		}
+		balances[userID] = balance + amount
+		remark = truncateRunes(remark+"："+campaign.Name, 255)
+		if err := model.CreateTokenRecord(tx, &model.TokenRecord{
+			UserID:       userID,
+			ChangeAmount: amount,
+			BalanceAfter: balance + amount,
+			ChangeType:   "INVITE_REWARD",
+			Remark:       &remark,
+			ChangeTime:   now,
+		}); err != nil {
+			return 0, errors.New(errors.ErrCodeInternal, "创建代币记录失败", err)
+		}
+		return amount, nil
+	}
+	inviterPaid, err := credit(record.InviterID, inviterReward, "邀请奖励")
+	if err != nil {
+		return false, err
+	}
+	inviteePaid, err := credit(record.InviteeID, record.InviteeReward, "受邀奖励")
+	if err != nil {
+		return false, err
+	}
+	level2Paid := 0
+	if record.Level2InviterID != nil {
+		if level2Paid, err = credit(*record.Level2InviterID, record.Level2Reward, "二级邀请奖励"); err != nil {
+			return false, err
+		}
+	}
+
+	// 记录实际发放的金额，被跳过的一方记为 0
+	if err := tx.Model(&model.InviteRecord{}).Where("record_id = ?", record.RecordID).Updates(map[string]interface{}{
+		"status":         model.InviteStatusPaid,
+		"token_reward":   inviterPaid,
+		"invitee_reward": inviteePaid,
+		"level2_reward":  level2Paid,
+		"settled_at":     now,
+	}).Error; err != nil {
+		return false, errors.New(errors.ErrCodeDatabaseError, "更新邀请记录状态失败", err)
+	}
+	return true, nil
+}
+
+// inviteTriggerReached 被邀请人当前是否已达成活动的触发条件
+func inviteTriggerReached(db *gorm.DB, c *model.InviteCampaign, inviteeID string, loc *time.Location) (bool, error) {
+	switch c.Trigger {
+	case model.InviteTriggerSignup:
+		return true, nil
+	case model.InviteTriggerLoginDay:
+		var invitee model.User
+		if err := db.Select("id", "created_at").Where("id = ?", inviteeID).First(&invitee).Error; err != nil {
+			return false, err
+		}
+		return model.HasLoginSince(db, inviteeID, loginDayStart(invitee.CreatedAt, c.TriggerValue, loc))
+	case model.InviteTriggerFirstPurchase:
+		return model.HasPaidOrder(db, inviteeID)
+	case model.InviteTriggerSpend:
+		spent, err := model.SumConsumedTokens(db, inviteeID)
+		return spent >= int64(c.TriggerValue), err
+	}
+	return false, nil
+}
+
+// loginDayStart 注册后第 day 天的零点，注册当天为第 1 天
+func loginDayStart(registeredAt time.Time, day int, loc *time.Location) time.Time {
+	start, _ := dayRange(registeredAt, loc)
+	return start.AddDate(0, 0, day-1)
+}
+
+// releaseHeldInvite 风控审核通过后将暂扣的活动邀请转为待发放，已达成触发条件的立即结算。
+// 没有处于审核中的活动邀请记录时返回 false，由调用方按原有方式补发邀请奖励
+func releaseHeldInvite(tx *gorm.DB, inviteeID, inviterID string, loc *time.Location) (bool, error) {
+	var record model.InviteRecord
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("invitee_id = ? AND inviter_id = ? AND status = ?", inviteeID, inviterID, model.InviteStatusHeld).
+		Limit(1).Find(&record).Error
+	if err != nil {
+		return false, errors.New(errors.ErrCodeDatabaseError, "查询邀请记录失败", err)
+	}
+	if record.RecordID == 0 {
+		return false, nil
+	}
+	if err := tx.Model(&model.InviteRecord{}).Where("record_id = ?", record.RecordID).
+		Update("status", model.InviteStatusPending).Error; err != nil {
+		return false, errors.New(errors.ErrCodeDatabaseError, "更新邀请记录状态失败", err)
+	}
+	if _, err := settleCampaignInvite(tx, record.RecordID, loc, time.Now()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// InviteDashboard 用户的邀请看板
+type InviteDashboard struct {
+	*model.InviteSummary
+	Campaign *model.InviteCampaign `json:"campaign"` // 当前生效的邀请活动，没有时为 null
+	Invitees []*model.Invitee      `json:"invitees"` // 邀请的人，按邀请时间倒序分页
+	Total    int64                 `json:"total"`    // 邀请的人总数
+}
+
+// GetInviteDashboard 用户的邀请汇总、当前活动和邀请的人
+func (s *InviteService) GetInviteDashboard(ctx context.Context, userID string, page, limit int) (*InviteDashboard, error) {
+	db := s.db.WithContext(ctx)
+	summary, err := model.GetInviteSummary(db, userID)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "统计邀请数据失败", err)
+	}
+	campaign, err := s.ActiveInviteCampaign(ctx)
+	if err != nil {
+		return nil, err
+	}
+	invitees, total, err := model.ListInvitees(db, userID, page, limit)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "查询邀请记录失败", err)
+	}
+	// 风控审核对用户展示为待发放
+	for _, inv := range invitees {
+		if inv.Status == model.InviteStatusHeld {
+			inv.Status = model.InviteStatusPending
+		}
+	}
+	return &InviteDashboard{InviteSummary: summary, Campaign: campaign, Invitees: invitees, Total: total}, nil
+}
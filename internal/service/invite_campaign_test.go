@@ -0,0 +1,62 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+)
+
+func TestScaleInviteReward(t *testing.T) {
+	cases := []struct {
+		reward, granted, total, want int
+	}{
+		{100, 300, 300, 100}, // 全额放行
+		{100, 150, 300, 50},  // 降额一半
+		{100, 0, 300, 0},
+		{0, 150, 300, 0},
+		{100, 0, 0, 100}, // 没有奖励总额时不缩减
+	}
+	for _, c := range cases {
+		if got := scaleInviteReward(c.reward, c.granted, c.total); got != c.want {
+			t.Errorf("scaleInviteReward(%d, %d, %d) = %d, want %d", c.reward, c.granted, c.total, got, c.want)
+		}
+	}
+}
+
+func TestValidateInviteCampaign(t *testing.T) {
+	status := int8(1)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(-time.Hour)
+	cases := []struct {
+		name string
+		req  InviteCampaignRequest
+		ok   bool
+	}{
+		{"signup", InviteCampaignRequest{InviterReward: 100, Trigger: model.InviteTriggerSignup}, true},
+		{"no reward", InviteCampaignRequest{Trigger: model.InviteTriggerSignup}, false},
+		{"login day", InviteCampaignRequest{InviteeReward: 50, Trigger: model.InviteTriggerLoginDay, TriggerValue: 3}, true},
+		{"login day without value", InviteCampaignRequest{InviteeReward: 50, Trigger: model.InviteTriggerLoginDay}, false},
+		{"spend", InviteCampaignRequest{Level2Reward: 10, Trigger: model.InviteTriggerSpend, TriggerValue: 500}, true},
+		{"purchase with value", InviteCampaignRequest{InviterReward: 100, Trigger: model.InviteTriggerFirstPurchase, TriggerValue: 1}, false},
+		{"end before start", InviteCampaignRequest{InviterReward: 100, Trigger: model.InviteTriggerSignup, StartAt: &start, EndAt: &end}, false},
+	}
+	for _, c := range cases {
+		c.req.Status = &status
+		if err := validateInviteCampaign(&c.req); (err == nil) != c.ok {
+			t.Errorf("%s: err = %v, want ok %v", c.name, err, c.ok)
+		}
+	}
+}
+
+func TestLoginDayStart(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	// 北京时间 1 月 1 日 23:30 注册
+	registered := time.Date(2026, 1, 1, 15, 30, 0, 0, time.UTC)
+	if got, want := loginDayStart(registered, 1, loc), time.Date(2026, 1, 1, 0, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("day 1 = %v, want %v", got, want)
+	}
+	if got, want := loginDayStart(registered, 3, loc), time.Date(2026, 1, 3, 0, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("day 3 = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/config"
+)
+
+func TestGenerateInviteCode(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		code, err := generateInviteCode()
+		if err != nil {
+			t.Fatalf("generate: %v", err)
+		}
+		if len(code) != inviteCodeLength {
+			t.Errorf("code %q has length %d", code, len(code))
+		}
+		for _, ch := range code {
+			if !strings.ContainsRune(inviteCodeAlphabet, ch) {
+				t.Errorf("code %q contains %q", code, ch)
+			}
+		}
+		if model.NormalizeInviteCode(strings.ToLower(code)) != code {
+			t.Errorf("code %q should be case-insensitive", code)
+		}
+		seen[code] = true
+	}
+	if len(seen) < 99 {
+		t.Errorf("too many duplicate codes: %d unique of 100", len(seen))
+	}
+}
+
+func TestInviteLink(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Invite.LinkPath = "/invite"
+	s := &InviteService{cfg: cfg}
+	if link := s.inviteLink("ABCD2345"); link != "" {
+		t.Errorf("link without base url should be empty, got %q", link)
+	}
+
+	cfg.Invite.LinkBaseURL = "https://example.com/"
+	if link, want := s.inviteLink("ABCD2345"), "https://example.com/invite?invite_code=ABCD2345"; link != want {
+		t.Errorf("link %q, want %q", link, want)
+	}
+}
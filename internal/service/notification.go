@@ -0,0 +1,752 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/audit"
+	"github.com/reusedev/uportal-api/pkg/config"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/logs"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	notificationQueueKey = "notification:queue"       // 待推送通知ID队列
+	notificationRetryKey = "notification:retry"       // 延迟重试集合，score 为下次推送时间
+	lowBalanceNotifyKey  = "notification:low_balance" // 余额提醒去重
+	lowBalanceNotifyTTL  = 24 * time.Hour
+)
+
+// 不可重试的订阅消息错误码
+var subscribeFatalErrCodes = map[int]bool{
+	40003: true, // openid 无效
+	40037: true, // 模板ID无效
+	43101: true, // 用户拒绝接受消息
+	47003: true, // 模板参数不准确
+}
+
+// NotificationService 通知服务，负责站内通知落库和微信订阅消息异步推送
+type NotificationService struct {
+	db        *gorm.DB
+	redis     *redis.Client
+	wechatSvc *WechatService
+	config    *config.Config
+}
+
+// NewNotificationService 创建通知服务实例
+func NewNotificationService(db *gorm.DB, redis *redis.Client, wechatSvc *WechatService, cfg *config.Config) *NotificationService {
+	return &NotificationService{
+		db:        db,
+		redis:     redis,
+		wechatSvc: wechatSvc,
+		config:    cfg,
+	}
+}
+
+// NotifyRequest 通知请求
+type NotifyRequest struct {
+	UserID  string
+	Type    string            // 通知类型，见 model.NotificationType*
+	Title   string            // 站内通知标题
+	Content string            // 站内通知内容
+	Fields  map[string]string // 订阅消息业务字段，按模板配置映射为模板关键词
+}
+
+// Notify 创建站内通知，配置了订阅消息模板时加入推送队列。通知失败不影响业务流程，只记录日志
+func (s *NotificationService) Notify(ctx context.Context, req *NotifyRequest) {
+	if s == nil {
+		return
+	}
+
+	notification := &model.Notification{
+		UserID:     req.UserID,
+		Type:       req.Type,
+		Title:      req.Title,
+		Content:    req.Content,
+		Status:     0,
+		SendStatus: model.NotificationSendNone,
+	}
+
+	tpl, ok := s.template(req.Type)
+	if ok {
+		payload, err := json.Marshal(buildTemplateData(tpl, req.Fields))
+		if err != nil {
+			logs.Business().Error("序列化订阅消息数据失败", zap.String("type", req.Type), zap.Error(err))
+		} else {
+			notification.TemplateID = model.StringPtr(tpl.TemplateID)
+			notification.Payload = model.StringPtr(string(payload))
+			notification.SendStatus = model.NotificationSendPending
+		}
+	}
+
+	if err := model.CreateNotification(s.db, notification); err != nil {
+		logs.Business().Error("创建通知失败",
+			zap.String("user_id", req.UserID),
+			zap.String("type", req.Type),
+			zap.Error(err),
+		)
+		return
+	}
+
+	if notification.SendStatus == model.NotificationSendPending {
+		if err := s.redis.LPush(ctx, notificationQueueKey, notification.ID).Err(); err != nil {
+			logs.Business().Error("通知加入推送队列失败",
+				zap.Int64("notification_id", notification.ID),
+				zap.Error(err),
+			)
+			s.markFailed(notification.ID, notification.SendAttempts, "加入推送队列失败")
+		}
+	}
+}
+
+// NotifyPaymentSucceeded 支付成功通知
+func (s *NotificationService) NotifyPaymentSucceeded(ctx context.Context, order *model.Order) {
+	s.Notify(ctx, &NotifyRequest{
+		UserID:  order.UserID,
+		Type:    model.NotificationTypePaymentSuccess,
+		Title:   "支付成功",
+		Content: fmt.Sprintf("您的订单「%s」已支付成功，金额 %.2f 元。", order.ProductName, order.Amount),
+		Fields: map[string]string{
+			"order_no": order.OrderNo,
+			"product":  order.ProductName,
+			"amount":   fmt.Sprintf("%.2f元", order.Amount),
+			"time":     time.Now().Format("2006-01-02 15:04:05"),
+		},
+	})
+}
+
+// NotifyRefundCompleted 退款完成通知
+func (s *NotificationService) NotifyRefundCompleted(ctx context.Context, order *model.Order) {
+	s.Notify(ctx, &NotifyRequest{
+		UserID:  order.UserID,
+		Type:    model.NotificationTypeRefundCompleted,
+		Title:   "退款完成",
+		Content: fmt.Sprintf("您的订单「%s」已退款，金额 %.2f 元将原路退回。", order.ProductName, order.Amount),
+		Fields: map[string]string{
+			"order_no": order.OrderNo,
+			"product":  order.ProductName,
+			"amount":   fmt.Sprintf("%.2f元", order.Amount),
+			"time":     time.Now().Format("2006-01-02 15:04:05"),
+		},
+	})
+}
+
+// NotifyTaskReward 任务奖励到账通知
+func (s *NotificationService) NotifyTaskReward(ctx context.Context, userID string, task *model.RewardTask) {
+	s.Notify(ctx, &NotifyRequest{
+		UserID:  userID,
+		Type:    model.NotificationTypeTaskCompletion,
+		Title:   "任务完成通知",
+		Content: fmt.Sprintf("恭喜您完成了任务「%s」，获得 %d 代币奖励！", task.TaskName, task.TokenReward),
+		Fields: map[string]string{
+			"task":   task.TaskName,
+			"reward": strconv.Itoa(task.TokenReward),
+			"time":   time.Now().Format("2006-01-02 15:04:05"),
+		},
+	})
+}
+
+// CheckLowBalance 余额从阈值以上降到阈值以下时发送提醒，同一用户24小时内只提醒一次
+func (s *NotificationService) CheckLowBalance(ctx context.Context, userID string, balanceBefore, balanceAfter int64) {
+	if s == nil {
+		return
+	}
+	threshold := int64(s.config.Notification.LowBalanceThreshold)
+	if threshold <= 0 || balanceAfter >= threshold || balanceBefore < threshold {
+		return
+	}
+
+	ok, err := s.redis.SetNX(ctx, fmt.Sprintf("%s:%s", lowBalanceNotifyKey, userID), 1, lowBalanceNotifyTTL).Result()
+	if err != nil {
+		logs.Business().Warn("余额提醒去重失败", zap.String("user_id", userID), zap.Error(err))
+		return
+	}
+	if !ok {
+		return
+	}
+
+	s.Notify(ctx, &NotifyRequest{
+		UserID:  userID,
+		Type:    model.NotificationTypeLowBalance,
+		Title:   "余额不足提醒",
+		Content: fmt.Sprintf("您的代币余额仅剩 %d，为避免影响使用，请及时充值。", balanceAfter),
+		Fields: map[string]string{
+			"balance": strconv.FormatInt(balanceAfter, 10),
+			"tip":     "余额不足，请及时充值",
+			"time":    time.Now().Format("2006-01-02 15:04:05"),
+		},
+	})
+}
+
+// SubscribeTemplateInfo 可订阅的消息模板
+type SubscribeTemplateInfo struct {
+	Type       string `json:"type"`
+	TemplateID string `json:"template_id"`
+	LongTerm   bool   `json:"long_term"`
+}
+
+// ListSubscribeTemplates 获取已配置的订阅消息模板，供客户端调用 wx.requestSubscribeMessage
+func (s *NotificationService) ListSubscribeTemplates() []*SubscribeTemplateInfo {
+	list := make([]*SubscribeTemplateInfo, 0, len(s.config.Notification.Templates))
+	for name, tpl := range s.config.Notification.Templates {
+		list = append(list, &SubscribeTemplateInfo{
+			Type:       strings.ToUpper(name),
+			TemplateID: tpl.TemplateID,
+			LongTerm:   tpl.LongTerm,
+		})
+	}
+	return list
+}
+
+// RecordSubscribeResults 记录 wx.requestSubscribeMessage 的授权结果，results 为模板ID到 accept/reject 的映射
+func (s *NotificationService) RecordSubscribeResults(ctx context.Context, userID string, results map[string]string) error {
+	known := make(map[string]bool, len(s.config.Notification.Templates))
+	for _, tpl := range s.config.Notification.Templates {
+		known[tpl.TemplateID] = true
+	}
+
+	for templateID, result := range results {
+		if result != "accept" {
+			continue
+		}
+		if !known[templateID] {
+			return errors.New(errors.ErrCodeInvalidParams, "未知的订阅消息模板", nil)
+		}
+		if err := model.AddSubscribeAuth(s.db, userID, templateID); err != nil {
+			return errors.New(errors.ErrCodeInternal, "记录订阅授权失败", err)
+		}
+	}
+	return nil
+}
+
+// ListSubscribeAuths 获取用户的订阅授权
+func (s *NotificationService) ListSubscribeAuths(ctx context.Context, userID string) ([]*model.UserSubscribeAuth, error) {
+	auths, err := model.ListSubscribeAuths(s.db, userID)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "查询订阅授权失败", err)
+	}
+	return auths, nil
+}
+
+// Start 启动推送队列消费协程和延迟重试搬运协程，ctx 取消后退出
+func (s *NotificationService) Start(ctx context.Context) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	for i := 0; i < s.config.Notification.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.runWorker(ctx)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.runRetryMover(ctx)
+	}()
+	return &wg
+}
+
+// runWorker 从队列中取出通知并推送
+func (s *NotificationService) runWorker(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		result, err := s.redis.BRPop(ctx, 5*time.Second, notificationQueueKey).Result()
+		if err != nil {
+			if err != redis.Nil && ctx.Err() == nil {
+				logs.Business().Warn("读取推送队列失败", zap.Error(err))
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+
+		id, err := strconv.ParseInt(result[1], 10, 64)
+		if err != nil {
+			logs.Business().Warn("推送队列中存在无效数据", zap.String("value", result[1]))
+			continue
+		}
+		s.deliver(ctx, id)
+	}
+}
+
+// runRetryMover 将到期的重试任务移回推送队列
+func (s *NotificationService) runRetryMover(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := strconv.FormatInt(time.Now().Unix(), 10)
+		ids, err := s.redis.ZRangeByScore(ctx, notificationRetryKey, &redis.ZRangeBy{Min: "-inf", Max: now, Count: 100}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				logs.Business().Warn("读取重试集合失败", zap.Error(err))
+			}
+			continue
+		}
+		for _, id := range ids {
+			// 多实例同时搬运时只有删除成功的实例负责入队
+			removed, err := s.redis.ZRem(ctx, notificationRetryKey, id).Result()
+			if err != nil || removed == 0 {
+				continue
+			}
+			if err := s.redis.LPush(ctx, notificationQueueKey, id).Err(); err != nil {
+				logs.Business().Warn("重试通知入队失败", zap.String("notification_id", id), zap.Error(err))
+			}
+		}
+	}
+}
+
+// deliver 推送单条通知
+func (s *NotificationService) deliver(ctx context.Context, id int64) {
+	notification, err := model.GetNotificationByID(s.db, id)
+	if err != nil {
+		logs.Business().Warn("获取待推送通知失败", zap.Int64("notification_id", id), zap.Error(err))
+		return
+	}
+	if notification.SendStatus != model.NotificationSendPending || notification.TemplateID == nil {
+		return
+	}
+	attempts := notification.SendAttempts + 1
+	templateID := *notification.TemplateID
+
+	openID, err := model.GetUserOpenID(s.db, notification.UserID)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			s.markFailed(id, attempts, "用户未绑定微信")
+			return
+		}
+		s.scheduleRetry(ctx, id, attempts, err)
+		return
+	}
+
+	tpl, _ := s.template(notification.Type)
+	if !tpl.LongTerm {
+		auth, err := model.GetSubscribeAuth(s.db, notification.UserID, templateID)
+		if err != nil && !stderrors.Is(err, gorm.ErrRecordNotFound) {
+			s.scheduleRetry(ctx, id, attempts, err)
+			return
+		}
+		if auth == nil || auth.RemainingCount <= 0 {
+			s.markFailed(id, attempts, "用户未授权该订阅消息")
+			return
+		}
+	}
+
+	var data map[string]interface{}
+	if notification.Payload != nil {
+		if err := json.Unmarshal([]byte(*notification.Payload), &data); err != nil {
+			s.markFailed(id, attempts, "订阅消息数据格式错误")
+			return
+		}
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	err = s.wechatSvc.SendSubscribeMessage(sendCtx, openID, templateID, data, tpl.Page)
+	cancel()
+	if err != nil {
+		var wxErr *WechatError
+		if stderrors.As(err, &wxErr) && subscribeFatalErrCodes[wxErr.Code] {
+			s.markFailed(id, attempts, wxErr.Error())
+			return
+		}
+		s.scheduleRetry(ctx, id, attempts, err)
+		return
+	}
+
+	if !tpl.LongTerm {
+		if _, err := model.ConsumeSubscribeAuth(s.db, notification.UserID, templateID); err != nil {
+			logs.Business().Warn("扣减订阅授权次数失败",
+				zap.String("user_id", notification.UserID),
+				zap.String("template_id", templateID),
+				zap.Error(err),
+			)
+		}
+	}
+
+	if err := model.UpdateNotification(s.db, id, map[string]interface{}{
+		"send_status":   model.NotificationSendSuccess,
+		"send_attempts": attempts,
+		"send_error":    nil,
+		"sent_at":       time.Now(),
+	}); err != nil {
+		logs.Business().Warn("更新通知推送状态失败", zap.Int64("notification_id", id), zap.Error(err))
+	}
+}
+
+// scheduleRetry 按指数退避安排重试，超过最大次数后标记失败
+func (s *NotificationService) scheduleRetry(ctx context.Context, id int64, attempts int, cause error) {
+	if attempts > s.config.Notification.MaxRetries {
+		s.markFailed(id, attempts, cause.Error())
+		return
+	}
+
+	if err := model.UpdateNotification(s.db, id, map[string]interface{}{
+		"send_attempts": attempts,
+		"send_error":    truncateRunes(cause.Error(), 255),
+	}); err != nil {
+		logs.Business().Warn("更新通知推送状态失败", zap.Int64("notification_id", id), zap.Error(err))
+	}
+
+	delay := s.config.Notification.RetryDelay * time.Duration(1<<uint(attempts-1))
+	score := float64(time.Now().Add(delay).Unix())
+	if err := s.redis.ZAdd(ctx, notificationRetryKey, &redis.Z{Score: score, Member: id}).Err(); err != nil {
+		logs.Business().Error("通知加入重试集合失败", zap.Int64("notification_id", id), zap.Error(err))
+		s.markFailed(id, attempts, "加入重试集合失败")
+		return
+	}
+	logs.Business().Info("通知推送失败，稍后重试",
+		zap.Int64("notification_id", id),
+		zap.Int("attempts", attempts),
+		zap.Duration("delay", delay),
+		zap.Error(cause),
+	)
+}
+
+// markFailed 标记推送失败
+func (s *NotificationService) markFailed(id int64, attempts int, reason string) {
+	if err := model.UpdateNotification(s.db, id, map[string]interface{}{
+		"send_status":   model.NotificationSendFailed,
+		"send_attempts": attempts,
+		"send_error":    truncateRunes(reason, 255),
+	}); err != nil {
+		logs.Business().Warn("更新通知推送状态失败", zap.Int64("notification_id", id), zap.Error(err))
+	}
+}
+
+// template 获取通知类型对应的订阅消息模板
+func (s *NotificationService) template(notificationType string) (config.SubscribeTemplate, bool) {
+	tpl, ok := s.config.Notification.Templates[strings.ToLower(notificationType)]
+	return tpl, ok
+}
+
+// buildTemplateData 按模板字段映射生成订阅消息数据，thing 类关键词限制20个字符
+func buildTemplateData(tpl config.SubscribeTemplate, fields map[string]string) map[string]interface{} {
+	data := make(map[string]interface{}, len(tpl.Fields))
+	for field, keyword := range tpl.Fields {
+		value, ok := fields[field]
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(keyword, "thing") {
+			value = truncateRunes(value, 20)
+		}
+		data[keyword] = value
+	}
+	return data
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+const (
+	broadcastBatchSize = 500
+	broadcastBatchGap  = 50 * time.Millisecond // 批次间隔，避免长时间占用数据库
+	broadcastLockKey   = "notification:broadcast_lock:%d"
+	broadcastLockTTL   = time.Minute
+)
+
+// ListNotificationsRequest 通知列表请求
+type ListNotificationsRequest struct {
+	Prev       int64 `json:"prev"`        // 上一页最后一条通知ID，首页不传
+	Limit      int   `json:"limit"`       // 每页数量，默认20，最大100
+	UnreadOnly bool  `json:"unread_only"` // 只看未读
+}
+
+// ListNotificationsResponse 通知列表响应
+type ListNotificationsResponse struct {
+	List    []*model.Notification `json:"list"`
+	Next    int64                 `json:"next"`     // 下一页游标，没有更多时为0
+	HasMore bool                  `json:"has_more"` // 是否还有更多
+}
+
+// ListNotifications 按游标获取用户通知
+func (s *NotificationService) ListNotifications(ctx context.Context, userID string, req *ListNotificationsRequest) (*ListNotificationsResponse, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	// 多取一条判断是否还有下一页
+	list, err := model.ListUserNotifications(s.db, userID, req.Prev, limit+1, req.UnreadOnly)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "获取通知列表失败", err)
+	}
+
+	resp := &ListNotificationsResponse{List: list}
+	if len(list) > limit {
+		resp.List = list[:limit]
+		resp.HasMore = true
+		resp.Next = resp.List[limit-1].ID
+	}
+	return resp, nil
+}
+
+// GetUnreadCount 获取未读通知数
+func (s *NotificationService) GetUnreadCount(ctx context.Context, userID string) (int64, error) {
+	count, err := model.CountUnreadNotifications(s.db, userID)
+	if err != nil {
+		return 0, errors.New(errors.ErrCodeInternal, "获取未读通知数失败", err)
+	}
+	return count, nil
+}
+
+// MarkRead 标记一条通知为已读
+func (s *NotificationService) MarkRead(ctx context.Context, userID string, id int64) error {
+	if _, err := model.GetUserNotification(s.db, userID, id); err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New(errors.ErrCodeNotFound, "通知不存在", nil)
+		}
+		return errors.New(errors.ErrCodeInternal, "查询通知失败", err)
+	}
+	if _, err := model.MarkNotificationRead(s.db, userID, id); err != nil {
+		return errors.New(errors.ErrCodeInternal, "标记已读失败", err)
+	}
+	return nil
+}
+
+// MarkAllRead 标记全部通知为已读，返回本次标记的数量
+func (s *NotificationService) MarkAllRead(ctx context.Context, userID string) (int64, error) {
+	affected, err := model.MarkAllNotificationsRead(s.db, userID)
+	if err != nil {
+		return 0, errors.New(errors.ErrCodeInternal, "标记全部已读失败", err)
+	}
+	return affected, nil
+}
+
+// DeleteNotification 删除一条通知
+func (s *NotificationService) DeleteNotification(ctx context.Context, userID string, id int64) error {
+	affected, err := model.DeleteUserNotification(s.db, userID, id)
+	if err != nil {
+		return errors.New(errors.ErrCodeInternal, "删除通知失败", err)
+	}
+	if affected == 0 {
+		return errors.New(errors.ErrCodeNotFound, "通知不存在", nil)
+	}
+	return nil
+}
+
+// BroadcastFilter 群发用户筛选条件，均为空时发送给全部用户
+type BroadcastFilter struct {
+	UserIDs     []string `json:"user_ids,omitempty"`     // 指定用户
+	Status      *int     `json:"status,omitempty"`       // 账号状态
+	CreatedFrom string   `json:"created_from,omitempty"` // 注册时间起，格式 2006-01-02
+	CreatedTo   string   `json:"created_to,omitempty"`   // 注册时间止（含当天），格式 2006-01-02
+}
+
+// BroadcastRequest 群发请求
+type BroadcastRequest struct {
+	Title   string          `json:"title" binding:"required,max=128"`
+	Content string          `json:"content" binding:"required"`
+	Filter  BroadcastFilter `json:"filter"`
+}
+
+// Broadcast 创建群发任务并在后台分批投递
+func (s *NotificationService) Broadcast(ctx context.Context, adminID int64, req *BroadcastRequest) (*model.NotificationBroadcast, error) {
+	if _, err := s.applyBroadcastFilter(s.db, &req.Filter); err != nil {
+		return nil, err
+	}
+	filter, err := json.Marshal(req.Filter)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "序列化筛选条件失败", err)
+	}
+
+	broadcast := &model.NotificationBroadcast{
+		AdminID: adminID,
+		Title:   req.Title,
+		Content: req.Content,
+		Filter:  string(filter),
+		Status:  model.BroadcastStatusPending,
+	}
+	if err := model.CreateNotificationBroadcast(s.db, broadcast); err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "创建群发任务失败", err)
+	}
+	// 群发任务会在后台更新进度，审计记录使用创建时的副本
+	snapshot := *broadcast
+	audit.Record(ctx, "notification_broadcast", strconv.FormatInt(broadcast.ID, 10), nil, &snapshot)
+
+	go s.runBroadcast(context.Background(), broadcast)
+	return broadcast, nil
+}
+
+// GetBroadcast 获取群发任务
+func (s *NotificationService) GetBroadcast(ctx context.Context, id int64) (*model.NotificationBroadcast, error) {
+	broadcast, err := model.GetNotificationBroadcast(s.db, id)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New(errors.ErrCodeNotFound, "群发任务不存在", nil)
+		}
+		return nil, errors.New(errors.ErrCodeInternal, "查询群发任务失败", err)
+	}
+	return broadcast, nil
+}
+
+// ListBroadcasts 分页获取群发任务
+func (s *NotificationService) ListBroadcasts(ctx context.Context, page, limit int) ([]*model.NotificationBroadcast, int64, error) {
+	list, total, err := model.ListNotificationBroadcasts(s.db, page, limit)
+	if err != nil {
+		return nil, 0, errors.New(errors.ErrCodeInternal, "查询群发任务失败", err)
+	}
+	return list, total, nil
+}
+
+// ResumeBroadcasts 服务启动时续跑未完成的群发任务
+func (s *NotificationService) ResumeBroadcasts(ctx context.Context) {
+	list, err := model.ListUnfinishedBroadcasts(s.db)
+	if err != nil {
+		logs.Business().Error("查询未完成群发任务失败", zap.Error(err))
+		return
+	}
+	for _, broadcast := range list {
+		go s.runBroadcast(ctx, broadcast)
+	}
+}
+
+// runBroadcast 按用户ID游标分批写入通知，每批独立提交并记录进度，不锁用户表
+func (s *NotificationService) runBroadcast(ctx context.Context, broadcast *model.NotificationBroadcast) {
+	// 多实例部署时同一任务只允许一个实例执行
+	lockKey := fmt.Sprintf(broadcastLockKey, broadcast.ID)
+	locked, err := s.redis.SetNX(ctx, lockKey, 1, broadcastLockTTL).Result()
+	if err != nil || !locked {
+		return
+	}
+	defer s.redis.Del(context.Background(), lockKey)
+
+	var filter BroadcastFilter
+	if err := json.Unmarshal([]byte(broadcast.Filter), &filter); err != nil {
+		s.failBroadcast(broadcast.ID, "筛选条件格式错误")
+		return
+	}
+	if err := model.UpdateNotificationBroadcast(s.db, broadcast.ID, map[string]interface{}{
+		"status": model.BroadcastStatusRunning,
+	}); err != nil {
+		logs.Business().Error("更新群发任务状态失败", zap.Int64("broadcast_id", broadcast.ID), zap.Error(err))
+		return
+	}
+
+	lastUserID := broadcast.LastUserID
+	sent := broadcast.SentCount
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		query, err := s.applyBroadcastFilter(s.db.Model(&model.User{}), &filter)
+		if err != nil {
+			s.failBroadcast(broadcast.ID, err.Error())
+			return
+		}
+		var userIDs []string
+		if err := query.Where("id > ?", lastUserID).
+			Order("id ASC").
+			Limit(broadcastBatchSize).
+			Pluck("id", &userIDs).Error; err != nil {
+			s.failBroadcast(broadcast.ID, "查询用户失败")
+			logs.Business().Error("群发查询用户失败", zap.Int64("broadcast_id", broadcast.ID), zap.Error(err))
+			return
+		}
+		if len(userIDs) == 0 {
+			break
+		}
+
+		notifications := make([]*model.Notification, 0, len(userIDs))
+		for _, userID := range userIDs {
+			notifications = append(notifications, &model.Notification{
+				UserID:      userID,
+				Type:        model.NotificationTypeSystem,
+				Title:       broadcast.Title,
+				Content:     broadcast.Content,
+				BroadcastID: &broadcast.ID,
+				SendStatus:  model.NotificationSendNone,
+			})
+		}
+
+		lastUserID = userIDs[len(userIDs)-1]
+		sent += int64(len(userIDs))
+		err = s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.CreateInBatches(notifications, 100).Error; err != nil {
+				return err
+			}
+			return model.UpdateNotificationBroadcast(tx, broadcast.ID, map[string]interface{}{
+				"last_user_id": lastUserID,
+				"sent_count":   sent,
+			})
+		})
+		if err != nil {
+			s.failBroadcast(broadcast.ID, "写入通知失败")
+			logs.Business().Error("群发写入通知失败", zap.Int64("broadcast_id", broadcast.ID), zap.Error(err))
+			return
+		}
+
+		s.redis.Expire(ctx, lockKey, broadcastLockTTL)
+		time.Sleep(broadcastBatchGap)
+	}
+
+	now := time.Now()
+	if err := model.UpdateNotificationBroadcast(s.db, broadcast.ID, map[string]interface{}{
+		"status":      model.BroadcastStatusFinished,
+		"finished_at": &now,
+	}); err != nil {
+		logs.Business().Error("更新群发任务状态失败", zap.Int64("broadcast_id", broadcast.ID), zap.Error(err))
+		return
+	}
+	logs.Business().Info("群发任务完成", zap.Int64("broadcast_id", broadcast.ID), zap.Int64("sent_count", sent))
+}
+
+// applyBroadcastFilter 将筛选条件应用到用户查询
+func (s *NotificationService) applyBroadcastFilter(query *gorm.DB, filter *BroadcastFilter) (*gorm.DB, error) {
+	if len(filter.UserIDs) > 0 {
+		query = query.Where("id IN ?", filter.UserIDs)
+	}
+	if filter.Status != nil {
+		query = query.Where("status = ?", *filter.Status)
+	}
+	if filter.CreatedFrom != "" {
+		from, err := time.ParseInLocation(time.DateOnly, filter.CreatedFrom, time.Local)
+		if err != nil {
+			return nil, errors.New(errors.ErrCodeInvalidParams, "注册开始日期格式错误", err)
+		}
+		query = query.Where("created_at >= ?", from)
+	}
+	if filter.CreatedTo != "" {
+		to, err := time.ParseInLocation(time.DateOnly, filter.CreatedTo, time.Local)
+		if err != nil {
+			return nil, errors.New(errors.ErrCodeInvalidParams, "注册结束日期格式错误", err)
+		}
+		query = query.Where("created_at < ?", to.AddDate(0, 0, 1))
+	}
+	return query, nil
+}
+
+// failBroadcast 标记群发任务失败，已投递的进度保留
+func (s *NotificationService) failBroadcast(id int64, reason string) {
+	if err := model.UpdateNotificationBroadcast(s.db, id, map[string]interface{}{
+		"status": model.BroadcastStatusFailed,
+		"error":  truncateRunes(reason, 255),
+	}); err != nil {
+		logs.Business().Error("更新群发任务状态失败", zap.Int64("broadcast_id", id), zap.Error(err))
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/config"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestBuildTemplateData(t *testing.T) {
+	tpl := config.SubscribeTemplate{
+		TemplateID: "tpl",
+		Fields: map[string]string{
+			"amount": "amount2",
+			"title":  "thing1",
+			"time":   "time3",
+		},
+	}
+	longTitle := strings.Repeat("任", 25)
+
+	cases := []struct {
+		name   string
+		fields map[string]string
+		want   map[string]interface{}
+	}{
+		{"maps fields to keywords", map[string]string{"amount": "100", "title": "充值成功", "time": "2026-10-18 12:00"},
+			map[string]interface{}{"amount2": "100", "thing1": "充值成功", "time3": "2026-10-18 12:00"}},
+		{"skips missing fields", map[string]string{"amount": "100"},
+			map[string]interface{}{"amount2": "100"}},
+		{"ignores unmapped fields", map[string]string{"amount": "100", "extra": "x"},
+			map[string]interface{}{"amount2": "100"}},
+		{"truncates thing keywords to 20 runes", map[string]string{"title": longTitle},
+			map[string]interface{}{"thing1": strings.Repeat("任", 20)}},
+	}
+	for _, c := range cases {
+		if got := buildTemplateData(tpl, c.fields); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestTruncateRunes(t *testing.T) {
+	cases := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 5, "hello"},
+		{"hello", 3, "hel"},
+		{"代币到账提醒", 4, "代币到账"},
+		{"", 3, ""},
+	}
+	for _, c := range cases {
+		if got := truncateRunes(c.in, c.n); got != c.want {
+			t.Errorf("truncateRunes(%q, %d) = %q, want %q", c.in, c.n, got, c.want)
+		}
+	}
+}
+
+// newDryRunDB 创建只生成 SQL、不连接数据库的 GORM 实例，用于检查查询条件
+func newDryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		DSN:                       "test:test@tcp(127.0.0.1:3306)/test?parseTime=true",
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open dry run db: %v", err)
+	}
+	return db
+}
+
+func TestApplyBroadcastFilter(t *testing.T) {
+	s := &NotificationService{}
+	status := 1
+
+	cases := []struct {
+		name   string
+		filter BroadcastFilter
+		want   []string
+		vars   int
+		bad    bool
+	}{
+		{"no filter selects everyone", BroadcastFilter{}, nil, 0, false},
+		{"user ids", BroadcastFilter{UserIDs: []string{"a", "b"}}, []string{"id IN (?,?)"}, 2, false},
+		{"status", BroadcastFilter{Status: &status}, []string{"status = ?"}, 1, false},
+		{"created range includes end day", BroadcastFilter{CreatedFrom: "2026-10-01", CreatedTo: "2026-10-18"},
+			[]string{"created_at >= ?", "created_at < ?"}, 2, false},
+		{"bad from date", BroadcastFilter{CreatedFrom: "2026/10/01"}, nil, 0, true},
+		{"bad to date", BroadcastFilter{CreatedTo: "yesterday"}, nil, 0, true},
+	}
+	for _, c := range cases {
+		query, err := s.applyBroadcastFilter(newDryRunDB(t).Model(&model.User{}), &c.filter)
+		if c.bad {
+			if err == nil {
+				t.Errorf("%s: expected error", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		stmt := query.Find(&[]model.User{}).Statement
+		sql := stmt.SQL.String()
+		for _, w := range c.want {
+			if !strings.Contains(sql, w) {
+				t.Errorf("%s: sql %q missing %q", c.name, sql, w)
+			}
+		}
+		if len(stmt.Vars) != c.vars {
+			t.Errorf("%s: %d vars, want %d", c.name, len(stmt.Vars), c.vars)
+		}
+		if c.filter.CreatedTo != "" {
+			to := stmt.Vars[len(stmt.Vars)-1].(time.Time)
+			if want := time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local); !to.Equal(want) {
+				t.Errorf("%s: end %v, want %v", c.name, to, want)
+			}
+		}
+	}
+}
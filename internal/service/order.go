@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	stderrors "errors"
+	"math"
+	"strconv"
 	"time"
 
 	"github.com/reusedev/uportal-api/internal/model"
@@ -13,12 +15,15 @@ import (
 
 // OrderService 订单服务
 type OrderService struct {
-	db *gorm.DB
+	db           *gorm.DB
+	notifier     *NotificationService
+	achievements *AchievementService
+	invites      *InviteService
 }
 
-// NewOrderService 创建订单服务实例
-func NewOrderService(db *gorm.DB) *OrderService {
-	return &OrderService{db: db}
+// NewOrderService 创建订单服务实例，achievements 为 nil 时不统计成就进度，invites 为 nil 时不结算邀请活动
+func NewOrderService(db *gorm.DB, notifier *NotificationService, achievements *AchievementService, invites *InviteService) *OrderService {
+	return &OrderService{db: db, notifier: notifier, achievements: achievements, invites: invites}
 }
 
 // CreateOrder 创建订单
@@ -53,6 +58,30 @@ func (s *OrderService) GetOrder(ctx context.Context, orderID int64) (*model.Orde
 	return order, nil
 }
 
+// OrderFilter 订单筛选条件，用于导出
+type OrderFilter struct {
+	UserID    string     `json:"user_id"`
+	Status    string     `json:"status"`
+	StartTime *time.Time `json:"start_time,omitempty"`
+	EndTime   *time.Time `json:"end_time,omitempty"`
+}
+
+func (f *OrderFilter) apply(db *gorm.DB) *gorm.DB {
+	if f.UserID != "" {
+		db = db.Where("user_id = ?", f.UserID)
+	}
+	if f.Status != "" {
+		db = db.Where("status = ?", f.Status)
+	}
+	if f.StartTime != nil {
+		db = db.Where("created_at >= ?", f.StartTime)
+	}
+	if f.EndTime != nil {
+		db = db.Where("created_at <= ?", f.EndTime)
+	}
+	return db
+}
+
 // ListOrders 获取订单列表
 func (s *OrderService) ListOrders(ctx context.Context, page, pageSize int, userID int64, status string) ([]*model.Order, int64, error) {
 	var orders []*model.Order
@@ -128,5 +157,20 @@ func (s *OrderService) UpdateOrderStatus(ctx context.Context, orderID int64, sta
 		return errors.New(errors.ErrCodeInternal, "更新订单状态失败", err)
 	}
 
+	// 支付成功、退款完成时通知用户
+	switch status {
+	case model.OrderStatusPaid:
+		s.notifier.NotifyPaymentSucceeded(context.Background(), order)
+		s.achievements.Track(context.Background(), &AchievementEvent{
+			UserID: order.UserID,
+			Type:   model.AchievementEventOrderPaid,
+			Key:    strconv.FormatInt(order.OrderID, 10),
+			Amount: int64(math.Round(order.Amount * 100)),
+		})
+		s.invites.SettleInvite(context.Background(), order.UserID)
+	case model.OrderStatusRefunded:
+		s.notifier.NotifyRefundCompleted(context.Background(), order)
+	}
+
 	return nil
 }
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/reusedev/uportal-api/pkg/config"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordPolicy 密码策略
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	RequireLetter bool
+	RequireDigit  bool
+	RequireUpper  bool
+	RequireSymbol bool
+	BcryptCost    int
+}
+
+// NewPasswordPolicy 根据配置创建密码策略
+func NewPasswordPolicy(cfg *config.Config) *PasswordPolicy {
+	p := cfg.Account.PasswordPolicy
+	return &PasswordPolicy{
+		MinLength:     p.MinLength,
+		MaxLength:     p.MaxLength,
+		RequireLetter: p.RequireLetter,
+		RequireDigit:  p.RequireDigit,
+		RequireUpper:  p.RequireUpper,
+		RequireSymbol: p.RequireSymbol,
+		BcryptCost:    cfg.Account.BcryptCost,
+	}
+}
+
+// Validate 校验密码是否满足策略
+func (p *PasswordPolicy) Validate(password string) error {
+	length := utf8.RuneCountInString(password)
+	if p.MinLength > 0 && length < p.MinLength {
+		return errors.New(errors.ErrCodeWeakPassword, fmt.Sprintf("密码长度不能少于%d位", p.MinLength), nil)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return errors.New(errors.ErrCodeWeakPassword, fmt.Sprintf("密码长度不能超过%d位", p.MaxLength), nil)
+	}
+	// bcrypt 只处理前 72 字节，超出部分会被静默忽略
+	if len(password) > 72 {
+		return errors.New(errors.ErrCodeWeakPassword, "密码过长", nil)
+	}
+
+	var hasLetter, hasDigit, hasUpper, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+			hasLetter = true
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+	if p.RequireLetter && !hasLetter {
+		return errors.New(errors.ErrCodeWeakPassword, "密码必须包含字母", nil)
+	}
+	if p.RequireDigit && !hasDigit {
+		return errors.New(errors.ErrCodeWeakPassword, "密码必须包含数字", nil)
+	}
+	if p.RequireUpper && !hasUpper {
+		return errors.New(errors.ErrCodeWeakPassword, "密码必须包含大写字母", nil)
+	}
+	if p.RequireSymbol && !hasSymbol {
+		return errors.New(errors.ErrCodeWeakPassword, "密码必须包含特殊字符", nil)
+	}
+	return nil
+}
+
+// Hash 校验并生成密码哈希
+func (p *PasswordPolicy) Hash(password string) (string, error) {
+	if err := p.Validate(password); err != nil {
+		return "", err
+	}
+	cost := p.BcryptCost
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", errors.New(errors.ErrCodeInternal, "生成密码哈希失败", err)
+	}
+	return string(hash), nil
+}
@@ -0,0 +1,371 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/config"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/logs"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 个人数据导出格式
+const (
+	PrivacyFormatJSON = "json" // 单个 JSON 文件
+	PrivacyFormatZIP  = "zip"  // ZIP 压缩包，每类数据一个 JSON 文件
+)
+
+const (
+	// privacyExportKeyPrefix 个人数据导出冷却期的 Redis 键前缀
+	privacyExportKeyPrefix = "privacy:export:"
+	// privacyDeletionLockKey 多实例部署时同一时刻只允许一个实例执行到期的注销申请
+	privacyDeletionLockKey = "privacy:deletion:lock"
+	// privacyDeletionLockTTL 注销执行锁的最长持有时间
+	privacyDeletionLockTTL = 10 * time.Minute
+	// privacyDeletionBatch 每轮最多执行的注销申请数
+	privacyDeletionBatch = 100
+)
+
+// privacySection 导出的一类个人数据，Name 同时作为 JSON 字段名和 ZIP 内的文件名
+type privacySection struct {
+	Name  string
+	Write func(ctx context.Context, w io.Writer, userID string) error
+}
+
+// privacyOrder 导出的订单，不含关联用户
+type privacyOrder struct {
+	OrderID     int64             `json:"order_id"`
+	OrderNo     string            `json:"order_no"`
+	Amount      float64           `json:"amount"`
+	ProductID   string            `json:"product_id"`
+	ProductName string            `json:"product_name"`
+	Status      model.OrderStatus `json:"status"`
+	CreatedAt   time.Time         `json:"created_at"`
+	PaidAt      *time.Time        `json:"paid_at"`
+}
+
+// privacyRechargeOrder 导出的充值订单，不含关联用户和方案
+type privacyRechargeOrder struct {
+	OrderID       int64      `json:"order_id"`
+	PlanID        *int       `json:"plan_id"`
+	TokenAmount   int        `json:"token_amount"`
+	AmountPaid    float64    `json:"amount_paid"`
+	PaymentMethod string     `json:"payment_method"`
+	Status        int8       `json:"status"`
+	TransactionID *string    `json:"transaction_id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	PaidAt        *time.Time `json:"paid_at"`
+}
+
+// privacyInvite 导出的邀请记录，不含对方的用户资料
+type privacyInvite struct {
+	RecordID    int64     `json:"record_id"`
+	InviterID   string    `json:"inviter_id"`
+	InviteeID   string    `json:"invitee_id"`
+	TokenReward int       `json:"token_reward"`
+	Status      int8      `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// PrivacyService 个人信息保护服务，提供个人数据导出和带冷静期的账号注销
+type PrivacyService struct {
+	db     *gorm.DB
+	redis  *redis.Client
+	config *config.Config
+}
+
+// NewPrivacyService 创建个人信息保护服务
+func NewPrivacyService(db *gorm.DB, redis *redis.Client, cfg *config.Config) *PrivacyService {
+	return &PrivacyService{db: db, redis: redis, config: cfg}
+}
+
+// PrivacyExportFileName 个人数据导出的文件名
+func PrivacyExportFileName(userID, format string) string {
+	return fmt.Sprintf("personal-data-%s-%s.%s", userID, time.Now().Format("20060102150405"), format)
+}
+
+// ValidateExport 检查用户能否导出个人数据，通过后开始计算冷却期
+func (s *PrivacyService) ValidateExport(ctx context.Context, userID string) error {
+	user, err := model.GetUserByID(s.db.WithContext(ctx), userID)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.ErrUserNotFound
+		}
+		return errors.New(errors.ErrCodeDatabaseError, "查询用户失败", err)
+	}
+	if user.Status != 1 {
+		return errors.ErrUserDisabled
+	}
+
+	if s.redis != nil && s.config.Privacy.ExportCooldown > 0 {
+		ok, err := s.redis.SetNX(ctx, privacyExportKeyPrefix+userID, 1, s.config.Privacy.ExportCooldown).Result()
+		if err != nil {
+			return errors.New(errors.ErrCodeRedisError, "检查导出频率失败", err)
+		}
+		if !ok {
+			return errors.New(errors.ErrCodeTooManyRequests, "导出过于频繁，请稍后再试", nil)
+		}
+	}
+	return nil
+}
+
+// Export 导出用户的个人数据：资料、第三方绑定、登录日志、代币流水、订单、任务完成记录和邀请记录
+func (s *PrivacyService) Export(ctx context.Context, userID, format string, w io.Writer) error {
+	sections := s.sections()
+	switch format {
+	case PrivacyFormatZIP:
+		zw := zip.NewWriter(w)
+		for _, section := range sections {
+			fw, err := zw.Create(section.Name + ".json")
+			if err != nil {
+				return err
+			}
+			if err := section.Write(ctx, fw, userID); err != nil {
+				return fmt.Errorf("%s: %w", section.Name, err)
+			}
+		}
+		return zw.Close()
+	case PrivacyFormatJSON:
+		if _, err := io.WriteString(w, "{"); err != nil {
+			return err
+		}
+		for i, section := range sections {
+			prefix := fmt.Sprintf("%q:", section.Name)
+			if i > 0 {
+				prefix = "," + prefix
+			}
+			if _, err := io.WriteString(w, prefix); err != nil {
+				return err
+			}
+			if err := section.Write(ctx, w, userID); err != nil {
+				return fmt.Errorf("%s: %w", section.Name, err)
+			}
+		}
+		_, err := io.WriteString(w, "}")
+		return err
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// sections 导出的各类个人数据，财务相关记录按时间正序
+func (s *PrivacyService) sections() []privacySection {
+	return []privacySection{
+		{Name: "profile", Write: func(ctx context.Context, w io.Writer, userID string) error {
+			var user model.User
+			if err := s.db.WithContext(ctx).Preload("UserAuths").Where("id = ?", userID).First(&user).Error; err != nil {
+				return err
+			}
+			return json.NewEncoder(w).Encode(user)
+		}},
+		{Name: "auth_providers", Write: func(ctx context.Context, w io.Writer, userID string) error {
+			query := s.db.WithContext(ctx).Model(&model.UserAuth{}).Where("user_id = ?", userID).Order("auth_id ASC")
+			return writePrivacyRows[model.UserAuth](s.db, query, w)
+		}},
+		{Name: "login_logs", Write: func(ctx context.Context, w io.Writer, userID string) error {
+			query := s.db.WithContext(ctx).Model(&model.UserLoginLog{}).Where("user_id = ?", userID).Order("log_id ASC")
+			return writePrivacyRows[model.UserLoginLog](s.db, query, w)
+		}},
+		{Name: "token_records", Write: func(ctx context.Context, w io.Writer, userID string) error {
+			query := s.db.WithContext(ctx).Model(&model.TokenRecord{}).Where("user_id = ?", userID).Order("record_id ASC")
+			return writePrivacyRows[model.TokenRecord](s.db, query, w)
+		}},
+		{Name: "recharge_orders", Write: func(ctx context.Context, w io.Writer, userID string) error {
+			query := s.db.WithContext(ctx).Model(&model.RechargeOrder{}).Where("user_id = ?", userID).Order("order_id ASC")
+			return writePrivacyRows[privacyRechargeOrder](s.db, query, w)
+		}},
+		{Name: "orders", Write: func(ctx context.Context, w io.Writer, userID string) error {
+			query := s.db.WithContext(ctx).Model(&model.Order{}).Where("user_id = ?", userID).Order("order_id ASC")
+			return writePrivacyRows[privacyOrder](s.db, query, w)
+		}},
+		{Name: "task_completions", Write: func(ctx context.Context, w io.Writer, userID string) error {
+			query := s.db.WithContext(ctx).Model(&model.TaskCompletionRecord{}).Where("user_id = ?", userID).Order("id ASC")
+			return writePrivacyRows[model.TaskCompletionRecord](s.db, query, w)
+		}},
+		{Name: "invites", Write: func(ctx context.Context, w io.Writer, userID string) error {
+			query := s.db.WithContext(ctx).Model(&model.InviteRecord{}).
+				Where("inviter_id = ? OR invitee_id = ?", userID, userID).Order("record_id ASC")
+			return writePrivacyRows[privacyInvite](s.db, query, w)
+		}},
+	}
+}
+
+// writePrivacyRows 逐行读取查询结果并写成 JSON 数组，避免一次加载全部记录
+func writePrivacyRows[T any](db *gorm.DB, query *gorm.DB, w io.Writer) error {
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	for first := true; rows.Next(); first = false {
+		var row T
+		if err := db.ScanRows(rows, &row); err != nil {
+			return err
+		}
+		data, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "]")
+	return err
+}
+
+// RequestDeletion 申请注销账号，冷静期结束后匿名化个人信息，冷静期内可以撤销
+func (s *PrivacyService) RequestDeletion(ctx context.Context, userID, reason string) (*model.UserDeletionRequest, error) {
+	db := s.db.WithContext(ctx)
+	user, err := model.GetUserByID(db, userID)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.ErrUserNotFound
+		}
+		return nil, errors.New(errors.ErrCodeDatabaseError, "查询用户失败", err)
+	}
+	if user.Status != 1 {
+		return nil, errors.ErrUserDisabled
+	}
+
+	if _, err := model.GetPendingUserDeletionRequest(db, userID); err == nil {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "已提交注销申请，请勿重复提交", nil)
+	} else if !stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "查询注销申请失败", err)
+	}
+
+	req := &model.UserDeletionRequest{
+		UserID:      userID,
+		Status:      model.DeletionStatusPending,
+		ScheduledAt: time.Now().Add(s.config.Privacy.GracePeriod),
+	}
+	if reason != "" {
+		reason = truncateRunes(reason, 255)
+		req.Reason = &reason
+	}
+	if err := model.CreateUserDeletionRequest(db, req); err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "提交注销申请失败", err)
+	}
+	logs.Business().Info("用户申请注销账号", zap.String("user_id", userID), zap.Time("scheduled_at", req.ScheduledAt))
+	return req, nil
+}
+
+// CancelDeletion 撤销冷静期内的注销申请
+func (s *PrivacyService) CancelDeletion(ctx context.Context, userID string) error {
+	db := s.db.WithContext(ctx)
+	req, err := model.GetPendingUserDeletionRequest(db, userID)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New(errors.ErrCodeNotFound, "没有待执行的注销申请", nil)
+		}
+		return errors.New(errors.ErrCodeDatabaseError, "查询注销申请失败", err)
+	}
+	ok, err := model.CancelUserDeletionRequest(db, req.ID)
+	if err != nil {
+		return errors.New(errors.ErrCodeDatabaseError, "撤销注销申请失败", err)
+	}
+	if !ok {
+		return errors.New(errors.ErrCodeInvalidParams, "注销申请已执行，无法撤销", nil)
+	}
+	logs.Business().Info("用户撤销注销申请", zap.String("user_id", userID), zap.Int64("request_id", req.ID))
+	return nil
+}
+
+// GetDeletion 获取用户最近一次注销申请，没有申请时返回 nil
+func (s *PrivacyService) GetDeletion(ctx context.Context, userID string) (*model.UserDeletionRequest, error) {
+	req, err := model.GetLatestUserDeletionRequest(s.db.WithContext(ctx), userID)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, errors.New(errors.ErrCodeDatabaseError, "查询注销申请失败", err)
+	}
+	return req, nil
+}
+
+// Start 启动注销执行协程，定期匿名化冷静期已结束的账号
+func (s *PrivacyService) Start(ctx context.Context) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(s.config.Privacy.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.processDueDeletions(ctx)
+			}
+		}
+	}()
+	return &wg
+}
+
+// processDueDeletions 加锁后执行到期的注销申请
+func (s *PrivacyService) processDueDeletions(ctx context.Context) {
+	if s.redis != nil {
+		locked, err := s.redis.SetNX(ctx, privacyDeletionLockKey, 1, privacyDeletionLockTTL).Result()
+		if err != nil || !locked {
+			return
+		}
+		defer s.redis.Del(context.Background(), privacyDeletionLockKey)
+	}
+
+	for ctx.Err() == nil {
+		reqs, err := model.ListDueUserDeletionRequests(s.db.WithContext(ctx), time.Now(), privacyDeletionBatch)
+		if err != nil {
+			logs.Business().Error("查询到期注销申请失败", zap.Error(err))
+			return
+		}
+		failed := false
+		for _, req := range reqs {
+			if err := s.anonymize(ctx, req); err != nil {
+				logs.Business().Error("执行账号注销失败", zap.Int64("request_id", req.ID), zap.String("user_id", req.UserID), zap.Error(err))
+				failed = true
+			}
+		}
+		// 有失败的申请时留到下一轮重试，避免反复领取同一批申请
+		if failed || len(reqs) < privacyDeletionBatch {
+			return
+		}
+	}
+}
+
+// anonymize 在同一事务中完成注销申请并匿名化用户个人信息，申请已被撤销时不做任何修改
+func (s *PrivacyService) anonymize(ctx context.Context, req *model.UserDeletionRequest) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ok, err := model.CompleteUserDeletionRequest(tx, req.ID)
+		if err != nil || !ok {
+			return err
+		}
+		if err := model.AnonymizeUser(tx, req.UserID); err != nil {
+			return err
+		}
+		logs.Business().Info("账号已注销", zap.Int64("request_id", req.ID), zap.String("user_id", req.UserID))
+		return nil
+	})
+}
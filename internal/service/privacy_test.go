@@ -0,0 +1,64 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"gorm.io/gorm"
+)
+
+func TestAnonymizeUser(t *testing.T) {
+	db := newDryRunDB(t)
+	statements := map[string]string{}
+	record := func(tx *gorm.DB) {
+		statements[tx.Statement.Table] = tx.Statement.SQL.String()
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("test:record_update", record); err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+	if err := db.Callback().Delete().After("gorm:delete").Register("test:record_delete", record); err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+
+	if err := model.AnonymizeUser(db, "u1"); err != nil {
+		t.Fatalf("anonymize: %v", err)
+	}
+
+	cases := []struct {
+		table string
+		want  []string
+	}{
+		{"users", []string{"UPDATE", "`phone`=", "`email`=", "`nickname`=", "`password_hash`=", "`phone_verified_at`=", "`status`="}},
+		{"user_login_log", []string{"UPDATE", "`ip_address`=", "`device_info`="}},
+		{"risk_events", []string{"UPDATE", "`ip`=", "`device_id`=", "user_id = ?"}},
+		{"user_auth", []string{"DELETE"}},
+		{"account_tokens", []string{"DELETE"}},
+		{"user_tag_members", []string{"DELETE"}},
+	}
+	for _, c := range cases {
+		sql, ok := statements[c.table]
+		if !ok {
+			t.Errorf("%s: not touched", c.table)
+			continue
+		}
+		for _, w := range c.want {
+			if !strings.Contains(sql, w) {
+				t.Errorf("%s: sql %q missing %q", c.table, sql, w)
+			}
+		}
+	}
+	// 财务记录必须保留
+	for _, table := range []string{"token_records", "orders", "recharge_orders"} {
+		if _, ok := statements[table]; ok {
+			t.Errorf("%s should be kept", table)
+		}
+	}
+}
+
+func TestPrivacyExportFileName(t *testing.T) {
+	name := PrivacyExportFileName("u1", PrivacyFormatZIP)
+	if !strings.HasPrefix(name, "personal-data-u1-") || !strings.HasSuffix(name, ".zip") {
+		t.Errorf("file name = %q", name)
+	}
+}
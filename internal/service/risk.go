@@ -0,0 +1,449 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"strconv"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/audit"
+	"github.com/reusedev/uportal-api/pkg/config"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/logs"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 风控规则命中时累加的风险分，总分封顶 100
+const (
+	riskScoreDeviceMissing      = 10  // 邀请上报未带设备指纹
+	riskScoreRewardNoDevice     = 30  // 注册赠送和奖励上报未带设备指纹，按默认阈值直接降额
+	riskScoreUserFlagged        = 50  // 发起人或受益人曾被审核拒绝
+	riskScoreSignupIPVelocity   = 40  // 同一 IP 短时间内大量注册
+	riskScoreSignupDeviceReuse  = 60  // 同一设备注册多个账号
+	riskScoreRewardUserVelocity = 40  // 同一用户短时间内频繁上报奖励
+	riskScoreRewardDailyLimit   = 100 // 同一用户当天上报奖励次数超限
+	riskScoreRewardIPUsers      = 30  // 同一 IP 上多个用户上报奖励
+	riskScoreRewardDeviceUsers  = 50  // 同一设备上多个用户上报奖励
+	riskScoreInviteVelocity     = 40  // 同一邀请人短时间内大量邀请
+	riskScoreInviteSharedDevice = 60  // 邀请人与被邀请人使用同一设备
+	riskScoreInviteSharedIP     = 30  // 邀请人近期使用过被邀请人的 IP
+	riskScoreMax                = 100
+)
+
+// riskSharedIPWindow 邀请双方共用 IP 的回溯时间
+const riskSharedIPWindow = 7 * 24 * time.Hour
+
+// RiskClient 发起行为的客户端信息，由处理器从请求中获取
+type RiskClient struct {
+	IP       string // 客户端IP
+	DeviceID string // 设备指纹，从 X-Device-ID 请求头获取
+}
+
+// RiskInput 风控评估的输入
+type RiskInput struct {
+	EventType     string     // 事件类型：signup/reward/invite
+	Action        string     // 具体行为，如奖励类型、登录渠道
+	UserID        string     // 发起行为的用户
+	RelatedUserID string     // 关联用户，邀请事件为邀请人
+	BeneficiaryID string     // 奖励受益人，为空时为发起人
+	Amount        int        // 原始奖励代币数
+	ChangeType    string     // 审核通过补发时代币记录的变动类型
+	Remark        string     // 审核通过补发时代币记录的备注
+	Client        RiskClient // 客户端信息
+}
+
+// RiskResult 风控评估结果
+type RiskResult struct {
+	EventID  int64    `json:"event_id"` // 风控事件ID
+	Score    int      `json:"score"`    // 风险分
+	Decision string   `json:"decision"` // 处置结果
+	Reasons  []string `json:"reasons"`  // 命中的规则
+	Amount   int      `json:"amount"`   // 实际发放的代币数，暂扣或拒绝时为 0
+}
+
+// Blocked 是否拒绝
+func (r *RiskResult) Blocked() bool {
+	return r.Decision == model.RiskDecisionBlock
+}
+
+// Held 奖励是否被暂扣等待审核
+func (r *RiskResult) Held() bool {
+	return r.Decision == model.RiskDecisionReview
+}
+
+// RiskService 风控服务，对注册赠送、奖励上报和邀请奖励打分并决定放行、降额、暂扣或拒绝
+type RiskService struct {
+	db     *gorm.DB
+	config *config.Config
+	roles  *RoleService
+}
+
+// NewRiskService 创建风控服务
+func NewRiskService(db *gorm.DB, cfg *config.Config, roles *RoleService) *RiskService {
+	return &RiskService{db: db, config: cfg, roles: roles}
+}
+
+// Assess 在业务事务中评估一次行为并记录风控事件，暂扣时同时创建审核；
+// 服务未启用时直接放行。规则查询失败时跳过该规则，记录事件失败时返回错误以便业务回滚
+func (s *RiskService) Assess(ctx context.Context, tx *gorm.DB, in *RiskInput) (*RiskResult, error) {
+	if s == nil {
+		return &RiskResult{Decision: model.RiskDecisionAllow, Amount: in.Amount}, nil
+	}
+	tx = tx.WithContext(ctx)
+
+	score, reasons := 0, []string{}
+	hit := func(reason string, points int) {
+		score += points
+		reasons = append(reasons, reason)
+	}
+
+	// 注册和奖励上报的设备规则都依赖设备指纹，不上报即可绕过，缺失时分值更高
+	if in.Client.DeviceID == "" {
+		if in.EventType == model.RiskEventSignup || in.EventType == model.RiskEventReward {
+			hit("device_missing", riskScoreRewardNoDevice)
+		} else {
+			hit("device_missing", riskScoreDeviceMissing)
+		}
+	}
+	switch in.EventType {
+	case model.RiskEventSignup:
+		s.scoreSignup(tx, in, hit)
+	case model.RiskEventReward:
+		s.scoreReward(tx, in, hit)
+	case model.RiskEventInvite:
+		s.scoreInvite(tx, in, hit)
+	}
+	// 新注册用户没有历史，其余事件检查发起人和关联用户是否曾被审核拒绝
+	if in.EventType != model.RiskEventSignup {
+		for _, userID := range []string{in.UserID, in.RelatedUserID} {
+			if userID != "" && s.reached(model.CountRejectedRiskReviews(tx, userID))(1) {
+				hit("user_flagged", riskScoreUserFlagged)
+				break
+			}
+		}
+	}
+	if score > riskScoreMax {
+		score = riskScoreMax
+	}
+
+	result := &RiskResult{Score: score, Reasons: reasons}
+	result.Decision, result.Amount = s.decide(score, in.Amount)
+
+	event := &model.RiskEvent{
+		EventType:     in.EventType,
+		Action:        truncateRunes(in.Action, 32),
+		UserID:        in.UserID,
+		IP:            in.Client.IP,
+		DeviceID:      truncateRunes(in.Client.DeviceID, 64),
+		Score:         score,
+		Decision:      result.Decision,
+		Reasons:       reasons,
+		Amount:        in.Amount,
+		GrantedAmount: result.Amount,
+	}
+	if in.RelatedUserID != "" {
+		event.RelatedUserID = &in.RelatedUserID
+	}
+	if err := model.CreateRiskEvent(tx, event); err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "记录风控事件失败", err)
+	}
+	result.EventID = event.ID
+
+	if result.Held() && in.Amount > 0 {
+		beneficiary := in.BeneficiaryID
+		if beneficiary == "" {
+			beneficiary = in.UserID
+		}
+		review := &model.RiskReview{
+			EventID:       event.ID,
+			EventType:     in.EventType,
+			UserID:        in.UserID,
+			BeneficiaryID: beneficiary,
+			Amount:        in.Amount,
+			ChangeType:    in.ChangeType,
+			Remark:        truncateRunes(in.Remark, 255),
+			Status:        model.RiskReviewPending,
+		}
+		if err := model.CreateRiskReview(tx, review); err != nil {
+			return nil, errors.New(errors.ErrCodeDatabaseError, "创建风控审核失败", err)
+		}
+	}
+
+	if result.Decision != model.RiskDecisionAllow {
+		logs.Business().Warn("风控命中",
+			zap.String("event_type", in.EventType),
+			zap.String("user_id", in.UserID),
+			zap.String("ip", in.Client.IP),
+			zap.String("device_id", in.Client.DeviceID),
+			zap.Int("score", score),
+			zap.String("decision", result.Decision),
+			zap.Strings("reasons", reasons),
+		)
+	}
+	return result, nil
+}
+
+// decide 按风险分决定处置结果和实际发放的代币数
+func (s *RiskService) decide(score, amount int) (string, int) {
+	cfg := s.config.Risk
+	switch {
+	case score >= cfg.BlockScore:
+		return model.RiskDecisionBlock, 0
+	case score >= cfg.ReviewScore:
+		return model.RiskDecisionReview, 0
+	case score >= cfg.ReduceScore:
+		return model.RiskDecisionReduce, int(float64(amount) * cfg.ReduceRatio)
+	}
+	return model.RiskDecisionAllow, amount
+}
+
+// scoreSignup 注册赠送：同一 IP 的注册频率、同一设备注册的账号数
+func (s *RiskService) scoreSignup(tx *gorm.DB, in *RiskInput, hit func(string, int)) {
+	cfg := s.config.Risk
+	since := time.Now().Add(-cfg.Window)
+	if in.Client.IP != "" && s.reached(model.CountRiskEvents(tx, model.RiskEventSignup, "ip", in.Client.IP, since))(cfg.SignupPerIP) {
+		hit("signup_ip_velocity", riskScoreSignupIPVelocity)
+	}
+	if in.Client.DeviceID != "" && s.reached(model.CountRiskEventUsers(tx, model.RiskEventSignup, "device_id", in.Client.DeviceID, time.Time{}))(cfg.SignupPerDevice) {
+		hit("signup_device_reuse", riskScoreSignupDeviceReuse)
+	}
+}
+
+// scoreReward 奖励上报：用户上报频率和当天次数、同一 IP 或设备上的用户数
+func (s *RiskService) scoreReward(tx *gorm.DB, in *RiskInput, hit func(string, int)) {
+	cfg := s.config.Risk
+	since := time.Now().Add(-cfg.Window)
+	if s.reached(model.CountRiskEvents(tx, model.RiskEventReward, "user_id", in.UserID, since))(cfg.RewardPerUser) {
+		hit("reward_user_velocity", riskScoreRewardUserVelocity)
+	}
+	// 当天按业务时区计算，与任务、签到的日界一致
+	dayStart, _ := dayRange(time.Now(), ResolveLocation(s.config.Locale.Timezone, time.Local))
+	if s.reached(model.CountRiskEvents(tx, model.RiskEventReward, "user_id", in.UserID, dayStart))(cfg.RewardDailyPerUser) {
+		hit("reward_daily_limit", riskScoreRewardDailyLimit)
+	}
+	// 统计的是此前的用户数，当前用户尚未记录时需要加上自己
+	if in.Client.IP != "" && s.reachedOthers(tx, "ip", in.Client.IP, in.UserID, since, cfg.RewardUsersPerIP) {
+		hit("reward_ip_users", riskScoreRewardIPUsers)
+	}
+	if in.Client.DeviceID != "" && s.reachedOthers(tx, "device_id", in.Client.DeviceID, in.UserID, since, cfg.RewardUsersPerDevice) {
+		hit("reward_device_users", riskScoreRewardDeviceUsers)
+	}
+}
+
+// scoreInvite 邀请奖励：邀请人的邀请频率，邀请双方共用设备或 IP
+func (s *RiskService) scoreInvite(tx *gorm.DB, in *RiskInput, hit func(string, int)) {
+	cfg := s.config.Risk
+	since := time.Now().Add(-cfg.Window)
+	if in.RelatedUserID == "" {
+		return
+	}
+	if s.reached(model.CountRiskEvents(tx, model.RiskEventInvite, "related_user_id", in.RelatedUserID, since))(cfg.InvitePerInviter) {
+		hit("invite_velocity", riskScoreInviteVelocity)
+	}
+	if in.Client.DeviceID != "" {
+		used, err := model.UserUsedDevice(tx, in.RelatedUserID, in.Client.DeviceID)
+		if err != nil {
+			logs.Business().Warn("风控规则查询失败", zap.String("rule", "invite_shared_device"), zap.Error(err))
+		} else if used {
+			hit("invite_shared_device", riskScoreInviteSharedDevice)
+		}
+	}
+	if in.Client.IP != "" {
+		used, err := model.UserUsedIP(tx, in.RelatedUserID, in.Client.IP, time.Now().Add(-riskSharedIPWindow))
+		if err != nil {
+			logs.Business().Warn("风控规则查询失败", zap.String("rule", "invite_shared_ip"), zap.Error(err))
+		} else if used {
+			hit("invite_shared_ip", riskScoreInviteSharedIP)
+		}
+	}
+}
+
+// reachedOthers 窗口内同一 IP 或设备上上报奖励的用户数（含当前用户）是否达到阈值
+func (s *RiskService) reachedOthers(tx *gorm.DB, column, value, userID string, since time.Time, threshold int) bool {
+	count, err := model.CountRiskEventUsers(tx, model.RiskEventReward, column, value, since)
+	if err != nil {
+		logs.Business().Warn("风控规则查询失败", zap.String("column", column), zap.Error(err))
+		return false
+	}
+	used, err := model.CountRiskEvents(tx, model.RiskEventReward, "user_id", userID, since)
+	if err == nil && used == 0 {
+		count++
+	}
+	return threshold > 0 && count >= int64(threshold)
+}
+
+// reached 返回计数是否达到阈值的判断函数，阈值不大于 0 表示规则关闭，查询失败时视为未命中
+func (s *RiskService) reached(count int64, err error) func(threshold int) bool {
+	return func(threshold int) bool {
+		if err != nil {
+			logs.Business().Warn("风控规则查询失败", zap.Error(err))
+			return false
+		}
+		return threshold > 0 && count >= int64(threshold)
+	}
+}
+
+// ListEvents 分页查询风控事件
+func (s *RiskService) ListEvents(ctx context.Context, filter *model.RiskEventFilter, page, limit int) ([]*model.RiskEvent, int64, error) {
+	list, total, err := model.ListRiskEvents(s.db.WithContext(ctx), filter, page, limit)
+	if err != nil {
+		return nil, 0, errors.New(errors.ErrCodeDatabaseError, "获取风控事件失败", err)
+	}
+	return list, total, nil
+}
+
+// ListReviews 分页查询风控审核
+func (s *RiskService) ListReviews(ctx context.Context, status, eventType, userID string, page, limit int) ([]*model.RiskReview, int64, error) {
+	list, total, err := model.ListRiskReviews(s.db.WithContext(ctx), status, eventType, userID, page, limit)
+	if err != nil {
+		return nil, 0, errors.New(errors.ErrCodeDatabaseError, "获取风控审核失败", err)
+	}
+	return list, total, nil
+}
+
+// GetReview 获取风控审核详情
+func (s *RiskService) GetReview(ctx context.Context, id int64) (*model.RiskReview, error) {
+	review, err := model.GetRiskReview(s.db.WithContext(ctx), id)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New(errors.ErrCodeNotFound, "风控审核不存在", nil)
+		}
+		return nil, errors.New(errors.ErrCodeDatabaseError, "查询风控审核失败", err)
+	}
+	return review, nil
+}
+
+// ApproveReview 审核通过，向受益人补发暂扣的代币；邀请事件同时将邀请记录标记为已发放，
+// 邀请活动的记录转为待发放，已达成触发条件的按活动结算
+func (s *RiskService) ApproveReview(ctx context.Context, adminID, id int64, note string) (*model.RiskReview, error) {
+	before, err := s.GetReview(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if before.Status != model.RiskReviewPending {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "该审核已处理", nil)
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 邀请活动的奖励在被邀请人达成触发条件时结算，审核通过只解除暂扣
+		if before.EventType == model.RiskEventInvite {
+			released, err := releaseHeldInvite(tx, before.UserID, before.BeneficiaryID, inviteLocation(s.config))
+			if err != nil {
+				return err
+			}
+			if released {
+				return s.finishReview(tx, adminID, id, model.RiskReviewApproved, note, nil)
+			}
+		}
+
+		var user model.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", before.BeneficiaryID).First(&user).Error; err != nil {
+			if stderrors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New(errors.ErrCodeUserNotFound, "受益人不存在", nil)
+			}
+			return errors.New(errors.ErrCodeDatabaseError, "获取受益人信息失败", err)
+		}
+		if user.Status != 1 {
+			return errors.New(errors.ErrCodeUserDisabled, "受益人账号已被禁用", nil)
+		}
+
+		if err := model.UpdateUserTokenBalance(tx, user.UserID, before.Amount); err != nil {
+			return errors.New(errors.ErrCodeDatabaseError, "更新代币余额失败", err)
+		}
+		record := &model.TokenRecord{
+			UserID:       user.UserID,
+			ChangeAmount: before.Amount,
+			BalanceAfter: user.TokenBalance + before.Amount,
+			ChangeType:   before.ChangeType,
+			Remark:       model.StringPtr(truncateRunes(before.Remark+"（风控审核通过补发）", 255)),
+			ChangeTime:   time.Now(),
+		}
+		if err := model.CreateTokenRecord(tx, record); err != nil {
+			return errors.New(errors.ErrCodeDatabaseError, "创建代币记录失败", err)
+		}
+
+		if before.EventType == model.RiskEventInvite {
+			if err := tx.Model(&model.InviteRecord{}).
+				Where("invitee_id = ? AND inviter_id = ? AND status = 0", before.UserID, before.BeneficiaryID).
+				Update("status", 1).Error; err != nil {
+				return errors.New(errors.ErrCodeDatabaseError, "更新邀请记录状态失败", err)
+			}
+		}
+
+		return s.finishReview(tx, adminID, id, model.RiskReviewApproved, note, &record.RecordID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	after, _ := model.GetRiskReview(s.db.WithContext(ctx), id)
+	audit.Record(ctx, "risk_review", strconv.FormatInt(id, 10), before, after)
+	return after, nil
+}
+
+// RejectReview 审核拒绝，暂扣的代币不再发放；邀请事件同时将邀请记录标记为已拒绝。
+// disableUsers 为 true 时禁用发起人（邀请事件还包括邀请人），需具备修改用户状态的权限
+func (s *RiskService) RejectReview(ctx context.Context, adminID, id int64, note string, disableUsers bool) (*model.RiskReview, error) {
+	before, err := s.GetReview(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if before.Status != model.RiskReviewPending {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "该审核已处理", nil)
+	}
+	if disableUsers {
+		if err := s.roles.CheckPermission(ctx, adminID, model.PermUsersWrite); err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if before.EventType == model.RiskEventInvite {
+			if err := tx.Model(&model.InviteRecord{}).
+				Where("invitee_id = ? AND inviter_id = ? AND status IN ?", before.UserID, before.BeneficiaryID,
+					[]int8{model.InviteStatusPending, model.InviteStatusHeld}).
+				Update("status", model.InviteStatusRejected).Error; err != nil {
+				return errors.New(errors.ErrCodeDatabaseError, "更新邀请记录状态失败", err)
+			}
+		}
+		if disableUsers {
+			userIDs := dedupeUserIDs([]string{before.UserID, before.BeneficiaryID})
+			if err := tx.Model(&model.User{}).Where("id IN ?", userIDs).
+				Updates(map[string]interface{}{"status": 0, "updated_at": time.Now()}).Error; err != nil {
+				return errors.New(errors.ErrCodeDatabaseError, "禁用用户失败", err)
+			}
+		}
+		return s.finishReview(tx, adminID, id, model.RiskReviewRejected, note, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	after, _ := model.GetRiskReview(s.db.WithContext(ctx), id)
+	audit.Record(ctx, "risk_review", strconv.FormatInt(id, 10), before, after)
+	return after, nil
+}
+
+// finishReview 完成审核，已被其他管理员处理时返回错误以回滚事务
+func (s *RiskService) finishReview(tx *gorm.DB, adminID, id int64, status, note string, recordID *int64) error {
+	updates := map[string]interface{}{
+		"status":      status,
+		"reviewer_id": adminID,
+		"reviewed_at": time.Now(),
+		"record_id":   recordID,
+	}
+	if note != "" {
+		updates["review_note"] = note
+	}
+	ok, err := model.FinishRiskReview(tx, id, updates)
+	if err != nil {
+		return errors.New(errors.ErrCodeDatabaseError, "更新风控审核失败", err)
+	}
+	if !ok {
+		return errors.New(errors.ErrCodeInvalidParams, "该审核已处理", nil)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/config"
+	"github.com/reusedev/uportal-api/pkg/logs"
+	"go.uber.org/zap"
+)
+
+func newTestRiskService(t *testing.T) *RiskService {
+	t.Helper()
+	if logs.BusinessLogger == nil {
+		logs.BusinessLogger = zap.NewNop()
+	}
+	cfg := &config.Config{}
+	cfg.Risk.ReduceScore = 30
+	cfg.Risk.ReviewScore = 60
+	cfg.Risk.BlockScore = 90
+	cfg.Risk.ReduceRatio = 0.5
+	cfg.Risk.SignupPerIP = 5
+	cfg.Risk.SignupPerDevice = 3
+	return NewRiskService(newDryRunDB(t), cfg, nil)
+}
+
+func TestRiskDecide(t *testing.T) {
+	s := newTestRiskService(t)
+	cases := []struct {
+		score    int
+		decision string
+		amount   int
+	}{
+		{0, model.RiskDecisionAllow, 100},
+		{29, model.RiskDecisionAllow, 100},
+		{30, model.RiskDecisionReduce, 50},
+		{59, model.RiskDecisionReduce, 50},
+		{60, model.RiskDecisionReview, 0},
+		{89, model.RiskDecisionReview, 0},
+		{90, model.RiskDecisionBlock, 0},
+		{riskScoreMax, model.RiskDecisionBlock, 0},
+	}
+	for _, c := range cases {
+		decision, amount := s.decide(c.score, 100)
+		if decision != c.decision || amount != c.amount {
+			t.Errorf("decide(%d) = %s/%d, want %s/%d", c.score, decision, amount, c.decision, c.amount)
+		}
+	}
+}
+
+func TestRiskReached(t *testing.T) {
+	s := newTestRiskService(t)
+	cases := []struct {
+		name      string
+		count     int64
+		err       error
+		threshold int
+		want      bool
+	}{
+		{"below threshold", 2, nil, 3, false},
+		{"at threshold", 3, nil, 3, true},
+		{"above threshold", 4, nil, 3, true},
+		{"rule disabled", 100, nil, 0, false},
+		{"query failed", 100, stderrors.New("db down"), 3, false},
+	}
+	for _, c := range cases {
+		if got := s.reached(c.count, c.err)(c.threshold); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestRiskAssess(t *testing.T) {
+	var nilService *RiskService
+	result, err := nilService.Assess(context.Background(), nil, &RiskInput{EventType: model.RiskEventSignup, Amount: 100})
+	if err != nil || result.Decision != model.RiskDecisionAllow || result.Amount != 100 {
+		t.Errorf("disabled service = %+v, %v; want allow with full amount", result, err)
+	}
+
+	s := newTestRiskService(t)
+	cases := []struct {
+		name     string
+		event    string
+		client   RiskClient
+		score    int
+		reasons  []string
+		decision string
+		amount   int
+	}{
+		{"clean signup", model.RiskEventSignup, RiskClient{IP: "10.0.0.1", DeviceID: "d1"}, 0, nil, model.RiskDecisionAllow, 100},
+		{"signup without device", model.RiskEventSignup, RiskClient{IP: "10.0.0.1"}, riskScoreRewardNoDevice, []string{"device_missing"}, model.RiskDecisionReduce, 50},
+		{"invite without device", model.RiskEventInvite, RiskClient{IP: "10.0.0.1"}, riskScoreDeviceMissing, []string{"device_missing"}, model.RiskDecisionAllow, 100},
+	}
+	for _, c := range cases {
+		in := &RiskInput{EventType: c.event, UserID: "u1", Amount: 100, Client: c.client}
+		result, err := s.Assess(context.Background(), s.db, in)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if result.Score != c.score || len(result.Reasons) != len(c.reasons) {
+			t.Errorf("%s: score %d reasons %v, want %d %v", c.name, result.Score, result.Reasons, c.score, c.reasons)
+			continue
+		}
+		for i := range c.reasons {
+			if result.Reasons[i] != c.reasons[i] {
+				t.Errorf("%s: reasons %v, want %v", c.name, result.Reasons, c.reasons)
+			}
+		}
+		if result.Decision != c.decision || result.Amount != c.amount {
+			t.Errorf("%s: decision %s/%d, want %s/%d", c.name, result.Decision, result.Amount, c.decision, c.amount)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/audit"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// roleCodePattern 角色编码只允许小写字母、数字和下划线
+var roleCodePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,19}$`)
+
+// rolePermissionCacheTTL 角色权限本地缓存时间，多实例部署时角色变更最迟在该时间后生效
+const rolePermissionCacheTTL = 30 * time.Second
+
+// RoleService 角色与权限服务
+type RoleService struct {
+	db    *gorm.DB
+	mu    sync.RWMutex
+	cache map[string]cachedPermissions
+}
+
+type cachedPermissions struct {
+	perms     []string
+	expiresAt time.Time
+}
+
+// NewRoleService 创建角色服务
+func NewRoleService(db *gorm.DB) *RoleService {
+	return &RoleService{
+		db:    db,
+		cache: make(map[string]cachedPermissions),
+	}
+}
+
+// CreateRoleRequest 创建角色请求
+type CreateRoleRequest struct {
+	Code        string
+	Name        string
+	Description *string
+	Permissions []string
+}
+
+// UpdateRoleRequest 更新角色请求
+type UpdateRoleRequest struct {
+	Name        string
+	Description *string
+	Permissions []string // 为 nil 时不修改权限
+}
+
+// ListPermissions 获取可分配的权限列表
+func (s *RoleService) ListPermissions() []model.PermissionInfo {
+	return model.Permissions
+}
+
+// ListRoles 获取角色列表
+func (s *RoleService) ListRoles(ctx context.Context) ([]*model.AdminRole, error) {
+	roles, err := model.ListRoles(s.db)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "获取角色列表失败", err)
+	}
+	return roles, nil
+}
+
+// GetRole 获取角色详情
+func (s *RoleService) GetRole(ctx context.Context, id int64) (*model.AdminRole, error) {
+	role, err := model.GetRoleByID(s.db, id)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New(errors.ErrCodeNotFound, "角色不存在", nil)
+		}
+		return nil, errors.New(errors.ErrCodeInternal, "查询角色失败", err)
+	}
+	return role, nil
+}
+
+// CreateRole 创建角色，操作人只能授予自己拥有的权限
+func (s *RoleService) CreateRole(ctx context.Context, operatorID int64, req *CreateRoleRequest) (*model.AdminRole, error) {
+	if !roleCodePattern.MatchString(req.Code) {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "角色编码只能包含小写字母、数字和下划线，且以字母开头", nil)
+	}
+	perms, err := normalizePermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkGrantable(ctx, operatorID, perms); err != nil {
+		return nil, err
+	}
+
+	if _, err := model.GetRoleByCode(s.db, req.Code); err == nil {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "角色编码已存在", nil)
+	} else if !stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New(errors.ErrCodeInternal, "查询角色失败", err)
+	}
+
+	role := &model.AdminRole{
+		Code:        req.Code,
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: perms,
+	}
+	if err := model.CreateRole(s.db, role); err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "创建角色失败", err)
+	}
+	audit.Record(ctx, "role", role.Code, nil, role)
+	return role, nil
+}
+
+// UpdateRole 更新角色，超级管理员角色的权限不可修改
+func (s *RoleService) UpdateRole(ctx context.Context, operatorID, id int64, req *UpdateRoleRequest) error {
+	role, err := s.GetRole(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	var perms []string
+	if req.Permissions != nil {
+		if role.Code == model.RoleSuperAdmin {
+			return errors.New(errors.ErrCodeForbidden, "不能修改超级管理员角色的权限", nil)
+		}
+		if perms, err = normalizePermissions(req.Permissions); err != nil {
+			return err
+		}
+		// 修改前后的权限都必须在操作人的权限范围内，防止借助角色编辑提权或削弱更高权限的角色
+		if err := s.checkGrantable(ctx, operatorID, append(perms, role.Permissions...)); err != nil {
+			return err
+		}
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != "" {
+		updates["name"] = req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if err := model.UpdateRole(s.db, id, updates, perms); err != nil {
+		return errors.New(errors.ErrCodeInternal, "更新角色失败", err)
+	}
+	s.invalidate(role.Code)
+	after, _ := model.GetRoleByID(s.db, id)
+	audit.Record(ctx, "role", role.Code, role, after)
+	return nil
+}
+
+// DeleteRole 删除角色，内置角色和仍有管理员使用的角色不可删除
+func (s *RoleService) DeleteRole(ctx context.Context, operatorID, id int64) error {
+	role, err := s.GetRole(ctx, id)
+	if err != nil {
+		return err
+	}
+	if role.IsSystem {
+		return errors.New(errors.ErrCodeForbidden, "内置角色不能删除", nil)
+	}
+	if err := s.checkGrantable(ctx, operatorID, role.Permissions); err != nil {
+		return err
+	}
+
+	count, err := model.CountAdminsByRole(s.db, role.Code)
+	if err != nil {
+		return errors.New(errors.ErrCodeInternal, "查询角色使用情况失败", err)
+	}
+	if count > 0 {
+		return errors.New(errors.ErrCodeInvalidParams, "仍有管理员使用该角色，不能删除", nil)
+	}
+
+	if err := model.DeleteRole(s.db, id); err != nil {
+		return errors.New(errors.ErrCodeInternal, "删除角色失败", err)
+	}
+	s.invalidate(role.Code)
+	audit.Record(ctx, "role", role.Code, role, nil)
+	return nil
+}
+
+// CheckPermission 校验管理员当前是否拥有指定权限，供权限中间件使用；
+// 每次按数据库中的角色和状态判断，角色调整或停用无需等待令牌过期
+func (s *RoleService) CheckPermission(ctx context.Context, adminID int64, permission string) error {
+	perms, err := s.adminPermissions(adminID)
+	if err != nil {
+		return err
+	}
+	if !hasPermission(perms, permission) {
+		return errors.New(errors.ErrCodeForbidden, "没有访问权限", nil)
+	}
+	return nil
+}
+
+// RolePermissions 获取角色的权限列表，带本地缓存
+func (s *RoleService) RolePermissions(code string) ([]string, error) {
+	s.mu.RLock()
+	entry, ok := s.cache[code]
+	s.mu.RUnlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.perms, nil
+	}
+
+	role, err := model.GetRoleByCode(s.db, code)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			// 角色不存在视为没有任何权限
+			role = &model.AdminRole{Permissions: []string{}}
+		} else {
+			return nil, errors.New(errors.ErrCodeInternal, "查询角色权限失败", err)
+		}
+	}
+
+	s.mu.Lock()
+	s.cache[code] = cachedPermissions{perms: role.Permissions, expiresAt: time.Now().Add(rolePermissionCacheTTL)}
+	s.mu.Unlock()
+	return role.Permissions, nil
+}
+
+// CanAssignRole 校验操作人能否把某角色分配给他人（或管理拥有该角色的管理员）
+func (s *RoleService) CanAssignRole(ctx context.Context, operatorID int64, code string) error {
+	role, err := model.GetRoleByCode(s.db, code)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New(errors.ErrCodeInvalidParams, "角色不存在", nil)
+		}
+		return errors.New(errors.ErrCodeInternal, "查询角色失败", err)
+	}
+	return s.checkGrantable(ctx, operatorID, role.Permissions)
+}
+
+// adminPermissions 查询管理员当前权限，停用的管理员没有任何权限
+func (s *RoleService) adminPermissions(adminID int64) ([]string, error) {
+	admin, err := model.GetAdinUserByID(s.db, adminID)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New(errors.ErrCodeUnauthorized, "管理员不存在", nil)
+		}
+		return nil, errors.New(errors.ErrCodeInternal, "查询管理员失败", err)
+	}
+	if admin.Status != 1 {
+		return nil, errors.New(errors.ErrCodeForbidden, "账号已被禁用", nil)
+	}
+	return s.RolePermissions(admin.Role)
+}
+
+// checkGrantable 操作人必须拥有 perms 中的全部权限
+func (s *RoleService) checkGrantable(ctx context.Context, operatorID int64, perms []string) error {
+	own, err := s.adminPermissions(operatorID)
+	if err != nil {
+		return err
+	}
+	if p, ok := exceededPermission(own, perms); ok {
+		return errors.New(errors.ErrCodeForbidden, "不能授予或管理超出自身权限的角色："+p, nil)
+	}
+	return nil
+}
+
+// exceededPermission 返回 perms 中超出 own 的第一个权限
+func exceededPermission(own, perms []string) (string, bool) {
+	for _, p := range perms {
+		if !hasPermission(own, p) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+func (s *RoleService) invalidate(code string) {
+	s.mu.Lock()
+	delete(s.cache, code)
+	s.mu.Unlock()
+}
+
+// hasPermission 判断权限集合是否包含指定权限，* 表示全部权限
+func hasPermission(perms []string, permission string) bool {
+	for _, p := range perms {
+		if p == model.PermissionAll || p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizePermissions 校验权限标识并去重排序
+func normalizePermissions(perms []string) ([]string, error) {
+	seen := make(map[string]struct{}, len(perms))
+	result := make([]string, 0, len(perms))
+	for _, p := range perms {
+		p = strings.TrimSpace(p)
+		if !model.IsValidPermission(p) {
+			return nil, errors.New(errors.ErrCodeInvalidParams, "未知的权限："+p, nil)
+		}
+		if _, ok := seen[p]; ok {
+			continue
+		}
+		seen[p] = struct{}{}
+		result = append(result, p)
+	}
+	sort.Strings(result)
+	return result, nil
+}
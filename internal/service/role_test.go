@@ -0,0 +1,77 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/reusedev/uportal-api/internal/model"
+)
+
+func TestExceededPermission(t *testing.T) {
+	operator := []string{model.PermUsersRead, model.PermUsersWrite, model.PermAdminsWrite}
+
+	cases := []struct {
+		name  string
+		own   []string
+		perms []string
+		want  string
+		over  bool
+	}{
+		{"subset of own permissions", operator, []string{model.PermUsersRead}, "", false},
+		{"same permissions", operator, operator, "", false},
+		{"empty role", operator, nil, "", false},
+		{"one permission beyond own", operator, []string{model.PermUsersRead, model.PermTokensAdjust}, model.PermTokensAdjust, true},
+		{"cannot grant all permissions without all", operator, []string{model.PermissionAll}, model.PermissionAll, true},
+		{"all permissions grant anything", []string{model.PermissionAll}, []string{model.PermRolesWrite, model.PermissionAll}, "", false},
+		{"no permissions", nil, []string{model.PermUsersRead}, model.PermUsersRead, true},
+	}
+	for _, c := range cases {
+		got, over := exceededPermission(c.own, c.perms)
+		if got != c.want || over != c.over {
+			t.Errorf("%s: got (%q, %v), want (%q, %v)", c.name, got, over, c.want, c.over)
+		}
+	}
+}
+
+func TestNormalizePermissions(t *testing.T) {
+	cases := []struct {
+		name  string
+		perms []string
+		want  []string
+		bad   bool
+	}{
+		{"dedupes and sorts", []string{" users:write", "users:read", "users:write"}, []string{"users:read", "users:write"}, false},
+		{"all permissions", []string{"*"}, []string{"*"}, false},
+		{"empty", nil, []string{}, false},
+		{"unknown permission", []string{"users:read", "users:delete"}, nil, true},
+	}
+	for _, c := range cases {
+		got, err := normalizePermissions(c.perms)
+		if c.bad {
+			if err == nil {
+				t.Errorf("%s: expected error", c.name)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, %v, want %v", c.name, got, err, c.want)
+		}
+	}
+}
+
+func TestRoleCodePattern(t *testing.T) {
+	cases := map[string]bool{
+		"operator":              true,
+		"risk_reviewer2":        true,
+		"a":                     false,
+		"Operator":              false,
+		"2fa_admin":             false,
+		"ops-team":              false,
+		"a_very_long_role_code": false,
+	}
+	for code, want := range cases {
+		if got := roleCodePattern.MatchString(code); got != want {
+			t.Errorf("roleCodePattern(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/audit"
 	"github.com/reusedev/uportal-api/pkg/errors"
 	"gorm.io/gorm"
 )
@@ -43,6 +44,7 @@ func (s *SystemConfigService) CreateConfig(ctx context.Context, req *CreateConfi
 	if err := s.db.Create(config).Error; err != nil {
 		return errors.New(errors.ErrCodeDatabaseError, "创建系统配置失败", err)
 	}
+	audit.Record(ctx, "config", config.ConfigKey, nil, config)
 	return nil
 }
 
@@ -55,6 +57,7 @@ type UpdateConfigRequest struct {
 
 // UpdateConfig 更新系统配置
 func (s *SystemConfigService) UpdateConfig(ctx context.Context, req *UpdateConfigRequest) error {
+	before, _ := s.getConfig(req.ConfigKey)
 	result := s.db.Model(&model.SystemConfig{}).
 		Where("config_key = ?", req.ConfigKey).
 		Updates(map[string]interface{}{
@@ -65,6 +68,8 @@ func (s *SystemConfigService) UpdateConfig(ctx context.Context, req *UpdateConfi
 	if result.Error != nil {
 		return errors.New(errors.ErrCodeDatabaseError, "更新系统配置失败", result.Error)
 	}
+	after, _ := s.getConfig(req.ConfigKey)
+	audit.Record(ctx, "config", req.ConfigKey, before, after)
 	return nil
 }
 
@@ -75,6 +80,7 @@ type DeleteConfigRequest struct {
 
 // DeleteConfig 删除系统配置
 func (s *SystemConfigService) DeleteConfig(ctx context.Context, req *DeleteConfigRequest) error {
+	before, _ := s.getConfig(req.ConfigKey)
 	result := s.db.Where("config_key = ?", req.ConfigKey).Delete(&model.SystemConfig{})
 	if result.Error != nil {
 		return errors.New(errors.ErrCodeDatabaseError, "删除系统配置失败", result.Error)
@@ -82,5 +88,15 @@ func (s *SystemConfigService) DeleteConfig(ctx context.Context, req *DeleteConfi
 	if result.RowsAffected == 0 {
 		return errors.New(errors.ErrCodeNotFound, "系统配置不存在", nil)
 	}
+	audit.Record(ctx, "config", req.ConfigKey, before, nil)
 	return nil
 }
+
+// getConfig 按键读取配置，用于审计记录变更前后的数据
+func (s *SystemConfigService) getConfig(key string) (*model.SystemConfig, error) {
+	var config model.SystemConfig
+	if err := s.db.Where("config_key = ?", key).First(&config).Error; err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
@@ -2,12 +2,15 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	stderrors "errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/audit"
 	"github.com/reusedev/uportal-api/pkg/config"
 	"github.com/reusedev/uportal-api/pkg/errors"
 	"go.uber.org/zap"
@@ -17,20 +20,31 @@ import (
 
 // TaskService 任务服务
 type TaskService struct {
-	db     *gorm.DB
-	redis  *redis.Client
-	logger *zap.Logger
-	config *config.Config
+	db        *gorm.DB
+	redis     *redis.Client
+	logger    *zap.Logger
+	config    *config.Config
+	notifier  *NotificationService
+	risk      *RiskService
+	verifiers *TaskVerifierRegistry
+	// achievements 成就服务，为 nil 时不统计成就进度
+	achievements *AchievementService
 }
 
 // NewTaskService 创建任务服务
-func NewTaskService(db *gorm.DB, redis *redis.Client, logger *zap.Logger, config *config.Config) *TaskService {
-	return &TaskService{
-		db:     db,
-		redis:  redis,
-		logger: logger,
-		config: config,
-	}
+func NewTaskService(db *gorm.DB, redis *redis.Client, logger *zap.Logger, config *config.Config, notifier *NotificationService, risk *RiskService, achievements *AchievementService) *TaskService {
+	s := &TaskService{
+		db:           db,
+		redis:        redis,
+		logger:       logger,
+		config:       config,
+		notifier:     notifier,
+		risk:         risk,
+		verifiers:    NewTaskVerifierRegistry(),
+		achievements: achievements,
+	}
+	s.registerBuiltinVerifiers()
+	return s
 }
 
 type ListTaskRequest struct {
@@ -42,16 +56,19 @@ type ListTaskRequest struct {
 
 // CreateTaskRequest 创建任务请求
 type CreateTaskRequest struct {
-	TaskName        string `json:"task_name" binding:"required"`
-	Description     string `json:"task_desc" binding:"required"`
-	TokenReward     int    `json:"token_reward" binding:"required"`
-	DailyLimit      int    `json:"daily_limit" binding:"required"`
-	IntervalSeconds *int   `json:"interval_seconds" binding:"required"`
-	ValidFrom       string `json:"valid_from" binding:"required"`
-	ValidTo         string `json:"valid_to"`
-	Repeatable      *int8  `json:"repeatable" binding:"required"`
-	Status          *int8  `json:"status" binding:"required"`
-	TaskKey         string `json:"task_key" binding:"required"`
+	TaskName        string                 `json:"task_name" binding:"required"`
+	Description     string                 `json:"task_desc" binding:"required"`
+	TokenReward     int                    `json:"token_reward" binding:"required"`
+	DailyLimit      int                    `json:"daily_limit" binding:"required"`
+	IntervalSeconds *int                   `json:"interval_seconds" binding:"required"`
+	ValidFrom       string                 `json:"valid_from" binding:"required"`
+	ValidTo         string                 `json:"valid_to"`
+	Repeatable      *int8                  `json:"repeatable" binding:"required"`
+	Status          *int8                  `json:"status" binding:"required"`
+	TaskKey         string                 `json:"task_key" binding:"required"`
+	TagIDs          []int64                `json:"tag_ids"`       // 参与条件，带其中任一标签的用户才能参与，为空表示不限
+	Eligibility     *model.TaskEligibility `json:"eligibility"`   // 参与规则，与标签条件同时满足才能参与，为空表示不限
+	VerifyConfig    json.RawMessage        `json:"verify_config"` // 任务完成校验配置，格式由 TaskKey 对应的校验器决定
 }
 
 // CreateTask 创建任务
@@ -68,33 +85,63 @@ func (s *TaskService) CreateTask(ctx context.Context, req *CreateTaskRequest) (*
 	}
 	from, _ := time.Parse(time.DateOnly, req.ValidFrom)
 	task.ValidFrom = &from
-	if req.ValidTo == "" {
+	if req.ValidTo != "" {
 		to, err := time.Parse(time.DateOnly, req.ValidTo)
 		if err == nil {
 			task.ValidTo = &to
 		}
 	}
 
+	if err := s.validateTagIDs(req.TagIDs); err != nil {
+		return nil, err
+	}
+	if err := s.validateVerifyConfig(req.TaskKey, req.VerifyConfig); err != nil {
+		return nil, err
+	}
+	task.VerifyJSON = verifyConfigString(req.VerifyConfig)
+	task.VerifyConfig = req.VerifyConfig
+	if err := validateEligibility(req.Eligibility); err != nil {
+		return nil, err
+	}
+	eligibility, err := eligibilityString(req.Eligibility)
+	if err != nil {
+		return nil, err
+	}
+	if eligibility != nil {
+		task.EligibilityJSON = eligibility
+		task.Eligibility = req.Eligibility
+	}
+
 	if err := s.db.Create(task).Error; err != nil {
 		return nil, errors.New(errors.ErrCodeInternal, "创建任务失败", err)
 	}
+	if len(req.TagIDs) > 0 {
+		if err := model.SetRewardTaskTags(s.db, task.TaskID, req.TagIDs); err != nil {
+			return nil, errors.New(errors.ErrCodeInternal, "保存任务参与条件失败", err)
+		}
+		task.TagIDs = req.TagIDs
+	}
+	audit.Record(ctx, "task", strconv.Itoa(task.TaskID), nil, task)
 
 	return task, nil
 }
 
 // UpdateTaskRequest 更新任务请求
 type UpdateTaskRequest struct {
-	TaskId          int    `json:"id" binding:"required"`
-	Status          *int8  `json:"status" binding:"required"`
-	TaskName        string `json:"task_name" binding:"required"`
-	Description     string `json:"task_desc" binding:"required"`
-	TokenReward     int    `json:"token_reward" binding:"required"`
-	DailyLimit      int    `json:"daily_limit" binding:"required"`
-	IntervalSeconds *int   `json:"interval_seconds" binding:"required"`
-	ValidFrom       string `json:"valid_from" binding:"required"`
-	ValidTo         string `json:"valid_to"`
-	Repeatable      *int8  `json:"repeatable" binding:"required"`
-	TaskKey         string `json:"task_key" binding:"required"`
+	TaskId          int                    `json:"id" binding:"required"`
+	Status          *int8                  `json:"status" binding:"required"`
+	TaskName        string                 `json:"task_name" binding:"required"`
+	Description     string                 `json:"task_desc" binding:"required"`
+	TokenReward     int                    `json:"token_reward" binding:"required"`
+	DailyLimit      int                    `json:"daily_limit" binding:"required"`
+	IntervalSeconds *int                   `json:"interval_seconds" binding:"required"`
+	ValidFrom       string                 `json:"valid_from" binding:"required"`
+	ValidTo         string                 `json:"valid_to"`
+	Repeatable      *int8                  `json:"repeatable" binding:"required"`
+	TaskKey         string                 `json:"task_key" binding:"required"`
+	TagIDs          []int64                `json:"tag_ids"`       // 参与条件，不传则不修改，传空数组表示不限
+	Eligibility     *model.TaskEligibility `json:"eligibility"`   // 参与规则，不传则不修改，传空对象表示不限
+	VerifyConfig    json.RawMessage        `json:"verify_config"` // 任务完成校验配置，不传则不修改
 }
 
 // UpdateTask 更新任务
@@ -123,9 +170,40 @@ func (s *TaskService) UpdateTask(ctx context.Context, req *UpdateTaskRequest) (*
 		}
 	}
 
+	if err := s.validateTagIDs(req.TagIDs); err != nil {
+		return nil, err
+	}
+	verifyConfig := task.VerifyConfig
+	if req.VerifyConfig != nil {
+		verifyConfig = req.VerifyConfig
+		updates["verify_config"] = verifyConfigString(req.VerifyConfig)
+	}
+	if err := s.validateVerifyConfig(req.TaskKey, verifyConfig); err != nil {
+		return nil, err
+	}
+	if req.Eligibility != nil {
+		if err := validateEligibility(req.Eligibility); err != nil {
+			return nil, err
+		}
+		eligibility, err := eligibilityString(req.Eligibility)
+		if err != nil {
+			return nil, err
+		}
+		updates["eligibility"] = eligibility
+	}
+
+	before := *task
 	if err := s.db.Model(task).Updates(updates).Error; err != nil {
 		return nil, errors.New(errors.ErrCodeInternal, "更新任务失败", err)
 	}
+	if req.TagIDs != nil {
+		if err := model.SetRewardTaskTags(s.db, task.TaskID, req.TagIDs); err != nil {
+			return nil, errors.New(errors.ErrCodeInternal, "保存任务参与条件失败", err)
+		}
+		task.TagIDs = req.TagIDs
+	}
+	after, _ := s.GetTask(ctx, req.TaskId)
+	audit.Record(ctx, "task", strconv.Itoa(req.TaskId), &before, after)
 
 	return task, nil
 }
@@ -135,6 +213,9 @@ func (s *TaskService) DeleteTask(ctx context.Context, taskID int) error {
 	if err := s.db.Delete(&model.RewardTask{}, taskID).Error; err != nil {
 		return errors.New(errors.ErrCodeInternal, "删除任务失败", err)
 	}
+	if err := model.SetRewardTaskTags(s.db, taskID, nil); err != nil {
+		return errors.New(errors.ErrCodeInternal, "删除任务参与条件失败", err)
+	}
 	return nil
 }
 
@@ -147,9 +228,72 @@ func (s *TaskService) GetTask(ctx context.Context, taskID int) (*model.RewardTas
 		}
 		return nil, errors.New(errors.ErrCodeInternal, "获取任务失败", err)
 	}
+	if err := s.loadTaskTags(&task); err != nil {
+		return nil, err
+	}
 	return &task, nil
 }
 
+// validateTagIDs 校验任务参与条件中的标签是否存在
+func (s *TaskService) validateTagIDs(tagIDs []int64) error {
+	if len(tagIDs) == 0 {
+		return nil
+	}
+	count, err := model.CountUserTags(s.db, tagIDs)
+	if err != nil {
+		return errors.New(errors.ErrCodeInternal, "检查标签失败", err)
+	}
+	if count != int64(len(tagIDs)) {
+		return errors.New(errors.ErrCodeInvalidParams, "标签不存在", nil)
+	}
+	return nil
+}
+
+// loadTaskTags 加载任务的参与条件标签
+func (s *TaskService) loadTaskTags(tasks ...*model.RewardTask) error {
+	ids := make([]int, 0, len(tasks))
+	for _, t := range tasks {
+		ids = append(ids, t.TaskID)
+	}
+	tagIDs, err := model.ListRewardTaskTagIDs(s.db, ids)
+	if err != nil {
+		return errors.New(errors.ErrCodeInternal, "获取任务参与条件失败", err)
+	}
+	for _, t := range tasks {
+		t.TagIDs = tagIDs[t.TaskID]
+		if t.TagIDs == nil {
+			t.TagIDs = []int64{}
+		}
+	}
+	return nil
+}
+
+// userTagSet 获取用户拥有的标签，用于判断任务参与条件
+func (s *TaskService) userTagSet(userID string) (map[int64]struct{}, error) {
+	ids, err := model.ListUserTagIDsByUser(s.db, userID)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "获取用户标签失败", err)
+	}
+	set := make(map[int64]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return set, nil
+}
+
+// eligibleForTask 判断用户是否满足任务的标签条件，任务未设置标签时所有用户都可参与
+func eligibleForTask(task *model.RewardTask, userTags map[int64]struct{}) bool {
+	if len(task.TagIDs) == 0 {
+		return true
+	}
+	for _, id := range task.TagIDs {
+		if _, ok := userTags[id]; ok {
+			return true
+		}
+	}
+	return false
+}
+
 // ListTasks 获取任务列表
 func (s *TaskService) ListTasks(ctx context.Context, status *int, taskName string) ([]*model.RewardTask, int64, error) {
 	var tasks []*model.RewardTask
@@ -170,6 +314,9 @@ func (s *TaskService) ListTasks(ctx context.Context, status *int, taskName strin
 	if err := query.Find(&tasks).Error; err != nil {
 		return nil, 0, errors.New(errors.ErrCodeInternal, "获取任务列表失败", err)
 	}
+	if err := s.loadTaskTags(tasks...); err != nil {
+		return nil, 0, err
+	}
 
 	return tasks, total, nil
 }
@@ -224,9 +371,15 @@ func (s *TaskService) UpdateConsumptionRule(ctx context.Context, id int, req *Up
 		updates["classify"] = req.Class
 	}
 
+	before := rule
 	if err := s.db.Model(&rule).Updates(updates).Error; err != nil {
 		return errors.New(errors.ErrCodeInternal, "更新消费规则失败", err)
 	}
+	after := &model.TokenConsumeRule{}
+	if err := s.db.Where("feature_id = ?", id).First(after).Error; err != nil {
+		after = nil
+	}
+	audit.Record(ctx, "consume_rule", strconv.Itoa(id), &before, after)
 
 	return nil
 }
@@ -255,95 +408,138 @@ func (s *TaskService) CreateConsumptionRule(ctx context.Context, req *CreateCons
 	if err != nil {
 		return nil, err
 	}
+	audit.Record(ctx, "consume_rule", strconv.Itoa(rule.FeatureID), nil, rule)
 	return rule, nil
 }
 
 // GetAvailableTasks 获取用户可用的任务列表
 func (s *TaskService) GetAvailableTasks(ctx context.Context, userID string) ([]*model.RewardTask, error) {
-	var tasks []*model.RewardTask
 	now := time.Now()
-
-	// 获取所有启用的任务
-	if err := s.db.Where("status = 1 AND (valid_from IS NULL OR valid_from <= ?) AND (valid_to IS NULL OR valid_to >= ?)",
-		now, now).Find(&tasks).Error; err != nil {
-		return nil, errors.New(errors.ErrCodeInternal, "获取可用任务失败", err)
+	loc := s.userLocation(s.db, userID)
+	tasks, stats, err := s.loadUserTasks(ctx, userID, now, loc)
+	if err != nil {
+		return nil, err
 	}
 
-	// 过滤掉已达到每日限制的任务
+	// 过滤掉已达到次数限制的任务
 	var availableTasks []*model.RewardTask
 	for _, task := range tasks {
-		if task.DailyLimit > 0 {
-			count, err := s.getUserTaskCompletionCount(ctx, userID, task.TaskID)
-			if err != nil {
-				return nil, err
-			}
-			if count >= int64(task.DailyLimit) {
-				continue
-			}
+		if evaluateTaskLimits(task, stats[task.TaskID], now, loc).canComplete() {
+			availableTasks = append(availableTasks, task)
 		}
+	}
+	return availableTasks, nil
+}
 
-		// 检查间隔时间
-		if task.IntervalSeconds > 0 {
-			lastCompletion, err := s.getLastTaskCompletion(ctx, s.db, userID, task.TaskID)
-			if err != nil {
-				return nil, err
-			}
-			if lastCompletion != nil {
-				nextAvailableTime := lastCompletion.Add(time.Duration(task.IntervalSeconds) * time.Second)
-				if now.Before(nextAvailableTime) {
-					continue
-				}
-			}
-		}
+// loadUserTasks 获取对用户展示的任务（启用、在有效期内、满足参与条件）及用户各任务的完成情况，
+// 查询次数与任务数量无关
+func (s *TaskService) loadUserTasks(ctx context.Context, userID string, now time.Time, loc *time.Location) ([]*model.RewardTask, map[int]*model.TaskCompletionStat, error) {
+	var tasks []*model.RewardTask
+	today := localDate(now, loc)
 
-		// 检查是否可重复完成
-		if task.Repeatable == 0 {
-			completed, err := s.hasCompletedTask(ctx, s.db, userID, task.TaskID)
-			if err != nil {
-				return nil, err
-			}
-			if completed {
-				continue
-			}
+	// 获取所有启用的任务，有效期按用户时区的当天日期判断
+	if err := s.db.WithContext(ctx).Where("status = 1 AND (valid_from IS NULL OR valid_from <= ?) AND (valid_to IS NULL OR valid_to >= ?)",
+		today, today).Find(&tasks).Error; err != nil {
+		return nil, nil, errors.New(errors.ErrCodeInternal, "获取可用任务失败", err)
+	}
+	if err := s.loadTaskTags(tasks...); err != nil {
+		return nil, nil, err
+	}
+	audience, err := s.loadTaskAudience(userID, tasks...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 不满足参与条件的任务不展示
+	visible := make([]*model.RewardTask, 0, len(tasks))
+	for _, task := range tasks {
+		if audience.eligible(task, now) {
+			visible = append(visible, task)
 		}
+	}
+	if len(visible) == 0 {
+		return visible, nil, nil
+	}
 
-		availableTasks = append(availableTasks, task)
+	start, end := dayRange(now, loc)
+	stats, err := model.GetTaskCompletionStats(s.db.WithContext(ctx), userID, nil, start, end)
+	if err != nil {
+		return nil, nil, errors.New(errors.ErrCodeInternal, "获取任务完成情况失败", err)
 	}
+	return visible, stats, nil
+}
 
-	return availableTasks, nil
+// 任务次数限制未满足的原因
+const (
+	taskLimitRepeat   = "该任务已完成且不可重复完成"
+	taskLimitDaily    = "今日任务完成次数已达上限"
+	taskLimitCooldown = "任务冷却中"
+)
+
+// taskLimitState 用户某个任务的次数限制状态
+type taskLimitState struct {
+	todayCount        int64      // 今日已完成次数
+	totalCount        int64      // 总完成次数
+	reason            string     // 不能完成的原因，为空表示可以完成
+	nextAvailableTime *time.Time // 下次可完成时间，不可重复的任务为 nil
 }
 
-// getUserTaskCompletionCount 获取用户任务完成次数
-func (s *TaskService) getUserTaskCompletionCount(ctx context.Context, userID string, taskID int) (int64, error) {
-	var count int64
-	today := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 0, 0, 0, 0, time.Local)
+// canComplete 当前是否可以完成
+func (st *taskLimitState) canComplete() bool {
+	return st.reason == ""
+}
 
-	if err := s.db.Model(&model.TaskCompletionRecord{}).
-		Where("user_id = ? AND task_id = ? AND completed_at >= ?",
-			userID, taskID, today).
-		Count(&count).Error; err != nil {
-		return 0, errors.New(errors.ErrCodeInternal, "获取任务完成次数失败", err)
+// err 不能完成时返回对应的错误
+func (st *taskLimitState) err(loc *time.Location) error {
+	switch st.reason {
+	case "":
+		return nil
+	case taskLimitCooldown:
+		return errors.New(errors.ErrCodeInvalidParams,
+			fmt.Sprintf("任务冷却中，请在 %s 后重试", st.nextAvailableTime.In(loc).Format("2006-01-02 15:04:05")), nil)
+	default:
+		return errors.New(errors.ErrCodeInvalidParams, st.reason, nil)
 	}
-
-	return count, nil
 }
 
-// getUserTotalTaskCompletionCount 获取用户任务总完成次数
-func (s *TaskService) getUserTotalTaskCompletionCount(ctx context.Context, userID string, taskID int) (int64, error) {
-	var count int64
-	if err := s.db.Model(&model.TaskCompletionRecord{}).
-		Where("user_id = ? AND task_id = ?",
-			userID, taskID).
-		Count(&count).Error; err != nil {
-		return 0, errors.New(errors.ErrCodeInternal, "获取任务总完成次数失败", err)
+// evaluateTaskLimits 按用户的完成情况判断不可重复、每日次数和间隔限制，stat 为 nil 表示从未完成。
+// 同时触发多个限制时原因按上述顺序取第一个，下次可完成时间取各限制中最晚的
+func evaluateTaskLimits(task *model.RewardTask, stat *model.TaskCompletionStat, now time.Time, loc *time.Location) *taskLimitState {
+	st := &taskLimitState{}
+	if stat == nil {
+		return st
+	}
+	st.todayCount = stat.TodayCount
+	st.totalCount = stat.TotalCount
+
+	if task.Repeatable == 0 && stat.TotalCount > 0 {
+		st.reason = taskLimitRepeat
+		return st
+	}
+	if task.DailyLimit > 0 && stat.TodayCount >= int64(task.DailyLimit) {
+		st.reason = taskLimitDaily
+		_, tomorrow := dayRange(now, loc)
+		st.nextAvailableTime = &tomorrow
+	}
+	if task.IntervalSeconds > 0 && stat.LastCompletedAt != nil {
+		next := stat.LastCompletedAt.Add(time.Duration(task.IntervalSeconds) * time.Second)
+		if now.Before(next) {
+			if st.reason == "" {
+				st.reason = taskLimitCooldown
+			}
+			if st.nextAvailableTime == nil || next.After(*st.nextAvailableTime) {
+				st.nextAvailableTime = &next
+			}
+		}
 	}
-	return count, nil
+	return st
 }
 
 // CompleteTaskRequest 完成任务请求
 type CompleteTaskRequest struct {
 	TaskID    int                    `json:"task_id" binding:"required"`
 	ExtraData map[string]interface{} `json:"extra_data"`
+	Client    RiskClient             `json:"-"` // 风控所需的客户端信息，由处理器从请求中获取
 }
 
 // TaskCompletionResult 任务完成结果
@@ -355,11 +551,17 @@ type TaskCompletionResult struct {
 	//Message           string     `json:"message"`
 	//NextAvailableTime *time.Time `json:"next_available_time,omitempty"`
 	Balance int64 `json:"balance"`
-	Reward  int   `json:"reward"`
+	Reward  int   `json:"reward"` // 实际发放的代币数，风控降额时低于任务奖励
+	Held    bool  `json:"held"`   // 奖励被风控暂扣，审核通过后补发
 }
 
 // CompleteTask 完成任务
 func (s *TaskService) CompleteTask(ctx context.Context, userID string, req *CompleteTaskRequest) (*TaskCompletionResult, error) {
+	extraData, err := extraDataString(req.ExtraData)
+	if err != nil {
+		return nil, err
+	}
+
 	// 获取分布式锁，防止并发完成
 	lockKey := fmt.Sprintf("task_completion_lock:%s:%d", userID, req.TaskID)
 	acquired, err := s.redis.SetNX(ctx, lockKey, "1", 10*time.Second).Result()
@@ -382,134 +584,213 @@ func (s *TaskService) CompleteTask(ctx context.Context, userID string, req *Comp
 		}
 	}()
 
-	// 获取任务信息
-	task, err := s.GetTask(ctx, req.TaskID)
+	// 在事务内获取任务信息，与次数检查和奖励发放使用同一份任务配置
+	task, err := s.getTaskWithDB(ctx, tx, req.TaskID)
 	if err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
-	// 检查任务状态
-	if task.Status != 1 {
+	// 检查任务状态、有效期、参与条件和次数限制
+	if err := s.checkTaskAvailable(ctx, tx, userID, task); err != nil {
 		tx.Rollback()
-		return nil, errors.New(errors.ErrCodeInvalidParams, "任务未启用", nil)
+		return nil, err
 	}
 
-	// 检查任务有效期
-	now := time.Now()
-	if task.ValidFrom != nil && now.Before(*task.ValidFrom) {
+	// 验证任务完成条件，校验器在事务外占用的资源在回滚时一并撤销
+	verifyInput, err := s.verifyTaskCompletion(ctx, tx, userID, task, req.ExtraData)
+	rollback := func() {
 		tx.Rollback()
-		return nil, errors.New(errors.ErrCodeInvalidParams, "任务未开始", nil)
+		verifyInput.rollback()
 	}
-	if task.ValidTo != nil && now.After(*task.ValidTo) {
-		tx.Rollback()
-		return nil, errors.New(errors.ErrCodeInvalidParams, "任务已结束", nil)
-	}
-
-	// 检查任务限制
-	if err := s.checkTaskLimits(ctx, tx, userID, task); err != nil {
-		tx.Rollback()
+	if err != nil {
+		rollback()
 		return nil, err
 	}
 
-	// 验证任务完成条件
-	if err := s.verifyTaskCompletion(ctx, tx, userID, task, req.ExtraData); err != nil {
-		tx.Rollback()
+	// 风控评估，按实际发放的代币数发放和记录；暂扣时只记录完成，审核通过后补发
+	risk, err := s.risk.Assess(ctx, tx, &RiskInput{
+		EventType:  model.RiskEventReward,
+		Action:     task.TaskKey,
+		UserID:     userID,
+		Amount:     task.TokenReward,
+		ChangeType: "TASK_REWARD",
+		Remark:     task.TaskName,
+		Client:     req.Client,
+	})
+	if err != nil {
+		rollback()
 		return nil, err
 	}
+	if risk.Blocked() {
+		// 拒绝的事件也要保留，作为后续频率统计的依据
+		if err := tx.Commit().Error; err != nil {
+			return nil, errors.New(errors.ErrCodeInternal, "提交事务失败", err)
+		}
+		return nil, errors.New(errors.ErrCodeForbidden, "奖励领取过于频繁，请稍后再试", nil)
+	}
+	granted := *task
+	granted.TokenReward = risk.Amount
 
 	// 发放奖励
-	if err := s.grantTaskReward(ctx, tx, userID, task); err != nil {
-		tx.Rollback()
-		return nil, err
+	if granted.TokenReward > 0 {
+		if err := s.grantTaskReward(ctx, tx, userID, &granted); err != nil {
+			rollback()
+			return nil, err
+		}
 	}
 
-	// 记录任务完成
-	if err := s.recordTaskCompletion(ctx, tx, userID, task); err != nil {
-		tx.Rollback()
+	// 记录任务完成，提交的数据作为校验凭证一并保存
+	if err := s.recordTaskCompletion(ctx, tx, userID, &granted, extraData); err != nil {
+		rollback()
 		return nil, err
 	}
 
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
+		verifyInput.rollback()
 		return nil, errors.New(errors.ErrCodeInternal, "提交事务失败", err)
 	}
 
-	token, err := s.getUserToken(ctx, tx, userID)
+	// 事务提交后再发送通知和统计成就，避免回滚后仍推送奖励消息
+	if granted.TokenReward > 0 {
+		s.notifier.NotifyTaskReward(context.Background(), userID, &granted)
+	}
+	s.trackTaskCompletion(userID, task)
+
+	token, err := s.getUserToken(ctx, s.db, userID)
 	if err != nil {
 		return nil, err
 	}
 
 	return &TaskCompletionResult{
-		Reward:  task.TokenReward,
+		Reward:  granted.TokenReward,
 		Balance: token,
+		Held:    risk.Held(),
 	}, nil
 }
 
-// checkTaskLimits 检查任务限制
-func (s *TaskService) checkTaskLimits(ctx context.Context, tx *gorm.DB, userID string, task *model.RewardTask) error {
-	// 检查每日限制
-	if task.DailyLimit > 0 {
-		count, err := s.getUserTaskCompletionCount(ctx, userID, task.TaskID)
-		if err != nil {
-			return err
-		}
-		if int(count) >= task.DailyLimit {
-			return errors.New(errors.ErrCodeInvalidParams, "今日任务完成次数已达上限", nil)
-		}
+// trackTaskCompletion 任务完成后推进成就进度，需在事务提交后调用
+func (s *TaskService) trackTaskCompletion(userID string, task *model.RewardTask) {
+	s.achievements.Track(context.Background(), &AchievementEvent{
+		UserID: userID,
+		Type:   model.AchievementEventTaskComplete,
+		Key:    strconv.Itoa(task.TaskID),
+		Amount: 1,
+	})
+}
+
+// checkTaskAvailable 检查任务状态、有效期、用户参与条件和次数限制
+func (s *TaskService) checkTaskAvailable(ctx context.Context, tx *gorm.DB, userID string, task *model.RewardTask) error {
+	now := time.Now()
+	loc := s.userLocation(tx, userID)
+	if err := s.checkTaskOpen(userID, task, now, loc); err != nil {
+		return err
 	}
+	return s.checkTaskLimits(ctx, tx, userID, task, now, loc)
+}
 
-	// 检查间隔时间
-	if task.IntervalSeconds > 0 {
-		lastCompletion, err := s.getLastTaskCompletion(ctx, tx, userID, task.TaskID)
-		if err != nil {
-			return err
-		}
-		if lastCompletion != nil {
-			nextAvailableTime := lastCompletion.Add(time.Duration(task.IntervalSeconds) * time.Second)
-			if time.Now().Before(nextAvailableTime) {
-				return errors.New(errors.ErrCodeInvalidParams,
-					fmt.Sprintf("任务冷却中，请在 %s 后重试", nextAvailableTime.Format("2006-01-02 15:04:05")), nil)
-			}
-		}
+// checkTaskOpen 检查任务状态、有效期和用户参与条件，有效期按用户时区的当天日期判断
+func (s *TaskService) checkTaskOpen(userID string, task *model.RewardTask, now time.Time, loc *time.Location) error {
+	if task.Status != 1 {
+		return errors.New(errors.ErrCodeInvalidParams, "任务未启用", nil)
 	}
 
-	// 检查是否可重复完成
-	if task.Repeatable == 0 {
-		completed, err := s.hasCompletedTask(ctx, tx, userID, task.TaskID)
-		if err != nil {
-			return err
-		}
-		if completed {
-			return errors.New(errors.ErrCodeInvalidParams, "该任务已完成且不可重复完成", nil)
-		}
+	if err := checkTaskValidity(task, now, loc); err != nil {
+		return err
 	}
 
+	audience, err := s.loadTaskAudience(userID, task)
+	if err != nil {
+		return err
+	}
+	if !audience.eligible(task, now) {
+		return errors.New(errors.ErrCodeTaskNotAvailable, "不满足任务参与条件", nil)
+	}
 	return nil
 }
 
-// verifyTaskCompletion 验证任务完成条件
-func (s *TaskService) verifyTaskCompletion(ctx context.Context, tx *gorm.DB, userID string, task *model.RewardTask, extraData map[string]interface{}) error {
-	// 这里可以根据具体任务类型实现不同的验证逻辑
-	// 例如：观看视频任务验证视频是否完整观看，分享任务验证分享是否成功等
-	// 目前实现一个简单的示例验证
+// GrantVerifiedCompletion 在调用方事务中为服务端已验证的完成（如广告平台回调）检查任务限制、发放奖励并记录完成，
+// 不再经过任务校验器；奖励通知由调用方在事务提交后发送
+func (s *TaskService) GrantVerifiedCompletion(ctx context.Context, tx *gorm.DB, userID string, task *model.RewardTask) error {
+	if err := s.checkTaskAvailable(ctx, tx, userID, task); err != nil {
+		return err
+	}
+	if err := s.grantTaskReward(ctx, tx, userID, task); err != nil {
+		return err
+	}
+	return s.recordTaskCompletion(ctx, tx, userID, task, nil)
+}
 
-	// 示例：验证任务参数
-	if task.TaskName == "每日签到" {
-		// 签到任务不需要额外验证
-		return nil
-	} else if task.TaskName == "观看视频" {
-		// 验证视频ID和观看时长
-		if _, ok := extraData["video_id"].(string); !ok {
-			return errors.New(errors.ErrCodeInvalidParams, "缺少视频ID", nil)
+// GetTaskByKey 按任务标识获取任务
+func (s *TaskService) GetTaskByKey(ctx context.Context, taskKey string) (*model.RewardTask, error) {
+	var task model.RewardTask
+	if err := s.db.WithContext(ctx).Where("task_key = ?", taskKey).First(&task).Error; err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New(errors.ErrCodeNotFound, "任务不存在", nil)
 		}
-		if _, ok := extraData["watch_duration"].(float64); !ok {
-			return errors.New(errors.ErrCodeInvalidParams, "缺少观看时长", nil)
+		return nil, errors.New(errors.ErrCodeInternal, "获取任务失败", err)
+	}
+	if err := s.loadTaskTags(&task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// checkTaskLimits 检查任务限制，每日次数按用户时区的自然日统计。
+// 先锁定用户行使同一用户的完成串行执行，再以加锁读取最新的完成情况，
+// 与 task_completion_lock 一起保证并发上报、广告回调等不同入口都不会超出限制
+func (s *TaskService) checkTaskLimits(ctx context.Context, tx *gorm.DB, userID string, task *model.RewardTask, now time.Time, loc *time.Location) error {
+	if _, err := lockTaskUser(tx, userID); err != nil {
+		return err
+	}
+	start, end := dayRange(now, loc)
+	stats, err := model.GetTaskCompletionStats(tx.Clauses(clause.Locking{Strength: "SHARE"}),
+		userID, []int{task.TaskID}, start, end)
+	if err != nil {
+		return errors.New(errors.ErrCodeInternal, "获取任务完成情况失败", err)
+	}
+	return evaluateTaskLimits(task, stats[task.TaskID], now, loc).err(loc)
+}
+
+// lockTaskUser 锁定用户行，同一用户的任务完成、签到和补签串行执行
+func lockTaskUser(tx *gorm.DB, userID string) (*model.User, error) {
+	var user model.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", userID).First(&user).Error; err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New(errors.ErrCodeUserNotFound, "用户不存在", nil)
 		}
-		// 这里可以添加更多验证逻辑，如检查视频是否存在、观看时长是否足够等
+		return nil, errors.New(errors.ErrCodeInternal, "获取用户信息失败", err)
+	}
+	if user.Status != 1 {
+		return nil, errors.New(errors.ErrCodeUserDisabled, "用户账号已被禁用", nil)
 	}
+	return &user, nil
+}
 
-	return nil
+// verifyTaskCompletion 按任务的 TaskKey 选择校验器验证任务完成条件，没有校验器的任务不能通过上报完成。
+// 返回的校验输入记录了事务回滚时需要撤销的操作，出错时也需调用其 rollback
+func (s *TaskService) verifyTaskCompletion(ctx context.Context, tx *gorm.DB, userID string, task *model.RewardTask, extraData map[string]interface{}) (*TaskVerifyInput, error) {
+	in := &TaskVerifyInput{
+		Tx:        tx,
+		UserID:    userID,
+		Task:      task,
+		ExtraData: extraData,
+	}
+	verifier, ok := s.verifiers.Lookup(task.TaskKey)
+	if !ok {
+		return in, errors.New(errors.ErrCodeTaskNotAvailable, "该任务不支持上报完成", nil)
+	}
+	return in, verifier.Verify(ctx, in)
+}
+
+// verifyConfigString 将校验配置转为存储格式，为空时存 NULL
+func verifyConfigString(raw json.RawMessage) *string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	str := string(raw)
+	return &str
 }
 
 func (s *TaskService) getUserToken(ctx context.Context, tx *gorm.DB, userID string) (int64, error) {
@@ -518,23 +799,28 @@ func (s *TaskService) getUserToken(ctx context.Context, tx *gorm.DB, userID stri
 
 // grantTaskReward 发放任务奖励
 func (s *TaskService) grantTaskReward(ctx context.Context, tx *gorm.DB, userID string, task *model.RewardTask) error {
+	return s.grantTaskTokens(ctx, tx, userID, task, task.TokenReward)
+}
+
+// grantTaskTokens 按指定数量发放任务奖励，用于奖励随完成情况变化的任务
+func (s *TaskService) grantTaskTokens(ctx context.Context, tx *gorm.DB, userID string, task *model.RewardTask, amount int) error {
 	// 获取用户信息并加行锁
 	var user model.User
 	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-		First(&user, userID).Error; err != nil {
+		Where("id = ?", userID).First(&user).Error; err != nil {
 		return errors.New(errors.ErrCodeInternal, "获取用户信息失败", err)
 	}
 
 	// 使用 UpdateUserTokenBalance 更新用户代币余额
-	if err := model.UpdateUserTokenBalance(tx, userID, task.TokenReward); err != nil {
+	if err := model.UpdateUserTokenBalance(tx, userID, amount); err != nil {
 		return errors.New(errors.ErrCodeInternal, "更新用户代币余额失败", err)
 	}
 
 	// 创建代币记录
 	tokenRecord := &model.TokenRecord{
 		UserID:       userID,
-		ChangeAmount: task.TokenReward,
-		BalanceAfter: user.TokenBalance + task.TokenReward,
+		ChangeAmount: amount,
+		BalanceAfter: user.TokenBalance + amount,
 		ChangeType:   "TASK_REWARD",
 		TaskID:       &task.TaskID,
 		Remark:       &task.TaskName,
@@ -547,13 +833,14 @@ func (s *TaskService) grantTaskReward(ctx context.Context, tx *gorm.DB, userID s
 	return nil
 }
 
-// recordTaskCompletion 记录任务完成
-func (s *TaskService) recordTaskCompletion(ctx context.Context, tx *gorm.DB, userID string, task *model.RewardTask) error {
+// recordTaskCompletion 记录任务完成，extraData 为上报时提交的数据
+func (s *TaskService) recordTaskCompletion(ctx context.Context, tx *gorm.DB, userID string, task *model.RewardTask, extraData *string) error {
 	// 创建任务完成记录
 	record := &model.TaskCompletionRecord{
 		UserID:      userID,
 		TaskID:      task.TaskID,
 		TokenReward: task.TokenReward,
+		ExtraData:   extraData,
 		CompletedAt: time.Now(),
 	}
 
@@ -561,70 +848,9 @@ func (s *TaskService) recordTaskCompletion(ctx context.Context, tx *gorm.DB, use
 		return errors.New(errors.ErrCodeInternal, "创建任务完成记录失败", err)
 	}
 
-	// 发送任务完成通知
-	go s.sendTaskCompletionNotification(ctx, userID, task)
-
 	return nil
 }
 
-// sendTaskCompletionNotification 发送任务完成通知
-func (s *TaskService) sendTaskCompletionNotification(ctx context.Context, userID string, task *model.RewardTask) {
-	// 获取用户信息
-	var user model.User
-	if err := s.db.First(&user, userID).Error; err != nil {
-		s.logger.Error("获取用户信息失败", zap.Error(err))
-		return
-	}
-
-	// 构建通知内容
-	notification := &model.Notification{
-		UserID:    userID,
-		Type:      "TASK_COMPLETION",
-		Title:     "任务完成通知",
-		Content:   fmt.Sprintf("恭喜您完成了任务「%s」，获得 %d 代币奖励！", task.TaskName, task.TokenReward),
-		Status:    0, // 未读
-		CreatedAt: time.Now(),
-	}
-
-	// 保存通知
-	if err := s.db.Create(notification).Error; err != nil {
-		s.logger.Error("创建任务完成通知失败", zap.Error(err))
-		return
-	}
-
-	// TODO: 如果需要消息推送，可以在这里添加推送逻辑
-	// 例如：推送到消息队列，由消息服务处理推送
-}
-
-// getLastTaskCompletion 获取最后一次任务完成时间
-func (s *TaskService) getLastTaskCompletion(ctx context.Context, tx *gorm.DB, userID string, taskID int) (*time.Time, error) {
-	var record model.TaskCompletionRecord
-	err := tx.Where("user_id = ? AND task_id = ?",
-		userID, taskID).
-		Order("completed_at DESC").
-		First(&record).Error
-	if err != nil {
-		if stderrors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, nil
-		}
-		return nil, errors.New(errors.ErrCodeInternal, "获取任务完成记录失败", err)
-	}
-	return &record.CompletedAt, nil
-}
-
-// hasCompletedTask 检查是否已完成任务
-func (s *TaskService) hasCompletedTask(ctx context.Context, tx *gorm.DB, userID string, taskID int) (bool, error) {
-	var count int64
-	err := tx.Model(&model.TaskCompletionRecord{}).
-		Where("user_id = ? AND task_id = ?",
-			userID, taskID).
-		Count(&count).Error
-	if err != nil {
-		return false, errors.New(errors.ErrCodeInternal, "检查任务完成状态失败", err)
-	}
-	return count > 0, nil
-}
-
 // TaskCompletionRecord 任务完成记录
 type TaskCompletionRecord struct {
 	RecordID    int64     `json:"record_id"`
@@ -677,8 +903,8 @@ func (s *TaskService) GetUserTaskRecords(ctx context.Context, userID string, pag
 // GetTaskStatistics 获取任务统计信息
 func (s *TaskService) GetTaskStatistics(ctx context.Context, taskID int) (*TaskStatistics, error) {
 	var stats TaskStatistics
-	now := time.Now()
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	// 今日统计按业务时区划分
+	today, _ := dayRange(time.Now(), s.businessLocation())
 
 	// 获取任务信息
 	task, err := s.GetTask(ctx, taskID)
@@ -691,7 +917,7 @@ func (s *TaskService) GetTaskStatistics(ctx context.Context, taskID int) (*TaskS
 
 	// 查询总完成次数和奖励
 	if err := s.db.Model(&model.TaskCompletionRecord{}).
-		Where("task_id = ?", taskID).
+		Where("task_id = ? AND revoked_at IS NULL", taskID).
 		Select("COUNT(*) as total_completions, SUM(token_reward) as total_rewards").
 		Scan(&stats).Error; err != nil {
 		return nil, errors.New(errors.ErrCodeInternal, "获取任务统计信息失败", err)
@@ -699,7 +925,7 @@ func (s *TaskService) GetTaskStatistics(ctx context.Context, taskID int) (*TaskS
 
 	// 查询今日完成次数和奖励
 	if err := s.db.Model(&model.TaskCompletionRecord{}).
-		Where("task_id = ? AND completed_at >= ?", taskID, today).
+		Where("task_id = ? AND completed_at >= ? AND revoked_at IS NULL", taskID, today).
 		Select("COUNT(*) as today_completions, SUM(token_reward) as today_rewards").
 		Scan(&stats).Error; err != nil {
 		return nil, errors.New(errors.ErrCodeInternal, "获取今日任务统计信息失败", err)
@@ -760,97 +986,37 @@ type UserTaskStatus struct {
 	ValidTo             *time.Time `json:"valid_to"`              // 任务截止时间
 }
 
-// GetUserAvailableTasksWithStatus 获取用户可用任务列表（包含详细状态信息）
+// GetUserAvailableTasksWithStatus 获取用户可用任务列表（包含详细状态信息），查询次数与任务数量无关
 func (s *TaskService) GetUserAvailableTasksWithStatus(ctx context.Context, userID string) ([]*UserTaskStatus, error) {
-	var tasks []*model.RewardTask
 	now := time.Now()
-
-	// 获取所有启用的任务
-	if err := s.db.Where("status = 1 AND (valid_from IS NULL OR valid_from <= ?) AND (valid_to IS NULL OR valid_to >= ?)",
-		now, now).Find(&tasks).Error; err != nil {
-		return nil, errors.New(errors.ErrCodeInternal, "获取可用任务失败", err)
+	loc := s.userLocation(s.db, userID)
+	tasks, stats, err := s.loadUserTasks(ctx, userID, now, loc)
+	if err != nil {
+		return nil, err
 	}
 
-	var result []*UserTaskStatus
+	result := make([]*UserTaskStatus, 0, len(tasks))
 	for _, task := range tasks {
-		userTaskStatus := &UserTaskStatus{
-			TaskID:          task.TaskID,
-			TaskKey:         task.TaskKey,
-			TaskName:        task.TaskName,
-			TaskDesc:        task.TaskDesc,
-			TokenReward:     task.TokenReward,
-			DailyLimit:      task.DailyLimit,
-			IntervalSeconds: task.IntervalSeconds,
-			Repeatable:      task.Repeatable,
-			Status:          task.Status,
-			ValidFrom:       task.ValidFrom,
-			ValidTo:         task.ValidTo,
-			CanComplete:     true, // 默认可以完成
-		}
-
-		// 获取今日完成次数
-		todayCount, err := s.getUserTaskCompletionCount(ctx, userID, task.TaskID)
-		if err != nil {
-			return nil, err
-		}
-		userTaskStatus.TodayCompletedCount = todayCount
-
-		// 获取总完成次数
-		totalCount, err := s.getUserTotalTaskCompletionCount(ctx, userID, task.TaskID)
-		if err != nil {
-			return nil, err
-		}
-		userTaskStatus.TotalCompletedCount = totalCount
-
-		// 检查每日限制
-		if task.DailyLimit > 0 && todayCount >= int64(task.DailyLimit) {
-			userTaskStatus.CanComplete = false
-			userTaskStatus.CompletionReason = "今日任务完成次数已达上限"
-			// 计算下次可完成时间（明天0点）
-			tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
-			userTaskStatus.NextAvailableTime = &tomorrow
-		}
-
-		// 检查间隔时间
-		if task.IntervalSeconds > 0 {
-			lastCompletion, err := s.getLastTaskCompletion(ctx, s.db, userID, task.TaskID)
-			if err != nil {
-				return nil, err
-			}
-			if lastCompletion != nil {
-				nextAvailableTime := lastCompletion.Add(time.Duration(task.IntervalSeconds) * time.Second)
-				if now.Before(nextAvailableTime) {
-					userTaskStatus.CanComplete = false
-					userTaskStatus.CompletionReason = "任务冷却中"
-					userTaskStatus.NextAvailableTime = &nextAvailableTime
-				}
-			}
-		}
-
-		// 检查是否可重复完成
-		if task.Repeatable == 0 {
-			completed, err := s.hasCompletedTask(ctx, s.db, userID, task.TaskID)
-			if err != nil {
-				return nil, err
-			}
-			if completed {
-				userTaskStatus.CanComplete = false
-				userTaskStatus.CompletionReason = "该任务已完成且不可重复完成"
-			}
-		}
-
-		// 如果任务不能完成且没有设置下次可完成时间，设置一个默认值
-		if !userTaskStatus.CanComplete && userTaskStatus.NextAvailableTime == nil {
-			// 对于每日限制的任务，设置为明天0点
-			if task.DailyLimit > 0 && todayCount >= int64(task.DailyLimit) {
-				tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
-				userTaskStatus.NextAvailableTime = &tomorrow
-			}
-		}
-
-		result = append(result, userTaskStatus)
+		st := evaluateTaskLimits(task, stats[task.TaskID], now, loc)
+		result = append(result, &UserTaskStatus{
+			TaskID:              task.TaskID,
+			TaskKey:             task.TaskKey,
+			TaskName:            task.TaskName,
+			TaskDesc:            task.TaskDesc,
+			TokenReward:         task.TokenReward,
+			DailyLimit:          task.DailyLimit,
+			IntervalSeconds:     task.IntervalSeconds,
+			Repeatable:          task.Repeatable,
+			Status:              task.Status,
+			ValidFrom:           task.ValidFrom,
+			ValidTo:             task.ValidTo,
+			CanComplete:         st.canComplete(),
+			TodayCompletedCount: st.todayCount,
+			TotalCompletedCount: st.totalCount,
+			NextAvailableTime:   st.nextAvailableTime,
+			CompletionReason:    st.reason,
+		})
 	}
-
 	return result, nil
 }
 
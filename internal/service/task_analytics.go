@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/audit"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	taskAnalyticsDefaultDays = 30  // 未指定日期区间时统计最近多少天
+	taskAnalyticsMaxDays     = 92  // 单次最多统计的天数
+	taskAnalyticsDefaultTop  = 10  // 默认返回的排行人数
+	taskAnalyticsMaxTop      = 100 // 最多返回的排行人数
+
+	// taskExtraDataMaxBytes 上报完成时提交数据的最大长度，超出拒绝上报
+	taskExtraDataMaxBytes = 4096
+)
+
+// TaskAnalyticsRequest 任务统计请求，日期按业务时区划分，均包含当天
+type TaskAnalyticsRequest struct {
+	TaskID    int    `json:"task_id" binding:"required"`
+	StartDate string `json:"start_date"` // 开始日期，默认为结束日期前 29 天
+	EndDate   string `json:"end_date"`   // 结束日期，默认为今天
+	Top       int    `json:"top" binding:"omitempty,min=1,max=100"`
+}
+
+// TaskAnalyticsPoint 任务统计时间序列中的一天
+type TaskAnalyticsPoint struct {
+	Date string `json:"date"`
+	model.TaskCompletionTotals
+}
+
+// TaskAnalytics 任务统计结果
+type TaskAnalytics struct {
+	TaskID        int                         `json:"task_id"`
+	TaskName      string                      `json:"task_name"`
+	StartDate     string                      `json:"start_date"`
+	EndDate       string                      `json:"end_date"`
+	Timezone      string                      `json:"timezone"`
+	Totals        *model.TaskCompletionTotals `json:"totals"`         // 区间汇总，完成人数按区间去重
+	Series        []*TaskAnalyticsPoint       `json:"series"`         // 按天的时间序列，没有完成记录的日期也会返回
+	TopCompleters []*model.TaskCompleter      `json:"top_completers"` // 完成次数排行
+}
+
+// GetTaskAnalytics 统计任务在日期区间内的完成次数、完成人数、发放代币和完成次数排行
+func (s *TaskService) GetTaskAnalytics(ctx context.Context, req *TaskAnalyticsRequest) (*TaskAnalytics, error) {
+	task, err := s.GetTask(ctx, req.TaskID)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := s.businessLocation()
+	start, end, err := taskAnalyticsRange(req.StartDate, req.EndDate, time.Now(), loc)
+	if err != nil {
+		return nil, err
+	}
+	top := req.Top
+	if top <= 0 {
+		top = taskAnalyticsDefaultTop
+	}
+	if top > taskAnalyticsMaxTop {
+		top = taskAnalyticsMaxTop
+	}
+
+	// 每天的边界按时区计算，夏令时切换当天不是 24 小时
+	var bounds []time.Time
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		bounds = append(bounds, day)
+	}
+	bounds = append(bounds, end.AddDate(0, 0, 1))
+	from, to := bounds[0], bounds[len(bounds)-1]
+
+	db := s.db.WithContext(ctx)
+	totals, err := model.GetTaskCompletionTotals(db, task.TaskID, from, to)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "统计任务完成情况失败", err)
+	}
+	buckets, err := model.ListTaskCompletionBuckets(db, task.TaskID, bounds)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "统计任务完成趋势失败", err)
+	}
+	completers, err := model.ListTopTaskCompleters(db, task.TaskID, from, to, top)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "统计任务完成排行失败", err)
+	}
+
+	series := make([]*TaskAnalyticsPoint, len(bounds)-1)
+	for i := range series {
+		series[i] = &TaskAnalyticsPoint{Date: bounds[i].Format(time.DateOnly)}
+	}
+	for _, b := range buckets {
+		if b.Bucket >= 0 && b.Bucket < len(series) {
+			series[b.Bucket].TaskCompletionTotals = b.TaskCompletionTotals
+		}
+	}
+
+	return &TaskAnalytics{
+		TaskID:        task.TaskID,
+		TaskName:      task.TaskName,
+		StartDate:     start.Format(time.DateOnly),
+		EndDate:       end.Format(time.DateOnly),
+		Timezone:      loc.String(),
+		Totals:        totals,
+		Series:        series,
+		TopCompleters: completers,
+	}, nil
+}
+
+// taskAnalyticsRange 解析统计的日期区间，返回开始日期和结束日期在 loc 时区的零点
+func taskAnalyticsRange(startDate, endDate string, now time.Time, loc *time.Location) (start, end time.Time, err error) {
+	end, _ = dayRange(now, loc)
+	if endDate != "" {
+		if end, err = time.ParseInLocation(time.DateOnly, endDate, loc); err != nil {
+			return start, end, errors.New(errors.ErrCodeInvalidParams, "结束日期格式错误", err)
+		}
+	}
+	start = end.AddDate(0, 0, 1-taskAnalyticsDefaultDays)
+	if startDate != "" {
+		if start, err = time.ParseInLocation(time.DateOnly, startDate, loc); err != nil {
+			return start, end, errors.New(errors.ErrCodeInvalidParams, "开始日期格式错误", err)
+		}
+	}
+	if end.Before(start) {
+		return start, end, errors.New(errors.ErrCodeInvalidParams, "结束日期不能早于开始日期", nil)
+	}
+	if !start.AddDate(0, 0, taskAnalyticsMaxDays).After(end) {
+		return start, end, errors.New(errors.ErrCodeInvalidParams,
+			fmt.Sprintf("单次最多统计 %d 天的数据", taskAnalyticsMaxDays), nil)
+	}
+	return start, end, nil
+}
+
+// ListTaskCompletions 管理端查询任务完成记录，包含上报时提交的数据
+func (s *TaskService) ListTaskCompletions(ctx context.Context, filter *model.TaskCompletionFilter, page, limit int) ([]*model.TaskCompletionDetail, int64, error) {
+	list, total, err := model.ListTaskCompletions(s.db.WithContext(ctx), filter, page, limit)
+	if err != nil {
+		return nil, 0, errors.New(errors.ErrCodeDatabaseError, "查询任务完成记录失败", err)
+	}
+	return list, total, nil
+}
+
+// RevokeTaskCompletionResult 撤销任务完成结果
+type RevokeTaskCompletionResult struct {
+	Record    *model.TaskCompletionRecord `json:"record"`
+	Deducted  int                         `json:"deducted"`  // 实际扣回的代币数
+	Shortfall int                         `json:"shortfall"` // 余额不足未能扣回的代币数
+	Balance   int                         `json:"balance"`   // 扣回后的余额
+}
+
+// RevokeTaskCompletion 撤销一次任务完成并通过代币流水扣回奖励，余额不足时扣至 0，差额在结果中返回。
+// 撤销的完成不计入任务统计，但仍计入用户的次数限制，不能借此重复领取
+func (s *TaskService) RevokeTaskCompletion(ctx context.Context, adminID, id int64, reason string) (*RevokeTaskCompletionResult, error) {
+	var (
+		before model.TaskCompletionRecord
+		result *RevokeTaskCompletionResult
+	)
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var record model.TaskCompletionRecord
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&record, id).Error; err != nil {
+			if stderrors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New(errors.ErrCodeNotFound, "任务完成记录不存在", nil)
+			}
+			return errors.New(errors.ErrCodeDatabaseError, "查询任务完成记录失败", err)
+		}
+		if record.RevokedAt != nil {
+			return errors.New(errors.ErrCodeInvalidParams, "该任务完成已撤销", nil)
+		}
+		before = record
+
+		var user model.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", record.UserID).First(&user).Error; err != nil {
+			return errors.New(errors.ErrCodeDatabaseError, "获取用户信息失败", err)
+		}
+		deduct := record.TokenReward
+		if deduct > user.TokenBalance {
+			deduct = user.TokenBalance
+		}
+		if deduct < 0 {
+			deduct = 0
+		}
+
+		if deduct > 0 {
+			if err := model.UpdateUserTokenBalance(tx, user.UserID, -deduct); err != nil {
+				return errors.New(errors.ErrCodeInternal, "扣回任务奖励失败", err)
+			}
+			taskName := strconv.Itoa(record.TaskID)
+			if task, err := s.getTaskWithDB(ctx, tx, record.TaskID); err == nil {
+				taskName = task.TaskName
+			}
+			remark := truncateRunes(fmt.Sprintf("撤销任务完成：%s（%s）", taskName, reason), 255)
+			if err := model.CreateTokenRecord(tx, &model.TokenRecord{
+				UserID:       user.UserID,
+				ChangeAmount: -deduct,
+				BalanceAfter: user.TokenBalance - deduct,
+				ChangeType:   "TASK_REVOKE",
+				TaskID:       &record.TaskID,
+				AdminID:      &adminID,
+				Remark:       &remark,
+				ChangeTime:   time.Now(),
+			}); err != nil {
+				return errors.New(errors.ErrCodeInternal, "创建代币记录失败", err)
+			}
+		}
+
+		now := time.Now()
+		record.RevokedAt = &now
+		record.RevokedBy = &adminID
+		record.RevokeReason = &reason
+		record.RevokedTokens = deduct
+		if err := tx.Model(&model.TaskCompletionRecord{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+			"revoked_at":     now,
+			"revoked_by":     adminID,
+			"revoke_reason":  reason,
+			"revoked_tokens": deduct,
+		}).Error; err != nil {
+			return errors.New(errors.ErrCodeDatabaseError, "撤销任务完成失败", err)
+		}
+
+		result = &RevokeTaskCompletionResult{
+			Record:    &record,
+			Deducted:  deduct,
+			Shortfall: record.TokenReward - deduct,
+			Balance:   user.TokenBalance - deduct,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	audit.Record(ctx, "task_completion", strconv.FormatInt(id, 10), &before, result.Record)
+	return result, nil
+}
+
+// extraDataString 将上报完成时提交的数据转为存储格式，为空时存 NULL
+func extraDataString(extraData map[string]interface{}) (*string, error) {
+	if len(extraData) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(extraData)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "extra_data 格式错误", err)
+	}
+	if len(data) > taskExtraDataMaxBytes {
+		return nil, errors.New(errors.ErrCodeInvalidParams, fmt.Sprintf("extra_data 不能超过 %d 字节", taskExtraDataMaxBytes), nil)
+	}
+	str := string(data)
+	return &str, nil
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTaskAnalyticsRange(t *testing.T) {
+	shanghai := mustLoadLocation(t, "Asia/Shanghai")
+	now := time.Date(2026, 10, 18, 1, 0, 0, 0, shanghai)
+
+	start, end, err := taskAnalyticsRange("", "", now, shanghai)
+	if err != nil {
+		t.Fatalf("default range: %v", err)
+	}
+	if got, want := start.Format(time.DateOnly), "2026-09-19"; got != want {
+		t.Errorf("default start %s, want %s", got, want)
+	}
+	if got, want := end.Format(time.DateOnly), "2026-10-18"; got != want {
+		t.Errorf("default end %s, want %s", got, want)
+	}
+	if start.Location() != shanghai {
+		t.Errorf("range should use business timezone, got %v", start.Location())
+	}
+
+	if _, _, err := taskAnalyticsRange("2026-01-01", "2026-04-02", now, shanghai); err != nil {
+		t.Errorf("92 days should be allowed: %v", err)
+	}
+	for _, c := range [][2]string{
+		{"2026-01-01", "2026-04-03"}, // 93 天
+		{"2026-10-18", "2026-10-17"},
+		{"2026/10/01", ""},
+	} {
+		if _, _, err := taskAnalyticsRange(c[0], c[1], now, shanghai); err == nil {
+			t.Errorf("range %v: expected error", c)
+		}
+	}
+}
+
+func TestExtraDataString(t *testing.T) {
+	if v, err := extraDataString(nil); err != nil || v != nil {
+		t.Errorf("empty extra data should be stored as NULL, got %v, %v", v, err)
+	}
+	v, err := extraDataString(map[string]interface{}{"video_id": "v1", "watch_duration": 30})
+	if err != nil || v == nil || *v != `{"video_id":"v1","watch_duration":30}` {
+		t.Errorf("unexpected extra data %v, %v", v, err)
+	}
+	big := make([]byte, taskExtraDataMaxBytes)
+	if _, err := extraDataString(map[string]interface{}{"blob": string(big)}); err == nil {
+		t.Errorf("oversized extra data should be rejected")
+	}
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/errors"
+)
+
+// taskEligibilityMaxValues 参与规则中平台、登录方式、语言列表的最大长度
+const taskEligibilityMaxValues = 20
+
+// taskUserProfile 判断任务参与规则所需的用户信息
+type taskUserProfile struct {
+	RegisteredAt time.Time // 注册时间
+	Language     string    // 界面语言
+	Balance      int       // 代币余额
+	Platform     string    // 最近一次登录的平台
+	Provider     string    // 最近一次登录的方式
+	Purchased    bool      // 是否有支付成功的订单
+}
+
+// taskAudience 用户的标签和参与规则所需的信息，一次加载后用于判断多个任务
+type taskAudience struct {
+	userID  string
+	tags    map[int64]struct{}
+	profile *taskUserProfile // 没有任务设置参与规则时不加载
+}
+
+// loadTaskAudience 加载判断 tasks 参与条件所需的用户信息，查询次数与任务数量无关
+func (s *TaskService) loadTaskAudience(userID string, tasks ...*model.RewardTask) (*taskAudience, error) {
+	tags, err := s.userTagSet(userID)
+	if err != nil {
+		return nil, err
+	}
+	audience := &taskAudience{userID: userID, tags: tags}
+
+	var needLogin, needPurchase, needProfile bool
+	for _, task := range tasks {
+		rule := task.Eligibility
+		if rule.Empty() {
+			continue
+		}
+		needProfile = true
+		needLogin = needLogin || len(rule.Platforms) > 0 || len(rule.Providers) > 0
+		needPurchase = needPurchase || rule.Purchased != nil
+	}
+	if !needProfile {
+		return audience, nil
+	}
+
+	var user model.User
+	if err := s.db.Select("id", "created_at", "language", "token_balance").
+		Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "获取用户信息失败", err)
+	}
+	profile := &taskUserProfile{
+		RegisteredAt: user.CreatedAt,
+		Language:     user.Language,
+		Balance:      user.TokenBalance,
+	}
+	if needLogin {
+		login, err := model.GetLatestLoginLog(s.db, userID)
+		if err != nil {
+			return nil, errors.New(errors.ErrCodeInternal, "获取登录记录失败", err)
+		}
+		if login != nil {
+			profile.Provider = login.LoginMethod
+			if login.LoginPlatform != nil {
+				profile.Platform = *login.LoginPlatform
+			}
+		}
+	}
+	if needPurchase {
+		if profile.Purchased, err = model.HasPaidOrder(s.db, userID); err != nil {
+			return nil, errors.New(errors.ErrCodeInternal, "查询订单失败", err)
+		}
+	}
+	audience.profile = profile
+	return audience, nil
+}
+
+// eligible 用户是否满足任务的标签条件和参与规则
+func (a *taskAudience) eligible(task *model.RewardTask, now time.Time) bool {
+	if !eligibleForTask(task, a.tags) {
+		return false
+	}
+	if task.Eligibility.Empty() {
+		return true
+	}
+	return matchTaskEligibility(task.Eligibility, task.TaskKey, a.userID, a.profile, now)
+}
+
+// matchTaskEligibility 判断用户是否满足参与规则的全部条件
+func matchTaskEligibility(rule *model.TaskEligibility, taskKey, userID string, p *taskUserProfile, now time.Time) bool {
+	if p == nil {
+		return false
+	}
+	if rule.NewUserDays > 0 && !now.Before(p.RegisteredAt.Add(time.Duration(rule.NewUserDays)*24*time.Hour)) {
+		return false
+	}
+	if len(rule.Platforms) > 0 && !containsString(rule.Platforms, p.Platform) {
+		return false
+	}
+	if len(rule.Providers) > 0 && !containsString(rule.Providers, p.Provider) {
+		return false
+	}
+	if len(rule.Languages) > 0 && !containsString(rule.Languages, p.Language) {
+		return false
+	}
+	if rule.MinBalance != nil && p.Balance < *rule.MinBalance {
+		return false
+	}
+	if rule.MaxBalance != nil && p.Balance > *rule.MaxBalance {
+		return false
+	}
+	if rule.Purchased != nil && *rule.Purchased != p.Purchased {
+		return false
+	}
+	if b := rule.ABBucket; b != nil {
+		salt := b.Salt
+		if salt == "" {
+			salt = taskKey
+		}
+		bucket := abBucket(salt, userID)
+		if bucket < b.From || bucket > b.To {
+			return false
+		}
+	}
+	return true
+}
+
+// abBucket 按 FNV-1a(salt:user_id) 把用户稳定地分到 0-99 号桶
+func abBucket(salt, userID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(salt + ":" + userID))
+	return int(h.Sum32() % 100)
+}
+
+// validateEligibility 校验任务参与规则
+func validateEligibility(rule *model.TaskEligibility) error {
+	if rule == nil {
+		return nil
+	}
+	invalid := func(msg string) error {
+		return errors.New(errors.ErrCodeInvalidParams, "参与规则无效："+msg, nil)
+	}
+	if rule.NewUserDays < 0 {
+		return invalid("new_user_days 不能为负数")
+	}
+	for name, values := range map[string][]string{
+		"platforms": rule.Platforms,
+		"providers": rule.Providers,
+		"languages": rule.Languages,
+	} {
+		if len(values) > taskEligibilityMaxValues {
+			return invalid(name + " 最多 20 项")
+		}
+		for _, v := range values {
+			if v == "" || len(v) > 20 {
+				return invalid(name + " 包含空值或过长的值")
+			}
+		}
+	}
+	if rule.MinBalance != nil && rule.MaxBalance != nil && *rule.MinBalance > *rule.MaxBalance {
+		return invalid("min_balance 不能大于 max_balance")
+	}
+	if b := rule.ABBucket; b != nil {
+		if b.From < 0 || b.To > 99 || b.From > b.To {
+			return invalid("ab_bucket 的范围须在 0-99 之间且 from 不大于 to")
+		}
+		if len(b.Salt) > 50 {
+			return invalid("ab_bucket.salt 最长 50 个字符")
+		}
+	}
+	return nil
+}
+
+// eligibilityString 将参与规则转为存储格式，未设置条件时存 NULL
+func eligibilityString(rule *model.TaskEligibility) (*string, error) {
+	if rule.Empty() {
+		return nil, nil
+	}
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInternal, "序列化参与规则失败", err)
+	}
+	str := string(data)
+	return &str, nil
+}
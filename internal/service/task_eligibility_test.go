@@ -0,0 +1,74 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+)
+
+func TestMatchTaskEligibility(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	profile := &taskUserProfile{
+		RegisteredAt: now.Add(-3 * 24 * time.Hour),
+		Language:     "zh-CN",
+		Balance:      50,
+		Platform:     "ios",
+		Provider:     "wechat",
+	}
+	intPtr := func(v int) *int { return &v }
+	boolPtr := func(v bool) *bool { return &v }
+	bucket := abBucket("exp", "u1")
+
+	cases := []struct {
+		name string
+		rule model.TaskEligibility
+		want bool
+	}{
+		{"new user", model.TaskEligibility{NewUserDays: 7}, true},
+		{"no longer new", model.TaskEligibility{NewUserDays: 3}, false},
+		{"platform", model.TaskEligibility{Platforms: []string{"android", "ios"}}, true},
+		{"other platform", model.TaskEligibility{Platforms: []string{"android"}}, false},
+		{"provider", model.TaskEligibility{Providers: []string{"email"}}, false},
+		{"language", model.TaskEligibility{Languages: []string{"zh-CN"}}, true},
+		{"min balance", model.TaskEligibility{MinBalance: intPtr(51)}, false},
+		{"balance range", model.TaskEligibility{MinBalance: intPtr(50), MaxBalance: intPtr(50)}, true},
+		{"never purchased", model.TaskEligibility{Purchased: boolPtr(false)}, true},
+		{"purchased only", model.TaskEligibility{Purchased: boolPtr(true)}, false},
+		{"in bucket", model.TaskEligibility{ABBucket: &model.TaskABBucket{Salt: "exp", From: bucket, To: bucket}}, true},
+		{"all conditions", model.TaskEligibility{NewUserDays: 7, Platforms: []string{"ios"}, MaxBalance: intPtr(10)}, false},
+	}
+	for _, c := range cases {
+		if got := matchTaskEligibility(&c.rule, "task", "u1", profile, now); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	// 未指定 salt 时按任务标识分桶，同一用户的结果稳定
+	if abBucket("task", "u1") != abBucket("task", "u1") || abBucket("task", "u1") < 0 || abBucket("task", "u1") > 99 {
+		t.Errorf("unstable or out of range bucket")
+	}
+	rule := model.TaskEligibility{ABBucket: &model.TaskABBucket{From: abBucket("task", "u1"), To: abBucket("task", "u1")}}
+	if !matchTaskEligibility(&rule, "task", "u1", profile, now) {
+		t.Errorf("default salt should use task key")
+	}
+}
+
+func TestValidateEligibility(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	invalid := []model.TaskEligibility{
+		{NewUserDays: -1},
+		{Platforms: []string{""}},
+		{MinBalance: intPtr(10), MaxBalance: intPtr(5)},
+		{ABBucket: &model.TaskABBucket{From: 50, To: 100}},
+		{ABBucket: &model.TaskABBucket{From: 60, To: 40}},
+	}
+	for i := range invalid {
+		if validateEligibility(&invalid[i]) == nil {
+			t.Errorf("rule %d: expected error", i)
+		}
+	}
+	if err := validateEligibility(&model.TaskEligibility{ABBucket: &model.TaskABBucket{From: 0, To: 49}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
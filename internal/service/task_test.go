@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/config"
+	"gorm.io/gorm"
+)
+
+func TestEvaluateTaskLimits(t *testing.T) {
+	shanghai := mustLoadLocation(t, "Asia/Shanghai")
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, shanghai)
+	tomorrow := time.Date(2026, 10, 19, 0, 0, 0, 0, shanghai)
+	ago := func(d time.Duration) *time.Time {
+		v := now.Add(-d)
+		return &v
+	}
+
+	cases := []struct {
+		name   string
+		task   model.RewardTask
+		stat   *model.TaskCompletionStat
+		reason string
+		next   *time.Time
+	}{
+		{"never completed", model.RewardTask{DailyLimit: 1, Repeatable: 0}, nil, "", nil},
+		{"not repeatable", model.RewardTask{Repeatable: 0, DailyLimit: 5},
+			&model.TaskCompletionStat{TotalCount: 1, LastCompletedAt: ago(48 * time.Hour)}, taskLimitRepeat, nil},
+		{"under daily limit", model.RewardTask{Repeatable: 1, DailyLimit: 3},
+			&model.TaskCompletionStat{TotalCount: 9, TodayCount: 2, LastCompletedAt: ago(time.Hour)}, "", nil},
+		{"daily limit reached", model.RewardTask{Repeatable: 1, DailyLimit: 3},
+			&model.TaskCompletionStat{TotalCount: 9, TodayCount: 3, LastCompletedAt: ago(time.Hour)}, taskLimitDaily, &tomorrow},
+		{"cooling down", model.RewardTask{Repeatable: 1, IntervalSeconds: 600},
+			&model.TaskCompletionStat{TotalCount: 1, TodayCount: 1, LastCompletedAt: ago(5 * time.Minute)}, taskLimitCooldown, ago(-5 * time.Minute)},
+		{"cooldown over", model.RewardTask{Repeatable: 1, IntervalSeconds: 600},
+			&model.TaskCompletionStat{TotalCount: 1, TodayCount: 1, LastCompletedAt: ago(11 * time.Minute)}, "", nil},
+		// 冷却结束晚于次日零点时，下次可完成时间取较晚的冷却结束时间
+		{"daily limit and long cooldown", model.RewardTask{Repeatable: 1, DailyLimit: 1, IntervalSeconds: 86400},
+			&model.TaskCompletionStat{TotalCount: 1, TodayCount: 1, LastCompletedAt: ago(time.Hour)}, taskLimitDaily, ago(-23 * time.Hour)},
+	}
+	for _, c := range cases {
+		st := evaluateTaskLimits(&c.task, c.stat, now, shanghai)
+		if st.reason != c.reason {
+			t.Errorf("%s: reason %q, want %q", c.name, st.reason, c.reason)
+		}
+		if st.canComplete() != (c.reason == "") {
+			t.Errorf("%s: canComplete %v with reason %q", c.name, st.canComplete(), st.reason)
+		}
+		switch {
+		case c.next == nil && st.nextAvailableTime != nil:
+			t.Errorf("%s: unexpected next available time %v", c.name, st.nextAvailableTime)
+		case c.next != nil && (st.nextAvailableTime == nil || !st.nextAvailableTime.Equal(*c.next)):
+			t.Errorf("%s: next available time %v, want %v", c.name, st.nextAvailableTime, c.next)
+		}
+		if (st.err(shanghai) == nil) != (c.reason == "") {
+			t.Errorf("%s: err %v with reason %q", c.name, st.err(shanghai), st.reason)
+		}
+	}
+}
+
+func TestCreateTaskValidTo(t *testing.T) {
+	db := newDryRunDB(t)
+	var inserted map[string]interface{}
+	if err := db.Callback().Create().After("gorm:create").Register("test:record_create", func(tx *gorm.DB) {
+		inserted = map[string]interface{}{}
+		for _, field := range tx.Statement.Schema.Fields {
+			if v, zero := field.ValueOf(tx.Statement.Context, tx.Statement.ReflectValue); !zero {
+				inserted[field.DBName] = v
+			}
+		}
+	}); err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+	s := &TaskService{db: db, config: &config.Config{}, verifiers: NewTaskVerifierRegistry()}
+	s.registerBuiltinVerifiers()
+
+	interval, repeatable, status := 0, int8(1), int8(1)
+	cases := []struct {
+		name    string
+		validTo string
+		want    string
+	}{
+		{"with end date", "2026-12-31", "2026-12-31"},
+		{"without end date", "", ""},
+		{"invalid end date ignored", "2026/12/31", ""},
+	}
+	for _, c := range cases {
+		task, err := s.CreateTask(context.Background(), &CreateTaskRequest{
+			TaskName:        "每日签到",
+			TokenReward:     10,
+			DailyLimit:      1,
+			IntervalSeconds: &interval,
+			ValidFrom:       "2026-01-01",
+			ValidTo:         c.validTo,
+			Repeatable:      &repeatable,
+			Status:          &status,
+			TaskKey:         TaskKeyDailyCheckin,
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		got := ""
+		if task.ValidTo != nil {
+			got = task.ValidTo.Format(time.DateOnly)
+		}
+		if got != c.want {
+			t.Errorf("%s: valid_to = %q, want %q", c.name, got, c.want)
+		}
+		// 结束日期需随任务一并写入
+		v, saved := inserted["valid_to"]
+		if saved != (c.want != "") {
+			t.Errorf("%s: valid_to saved = %v (%v), want %v", c.name, saved, v, c.want != "")
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"time"
+	_ "time/tzdata" // 内置时区数据，运行环境缺少 zoneinfo 时也能解析用户时区
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// ResolveLocation 解析 IANA 时区名，为空或无效时返回 fallback
+func ResolveLocation(name string, fallback *time.Location) *time.Location {
+	if name == "" {
+		return fallback
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return fallback
+	}
+	return loc
+}
+
+// ValidateTimezone 校验 IANA 时区名，空字符串表示不设置
+func ValidateTimezone(name string) error {
+	if name == "" {
+		return nil
+	}
+	if len(name) > 64 {
+		return errors.New(errors.ErrCodeInvalidParams, "无效的时区："+name, nil)
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return errors.New(errors.ErrCodeInvalidParams, "无效的时区："+name, err)
+	}
+	return nil
+}
+
+// dayRange 返回 t 在 loc 时区下所在自然日的起止时间 [start, end)，夏令时切换当天可能为 23 或 25 小时
+func dayRange(t time.Time, loc *time.Location) (start, end time.Time) {
+	t = t.In(loc)
+	start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	end = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+	return start, end
+}
+
+// localDate 返回 t 在 loc 时区下的日期，格式 2006-01-02
+func localDate(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format(time.DateOnly)
+}
+
+// checkTaskValidity 按 loc 时区下的当天日期检查任务有效期，valid_from 和 valid_to 均包含当天
+func checkTaskValidity(task *model.RewardTask, now time.Time, loc *time.Location) error {
+	today := localDate(now, loc)
+	// 有效期为 DATE 列，按数据库连接时区解析，直接取日期部分比较
+	if task.ValidFrom != nil && today < task.ValidFrom.Format(time.DateOnly) {
+		return errors.New(errors.ErrCodeInvalidParams, "任务未开始", nil)
+	}
+	if task.ValidTo != nil && today > task.ValidTo.Format(time.DateOnly) {
+		return errors.New(errors.ErrCodeInvalidParams, "任务已结束", nil)
+	}
+	return nil
+}
+
+// businessLocation 业务时区，用户未设置时区时按该时区划分自然日
+func (s *TaskService) businessLocation() *time.Location {
+	if s.config == nil {
+		return time.Local
+	}
+	return ResolveLocation(s.config.Locale.Timezone, time.Local)
+}
+
+// userLocation 用户时区，未设置或无效时使用业务时区
+func (s *TaskService) userLocation(db *gorm.DB, userID string) *time.Location {
+	var user model.User
+	if err := db.Select("timezone").Where("id = ?", userID).First(&user).Error; err != nil || user.Timezone == nil {
+		return s.businessLocation()
+	}
+	return ResolveLocation(*user.Timezone, s.businessLocation())
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("load location %s: %v", name, err)
+	}
+	return loc
+}
+
+func TestDayRangeAcrossDST(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+	london := mustLoadLocation(t, "Europe/London")
+
+	cases := []struct {
+		name string
+		loc  *time.Location
+		at   time.Time
+		want time.Duration
+	}{
+		{"new york spring forward", newYork, time.Date(2026, 3, 8, 12, 0, 0, 0, newYork), 23 * time.Hour},
+		{"new york fall back", newYork, time.Date(2026, 11, 1, 12, 0, 0, 0, newYork), 25 * time.Hour},
+		{"new york regular day", newYork, time.Date(2026, 11, 2, 12, 0, 0, 0, newYork), 24 * time.Hour},
+		{"london spring forward", london, time.Date(2026, 3, 29, 12, 0, 0, 0, london), 23 * time.Hour},
+		{"london fall back", london, time.Date(2026, 10, 25, 12, 0, 0, 0, london), 25 * time.Hour},
+	}
+	for _, c := range cases {
+		start, end := dayRange(c.at, c.loc)
+		if got := end.Sub(start); got != c.want {
+			t.Errorf("%s: day length %v, want %v", c.name, got, c.want)
+		}
+		if start.In(c.loc).Hour() != 0 || end.In(c.loc).Hour() != 0 {
+			t.Errorf("%s: range %v - %v does not start at local midnight", c.name, start, end)
+		}
+	}
+
+	// 回拨当天 23:30 仍属于当天，次日 00:30 属于次日
+	start, end := dayRange(time.Date(2026, 11, 1, 12, 0, 0, 0, newYork), newYork)
+	lateNight := time.Date(2026, 11, 1, 23, 30, 0, 0, newYork)
+	if lateNight.Before(start) || !lateNight.Before(end) {
+		t.Errorf("23:30 on fall-back day is outside %v - %v", start, end)
+	}
+	nextDay := time.Date(2026, 11, 2, 0, 30, 0, 0, newYork)
+	if nextDay.Before(end) {
+		t.Errorf("00:30 on the next day is inside %v - %v", start, end)
+	}
+}
+
+func TestDailyBoundaryPerUserTimezone(t *testing.T) {
+	shanghai := mustLoadLocation(t, "Asia/Shanghai")
+	losAngeles := mustLoadLocation(t, "America/Los_Angeles")
+
+	// 上海已是 10-18 下午，洛杉矶仍是 10-17 深夜，两地用户的当天范围不同
+	now := time.Date(2026, 10, 18, 6, 59, 0, 0, time.UTC)
+	if got := localDate(now, shanghai); got != "2026-10-18" {
+		t.Errorf("shanghai date %s", got)
+	}
+	if got := localDate(now, losAngeles); got != "2026-10-17" {
+		t.Errorf("los angeles date %s", got)
+	}
+
+	// 洛杉矶用户的每日次数在当地午夜重置，而不是上海午夜（洛杉矶下午）
+	_, laEnd := dayRange(now, losAngeles)
+	if want := time.Date(2026, 10, 18, 7, 0, 0, 0, time.UTC); !laEnd.Equal(want) {
+		t.Errorf("los angeles day ends at %v, want %v", laEnd.UTC(), want)
+	}
+	shStart, _ := dayRange(now, shanghai)
+	if want := time.Date(2026, 10, 17, 16, 0, 0, 0, time.UTC); !shStart.Equal(want) {
+		t.Errorf("shanghai day starts at %v, want %v", shStart.UTC(), want)
+	}
+}
+
+func TestCheckTaskValidityUsesUserTimezone(t *testing.T) {
+	shanghai := mustLoadLocation(t, "Asia/Shanghai")
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	// 有效期为 DATE 列，数据库连接按服务器时区解析为当天零点
+	from := time.Date(2026, 10, 18, 0, 0, 0, 0, time.Local)
+	to := time.Date(2026, 10, 18, 0, 0, 0, 0, time.Local)
+	task := &model.RewardTask{ValidFrom: &from, ValidTo: &to}
+
+	// UTC 10-18 20:00：上海已是 10-19，纽约仍是 10-18
+	now := time.Date(2026, 10, 18, 20, 0, 0, 0, time.UTC)
+	if err := checkTaskValidity(task, now, shanghai); err == nil {
+		t.Error("task should have ended for a shanghai user")
+	}
+	if err := checkTaskValidity(task, now, newYork); err != nil {
+		t.Errorf("task should still be valid for a new york user: %v", err)
+	}
+
+	// UTC 10-17 20:00：上海已是 10-18，纽约仍是 10-17
+	now = time.Date(2026, 10, 17, 20, 0, 0, 0, time.UTC)
+	if err := checkTaskValidity(task, now, shanghai); err != nil {
+		t.Errorf("task should have started for a shanghai user: %v", err)
+	}
+	if err := checkTaskValidity(task, now, newYork); err == nil {
+		t.Error("task should not have started for a new york user")
+	}
+}
+
+func TestCheckinDateAcrossDST(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	// 回拨当天有 25 小时，相隔 24 小时的两次签到仍是同一天
+	first := time.Date(2026, 11, 1, 4, 30, 0, 0, time.UTC)  // 00:30 EDT
+	second := time.Date(2026, 11, 2, 4, 30, 0, 0, time.UTC) // 23:30 EST
+	if a, b := checkinDate(first, newYork), checkinDate(second, newYork); !a.Equal(b) {
+		t.Errorf("check-ins 24h apart on fall-back day map to %v and %v", a, b)
+	}
+
+	// 拨快当天只有 23 小时，相隔 23 小时的两次签到分属相邻两天
+	first = time.Date(2026, 3, 8, 5, 30, 0, 0, time.UTC)  // 00:30 EST
+	second = time.Date(2026, 3, 9, 4, 30, 0, 0, time.UTC) // 00:30 EDT
+	a, b := checkinDate(first, newYork), checkinDate(second, newYork)
+	if a.Format(time.DateOnly) != "2026-03-08" || b.Format(time.DateOnly) != "2026-03-09" {
+		t.Errorf("spring-forward check-ins map to %v and %v", a, b)
+	}
+}
+
+func TestResolveLocation(t *testing.T) {
+	fallback := mustLoadLocation(t, "Asia/Shanghai")
+	if got := ResolveLocation("", fallback); got != fallback {
+		t.Errorf("empty name resolved to %v", got)
+	}
+	if got := ResolveLocation("Mars/Olympus_Mons", fallback); got != fallback {
+		t.Errorf("invalid name resolved to %v", got)
+	}
+	if got := ResolveLocation("Europe/Berlin", fallback); got.String() != "Europe/Berlin" {
+		t.Errorf("valid name resolved to %v", got)
+	}
+	if err := ValidateTimezone("Mars/Olympus_Mons"); err == nil {
+		t.Error("invalid timezone accepted")
+	}
+}
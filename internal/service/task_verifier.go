@@ -0,0 +1,393 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/logs"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 内置任务校验器对应的 TaskKey，形如 watch_video:ad_2 的 TaskKey 按冒号前的部分选择校验器
+const (
+	TaskKeyDailyCheckin    = "daily_checkin"    // 每日签到
+	TaskKeyWatchVideo      = "watch_video"      // 观看视频
+	TaskKeyShare           = "share"            // 分享
+	TaskKeyCompleteProfile = "complete_profile" // 完善资料
+	TaskKeyFirstPurchase   = "first_purchase"   // 首次购买
+	TaskKeyServerProof     = "server_proof"     // 可信后端签名证明
+	TaskKeyRewardedAd      = "rewarded_ad"      // 激励视频广告，只能由广告平台服务端回调完成
+	TaskKeyCheckinStreak   = "checkin_streak"   // 连续签到，通过签到接口完成
+)
+
+// 初始化数据中任务使用的 TaskKey
+const (
+	taskKeyLegacyDailyLogin = "daily_login_task"
+	taskKeyLegacyShare      = "share_task"
+)
+
+// TaskVerifyInput 任务完成校验的输入
+type TaskVerifyInput struct {
+	Tx        *gorm.DB               // 完成任务所在的事务
+	UserID    string                 // 上报的用户
+	Task      *model.RewardTask      // 任务
+	ExtraData map[string]interface{} // 客户端上报的完成数据
+
+	undo []func() // 事务回滚时需要撤销的事务外操作
+}
+
+// OnRollback 登记完成任务的事务回滚时需要撤销的事务外操作，如已占用的 nonce
+func (in *TaskVerifyInput) OnRollback(fn func()) {
+	in.undo = append(in.undo, fn)
+}
+
+// rollback 按登记的相反顺序撤销事务外操作
+func (in *TaskVerifyInput) rollback() {
+	for i := len(in.undo) - 1; i >= 0; i-- {
+		in.undo[i]()
+	}
+}
+
+// TaskVerifier 任务完成校验器，按任务的 TaskKey 选择
+type TaskVerifier interface {
+	// ValidateConfig 校验任务的校验配置，创建和更新任务时调用，raw 为空表示使用默认配置
+	ValidateConfig(raw json.RawMessage) error
+	// Verify 校验用户上报的完成数据，不通过时返回错误
+	Verify(ctx context.Context, in *TaskVerifyInput) error
+}
+
+// TaskVerifierRegistry 任务校验器注册表
+type TaskVerifierRegistry struct {
+	mu        sync.RWMutex
+	verifiers map[string]TaskVerifier
+}
+
+// NewTaskVerifierRegistry 创建任务校验器注册表
+func NewTaskVerifierRegistry() *TaskVerifierRegistry {
+	return &TaskVerifierRegistry{verifiers: make(map[string]TaskVerifier)}
+}
+
+// Register 为 TaskKey 注册校验器，已存在时覆盖
+func (r *TaskVerifierRegistry) Register(taskKey string, v TaskVerifier) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.verifiers[taskKey] = v
+}
+
+// Lookup 按 TaskKey 查找校验器，没有完全匹配时按冒号前的部分查找
+func (r *TaskVerifierRegistry) Lookup(taskKey string) (TaskVerifier, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if v, ok := r.verifiers[taskKey]; ok {
+		return v, true
+	}
+	if prefix, _, found := strings.Cut(taskKey, ":"); found {
+		v, ok := r.verifiers[prefix]
+		return v, ok
+	}
+	return nil, false
+}
+
+// typedTaskVerifier 将任务的校验配置解析为具体类型 C 后再校验
+type typedTaskVerifier[C any] struct {
+	validate func(cfg *C) error
+	verify   func(ctx context.Context, in *TaskVerifyInput, cfg *C) error
+}
+
+// parse 解析校验配置，不允许未知字段
+func (v typedTaskVerifier[C]) parse(raw json.RawMessage) (*C, error) {
+	cfg := new(C)
+	if len(raw) > 0 && string(raw) != "null" {
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(cfg); err != nil {
+			return nil, errors.New(errors.ErrCodeInvalidParams, "任务校验配置格式错误", err)
+		}
+	}
+	if v.validate != nil {
+		if err := v.validate(cfg); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+func (v typedTaskVerifier[C]) ValidateConfig(raw json.RawMessage) error {
+	_, err := v.parse(raw)
+	return err
+}
+
+func (v typedTaskVerifier[C]) Verify(ctx context.Context, in *TaskVerifyInput) error {
+	cfg, err := v.parse(in.Task.VerifyConfig)
+	if err != nil {
+		return errors.New(errors.ErrCodeInternal, "任务校验配置无效", err)
+	}
+	return v.verify(ctx, in, cfg)
+}
+
+// checkinConfig 每日签到没有额外配置，次数由任务的每日上限和间隔控制
+type checkinConfig struct{}
+
+// watchVideoConfig 观看视频校验配置
+type watchVideoConfig struct {
+	MinSeconds float64  `json:"min_seconds"` // 最短观看秒数，默认 15
+	VideoIDs   []string `json:"video_ids"`   // 允许的视频ID，为空表示不限
+}
+
+// shareConfig 分享校验配置
+type shareConfig struct {
+	Channels []string `json:"channels"` // 允许的分享渠道，为空表示不限
+}
+
+// completeProfileConfig 完善资料校验配置
+type completeProfileConfig struct {
+	Fields []string `json:"fields"` // 需要填写的资料：nickname/avatar_url/phone/email（需已验证），默认昵称和头像
+}
+
+// firstPurchaseConfig 首次购买校验配置
+type firstPurchaseConfig struct {
+	MinAmount float64 `json:"min_amount"` // 订单金额下限（元），0 表示不限
+}
+
+// checkinStreakConfig 连续签到配置
+type checkinStreakConfig struct {
+	Rewards    []int  `json:"rewards"`     // 连续签到第 N 天的奖励，签满一轮后从第 1 天重新开始，默认 10/20/30/40/50/60/100
+	MakeupCost int    `json:"makeup_cost"` // 补签一天消耗的代币，0 表示不允许补签
+	MakeupDays int    `json:"makeup_days"` // 可补签最近多少天，默认 7
+	Timezone   string `json:"timezone"`    // 划分自然日的时区，如 Asia/Shanghai，默认按用户时区
+}
+
+// rewardedAdConfig 激励视频广告没有额外配置
+type rewardedAdConfig struct{}
+
+// serverProofConfig 可信后端签名证明校验配置
+type serverProofConfig struct {
+	KeyID string `json:"key_id"` // 签名密钥，对应配置文件 taskVerify.proofKeys 中的 key_id
+}
+
+// profileFields 完善资料任务可检查的字段
+var profileFields = map[string]func(u *model.User) bool{
+	"nickname":   func(u *model.User) bool { return u.Nickname != nil && strings.TrimSpace(*u.Nickname) != "" },
+	"avatar_url": func(u *model.User) bool { return u.AvatarURL != nil && strings.TrimSpace(*u.AvatarURL) != "" },
+	"phone":      func(u *model.User) bool { return u.Phone != nil && *u.Phone != "" },
+	"email":      func(u *model.User) bool { return u.Email != nil && *u.Email != "" && u.EmailVerifiedAt != nil },
+}
+
+// registerBuiltinVerifiers 注册内置任务校验器
+func (s *TaskService) registerBuiltinVerifiers() {
+	checkin := typedTaskVerifier[checkinConfig]{
+		verify: func(ctx context.Context, in *TaskVerifyInput, cfg *checkinConfig) error { return nil },
+	}
+	s.verifiers.Register(TaskKeyDailyCheckin, checkin)
+	s.verifiers.Register(taskKeyLegacyDailyLogin, checkin)
+
+	s.verifiers.Register(TaskKeyWatchVideo, typedTaskVerifier[watchVideoConfig]{
+		validate: func(cfg *watchVideoConfig) error {
+			if cfg.MinSeconds < 0 {
+				return errors.New(errors.ErrCodeInvalidParams, "最短观看秒数不能为负数", nil)
+			}
+			if cfg.MinSeconds == 0 {
+				cfg.MinSeconds = 15
+			}
+			return nil
+		},
+		verify: verifyWatchVideo,
+	})
+
+	share := typedTaskVerifier[shareConfig]{verify: verifyShare}
+	s.verifiers.Register(TaskKeyShare, share)
+	s.verifiers.Register(taskKeyLegacyShare, share)
+
+	s.verifiers.Register(TaskKeyCompleteProfile, typedTaskVerifier[completeProfileConfig]{
+		validate: func(cfg *completeProfileConfig) error {
+			if len(cfg.Fields) == 0 {
+				cfg.Fields = []string{"nickname", "avatar_url"}
+			}
+			for _, f := range cfg.Fields {
+				if _, ok := profileFields[f]; !ok {
+					return errors.New(errors.ErrCodeInvalidParams, "不支持的资料字段："+f, nil)
+				}
+			}
+			return nil
+		},
+		verify: verifyCompleteProfile,
+	})
+
+	s.verifiers.Register(TaskKeyFirstPurchase, typedTaskVerifier[firstPurchaseConfig]{
+		validate: func(cfg *firstPurchaseConfig) error {
+			if cfg.MinAmount < 0 {
+				return errors.New(errors.ErrCodeInvalidParams, "订单金额下限不能为负数", nil)
+			}
+			return nil
+		},
+		verify: verifyFirstPurchase,
+	})
+
+	s.verifiers.Register(TaskKeyServerProof, typedTaskVerifier[serverProofConfig]{
+		validate: func(cfg *serverProofConfig) error {
+			if _, ok := s.config.TaskVerify.ProofKeys[cfg.KeyID]; !ok {
+				return errors.New(errors.ErrCodeInvalidParams, "签名密钥未配置："+cfg.KeyID, nil)
+			}
+			return nil
+		},
+		verify: s.verifyServerProof,
+	})
+
+	s.verifiers.Register(TaskKeyCheckinStreak, checkinStreakVerifier)
+
+	// 激励视频奖励由广告平台回调经 GrantVerifiedCompletion 发放，客户端上报一律拒绝
+	s.verifiers.Register(TaskKeyRewardedAd, typedTaskVerifier[rewardedAdConfig]{
+		verify: func(ctx context.Context, in *TaskVerifyInput, cfg *rewardedAdConfig) error {
+			return errors.New(errors.ErrCodeTaskVerifyFailed, "激励视频奖励由广告平台回调发放，不能直接上报", nil)
+		},
+	})
+}
+
+// RegisterVerifier 为 TaskKey 注册自定义任务校验器
+func (s *TaskService) RegisterVerifier(taskKey string, v TaskVerifier) {
+	s.verifiers.Register(taskKey, v)
+}
+
+// validateVerifyConfig 创建和更新任务时校验 TaskKey 是否有对应的校验器及其配置
+func (s *TaskService) validateVerifyConfig(taskKey string, raw json.RawMessage) error {
+	v, ok := s.verifiers.Lookup(taskKey)
+	if !ok {
+		return errors.New(errors.ErrCodeInvalidParams, "任务标识没有对应的完成校验器："+taskKey, nil)
+	}
+	return v.ValidateConfig(raw)
+}
+
+// verifyWatchVideo 校验视频ID和观看时长
+func verifyWatchVideo(ctx context.Context, in *TaskVerifyInput, cfg *watchVideoConfig) error {
+	videoID, _ := in.ExtraData["video_id"].(string)
+	if videoID == "" {
+		return errors.New(errors.ErrCodeTaskVerifyFailed, "缺少视频ID", nil)
+	}
+	if len(cfg.VideoIDs) > 0 && !containsString(cfg.VideoIDs, videoID) {
+		return errors.New(errors.ErrCodeTaskVerifyFailed, "该视频不参与任务", nil)
+	}
+	duration, ok := in.ExtraData["watch_duration"].(float64)
+	if !ok {
+		return errors.New(errors.ErrCodeTaskVerifyFailed, "缺少观看时长", nil)
+	}
+	if duration < cfg.MinSeconds {
+		return errors.New(errors.ErrCodeTaskVerifyFailed,
+			fmt.Sprintf("观看时长不足 %v 秒", cfg.MinSeconds), nil)
+	}
+	return nil
+}
+
+// verifyShare 校验分享渠道
+func verifyShare(ctx context.Context, in *TaskVerifyInput, cfg *shareConfig) error {
+	channel, _ := in.ExtraData["channel"].(string)
+	if channel == "" {
+		return errors.New(errors.ErrCodeTaskVerifyFailed, "缺少分享渠道", nil)
+	}
+	if len(cfg.Channels) > 0 && !containsString(cfg.Channels, channel) {
+		return errors.New(errors.ErrCodeTaskVerifyFailed, "不支持的分享渠道", nil)
+	}
+	return nil
+}
+
+// verifyCompleteProfile 校验用户资料是否已实际填写
+func verifyCompleteProfile(ctx context.Context, in *TaskVerifyInput, cfg *completeProfileConfig) error {
+	var user model.User
+	if err := in.Tx.Where("id = ?", in.UserID).First(&user).Error; err != nil {
+		return errors.New(errors.ErrCodeDatabaseError, "获取用户信息失败", err)
+	}
+	for _, f := range cfg.Fields {
+		if !profileFields[f](&user) {
+			return errors.New(errors.ErrCodeTaskVerifyFailed, "资料尚未完善："+f, nil)
+		}
+	}
+	return nil
+}
+
+// verifyFirstPurchase 校验用户是否有支付成功的订单
+func verifyFirstPurchase(ctx context.Context, in *TaskVerifyInput, cfg *firstPurchaseConfig) error {
+	var count int64
+	if err := in.Tx.Model(&model.Order{}).
+		Where("user_id = ? AND status IN ? AND amount >= ?", in.UserID,
+			[]model.OrderStatus{model.OrderStatusPaid, model.OrderStatusCompleted}, cfg.MinAmount).
+		Count(&count).Error; err != nil {
+		return errors.New(errors.ErrCodeDatabaseError, "查询订单失败", err)
+	}
+	if count == 0 {
+		return errors.New(errors.ErrCodeTaskVerifyFailed, "尚未完成购买", nil)
+	}
+	return nil
+}
+
+// ServerProofPayload 可信后端签名的内容：task_key、user_id、timestamp、nonce 以换行连接
+func ServerProofPayload(taskKey, userID string, timestamp int64, nonce string) string {
+	return fmt.Sprintf("%s\n%s\n%d\n%s", taskKey, userID, timestamp, nonce)
+}
+
+// SignServerProof 使用密钥对证明内容做 HMAC-SHA256 签名，返回十六进制字符串
+func SignServerProof(secret, taskKey, userID string, timestamp int64, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ServerProofPayload(taskKey, userID, timestamp, nonce)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyServerProof 校验可信后端签发的完成证明，extra_data 需包含 timestamp（秒）、nonce 和 signature；
+// 超过有效期或 nonce 已使用时拒绝
+func (s *TaskService) verifyServerProof(ctx context.Context, in *TaskVerifyInput, cfg *serverProofConfig) error {
+	secret := s.config.TaskVerify.ProofKeys[cfg.KeyID]
+	if secret == "" {
+		return errors.New(errors.ErrCodeInternal, "签名密钥未配置", nil)
+	}
+	ts, ok := in.ExtraData["timestamp"].(float64)
+	nonce, _ := in.ExtraData["nonce"].(string)
+	signature, _ := in.ExtraData["signature"].(string)
+	if !ok || nonce == "" || signature == "" || len(nonce) > 64 {
+		return errors.New(errors.ErrCodeTaskVerifyFailed, "缺少完成证明", nil)
+	}
+	timestamp := int64(ts)
+	maxAge := s.config.TaskVerify.ProofMaxAge
+	if math.Abs(float64(time.Now().Unix()-timestamp)) > maxAge.Seconds() {
+		return errors.New(errors.ErrCodeTaskVerifyFailed, "完成证明已过期", nil)
+	}
+	expected := SignServerProof(secret, in.Task.TaskKey, in.UserID, timestamp, nonce)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return errors.New(errors.ErrCodeTaskVerifyFailed, "完成证明无效", nil)
+	}
+	// nonce 在有效期前后都可能被重放，保留两倍有效期
+	key := fmt.Sprintf("task:proof:nonce:%s:%s", cfg.KeyID, nonce)
+	fresh, err := s.redis.SetNX(ctx, key, in.UserID, 2*maxAge).Result()
+	if err != nil {
+		return errors.New(errors.ErrCodeRedisError, "校验完成证明失败", err)
+	}
+	if !fresh {
+		return errors.New(errors.ErrCodeTaskVerifyFailed, "完成证明已使用", nil)
+	}
+	// 先占用 nonce 防止并发重放，完成任务的事务回滚时释放，证明仍可重新提交
+	in.OnRollback(func() {
+		if err := s.redis.Del(context.Background(), key).Err(); err != nil {
+			logs.Business().Warn("释放完成证明 nonce 失败", zap.String("key", key), zap.Error(err))
+		}
+	})
+	return nil
+}
+
+// containsString 判断字符串是否在列表中
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/config"
+)
+
+func newTestVerifierService(t *testing.T) *TaskService {
+	t.Helper()
+	client, _ := newFakeRedisClient(t)
+	cfg := &config.Config{}
+	cfg.TaskVerify.ProofKeys = map[string]string{"partner": "proof-secret"}
+	cfg.TaskVerify.ProofMaxAge = 5 * time.Minute
+	s := &TaskService{redis: client, config: cfg, verifiers: NewTaskVerifierRegistry()}
+	s.registerBuiltinVerifiers()
+	return s
+}
+
+func TestTaskVerifierLookup(t *testing.T) {
+	s := newTestVerifierService(t)
+	cases := []struct {
+		taskKey string
+		found   bool
+	}{
+		{TaskKeyWatchVideo, true},
+		{"watch_video:ad_2", true},
+		{taskKeyLegacyShare, true},
+		{"share_task:wechat", true},
+		{"unknown", false},
+		{"unknown:watch_video", false},
+	}
+	for _, c := range cases {
+		if _, ok := s.verifiers.Lookup(c.taskKey); ok != c.found {
+			t.Errorf("Lookup(%q) found = %v, want %v", c.taskKey, ok, c.found)
+		}
+	}
+}
+
+func TestValidateVerifyConfig(t *testing.T) {
+	s := newTestVerifierService(t)
+	cases := []struct {
+		name    string
+		taskKey string
+		raw     string
+		ok      bool
+	}{
+		{"default config", TaskKeyWatchVideo, "", true},
+		{"null config", TaskKeyWatchVideo, "null", true},
+		{"valid config", TaskKeyWatchVideo, `{"min_seconds":30,"video_ids":["v1"]}`, true},
+		{"negative seconds", TaskKeyWatchVideo, `{"min_seconds":-1}`, false},
+		{"unknown field", TaskKeyWatchVideo, `{"min_second":30}`, false},
+		{"unsupported profile field", TaskKeyCompleteProfile, `{"fields":["birthday"]}`, false},
+		{"configured proof key", TaskKeyServerProof, `{"key_id":"partner"}`, true},
+		{"unknown proof key", TaskKeyServerProof, `{"key_id":"other"}`, false},
+		{"no verifier", "unknown", "", false},
+	}
+	for _, c := range cases {
+		err := s.validateVerifyConfig(c.taskKey, json.RawMessage(c.raw))
+		if (err == nil) != c.ok {
+			t.Errorf("%s: got %v, want ok=%v", c.name, err, c.ok)
+		}
+	}
+}
+
+func TestVerifyWatchVideoAndShare(t *testing.T) {
+	video := &watchVideoConfig{MinSeconds: 15, VideoIDs: []string{"v1"}}
+	share := &shareConfig{Channels: []string{"wechat"}}
+	cases := []struct {
+		name  string
+		check func(in *TaskVerifyInput) error
+		extra map[string]interface{}
+		ok    bool
+	}{
+		{"video watched", bindConfig(verifyWatchVideo, video), map[string]interface{}{"video_id": "v1", "watch_duration": 15.0}, true},
+		{"video too short", bindConfig(verifyWatchVideo, video), map[string]interface{}{"video_id": "v1", "watch_duration": 14.9}, false},
+		{"video not listed", bindConfig(verifyWatchVideo, video), map[string]interface{}{"video_id": "v2", "watch_duration": 60.0}, false},
+		{"video duration missing", bindConfig(verifyWatchVideo, video), map[string]interface{}{"video_id": "v1"}, false},
+		{"share allowed", bindConfig(verifyShare, share), map[string]interface{}{"channel": "wechat"}, true},
+		{"share channel not allowed", bindConfig(verifyShare, share), map[string]interface{}{"channel": "qq"}, false},
+		{"share channel missing", bindConfig(verifyShare, share), map[string]interface{}{}, false},
+	}
+	for _, c := range cases {
+		err := c.check(&TaskVerifyInput{ExtraData: c.extra})
+		if (err == nil) != c.ok {
+			t.Errorf("%s: got %v, want ok=%v", c.name, err, c.ok)
+		}
+	}
+}
+
+// bindConfig 把校验函数绑定到配置上，便于表驱动测试
+func bindConfig[C any](fn func(context.Context, *TaskVerifyInput, *C) error, cfg *C) func(*TaskVerifyInput) error {
+	return func(in *TaskVerifyInput) error { return fn(context.Background(), in, cfg) }
+}
+
+func TestVerifyServerProof(t *testing.T) {
+	s := newTestVerifierService(t)
+	ctx := context.Background()
+	cfg := &serverProofConfig{KeyID: "partner"}
+	task := &model.RewardTask{TaskKey: TaskKeyServerProof}
+	now := time.Now().Unix()
+
+	proof := func(userID string, ts int64, nonce, secret string) *TaskVerifyInput {
+		return &TaskVerifyInput{UserID: "u1", Task: task, ExtraData: map[string]interface{}{
+			"timestamp": float64(ts),
+			"nonce":     nonce,
+			"signature": SignServerProof(secret, task.TaskKey, userID, ts, nonce),
+		}}
+	}
+
+	cases := []struct {
+		name string
+		in   *TaskVerifyInput
+		ok   bool
+	}{
+		{"valid proof", proof("u1", now, "n1", "proof-secret"), true},
+		{"nonce reused", proof("u1", now, "n1", "proof-secret"), false},
+		{"signed for another user", proof("u2", now, "n2", "proof-secret"), false},
+		{"wrong secret", proof("u1", now, "n3", "other-secret"), false},
+		{"expired", proof("u1", now-600, "n4", "proof-secret"), false},
+		{"from the future", proof("u1", now+600, "n5", "proof-secret"), false},
+		{"missing nonce", proof("u1", now, "", "proof-secret"), false},
+	}
+	for _, c := range cases {
+		err := s.verifyServerProof(ctx, c.in, cfg)
+		if (err == nil) != c.ok {
+			t.Errorf("%s: got %v, want ok=%v", c.name, err, c.ok)
+		}
+	}
+
+	// 完成任务的事务回滚后释放 nonce，同一证明可以重新提交
+	in := proof("u1", now, "n6", "proof-secret")
+	if err := s.verifyServerProof(ctx, in, cfg); err != nil {
+		t.Fatalf("first submit: %v", err)
+	}
+	in.rollback()
+	if err := s.verifyServerProof(ctx, proof("u1", now, "n6", "proof-secret"), cfg); err != nil {
+		t.Errorf("resubmit after rollback: %v", err)
+	}
+}
@@ -17,14 +17,35 @@ func NewTokenRecordService(db *gorm.DB) *TokenRecordService {
 	return &TokenRecordService{db: db}
 }
 
-// ListTokenRecordsRequest 获取代币记录请求
-type ListTokenRecordsRequest struct {
+// TokenRecordFilter 代币记录筛选条件，列表与导出共用
+type TokenRecordFilter struct {
 	UserID     string     `json:"user_id"`
 	ChangeType string     `json:"change_type"`
 	StartTime  *time.Time `json:"start_time,omitempty"`
 	EndTime    *time.Time `json:"end_time,omitempty"`
-	PageNum    int        `json:"page" binding:"required,min=1"`
-	PageSize   int        `json:"limit" binding:"required,min=1,max=100"`
+}
+
+func (f *TokenRecordFilter) apply(db *gorm.DB) *gorm.DB {
+	if f.UserID != "" {
+		db = db.Where("user_id = ?", f.UserID)
+	}
+	if f.ChangeType != "" {
+		db = db.Where("change_type = ?", f.ChangeType)
+	}
+	if f.StartTime != nil {
+		db = db.Where("change_time >= ?", f.StartTime)
+	}
+	if f.EndTime != nil {
+		db = db.Where("change_time <= ?", f.EndTime)
+	}
+	return db
+}
+
+// ListTokenRecordsRequest 获取代币记录请求
+type ListTokenRecordsRequest struct {
+	TokenRecordFilter
+	PageNum  int `json:"page" binding:"required,min=1"`
+	PageSize int `json:"limit" binding:"required,min=1,max=100"`
 }
 
 // ListTokenRecordsResponse 代币记录列表响应
@@ -36,25 +57,7 @@ type ListTokenRecordsResponse struct {
 // ListTokenRecords 获取用户代币记录列表
 func (s *TokenRecordService) ListTokenRecords(ctx context.Context, req *ListTokenRecordsRequest) (*ListTokenRecordsResponse, error) {
 	// 构建查询
-	query := s.db.Model(&model.TokenRecord{})
-
-	// 添加用户ID过滤
-	if req.UserID != "" {
-		query = query.Where("user_id = ?", req.UserID)
-	}
-
-	// 添加变动类型过滤
-	if req.ChangeType != "" {
-		query = query.Where("change_type = ?", req.ChangeType)
-	}
-
-	// 添加时间范围过滤
-	if req.StartTime != nil {
-		query = query.Where("created_at >= ?", req.StartTime)
-	}
-	if req.EndTime != nil {
-		query = query.Where("created_at <= ?", req.EndTime)
-	}
+	query := req.TokenRecordFilter.apply(s.db.Model(&model.TokenRecord{}))
 
 	// 获取总数
 	var total int64
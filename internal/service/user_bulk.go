@@ -0,0 +1,431 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/audit"
+	"github.com/reusedev/uportal-api/pkg/config"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/logs"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 批量操作的目标用户来源
+const (
+	BulkSourceFilter = "filter" // 按用户列表的筛选条件
+	BulkSourceIDs    = "ids"    // 上传或传入的用户ID列表
+)
+
+const (
+	// bulkPollInterval 批量任务协程检查新任务的间隔
+	bulkPollInterval = 3 * time.Second
+	// bulkCleanInterval 检查无进度任务的间隔
+	bulkCleanInterval = time.Minute
+	// bulkDefaultRemark 代币调整未填写备注时写入代币记录的备注
+	bulkDefaultRemark = "批量调整代币"
+)
+
+// bulkOperationPermissions 各批量操作需要的权限，与单个用户操作的接口一致
+var bulkOperationPermissions = map[string]string{
+	model.BulkOpGrantTokens: model.PermTokensAdjust,
+	model.BulkOpDisable:     model.PermUsersWrite,
+	model.BulkOpEnable:      model.PermUsersWrite,
+}
+
+// CreateBulkJobRequest 创建批量操作任务请求，UserIDs 与 Filter 二选一
+type CreateBulkJobRequest struct {
+	Operation string      // 操作类型
+	Amount    int         // 代币增减数量，负数为扣减，仅 tokens_grant
+	Remark    string      // 备注，写入代币记录
+	DryRun    bool        // 试运行
+	UserIDs   []string    // 目标用户ID列表
+	Filter    *UserFilter // 目标用户筛选条件
+}
+
+// BulkService 用户批量操作服务，任务由后台协程逐个用户执行，每个用户的结果单独记录
+type BulkService struct {
+	db     *gorm.DB
+	config *config.Config
+	roles  *RoleService
+}
+
+// NewBulkService 创建用户批量操作服务
+func NewBulkService(db *gorm.DB, cfg *config.Config, roles *RoleService) *BulkService {
+	return &BulkService{db: db, config: cfg, roles: roles}
+}
+
+// CreateJob 校验操作权限并创建批量任务，目标用户在创建时确定，后续新增的用户不受影响
+func (s *BulkService) CreateJob(ctx context.Context, adminID int64, req *CreateBulkJobRequest) (*model.UserBulkJob, error) {
+	perm, ok := bulkOperationPermissions[req.Operation]
+	if !ok {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "不支持的批量操作", nil)
+	}
+	if err := s.roles.CheckPermission(ctx, adminID, perm); err != nil {
+		return nil, err
+	}
+	if req.Operation == model.BulkOpGrantTokens && req.Amount == 0 {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "代币调整数量不能为0", nil)
+	}
+
+	job := &model.UserBulkJob{
+		AdminID:   adminID,
+		Operation: req.Operation,
+		DryRun:    req.DryRun,
+		Status:    model.BulkJobStatusPending,
+	}
+	if req.Operation == model.BulkOpGrantTokens {
+		job.Amount = req.Amount
+		remark := req.Remark
+		if remark == "" {
+			remark = bulkDefaultRemark
+		}
+		job.Remark = &remark
+	}
+
+	var userIDs []string
+	switch {
+	case len(req.UserIDs) > 0:
+		job.Source = BulkSourceIDs
+		userIDs = dedupeUserIDs(req.UserIDs)
+	case req.Filter != nil:
+		job.Source = BulkSourceFilter
+		raw, err := json.Marshal(req.Filter)
+		if err != nil {
+			return nil, errors.New(errors.ErrCodeInvalidParams, "无效的筛选条件", err)
+		}
+		filter := string(raw)
+		job.Filter = &filter
+		if userIDs, err = s.filterUserIDs(ctx, req.Filter); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New(errors.ErrCodeInvalidParams, "请指定用户ID列表或筛选条件", nil)
+	}
+	if len(userIDs) == 0 {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "没有需要处理的用户", nil)
+	}
+	if len(userIDs) > s.config.Bulk.MaxUsers {
+		return nil, errors.New(errors.ErrCodeInvalidParams,
+			fmt.Sprintf("单个批量任务最多处理 %d 个用户，请缩小范围", s.config.Bulk.MaxUsers), nil)
+	}
+
+	if err := model.CreateUserBulkJob(s.db.WithContext(ctx), job, userIDs); err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "创建批量任务失败", err)
+	}
+	audit.Record(ctx, "user_bulk_job", strconv.FormatInt(job.ID, 10), nil, job)
+	return job, nil
+}
+
+// filterUserIDs 按筛选条件取出目标用户ID，超出上限时多取一个以便调用方判断
+func (s *BulkService) filterUserIDs(ctx context.Context, filter *UserFilter) ([]string, error) {
+	var ids []string
+	err := filter.apply(s.db.WithContext(ctx).Model(&model.User{})).
+		Order("id ASC").
+		Limit(s.config.Bulk.MaxUsers+1).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "查询目标用户失败", err)
+	}
+	return ids, nil
+}
+
+// GetJob 获取批量任务及进度
+func (s *BulkService) GetJob(ctx context.Context, id int64) (*model.UserBulkJob, error) {
+	job, err := model.GetUserBulkJob(s.db.WithContext(ctx), id)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New(errors.ErrCodeNotFound, "批量任务不存在", nil)
+		}
+		return nil, errors.New(errors.ErrCodeDatabaseError, "查询批量任务失败", err)
+	}
+	return job, nil
+}
+
+// ListJobs 分页获取批量任务
+func (s *BulkService) ListJobs(ctx context.Context, page, limit int) ([]*model.UserBulkJob, int64, error) {
+	list, total, err := model.ListUserBulkJobs(s.db.WithContext(ctx), page, limit)
+	if err != nil {
+		return nil, 0, errors.New(errors.ErrCodeDatabaseError, "获取批量任务失败", err)
+	}
+	return list, total, nil
+}
+
+// ListItems 分页获取批量任务的逐个用户结果
+func (s *BulkService) ListItems(ctx context.Context, jobID int64, result string, page, limit int) ([]*model.UserBulkJobItem, int64, error) {
+	if _, err := s.GetJob(ctx, jobID); err != nil {
+		return nil, 0, err
+	}
+	list, total, err := model.ListUserBulkJobItems(s.db.WithContext(ctx), jobID, result, page, limit)
+	if err != nil {
+		return nil, 0, errors.New(errors.ErrCodeDatabaseError, "获取批量任务结果失败", err)
+	}
+	return list, total, nil
+}
+
+// CancelJob 取消排队中或执行中的任务，需具备该操作类型的权限，已处理的用户不回滚
+func (s *BulkService) CancelJob(ctx context.Context, adminID, id int64) (*model.UserBulkJob, error) {
+	before, err := s.GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if perm, ok := bulkOperationPermissions[before.Operation]; ok {
+		if err := s.roles.CheckPermission(ctx, adminID, perm); err != nil {
+			return nil, err
+		}
+	}
+	ok, err := model.UpdateUserBulkJobStatus(s.db.WithContext(ctx), id,
+		[]string{model.BulkJobStatusPending, model.BulkJobStatusRunning},
+		map[string]interface{}{"status": model.BulkJobStatusCancelled, "finished_at": time.Now()})
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "取消批量任务失败", err)
+	}
+	if !ok {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "任务已结束，无法取消", nil)
+	}
+	after, _ := model.GetUserBulkJob(s.db.WithContext(ctx), id)
+	audit.Record(ctx, "user_bulk_job", strconv.FormatInt(id, 10), before, after)
+	return after, nil
+}
+
+// Start 启动批量任务协程和无进度任务的检查协程
+func (s *BulkService) Start(ctx context.Context) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	for i := 0; i < s.config.Bulk.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.runWorker(ctx)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.runCleaner(ctx)
+	}()
+	return &wg
+}
+
+// runWorker 轮询并执行排队中的批量任务
+func (s *BulkService) runWorker(ctx context.Context) {
+	ticker := time.NewTicker(bulkPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for ctx.Err() == nil {
+			job, err := model.ClaimUserBulkJob(s.db)
+			if err != nil {
+				logs.Business().Warn("领取批量任务失败", zap.Error(err))
+				break
+			}
+			if job == nil {
+				break
+			}
+			s.runJob(ctx, job)
+		}
+	}
+}
+
+// runJob 分批处理任务中待处理的用户，每批结束后更新进度并检查任务是否已取消；
+// 服务退出时任务保持执行中状态，由检查协程重新排队后从未处理的用户继续
+func (s *BulkService) runJob(ctx context.Context, job *model.UserBulkJob) {
+	log := logs.Business().With(zap.Int64("job_id", job.ID), zap.String("operation", job.Operation))
+	for ctx.Err() == nil {
+		items, err := model.ListPendingBulkJobItems(s.db, job.ID, s.config.Bulk.BatchSize)
+		if err != nil {
+			log.Error("查询待处理用户失败", zap.Error(err))
+			return
+		}
+		if len(items) == 0 {
+			break
+		}
+		for _, item := range items {
+			if err := s.processItem(job, item); err != nil && !stderrors.Is(err, errBulkItemProcessed) {
+				log.Error("处理批量任务用户失败", zap.String("user_id", item.UserID), zap.Error(err))
+				s.failJob(job, "处理用户时数据库异常")
+				return
+			}
+		}
+		if err := model.RefreshUserBulkJobProgress(s.db, job.ID); err != nil {
+			log.Warn("更新批量任务进度失败", zap.Error(err))
+		}
+		current, err := model.GetUserBulkJob(s.db, job.ID)
+		if err != nil {
+			log.Warn("查询批量任务状态失败", zap.Error(err))
+			continue
+		}
+		if current.Status != model.BulkJobStatusRunning {
+			log.Info("批量任务已停止", zap.String("status", current.Status))
+			return
+		}
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	if err := model.RefreshUserBulkJobProgress(s.db, job.ID); err != nil {
+		log.Warn("更新批量任务进度失败", zap.Error(err))
+	}
+	if _, err := model.UpdateUserBulkJobStatus(s.db, job.ID, []string{model.BulkJobStatusRunning},
+		map[string]interface{}{"status": model.BulkJobStatusSuccess, "finished_at": time.Now()}); err != nil {
+		log.Error("更新批量任务状态失败", zap.Error(err))
+	}
+}
+
+// failJob 将任务标记为异常终止
+func (s *BulkService) failJob(job *model.UserBulkJob, reason string) {
+	if err := model.RefreshUserBulkJobProgress(s.db, job.ID); err != nil {
+		logs.Business().Warn("更新批量任务进度失败", zap.Int64("job_id", job.ID), zap.Error(err))
+	}
+	if _, err := model.UpdateUserBulkJobStatus(s.db, job.ID, []string{model.BulkJobStatusRunning},
+		map[string]interface{}{
+			"status":      model.BulkJobStatusFailed,
+			"error":       truncateRunes(reason, 255),
+			"finished_at": time.Now(),
+		}); err != nil {
+		logs.Business().Error("更新批量任务状态失败", zap.Int64("job_id", job.ID), zap.Error(err))
+	}
+}
+
+// processItem 在单独的事务中处理一个用户，用户的变更与结果记录同时提交，
+// 任务中断后重新执行不会重复处理；返回的错误表示数据库异常，业务上的失败记录在结果中
+func (s *BulkService) processItem(job *model.UserBulkJob, item *model.UserBulkJobItem) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var user model.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", item.UserID).First(&user).Error
+		switch {
+		case stderrors.Is(err, gorm.ErrRecordNotFound):
+			return finishItem(tx, item, model.BulkItemFailed, "用户不存在")
+		case err != nil:
+			return err
+		}
+
+		switch job.Operation {
+		case model.BulkOpGrantTokens:
+			return s.grantTokens(tx, job, item, &user)
+		case model.BulkOpDisable, model.BulkOpEnable:
+			return s.setStatus(tx, job, item, &user)
+		default:
+			return finishItem(tx, item, model.BulkItemFailed, "不支持的批量操作")
+		}
+	})
+}
+
+// grantTokens 按差额调整代币并写入代币记录，扣减后余额不能为负
+func (s *BulkService) grantTokens(tx *gorm.DB, job *model.UserBulkJob, item *model.UserBulkJobItem, user *model.User) error {
+	before := user.TokenBalance
+	after := before + job.Amount
+	item.BalanceBefore = &before
+	item.BalanceAfter = &after
+	if after < 0 {
+		return finishItem(tx, item, model.BulkItemFailed, "代币余额不足")
+	}
+	if !job.DryRun {
+		if err := tx.Model(&model.User{}).Where("id = ?", user.UserID).
+			Updates(map[string]interface{}{"token_balance": after, "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+		adminID := job.AdminID
+		record := &model.TokenRecord{
+			UserID:       user.UserID,
+			AdminID:      &adminID,
+			ChangeAmount: job.Amount,
+			BalanceAfter: after,
+			ChangeType:   "ADJUST",
+			Remark:       job.Remark,
+			ChangeTime:   time.Now(),
+		}
+		if err := model.CreateTokenRecord(tx, record); err != nil {
+			return err
+		}
+		item.RecordID = &record.RecordID
+	}
+	return finishItem(tx, item, model.BulkItemSuccess, "")
+}
+
+// setStatus 启用或禁用账号，状态未变化时跳过
+func (s *BulkService) setStatus(tx *gorm.DB, job *model.UserBulkJob, item *model.UserBulkJobItem, user *model.User) error {
+	status := int8(1)
+	if job.Operation == model.BulkOpDisable {
+		status = 0
+	}
+	if user.Status == status {
+		return finishItem(tx, item, model.BulkItemSkipped, "账号状态未变化")
+	}
+	if !job.DryRun {
+		if err := tx.Model(&model.User{}).Where("id = ?", user.UserID).
+			Updates(map[string]interface{}{"status": status, "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+	}
+	return finishItem(tx, item, model.BulkItemSuccess, "")
+}
+
+// finishItem 记录用户的处理结果，结果已被其他协程记录时回滚本次变更
+func finishItem(tx *gorm.DB, item *model.UserBulkJobItem, result, message string) error {
+	item.Result = result
+	if message != "" {
+		item.Message = &message
+	}
+	ok, err := model.FinishBulkJobItem(tx, item)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errBulkItemProcessed
+	}
+	return nil
+}
+
+// errBulkItemProcessed 用户已被其他协程处理，回滚本次事务
+var errBulkItemProcessed = stderrors.New("bulk job item already processed")
+
+// runCleaner 定期将长时间没有进度的执行中任务重新排队
+func (s *BulkService) runCleaner(ctx context.Context) {
+	ticker := time.NewTicker(bulkCleanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		n, err := model.RequeueStaleUserBulkJobs(s.db, time.Now().Add(-s.config.Bulk.StaleTimeout))
+		if err != nil {
+			logs.Business().Warn("重新排队批量任务失败", zap.Error(err))
+			continue
+		}
+		if n > 0 {
+			logs.Business().Warn("批量任务长时间无进度，已重新排队", zap.Int64("count", n))
+		}
+	}
+}
+
+// dedupeUserIDs 去掉空值和重复的用户ID，保持原有顺序
+func dedupeUserIDs(ids []string) []string {
+	seen := make(map[string]struct{}, len(ids))
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		result = append(result, id)
+	}
+	return result
+}
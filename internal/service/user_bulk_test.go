@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"reflect"
+	"testing"
+
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// newRecordingDB 在试运行 GORM 上记录写入的表，写操作均视为影响一行
+func newRecordingDB(t *testing.T) (*gorm.DB, *[]string) {
+	t.Helper()
+	db := newDryRunDB(t)
+	var tables []string
+	record := func(tx *gorm.DB) {
+		tables = append(tables, tx.Statement.Table)
+		tx.RowsAffected = 1
+	}
+	if err := db.Callback().Create().After("gorm:create").Register("test:record_create", record); err != nil {
+		t.Fatalf("register create callback: %v", err)
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("test:record_update", record); err != nil {
+		t.Fatalf("register update callback: %v", err)
+	}
+	return db, &tables
+}
+
+func TestBulkOperationPermissions(t *testing.T) {
+	cases := []struct {
+		operation string
+		want      string
+	}{
+		{model.BulkOpGrantTokens, model.PermTokensAdjust},
+		{model.BulkOpDisable, model.PermUsersWrite},
+		{model.BulkOpEnable, model.PermUsersWrite},
+	}
+	for _, c := range cases {
+		if got := bulkOperationPermissions[c.operation]; got != c.want {
+			t.Errorf("%s requires %q, want %q", c.operation, got, c.want)
+		}
+	}
+
+	// 不支持的操作在检查权限前就被拒绝
+	s := &BulkService{}
+	_, err := s.CreateJob(context.Background(), 1, &CreateBulkJobRequest{Operation: "delete", UserIDs: []string{"u1"}})
+	var bizErr *errors.Error
+	if !stderrors.As(err, &bizErr) || bizErr.Code != errors.ErrCodeInvalidParams {
+		t.Errorf("unsupported operation returned %v", err)
+	}
+}
+
+func TestBulkDryRunDoesNotWrite(t *testing.T) {
+	cases := []struct {
+		name      string
+		operation string
+		amount    int
+		dryRun    bool
+		balance   int
+		status    int8
+		result    string
+		tables    []string
+		after     int
+	}{
+		{"grant dry run", model.BulkOpGrantTokens, 100, true, 50, 1, model.BulkItemSuccess,
+			[]string{"user_bulk_job_items"}, 150},
+		{"grant", model.BulkOpGrantTokens, 100, false, 50, 1, model.BulkItemSuccess,
+			[]string{"users", "token_records", "user_bulk_job_items"}, 150},
+		{"deduct beyond balance dry run", model.BulkOpGrantTokens, -80, true, 50, 1, model.BulkItemFailed,
+			[]string{"user_bulk_job_items"}, -30},
+		{"deduct beyond balance", model.BulkOpGrantTokens, -80, false, 50, 1, model.BulkItemFailed,
+			[]string{"user_bulk_job_items"}, -30},
+		{"disable dry run", model.BulkOpDisable, 0, true, 0, 1, model.BulkItemSuccess,
+			[]string{"user_bulk_job_items"}, 0},
+		{"disable", model.BulkOpDisable, 0, false, 0, 1, model.BulkItemSuccess,
+			[]string{"users", "user_bulk_job_items"}, 0},
+		{"disable already disabled", model.BulkOpDisable, 0, false, 0, 0, model.BulkItemSkipped,
+			[]string{"user_bulk_job_items"}, 0},
+		{"enable dry run", model.BulkOpEnable, 0, true, 0, 0, model.BulkItemSuccess,
+			[]string{"user_bulk_job_items"}, 0},
+	}
+	s := &BulkService{}
+	for _, c := range cases {
+		db, tables := newRecordingDB(t)
+		remark := bulkDefaultRemark
+		job := &model.UserBulkJob{AdminID: 1, Operation: c.operation, Amount: c.amount, DryRun: c.dryRun, Remark: &remark}
+		item := &model.UserBulkJobItem{ID: 1, JobID: 1, UserID: "u1", Result: model.BulkItemPending}
+		user := &model.User{UserID: "u1", TokenBalance: c.balance, Status: c.status}
+
+		var err error
+		if c.operation == model.BulkOpGrantTokens {
+			err = s.grantTokens(db, job, item, user)
+		} else {
+			err = s.setStatus(db, job, item, user)
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if item.Result != c.result {
+			t.Errorf("%s: result %q, want %q", c.name, item.Result, c.result)
+		}
+		if !reflect.DeepEqual(*tables, c.tables) {
+			t.Errorf("%s: wrote %v, want %v", c.name, *tables, c.tables)
+		}
+		if c.operation == model.BulkOpGrantTokens && (item.BalanceAfter == nil || *item.BalanceAfter != c.after) {
+			t.Errorf("%s: balance after %v, want %d", c.name, item.BalanceAfter, c.after)
+		}
+	}
+}
+
+func TestDedupeUserIDs(t *testing.T) {
+	got := dedupeUserIDs([]string{"b", "", "a", "b", "c", "a"})
+	if want := []string{"b", "a", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dedupeUserIDs = %v, want %v", got, want)
+	}
+}
@@ -17,13 +17,31 @@ func NewUserLoginLogService(db *gorm.DB) *UserLoginLogService {
 	return &UserLoginLogService{db: db}
 }
 
+// LoginLogFilter 登录日志筛选条件，列表与导出共用
+type LoginLogFilter struct {
+	UserID    string     `json:"user_id"`
+	StartTime *time.Time `json:"start_time,omitempty"`
+	EndTime   *time.Time `json:"end_time,omitempty"`
+}
+
+func (f *LoginLogFilter) apply(db *gorm.DB) *gorm.DB {
+	if f.UserID != "" {
+		db = db.Where("user_id = ?", f.UserID)
+	}
+	if f.StartTime != nil {
+		db = db.Where("login_time >= ?", f.StartTime)
+	}
+	if f.EndTime != nil {
+		db = db.Where("login_time <= ?", f.EndTime)
+	}
+	return db
+}
+
 // ListUserLoginLogsRequest 获取登录日志请求
 type ListUserLoginLogsRequest struct {
-	UserID    string     `form:"user_id" binding:"required"`
-	StartTime *time.Time `form:"start_time" binding:"required"`
-	EndTime   *time.Time `form:"end_time" binding:"required"`
-	PageNum   int        `form:"page_num" binding:"required,min=1"`
-	PageSize  int        `form:"page_size" binding:"required,min=1,max=100"`
+	LoginLogFilter
+	PageNum  int `form:"page_num" binding:"required,min=1"`
+	PageSize int `form:"page_size" binding:"required,min=1,max=100"`
 }
 
 // ListUserLoginLogsResponse 登录日志列表响应
@@ -35,17 +53,8 @@ type ListUserLoginLogsResponse struct {
 // ListUserLoginLogs 获取用户登录日志列表
 func (s *UserLoginLogService) ListUserLoginLogs(ctx context.Context, req *ListUserLoginLogsRequest) (*ListUserLoginLogsResponse, error) {
 	// 构建查询
-	query := s.db.Model(&model.UserLoginLog{})
+	query := req.LoginLogFilter.apply(s.db.Model(&model.UserLoginLog{}))
 
-	if req.UserID != "" {
-		query = query.Where("user_id = ?", req.UserID)
-	}
-	if req.StartTime != nil {
-		query = query.Where("start_time >= ?", req.StartTime)
-	}
-	if req.EndTime != nil {
-		query = query.Where("end_time <= ?", req.EndTime)
-	}
 	// 获取总数
 	var total int64
 	if err := query.Count(&total).Error; err != nil {
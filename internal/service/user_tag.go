@@ -0,0 +1,491 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/reusedev/uportal-api/internal/model"
+	"github.com/reusedev/uportal-api/pkg/audit"
+	"github.com/reusedev/uportal-api/pkg/config"
+	"github.com/reusedev/uportal-api/pkg/errors"
+	"github.com/reusedev/uportal-api/pkg/logs"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// userTagLockKey 多实例部署时同一时刻只允许一个实例重算规则标签
+	userTagLockKey = "user_tag:recompute:lock"
+	// userTagLockTTL 重算锁的最长持有时间，防止实例异常退出后锁不释放
+	userTagLockTTL = 30 * time.Minute
+	// userTagMaxConditions 单个规则标签最多的条件数
+	userTagMaxConditions = 5
+)
+
+// userTagNegatedOps 比较方式的取反，统计值为0也满足条件时按取反条件排除用户
+var userTagNegatedOps = map[string]string{
+	">":  "<=",
+	">=": "<",
+	"<":  ">=",
+	"<=": ">",
+	"=":  "<>",
+}
+
+// UserTagRequest 创建或更新用户标签请求
+type UserTagRequest struct {
+	Code        string             `json:"code" binding:"required,max=50"`            // 标签编码，创建后不可修改
+	Name        string             `json:"name" binding:"required,max=50"`            // 标签名称
+	Description string             `json:"description" binding:"max=255"`             // 标签说明
+	Type        string             `json:"type" binding:"required,oneof=manual rule"` // 标签类型，创建后不可修改
+	Rule        *model.UserTagRule `json:"rule"`                                      // 标签规则，仅规则标签
+}
+
+// UserTagService 用户标签服务，规则标签定期按业务数据重算，手动标签由管理员维护
+type UserTagService struct {
+	db     *gorm.DB
+	redis  *redis.Client
+	config *config.Config
+}
+
+// NewUserTagService 创建用户标签服务
+func NewUserTagService(db *gorm.DB, redis *redis.Client, cfg *config.Config) *UserTagService {
+	return &UserTagService{db: db, redis: redis, config: cfg}
+}
+
+// CreateTag 创建用户标签，规则标签创建后立即计算一次
+func (s *UserTagService) CreateTag(ctx context.Context, req *UserTagRequest) (*model.UserTag, error) {
+	tag := &model.UserTag{
+		Code: req.Code,
+		Name: req.Name,
+		Type: req.Type,
+	}
+	if req.Description != "" {
+		tag.Description = &req.Description
+	}
+	if err := s.setRule(tag, req.Rule); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&model.UserTag{}).Where("code = ?", req.Code).Count(&count).Error; err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "检查标签编码失败", err)
+	}
+	if count > 0 {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "标签编码已存在", nil)
+	}
+	if err := model.CreateUserTag(s.db.WithContext(ctx), tag); err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "创建标签失败", err)
+	}
+
+	if tag.Type == model.TagTypeRule {
+		if err := s.recompute(ctx, tag); err != nil {
+			return nil, err
+		}
+	}
+	after, _ := model.GetUserTag(s.db.WithContext(ctx), tag.ID)
+	audit.Record(ctx, "user_tag", strconv.FormatInt(tag.ID, 10), nil, after)
+	if after != nil {
+		return after, nil
+	}
+	return tag, nil
+}
+
+// UpdateTag 更新标签名称、说明和规则，规则变更后立即重算
+func (s *UserTagService) UpdateTag(ctx context.Context, id int64, req *UserTagRequest) (*model.UserTag, error) {
+	before, err := s.GetTag(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Code != before.Code || req.Type != before.Type {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "标签编码和类型不可修改", nil)
+	}
+
+	tag := *before
+	if err := s.setRule(&tag, req.Rule); err != nil {
+		return nil, err
+	}
+	updates := map[string]interface{}{
+		"name":        req.Name,
+		"description": nil,
+		"rule":        tag.RuleJSON,
+	}
+	if req.Description != "" {
+		updates["description"] = req.Description
+	}
+	if err := model.UpdateUserTag(s.db.WithContext(ctx), id, updates); err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "更新标签失败", err)
+	}
+
+	if tag.Type == model.TagTypeRule {
+		if err := s.recompute(ctx, &tag); err != nil {
+			return nil, err
+		}
+	}
+	after, _ := model.GetUserTag(s.db.WithContext(ctx), id)
+	audit.Record(ctx, "user_tag", strconv.FormatInt(id, 10), before, after)
+	return after, nil
+}
+
+// DeleteTag 删除标签，同时移除用户关联和任务参与条件
+func (s *UserTagService) DeleteTag(ctx context.Context, id int64) error {
+	before, err := s.GetTag(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := model.DeleteUserTag(s.db.WithContext(ctx), id); err != nil {
+		return errors.New(errors.ErrCodeDatabaseError, "删除标签失败", err)
+	}
+	audit.Record(ctx, "user_tag", strconv.FormatInt(id, 10), before, nil)
+	return nil
+}
+
+// GetTag 获取标签详情
+func (s *UserTagService) GetTag(ctx context.Context, id int64) (*model.UserTag, error) {
+	tag, err := model.GetUserTag(s.db.WithContext(ctx), id)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New(errors.ErrCodeNotFound, "标签不存在", nil)
+		}
+		return nil, errors.New(errors.ErrCodeDatabaseError, "查询标签失败", err)
+	}
+	return tag, nil
+}
+
+// ListTags 获取标签列表，typ 为空时返回全部
+func (s *UserTagService) ListTags(ctx context.Context, typ string) ([]*model.UserTag, error) {
+	tags, err := model.ListUserTags(s.db.WithContext(ctx), typ)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "获取标签列表失败", err)
+	}
+	return tags, nil
+}
+
+// ListMembers 分页获取标签下的用户
+func (s *UserTagService) ListMembers(ctx context.Context, tagID int64, page, limit int) ([]*model.UserTagMember, int64, error) {
+	if _, err := s.GetTag(ctx, tagID); err != nil {
+		return nil, 0, err
+	}
+	list, total, err := model.ListUserTagMembers(s.db.WithContext(ctx), tagID, page, limit)
+	if err != nil {
+		return nil, 0, errors.New(errors.ErrCodeDatabaseError, "获取标签用户失败", err)
+	}
+	return list, total, nil
+}
+
+// AddMembers 为用户打上手动标签，不存在的用户忽略，返回实际处理的用户数
+func (s *UserTagService) AddMembers(ctx context.Context, tagID int64, userIDs []string) (int, error) {
+	tag, err := s.manualTag(ctx, tagID)
+	if err != nil {
+		return 0, err
+	}
+	var existing []string
+	if err := s.db.WithContext(ctx).Model(&model.User{}).
+		Where("id IN ?", dedupeUserIDs(userIDs)).
+		Pluck("id", &existing).Error; err != nil {
+		return 0, errors.New(errors.ErrCodeDatabaseError, "查询用户失败", err)
+	}
+	if err := model.AddUserTagMembers(s.db.WithContext(ctx), tag.ID, existing); err != nil {
+		return 0, errors.New(errors.ErrCodeDatabaseError, "添加标签用户失败", err)
+	}
+	if err := model.RefreshUserTagMemberCount(s.db.WithContext(ctx), tag.ID); err != nil {
+		logs.Business().Warn("更新标签用户数失败", zap.Int64("tag_id", tag.ID), zap.Error(err))
+	}
+	audit.Record(ctx, "user_tag", strconv.FormatInt(tag.ID, 10), nil, map[string]interface{}{"added": existing})
+	return len(existing), nil
+}
+
+// RemoveMembers 移除用户的手动标签
+func (s *UserTagService) RemoveMembers(ctx context.Context, tagID int64, userIDs []string) error {
+	tag, err := s.manualTag(ctx, tagID)
+	if err != nil {
+		return err
+	}
+	userIDs = dedupeUserIDs(userIDs)
+	if err := model.RemoveUserTagMembers(s.db.WithContext(ctx), tag.ID, userIDs); err != nil {
+		return errors.New(errors.ErrCodeDatabaseError, "移除标签用户失败", err)
+	}
+	if err := model.RefreshUserTagMemberCount(s.db.WithContext(ctx), tag.ID); err != nil {
+		logs.Business().Warn("更新标签用户数失败", zap.Int64("tag_id", tag.ID), zap.Error(err))
+	}
+	audit.Record(ctx, "user_tag", strconv.FormatInt(tag.ID, 10), map[string]interface{}{"removed": userIDs}, nil)
+	return nil
+}
+
+// UserTags 获取用户拥有的标签
+func (s *UserTagService) UserTags(ctx context.Context, userID string) ([]*model.UserTag, error) {
+	tags, err := model.ListUserTagsByUser(s.db.WithContext(ctx), userID)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeDatabaseError, "获取用户标签失败", err)
+	}
+	return tags, nil
+}
+
+// RecomputeTag 立即重算规则标签
+func (s *UserTagService) RecomputeTag(ctx context.Context, id int64) (*model.UserTag, error) {
+	tag, err := s.GetTag(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tag.Type != model.TagTypeRule {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "只有规则标签可以重算", nil)
+	}
+	if err := s.recompute(ctx, tag); err != nil {
+		return nil, err
+	}
+	return s.GetTag(ctx, id)
+}
+
+// Start 启动规则标签定时重算
+func (s *UserTagService) Start(ctx context.Context) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(s.config.UserTag.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.recomputeAll(ctx)
+			}
+		}
+	}()
+	return &wg
+}
+
+// recomputeAll 加锁后依次重算全部规则标签
+func (s *UserTagService) recomputeAll(ctx context.Context) {
+	if s.redis != nil {
+		locked, err := s.redis.SetNX(ctx, userTagLockKey, 1, userTagLockTTL).Result()
+		if err != nil || !locked {
+			return
+		}
+		defer s.redis.Del(context.Background(), userTagLockKey)
+	}
+
+	tags, err := model.ListUserTags(s.db.WithContext(ctx), model.TagTypeRule)
+	if err != nil {
+		logs.Business().Error("查询规则标签失败", zap.Error(err))
+		return
+	}
+	for _, tag := range tags {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.recompute(ctx, tag); err != nil {
+			logs.Business().Error("重算规则标签失败", zap.Int64("tag_id", tag.ID), zap.String("code", tag.Code), zap.Error(err))
+		}
+	}
+}
+
+// recompute 按规则计算标签用户，与现有用户比较后只增删有变化的部分，保留已有用户的打标时间
+func (s *UserTagService) recompute(ctx context.Context, tag *model.UserTag) error {
+	if tag.Rule == nil || len(tag.Rule.Conditions) == 0 {
+		return errors.New(errors.ErrCodeInvalidParams, "规则标签缺少规则", nil)
+	}
+	db := s.db.WithContext(ctx)
+	query := db.Model(&model.User{})
+	for _, cond := range tag.Rule.Conditions {
+		var err error
+		if query, err = applyTagCondition(db, query, cond); err != nil {
+			return err
+		}
+	}
+	var matched []string
+	if err := query.Pluck("id", &matched).Error; err != nil {
+		return errors.New(errors.ErrCodeDatabaseError, "计算标签用户失败", err)
+	}
+	current, err := model.ListUserTagMemberIDs(db, tag.ID)
+	if err != nil {
+		return errors.New(errors.ErrCodeDatabaseError, "查询标签用户失败", err)
+	}
+
+	matchedSet := make(map[string]struct{}, len(matched))
+	for _, id := range matched {
+		matchedSet[id] = struct{}{}
+	}
+	currentSet := make(map[string]struct{}, len(current))
+	var removed []string
+	for _, id := range current {
+		currentSet[id] = struct{}{}
+		if _, ok := matchedSet[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	var added []string
+	for _, id := range matched {
+		if _, ok := currentSet[id]; !ok {
+			added = append(added, id)
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for start := 0; start < len(removed); start += 1000 {
+			end := min(start+1000, len(removed))
+			if err := model.RemoveUserTagMembers(tx, tag.ID, removed[start:end]); err != nil {
+				return err
+			}
+		}
+		if err := model.AddUserTagMembers(tx, tag.ID, added); err != nil {
+			return err
+		}
+		return model.UpdateUserTag(tx, tag.ID, map[string]interface{}{
+			"member_count": len(matched),
+			"computed_at":  time.Now(),
+		})
+	})
+	if err != nil {
+		return errors.New(errors.ErrCodeDatabaseError, "更新标签用户失败", err)
+	}
+	return nil
+}
+
+// applyTagCondition 将单个条件转换为用户ID子查询；统计值为0也满足条件时（如消耗代币 < 100），
+// 没有相关记录的用户同样命中，此时改为排除不满足条件的用户
+func applyTagCondition(db, query *gorm.DB, cond model.UserTagCondition) (*gorm.DB, error) {
+	if cond.Metric == model.TagMetricProvider {
+		sub := db.Model(&model.UserAuth{}).Select("user_id").Where("provider = ?", cond.Provider)
+		return query.Where("id IN (?)", sub), nil
+	}
+
+	var since *time.Time
+	if cond.Days > 0 {
+		t := truncateDay(time.Now()).AddDate(0, 0, -cond.Days+1)
+		since = &t
+	}
+	op := cond.Op
+	negate := compareZero(op, cond.Value)
+	if negate {
+		op = userTagNegatedOps[op]
+	}
+
+	var sub *gorm.DB
+	switch cond.Metric {
+	case model.TagMetricTokensSpent:
+		sub = db.Model(&model.TokenRecord{}).Select("user_id").Where("change_amount < 0")
+		if since != nil {
+			sub = sub.Where("change_time >= ?", *since)
+		}
+		sub = sub.Group("user_id").Having("SUM(-change_amount) "+op+" ?", cond.Value)
+	case model.TagMetricTokensEarned:
+		sub = db.Model(&model.TokenRecord{}).Select("user_id").Where("change_amount > 0")
+		if since != nil {
+			sub = sub.Where("change_time >= ?", *since)
+		}
+		sub = sub.Group("user_id").Having("SUM(change_amount) "+op+" ?", cond.Value)
+	case model.TagMetricRechargeAmount:
+		sub = db.Model(&model.RechargeOrder{}).Select("user_id").Where("status = 1")
+		if since != nil {
+			sub = sub.Where("paid_at >= ?", *since)
+		}
+		sub = sub.Group("user_id").Having("SUM(amount_paid) "+op+" ?", cond.Value)
+	case model.TagMetricInvites:
+		sub = db.Model(&model.InviteRecord{}).Select("inviter_id")
+		if since != nil {
+			sub = sub.Where("created_at >= ?", *since)
+		}
+		sub = sub.Group("inviter_id").Having("COUNT(*) "+op+" ?", cond.Value)
+	case model.TagMetricTaskCompletion:
+		sub = db.Model(&model.TaskCompletionRecord{}).Select("user_id")
+		if since != nil {
+			sub = sub.Where("completed_at >= ?", *since)
+		}
+		sub = sub.Group("user_id").Having("COUNT(*) "+op+" ?", cond.Value)
+	default:
+		return nil, errors.New(errors.ErrCodeInvalidParams, "不支持的标签指标："+cond.Metric, nil)
+	}
+
+	if negate {
+		return query.Where("id NOT IN (?)", sub), nil
+	}
+	return query.Where("id IN (?)", sub), nil
+}
+
+// compareZero 判断统计值为0时是否满足条件
+func compareZero(op string, value float64) bool {
+	switch op {
+	case ">":
+		return 0 > value
+	case ">=":
+		return 0 >= value
+	case "<":
+		return 0 < value
+	case "<=":
+		return 0 <= value
+	case "=":
+		return value == 0
+	}
+	return false
+}
+
+// setRule 校验并设置标签规则，手动标签不能带规则
+func (s *UserTagService) setRule(tag *model.UserTag, rule *model.UserTagRule) error {
+	if tag.Type == model.TagTypeManual {
+		if rule != nil && len(rule.Conditions) > 0 {
+			return errors.New(errors.ErrCodeInvalidParams, "手动标签不能设置规则", nil)
+		}
+		tag.Rule = nil
+		tag.RuleJSON = nil
+		return nil
+	}
+	if err := validateTagRule(rule); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(rule)
+	if err != nil {
+		return errors.New(errors.ErrCodeInvalidParams, "无效的标签规则", err)
+	}
+	ruleJSON := string(raw)
+	tag.Rule = rule
+	tag.RuleJSON = &ruleJSON
+	return nil
+}
+
+// validateTagRule 校验规则条件
+func validateTagRule(rule *model.UserTagRule) error {
+	if rule == nil || len(rule.Conditions) == 0 {
+		return errors.New(errors.ErrCodeInvalidParams, "规则标签至少需要一个条件", nil)
+	}
+	if len(rule.Conditions) > userTagMaxConditions {
+		return errors.New(errors.ErrCodeInvalidParams, fmt.Sprintf("规则最多 %d 个条件", userTagMaxConditions), nil)
+	}
+	for _, cond := range rule.Conditions {
+		if cond.Days < 0 {
+			return errors.New(errors.ErrCodeInvalidParams, "统计天数不能为负数", nil)
+		}
+		switch cond.Metric {
+		case model.TagMetricProvider:
+			if cond.Provider == "" {
+				return errors.New(errors.ErrCodeInvalidParams, "注册渠道条件需指定 provider", nil)
+			}
+		case model.TagMetricTokensSpent, model.TagMetricTokensEarned, model.TagMetricRechargeAmount,
+			model.TagMetricInvites, model.TagMetricTaskCompletion:
+			if _, ok := userTagNegatedOps[cond.Op]; !ok {
+				return errors.New(errors.ErrCodeInvalidParams, "不支持的比较方式："+cond.Op, nil)
+			}
+		default:
+			return errors.New(errors.ErrCodeInvalidParams, "不支持的标签指标："+cond.Metric, nil)
+		}
+	}
+	return nil
+}
+
+// manualTag 获取手动标签，规则标签的用户由规则决定，不能手动增删
+func (s *UserTagService) manualTag(ctx context.Context, id int64) (*model.UserTag, error) {
+	tag, err := s.GetTag(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tag.Type != model.TagTypeManual {
+		return nil, errors.New(errors.ErrCodeInvalidParams, "规则标签的用户由规则计算，不能手动修改", nil)
+	}
+	return tag, nil
+}
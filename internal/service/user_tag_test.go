@@ -0,0 +1,101 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/reusedev/uportal-api/internal/model"
+)
+
+func TestCompareZero(t *testing.T) {
+	cases := []struct {
+		op    string
+		value float64
+		want  bool
+	}{
+		{">", 0, false},
+		{">", -1, true},
+		{">=", 0, true},
+		{">=", 1, false},
+		{"<", 100, true},
+		{"<", 0, false},
+		{"<=", 0, true},
+		{"=", 0, true},
+		{"=", 5, false},
+		{"!=", 0, false},
+	}
+	for _, c := range cases {
+		if got := compareZero(c.op, c.value); got != c.want {
+			t.Errorf("compareZero(%q, %v) = %v, want %v", c.op, c.value, got, c.want)
+		}
+	}
+}
+
+func TestValidateTagRule(t *testing.T) {
+	cond := func(metric, op string) model.UserTagCondition {
+		return model.UserTagCondition{Metric: metric, Op: op, Value: 1}
+	}
+	tooMany := &model.UserTagRule{}
+	for i := 0; i <= userTagMaxConditions; i++ {
+		tooMany.Conditions = append(tooMany.Conditions, cond(model.TagMetricInvites, ">"))
+	}
+
+	cases := []struct {
+		name string
+		rule *model.UserTagRule
+		ok   bool
+	}{
+		{"nil rule", nil, false},
+		{"no conditions", &model.UserTagRule{}, false},
+		{"too many conditions", tooMany, false},
+		{"metric with op", &model.UserTagRule{Conditions: []model.UserTagCondition{cond(model.TagMetricTokensSpent, ">=")}}, true},
+		{"unsupported op", &model.UserTagRule{Conditions: []model.UserTagCondition{cond(model.TagMetricTokensSpent, "!=")}}, false},
+		{"unknown metric", &model.UserTagRule{Conditions: []model.UserTagCondition{cond("age", ">")}}, false},
+		{"negative days", &model.UserTagRule{Conditions: []model.UserTagCondition{{Metric: model.TagMetricInvites, Op: ">", Days: -1}}}, false},
+		{"provider", &model.UserTagRule{Conditions: []model.UserTagCondition{{Metric: model.TagMetricProvider, Provider: "wechat"}}}, true},
+		{"provider without value", &model.UserTagRule{Conditions: []model.UserTagCondition{{Metric: model.TagMetricProvider}}}, false},
+	}
+	for _, c := range cases {
+		err := validateTagRule(c.rule)
+		if (err == nil) != c.ok {
+			t.Errorf("%s: err = %v, want ok=%v", c.name, err, c.ok)
+		}
+	}
+}
+
+func TestApplyTagCondition(t *testing.T) {
+	cases := []struct {
+		name string
+		cond model.UserTagCondition
+		want []string
+	}{
+		{"spent at least", model.UserTagCondition{Metric: model.TagMetricTokensSpent, Op: ">=", Value: 100},
+			[]string{"id IN (SELECT `user_id` FROM `token_records`", "SUM(-change_amount) >= ?"}},
+		{"spent less than includes users without records", model.UserTagCondition{Metric: model.TagMetricTokensSpent, Op: "<", Value: 100},
+			[]string{"id NOT IN (SELECT `user_id` FROM `token_records`", "SUM(-change_amount) >= ?"}},
+		{"no invites", model.UserTagCondition{Metric: model.TagMetricInvites, Op: "=", Value: 0},
+			[]string{"id NOT IN (SELECT `inviter_id` FROM `invite_records`", "COUNT(*) <> ?"}},
+		{"recent task completions", model.UserTagCondition{Metric: model.TagMetricTaskCompletion, Op: ">", Value: 3, Days: 7},
+			[]string{"completed_at >= ?", "COUNT(*) > ?"}},
+		{"provider", model.UserTagCondition{Metric: model.TagMetricProvider, Provider: "apple"},
+			[]string{"id IN (SELECT `user_id` FROM `user_auth` WHERE provider = ?"}},
+	}
+	for _, c := range cases {
+		db := newDryRunDB(t)
+		query, err := applyTagCondition(db, db.Model(&model.User{}).Select("id"), c.cond)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		sql := query.Find(&[]string{}).Statement.SQL.String()
+		for _, w := range c.want {
+			if !strings.Contains(sql, w) {
+				t.Errorf("%s: sql %q missing %q", c.name, sql, w)
+			}
+		}
+	}
+
+	db := newDryRunDB(t)
+	if _, err := applyTagCondition(db, db.Model(&model.User{}), model.UserTagCondition{Metric: "age"}); err == nil {
+		t.Errorf("unknown metric accepted")
+	}
+}
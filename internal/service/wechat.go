@@ -1,26 +1,54 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/reusedev/uportal-api/pkg/config"
 	"github.com/reusedev/uportal-api/pkg/errors"
 	"github.com/reusedev/uportal-api/pkg/logs"
 	"go.uber.org/zap"
 )
 
+const (
+	wechatAPIBase = "https://api.weixin.qq.com"
+
+	// access_token 提前过期的缓冲时间，避免临界点使用失效凭据
+	accessTokenExpireBuffer = 5 * time.Minute
+	accessTokenLockTTL      = 10 * time.Second
+)
+
+// 表示 access_token 失效、需要刷新后重试的微信错误码
+var wechatTokenErrCodes = map[int]bool{
+	40001: true, // access_token 无效
+	40014: true, // 不合法的 access_token
+	42001: true, // access_token 超时
+}
+
 // WechatService 微信服务
 type WechatService struct {
-	config *config.Config
+	config     *config.Config
+	redis      *redis.Client
+	httpClient *http.Client
 }
 
 // NewWechatService 创建微信服务实例
-func NewWechatService(config *config.Config) *WechatService {
+func NewWechatService(config *config.Config, redis *redis.Client) *WechatService {
 	return &WechatService{
-		config: config,
+		config:     config,
+		redis:      redis,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
 	}
 }
 
@@ -60,7 +88,7 @@ func (s *WechatService) Login(ctx context.Context, req *WxLoginRequest) (*WxLogi
 	)
 
 	// 发送请求
-	resp, err := http.Get(url)
+	resp, err := s.httpClient.Get(url)
 	if err != nil {
 		return nil, errors.New(errors.ErrCodeWechatLoginFailed, "请求微信服务器失败", err)
 	}
@@ -94,23 +122,409 @@ func (s *WechatService) Login(ctx context.Context, req *WxLoginRequest) (*WxLogi
 	}, nil
 }
 
-// DecryptUserInfo 解密用户信息
+// WxWatermark 微信加密数据水印
+type WxWatermark struct {
+	AppID     string `json:"appid"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// WxPhoneInfo 微信手机号信息
+type WxPhoneInfo struct {
+	PhoneNumber     string      `json:"phoneNumber"`     // 带区号的手机号（境外手机号带区号）
+	PurePhoneNumber string      `json:"purePhoneNumber"` // 不带区号的手机号
+	CountryCode     string      `json:"countryCode"`     // 区号
+	Watermark       WxWatermark `json:"watermark"`
+}
+
+// Phone 返回用于绑定账号的手机号，大陆手机号不带区号，境外手机号带 + 区号
+func (p *WxPhoneInfo) Phone() string {
+	if p.CountryCode == "" || p.CountryCode == "86" {
+		return p.PurePhoneNumber
+	}
+	return "+" + p.CountryCode + p.PurePhoneNumber
+}
+
+// DecryptUserInfo 解密 wx.getUserInfo / 旧版 getPhoneNumber 返回的加密数据，并校验水印中的 appid
 func (s *WechatService) DecryptUserInfo(sessionKey, encryptedData, iv string) (map[string]interface{}, error) {
-	// TODO: 实现用户信息解密
-	// 这里需要使用微信提供的解密算法
-	// 可以参考：https://developers.weixin.qq.com/miniprogram/dev/framework/open-ability/signature.html
-	return nil, nil
+	plain, err := s.decryptData(sessionKey, encryptedData, iv)
+	if err != nil {
+		return nil, err
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(plain, &data); err != nil {
+		return nil, errors.New(errors.ErrCodeWechatDecryptFailed, "解析解密数据失败", err)
+	}
+	return data, nil
+}
+
+// DecryptPhoneNumber 解密旧版 getPhoneNumber 返回的加密手机号
+func (s *WechatService) DecryptPhoneNumber(sessionKey, encryptedData, iv string) (*WxPhoneInfo, error) {
+	plain, err := s.decryptData(sessionKey, encryptedData, iv)
+	if err != nil {
+		return nil, err
+	}
+
+	var info WxPhoneInfo
+	if err := json.Unmarshal(plain, &info); err != nil {
+		return nil, errors.New(errors.ErrCodeWechatDecryptFailed, "解析手机号数据失败", err)
+	}
+	if info.PurePhoneNumber == "" {
+		return nil, errors.New(errors.ErrCodeWechatDecryptFailed, "加密数据中不包含手机号", nil)
+	}
+	return &info, nil
+}
+
+// decryptData 使用 session_key 以 AES-128-CBC 解密数据并校验水印
+func (s *WechatService) decryptData(sessionKey, encryptedData, iv string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(sessionKey)
+	if err != nil || len(key) != aes.BlockSize {
+		return nil, errors.New(errors.ErrCodeWechatDecryptFailed, "无效的session_key", err)
+	}
+	ivBytes, err := base64.StdEncoding.DecodeString(iv)
+	if err != nil || len(ivBytes) != aes.BlockSize {
+		return nil, errors.New(errors.ErrCodeWechatDecryptFailed, "无效的iv", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(encryptedData)
+	if err != nil || len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, errors.New(errors.ErrCodeWechatDecryptFailed, "无效的加密数据", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeWechatDecryptFailed, "创建解密器失败", err)
+	}
+	plain := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, ivBytes).CryptBlocks(plain, ciphertext)
+
+	plain, err = pkcs7Unpad(plain)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeWechatDecryptFailed, "解密数据失败", err)
+	}
+
+	// 校验水印，防止使用其他小程序的加密数据
+	var payload struct {
+		Watermark WxWatermark `json:"watermark"`
+	}
+	if err := json.Unmarshal(plain, &payload); err != nil {
+		return nil, errors.New(errors.ErrCodeWechatDecryptFailed, "解析解密数据失败", err)
+	}
+	if payload.Watermark.AppID != s.config.Wechat.MiniProgram.AppID {
+		return nil, errors.New(errors.ErrCodeWechatDecryptFailed, "加密数据水印校验失败", nil)
+	}
+	return plain, nil
+}
+
+// pkcs7Unpad 去除 PKCS#7 填充
+func pkcs7Unpad(data []byte) ([]byte, error) {
+	n := len(data)
+	if n == 0 {
+		return nil, fmt.Errorf("empty data")
+	}
+	pad := int(data[n-1])
+	if pad == 0 || pad > aes.BlockSize || pad > n {
+		return nil, fmt.Errorf("invalid padding")
+	}
+	for _, b := range data[n-pad:] {
+		if int(b) != pad {
+			return nil, fmt.Errorf("invalid padding")
+		}
+	}
+	return data[:n-pad], nil
+}
+
+// GetPhoneNumber 使用 getPhoneNumber 返回的 code 换取用户手机号
+func (s *WechatService) GetPhoneNumber(ctx context.Context, code string) (*WxPhoneInfo, error) {
+	var resp struct {
+		wechatAPIError
+		PhoneInfo WxPhoneInfo `json:"phone_info"`
+	}
+	if err := s.CallAPI(ctx, http.MethodPost, "/wxa/business/getuserphonenumber", map[string]string{"code": code}, &resp); err != nil {
+		return nil, err
+	}
+	if resp.PhoneInfo.Watermark.AppID != "" && resp.PhoneInfo.Watermark.AppID != s.config.Wechat.MiniProgram.AppID {
+		return nil, errors.New(errors.ErrCodeWechatAPIFailed, "手机号水印校验失败", nil)
+	}
+	if resp.PhoneInfo.PurePhoneNumber == "" {
+		return nil, errors.New(errors.ErrCodeWechatAPIFailed, "获取手机号失败", nil)
+	}
+	return &resp.PhoneInfo, nil
+}
+
+// WxaCodeRequest 获取不限数量小程序码（wxacode.getUnlimited）请求
+type WxaCodeRequest struct {
+	Scene      string `json:"scene"`       // 场景值，最多 32 个可见字符，小程序通过 scene 参数读取
+	Page       string `json:"page"`        // 打开的页面，为空时打开首页
+	CheckPath  bool   `json:"check_path"`  // 是否检查页面存在，未发布的版本需关闭
+	EnvVersion string `json:"env_version"` // 打开的版本：release/trial/develop
+	Width      int    `json:"width"`       // 二维码宽度（像素）
+}
+
+// GetUnlimitedQRCode 获取不限数量的小程序码，返回图片内容和类型
+func (s *WechatService) GetUnlimitedQRCode(ctx context.Context, req *WxaCodeRequest) ([]byte, string, error) {
+	var resp wechatBinaryResponse
+	if err := s.CallAPI(ctx, http.MethodPost, "/wxa/getwxacodeunlimit", req, &resp); err != nil {
+		return nil, "", err
+	}
+	if len(resp.Body) == 0 {
+		return nil, "", errors.New(errors.ErrCodeWechatAPIFailed, "获取小程序码失败", nil)
+	}
+	return resp.Body, resp.ContentType, nil
+}
+
+// wechatBinaryResponse 成功时返回二进制内容（如小程序码图片）、失败时返回 JSON 错误的接口响应
+type wechatBinaryResponse struct {
+	wechatAPIError
+	ContentType string
+	Body        []byte
+}
+
+// wechatBinaryMaxBytes 二进制响应的最大长度
+const wechatBinaryMaxBytes = 4 << 20
+
+func (r *wechatBinaryResponse) read(resp *http.Response) error {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, wechatBinaryMaxBytes))
+	if err != nil {
+		return errors.New(errors.ErrCodeWechatAPIFailed, "读取微信响应失败", err)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if strings.Contains(contentType, "json") || strings.HasPrefix(contentType, "text/") {
+		if err := json.Unmarshal(data, &r.wechatAPIError); err != nil {
+			return errors.New(errors.ErrCodeWechatAPIFailed, "解析微信响应失败", err)
+		}
+		return nil
+	}
+	r.ContentType = contentType
+	r.Body = data
+	return nil
+}
+
+// WechatError 微信接口返回的业务错误，可通过 errors.As 获取错误码
+type WechatError struct {
+	Code int
+	Msg  string
+}
+
+func (e *WechatError) Error() string {
+	return fmt.Sprintf("wechat errcode %d: %s", e.Code, e.Msg)
+}
+
+// wechatAPIError 微信接口通用错误字段
+type wechatAPIError struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
 }
 
-// GetAccessToken 获取小程序全局接口调用凭据
+func (e *wechatAPIError) apiError() *wechatAPIError {
+	return e
+}
+
+type wechatAPIResponse interface {
+	apiError() *wechatAPIError
+}
+
+// CallAPI 调用需要 access_token 的微信接口，凭据失效时自动刷新并重试一次
+func (s *WechatService) CallAPI(ctx context.Context, method, path string, body interface{}, out wechatAPIResponse) error {
+	for attempt := 0; attempt < 2; attempt++ {
+		token, err := s.GetAccessToken(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err := s.doRequest(ctx, method, path, token, body, out); err != nil {
+			return err
+		}
+
+		apiErr := out.apiError()
+		if apiErr.ErrCode == 0 {
+			return nil
+		}
+		if wechatTokenErrCodes[apiErr.ErrCode] && attempt == 0 {
+			logs.Business().Warn("微信access_token失效，刷新后重试",
+				zap.String("path", path),
+				zap.Int("errcode", apiErr.ErrCode),
+			)
+			s.invalidateAccessToken(ctx, token)
+			*apiErr = wechatAPIError{}
+			continue
+		}
+
+		logs.Business().Error("调用微信接口失败",
+			zap.String("path", path),
+			zap.Int("errcode", apiErr.ErrCode),
+			zap.String("errmsg", apiErr.ErrMsg),
+		)
+		return errors.New(errors.ErrCodeWechatAPIFailed, fmt.Sprintf("微信接口调用失败: %s", apiErr.ErrMsg),
+			&WechatError{Code: apiErr.ErrCode, Msg: apiErr.ErrMsg})
+	}
+	return errors.New(errors.ErrCodeWechatAPIFailed, "微信接口调用失败", nil)
+}
+
+func (s *WechatService) doRequest(ctx context.Context, method, path, token string, body interface{}, out interface{}) error {
+	reqURL := wechatAPIBase + path + "?access_token=" + url.QueryEscape(token)
+
+	var reader *bytes.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return errors.New(errors.ErrCodeInternal, "序列化请求失败", err)
+		}
+		reader = bytes.NewReader(payload)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, reader)
+	if err != nil {
+		return errors.New(errors.ErrCodeInternal, "创建请求失败", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return errors.New(errors.ErrCodeWechatAPIFailed, "请求微信服务器失败", err)
+	}
+	defer resp.Body.Close()
+
+	if bin, ok := out.(*wechatBinaryResponse); ok {
+		return bin.read(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return errors.New(errors.ErrCodeWechatAPIFailed, "解析微信响应失败", err)
+	}
+	return nil
+}
+
+// GetAccessToken 获取小程序全局接口调用凭据，多实例共享 Redis 中的缓存
 func (s *WechatService) GetAccessToken(ctx context.Context) (string, error) {
-	// TODO: 实现获取 access_token 的逻辑
-	// 需要实现缓存机制，因为 access_token 有效期较长
-	return "", nil
+	if s.redis == nil {
+		token, _, err := s.fetchAccessToken(ctx)
+		return token, err
+	}
+
+	cacheKey := s.accessTokenKey()
+	token, err := s.redis.Get(ctx, cacheKey).Result()
+	if err == nil && token != "" {
+		return token, nil
+	}
+	if err != nil && err != redis.Nil {
+		logs.Business().Warn("读取access_token缓存失败", zap.Error(err))
+	}
+
+	// 只允许一个实例刷新，其他实例等待刷新结果，避免并发刷新导致旧凭据失效
+	lockKey := cacheKey + ":lock"
+	locked, err := s.redis.SetNX(ctx, lockKey, 1, accessTokenLockTTL).Result()
+	if err != nil {
+		logs.Business().Warn("获取access_token刷新锁失败", zap.Error(err))
+	}
+	if !locked {
+		deadline := time.Now().Add(accessTokenLockTTL)
+		for time.Now().Before(deadline) {
+			select {
+			case <-ctx.Done():
+				return "", errors.New(errors.ErrCodeWechatAPIFailed, "获取access_token超时", ctx.Err())
+			case <-time.After(100 * time.Millisecond):
+			}
+			if token, err := s.redis.Get(ctx, cacheKey).Result(); err == nil && token != "" {
+				return token, nil
+			}
+		}
+		return "", errors.New(errors.ErrCodeWechatAPIFailed, "等待access_token刷新超时", nil)
+	}
+	defer s.redis.Del(context.Background(), lockKey)
+
+	// 拿到锁后再检查一次，可能其他实例刚刚完成刷新
+	if token, err := s.redis.Get(ctx, cacheKey).Result(); err == nil && token != "" {
+		return token, nil
+	}
+
+	token, expiresIn, err := s.fetchAccessToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	ttl := expiresIn - accessTokenExpireBuffer
+	if ttl <= 0 {
+		ttl = expiresIn / 2
+	}
+	if err := s.redis.Set(ctx, cacheKey, token, ttl).Err(); err != nil {
+		logs.Business().Warn("缓存access_token失败", zap.Error(err))
+	}
+	return token, nil
+}
+
+// fetchAccessToken 从微信服务器获取 access_token
+func (s *WechatService) fetchAccessToken(ctx context.Context) (string, time.Duration, error) {
+	reqURL := fmt.Sprintf("%s/cgi-bin/token?grant_type=client_credential&appid=%s&secret=%s",
+		wechatAPIBase,
+		url.QueryEscape(s.config.Wechat.MiniProgram.AppID),
+		url.QueryEscape(s.config.Wechat.MiniProgram.AppSecret),
+	)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return "", 0, errors.New(errors.ErrCodeInternal, "创建请求失败", err)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", 0, errors.New(errors.ErrCodeWechatAPIFailed, "请求微信服务器失败", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		wechatAPIError
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", 0, errors.New(errors.ErrCodeWechatAPIFailed, "解析微信响应失败", err)
+	}
+	if result.ErrCode != 0 || result.AccessToken == "" {
+		logs.Business().Error("获取微信access_token失败",
+			zap.Int("errcode", result.ErrCode),
+			zap.String("errmsg", result.ErrMsg),
+		)
+		return "", 0, errors.New(errors.ErrCodeWechatAPIFailed, fmt.Sprintf("获取access_token失败: %s", result.ErrMsg), nil)
+	}
+
+	logs.Business().Info("刷新微信access_token成功", zap.Int("expires_in", result.ExpiresIn))
+	return result.AccessToken, time.Duration(result.ExpiresIn) * time.Second, nil
+}
+
+// invalidateAccessToken 删除失效的缓存凭据，仅当缓存仍是该凭据时删除，避免误删其他实例刚刷新的凭据
+func (s *WechatService) invalidateAccessToken(ctx context.Context, token string) {
+	if s.redis == nil {
+		return
+	}
+	cacheKey := s.accessTokenKey()
+	if cached, err := s.redis.Get(ctx, cacheKey).Result(); err == nil && cached == token {
+		s.redis.Del(ctx, cacheKey)
+	}
 }
 
-// SendSubscribeMessage 发送订阅消息
+func (s *WechatService) accessTokenKey() string {
+	return fmt.Sprintf("wechat:access_token:%s", s.config.Wechat.MiniProgram.AppID)
+}
+
+// SendSubscribeMessage 发送订阅消息，data 为模板关键词到取值的映射
 func (s *WechatService) SendSubscribeMessage(ctx context.Context, openID, templateID string, data map[string]interface{}, page string) error {
-	// TODO: 实现发送订阅消息的逻辑
-	return nil
+	msgData := make(map[string]map[string]interface{}, len(data))
+	for key, value := range data {
+		msgData[key] = map[string]interface{}{"value": value}
+	}
+
+	body := map[string]interface{}{
+		"touser":            openID,
+		"template_id":       templateID,
+		"data":              msgData,
+		"miniprogram_state": s.config.Notification.MiniProgramState,
+		"lang":              "zh_CN",
+	}
+	if page != "" {
+		body["page"] = page
+	}
+
+	var resp wechatAPIError
+	return s.CallAPI(ctx, http.MethodPost, "/cgi-bin/message/subscribe/send", body, &resp)
 }
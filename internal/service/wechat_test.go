@@ -0,0 +1,109 @@
+package service
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"github.com/reusedev/uportal-api/pkg/config"
+)
+
+// encryptWxData 按小程序加密数据的格式（AES-128-CBC、PKCS#7 填充）加密，返回 base64 编码的数据
+func encryptWxData(t *testing.T, key, iv []byte, plain string) string {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("new cipher: %v", err)
+	}
+	pad := aes.BlockSize - len(plain)%aes.BlockSize
+	data := append([]byte(plain), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("rand: %v", err)
+	}
+	return b
+}
+
+func TestWechatDecryptData(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Wechat.MiniProgram.AppID = "wx-test"
+	s := &WechatService{config: cfg}
+
+	key, iv := randomBytes(t, aes.BlockSize), randomBytes(t, aes.BlockSize)
+	sessionKey := base64.StdEncoding.EncodeToString(key)
+	ivText := base64.StdEncoding.EncodeToString(iv)
+	phone := `{"phoneNumber":"+85261234567","purePhoneNumber":"61234567","countryCode":"852","watermark":{"appid":"wx-test","timestamp":1700000000}}`
+
+	info, err := s.DecryptPhoneNumber(sessionKey, encryptWxData(t, key, iv, phone), ivText)
+	if err != nil {
+		t.Fatalf("decrypt phone: %v", err)
+	}
+	if info.Phone() != "+85261234567" {
+		t.Errorf("phone = %q, want +85261234567", info.Phone())
+	}
+
+	cases := []struct {
+		name       string
+		sessionKey string
+		data       string
+		iv         string
+	}{
+		{"watermark from another app", sessionKey, encryptWxData(t, key, iv, `{"nickName":"a","watermark":{"appid":"wx-other"}}`), ivText},
+		{"missing watermark", sessionKey, encryptWxData(t, key, iv, `{"nickName":"a"}`), ivText},
+		{"wrong session key", base64.StdEncoding.EncodeToString(randomBytes(t, aes.BlockSize)), encryptWxData(t, key, iv, phone), ivText},
+		{"short session key", base64.StdEncoding.EncodeToString(key[:8]), encryptWxData(t, key, iv, phone), ivText},
+		{"invalid iv", sessionKey, encryptWxData(t, key, iv, phone), "not-base64"},
+		{"truncated data", sessionKey, base64.StdEncoding.EncodeToString([]byte("0123456789")), ivText},
+	}
+	for _, c := range cases {
+		if _, err := s.decryptData(c.sessionKey, c.data, c.iv); err == nil {
+			t.Errorf("%s: decrypted without error", c.name)
+		}
+	}
+
+	// 用户信息中没有手机号
+	userInfo := encryptWxData(t, key, iv, `{"nickName":"a","watermark":{"appid":"wx-test"}}`)
+	if _, err := s.DecryptPhoneNumber(sessionKey, userInfo, ivText); err == nil {
+		t.Error("decrypted phone number from user info")
+	}
+}
+
+func TestPKCS7Unpad(t *testing.T) {
+	block := func(tail ...byte) []byte {
+		return append(bytes.Repeat([]byte{'a'}, aes.BlockSize-len(tail)), tail...)
+	}
+	cases := []struct {
+		name string
+		data []byte
+		want int // 去除填充后的长度，-1 表示出错
+	}{
+		{"one byte", block(1), aes.BlockSize - 1},
+		{"three bytes", block(3, 3, 3), aes.BlockSize - 3},
+		{"full block", bytes.Repeat([]byte{aes.BlockSize}, aes.BlockSize), 0},
+		{"empty", nil, -1},
+		{"zero padding", block(0), -1},
+		{"padding longer than a block", block(aes.BlockSize + 1), -1},
+		{"inconsistent padding", block(2, 3, 3), -1},
+	}
+	for _, c := range cases {
+		got, err := pkcs7Unpad(c.data)
+		if c.want < 0 {
+			if err == nil {
+				t.Errorf("%s: got no error", c.name)
+			}
+			continue
+		}
+		if err != nil || len(got) != c.want {
+			t.Errorf("%s: got %d bytes, %v, want %d bytes", c.name, len(got), err, c.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Package audit 在请求上下文中携带审计信息，由业务代码补充操作对象和变更前后的数据，
+// 审计中间件在请求结束后统一落库
+package audit
+
+import (
+	"context"
+	"sync"
+)
+
+type contextKey struct{}
+
+// Entry 一次操作的审计信息，所有方法在 nil 上调用都是安全的，
+// 因此业务代码无需区分请求是否处于审计范围内
+type Entry struct {
+	mu         sync.Mutex
+	TargetType string      // 操作对象类型，如 user、admin、config
+	TargetID   string      // 操作对象ID
+	Before     interface{} // 变更前的数据
+	After      interface{} // 变更后的数据
+}
+
+// NewContext 将审计信息放入上下文
+func NewContext(ctx context.Context, e *Entry) context.Context {
+	return context.WithValue(ctx, contextKey{}, e)
+}
+
+// FromContext 取出上下文中的审计信息，不存在时返回 nil
+func FromContext(ctx context.Context) *Entry {
+	e, _ := ctx.Value(contextKey{}).(*Entry)
+	return e
+}
+
+// SetTarget 设置操作对象
+func (e *Entry) SetTarget(targetType, targetID string) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.TargetType = targetType
+	e.TargetID = targetID
+}
+
+// SetBefore 记录变更前的数据，重复调用以第一次为准
+func (e *Entry) SetBefore(v interface{}) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.Before == nil {
+		e.Before = v
+	}
+}
+
+// SetAfter 记录变更后的数据，重复调用以最后一次为准
+func (e *Entry) SetAfter(v interface{}) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.After = v
+}
+
+// Snapshot 返回当前记录的内容
+func (e *Entry) Snapshot() (targetType, targetID string, before, after interface{}) {
+	if e == nil {
+		return "", "", nil, nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.TargetType, e.TargetID, e.Before, e.After
+}
+
+// Record 便捷方法：一次性设置操作对象和变更前后的数据
+func Record(ctx context.Context, targetType, targetID string, before, after interface{}) {
+	e := FromContext(ctx)
+	e.SetTarget(targetType, targetID)
+	if before != nil {
+		e.SetBefore(before)
+	}
+	if after != nil {
+		e.SetAfter(after)
+	}
+}
+
+// Log 一条待落库的审计日志
+type Log struct {
+	AdminID    int64
+	AdminName  string
+	Action     string
+	TargetType string
+	TargetID   string
+	Before     interface{}
+	After      interface{}
+	Params     []byte // 已脱敏的请求参数
+	Success    bool
+	Error      string
+	IP         string
+	UserAgent  string
+	RequestID  string
+	Method     string
+	Path       string
+}
+
+// Recorder 审计日志存储
+type Recorder interface {
+	Write(ctx context.Context, log *Log)
+}
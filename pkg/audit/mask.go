@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// sensitiveSubstrings 字段名包含这些片段即脱敏（按小写匹配）
+var sensitiveSubstrings = []string{"password", "secret", "private_key"}
+
+// sensitiveKeys 需要脱敏的完整字段名，动态码、恢复码和各类凭证
+var sensitiveKeys = map[string]struct{}{
+	"code":            {},
+	"recovery_code":   {},
+	"recovery_codes":  {},
+	"token":           {},
+	"challenge_token": {},
+	"access_token":    {},
+	"refresh_token":   {},
+}
+
+const maskedValue = "******"
+
+// MaskParams 对 JSON 请求体中的敏感字段脱敏，非 JSON 内容返回 nil
+func MaskParams(body []byte) []byte {
+	if len(body) == 0 {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil
+	}
+	masked, err := json.Marshal(maskValue(v))
+	if err != nil {
+		return nil
+	}
+	return masked
+}
+
+func maskValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if isSensitive(k) {
+				val[k] = maskedValue
+				continue
+			}
+			val[k] = maskValue(item)
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = maskValue(item)
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	if _, ok := sensitiveKeys[key]; ok {
+		return true
+	}
+	for _, s := range sensitiveSubstrings {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
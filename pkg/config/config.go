@@ -2,7 +2,9 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -20,6 +22,9 @@ type Config struct {
 		Mode         string        `yaml:"mode"`         // 运行模式：debug/release
 		ReadTimeout  time.Duration `yaml:"readTimeout"`  // 读取超时时间
 		WriteTimeout time.Duration `yaml:"writeTimeout"` // 写入超时时间
+		// 可信反向代理的IP或网段，只有来自这些地址的请求才从 X-Forwarded-For 等请求头取客户端IP，
+		// 为空时不信任任何代理，直接使用连接地址
+		TrustedProxies []string `yaml:"trustedProxies"`
 	} `yaml:"server"`
 
 	Database struct {
@@ -84,6 +89,161 @@ type Config struct {
 		ReturnUrl  string `yaml:"returnUrl"`  // 支付完成返回地址
 		IsProd     bool   `yaml:"isProd"`     // 是否生产环境
 	} `yaml:"alipay"`
+
+	Account struct {
+		BcryptCost           int           `yaml:"bcryptCost"`           // 密码哈希的bcrypt代价
+		RequireEmailVerified bool          `yaml:"requireEmailVerified"` // 邮箱登录前是否必须完成验证
+		VerifyTokenTTL       time.Duration `yaml:"verifyTokenTTL"`       // 邮箱验证令牌有效期
+		ResetTokenTTL        time.Duration `yaml:"resetTokenTTL"`        // 密码重置令牌有效期
+		ResendInterval       time.Duration `yaml:"resendInterval"`       // 同类邮件最小发送间隔
+		VerifyURL            string        `yaml:"verifyUrl"`            // 邮箱验证链接地址
+		ResetURL             string        `yaml:"resetUrl"`             // 密码重置链接地址
+		PasswordPolicy       struct {
+			MinLength     int  `yaml:"minLength"`     // 最小长度
+			MaxLength     int  `yaml:"maxLength"`     // 最大长度
+			RequireLetter bool `yaml:"requireLetter"` // 必须包含字母
+			RequireDigit  bool `yaml:"requireDigit"`  // 必须包含数字
+			RequireUpper  bool `yaml:"requireUpper"`  // 必须包含大写字母
+			RequireSymbol bool `yaml:"requireSymbol"` // 必须包含特殊字符
+		} `yaml:"passwordPolicy"`
+	} `yaml:"account"`
+
+	AdminSecurity struct {
+		TOTPIssuer              string        `yaml:"totpIssuer"`              // 验证器中显示的签发方名称
+		TOTPSkew                int           `yaml:"totpSkew"`                // 允许的时钟偏差（时间步数）
+		Require2FARoles         []string      `yaml:"require2faRoles"`         // 强制启用双因素认证的角色
+		RecoveryCodeCount       int           `yaml:"recoveryCodeCount"`       // 恢复码数量
+		MaxLoginAttempts        int           `yaml:"maxLoginAttempts"`        // 同一用户名在同一IP上锁定前允许的连续失败次数
+		MaxAccountLoginAttempts int           `yaml:"maxAccountLoginAttempts"` // 同一用户名在所有IP上锁定前允许的失败次数
+		AttemptWindow           time.Duration `yaml:"attemptWindow"`           // 失败次数统计窗口
+		LockoutDuration         time.Duration `yaml:"lockoutDuration"`         // 锁定时长
+		ChallengeTTL            time.Duration `yaml:"challengeTTL"`            // 密码通过后输入动态码的有效期
+		RestrictedTTL           time.Duration `yaml:"restrictedTTL"`           // 受限令牌（强制改密/绑定2FA）有效期
+		SecretKey               string        `yaml:"secretKey"`               // 加密存储TOTP密钥的口令，为空时使用JWT密钥
+		InviteTTL               time.Duration `yaml:"inviteTTL"`               // 管理员邀请有效期
+		InviteURL               string        `yaml:"inviteUrl"`               // 管理员邀请链接地址（管理后台接受邀请页面）
+		ImpersonationTTL        time.Duration `yaml:"impersonationTTL"`        // 模拟用户只读令牌有效期
+	} `yaml:"adminSecurity"`
+
+	Mail struct {
+		Driver   string `yaml:"driver"`   // 发送方式：smtp/file/stdout
+		Host     string `yaml:"host"`     // SMTP主机
+		Port     int    `yaml:"port"`     // SMTP端口
+		Username string `yaml:"username"` // SMTP用户名
+		Password string `yaml:"password"` // SMTP密码
+		From     string `yaml:"from"`     // 发件人地址
+		FromName string `yaml:"fromName"` // 发件人名称
+		FileDir  string `yaml:"fileDir"`  // file 模式下邮件输出目录
+	} `yaml:"mail"`
+
+	Notification struct {
+		Workers             int                          `yaml:"workers"`             // 推送队列消费协程数
+		MaxRetries          int                          `yaml:"maxRetries"`          // 推送失败最大重试次数
+		RetryDelay          time.Duration                `yaml:"retryDelay"`          // 首次重试间隔，之后按指数退避
+		LowBalanceThreshold int                          `yaml:"lowBalanceThreshold"` // 余额低于该值时发送提醒，0 表示不提醒
+		MiniProgramState    string                       `yaml:"miniProgramState"`    // 跳转小程序类型：developer/trial/formal
+		Templates           map[string]SubscribeTemplate `yaml:"templates"`           // 通知类型（小写）到订阅消息模板的映射
+	} `yaml:"notification"`
+
+	Analytics struct {
+		Interval     time.Duration `yaml:"interval"`     // 日汇总重算间隔，每次重算今天和昨天
+		BackfillDays int           `yaml:"backfillDays"` // 启动时重算最近多少天（含今天）
+	} `yaml:"analytics"`
+
+	Export struct {
+		Dir         string        `yaml:"dir"`         // 异步导出文件存放目录，多实例部署时需使用共享存储
+		Workers     int           `yaml:"workers"`     // 异步导出并发数
+		SyncMaxRows int64         `yaml:"syncMaxRows"` // 同步导出的最大行数，超出需使用异步导出
+		MaxRows     int64         `yaml:"maxRows"`     // 单个导出文件的最大行数
+		FileTTL     time.Duration `yaml:"fileTTL"`     // 导出文件保留时长，过期后删除
+		JobTimeout  time.Duration `yaml:"jobTimeout"`  // 导出任务超时时间，超时仍未完成视为失败
+	} `yaml:"export"`
+
+	// 用户批量操作配置
+	Bulk struct {
+		Workers      int           `yaml:"workers"`      // 批量任务并发数
+		MaxUsers     int           `yaml:"maxUsers"`     // 单个批量任务最多处理的用户数
+		BatchSize    int           `yaml:"batchSize"`    // 每批处理的用户数，每批结束后更新进度
+		StaleTimeout time.Duration `yaml:"staleTimeout"` // 执行中的任务超过该时长没有进度时重新排队
+	} `yaml:"bulk"`
+
+	// 用户标签配置
+	UserTag struct {
+		Interval time.Duration `yaml:"interval"` // 规则标签重算间隔
+	} `yaml:"userTag"`
+
+	// 个人信息保护配置
+	Privacy struct {
+		GracePeriod    time.Duration `yaml:"gracePeriod"`    // 账号注销冷静期，期满后匿名化个人信息
+		Interval       time.Duration `yaml:"interval"`       // 检查到期注销申请的间隔
+		ExportCooldown time.Duration `yaml:"exportCooldown"` // 同一用户两次导出个人数据的最小间隔
+	} `yaml:"privacy"`
+
+	// 风控配置，各规则的阈值设为负数时关闭该规则
+	Risk struct {
+		Window               time.Duration `yaml:"window"`               // 频率统计窗口
+		SignupPerIP          int           `yaml:"signupPerIP"`          // 窗口内同一 IP 的注册数达到该值时加分
+		SignupPerDevice      int           `yaml:"signupPerDevice"`      // 同一设备注册的账号数达到该值时加分，不限时间
+		RewardPerUser        int           `yaml:"rewardPerUser"`        // 窗口内同一用户的奖励上报次数达到该值时加分
+		RewardDailyPerUser   int           `yaml:"rewardDailyPerUser"`   // 同一用户每天的奖励上报次数达到该值后拒绝
+		RewardUsersPerIP     int           `yaml:"rewardUsersPerIP"`     // 窗口内同一 IP 上报奖励的用户数达到该值时加分
+		RewardUsersPerDevice int           `yaml:"rewardUsersPerDevice"` // 窗口内同一设备上报奖励的用户数达到该值时加分
+		InvitePerInviter     int           `yaml:"invitePerInviter"`     // 窗口内同一邀请人的邀请数达到该值时加分
+		ReduceScore          int           `yaml:"reduceScore"`          // 风险分达到该值时按比例降低奖励
+		ReviewScore          int           `yaml:"reviewScore"`          // 风险分达到该值时暂扣奖励，进入人工审核
+		BlockScore           int           `yaml:"blockScore"`           // 风险分达到该值时拒绝
+		ReduceRatio          float64       `yaml:"reduceRatio"`          // 降低奖励时实际发放的比例
+	} `yaml:"risk"`
+
+	// 业务时区配置
+	Locale struct {
+		Timezone             string        `yaml:"timezone"`             // 业务时区（IANA 时区名，如 Asia/Shanghai），用户未设置时区时按此划分任务的自然日，为空使用服务器时区
+		TimezoneChangeWindow time.Duration `yaml:"timezoneChangeWindow"` // 用户两次修改时区的最小间隔，防止来回切换时区重置每日次数和签到日期
+	} `yaml:"locale"`
+
+	// 任务完成校验配置
+	TaskVerify struct {
+		ProofKeys   map[string]string `yaml:"proofKeys"`   // 服务端签名凭证的密钥，按 key_id 区分可信后端
+		ProofMaxAge time.Duration     `yaml:"proofMaxAge"` // 签名凭证的有效期，超出后拒绝，同一 nonce 在有效期内只能使用一次
+	} `yaml:"taskVerify"`
+
+	// 邀请配置
+	Invite struct {
+		LinkBaseURL     string        `yaml:"linkBaseURL"`     // 邀请落地页地址（协议和域名），为空时不返回分享链接
+		LinkPath        string        `yaml:"linkPath"`        // 邀请落地页路径，邀请码以 invite_code 参数附加
+		MiniProgramPage string        `yaml:"miniProgramPage"` // 小程序码打开的页面，邀请码作为 scene 参数传入
+		MiniProgramEnv  string        `yaml:"miniProgramEnv"`  // 小程序码打开的版本：release/trial/develop
+		QRCodeWidth     int           `yaml:"qrCodeWidth"`     // 小程序码宽度（像素），280-1280
+		QRCodeCacheTTL  time.Duration `yaml:"qrCodeCacheTTL"`  // 小程序码图片缓存时长
+	} `yaml:"invite"`
+
+	// 激励视频广告服务端回调（SSV）配置，custom_data/extra 为空时使用各平台的 taskKey
+	AdCallback struct {
+		AdMob struct {
+			Enabled bool          `yaml:"enabled"` // 是否启用
+			KeysURL string        `yaml:"keysURL"` // 验签公钥地址
+			KeysTTL time.Duration `yaml:"keysTTL"` // 公钥缓存时间
+			TaskKey string        `yaml:"taskKey"` // 默认任务标识
+		} `yaml:"admob"`
+		Pangle struct {
+			Enabled bool   `yaml:"enabled"` // 是否启用
+			Secret  string `yaml:"secret"`  // 奖励回调密钥（appSecurityKey）
+			TaskKey string `yaml:"taskKey"` // 默认任务标识
+		} `yaml:"pangle"`
+		YLH struct {
+			Enabled bool   `yaml:"enabled"` // 是否启用
+			Secret  string `yaml:"secret"`  // 奖励回调密钥
+			TaskKey string `yaml:"taskKey"` // 默认任务标识
+		} `yaml:"ylh"`
+	} `yaml:"adCallback"`
+}
+
+// SubscribeTemplate 微信订阅消息模板配置
+type SubscribeTemplate struct {
+	TemplateID string            `yaml:"templateId"` // 模板ID
+	Page       string            `yaml:"page"`       // 点击消息跳转的小程序页面
+	LongTerm   bool              `yaml:"longTerm"`   // 是否长期订阅，长期订阅不扣减授权次数
+	Fields     map[string]string `yaml:"fields"`     // 业务字段到模板关键词的映射，如 amount: amount2
 }
 
 // LoadConfig 加载配置文件
@@ -194,6 +354,217 @@ func setDefaults(config *Config) {
 	if config.JWT.Issuer == "" {
 		config.JWT.Issuer = "uportal-api"
 	}
+
+	// Account 默认值
+	if config.Account.BcryptCost == 0 {
+		config.Account.BcryptCost = 10
+	}
+	if config.Account.VerifyTokenTTL == 0 {
+		config.Account.VerifyTokenTTL = 24 * time.Hour
+	}
+	if config.Account.ResetTokenTTL == 0 {
+		config.Account.ResetTokenTTL = 30 * time.Minute
+	}
+	if config.Account.ResendInterval == 0 {
+		config.Account.ResendInterval = time.Minute
+	}
+	if config.Account.PasswordPolicy.MinLength == 0 {
+		config.Account.PasswordPolicy.MinLength = 8
+	}
+	if config.Account.PasswordPolicy.MaxLength == 0 {
+		config.Account.PasswordPolicy.MaxLength = 64
+	}
+
+	// AdminSecurity 默认值
+	if config.AdminSecurity.TOTPIssuer == "" {
+		config.AdminSecurity.TOTPIssuer = "UPortal Admin"
+	}
+	if config.AdminSecurity.TOTPSkew == 0 {
+		config.AdminSecurity.TOTPSkew = 1
+	}
+	if config.AdminSecurity.Require2FARoles == nil {
+		config.AdminSecurity.Require2FARoles = []string{"super_admin"}
+	}
+	if config.AdminSecurity.RecoveryCodeCount == 0 {
+		config.AdminSecurity.RecoveryCodeCount = 10
+	}
+	if config.AdminSecurity.MaxLoginAttempts == 0 {
+		config.AdminSecurity.MaxLoginAttempts = 5
+	}
+	if config.AdminSecurity.MaxAccountLoginAttempts == 0 {
+		config.AdminSecurity.MaxAccountLoginAttempts = 20
+	}
+	if config.AdminSecurity.AttemptWindow == 0 {
+		config.AdminSecurity.AttemptWindow = 15 * time.Minute
+	}
+	if config.AdminSecurity.LockoutDuration == 0 {
+		config.AdminSecurity.LockoutDuration = 15 * time.Minute
+	}
+	if config.AdminSecurity.ChallengeTTL == 0 {
+		config.AdminSecurity.ChallengeTTL = 5 * time.Minute
+	}
+	if config.AdminSecurity.RestrictedTTL == 0 {
+		config.AdminSecurity.RestrictedTTL = 15 * time.Minute
+	}
+	if config.AdminSecurity.InviteTTL == 0 {
+		config.AdminSecurity.InviteTTL = 72 * time.Hour
+	}
+	if config.AdminSecurity.ImpersonationTTL == 0 {
+		config.AdminSecurity.ImpersonationTTL = 15 * time.Minute
+	}
+
+	// Mail 默认值
+	if config.Mail.Driver == "" {
+		config.Mail.Driver = "stdout"
+	}
+	if config.Mail.Port == 0 {
+		config.Mail.Port = 465
+	}
+	if config.Mail.FileDir == "" {
+		config.Mail.FileDir = "mails"
+	}
+
+	// Notification 默认值
+	if config.Notification.Workers == 0 {
+		config.Notification.Workers = 2
+	}
+	if config.Notification.MaxRetries == 0 {
+		config.Notification.MaxRetries = 3
+	}
+	if config.Notification.RetryDelay == 0 {
+		config.Notification.RetryDelay = 30 * time.Second
+	}
+	if config.Notification.MiniProgramState == "" {
+		config.Notification.MiniProgramState = "formal"
+	}
+
+	// Analytics 默认值
+	if config.Analytics.Interval == 0 {
+		config.Analytics.Interval = time.Hour
+	}
+	if config.Analytics.BackfillDays == 0 {
+		config.Analytics.BackfillDays = 7
+	}
+
+	// Export 默认值
+	if config.Export.Dir == "" {
+		config.Export.Dir = "exports"
+	}
+	if config.Export.Workers == 0 {
+		config.Export.Workers = 1
+	}
+	if config.Export.SyncMaxRows == 0 {
+		config.Export.SyncMaxRows = 10000
+	}
+	if config.Export.MaxRows == 0 {
+		config.Export.MaxRows = 1000000
+	}
+	if config.Export.FileTTL == 0 {
+		config.Export.FileTTL = 24 * time.Hour
+	}
+	if config.Export.JobTimeout == 0 {
+		config.Export.JobTimeout = time.Hour
+	}
+
+	// Bulk 默认值
+	if config.Bulk.Workers == 0 {
+		config.Bulk.Workers = 1
+	}
+	if config.Bulk.MaxUsers == 0 {
+		config.Bulk.MaxUsers = 100000
+	}
+	if config.Bulk.BatchSize == 0 {
+		config.Bulk.BatchSize = 200
+	}
+	if config.Bulk.StaleTimeout == 0 {
+		config.Bulk.StaleTimeout = 10 * time.Minute
+	}
+
+	// UserTag 默认值
+	if config.UserTag.Interval == 0 {
+		config.UserTag.Interval = 6 * time.Hour
+	}
+
+	// Privacy 默认值
+	if config.Privacy.GracePeriod == 0 {
+		config.Privacy.GracePeriod = 15 * 24 * time.Hour
+	}
+	if config.Privacy.Interval == 0 {
+		config.Privacy.Interval = time.Hour
+	}
+	if config.Privacy.ExportCooldown == 0 {
+		config.Privacy.ExportCooldown = 10 * time.Minute
+	}
+
+	// Risk 默认值
+	if config.Risk.Window == 0 {
+		config.Risk.Window = time.Hour
+	}
+	if config.Risk.SignupPerIP == 0 {
+		config.Risk.SignupPerIP = 5
+	}
+	if config.Risk.SignupPerDevice == 0 {
+		config.Risk.SignupPerDevice = 3
+	}
+	if config.Risk.RewardPerUser == 0 {
+		config.Risk.RewardPerUser = 20
+	}
+	if config.Risk.RewardDailyPerUser == 0 {
+		config.Risk.RewardDailyPerUser = 60
+	}
+	if config.Risk.RewardUsersPerIP == 0 {
+		config.Risk.RewardUsersPerIP = 10
+	}
+	if config.Risk.RewardUsersPerDevice == 0 {
+		config.Risk.RewardUsersPerDevice = 3
+	}
+	if config.Risk.InvitePerInviter == 0 {
+		config.Risk.InvitePerInviter = 10
+	}
+	if config.Risk.ReduceScore == 0 {
+		config.Risk.ReduceScore = 30
+	}
+	if config.Risk.ReviewScore == 0 {
+		config.Risk.ReviewScore = 60
+	}
+	if config.Risk.BlockScore == 0 {
+		config.Risk.BlockScore = 90
+	}
+	if config.Risk.ReduceRatio == 0 {
+		config.Risk.ReduceRatio = 0.5
+	}
+
+	// Locale 默认值
+	if config.Locale.TimezoneChangeWindow == 0 {
+		config.Locale.TimezoneChangeWindow = 30 * 24 * time.Hour
+	}
+
+	// TaskVerify 默认值
+	if config.TaskVerify.ProofMaxAge == 0 {
+		config.TaskVerify.ProofMaxAge = 5 * time.Minute
+	}
+
+	// Invite 默认值
+	if config.Invite.LinkPath == "" {
+		config.Invite.LinkPath = "/invite"
+	}
+	if config.Invite.MiniProgramEnv == "" {
+		config.Invite.MiniProgramEnv = "release"
+	}
+	if config.Invite.QRCodeWidth == 0 {
+		config.Invite.QRCodeWidth = 430
+	}
+	if config.Invite.QRCodeCacheTTL == 0 {
+		config.Invite.QRCodeCacheTTL = 24 * time.Hour
+	}
+
+	// AdCallback 默认值
+	if config.AdCallback.AdMob.KeysURL == "" {
+		config.AdCallback.AdMob.KeysURL = "https://www.gstatic.com/admob/reward/verifier-keys.json"
+	}
+	if config.AdCallback.AdMob.KeysTTL == 0 {
+		config.AdCallback.AdMob.KeysTTL = 24 * time.Hour
+	}
 }
 
 // validateConfig 验证配置
@@ -258,6 +629,111 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("invalid JWT expire time: %v", config.JWT.ExpireTime)
 	}
 
+	// 验证账号配置
+	if config.Account.BcryptCost < 4 || config.Account.BcryptCost > 31 {
+		return fmt.Errorf("invalid bcrypt cost: %d", config.Account.BcryptCost)
+	}
+	if config.Account.PasswordPolicy.MinLength > config.Account.PasswordPolicy.MaxLength {
+		return fmt.Errorf("invalid password policy: min length %d > max length %d",
+			config.Account.PasswordPolicy.MinLength, config.Account.PasswordPolicy.MaxLength)
+	}
+
+	// 验证管理员安全配置
+	if config.AdminSecurity.TOTPSkew < 0 || config.AdminSecurity.TOTPSkew > 3 {
+		return fmt.Errorf("invalid totp skew: %d", config.AdminSecurity.TOTPSkew)
+	}
+	if config.AdminSecurity.MaxLoginAttempts < 0 {
+		return fmt.Errorf("invalid max login attempts: %d", config.AdminSecurity.MaxLoginAttempts)
+	}
+	if config.AdminSecurity.MaxAccountLoginAttempts < 0 {
+		return fmt.Errorf("invalid max account login attempts: %d", config.AdminSecurity.MaxAccountLoginAttempts)
+	}
+	if config.AdminSecurity.RecoveryCodeCount < 1 || config.AdminSecurity.RecoveryCodeCount > 20 {
+		return fmt.Errorf("invalid recovery code count: %d", config.AdminSecurity.RecoveryCodeCount)
+	}
+	if config.AdminSecurity.ImpersonationTTL < 0 || config.AdminSecurity.ImpersonationTTL > time.Hour {
+		return fmt.Errorf("invalid impersonation ttl: %s", config.AdminSecurity.ImpersonationTTL)
+	}
+
+	// 验证风控配置
+	if !(config.Risk.ReduceScore <= config.Risk.ReviewScore && config.Risk.ReviewScore <= config.Risk.BlockScore) {
+		return fmt.Errorf("invalid risk scores: reduce %d, review %d, block %d",
+			config.Risk.ReduceScore, config.Risk.ReviewScore, config.Risk.BlockScore)
+	}
+	if config.Risk.ReduceRatio < 0 || config.Risk.ReduceRatio > 1 {
+		return fmt.Errorf("invalid risk reduce ratio: %v", config.Risk.ReduceRatio)
+	}
+
+	// 验证任务完成校验配置
+	for keyID, secret := range config.TaskVerify.ProofKeys {
+		if len(secret) < 16 {
+			return fmt.Errorf("task proof key %q must be at least 16 characters", keyID)
+		}
+	}
+
+	// 验证业务时区配置
+	if config.Locale.Timezone != "" {
+		if _, err := time.LoadLocation(config.Locale.Timezone); err != nil {
+			return fmt.Errorf("invalid locale timezone %q: %v", config.Locale.Timezone, err)
+		}
+	}
+	if config.Locale.TimezoneChangeWindow < 0 {
+		return fmt.Errorf("invalid locale timezone change window: %v", config.Locale.TimezoneChangeWindow)
+	}
+
+	// 验证广告回调配置
+	if config.AdCallback.Pangle.Enabled && config.AdCallback.Pangle.Secret == "" {
+		return fmt.Errorf("pangle ad callback secret is required")
+	}
+	if config.AdCallback.YLH.Enabled && config.AdCallback.YLH.Secret == "" {
+		return fmt.Errorf("ylh ad callback secret is required")
+	}
+
+	// 验证邀请配置
+	if config.Invite.LinkBaseURL != "" {
+		u, err := url.Parse(config.Invite.LinkBaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid invite link base url: %s", config.Invite.LinkBaseURL)
+		}
+	}
+	if !strings.HasPrefix(config.Invite.LinkPath, "/") {
+		return fmt.Errorf("invite link path must start with /: %s", config.Invite.LinkPath)
+	}
+	switch config.Invite.MiniProgramEnv {
+	case "release", "trial", "develop":
+	default:
+		return fmt.Errorf("invalid invite mini program env: %s", config.Invite.MiniProgramEnv)
+	}
+	if config.Invite.QRCodeWidth < 280 || config.Invite.QRCodeWidth > 1280 {
+		return fmt.Errorf("invalid invite qr code width: %d", config.Invite.QRCodeWidth)
+	}
+
+	// 验证邮件配置
+	switch config.Mail.Driver {
+	case "smtp":
+		if config.Mail.Host == "" {
+			return fmt.Errorf("mail host is required")
+		}
+		if config.Mail.From == "" {
+			return fmt.Errorf("mail from address is required")
+		}
+	case "file", "stdout":
+	default:
+		return fmt.Errorf("unsupported mail driver: %s", config.Mail.Driver)
+	}
+
+	// 验证通知配置
+	switch config.Notification.MiniProgramState {
+	case "developer", "trial", "formal":
+	default:
+		return fmt.Errorf("invalid mini program state: %s", config.Notification.MiniProgramState)
+	}
+	for name, tpl := range config.Notification.Templates {
+		if tpl.TemplateID == "" {
+			return fmt.Errorf("notification template %s: template id is required", name)
+		}
+	}
+
 	// 验证Redis配置
 	if config.Redis.Host == "" {
 		return fmt.Errorf("redis host is required")
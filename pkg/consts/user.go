@@ -25,6 +25,9 @@ const (
 	LoginStatusFailed  = 0
 	LoginStatusSuccess = 1
 
-	UserId   = "user_id"
-	SetToken = "Set-Token"
+	UserId         = "user_id"
+	UserName       = "user_name"
+	ImpersonatorId = "impersonator_id" // 模拟登录的管理员ID，仅使用模拟令牌的请求存在
+	RequestId      = "request_id"
+	SetToken       = "Set-Token"
 )
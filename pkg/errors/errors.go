@@ -34,7 +34,7 @@ func (e *Error) HTTPStatus() int {
 		return http.StatusForbidden
 	case ErrCodeNotFound:
 		return http.StatusNotFound
-	case ErrCodeInvalidParams:
+	case ErrCodeInvalidParams, ErrCodeTaskVerifyFailed:
 		return http.StatusBadRequest
 	case ErrCodeServiceUnavailable:
 		return http.StatusServiceUnavailable
@@ -72,16 +72,24 @@ const (
 	ErrCodeInvalidPhone      = 2005 // 无效的手机号
 	ErrCodeInvalidEmail      = 2006 // 无效的邮箱
 	ErrCodeInvalidVerifyCode = 2007 // 无效的验证码
+	ErrCodeEmailNotVerified  = 2008 // 邮箱未验证
+	ErrCodeWeakPassword      = 2009 // 密码强度不足
+	ErrCodeInvalidToken      = 2010 // 无效或已过期的令牌
+	ErrCodeAccountLocked     = 2011 // 账号已锁定
+	ErrCodeInvalidOTP        = 2012 // 动态验证码错误
 
 	// 微信相关错误码 (3000-3999)
-	ErrCodeWechatLoginFailed = 3000 // 微信登录失败
-	ErrCodeWechatPayFailed   = 3001 // 微信支付失败
+	ErrCodeWechatLoginFailed   = 3000 // 微信登录失败
+	ErrCodeWechatPayFailed     = 3001 // 微信支付失败
+	ErrCodeWechatDecryptFailed = 3002 // 微信数据解密失败
+	ErrCodeWechatAPIFailed     = 3003 // 微信接口调用失败
 
 	// 代币相关错误码 (4000-4999)
 	ErrCodeInsufficientBalance = 4000 // 余额不足
 	ErrCodeInvalidAmount       = 4001 // 无效的金额
 	ErrCodeTaskNotAvailable    = 4002 // 任务不可用
 	ErrCodeTaskLimitExceeded   = 4003 // 任务次数超限
+	ErrCodeTaskVerifyFailed    = 4004 // 任务完成校验未通过
 
 	// 系统级错误码 (10000-10099)
 	ErrCodeSystemError     = 10000
@@ -109,6 +117,9 @@ var (
 	ErrInvalidPhone      = New(ErrCodeInvalidPhone, "无效的手机号", nil)
 	ErrInvalidEmail      = New(ErrCodeInvalidEmail, "无效的邮箱地址", nil)
 	ErrInvalidVerifyCode = New(ErrCodeInvalidVerifyCode, "无效的验证码", nil)
+	ErrEmailNotVerified  = New(ErrCodeEmailNotVerified, "邮箱尚未验证", nil)
+	ErrInvalidToken      = New(ErrCodeInvalidToken, "链接无效或已过期", nil)
+	ErrInvalidOTP        = New(ErrCodeInvalidOTP, "动态验证码错误", nil)
 
 	// 微信相关错误
 	ErrWechatLoginFailed = New(ErrCodeWechatLoginFailed, "微信登录失败", nil)
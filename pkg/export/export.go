@@ -0,0 +1,90 @@
+// Package export 提供按行流式写出表格文件的能力，支持 CSV 和 XLSX，写出过程中不在内存中保留已写的行
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 导出文件格式
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Writer 按行写出表格，第一行通常为表头；Close 写出文件尾部，之后不能再写
+type Writer interface {
+	Write(row []string) error
+	Close() error
+}
+
+// NewWriter 按格式创建表格写出器
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatXLSX:
+		return newXLSXWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// ContentType 返回导出格式对应的 MIME 类型
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// csvFlushEvery 每写出多少行刷新一次缓冲
+const csvFlushEvery = 500
+
+type csvWriter struct {
+	w    *csv.Writer
+	rows int
+}
+
+// newCSVWriter 写出带 UTF-8 BOM 的 CSV，便于 Excel 直接打开
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	if _, err := w.Write([]byte("\xEF\xBB\xBF")); err != nil {
+		return nil, err
+	}
+	return &csvWriter{w: csv.NewWriter(w)}, nil
+}
+
+func (c *csvWriter) Write(row []string) error {
+	cells := make([]string, len(row))
+	for i, v := range row {
+		cells[i] = escapeFormula(v)
+	}
+	if err := c.w.Write(cells); err != nil {
+		return err
+	}
+	c.rows++
+	if c.rows%csvFlushEvery == 0 {
+		c.w.Flush()
+		return c.w.Error()
+	}
+	return nil
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// escapeFormula 防止 CSV 公式注入：以 = + - @ 开头且不是数字的单元格前加单引号
+func escapeFormula(v string) string {
+	if v == "" || !strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return v
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return v
+	}
+	return "'" + v
+}
@@ -1,6 +1,8 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -12,6 +14,15 @@ var (
 	ErrInvalidToken = errors.New("invalid token")
 )
 
+// 管理员令牌作用域，空字符串表示完整权限
+const (
+	ScopePasswordChange = "password_change" // 仅允许修改密码（默认口令首次登录）
+	ScopeTwoFactorSetup = "2fa_setup"       // 仅允许绑定双因素认证（角色强制要求但尚未绑定）
+)
+
+// ScopeImpersonation 用户令牌作用域：管理员模拟用户的只读令牌
+const ScopeImpersonation = "impersonation"
+
 // Claims 自定义的 JWT 声明
 type Claims struct {
 	UserID   int64  `json:"user_id"`
@@ -20,6 +31,9 @@ type Claims struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 	Role     string `json:"role"`
+	Scope    string `json:"scope,omitempty"`
+	// ImpersonatorID 模拟登录的管理员ID，仅模拟令牌
+	ImpersonatorID int64 `json:"impersonator_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -42,6 +56,56 @@ func GenerateToken(userID int64, isAdmin bool, userName, password, role string)
 	return token.SignedString([]byte(config.Get().JWT.Secret))
 }
 
+// GenerateAdminToken 生成管理员 JWT token，scope 非空时为受限令牌
+func GenerateAdminToken(adminID int64, isAdmin bool, userName, role, scope string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		UserID:   adminID,
+		IsAdmin:  isAdmin,
+		Username: userName,
+		Role:     role,
+		Scope:    scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(config.Get().JWT.Secret))
+}
+
+// GenerateImpersonationToken 生成管理员模拟用户的只读令牌，返回令牌和会话ID（jti），会话ID用于关联审计日志
+func GenerateImpersonationToken(userID string, adminID int64, adminName string, ttl time.Duration) (string, string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	sessionID := hex.EncodeToString(buf)
+
+	now := time.Now()
+	claims := Claims{
+		Sub:            userID,
+		Username:       adminName,
+		Scope:          ScopeImpersonation,
+		ImpersonatorID: adminID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString([]byte(config.Get().JWT.Secret))
+	if err != nil {
+		return "", "", err
+	}
+	return signed, sessionID, nil
+}
+
 // ParseToken 解析 JWT token
 func ParseToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
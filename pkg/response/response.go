@@ -30,8 +30,12 @@ func Success(c *gin.Context, data interface{}) {
 	})
 }
 
+// ErrorKey 上下文中保存业务错误的键，供审计等中间件判断请求是否成功
+const ErrorKey = "response_error"
+
 // Error 返回错误响应
 func Error(c *gin.Context, err error) {
+	c.Set(ErrorKey, err)
 	if e, ok := err.(*errors.Error); ok {
 		c.JSON(http.StatusOK, Response{
 			Code:    e.Code,
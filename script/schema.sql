@@ -10,9 +10,13 @@ CREATE TABLE IF NOT EXISTS `users` (
                          `phone`   VARCHAR(20)  DEFAULT NULL             COMMENT '手机号，用户使用手机号注册/登录时的号码，唯一',
                          `email`   VARCHAR(100) DEFAULT NULL             COMMENT '邮箱，用户邮箱地址，唯一',
                          `password_hash` VARCHAR(255) DEFAULT NULL       COMMENT '密码哈希，用于手机号/邮箱注册的情况，第三方登录用户此字段为空',
+                         `email_verified_at` DATETIME DEFAULT NULL       COMMENT '邮箱验证时间，为空表示未验证',
+                         `phone_verified_at` DATETIME DEFAULT NULL       COMMENT '手机号验证时间，为空表示注册时填写、未经验证',
                          `nickname` VARCHAR(50)  DEFAULT NULL            COMMENT '用户昵称，显示名称',
                          `avatar_url` VARCHAR(255) DEFAULT NULL          COMMENT '头像URL，用户头像图片链接',
                          `language` VARCHAR(10)  NOT NULL DEFAULT 'zh-CN' COMMENT '界面语言偏好，如 zh-CN、en-US 等',
+                         `timezone` VARCHAR(64)  DEFAULT NULL COMMENT '用户时区（IANA 时区名），为空使用业务时区',
+                         `timezone_set_at` DATETIME DEFAULT NULL COMMENT '最近一次修改时区的时间，用于限制修改频率',
                          `status`  TINYINT       NOT NULL DEFAULT 1      COMMENT '账号状态：1=正常，0=禁用',
                          `token_balance` INT     NOT NULL DEFAULT 0      COMMENT '代币余额',
                          `inviter_id` VARCHAR(13) DEFAULT NULL COMMENT '邀请人ID',
@@ -33,15 +37,74 @@ CREATE TABLE IF NOT EXISTS `admin_users` (
                                `admin_id`     INT          NOT NULL AUTO_INCREMENT COMMENT '管理员ID，主键，自增',
                                `username`     VARCHAR(50)  NOT NULL               COMMENT '登录用户名，唯一',
                                `password_hash` VARCHAR(255) NOT NULL              COMMENT '密码哈希',
-                               `role`         VARCHAR(20)  NOT NULL DEFAULT 'admin' COMMENT '角色，如 superadmin、admin',
+                               `role`         VARCHAR(20)  NOT NULL DEFAULT 'admin' COMMENT '角色编码，引用 admin_roles.code',
                                `status`       TINYINT      NOT NULL DEFAULT 1     COMMENT '账号状态：1=正常，0=停用',
                                `created_at`   DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
                                `last_login_at` DATETIME    DEFAULT NULL           COMMENT '最后登录时间',
+                               `totp_secret`  VARCHAR(255) DEFAULT NULL           COMMENT '加密后的TOTP密钥',
+                               `two_factor_enabled` TINYINT(1) NOT NULL DEFAULT 0 COMMENT '是否已启用双因素认证',
+                               `must_change_password` TINYINT(1) NOT NULL DEFAULT 0 COMMENT '下次登录是否必须修改密码',
+                               `password_changed_at` DATETIME DEFAULT NULL        COMMENT '最近一次修改密码时间',
                                PRIMARY KEY (`admin_id`),
                                UNIQUE KEY `uk_admin_username` (`username`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
   COMMENT='管理员用户表，存储后台管理员账号信息';
 
+-- 管理员角色表，admin_users.role 引用角色编码
+CREATE TABLE IF NOT EXISTS `admin_roles` (
+    `role_id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '角色ID，主键，自增',
+    `code` VARCHAR(20) NOT NULL COMMENT '角色编码',
+    `name` VARCHAR(50) NOT NULL COMMENT '角色名称',
+    `description` VARCHAR(255) DEFAULT NULL COMMENT '角色说明',
+    `is_system` TINYINT(1) NOT NULL DEFAULT 0 COMMENT '是否内置角色，内置角色不可删除',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (`role_id`),
+    UNIQUE KEY `uk_admin_role_code` (`code`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='管理员角色表';
+
+-- 角色权限表
+CREATE TABLE IF NOT EXISTS `admin_role_permissions` (
+    `role_id` BIGINT NOT NULL COMMENT '角色ID',
+    `permission` VARCHAR(50) NOT NULL COMMENT '权限标识，如 users:read，* 表示全部权限',
+    PRIMARY KEY (`role_id`, `permission`),
+    CONSTRAINT `fk_admin_role_permissions_role` FOREIGN KEY (`role_id`) REFERENCES `admin_roles` (`role_id`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='角色权限表';
+
+-- 管理员邀请表
+CREATE TABLE IF NOT EXISTS `admin_invitations` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键，自增',
+    `token_hash` CHAR(64) NOT NULL COMMENT '邀请令牌的SHA-256哈希',
+    `username` VARCHAR(50) NOT NULL COMMENT '预分配的用户名',
+    `role` VARCHAR(20) NOT NULL COMMENT '接受后获得的角色',
+    `note` VARCHAR(255) DEFAULT NULL COMMENT '备注',
+    `created_by` INT NOT NULL COMMENT '邀请人（管理员ID）',
+    `expires_at` DATETIME NOT NULL COMMENT '过期时间',
+    `used_at` DATETIME DEFAULT NULL COMMENT '接受时间，非空表示已使用',
+    `admin_id` INT DEFAULT NULL COMMENT '接受后创建的管理员ID',
+    `revoked_at` DATETIME DEFAULT NULL COMMENT '撤销时间',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_admin_invitations_token` (`token_hash`),
+    KEY `idx_admin_invitations_username` (`username`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='管理员邀请表，一次性使用';
+
+-- 管理员双因素认证恢复码表
+CREATE TABLE IF NOT EXISTS `admin_recovery_codes` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键，自增',
+    `admin_id` INT NOT NULL COMMENT '管理员ID',
+    `code_hash` CHAR(64) NOT NULL COMMENT '恢复码的SHA-256哈希',
+    `used_at` DATETIME DEFAULT NULL COMMENT '使用时间，非空表示已失效',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '生成时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_recovery_codes_admin` (`admin_id`),
+    CONSTRAINT `fk_admin_recovery_codes_admin` FOREIGN KEY (`admin_id`) REFERENCES `admin_users` (`admin_id`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='管理员双因素认证恢复码表';
+
 -- 3. 系统配置表，存储全局系统参数
 CREATE TABLE IF NOT EXISTS `system_config` (
                                  `config_key`   VARCHAR(50)  NOT NULL                COMMENT '配置键，主键，如 TOKEN_EXCHANGE_RATE',
@@ -64,6 +127,8 @@ CREATE TABLE IF NOT EXISTS `reward_tasks` (
                                 `valid_to`      DATE     DEFAULT NULL           COMMENT '任务截止时间，NULL表示永久有效',
                                 `repeatable`    TINYINT      NOT NULL DEFAULT 1     COMMENT '是否可重复完成：1=是，0=否',
                                 `status`        TINYINT      NOT NULL DEFAULT 1     COMMENT '任务状态：1=启用，0=停用',
+                                `verify_config` JSON         DEFAULT NULL           COMMENT '任务完成校验配置，格式由 task_key 对应的校验器决定',
+                                `eligibility`   JSON         DEFAULT NULL           COMMENT '参与规则：新用户、平台、登录方式、语言、余额、是否购买、A/B 分桶',
                                 PRIMARY KEY (`task_id`),
                                 UNIQUE KEY `uk_task_key` (`task_key`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
@@ -120,6 +185,7 @@ CREATE TABLE IF NOT EXISTS `user_login_log` (
                                   `device_info`  VARCHAR(100) DEFAULT NULL         COMMENT '设备信息或User-Agent简述',
                                   PRIMARY KEY (`log_id`),
                                   KEY `idx_login_log_user` (`user_id`),
+                                  KEY `idx_login_log_time` (`login_time`),
                                   CONSTRAINT `fk_login_log_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`user_id`) ON DELETE CASCADE ON UPDATE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
   COMMENT='用户登录日志表';
@@ -186,6 +252,7 @@ CREATE TABLE IF NOT EXISTS `token_records` (
                                  KEY `idx_token_records_feature` (`feature_id`),
                                  KEY `idx_token_records_order` (`order_id`),
                                  KEY `idx_token_records_admin` (`admin_id`),
+                                 KEY `idx_token_records_time` (`change_time`),
                                  CONSTRAINT `fk_token_records_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`user_id`) ON DELETE CASCADE ON UPDATE CASCADE,
                                  CONSTRAINT `fk_token_records_task` FOREIGN KEY (`task_id`) REFERENCES `reward_tasks`(`task_id`) ON DELETE SET NULL ON UPDATE CASCADE,
                                  CONSTRAINT `fk_token_records_feature` FOREIGN KEY (`feature_id`) REFERENCES `token_consume_rules`(`feature_id`) ON DELETE SET NULL ON UPDATE CASCADE,
@@ -220,10 +287,20 @@ CREATE TABLE IF NOT EXISTS task_completion_records (
     user_id VARCHAR(13) NOT NULL COMMENT '用户ID',
     task_id INT NOT NULL COMMENT '任务ID',
     token_reward INT NOT NULL COMMENT '获得的代币奖励',
+    checkin_date DATE DEFAULT NULL COMMENT '签到日期，仅连续签到任务使用',
+    streak_day INT NOT NULL DEFAULT 0 COMMENT '截至签到日期的连续签到天数',
+    makeup TINYINT NOT NULL DEFAULT 0 COMMENT '是否补签：1=是，0=否',
+    extra_data JSON DEFAULT NULL COMMENT '上报完成时提交的数据，作为校验凭证留存',
+    revoked_at TIMESTAMP NULL DEFAULT NULL COMMENT '撤销时间，撤销后不计入任务统计',
+    revoked_by BIGINT DEFAULT NULL COMMENT '撤销的管理员ID',
+    revoke_reason VARCHAR(255) DEFAULT NULL COMMENT '撤销原因',
+    revoked_tokens INT NOT NULL DEFAULT 0 COMMENT '撤销时实际扣回的代币数',
     completed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '完成时间',
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-    INDEX idx_user_task (user_id, task_id),
+    INDEX idx_task_completion_user_task_time (user_id, task_id, completed_at) COMMENT '用户任务状态：当天次数、总次数、最近完成时间',
+    INDEX idx_task_completion_task_time (task_id, completed_at) COMMENT '任务统计',
+    UNIQUE KEY uk_task_completion_checkin (user_id, task_id, checkin_date),
     INDEX idx_completed_at (completed_at)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='任务完成记录表';
 
@@ -235,25 +312,404 @@ CREATE TABLE IF NOT EXISTS notifications (
     title VARCHAR(128) NOT NULL COMMENT '通知标题',
     content TEXT NOT NULL COMMENT '通知内容',
     status TINYINT NOT NULL DEFAULT 0 COMMENT '状态：0-未读，1-已读',
+    template_id VARCHAR(64) DEFAULT NULL COMMENT '微信订阅消息模板ID',
+    payload JSON DEFAULT NULL COMMENT '订阅消息模板数据',
+    send_status TINYINT NOT NULL DEFAULT 0 COMMENT '推送状态：0-无需推送，1-待推送，2-推送成功，3-推送失败',
+    send_attempts INT NOT NULL DEFAULT 0 COMMENT '推送尝试次数',
+    send_error VARCHAR(255) DEFAULT NULL COMMENT '最近一次推送失败原因',
+    sent_at TIMESTAMP NULL DEFAULT NULL COMMENT '推送成功时间',
+    broadcast_id BIGINT DEFAULT NULL COMMENT '群发任务ID，非群发通知为空',
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     INDEX idx_user_status (user_id, status),
-    INDEX idx_created_at (created_at)
+    INDEX idx_created_at (created_at),
+    INDEX idx_notifications_broadcast (broadcast_id)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='通知表';
 
+-- 通知群发任务表
+CREATE TABLE IF NOT EXISTS `notification_broadcasts` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键，自增',
+    `admin_id` BIGINT NOT NULL COMMENT '发起群发的管理员ID',
+    `title` VARCHAR(128) NOT NULL COMMENT '通知标题',
+    `content` TEXT NOT NULL COMMENT '通知内容',
+    `filter` JSON NOT NULL COMMENT '用户筛选条件',
+    `status` TINYINT NOT NULL DEFAULT 0 COMMENT '状态：0=待执行，1=执行中，2=已完成，3=失败',
+    `last_user_id` VARCHAR(13) NOT NULL DEFAULT '' COMMENT '已处理到的用户ID，用于中断后续跑',
+    `sent_count` BIGINT NOT NULL DEFAULT 0 COMMENT '已投递用户数',
+    `error` VARCHAR(255) DEFAULT NULL COMMENT '失败原因',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    `finished_at` DATETIME DEFAULT NULL COMMENT '完成时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_broadcasts_status` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='通知群发任务表';
+
+-- 管理端操作审计日志表
+CREATE TABLE IF NOT EXISTS `admin_audit_logs` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键，自增',
+    `admin_id` BIGINT NOT NULL COMMENT '操作人ID',
+    `admin_name` VARCHAR(50) NOT NULL DEFAULT '' COMMENT '操作人用户名',
+    `action` VARCHAR(64) NOT NULL COMMENT '操作类型，如 user.update',
+    `target_type` VARCHAR(32) NOT NULL DEFAULT '' COMMENT '操作对象类型',
+    `target_id` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '操作对象ID',
+    `before_data` JSON DEFAULT NULL COMMENT '变更前数据',
+    `after_data` JSON DEFAULT NULL COMMENT '变更后数据',
+    `changes` JSON DEFAULT NULL COMMENT '字段级差异',
+    `params` JSON DEFAULT NULL COMMENT '请求参数（敏感字段已脱敏）',
+    `result` TINYINT NOT NULL DEFAULT 1 COMMENT '执行结果：0=失败，1=成功',
+    `error` VARCHAR(255) DEFAULT NULL COMMENT '失败原因',
+    `ip` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '客户端IP',
+    `user_agent` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '客户端UA',
+    `request_id` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '请求ID',
+    `method` VARCHAR(10) NOT NULL COMMENT '请求方法',
+    `path` VARCHAR(255) NOT NULL COMMENT '请求路径',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '操作时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_audit_admin_time` (`admin_id`, `created_at`),
+    KEY `idx_audit_target` (`target_type`, `target_id`),
+    KEY `idx_audit_action` (`action`),
+    KEY `idx_audit_request` (`request_id`),
+    KEY `idx_audit_created` (`created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='管理端操作审计日志表，只增不改';
+
+-- 运营数据日汇总表
+CREATE TABLE IF NOT EXISTS `analytics_daily_stats` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键，自增',
+    `stat_date` DATE NOT NULL COMMENT '统计日期',
+    `metric` VARCHAR(32) NOT NULL COMMENT '指标，如 new_users、revenue、tokens_consumed',
+    `dimension` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '维度值，空字符串表示汇总',
+    `count` BIGINT NOT NULL DEFAULT 0 COMMENT '次数/人数',
+    `amount` DECIMAL(16,2) NOT NULL DEFAULT 0 COMMENT '金额/代币数',
+    `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '最近一次重算时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_analytics_daily` (`stat_date`, `metric`, `dimension`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='运营数据日汇总表，由定时任务从业务表重算';
+
+-- 管理端异步导出任务表
+CREATE TABLE IF NOT EXISTS `admin_export_jobs` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键，自增',
+    `admin_id` BIGINT NOT NULL COMMENT '发起导出的管理员ID，只有本人可下载',
+    `type` VARCHAR(32) NOT NULL COMMENT '导出数据类型：users/orders/token_records/login_logs',
+    `format` VARCHAR(10) NOT NULL COMMENT '文件格式：csv/xlsx',
+    `filter` JSON NOT NULL COMMENT '筛选条件',
+    `status` VARCHAR(20) NOT NULL DEFAULT 'pending' COMMENT '任务状态：pending/running/success/failed/expired',
+    `row_count` BIGINT NOT NULL DEFAULT 0 COMMENT '已导出行数',
+    `file_name` VARCHAR(128) NOT NULL DEFAULT '' COMMENT '下载文件名',
+    `file_path` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '服务器上的文件路径',
+    `file_size` BIGINT NOT NULL DEFAULT 0 COMMENT '文件大小（字节）',
+    `error` VARCHAR(255) DEFAULT NULL COMMENT '失败原因',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    `started_at` DATETIME DEFAULT NULL COMMENT '开始导出时间',
+    `finished_at` DATETIME DEFAULT NULL COMMENT '完成时间',
+    `expires_at` DATETIME DEFAULT NULL COMMENT '文件过期时间，过期后删除文件',
+    PRIMARY KEY (`id`),
+    KEY `idx_export_admin` (`admin_id`),
+    KEY `idx_export_status` (`status`),
+    KEY `idx_export_expires` (`expires_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='管理端异步导出任务表';
+
+-- 用户批量操作任务表
+CREATE TABLE IF NOT EXISTS `user_bulk_jobs` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键，自增',
+    `admin_id` BIGINT NOT NULL COMMENT '发起人',
+    `operation` VARCHAR(20) NOT NULL COMMENT '操作类型：tokens_grant/disable/enable',
+    `amount` INT NOT NULL DEFAULT 0 COMMENT '代币增减数量，仅 tokens_grant',
+    `remark` VARCHAR(255) DEFAULT NULL COMMENT '备注，写入代币记录',
+    `source` VARCHAR(10) NOT NULL COMMENT '用户来源：filter=按筛选条件，ids=ID列表',
+    `filter` JSON DEFAULT NULL COMMENT '筛选条件，仅 filter 来源',
+    `dry_run` TINYINT(1) NOT NULL DEFAULT 0 COMMENT '试运行，只计算结果不落库',
+    `status` VARCHAR(20) NOT NULL DEFAULT 'pending' COMMENT '任务状态：pending/running/success/failed/cancelled',
+    `total` INT NOT NULL DEFAULT 0 COMMENT '目标用户数',
+    `processed` INT NOT NULL DEFAULT 0 COMMENT '已处理数',
+    `succeeded` INT NOT NULL DEFAULT 0 COMMENT '成功数',
+    `failed` INT NOT NULL DEFAULT 0 COMMENT '失败数',
+    `skipped` INT NOT NULL DEFAULT 0 COMMENT '跳过数',
+    `error` VARCHAR(255) DEFAULT NULL COMMENT '任务异常原因',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '最近一次进度更新时间',
+    `started_at` DATETIME DEFAULT NULL COMMENT '开始执行时间',
+    `finished_at` DATETIME DEFAULT NULL COMMENT '结束时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_bulk_jobs_admin` (`admin_id`),
+    KEY `idx_bulk_jobs_status` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='用户批量操作任务表';
+
+-- 用户批量操作结果表
+CREATE TABLE IF NOT EXISTS `user_bulk_job_items` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键，自增',
+    `job_id` BIGINT NOT NULL COMMENT '任务ID',
+    `user_id` VARCHAR(13) NOT NULL COMMENT '用户ID',
+    `result` VARCHAR(10) NOT NULL DEFAULT 'pending' COMMENT '处理结果：pending/success/failed/skipped',
+    `message` VARCHAR(255) DEFAULT NULL COMMENT '失败或跳过原因',
+    `balance_before` INT DEFAULT NULL COMMENT '调整前余额，仅 tokens_grant',
+    `balance_after` INT DEFAULT NULL COMMENT '调整后余额，仅 tokens_grant',
+    `record_id` BIGINT DEFAULT NULL COMMENT '代币记录ID',
+    `processed_at` DATETIME DEFAULT NULL COMMENT '处理时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_bulk_item_user` (`job_id`, `user_id`),
+    KEY `idx_bulk_item_result` (`job_id`, `result`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='用户批量操作结果表';
+
+-- 用户标签表
+CREATE TABLE IF NOT EXISTS `user_tags` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键，自增',
+    `code` VARCHAR(50) NOT NULL COMMENT '标签编码',
+    `name` VARCHAR(50) NOT NULL COMMENT '标签名称',
+    `description` VARCHAR(255) DEFAULT NULL COMMENT '标签说明',
+    `type` VARCHAR(10) NOT NULL COMMENT '标签类型：manual=手动，rule=规则',
+    `rule` JSON DEFAULT NULL COMMENT '标签规则，仅规则标签',
+    `member_count` BIGINT NOT NULL DEFAULT 0 COMMENT '用户数',
+    `computed_at` DATETIME DEFAULT NULL COMMENT '规则最近一次重算时间',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_user_tags_code` (`code`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='用户标签表';
+
+-- 用户标签关联表
+CREATE TABLE IF NOT EXISTS `user_tag_members` (
+    `tag_id` BIGINT NOT NULL COMMENT '标签ID',
+    `user_id` VARCHAR(13) NOT NULL COMMENT '用户ID',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '打上标签的时间',
+    PRIMARY KEY (`tag_id`, `user_id`),
+    KEY `idx_user_tag_members_user` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='用户标签关联表';
+
+-- 任务参与条件标签表
+CREATE TABLE IF NOT EXISTS `reward_task_tags` (
+    `task_id` INT NOT NULL COMMENT '任务ID',
+    `tag_id` BIGINT NOT NULL COMMENT '标签ID',
+    PRIMARY KEY (`task_id`, `tag_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='任务参与条件标签表，任务关联标签后只有带其中任一标签的用户可以参与';
+
+-- 账号注销申请表
+CREATE TABLE IF NOT EXISTS `user_deletion_requests` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键，自增',
+    `user_id` VARCHAR(13) NOT NULL COMMENT '用户ID',
+    `status` VARCHAR(10) NOT NULL COMMENT '申请状态：pending=冷静期内，cancelled=已撤销，completed=已匿名化',
+    `reason` VARCHAR(255) DEFAULT NULL COMMENT '注销原因',
+    `scheduled_at` DATETIME NOT NULL COMMENT '冷静期结束、计划执行匿名化的时间',
+    `cancelled_at` DATETIME DEFAULT NULL COMMENT '撤销时间',
+    `completed_at` DATETIME DEFAULT NULL COMMENT '完成匿名化时间',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '申请时间',
+    `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_user_deletion_user` (`user_id`),
+    KEY `idx_user_deletion_status_scheduled` (`status`, `scheduled_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='账号注销申请表，冷静期结束后匿名化个人信息，财务记录保留';
+
+-- 风控事件表
+CREATE TABLE IF NOT EXISTS `risk_events` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键，自增',
+    `event_type` VARCHAR(10) NOT NULL COMMENT '事件类型：signup=注册赠送，reward=奖励上报，invite=邀请奖励',
+    `action` VARCHAR(32) NOT NULL DEFAULT '' COMMENT '具体行为，如奖励类型',
+    `user_id` VARCHAR(13) NOT NULL COMMENT '发起行为的用户ID',
+    `related_user_id` VARCHAR(13) DEFAULT NULL COMMENT '关联用户ID，邀请事件为邀请人',
+    `ip` VARCHAR(45) NOT NULL DEFAULT '' COMMENT '客户端IP',
+    `device_id` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '设备指纹',
+    `score` INT NOT NULL DEFAULT 0 COMMENT '风险分 0-100',
+    `decision` VARCHAR(10) NOT NULL COMMENT '处置结果：allow/reduce/review/block',
+    `reasons` JSON DEFAULT NULL COMMENT '命中的规则',
+    `amount` INT NOT NULL DEFAULT 0 COMMENT '原始奖励代币数',
+    `granted_amount` INT NOT NULL DEFAULT 0 COMMENT '实际发放代币数',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '发生时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_risk_events_ip` (`event_type`, `ip`),
+    KEY `idx_risk_events_device` (`event_type`, `device_id`),
+    KEY `idx_risk_events_user` (`user_id`, `created_at`),
+    KEY `idx_risk_events_related` (`related_user_id`),
+    KEY `idx_risk_events_decision` (`decision`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='风控事件表，记录注册、奖励、邀请的风险评估结果';
+
+-- 风控审核表
+CREATE TABLE IF NOT EXISTS `risk_reviews` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键，自增',
+    `event_id` BIGINT NOT NULL COMMENT '风控事件ID',
+    `event_type` VARCHAR(10) NOT NULL COMMENT '事件类型',
+    `user_id` VARCHAR(13) NOT NULL COMMENT '发起行为的用户ID',
+    `beneficiary_id` VARCHAR(13) NOT NULL COMMENT '奖励受益人，邀请事件为邀请人',
+    `amount` INT NOT NULL COMMENT '暂扣的代币数',
+    `change_type` VARCHAR(20) NOT NULL COMMENT '补发时代币记录的变动类型',
+    `remark` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '补发时代币记录的备注',
+    `status` VARCHAR(10) NOT NULL COMMENT '审核状态：pending=待审核，approved=已通过，rejected=已拒绝',
+    `reviewer_id` BIGINT DEFAULT NULL COMMENT '审核管理员ID',
+    `review_note` VARCHAR(255) DEFAULT NULL COMMENT '审核备注',
+    `record_id` BIGINT DEFAULT NULL COMMENT '补发的代币记录ID',
+    `reviewed_at` DATETIME DEFAULT NULL COMMENT '审核时间',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_risk_reviews_event` (`event_id`),
+    KEY `idx_risk_reviews_user` (`user_id`),
+    KEY `idx_risk_reviews_status` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='风控审核表，被暂扣的奖励审核通过后补发';
+
+-- 激励视频广告回调记录表
+CREATE TABLE IF NOT EXISTS `ad_reward_callbacks` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键，自增',
+    `network` VARCHAR(10) NOT NULL COMMENT '广告平台：admob/pangle/ylh',
+    `transaction_id` VARCHAR(128) NOT NULL COMMENT '平台交易号',
+    `user_id` VARCHAR(13) NOT NULL COMMENT '用户ID',
+    `task_id` INT DEFAULT NULL COMMENT '匹配的任务ID',
+    `status` VARCHAR(10) NOT NULL COMMENT '处理结果：granted=已发放，rejected=未发放',
+    `reason` VARCHAR(255) DEFAULT NULL COMMENT '未发放原因',
+    `reward` INT NOT NULL DEFAULT 0 COMMENT '发放的代币数',
+    `raw_query` TEXT NOT NULL COMMENT '回调原始参数',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '回调时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_ad_callbacks_transaction` (`network`, `transaction_id`),
+    KEY `idx_ad_callbacks_user` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='激励视频广告服务端回调记录表，按平台和交易号去重';
+
+-- 成就定义表
+CREATE TABLE IF NOT EXISTS `achievements` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键，自增',
+    `code` VARCHAR(50) NOT NULL COMMENT '成就编码',
+    `name` VARCHAR(100) NOT NULL COMMENT '名称',
+    `description` VARCHAR(255) DEFAULT NULL COMMENT '说明',
+    `kind` VARCHAR(20) NOT NULL COMMENT '类别：achievement=成就，quest=任务挑战',
+    `event_type` VARCHAR(20) NOT NULL COMMENT '推进进度的事件：task_complete/token_consume/invite/order_paid',
+    `aggregate` VARCHAR(10) NOT NULL COMMENT '累计方式：count=次数，sum=数量，distinct=不同Key个数',
+    `filter_key` VARCHAR(64) DEFAULT NULL COMMENT '只统计Key等于该值的事件，为空不限',
+    `target` BIGINT NOT NULL COMMENT '目标值',
+    `period` VARCHAR(10) NOT NULL DEFAULT 'none' COMMENT '统计周期：none/daily/weekly/monthly',
+    `reward_tokens` INT NOT NULL DEFAULT 0 COMMENT '完成奖励的代币数',
+    `badge_name` VARCHAR(50) DEFAULT NULL COMMENT '徽章名称，为空不发徽章',
+    `badge_icon` VARCHAR(255) DEFAULT NULL COMMENT '徽章图标URL',
+    `sort` INT NOT NULL DEFAULT 0 COMMENT '排序，越小越靠前',
+    `status` TINYINT NOT NULL DEFAULT 1 COMMENT '状态：1=启用，0=停用',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_achievements_code` (`code`),
+    KEY `idx_achievements_event` (`event_type`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='成就与任务挑战定义表';
+
+-- 用户成就进度表
+CREATE TABLE IF NOT EXISTS `user_achievements` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键，自增',
+    `user_id` VARCHAR(13) NOT NULL COMMENT '用户ID',
+    `achievement_id` BIGINT NOT NULL COMMENT '成就ID',
+    `period_key` VARCHAR(10) NOT NULL COMMENT '周期标识：all/2006-01-02/2006-W01/2006-01',
+    `progress` BIGINT NOT NULL DEFAULT 0 COMMENT '当前进度',
+    `completed_at` DATETIME DEFAULT NULL COMMENT '完成时间',
+    `reward_tokens` INT NOT NULL DEFAULT 0 COMMENT '完成时发放的代币数',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_user_achievements_period` (`user_id`, `achievement_id`, `period_key`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='用户成就进度表，周期性成就每个周期一条';
+
+-- 用户成就去重记录表
+CREATE TABLE IF NOT EXISTS `user_achievement_marks` (
+    `user_achievement_id` BIGINT NOT NULL COMMENT '用户成就进度ID',
+    `mark` VARCHAR(64) NOT NULL COMMENT '已计入的事件Key',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '计入时间',
+    PRIMARY KEY (`user_achievement_id`, `mark`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='按不同Key累计的成就已计入的Key';
+
 -- 邀请记录表
 CREATE TABLE IF NOT EXISTS `invite_records` (
     `record_id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '记录ID，主键，自增',
     `inviter_id` VARCHAR(13) NOT NULL  COMMENT '邀请人ID',
     `invitee_id` VARCHAR(13) NOT NULL  COMMENT '被邀请人ID',
     `token_reward` INT NOT NULL COMMENT '邀请奖励代币数',
-    `status` TINYINT NOT NULL DEFAULT 0 COMMENT '状态：0=待发放，1=已发放，2=发放失败',
+    `status` TINYINT NOT NULL DEFAULT 0 COMMENT '状态：0=待发放，1=已发放，2=发放失败，3=风控审核中',
+    `campaign_id` BIGINT DEFAULT NULL COMMENT '邀请活动ID，为空表示建立关系时没有生效的活动',
+    `invitee_reward` INT NOT NULL DEFAULT 0 COMMENT '被邀请人奖励的代币数',
+    `level2_inviter_id` VARCHAR(13) DEFAULT NULL COMMENT '二级邀请人，即邀请人的邀请人',
+    `level2_reward` INT NOT NULL DEFAULT 0 COMMENT '二级邀请人奖励的代币数',
+    `settled_at` DATETIME DEFAULT NULL COMMENT '结算时间',
     `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
     `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
     PRIMARY KEY (`record_id`),
     UNIQUE KEY `uk_invite_invitee` (`invitee_id`),
     KEY `idx_invite_inviter` (`inviter_id`),
+    KEY `idx_invite_campaign` (`campaign_id`),
+    KEY `idx_invite_level2` (`level2_inviter_id`),
     CONSTRAINT `fk_invite_inviter` FOREIGN KEY (`inviter_id`) REFERENCES `users` (`user_id`) ON DELETE CASCADE ON UPDATE CASCADE,
     CONSTRAINT `fk_invite_invitee` FOREIGN KEY (`invitee_id`) REFERENCES `users` (`user_id`) ON DELETE CASCADE ON UPDATE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
   COMMENT='邀请记录表，记录用户邀请关系和奖励发放状态';
+
+-- 邀请活动表
+CREATE TABLE IF NOT EXISTS `invite_campaigns` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键，自增',
+    `code` VARCHAR(50) NOT NULL COMMENT '活动编码',
+    `name` VARCHAR(100) NOT NULL COMMENT '名称',
+    `description` VARCHAR(255) DEFAULT NULL COMMENT '说明',
+    `inviter_reward` INT NOT NULL DEFAULT 0 COMMENT '邀请人奖励的代币数',
+    `invitee_reward` INT NOT NULL DEFAULT 0 COMMENT '被邀请人奖励的代币数',
+    `level2_reward` INT NOT NULL DEFAULT 0 COMMENT '二级奖励，发给邀请人的邀请人，0 表示不发',
+    `trigger_type` VARCHAR(20) NOT NULL COMMENT '触发条件：signup/login_day/first_purchase/spend',
+    `trigger_value` INT NOT NULL DEFAULT 0 COMMENT 'login_day 为天数，spend 为代币数',
+    `inviter_cap` INT NOT NULL DEFAULT 0 COMMENT '每个邀请人在本活动中最多获得奖励的人数，0 表示不限',
+    `start_at` DATETIME DEFAULT NULL COMMENT '开始时间，为空不限',
+    `end_at` DATETIME DEFAULT NULL COMMENT '结束时间，为空不限',
+    `sort` INT NOT NULL DEFAULT 0 COMMENT '排序，越小越优先',
+    `status` TINYINT NOT NULL DEFAULT 1 COMMENT '状态：1=启用，0=停用',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_invite_campaigns_code` (`code`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='邀请活动表，配置邀请双方的奖励、触发条件、二级奖励、邀请人上限和有效期';
+
+-- 用户邀请码表
+CREATE TABLE IF NOT EXISTS `invite_codes` (
+    `user_id` VARCHAR(13) NOT NULL COMMENT '邀请人ID',
+    `code` VARCHAR(16) NOT NULL COMMENT '邀请码，统一存为大写',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    PRIMARY KEY (`user_id`),
+    UNIQUE KEY `uk_invite_codes_code` (`code`),
+    CONSTRAINT `fk_invite_codes_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`user_id`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='用户邀请码表，每个用户一个邀请码';
+
+-- 账号令牌表（邮箱验证、密码重置）
+CREATE TABLE IF NOT EXISTS `account_tokens` (
+    `token_id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '令牌ID，主键，自增',
+    `user_id` VARCHAR(13) NOT NULL COMMENT '用户ID',
+    `purpose` VARCHAR(20) NOT NULL COMMENT '用途：email_verify=邮箱验证，password_reset=密码重置',
+    `token_hash` CHAR(64) NOT NULL COMMENT '链接令牌的SHA-256哈希',
+    `code_hash` CHAR(64) NOT NULL COMMENT '数字验证码的SHA-256哈希',
+    `email` VARCHAR(100) NOT NULL COMMENT '发送时的邮箱地址',
+    `attempts` INT NOT NULL DEFAULT 0 COMMENT '验证码错误尝试次数',
+    `expires_at` DATETIME NOT NULL COMMENT '过期时间',
+    `used_at` DATETIME DEFAULT NULL COMMENT '使用时间，非空表示已失效',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    PRIMARY KEY (`token_id`),
+    UNIQUE KEY `uk_account_tokens_hash` (`token_hash`),
+    KEY `idx_account_tokens_user_purpose` (`user_id`, `purpose`),
+    CONSTRAINT `fk_account_tokens_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`user_id`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='账号一次性令牌表';
+
+-- 订阅消息授权表
+CREATE TABLE IF NOT EXISTS `user_subscribe_auths` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键，自增',
+    `user_id` VARCHAR(13) NOT NULL COMMENT '用户ID',
+    `template_id` VARCHAR(64) NOT NULL COMMENT '订阅消息模板ID',
+    `remaining_count` INT NOT NULL DEFAULT 0 COMMENT '剩余可下发次数，一次性订阅每次授权加一',
+    `last_accepted_at` DATETIME NOT NULL COMMENT '最近一次授权时间',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_subscribe_user_template` (`user_id`, `template_id`),
+    CONSTRAINT `fk_subscribe_auths_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`user_id`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+  COMMENT='用户订阅消息授权表';